@@ -3,6 +3,7 @@ package build
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/btcsuite/btclog"
@@ -186,6 +187,56 @@ func ParseAndSetDebugLevels(level string, logger LeveledSubLogger) error {
 	return nil
 }
 
+// DebugLevelSpec returns the current log level of each subsystem of the given
+// logger as a level specification in the format accepted by
+// ParseAndSetDebugLevels, i.e. subsystem1=level1,subsystem2=level2, sorted by
+// subsystem.
+func DebugLevelSpec(logger LeveledSubLogger) string {
+	subLoggers := logger.SubLoggers()
+
+	subsystems := make([]string, 0, len(subLoggers))
+	for subsysID := range subLoggers {
+		subsystems = append(subsystems, subsysID)
+	}
+	sort.Strings(subsystems)
+
+	levels := make([]string, 0, len(subsystems))
+	for _, subsysID := range subsystems {
+		level := subLoggers[subsysID].Level()
+		levels = append(levels, fmt.Sprintf("%s=%s", subsysID,
+			logLevelName(level)))
+	}
+
+	return strings.Join(levels, ",")
+}
+
+// logLevelName returns the name of the given log level as accepted by
+// ParseAndSetDebugLevels.
+func logLevelName(level btclog.Level) string {
+	switch level {
+	case btclog.LevelTrace:
+		return "trace"
+
+	case btclog.LevelDebug:
+		return "debug"
+
+	case btclog.LevelInfo:
+		return "info"
+
+	case btclog.LevelWarn:
+		return "warn"
+
+	case btclog.LevelError:
+		return "error"
+
+	case btclog.LevelCritical:
+		return "critical"
+
+	default:
+		return "off"
+	}
+}
+
 // validLogLevel returns whether or not logLevel is a valid debug log level.
 func validLogLevel(logLevel string) bool {
 	switch logLevel {
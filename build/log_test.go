@@ -116,3 +116,26 @@ func TestParseAndSetDebugLevels(t *testing.T) {
 		})
 	}
 }
+
+// TestDebugLevelSpec tests that the current log levels of all subsystems are
+// returned as a level specification that can be parsed again.
+func TestDebugLevelSpec(t *testing.T) {
+	logWriter := build.NewRotatingLogWriter()
+	for _, subsystem := range []string{"SRVR", "PEER", "HSWC"} {
+		logWriter.RegisterSubLogger(
+			subsystem, logWriter.GenSubLogger(subsystem, nil),
+		)
+	}
+
+	require.NoError(t, build.ParseAndSetDebugLevels(
+		"info,PEER=debug,HSWC=off", logWriter,
+	))
+
+	spec := build.DebugLevelSpec(logWriter)
+	require.Equal(t, "HSWC=off,PEER=debug,SRVR=info", spec)
+
+	// The returned specification restores the same levels.
+	require.NoError(t, build.ParseAndSetDebugLevels("trace", logWriter))
+	require.NoError(t, build.ParseAndSetDebugLevels(spec, logWriter))
+	require.Equal(t, spec, build.DebugLevelSpec(logWriter))
+}
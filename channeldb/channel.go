@@ -130,6 +130,13 @@ var (
 	// TODO(roasbeef): rename to commit chain?
 	commitDiffKey = []byte("commit-diff-key")
 
+	// pendingCommitDiffsBucket is a nested bucket within the channel
+	// bucket which stores the pending commitment states we've extended to
+	// the remote party beyond the one stored under commitDiffKey, keyed by
+	// their commitment height. This is only used if the revocation window
+	// allows more than a single unrevoked remote commitment.
+	pendingCommitDiffsBucket = []byte("pending-commit-diffs")
+
 	// frozenChanKey is the key where we store the information for any
 	// active "frozen" channels. This key is present only in the leaf
 	// bucket for a given channel.
@@ -230,6 +237,10 @@ const (
 	// A tlv type definition used to serialize and deserialize the
 	// Memo for the channel channel.
 	channelMemoType tlv.Type = 5
+
+	// A tlv type used to serialize and deserialize the
+	// `CloseFeePaid` field.
+	closeFeePaidType tlv.Type = 6
 )
 
 // indexStatus is an enum-like type that describes what state the
@@ -742,6 +753,14 @@ type OpenChannel struct {
 	// received within this channel.
 	TotalMSatReceived lnwire.MilliSatoshi
 
+	// CloseFeePaid is the fee we pay to miners to close this channel.
+	// While the channel is open, this is the fee of our latest
+	// commitment, which we'd pay on a force close. Once a closing
+	// transaction is broadcast, it's the fee of that transaction. Only
+	// the initiator pays these fees, so this is always zero for channels
+	// opened by the remote party.
+	CloseFeePaid btcutil.Amount
+
 	// InitialLocalBalance is the balance we have during the channel
 	// opening. When we are not the initiator, this value represents the
 	// push amount.
@@ -781,6 +800,12 @@ type OpenChannel struct {
 	// per-commitment-point.
 	RemoteNextRevocation *btcec.PublicKey
 
+	// RemoteCommitPoints are the per-commitment-points of the remote party
+	// for the commitments following the one of RemoteNextRevocation, in
+	// order. These are only populated if the remote party extended our
+	// revocation window beyond a single commitment.
+	RemoteCommitPoints []*btcec.PublicKey
+
 	// RevocationProducer is used to generate the revocation in such a way
 	// that remote side might store it efficiently and have the ability to
 	// restore the revocation by index if needed. Current implementation of
@@ -1608,7 +1633,7 @@ func (c *OpenChannel) markBroadcasted(status ChannelStatus, key []byte,
 
 	// If a closing tx is provided, we'll generate a closure to write the
 	// transaction in the appropriate bucket under the given key.
-	var putClosingTx func(kvdb.RwBucket) error
+	var putClosingTx, putCloseFee func(kvdb.RwBucket) error
 	if closeTx != nil {
 		var b bytes.Buffer
 		if err := WriteElement(&b, closeTx); err != nil {
@@ -1618,6 +1643,18 @@ func (c *OpenChannel) markBroadcasted(status ChannelStatus, key []byte,
 		putClosingTx = func(chanBucket kvdb.RwBucket) error {
 			return chanBucket.Put(key, b.Bytes())
 		}
+
+		// As the initiator, we pay the fee of the closing
+		// transaction, so we'll record it as the final fee paid for
+		// this channel.
+		if c.IsInitiator {
+			putCloseFee = func(chanBucket kvdb.RwBucket) error {
+				return putCloseFeePaid(
+					chanBucket, &c.FundingOutpoint,
+					closeTxFee(c.Capacity, closeTx),
+				)
+			}
+		}
 	}
 
 	// Add the initiator status to the status provided. These statuses are
@@ -1629,7 +1666,54 @@ func (c *OpenChannel) markBroadcasted(status ChannelStatus, key []byte,
 		status |= ChanStatusRemoteCloseInitiator
 	}
 
-	return c.putChanStatus(status, putClosingTx)
+	putCloseEvent := func(chanBucket kvdb.RwBucket) error {
+		return c.putChannelEvent(
+			chanBucket, EventCloseInitiated,
+			c.LocalCommitment.CommitHeight,
+		)
+	}
+
+	err := c.putChanStatus(
+		status, putClosingTx, putCloseFee, putCloseEvent,
+	)
+	if err != nil {
+		return err
+	}
+
+	if putCloseFee != nil {
+		c.CloseFeePaid = closeTxFee(c.Capacity, closeTx)
+	}
+
+	return nil
+}
+
+// closeTxFee returns the fee paid by a transaction spending the funding output
+// of a channel with the given capacity.
+func closeTxFee(capacity btcutil.Amount, closeTx *wire.MsgTx) btcutil.Amount {
+	var outputTotal btcutil.Amount
+	for _, txOut := range closeTx.TxOut {
+		outputTotal += btcutil.Amount(txOut.Value)
+	}
+
+	if outputTotal > capacity {
+		return 0
+	}
+
+	return capacity - outputTotal
+}
+
+// putCloseFeePaid overwrites the close fee paid of the channel stored within
+// the passed bucket.
+func putCloseFeePaid(chanBucket kvdb.RwBucket, chanPoint *wire.OutPoint,
+	fees btcutil.Amount) error {
+
+	channel, err := fetchOpenChannel(chanBucket, chanPoint)
+	if err != nil {
+		return err
+	}
+	channel.CloseFeePaid = fees
+
+	return putChanInfo(chanBucket, channel)
 }
 
 // BroadcastedCommitment retrieves the stored unilateral closing tx set during
@@ -1916,6 +2000,13 @@ func (c *OpenChannel) UpdateCommitment(newCommitment *ChannelCommitment,
 
 	var finalHtlcs = make(map[uint64]bool)
 
+	// As the initiator pays the commitment fee, the fee of the new
+	// commitment replaces the one we'd pay to close the channel.
+	closeFeePaid := c.CloseFeePaid
+	if c.IsInitiator {
+		closeFeePaid = newCommitment.CommitFee
+	}
+
 	err := kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
 		chanBucket, err := fetchChanBucketRw(
 			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
@@ -1934,10 +2025,39 @@ func (c *OpenChannel) UpdateCommitment(newCommitment *ChannelCommitment,
 			return ErrChanBorked
 		}
 
-		if err = putChanInfo(chanBucket, c); err != nil {
+		// The new close fee is only applied to the channel once the
+		// update succeeded, so we only set it for writing the info.
+		prevCloseFeePaid := c.CloseFeePaid
+		c.CloseFeePaid = closeFeePaid
+		err = putChanInfo(chanBucket, c)
+		c.CloseFeePaid = prevCloseFeePaid
+		if err != nil {
 			return fmt.Errorf("unable to store chan info: %v", err)
 		}
 
+		// If the new commitment changes the fee rate, we'll record the
+		// transition in the channel's fee rate history.
+		oldFeePerKw := c.LocalCommitment.FeePerKw
+		if newCommitment.FeePerKw != oldFeePerKw {
+			err := appendFeeRateUpdate(chanBucket, FeeRateUpdate{
+				Height:      newCommitment.CommitHeight,
+				OldFeePerKw: oldFeePerKw,
+				NewFeePerKw: newCommitment.FeePerKw,
+			}, c.Db.parent.maxFeeRateHistory)
+			if err != nil {
+				return fmt.Errorf("unable to store fee rate "+
+					"history: %v", err)
+			}
+
+			err = c.putChannelEvent(
+				chanBucket, EventFeeUpdated,
+				newCommitment.CommitHeight,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
 		// With the proper bucket fetched, we'll now write the latest
 		// commitment state to disk for the target party.
 		err = putChanCommitment(
@@ -2039,6 +2159,7 @@ func (c *OpenChannel) UpdateCommitment(newCommitment *ChannelCommitment,
 	}
 
 	c.LocalCommitment = *newCommitment
+	c.CloseFeePaid = closeFeePaid
 
 	return finalHtlcs, nil
 }
@@ -2600,6 +2721,14 @@ func (c *OpenChannel) AppendRemoteCommitChain(diff *CommitDiff) error {
 			return err
 		}
 
+		err = c.putChannelEvent(
+			chanBucket, EventStateSigned,
+			diff.Commitment.CommitHeight,
+		)
+		if err != nil {
+			return err
+		}
+
 		// TODO(roasbeef): use seqno to derive key for later LCP
 
 		// With the bucket retrieved, we'll now serialize the commit
@@ -2608,16 +2737,89 @@ func (c *OpenChannel) AppendRemoteCommitChain(diff *CommitDiff) error {
 		if err := serializeCommitDiff(&b2, diff); err != nil {
 			return err
 		}
-		return chanBucket.Put(commitDiffKey, b2.Bytes())
+
+		// If this is the first pending commitment, or it replaces the
+		// first pending commitment, we'll store it under the commit
+		// diff key. Otherwise, the revocation window allows for
+		// multiple pending commitments, so we'll queue it behind the
+		// existing ones, keyed by its height.
+		tipBytes := chanBucket.Get(commitDiffKey)
+		if tipBytes == nil {
+			return chanBucket.Put(commitDiffKey, b2.Bytes())
+		}
+
+		tip, err := deserializeCommitDiff(bytes.NewReader(tipBytes))
+		if err != nil {
+			return err
+		}
+		if tip.Commitment.CommitHeight == diff.Commitment.CommitHeight {
+			return chanBucket.Put(commitDiffKey, b2.Bytes())
+		}
+
+		pendingBucket, err := chanBucket.CreateBucketIfNotExists(
+			pendingCommitDiffsBucket,
+		)
+		if err != nil {
+			return err
+		}
+
+		heightKey := makeLogKey(diff.Commitment.CommitHeight)
+		return pendingBucket.Put(heightKey[:], b2.Bytes())
 	}, func() {})
 }
 
+// AppendRemoteCommitPoints persists additional per-commitment-points of the
+// remote party, which extend our revocation window such that we're able to
+// extend multiple commitments to the remote party before receiving a
+// revocation. The points must follow the ones already known, starting with
+// the point of the commitment after the one of RemoteNextRevocation.
+func (c *OpenChannel) AppendRemoteCommitPoints(
+	points ...*btcec.PublicKey) error {
+
+	c.Lock()
+	defer c.Unlock()
+
+	// If this is a restored channel, then we want to avoid mutating the
+	// state at all, as it's impossible to do so in a protocol compliant
+	// manner.
+	if c.hasChanStatus(ChanStatusRestored) {
+		return ErrNoRestoredChannelMutation
+	}
+
+	prevPoints := c.RemoteCommitPoints
+	c.RemoteCommitPoints = append(
+		append([]*btcec.PublicKey(nil), prevPoints...), points...,
+	)
+
+	err := kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		return putChanRevocationState(chanBucket, c)
+	}, func() {})
+	if err != nil {
+		c.RemoteCommitPoints = prevPoints
+		return err
+	}
+
+	return nil
+}
+
 // RemoteCommitChainTip returns the "tip" of the current remote commitment
 // chain. This value will be non-nil iff, we've created a new commitment for
 // the remote party that they haven't yet ACK'd. In this case, their commitment
 // chain will have a length of two: their current unrevoked commitment, and
 // this new pending commitment. Once they revoked their prior state, we'll swap
 // these pointers, causing the tip and the tail to point to the same entry.
+//
+// NOTE: If the revocation window allows for more than a single pending
+// commitment, this returns the first pending commitment, which is the one
+// that follows their current unrevoked commitment. Use
+// RemoteCommitChainPending to retrieve all pending commitments.
 func (c *OpenChannel) RemoteCommitChainTip() (*CommitDiff, error) {
 	var cd *CommitDiff
 	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
@@ -2655,6 +2857,145 @@ func (c *OpenChannel) RemoteCommitChainTip() (*CommitDiff, error) {
 	return cd, err
 }
 
+// RemoteCommitChainPending returns all pending commitments we've extended to
+// the remote party that they haven't yet ACK'd, ordered by their height. An
+// empty slice is returned if there are no pending commitments.
+func (c *OpenChannel) RemoteCommitChainPending() ([]*CommitDiff, error) {
+	var diffs []*CommitDiff
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		switch err {
+		case nil:
+		case ErrNoChanDBExists, ErrNoActiveChannels, ErrChannelNotFound:
+			return nil
+		default:
+			return err
+		}
+
+		tipBytes := chanBucket.Get(commitDiffKey)
+		if tipBytes == nil {
+			return nil
+		}
+
+		diff, err := deserializeCommitDiff(bytes.NewReader(tipBytes))
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, diff)
+
+		pendingBucket := chanBucket.NestedReadBucket(
+			pendingCommitDiffsBucket,
+		)
+		if pendingBucket == nil {
+			return nil
+		}
+
+		return pendingBucket.ForEach(func(_, v []byte) error {
+			diff, err := deserializeCommitDiff(bytes.NewReader(v))
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, diff)
+
+			return nil
+		})
+	}, func() {
+		diffs = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return diffs, nil
+}
+
+// DiscardRemoteCommitChainTip removes the latest pending commitment we've
+// extended to the remote party, which must be at the given height, from disk.
+// This must only be used if the commitment was never sent to the remote party,
+// as we're unable to retransmit it or to act on its broadcast afterwards.
+// ErrNoPendingCommit is returned if there's no pending commitment at this
+// height, or if it's followed by another pending commitment.
+func (c *OpenChannel) DiscardRemoteCommitChainTip(height uint64) error {
+	c.Lock()
+	defer c.Unlock()
+
+	// If this is a restored channel, then we want to avoid mutating the
+	// state at all, as it's impossible to do so in a protocol compliant
+	// manner.
+	if c.hasChanStatus(ChanStatusRestored) {
+		return ErrNoRestoredChannelMutation
+	}
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		// If further commitments are queued behind the first pending
+		// one, the tip is the last of them.
+		pendingBucket := chanBucket.NestedReadWriteBucket(
+			pendingCommitDiffsBucket,
+		)
+		if pendingBucket != nil {
+			cursor := pendingBucket.ReadWriteCursor()
+			heightKey, _ := cursor.Last()
+			if heightKey != nil {
+				if byteOrder.Uint64(heightKey) != height {
+					return ErrNoPendingCommit
+				}
+
+				return cursor.Delete()
+			}
+		}
+
+		tipBytes := chanBucket.Get(commitDiffKey)
+		if tipBytes == nil {
+			return ErrNoPendingCommit
+		}
+
+		tip, err := deserializeCommitDiff(bytes.NewReader(tipBytes))
+		if err != nil {
+			return err
+		}
+		if tip.Commitment.CommitHeight != height {
+			return ErrNoPendingCommit
+		}
+
+		return chanBucket.Delete(commitDiffKey)
+	}, func() {})
+}
+
+// popPendingCommitDiff moves the lowest queued pending commitment, if any, to
+// the commit diff key, making it the first pending commitment.
+func popPendingCommitDiff(chanBucket kvdb.RwBucket) error {
+	pendingBucket := chanBucket.NestedReadWriteBucket(
+		pendingCommitDiffsBucket,
+	)
+	if pendingBucket == nil {
+		return nil
+	}
+
+	cursor := pendingBucket.ReadWriteCursor()
+	heightKey, diffBytes := cursor.First()
+	if heightKey == nil {
+		return nil
+	}
+
+	// Copy the diff before deleting the entry, as the returned slice is
+	// only valid until the bucket is modified.
+	diffBytes = append([]byte(nil), diffBytes...)
+	if err := chanBucket.Put(commitDiffKey, diffBytes); err != nil {
+		return err
+	}
+
+	return cursor.Delete()
+}
+
 // UnsignedAckedUpdates retrieves the persisted unsigned acked remote log
 // updates that still need to be signed for.
 func (c *OpenChannel) UnsignedAckedUpdates() ([]LogUpdate, error) {
@@ -2754,6 +3095,44 @@ func (c *OpenChannel) InsertNextRevocation(revKey *btcec.PublicKey) error {
 	return nil
 }
 
+// ReplaceRevocationState replaces the revocation producer and revocation store
+// of the channel with the passed ones, and writes them to disk. Callers are
+// responsible for making sure the new state is consistent with the
+// commitments of the channel.
+func (c *OpenChannel) ReplaceRevocationState(producer shachain.Producer,
+	store shachain.Store) error {
+
+	c.Lock()
+	defer c.Unlock()
+
+	// If this is a restored channel, then we want to avoid mutating the
+	// state at all, as it's impossible to do so in a protocol compliant
+	// manner.
+	if c.hasChanStatus(ChanStatusRestored) {
+		return ErrNoRestoredChannelMutation
+	}
+
+	prevProducer, prevStore := c.RevocationProducer, c.RevocationStore
+	c.RevocationProducer, c.RevocationStore = producer, store
+
+	err := kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		return putChanRevocationState(chanBucket, c)
+	}, func() {})
+	if err != nil {
+		c.RevocationProducer, c.RevocationStore = prevProducer, prevStore
+		return err
+	}
+
+	return nil
+}
+
 // AdvanceCommitChainTail records the new state transition within an on-disk
 // append-only log which records all state transitions by the remote peer. In
 // the case of an uncooperative broadcast of a prior state by the remote peer,
@@ -2803,6 +3182,13 @@ func (c *OpenChannel) AdvanceCommitChainTail(fwdPkg *FwdPkg,
 			return err
 		}
 
+		// The HTLCs settled in the revoked commitment are accounted
+		// for in the totals sent and received within the channel, so
+		// we persist those as well.
+		if err := putChanInfo(chanBucket, c); err != nil {
+			return err
+		}
+
 		// With the current preimage producer/store state updated,
 		// append a new log entry recording this the delta of this
 		// state transition.
@@ -2835,6 +3221,12 @@ func (c *OpenChannel) AdvanceCommitChainTail(fwdPkg *FwdPkg,
 			return err
 		}
 
+		// If we extended further commitments to the remote party, the
+		// next one now becomes the first pending commitment.
+		if err := popPendingCommitDiff(chanBucket); err != nil {
+			return err
+		}
+
 		// With the commitment pointer swapped, we can now add the
 		// revoked (prior) state to the revocation log.
 		err = putRevocationLog(
@@ -2845,6 +3237,14 @@ func (c *OpenChannel) AdvanceCommitChainTail(fwdPkg *FwdPkg,
 			return err
 		}
 
+		err = c.putChannelEvent(
+			chanBucket, EventRevocationReceived,
+			c.RemoteCommitment.CommitHeight,
+		)
+		if err != nil {
+			return err
+		}
+
 		// Lastly, we write the forwarding package to disk so that we
 		// can properly recover from failures and reforward HTLCs that
 		// have not received a corresponding settle/fail.
@@ -3033,6 +3433,19 @@ func (c *OpenChannel) SetFwdFilter(height uint64, fwdFilter *PkgFilter) error {
 	}, func() {})
 }
 
+// SetHtlcIntercept atomically records the decision of the HTLC interceptor
+// about the Add at `index` in the forwarding package identified by `height`.
+func (c *OpenChannel) SetHtlcIntercept(height uint64, index uint16,
+	intercept *HtlcIntercept) error {
+
+	c.Lock()
+	defer c.Unlock()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		return c.Packager.SetHtlcIntercept(tx, height, index, intercept)
+	}, func() {})
+}
+
 // RemoveFwdPkgs atomically removes forwarding packages specified by the remote
 // commitment heights. If one of the intermediate RemovePkg calls fails, then the
 // later packages won't be removed.
@@ -3808,6 +4221,7 @@ func putChanInfo(chanBucket kvdb.RwBucket, channel *OpenChannel) error {
 	// Convert balance fields into uint64.
 	localBalance := uint64(channel.InitialLocalBalance)
 	remoteBalance := uint64(channel.InitialRemoteBalance)
+	closeFeePaid := uint64(channel.CloseFeePaid)
 
 	// Create the tlv stream.
 	tlvStream, err := tlv.NewStream(
@@ -3824,6 +4238,7 @@ func putChanInfo(chanBucket kvdb.RwBucket, channel *OpenChannel) error {
 		),
 		MakeScidRecord(realScidType, &channel.confirmedScid),
 		tlv.MakePrimitiveRecord(channelMemoType, &channel.Memo),
+		tlv.MakePrimitiveRecord(closeFeePaidType, &closeFeePaid),
 	)
 	if err != nil {
 		return err
@@ -3956,6 +4371,17 @@ func putChanRevocationState(chanBucket kvdb.RwBucket, channel *OpenChannel) erro
 		if err != nil {
 			return err
 		}
+
+		// Any additional commitment points extending the revocation
+		// window follow the next revocation.
+		if len(channel.RemoteCommitPoints) > 0 {
+			err := serializeCommitPoints(
+				&b, channel.RemoteCommitPoints,
+			)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	return chanBucket.Put(revocationStateKey, b.Bytes())
@@ -4023,6 +4449,7 @@ func fetchChanInfo(chanBucket kvdb.RBucket, channel *OpenChannel) error {
 	var (
 		localBalance  uint64
 		remoteBalance uint64
+		closeFeePaid  uint64
 		memo          []byte
 	)
 
@@ -4041,6 +4468,7 @@ func fetchChanInfo(chanBucket kvdb.RBucket, channel *OpenChannel) error {
 		),
 		MakeScidRecord(realScidType, &channel.confirmedScid),
 		tlv.MakePrimitiveRecord(channelMemoType, &memo),
+		tlv.MakePrimitiveRecord(closeFeePaidType, &closeFeePaid),
 	)
 	if err != nil {
 		return err
@@ -4053,6 +4481,7 @@ func fetchChanInfo(chanBucket kvdb.RBucket, channel *OpenChannel) error {
 	// Attach the balance fields.
 	channel.InitialLocalBalance = lnwire.MilliSatoshi(localBalance)
 	channel.InitialRemoteBalance = lnwire.MilliSatoshi(remoteBalance)
+	channel.CloseFeePaid = btcutil.Amount(closeFeePaid)
 
 	// Attach the memo field if non-empty.
 	if len(memo) > 0 {
@@ -4152,9 +4581,54 @@ func fetchChanRevocationState(chanBucket kvdb.RBucket, channel *OpenChannel) err
 		return nil
 	}
 
-	// Otherwise we'll read the next revocation for the remote party which
-	// is always the last item within the buffer.
-	return ReadElements(r, &channel.RemoteNextRevocation)
+	// Otherwise we'll read the next revocation for the remote party.
+	err = ReadElements(r, &channel.RemoteNextRevocation)
+	if err != nil {
+		return err
+	}
+
+	// Finally, read any commitment points extending the revocation window
+	// if present.
+	if r.Len() == 0 {
+		return nil
+	}
+
+	channel.RemoteCommitPoints, err = deserializeCommitPoints(r)
+	return err
+}
+
+// serializeCommitPoints serializes a list of commitment points to a stream.
+func serializeCommitPoints(w io.Writer, points []*btcec.PublicKey) error {
+	numPoints := uint16(len(points))
+	if err := binary.Write(w, byteOrder, numPoints); err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		if err := WriteElement(w, point); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deserializeCommitPoints deserializes a list of commitment points from a
+// stream.
+func deserializeCommitPoints(r io.Reader) ([]*btcec.PublicKey, error) {
+	var numPoints uint16
+	if err := binary.Read(r, byteOrder, &numPoints); err != nil {
+		return nil, err
+	}
+
+	points := make([]*btcec.PublicKey, numPoints)
+	for i := range points {
+		if err := ReadElement(r, &points[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return points, nil
 }
 
 func deleteOpenChannel(chanBucket kvdb.RwBucket) error {
@@ -4175,6 +4649,14 @@ func deleteOpenChannel(chanBucket kvdb.RwBucket) error {
 		return err
 	}
 
+	if err := chanBucket.Delete(feeRateHistoryKey); err != nil {
+		return err
+	}
+
+	if err := deleteUpdateLogOverflow(chanBucket); err != nil {
+		return err
+	}
+
 	if diff := chanBucket.Get(commitDiffKey); diff != nil {
 		return chanBucket.Delete(commitDiffKey)
 	}
@@ -0,0 +1,284 @@
+package channeldb
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+const (
+	// DefaultMaxChannelEvents is the default number of events we'll keep
+	// in the event log of each channel before the oldest entries are
+	// compacted.
+	DefaultMaxChannelEvents = 1000
+)
+
+var (
+	// channelEventLogBucket is a nested bucket within the channel bucket
+	// which stores the bounded log of significant events of the channel,
+	// keyed by an increasing sequence number.
+	channelEventLogBucket = []byte("channel-event-log")
+)
+
+// ChannelEventType is the type of an event recorded in the event log of a
+// channel.
+type ChannelEventType uint8
+
+const (
+	// EventStateSigned is recorded when we sign a new commitment for the
+	// remote party. The height is the one of the signed commitment.
+	EventStateSigned ChannelEventType = iota
+
+	// EventRevocationReceived is recorded when the remote party revokes
+	// their prior commitment. The height is the one of the revoked
+	// commitment.
+	EventRevocationReceived
+
+	// EventFeeUpdated is recorded when a new fee rate is locked into our
+	// local commitment. The height is the one of the first local
+	// commitment using the new fee rate.
+	EventFeeUpdated
+
+	// EventCloseInitiated is recorded when a closing transaction of the
+	// channel, either cooperative or unilateral, is marked as broadcast.
+	// The height is the one of our local commitment at that time.
+	EventCloseInitiated
+
+	// EventBreachDetected is recorded when the remote party broadcast a
+	// revoked commitment. The height is the one of the revoked
+	// commitment.
+	EventBreachDetected
+)
+
+// String returns a human-readable name of the event type.
+func (e ChannelEventType) String() string {
+	switch e {
+	case EventStateSigned:
+		return "StateSigned"
+
+	case EventRevocationReceived:
+		return "RevocationReceived"
+
+	case EventFeeUpdated:
+		return "FeeUpdated"
+
+	case EventCloseInitiated:
+		return "CloseInitiated"
+
+	case EventBreachDetected:
+		return "BreachDetected"
+
+	default:
+		return fmt.Sprintf("UnknownEvent(%d)", uint8(e))
+	}
+}
+
+// ChannelEvent is a significant event in the lifetime of a channel, recorded
+// for post-mortem debugging.
+type ChannelEvent struct {
+	// Type is the type of the event.
+	Type ChannelEventType
+
+	// Timestamp is the time the event was recorded at.
+	Timestamp time.Time
+
+	// Height is the commitment height the event refers to, see the
+	// documentation of the event types.
+	Height uint64
+}
+
+// serializeChannelEvent serializes the passed event.
+func serializeChannelEvent(event ChannelEvent) ([]byte, error) {
+	var b bytes.Buffer
+	err := WriteElements(
+		&b, uint8(event.Type), uint64(event.Timestamp.UnixNano()),
+		event.Height,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// deserializeChannelEvent deserializes an event from its raw bytes.
+func deserializeChannelEvent(eventBytes []byte) (ChannelEvent, error) {
+	var (
+		event     ChannelEvent
+		eventType uint8
+		unixNano  uint64
+	)
+	err := ReadElements(
+		bytes.NewReader(eventBytes), &eventType, &unixNano,
+		&event.Height,
+	)
+	if err != nil {
+		return event, err
+	}
+
+	event.Type = ChannelEventType(eventType)
+	event.Timestamp = time.Unix(0, int64(unixNano))
+
+	return event, nil
+}
+
+// appendChannelEvent adds a new event to the event log stored within the
+// passed channel bucket. If the log grows beyond maxEntries, the oldest
+// entries are deleted until it fits again. A maxEntries of zero disables the
+// event log altogether.
+func appendChannelEvent(chanBucket kvdb.RwBucket, event ChannelEvent,
+	maxEntries int) error {
+
+	if maxEntries <= 0 {
+		return nil
+	}
+
+	logBucket, err := chanBucket.CreateBucketIfNotExists(
+		channelEventLogBucket,
+	)
+	if err != nil {
+		return err
+	}
+
+	seqNo, err := logBucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	eventBytes, err := serializeChannelEvent(event)
+	if err != nil {
+		return err
+	}
+
+	seqKey := makeLogKey(seqNo)
+	if err := logBucket.Put(seqKey[:], eventBytes); err != nil {
+		return err
+	}
+
+	// As entries are only ever deleted from the front, the sequence
+	// numbers of the log are contiguous, so the number of entries follows
+	// from the oldest one.
+	cursor := logBucket.ReadWriteCursor()
+	for firstKey, _ := cursor.First(); firstKey != nil; {
+		numEntries := seqNo - byteOrder.Uint64(firstKey) + 1
+		if numEntries <= uint64(maxEntries) {
+			break
+		}
+
+		if err := cursor.Delete(); err != nil {
+			return err
+		}
+		firstKey, _ = cursor.First()
+	}
+
+	return nil
+}
+
+// fetchChannelEvents reads the event log stored within the passed channel
+// bucket, ordered from oldest to newest.
+func fetchChannelEvents(chanBucket kvdb.RBucket) ([]ChannelEvent, error) {
+	logBucket := chanBucket.NestedReadBucket(channelEventLogBucket)
+	if logBucket == nil {
+		return nil, nil
+	}
+
+	var events []ChannelEvent
+	err := logBucket.ForEach(func(_, eventBytes []byte) error {
+		event, err := deserializeChannelEvent(eventBytes)
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// newChannelEvent returns an event of the given type and height, timestamped
+// with the current time of the database's clock.
+func (c *OpenChannel) newChannelEvent(eventType ChannelEventType,
+	height uint64) ChannelEvent {
+
+	return ChannelEvent{
+		Type:      eventType,
+		Timestamp: c.Db.parent.clock.Now(),
+		Height:    height,
+	}
+}
+
+// putChannelEvent adds an event of the given type and height to the event log
+// stored within the passed channel bucket.
+func (c *OpenChannel) putChannelEvent(chanBucket kvdb.RwBucket,
+	eventType ChannelEventType, height uint64) error {
+
+	err := appendChannelEvent(
+		chanBucket, c.newChannelEvent(eventType, height),
+		c.Db.parent.maxChannelEvents,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to store channel event: %w", err)
+	}
+
+	return nil
+}
+
+// AppendChannelEvent adds an event of the given type and height to the event
+// log of the channel. Most events are recorded along with the state
+// transitions they refer to, this is meant for events detected outside of the
+// channel state machine, such as breaches.
+func (c *OpenChannel) AppendChannelEvent(eventType ChannelEventType,
+	height uint64) error {
+
+	c.Lock()
+	defer c.Unlock()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		return c.putChannelEvent(chanBucket, eventType, height)
+	}, func() {})
+}
+
+// EventLog returns the recorded events of the channel, ordered from oldest to
+// newest. The log is bounded, see DefaultMaxChannelEvents, so the oldest
+// events may have been compacted.
+func (c *OpenChannel) EventLog() ([]ChannelEvent, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var events []ChannelEvent
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		switch err {
+		case nil:
+		case ErrNoChanDBExists, ErrNoActiveChannels, ErrChannelNotFound:
+			return nil
+		default:
+			return err
+		}
+
+		events, err = fetchChannelEvents(chanBucket)
+		return err
+	}, func() {
+		events = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
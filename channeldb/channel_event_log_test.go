@@ -0,0 +1,67 @@
+package channeldb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChannelEventLog asserts that channel events are recorded in order, and
+// that the oldest events are deleted once the log exceeds the configured
+// maximum.
+func TestChannelEventLog(t *testing.T) {
+	t.Parallel()
+
+	const maxEvents = 3
+
+	now := time.Unix(1700000000, 0)
+	testClock := clock.NewTestClock(now)
+	fullDB, err := MakeTestDB(
+		t, OptionSetMaxChannelEvents(maxEvents), OptionClock(testClock),
+	)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+	channel := createTestChannel(t, cdb, openChannelOption())
+
+	events, err := channel.EventLog()
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	// Record a number of events that stays within the limit, advancing
+	// the clock in between.
+	for height := uint64(1); height <= maxEvents; height++ {
+		testClock.SetTime(now.Add(time.Duration(height) * time.Second))
+		err := channel.AppendChannelEvent(EventBreachDetected, height)
+		require.NoError(t, err)
+	}
+
+	events, err = channel.EventLog()
+	require.NoError(t, err)
+	require.Len(t, events, maxEvents)
+	for i, event := range events {
+		height := uint64(i + 1)
+		require.Equal(t, EventBreachDetected, event.Type)
+		require.Equal(t, height, event.Height)
+		require.True(t, event.Timestamp.Equal(
+			now.Add(time.Duration(height)*time.Second),
+		))
+	}
+
+	// Marking a closing transaction as broadcast records another event,
+	// which pushes the oldest one out of the log.
+	err = channel.MarkCommitmentBroadcasted(nil, true)
+	require.NoError(t, err)
+
+	events, err = channel.EventLog()
+	require.NoError(t, err)
+	require.Len(t, events, maxEvents)
+	require.EqualValues(t, 2, events[0].Height)
+	require.Equal(t, EventCloseInitiated, events[maxEvents-1].Type)
+	require.Equal(
+		t, channel.LocalCommitment.CommitHeight,
+		events[maxEvents-1].Height,
+	)
+}
@@ -324,6 +324,7 @@ func createTestChannelState(t *testing.T, cdb *ChannelStateDB) *OpenChannel {
 		RemoteChanCfg:     remoteCfg,
 		TotalMSatSent:     8,
 		TotalMSatReceived: 2,
+		CloseFeePaid:      3,
 		LocalCommitment: ChannelCommitment{
 			CommitHeight:  0,
 			LocalBalance:  lnwire.MilliSatoshi(9000),
@@ -1385,6 +1386,78 @@ func TestCloseChannelStatus(t *testing.T) {
 	}
 }
 
+// TestCloseFeePaidOnClose asserts that the fee of a broadcast closing
+// transaction is recorded as the close fee paid, and that it survives
+// closing the channel.
+func TestCloseFeePaidOnClose(t *testing.T) {
+	t.Parallel()
+
+	fullDB, err := MakeTestDB(t)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+	channel := createTestChannel(t, cdb, openChannelOption())
+
+	const closeFee = btcutil.Amount(1000)
+	closeTx := wire.NewMsgTx(2)
+	closeTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: channel.FundingOutpoint,
+	})
+	closeTx.AddTxOut(&wire.TxOut{
+		Value: int64(channel.Capacity - closeFee),
+	})
+
+	err = channel.MarkCoopBroadcasted(closeTx, true)
+	require.NoError(t, err)
+	require.Equal(t, closeFee, channel.CloseFeePaid)
+
+	err = channel.CloseChannel(&ChannelCloseSummary{
+		ChanPoint: channel.FundingOutpoint,
+		RemotePub: channel.IdentityPub,
+	})
+	require.NoError(t, err)
+
+	histChan, err := channel.Db.FetchHistoricalChannel(
+		&channel.FundingOutpoint,
+	)
+	require.NoError(t, err)
+	require.Equal(t, closeFee, histChan.CloseFeePaid)
+}
+
+// TestCloseFeePaidOnUpdateCommitment asserts that the initiator's close fee
+// follows the fee of its latest commitment, and that it's only updated if the
+// commitment is written successfully.
+func TestCloseFeePaidOnUpdateCommitment(t *testing.T) {
+	t.Parallel()
+
+	fullDB, err := MakeTestDB(t)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+	channel := createTestChannel(t, cdb, openChannelOption())
+	require.True(t, channel.IsInitiator)
+
+	commitment := channel.LocalCommitment
+	commitment.CommitHeight++
+	commitment.CommitFee = 55
+	_, err = channel.UpdateCommitment(&commitment, nil)
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(55), channel.CloseFeePaid)
+
+	channels, err := cdb.FetchOpenChannels(channel.IdentityPub)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	require.Equal(t, btcutil.Amount(55), channels[0].CloseFeePaid)
+
+	// A failed update leaves the close fee untouched.
+	require.NoError(t, channel.MarkBorked())
+	commitment.CommitHeight++
+	commitment.CommitFee = 77
+	_, err = channel.UpdateCommitment(&commitment, nil)
+	require.ErrorIs(t, err, ErrChanBorked)
+	require.Equal(t, btcutil.Amount(55), channel.CloseFeePaid)
+}
+
 // TestHasChanStatus asserts the behavior of HasChanStatus by checking the
 // behavior of various status flags in addition to the special case of
 // ChanStatusDefault which is treated like a flag in the code base even though
@@ -1633,3 +1706,114 @@ func TestOnionBlobIncorrectLength(t *testing.T) {
 	_, err := DeserializeHtlcs(&b)
 	require.ErrorIs(t, err, ErrOnionBlobLength)
 }
+
+// TestRemoteCommitChainPendingDiffs asserts that multiple pending commitments
+// extended to the remote party are persisted in order, survive reloading the
+// channel from disk, and are popped one by one as the remote party revokes.
+func TestRemoteCommitChainPendingDiffs(t *testing.T) {
+	t.Parallel()
+
+	fullDB, err := MakeTestDB(t)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+	channel := createTestChannel(t, cdb)
+
+	// newDiff creates a commit diff for the remote commitment at the given
+	// height, which adds a single HTLC.
+	newDiff := func(height uint64) *CommitDiff {
+		commit := channel.RemoteCommitment
+		commit.CommitHeight = height
+		commit.LocalBalance -= lnwire.MilliSatoshi(height * 1000)
+		commit.Htlcs = nil
+
+		return &CommitDiff{
+			Commitment: commit,
+			CommitSig: &lnwire.CommitSig{
+				ChanID: lnwire.NewChanIDFromOutPoint(
+					&channel.FundingOutpoint,
+				),
+				CommitSig: wireSig,
+				ExtraData: make([]byte, 0),
+			},
+			LogUpdates: []LogUpdate{{
+				LogIndex: height,
+				UpdateMsg: &lnwire.UpdateAddHTLC{
+					ID:        height,
+					Amount:    lnwire.MilliSatoshi(1000),
+					Expiry:    uint32(height),
+					ExtraData: make([]byte, 0),
+				},
+			}},
+			OpenedCircuitKeys: []models.CircuitKey{},
+			ClosedCircuitKeys: []models.CircuitKey{},
+		}
+	}
+
+	// We extend two commitments to the remote party before they revoke
+	// their current one.
+	firstDiff := newDiff(channel.RemoteCommitment.CommitHeight + 1)
+	secondDiff := newDiff(channel.RemoteCommitment.CommitHeight + 2)
+	require.NoError(t, channel.AppendRemoteCommitChain(firstDiff))
+	require.NoError(t, channel.AppendRemoteCommitChain(secondDiff))
+
+	// After reloading the channel from disk, the tip is the first pending
+	// commitment, while both are returned in order.
+	channels, err := cdb.FetchOpenChannels(channel.IdentityPub)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	channel = channels[0]
+
+	tip, err := channel.RemoteCommitChainTip()
+	require.NoError(t, err)
+	require.Equal(t, firstDiff, tip)
+
+	pending, err := channel.RemoteCommitChainPending()
+	require.NoError(t, err)
+	require.Equal(t, []*CommitDiff{firstDiff, secondDiff}, pending)
+
+	// Once the remote party revokes their current commitment, the first
+	// pending commitment becomes their current one, and the second one
+	// becomes the tip.
+	fwdPkg := NewFwdPkg(
+		channel.ShortChanID(), channel.RemoteCommitment.CommitHeight,
+		nil, nil,
+	)
+	err = channel.AdvanceCommitChainTail(
+		fwdPkg, nil, dummyLocalOutputIndex, dummyRemoteOutIndex,
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t, firstDiff.Commitment.CommitHeight,
+		channel.RemoteCommitment.CommitHeight,
+	)
+
+	tip, err = channel.RemoteCommitChainTip()
+	require.NoError(t, err)
+	require.Equal(t, secondDiff, tip)
+
+	pending, err = channel.RemoteCommitChainPending()
+	require.NoError(t, err)
+	require.Equal(t, []*CommitDiff{secondDiff}, pending)
+
+	// Revoking the next commitment leaves no pending commitment behind.
+	fwdPkg = NewFwdPkg(
+		channel.ShortChanID(), channel.RemoteCommitment.CommitHeight,
+		nil, nil,
+	)
+	err = channel.AdvanceCommitChainTail(
+		fwdPkg, nil, dummyLocalOutputIndex, dummyRemoteOutIndex,
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t, secondDiff.Commitment.CommitHeight,
+		channel.RemoteCommitment.CommitHeight,
+	)
+
+	_, err = channel.RemoteCommitChainTip()
+	require.ErrorIs(t, err, ErrNoPendingCommit)
+
+	pending, err = channel.RemoteCommitChainPending()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
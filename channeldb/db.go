@@ -334,6 +334,14 @@ type DB struct {
 	// noRevLogAmtData if true, means that commitment transaction amount
 	// data should not be stored in the revocation log.
 	noRevLogAmtData bool
+
+	// maxFeeRateHistory is the maximum number of fee rate transitions
+	// stored for each channel.
+	maxFeeRateHistory int
+
+	// maxChannelEvents is the maximum number of events stored in the
+	// event log of each channel.
+	maxChannelEvents int
 }
 
 // Open opens or creates channeldb. Any necessary schemas migrations due
@@ -393,6 +401,8 @@ func CreateWithBackend(backend kvdb.Backend,
 		keepFailedPaymentAttempts: opts.keepFailedPaymentAttempts,
 		storeFinalHtlcResolutions: opts.storeFinalHtlcResolutions,
 		noRevLogAmtData:           opts.NoRevLogAmtData,
+		maxFeeRateHistory:         opts.maxFeeRateHistory,
+		maxChannelEvents:          opts.maxChannelEvents,
 	}
 
 	// Set the parent pointer (only used in tests).
@@ -1295,6 +1305,13 @@ type ChannelShell struct {
 	Chan *OpenChannel
 }
 
+// MarkRestored marks the channel of the shell as restored, without writing it
+// to disk. This signals to other sub-systems to not attempt to use the channel
+// as if it was a regular one.
+func (c *ChannelShell) MarkRestored() {
+	c.Chan.chanStatus |= ChanStatusRestored
+}
+
 // RestoreChannelShells is a method that allows the caller to reconstruct the
 // state of an OpenChannel from the ChannelShell. We'll attempt to write the
 // new channel to disk, create a LinkNode instance with the passed node
@@ -1310,7 +1327,7 @@ func (c *ChannelStateDB) RestoreChannelShells(channelShells ...*ChannelShell) er
 			// been restored, this will signal to other sub-systems
 			// to not attempt to use the channel as if it was a
 			// regular one.
-			channel.chanStatus |= ChanStatusRestored
+			channelShell.MarkRestored()
 
 			// First, we'll attempt to create a new open channel
 			// and link node for this channel. If the channel
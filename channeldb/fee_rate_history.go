@@ -0,0 +1,157 @@
+package channeldb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+const (
+	// DefaultMaxFeeRateHistory is the default number of fee rate
+	// transitions we'll keep on disk for each channel before older entries
+	// are compacted.
+	DefaultMaxFeeRateHistory = 100
+)
+
+var (
+	// feeRateHistoryKey can be accessed within the bucket for a channel
+	// (identified by its chanPoint). This key stores the bounded, append
+	// only history of fee rate changes committed to the local commitment.
+	feeRateHistoryKey = []byte("fee-rate-history-key")
+)
+
+// FeeRateUpdate records a single change of the fee rate of the local
+// commitment transaction.
+type FeeRateUpdate struct {
+	// Height is the height of the first local commitment that uses the new
+	// fee rate.
+	Height uint64
+
+	// OldFeePerKw is the fee rate in sat/kw used before the update.
+	OldFeePerKw btcutil.Amount
+
+	// NewFeePerKw is the fee rate in sat/kw used after the update.
+	NewFeePerKw btcutil.Amount
+}
+
+// serializeFeeRateHistory serializes the passed fee rate updates to a stream.
+func serializeFeeRateHistory(w io.Writer, updates []FeeRateUpdate) error {
+	numUpdates := uint16(len(updates))
+	if err := binary.Write(w, byteOrder, numUpdates); err != nil {
+		return err
+	}
+
+	for _, update := range updates {
+		err := WriteElements(
+			w, update.Height, update.OldFeePerKw,
+			update.NewFeePerKw,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deserializeFeeRateHistory deserializes a list of fee rate updates from a
+// stream.
+func deserializeFeeRateHistory(r io.Reader) ([]FeeRateUpdate, error) {
+	var numUpdates uint16
+	if err := binary.Read(r, byteOrder, &numUpdates); err != nil {
+		return nil, err
+	}
+
+	updates := make([]FeeRateUpdate, numUpdates)
+	for i := 0; i < int(numUpdates); i++ {
+		err := ReadElements(
+			r, &updates[i].Height, &updates[i].OldFeePerKw,
+			&updates[i].NewFeePerKw,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return updates, nil
+}
+
+// fetchFeeRateHistory reads the fee rate history stored within the passed
+// channel bucket. A nil slice is returned if no fee rate changes have been
+// recorded yet.
+func fetchFeeRateHistory(chanBucket kvdb.RBucket) ([]FeeRateUpdate, error) {
+	historyBytes := chanBucket.Get(feeRateHistoryKey)
+	if historyBytes == nil {
+		return nil, nil
+	}
+
+	return deserializeFeeRateHistory(bytes.NewReader(historyBytes))
+}
+
+// appendFeeRateUpdate adds a new fee rate update to the history stored within
+// the passed channel bucket. If the history grows beyond maxEntries, the two
+// oldest entries are merged into a single transition until it fits again.
+// This retains the original fee rate of the channel and the most recent
+// transitions, while only losing intermediate rates. A maxEntries of zero
+// disables the history altogether.
+func appendFeeRateUpdate(chanBucket kvdb.RwBucket, update FeeRateUpdate,
+	maxEntries int) error {
+
+	if maxEntries <= 0 {
+		return nil
+	}
+
+	history, err := fetchFeeRateHistory(chanBucket)
+	if err != nil {
+		return err
+	}
+	history = append(history, update)
+
+	for len(history) > maxEntries {
+		history[1].OldFeePerKw = history[0].OldFeePerKw
+		history = history[1:]
+	}
+
+	var b bytes.Buffer
+	if err := serializeFeeRateHistory(&b, history); err != nil {
+		return err
+	}
+
+	return chanBucket.Put(feeRateHistoryKey, b.Bytes())
+}
+
+// FeeRateHistory returns the recorded history of fee rate changes of the
+// local commitment transaction, ordered from oldest to newest. The history is
+// bounded, see DefaultMaxFeeRateHistory, so older transitions may have been
+// merged together.
+func (c *OpenChannel) FeeRateHistory() ([]FeeRateUpdate, error) {
+	c.RLock()
+	defer c.RUnlock()
+
+	var history []FeeRateUpdate
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		switch err {
+		case nil:
+		case ErrNoChanDBExists, ErrNoActiveChannels, ErrChannelNotFound:
+			return nil
+		default:
+			return err
+		}
+
+		history, err = fetchFeeRateHistory(chanBucket)
+		return err
+	}, func() {
+		history = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,95 @@
+package channeldb
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFeeRateHistory asserts that fee rate changes of the local commitment
+// are recorded in order, and that the history is compacted once it exceeds
+// the configured maximum.
+func TestFeeRateHistory(t *testing.T) {
+	t.Parallel()
+
+	const maxHistory = 3
+
+	fullDB, err := MakeTestDB(t, OptionSetMaxFeeRateHistory(maxHistory))
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+	channel := createTestChannel(t, cdb, openChannelOption())
+
+	// updateFee writes a new local commitment with the given fee rate.
+	updateFee := func(feePerKw btcutil.Amount) {
+		t.Helper()
+
+		commit := channel.LocalCommitment
+		commit.CommitHeight++
+		commit.FeePerKw = feePerKw
+
+		_, err := channel.UpdateCommitment(&commit, nil)
+		require.NoError(t, err)
+	}
+
+	initialFee := channel.LocalCommitment.FeePerKw
+	initialHeight := channel.LocalCommitment.CommitHeight
+
+	// A commitment that keeps the fee rate shouldn't be recorded.
+	updateFee(initialFee)
+
+	history, err := channel.FeeRateHistory()
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	// Apply a number of fee updates that stays within the limit.
+	updateFee(1000)
+	updateFee(2000)
+	updateFee(3000)
+
+	history, err = channel.FeeRateHistory()
+	require.NoError(t, err)
+	require.Equal(t, []FeeRateUpdate{
+		{
+			Height:      initialHeight + 2,
+			OldFeePerKw: initialFee,
+			NewFeePerKw: 1000,
+		},
+		{
+			Height:      initialHeight + 3,
+			OldFeePerKw: 1000,
+			NewFeePerKw: 2000,
+		},
+		{
+			Height:      initialHeight + 4,
+			OldFeePerKw: 2000,
+			NewFeePerKw: 3000,
+		},
+	}, history)
+
+	// Two more updates should cause the oldest entries to be merged, while
+	// retaining the initial fee rate of the channel.
+	updateFee(4000)
+	updateFee(5000)
+
+	history, err = channel.FeeRateHistory()
+	require.NoError(t, err)
+	require.Equal(t, []FeeRateUpdate{
+		{
+			Height:      initialHeight + 4,
+			OldFeePerKw: initialFee,
+			NewFeePerKw: 3000,
+		},
+		{
+			Height:      initialHeight + 5,
+			OldFeePerKw: 3000,
+			NewFeePerKw: 4000,
+		},
+		{
+			Height:      initialHeight + 6,
+			OldFeePerKw: 4000,
+			NewFeePerKw: 5000,
+		},
+	}, history)
+}
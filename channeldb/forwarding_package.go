@@ -57,8 +57,12 @@ var (
 	//     	|       |       |        ...
 	//     	|       |       |
 	//     	|       |       |-- failSettleBucketKey
-	//     	|       |                |-- <index of LogUpdate>: <encoded bytes of LogUpdate>
-	//     	|       |                |-- <index of LogUpdate>: <encoded bytes of LogUpdate>
+	//     	|       |       |        |-- <index of LogUpdate>: <encoded bytes of LogUpdate>
+	//     	|       |       |        |-- <index of LogUpdate>: <encoded bytes of LogUpdate>
+	//     	|       |       |        ...
+	//     	|       |       |
+	//     	|       |       |-- interceptBucketKey
+	//     	|       |                |-- <index of Add>: <encoded bytes of HtlcIntercept>
 	//     	|       |                ...
 	//     	|       |
 	//     	|       |-- <height>
@@ -97,8 +101,82 @@ var (
 	// which Settles/Fails in have been received and processed by the link
 	// that originally received the Add.
 	settleFailFilterKey = []byte("settle-fail-filter-key")
+
+	// interceptBucketKey is the bucket to which the decisions of the HTLC
+	// interceptor of the link about the Adds are written, keyed by the
+	// index of the Add. Adds accepted by the interceptor, or processed
+	// without one, don't have an entry.
+	interceptBucketKey = []byte("intercepts")
 )
 
+// HtlcInterceptState describes the persisted outcome of the interception of an
+// Add, which determines how the Add is reprocessed after a restart.
+type HtlcInterceptState byte
+
+const (
+	// HtlcInterceptHeld marks an Add that is held by the interceptor, and
+	// neither forwarded nor failed back yet.
+	HtlcInterceptHeld HtlcInterceptState = iota + 1
+
+	// HtlcInterceptResumed marks a held Add that was released and
+	// forwarded to the switch.
+	HtlcInterceptResumed
+
+	// HtlcInterceptFailed marks an Add that was rejected by the
+	// interceptor, or held and then canceled, and is failed back with the
+	// recorded failure code.
+	HtlcInterceptFailed
+)
+
+// String returns a human-readable description of the intercept state.
+func (s HtlcInterceptState) String() string {
+	switch s {
+	case HtlcInterceptHeld:
+		return "Held"
+
+	case HtlcInterceptResumed:
+		return "Resumed"
+
+	case HtlcInterceptFailed:
+		return "Failed"
+
+	default:
+		return fmt.Sprintf("Unknown(%d)", byte(s))
+	}
+}
+
+// HtlcIntercept records the decision of the HTLC interceptor about an Add in a
+// forwarding package.
+type HtlcIntercept struct {
+	// State is the outcome of the interception.
+	State HtlcInterceptState
+
+	// FailCode is the failure code the Add is failed back with. It's only
+	// set for the HtlcInterceptFailed state.
+	FailCode lnwire.FailCode
+}
+
+// Encode writes the intercept to the provided io.Writer.
+func (h *HtlcIntercept) Encode(w io.Writer) error {
+	return WriteElements(w, uint8(h.State), uint16(h.FailCode))
+}
+
+// Decode reads the intercept from the provided io.Reader.
+func (h *HtlcIntercept) Decode(r io.Reader) error {
+	var (
+		state    uint8
+		failCode uint16
+	)
+	if err := ReadElements(r, &state, &failCode); err != nil {
+		return err
+	}
+
+	h.State = HtlcInterceptState(state)
+	h.FailCode = lnwire.FailCode(failCode)
+
+	return nil
+}
+
 // PkgFilter is used to compactly represent a particular subset of the Adds in a
 // forwarding package. Each filter is represented as a simple, statically-sized
 // bitvector, where the elements are intended to be the indices of the Adds as
@@ -259,6 +337,11 @@ type FwdPkg struct {
 	// Fails originating in this package that have been received and locked
 	// into the incoming link's commitment state.
 	SettleFailFilter *PkgFilter
+
+	// Intercepts maps the indices of the Adds that were held or rejected
+	// by the HTLC interceptor of the link to the latest decision about
+	// them.
+	Intercepts map[uint16]HtlcIntercept
 }
 
 // NewFwdPkg initializes a new forwarding package in FwdStateLockedIn. This
@@ -278,6 +361,7 @@ func NewFwdPkg(source lnwire.ShortChannelID, height uint64,
 		AckFilter:        NewPkgFilter(nAddUpdates),
 		SettleFails:      settleFailUpdates,
 		SettleFailFilter: NewPkgFilter(nSettleFailUpdates),
+		Intercepts:       make(map[uint16]HtlcIntercept),
 	}
 }
 
@@ -417,6 +501,12 @@ type FwdPackager interface {
 	// 3) Should be forwarded to the switch immediately after a failure
 	SetFwdFilter(tx kvdb.RwTx, height uint64, fwdFilter *PkgFilter) error
 
+	// SetHtlcIntercept records the latest decision of the HTLC interceptor
+	// about the Add at `index` in the forwarding package at the remote
+	// `height`.
+	SetHtlcIntercept(tx kvdb.RwTx, height uint64, index uint16,
+		intercept *HtlcIntercept) error
+
 	// AckAddHtlcs atomically updates the add filters in this channel's
 	// forwarding packages to mark the resolution of an Add that was
 	// received from the remote party.
@@ -652,6 +742,12 @@ func loadFwdPkg(fwdPkgBkt kvdb.RBucket, source lnwire.ShortChannelID,
 		SettleFailFilter: settleFailFilter,
 	}
 
+	// Load the decisions of the HTLC interceptor, if the link made any.
+	fwdPkg.Intercepts, err = loadHtlcIntercepts(heightBkt)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check to see if we have written the set exported filter adds to
 	// disk. If we haven't, processing of this package was never started, or
 	// failed during the last attempt.
@@ -702,6 +798,38 @@ func loadHtlcs(bkt kvdb.RBucket) ([]LogUpdate, error) {
 	return htlcs, nil
 }
 
+// loadHtlcIntercepts retrieves the decisions of the HTLC interceptor stored in
+// the bucket of a forwarding package, indexed by the indexes of their Adds.
+func loadHtlcIntercepts(heightBkt kvdb.RBucket) (map[uint16]HtlcIntercept,
+	error) {
+
+	intercepts := make(map[uint16]HtlcIntercept)
+
+	interceptBkt := heightBkt.NestedReadBucket(interceptBucketKey)
+	if interceptBkt == nil {
+		return intercepts, nil
+	}
+
+	err := interceptBkt.ForEach(func(k, v []byte) error {
+		if len(k) != 2 {
+			return ErrCorruptedFwdPkg
+		}
+
+		var intercept HtlcIntercept
+		if err := intercept.Decode(bytes.NewReader(v)); err != nil {
+			return err
+		}
+		intercepts[byteOrder.Uint16(k)] = intercept
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return intercepts, nil
+}
+
 // SetFwdFilter writes the set of indexes corresponding to Adds at the
 // `height` that are to be forwarded to the switch. Calling this method causes
 // the forwarding package at `height` to be in FwdStateProcessed. We write this
@@ -745,6 +873,45 @@ func (p *ChannelPackager) SetFwdFilter(tx kvdb.RwTx, height uint64,
 	return heightBkt.Put(fwdFilterKey, b.Bytes())
 }
 
+// SetHtlcIntercept writes the latest decision of the HTLC interceptor about the
+// Add at `index` in the forwarding package at `height`, replacing any previous
+// one. Unlike the fwd filter, the decision may change after the package has
+// been processed, as held Adds are later resumed or failed.
+func (p *ChannelPackager) SetHtlcIntercept(tx kvdb.RwTx, height uint64,
+	index uint16, intercept *HtlcIntercept) error {
+
+	fwdPkgBkt := tx.ReadWriteBucket(fwdPackagesKey)
+	if fwdPkgBkt == nil {
+		return ErrCorruptedFwdPkg
+	}
+
+	source := makeLogKey(p.source.ToUint64())
+	sourceBkt := fwdPkgBkt.NestedReadWriteBucket(source[:])
+	if sourceBkt == nil {
+		return ErrCorruptedFwdPkg
+	}
+
+	heightKey := makeLogKey(height)
+	heightBkt := sourceBkt.NestedReadWriteBucket(heightKey[:])
+	if heightBkt == nil {
+		return ErrCorruptedFwdPkg
+	}
+
+	interceptBkt, err := heightBkt.CreateBucketIfNotExists(
+		interceptBucketKey,
+	)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := intercept.Encode(&b); err != nil {
+		return err
+	}
+
+	return interceptBkt.Put(uint16Key(index), b.Bytes())
+}
+
 // AckAddHtlcs accepts a list of references to add htlcs, and updates the
 // AckAddFilter of those forwarding packages to indicate that a settle or fail
 // has been received in response to the add.
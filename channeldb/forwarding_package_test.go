@@ -729,6 +729,90 @@ func TestPackagerSettleFailsThenAdds(t *testing.T) {
 	}
 }
 
+// TestPackagerHtlcIntercepts asserts that the decisions of the HTLC
+// interceptor are persisted with the forwarding package, that a later decision
+// replaces an earlier one even after the fwd filter was written, and that they
+// are removed with the package.
+func TestPackagerHtlcIntercepts(t *testing.T) {
+	t.Parallel()
+
+	db := makeFwdPkgDB(t, "")
+
+	shortChanID := lnwire.NewShortChanIDFromInt(1)
+	packager := channeldb.NewChannelPackager(shortChanID)
+
+	fwdPkg := channeldb.NewFwdPkg(shortChanID, 0, adds, nil)
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		return packager.AddFwdPkg(tx, fwdPkg)
+	}, func() {})
+	require.NoError(t, err, "unable to add fwd pkg")
+
+	// A freshly written package has no intercepts.
+	fwdPkgs := loadFwdPkgs(t, db, packager)
+	require.Len(t, fwdPkgs, 1)
+	require.Empty(t, fwdPkgs[0].Intercepts)
+
+	setIntercept := func(index uint16, intercept channeldb.HtlcIntercept) {
+		err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+			return packager.SetHtlcIntercept(
+				tx, fwdPkg.Height, index, &intercept,
+			)
+		}, func() {})
+		require.NoError(t, err, "unable to set intercept")
+	}
+
+	// Hold the first add and reject the second one, then write the
+	// forwarding decision, which doesn't include either of them.
+	held := channeldb.HtlcIntercept{State: channeldb.HtlcInterceptHeld}
+	rejected := channeldb.HtlcIntercept{
+		State:    channeldb.HtlcInterceptFailed,
+		FailCode: lnwire.CodeTemporaryChannelFailure,
+	}
+	setIntercept(0, held)
+	setIntercept(1, rejected)
+
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		return packager.SetFwdFilter(tx, fwdPkg.Height, fwdPkg.FwdFilter)
+	}, func() {})
+	require.NoError(t, err, "unable to set fwd filter")
+
+	fwdPkgs = loadFwdPkgs(t, db, packager)
+	require.Len(t, fwdPkgs, 1)
+	assertFwdPkgState(t, fwdPkgs[0], channeldb.FwdStateProcessed)
+	require.Equal(t, map[uint16]channeldb.HtlcIntercept{
+		0: held,
+		1: rejected,
+	}, fwdPkgs[0].Intercepts)
+
+	// Resuming the held add replaces its decision, although the package
+	// was already processed.
+	resumed := channeldb.HtlcIntercept{State: channeldb.HtlcInterceptResumed}
+	setIntercept(0, resumed)
+
+	fwdPkgs = loadFwdPkgs(t, db, packager)
+	require.Len(t, fwdPkgs, 1)
+	require.Equal(t, map[uint16]channeldb.HtlcIntercept{
+		0: resumed,
+		1: rejected,
+	}, fwdPkgs[0].Intercepts)
+
+	// Removing the package also removes its intercepts.
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		return packager.RemovePkg(tx, fwdPkg.Height)
+	}, func() {})
+	require.NoError(t, err, "unable to remove fwdpkg")
+
+	fwdPkg = channeldb.NewFwdPkg(shortChanID, 0, adds, nil)
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		return packager.AddFwdPkg(tx, fwdPkg)
+	}, func() {})
+	require.NoError(t, err, "unable to add fwd pkg")
+
+	fwdPkgs = loadFwdPkgs(t, db, packager)
+	require.Len(t, fwdPkgs, 1)
+	require.Empty(t, fwdPkgs[0].Intercepts)
+}
+
 // TestPackagerWipeAll checks that when the method is called, all the related
 // forwarding packages will be removed.
 func TestPackagerWipeAll(t *testing.T) {
@@ -82,6 +82,14 @@ type Options struct {
 	// storeFinalHtlcResolutions determines whether to persistently store
 	// the final resolution of incoming htlcs.
 	storeFinalHtlcResolutions bool
+
+	// maxFeeRateHistory is the maximum number of fee rate transitions
+	// stored for each channel.
+	maxFeeRateHistory int
+
+	// maxChannelEvents is the maximum number of events stored in the
+	// event log of each channel.
+	maxChannelEvents int
 }
 
 // DefaultOptions returns an Options populated with default values.
@@ -100,6 +108,8 @@ func DefaultOptions() Options {
 		UseGraphCache:           true,
 		NoMigration:             false,
 		clock:                   clock.NewDefaultClock(),
+		maxFeeRateHistory:       DefaultMaxFeeRateHistory,
+		maxChannelEvents:        DefaultMaxChannelEvents,
 	}
 }
 
@@ -213,6 +223,24 @@ func OptionStoreFinalHtlcResolutions(
 	}
 }
 
+// OptionSetMaxFeeRateHistory sets the maximum number of fee rate transitions
+// that are stored for each channel. Once exceeded, the oldest transitions are
+// compacted. A value of zero disables recording the fee rate history.
+func OptionSetMaxFeeRateHistory(n int) OptionModifier {
+	return func(o *Options) {
+		o.maxFeeRateHistory = n
+	}
+}
+
+// OptionSetMaxChannelEvents sets the maximum number of events that are stored
+// in the event log of each channel. Once exceeded, the oldest events are
+// deleted. A value of zero disables the event log.
+func OptionSetMaxChannelEvents(n int) OptionModifier {
+	return func(o *Options) {
+		o.maxChannelEvents = n
+	}
+}
+
 // OptionPruneRevocationLog specifies whether the migration for pruning
 // revocation logs needs to be applied or not.
 func OptionPruneRevocationLog(prune bool) OptionModifier {
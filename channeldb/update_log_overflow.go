@@ -0,0 +1,236 @@
+package channeldb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+var (
+	// updateLogOverflowBucket is a sub-bucket of the bucket for a channel
+	// (identified by its chanPoint). It stores HTLCs that were spilled out
+	// of the in-memory update logs of the channel state machine, keyed by
+	// their direction and HTLC index.
+	updateLogOverflowBucket = []byte("update-log-overflow-bucket")
+
+	// ErrOverflowHtlcNotFound is returned when an HTLC can't be found in
+	// the update log overflow of a channel.
+	ErrOverflowHtlcNotFound = errors.New("overflow htlc not found")
+)
+
+// OverflowHtlc is an HTLC that was spilled out of an in-memory update log,
+// along with the heights of the commitments that first included it.
+type OverflowHtlc struct {
+	HTLC
+
+	// AddCommitHeightLocal is the height of the first local commitment
+	// that included the HTLC.
+	AddCommitHeightLocal uint64
+
+	// AddCommitHeightRemote is the height of the first remote commitment
+	// that included the HTLC.
+	AddCommitHeightRemote uint64
+}
+
+// overflowHtlcKey returns the key an HTLC is stored under within the update
+// log overflow bucket.
+func overflowHtlcKey(incoming bool, htlcIndex uint64) []byte {
+	var key [9]byte
+	if incoming {
+		key[0] = 1
+	}
+	byteOrder.PutUint64(key[1:], htlcIndex)
+
+	return key[:]
+}
+
+// serializeOverflowHtlc serializes the passed overflow HTLC to a stream.
+func serializeOverflowHtlc(w io.Writer, htlc *OverflowHtlc) error {
+	if err := SerializeHtlcs(w, htlc.HTLC); err != nil {
+		return err
+	}
+
+	return WriteElements(
+		w, htlc.AddCommitHeightLocal, htlc.AddCommitHeightRemote,
+	)
+}
+
+// deserializeOverflowHtlc deserializes an overflow HTLC from a stream.
+func deserializeOverflowHtlc(r io.Reader) (*OverflowHtlc, error) {
+	htlcs, err := DeserializeHtlcs(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(htlcs) != 1 {
+		return nil, ErrOverflowHtlcNotFound
+	}
+
+	htlc := &OverflowHtlc{
+		HTLC: htlcs[0],
+	}
+	err = ReadElements(
+		r, &htlc.AddCommitHeightLocal, &htlc.AddCommitHeightRemote,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return htlc, nil
+}
+
+// PutOverflowHtlc stores the passed HTLC in the update log overflow of the
+// channel, replacing any HTLC with the same direction and index.
+func (c *OpenChannel) PutOverflowHtlc(htlc *OverflowHtlc) error {
+	c.Lock()
+	defer c.Unlock()
+
+	var b bytes.Buffer
+	if err := serializeOverflowHtlc(&b, htlc); err != nil {
+		return err
+	}
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		overflow, err := chanBucket.CreateBucketIfNotExists(
+			updateLogOverflowBucket,
+		)
+		if err != nil {
+			return err
+		}
+
+		key := overflowHtlcKey(htlc.Incoming, htlc.HtlcIndex)
+
+		return overflow.Put(key, b.Bytes())
+	}, func() {})
+}
+
+// FetchOverflowHtlc returns the HTLC with the given direction and index from
+// the update log overflow of the channel. ErrOverflowHtlcNotFound is returned
+// if no such HTLC is stored.
+func (c *OpenChannel) FetchOverflowHtlc(incoming bool,
+	htlcIndex uint64) (*OverflowHtlc, error) {
+
+	c.RLock()
+	defer c.RUnlock()
+
+	var htlc *OverflowHtlc
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		overflow := chanBucket.NestedReadBucket(updateLogOverflowBucket)
+		if overflow == nil {
+			return ErrOverflowHtlcNotFound
+		}
+
+		htlcBytes := overflow.Get(overflowHtlcKey(incoming, htlcIndex))
+		if htlcBytes == nil {
+			return ErrOverflowHtlcNotFound
+		}
+
+		htlc, err = deserializeOverflowHtlc(bytes.NewReader(htlcBytes))
+		return err
+	}, func() {
+		htlc = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return htlc, nil
+}
+
+// FetchOverflowHtlcs returns all HTLCs of the given direction stored in the
+// update log overflow of the channel, ordered by their HTLC index.
+func (c *OpenChannel) FetchOverflowHtlcs(incoming bool) ([]OverflowHtlc,
+	error) {
+
+	c.RLock()
+	defer c.RUnlock()
+
+	var htlcs []OverflowHtlc
+	err := kvdb.View(c.Db.backend, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchChanBucket(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		overflow := chanBucket.NestedReadBucket(updateLogOverflowBucket)
+		if overflow == nil {
+			return nil
+		}
+
+		prefix := overflowHtlcKey(incoming, 0)[:1]
+		cursor := overflow.ReadCursor()
+		for k, v := cursor.Seek(prefix); k != nil &&
+			bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+
+			htlc, err := deserializeOverflowHtlc(bytes.NewReader(v))
+			if err != nil {
+				return err
+			}
+			htlcs = append(htlcs, *htlc)
+		}
+
+		return nil
+	}, func() {
+		htlcs = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return htlcs, nil
+}
+
+// DeleteOverflowHtlc removes the HTLC with the given direction and index from
+// the update log overflow of the channel. Deleting an HTLC that isn't stored
+// is not an error.
+func (c *OpenChannel) DeleteOverflowHtlc(incoming bool,
+	htlcIndex uint64) error {
+
+	c.Lock()
+	defer c.Unlock()
+
+	return kvdb.Update(c.Db.backend, func(tx kvdb.RwTx) error {
+		chanBucket, err := fetchChanBucketRw(
+			tx, c.IdentityPub, &c.FundingOutpoint, c.ChainHash,
+		)
+		if err != nil {
+			return err
+		}
+
+		overflow := chanBucket.NestedReadWriteBucket(
+			updateLogOverflowBucket,
+		)
+		if overflow == nil {
+			return nil
+		}
+
+		return overflow.Delete(overflowHtlcKey(incoming, htlcIndex))
+	}, func() {})
+}
+
+// deleteUpdateLogOverflow removes the update log overflow bucket from the
+// passed channel bucket, if it exists.
+func deleteUpdateLogOverflow(chanBucket kvdb.RwBucket) error {
+	if chanBucket.NestedReadWriteBucket(updateLogOverflowBucket) == nil {
+		return nil
+	}
+
+	return chanBucket.DeleteNestedBucket(updateLogOverflowBucket)
+}
@@ -0,0 +1,85 @@
+package channeldb
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdateLogOverflow asserts that HTLCs can be stored in, fetched from and
+// deleted from the update log overflow of a channel.
+func TestUpdateLogOverflow(t *testing.T) {
+	t.Parallel()
+
+	fullDB, err := MakeTestDB(t)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+	channel := createTestChannel(t, cdb, openChannelOption())
+
+	newHtlc := func(incoming bool, htlcIndex uint64) *OverflowHtlc {
+		htlc := &OverflowHtlc{
+			HTLC: HTLC{
+				Signature:     []byte{byte(htlcIndex)},
+				RHash:         [32]byte{byte(htlcIndex)},
+				Amt:           lnwire.MilliSatoshi(htlcIndex),
+				RefundTimeout: 500,
+				Incoming:      incoming,
+				HtlcIndex:     htlcIndex,
+				LogIndex:      htlcIndex + 10,
+			},
+			AddCommitHeightLocal:  htlcIndex + 1,
+			AddCommitHeightRemote: htlcIndex + 2,
+		}
+		htlc.OnionBlob[0] = byte(htlcIndex)
+
+		return htlc
+	}
+
+	_, err = channel.FetchOverflowHtlc(true, 0)
+	require.ErrorIs(t, err, ErrOverflowHtlcNotFound)
+
+	htlcs, err := channel.FetchOverflowHtlcs(true)
+	require.NoError(t, err)
+	require.Empty(t, htlcs)
+
+	// Store HTLCs in both directions, using the same indexes.
+	var incoming, outgoing []OverflowHtlc
+	for i := uint64(0); i < 3; i++ {
+		in, out := newHtlc(true, i), newHtlc(false, i)
+		require.NoError(t, channel.PutOverflowHtlc(in))
+		require.NoError(t, channel.PutOverflowHtlc(out))
+
+		incoming = append(incoming, *in)
+		outgoing = append(outgoing, *out)
+	}
+
+	htlc, err := channel.FetchOverflowHtlc(false, 1)
+	require.NoError(t, err)
+	require.Equal(t, &outgoing[1], htlc)
+
+	htlcs, err = channel.FetchOverflowHtlcs(true)
+	require.NoError(t, err)
+	require.Equal(t, incoming, htlcs)
+
+	htlcs, err = channel.FetchOverflowHtlcs(false)
+	require.NoError(t, err)
+	require.Equal(t, outgoing, htlcs)
+
+	// Deleting an HTLC only affects the given direction, and deleting it
+	// again is not an error.
+	require.NoError(t, channel.DeleteOverflowHtlc(true, 1))
+	require.NoError(t, channel.DeleteOverflowHtlc(true, 1))
+
+	_, err = channel.FetchOverflowHtlc(true, 1)
+	require.ErrorIs(t, err, ErrOverflowHtlcNotFound)
+
+	htlcs, err = channel.FetchOverflowHtlcs(true)
+	require.NoError(t, err)
+	require.Equal(t, []OverflowHtlc{incoming[0], incoming[2]}, htlcs)
+
+	htlcs, err = channel.FetchOverflowHtlcs(false)
+	require.NoError(t, err)
+	require.Equal(t, outgoing, htlcs)
+}
@@ -7,13 +7,11 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/contractcourt"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lnwire"
-	"github.com/lightningnetwork/lnd/shachain"
 )
 
 const (
@@ -48,146 +46,7 @@ type chanDBRestorer struct {
 func (c *chanDBRestorer) openChannelShell(backup chanbackup.Single) (
 	*channeldb.ChannelShell, error) {
 
-	var err error
-
-	// Each of the keys in our local channel config only have their
-	// locators populate, so we'll re-derive the raw key now as we'll need
-	// it in order to carry out the DLP protocol.
-	backup.LocalChanCfg.MultiSigKey, err = c.secretKeys.DeriveKey(
-		backup.LocalChanCfg.MultiSigKey.KeyLocator,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to derive multi sig key: %v", err)
-	}
-	backup.LocalChanCfg.RevocationBasePoint, err = c.secretKeys.DeriveKey(
-		backup.LocalChanCfg.RevocationBasePoint.KeyLocator,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to derive revocation key: %v", err)
-	}
-	backup.LocalChanCfg.PaymentBasePoint, err = c.secretKeys.DeriveKey(
-		backup.LocalChanCfg.PaymentBasePoint.KeyLocator,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to derive payment key: %v", err)
-	}
-	backup.LocalChanCfg.DelayBasePoint, err = c.secretKeys.DeriveKey(
-		backup.LocalChanCfg.DelayBasePoint.KeyLocator,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to derive delay key: %v", err)
-	}
-	backup.LocalChanCfg.HtlcBasePoint, err = c.secretKeys.DeriveKey(
-		backup.LocalChanCfg.HtlcBasePoint.KeyLocator,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to derive htlc key: %v", err)
-	}
-
-	// The shachain root that seeds RevocationProducer for this channel.
-	// It currently has two possible formats.
-	var revRoot *chainhash.Hash
-
-	// If the PubKey field is non-nil, then this shachain root is using the
-	// legacy non-ECDH scheme.
-	if backup.ShaChainRootDesc.PubKey != nil {
-		ltndLog.Debugf("Using legacy revocation producer format for "+
-			"channel point %v", backup.FundingOutpoint)
-
-		// Obtain the private key for the shachain root from the
-		// encoded public key.
-		privKey, err := c.secretKeys.DerivePrivKey(
-			backup.ShaChainRootDesc,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("could not derive private key "+
-				"for legacy channel revocation root format: "+
-				"%v", err)
-		}
-
-		revRoot, err = chainhash.NewHash(privKey.Serialize())
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		ltndLog.Debugf("Using new ECDH revocation producer format "+
-			"for channel point %v", backup.FundingOutpoint)
-
-		// This is the scheme in which the shachain root is derived via
-		// an ECDH operation on the private key of ShaChainRootDesc and
-		// our public multisig key.
-		ecdh, err := c.secretKeys.ECDH(
-			backup.ShaChainRootDesc,
-			backup.LocalChanCfg.MultiSigKey.PubKey,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("unable to derive shachain "+
-				"root: %v", err)
-		}
-
-		ch := chainhash.Hash(ecdh)
-		revRoot = &ch
-	}
-
-	shaChainProducer := shachain.NewRevocationProducer(*revRoot)
-
-	var chanType channeldb.ChannelType
-	switch backup.Version {
-	case chanbackup.DefaultSingleVersion:
-		chanType = channeldb.SingleFunderBit
-
-	case chanbackup.TweaklessCommitVersion:
-		chanType = channeldb.SingleFunderTweaklessBit
-
-	case chanbackup.AnchorsCommitVersion:
-		chanType = channeldb.AnchorOutputsBit
-		chanType |= channeldb.SingleFunderTweaklessBit
-
-	case chanbackup.AnchorsZeroFeeHtlcTxCommitVersion:
-		chanType = channeldb.ZeroHtlcTxFeeBit
-		chanType |= channeldb.AnchorOutputsBit
-		chanType |= channeldb.SingleFunderTweaklessBit
-
-	case chanbackup.ScriptEnforcedLeaseVersion:
-		chanType = channeldb.LeaseExpirationBit
-		chanType |= channeldb.ZeroHtlcTxFeeBit
-		chanType |= channeldb.AnchorOutputsBit
-		chanType |= channeldb.SingleFunderTweaklessBit
-
-	case chanbackup.SimpleTaprootVersion:
-		chanType = channeldb.ZeroHtlcTxFeeBit
-		chanType |= channeldb.AnchorOutputsBit
-		chanType |= channeldb.SingleFunderTweaklessBit
-		chanType |= channeldb.SimpleTaprootFeatureBit
-
-	default:
-		return nil, fmt.Errorf("unknown Single version: %v", err)
-	}
-
-	ltndLog.Infof("SCB Recovery: created channel shell for ChannelPoint"+
-		"(%v), chan_type=%v", backup.FundingOutpoint, chanType)
-
-	chanShell := channeldb.ChannelShell{
-		NodeAddrs: backup.Addresses,
-		Chan: &channeldb.OpenChannel{
-			ChanType:                chanType,
-			ChainHash:               backup.ChainHash,
-			IsInitiator:             backup.IsInitiator,
-			Capacity:                backup.Capacity,
-			FundingOutpoint:         backup.FundingOutpoint,
-			ShortChannelID:          backup.ShortChannelID,
-			IdentityPub:             backup.RemoteNodePub,
-			IsPending:               false,
-			LocalChanCfg:            backup.LocalChanCfg,
-			RemoteChanCfg:           backup.RemoteChanCfg,
-			RemoteCurrentRevocation: backup.RemoteNodePub,
-			RevocationStore:         shachain.NewRevocationStore(),
-			RevocationProducer:      shaChainProducer,
-			ThawHeight:              backup.LeaseExpiry,
-		},
-	}
-
-	return &chanShell, nil
+	return chanbackup.NewChannelShell(backup, c.secretKeys)
 }
 
 // RestoreChansFromSingles attempts to map the set of single channel backups to
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -46,3 +48,59 @@ var lightningPrefix = "lightning:"
 func stripPrefix(s string) string {
 	return strings.TrimSpace(strings.TrimPrefix(s, lightningPrefix))
 }
+
+// unitDecimals maps the amount units accepted on the command line to the
+// number of decimal places they have when expressed in satoshis.
+var unitDecimals = map[string]int{
+	"sat": 0,
+	"bit": 2,
+	"btc": 8,
+}
+
+// errFractionalSat is returned when an amount can't be expressed in whole
+// satoshis.
+var errFractionalSat = errors.New("amount results in fractional satoshis")
+
+// parseAmount parses a non-negative decimal amount denominated in the given
+// unit (sat, bit or btc) and returns it in satoshis. The conversion is done on
+// the decimal string itself, so no precision is lost for large btc amounts.
+func parseAmount(s, unit string) (int64, error) {
+	decimals, ok := unitDecimals[strings.ToLower(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q, must be one of sat, "+
+			"bit or btc", unit)
+	}
+
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	// Any digits beyond the precision of a satoshi must be zero.
+	if len(frac) > decimals {
+		if strings.Trim(frac[decimals:], "0") != "" {
+			return 0, fmt.Errorf("%w: %s %s", errFractionalSat, s,
+				unit)
+		}
+		frac = frac[:decimals]
+	}
+	frac += strings.Repeat("0", decimals-len(frac))
+
+	digits := whole + frac
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	if digits == "" {
+		return 0, nil
+	}
+
+	amt, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+
+	return amt, nil
+}
@@ -115,3 +115,46 @@ func TestStripPrefix(t *testing.T) {
 		require.Equal(t, test.expected, actual)
 	}
 }
+
+var parseAmountTests = []struct {
+	in          string
+	unit        string
+	expected    int64
+	errExpected bool
+}{
+	{"100000", "sat", 100000, false},
+	{"100000.000", "sat", 100000, false},
+	{"1.5", "sat", 0, true},
+	{"250", "bit", 25000, false},
+	{"0.01", "bit", 1, false},
+	{"0.001", "bit", 0, true},
+	{"0.5", "btc", 50_000_000, false},
+	{".5", "btc", 50_000_000, false},
+	{"1.", "btc", 100_000_000, false},
+	{"0.16777215", "btc", 16_777_215, false},
+	{"20999999.9769", "btc", 2_099_999_997_690_000, false},
+	{"0.000000015", "btc", 0, true},
+	{"0.000000010", "BTC", 1, false},
+	{"92233720368.54775808", "btc", 0, true},
+	{"-1", "sat", 0, true},
+	{"1e8", "sat", 0, true},
+	{".", "btc", 0, true},
+	{"1", "msat", 0, true},
+}
+
+// TestParseAmount tests that amounts are converted to satoshis without losing
+// precision and that fractional satoshis are rejected.
+func TestParseAmount(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range parseAmountTests {
+		actual, err := parseAmount(test.in, test.unit)
+		if test.errExpected {
+			require.Error(t, err, "%s %s", test.in, test.unit)
+			continue
+		}
+
+		require.NoError(t, err, "%s %s", test.in, test.unit)
+		require.Equal(t, test.expected, actual)
+	}
+}
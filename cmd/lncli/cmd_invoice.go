@@ -1,14 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"strconv"
+	"time"
 
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/urfave/cli"
 )
 
+// maxRouteHints is the maximum number of route hints the daemon accepts for a
+// new invoice.
+const maxRouteHints = 20
+
 var addInvoiceCommand = cli.Command{
 	Name:     "addinvoice",
 	Category: "Invoices",
@@ -18,7 +28,18 @@ var addInvoiceCommand = cli.Command{
 
 	Invoices without an amount can be created by not supplying any
 	parameters or providing an amount of 0. These invoices allow the payer
-	to specify the amount of satoshis they wish to send.`,
+	to specify the amount of satoshis they wish to send.
+
+	To receive payments over unannounced channels, the invoice must include
+	route hints. With --private, hints for the node's private channels are
+	added automatically. Explicit hints can be set with --route_hints as a
+	JSON array of route hints, each consisting of the hops leading to this
+	node:
+
+	lncli addinvoice --amt 1000 --route_hints '[{"hop_hints": [{
+		"node_id": "<hex pubkey>", "chan_id": "<short chan id>",
+		"fee_base_msat": 1000, "fee_proportional_millionths": 1,
+		"cltv_expiry_delta": 40}]}]'`,
 	ArgsUsage: "value preimage",
 	Flags: []cli.Flag{
 		cli.StringFlag{
@@ -69,6 +90,12 @@ var addInvoiceCommand = cli.Command{
 				"these channels can be included, which " +
 				"might not be desirable.",
 		},
+		cli.StringFlag{
+			Name: "route_hints",
+			Usage: "a JSON array of route hints to encode in the " +
+				"invoice, see the description for the " +
+				"format. Can be combined with --private.",
+		},
 		cli.BoolFlag{
 			Name: "amp",
 			Usage: "creates an AMP invoice. If true, preimage " +
@@ -118,6 +145,15 @@ func addInvoice(ctx *cli.Context) error {
 		return fmt.Errorf("unable to parse description_hash: %v", err)
 	}
 
+	var routeHints []*lnrpc.RouteHint
+	if ctx.IsSet("route_hints") {
+		routeHints, err = parseRouteHints(ctx.String("route_hints"))
+		if err != nil {
+			return fmt.Errorf("unable to parse route_hints: %w",
+				err)
+		}
+	}
+
 	invoice := &lnrpc.Invoice{
 		Memo:            ctx.String("memo"),
 		RPreimage:       preimage,
@@ -127,6 +163,7 @@ func addInvoice(ctx *cli.Context) error {
 		FallbackAddr:    ctx.String("fallback_addr"),
 		Expiry:          ctx.Int64("expiry"),
 		Private:         ctx.Bool("private"),
+		RouteHints:      routeHints,
 		IsAmp:           ctx.Bool("amp"),
 	}
 
@@ -140,6 +177,71 @@ func addInvoice(ctx *cli.Context) error {
 	return nil
 }
 
+// parseRouteHints parses a JSON array of route hints, and validates them the
+// same way the daemon does, so malformed hints are caught before the invoice
+// is added.
+func parseRouteHints(jsonHints string) ([]*lnrpc.RouteHint, error) {
+	// The protobuf JSON decoder only decodes messages, so we decode the
+	// array as the route hints field of an invoice.
+	var invoice lnrpc.Invoice
+	err := lnrpc.ProtoJSONUnmarshalOpts.Unmarshal(
+		[]byte(fmt.Sprintf(`{"route_hints": %s}`, jsonHints)),
+		&invoice,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	routeHints := invoice.RouteHints
+	switch {
+	case len(routeHints) == 0:
+		return nil, fmt.Errorf("no route hints provided")
+
+	case len(routeHints) > maxRouteHints:
+		return nil, fmt.Errorf("number of route hints must not "+
+			"exceed %v", maxRouteHints)
+	}
+
+	for i, routeHint := range routeHints {
+		if len(routeHint.HopHints) == 0 {
+			return nil, fmt.Errorf("route hint %d has no hop "+
+				"hints", i)
+		}
+
+		for j, hopHint := range routeHint.HopHints {
+			if err := validateHopHint(hopHint); err != nil {
+				return nil, fmt.Errorf("route hint %d, hop "+
+					"%d: %w", i, j, err)
+			}
+		}
+	}
+
+	return routeHints, nil
+}
+
+// validateHopHint checks that the passed hop hint has a valid node ID, a
+// channel ID and a CLTV delta that fits into an invoice.
+func validateHopHint(hopHint *lnrpc.HopHint) error {
+	pubKey, err := hex.DecodeString(hopHint.NodeId)
+	if err != nil {
+		return fmt.Errorf("invalid node_id: %w", err)
+	}
+	if _, err := btcec.ParsePubKey(pubKey); err != nil {
+		return fmt.Errorf("invalid node_id: %w", err)
+	}
+
+	if hopHint.ChanId == 0 {
+		return fmt.Errorf("chan_id must be set")
+	}
+
+	if hopHint.CltvExpiryDelta > math.MaxUint16 {
+		return fmt.Errorf("cltv_expiry_delta %d exceeds %d",
+			hopHint.CltvExpiryDelta, math.MaxUint16)
+	}
+
+	return nil
+}
+
 var lookupInvoiceCommand = cli.Command{
 	Name:      "lookupinvoice",
 	Category:  "Invoices",
@@ -211,7 +313,17 @@ var listInvoicesCommand = cli.Command{
 	For example: if you have 200 invoices, "lncli listinvoices" will return
 	the last 100 created. If you wish to retrieve the previous 100, the
 	first_offset_index of the response can be used as the index_offset of
-	the next listinvoices request.`,
+	the next listinvoices request.
+
+	In the default reversed order, the most recent invoices are fetched
+	first: index_offset is excluded and marks the end of the page, and a
+	zero index_offset starts from the latest invoice. With
+	paginate-forwards, index_offset marks the start of the page instead,
+	and the last_index_offset resumes the pagination.
+
+	The invoices are printed as json by default. With the --table flag, a
+	table is printed instead, followed by the first and last index offsets
+	of the page.`,
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name: "pending_only",
@@ -246,6 +358,11 @@ var listInvoicesCommand = cli.Command{
 				"invoices with creation date less than or " +
 				"equal to it",
 		},
+		cli.BoolFlag{
+			Name: "table",
+			Usage: "if set, the invoices are printed as a table " +
+				"instead of json",
+		},
 	},
 	Action: actionDecorator(listInvoices),
 }
@@ -269,11 +386,51 @@ func listInvoices(ctx *cli.Context) error {
 		return err
 	}
 
+	if ctx.Bool("table") {
+		fmt.Print(formatInvoiceList(invoices))
+		return nil
+	}
+
 	printRespJSON(invoices)
 
 	return nil
 }
 
+// formatInvoiceList formats the invoices of a list invoices response as an
+// ascii table, followed by the index offsets to resume pagination from.
+func formatInvoiceList(resp *lnrpc.ListInvoiceResponse) string {
+	t := table.NewWriter()
+
+	t.AppendHeader(table.Row{
+		"ADD_INDEX", "CREATED", "PAYMENT_HASH", "STATE", "AMOUNT",
+		"AMOUNT_PAID", "MEMO",
+	})
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "AMOUNT", Align: text.AlignRight},
+		{Name: "AMOUNT_PAID", Align: text.AlignRight},
+	})
+
+	for _, invoice := range resp.Invoices {
+		created := time.Unix(invoice.CreationDate, 0)
+
+		t.AppendRow(table.Row{
+			invoice.AddIndex, created.Format(time.RFC3339),
+			hex.EncodeToString(invoice.RHash),
+			invoice.State.String(), formatMsat(invoice.ValueMsat),
+			formatMsat(invoice.AmtPaidMsat), invoice.Memo,
+		})
+	}
+
+	b := &bytes.Buffer{}
+	t.SetOutputMirror(b)
+	t.Render()
+
+	fmt.Fprintf(b, "First index offset: %d\n", resp.FirstIndexOffset)
+	fmt.Fprintf(b, "Last index offset:  %d\n", resp.LastIndexOffset)
+
+	return b.String()
+}
+
 var decodePayReqCommand = cli.Command{
 	Name:        "decodepayreq",
 	Category:    "Invoices",
@@ -11,7 +11,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/btcsuite/btcd/btcutil"
@@ -79,7 +78,9 @@ var openChannelCommand = cli.Command{
 	optional.
 
 	The channel will be initialized with local-amt satoshis locally and
-	push-amt satoshis for the remote node. Note that the push-amt is
+	push-amt satoshis for the remote node. Both amounts can alternatively
+	be specified in bits or BTC by setting the --unit argument, e.g.
+	--unit=btc --local_amt=0.5. Note that the push-amt is
 	deducted from the specified local-amt which implies that the local-amt
 	must be greater than the push-amt. Also note that specifying push-amt
 	means you give that amount to the remote node as part of the channel
@@ -111,10 +112,10 @@ var openChannelCommand = cli.Command{
 			Name:  "connect",
 			Usage: "(optional) the host:port of the target node",
 		},
-		cli.IntFlag{
+		cli.StringFlag{
 			Name: "local_amt",
-			Usage: "the number of satoshis the wallet should " +
-				"commit to the channel",
+			Usage: "the amount the wallet should commit to the " +
+				"channel, denominated in --unit",
 		},
 		cli.BoolFlag{
 			Name: "fundmax",
@@ -148,14 +149,21 @@ var openChannelCommand = cli.Command{
 				"possible rate is 0 with a granularity of " +
 				"0.000001 (millionths)",
 		},
-		cli.IntFlag{
+		cli.StringFlag{
 			Name: "push_amt",
-			Usage: "the number of satoshis to give the remote " +
-				"side as part of the initial commitment " +
-				"state, this is equivalent to first opening " +
+			Usage: "the amount, denominated in --unit, to give " +
+				"the remote side as part of the initial " +
+				"commitment state, this is equivalent to " +
+				"first opening " +
 				"a channel and sending the remote party " +
 				"funds, but done all in one step",
 		},
+		cli.StringFlag{
+			Name:  "unit",
+			Value: "sat",
+			Usage: "(optional) the unit of local_amt and " +
+				"push_amt, one of sat, bit (100 sat) or btc",
+		},
 		cli.BoolFlag{
 			Name:  "block",
 			Usage: "block and wait until the channel is fully open",
@@ -374,13 +382,18 @@ func openChannel(ctx *cli.Context) error {
 		}
 	}
 
+	unit := ctx.String("unit")
+
 	switch {
 	case ctx.IsSet("local_amt"):
-		req.LocalFundingAmount = int64(ctx.Int("local_amt"))
-	case args.Present():
-		req.LocalFundingAmount, err = strconv.ParseInt(
-			args.First(), 10, 64,
+		req.LocalFundingAmount, err = parseAmount(
+			ctx.String("local_amt"), unit,
 		)
+		if err != nil {
+			return fmt.Errorf("unable to decode local amt: %w", err)
+		}
+	case args.Present():
+		req.LocalFundingAmount, err = parseAmount(args.First(), unit)
 		if err != nil {
 			return fmt.Errorf("unable to decode local amt: %v", err)
 		}
@@ -416,9 +429,12 @@ func openChannel(ctx *cli.Context) error {
 	}
 
 	if ctx.IsSet("push_amt") {
-		req.PushSat = int64(ctx.Int("push_amt"))
+		req.PushSat, err = parseAmount(ctx.String("push_amt"), unit)
+		if err != nil {
+			return fmt.Errorf("unable to decode push amt: %w", err)
+		}
 	} else if args.Present() {
-		req.PushSat, err = strconv.ParseInt(args.First(), 10, 64)
+		req.PushSat, err = parseAmount(args.First(), unit)
 		if err != nil {
 			return fmt.Errorf("unable to decode push amt: %v", err)
 		}
@@ -236,8 +236,11 @@ var sendPaymentCommand = cli.Command{
 			Usage: "the payment address of the generated invoice",
 		},
 		cli.BoolFlag{
-			Name:  "keysend",
-			Usage: "will generate a pre-image and encode it in the sphinx packet, a dest must be set [experimental]",
+			Name: "keysend",
+			Usage: "will generate a pre-image and encode it in " +
+				"the sphinx packet, a dest and amt must be " +
+				"set, the pre-image is printed as proof of " +
+				"payment [experimental]",
 		},
 	),
 	Action: sendPayment,
@@ -397,6 +400,15 @@ func sendPayment(ctx *cli.Context) error {
 			return errors.New("cannot set payment hash when using " +
 				"keysend")
 		}
+
+		// Without an invoice, there's nothing that tells the
+		// recipient how much they should receive, so the amount must
+		// be set explicitly.
+		if amount <= 0 {
+			return errors.New("amount must be specified when " +
+				"using keysend")
+		}
+
 		var preimage lntypes.Preimage
 		if _, err := rand.Read(preimage[:]); err != nil {
 			return err
@@ -404,7 +416,7 @@ func sendPayment(ctx *cli.Context) error {
 
 		// Set the preimage. If the user supplied a preimage with the
 		// data flag, the preimage that is set here will be overwritten
-		// later.
+		// later, along with the payment hash.
 		req.DestCustomRecords[record.KeySendType] = preimage[:]
 
 		hash := preimage.Hash()
@@ -533,6 +545,23 @@ func sendPaymentRequest(ctx *cli.Context,
 		}
 	}
 
+	// For keysend payments, the payment hash must commit to the preimage
+	// that is actually sent to the recipient, which may have been
+	// overwritten by the custom data records above.
+	var keySendPreimage *lntypes.Preimage
+	if ctx.Bool("keysend") {
+		preimage, err := lntypes.MakePreimage(
+			req.DestCustomRecords[record.KeySendType],
+		)
+		if err != nil {
+			return fmt.Errorf("invalid keysend preimage: %w", err)
+		}
+
+		hash := preimage.Hash()
+		req.PaymentHash = hash[:]
+		keySendPreimage = &preimage
+	}
+
 	var feeLimit int64
 	if req.PaymentRequest != "" {
 		// Decode payment request to find out the amount.
@@ -581,6 +610,13 @@ func sendPaymentRequest(ctx *cli.Context,
 	printJSON := ctx.Bool(jsonFlag.Name)
 	req.NoInflightUpdates = !ctx.Bool(inflightUpdatesFlag.Name) && printJSON
 
+	// The preimage of a keysend payment is generated by us, so we print
+	// it to give the sender a proof of payment. In JSON mode it's part of
+	// the final payment output instead.
+	if keySendPreimage != nil && !printJSON {
+		fmt.Printf("Keysend preimage: %v\n", keySendPreimage)
+	}
+
 	stream, err := routerClient.SendPaymentV2(ctxc, req)
 	if err != nil {
 		return err
@@ -1064,6 +1100,11 @@ var queryRoutesCommand = cli.Command{
 			Name:  "amt",
 			Usage: "the amount to send expressed in satoshis",
 		},
+		cli.Int64Flag{
+			Name: "amt_msat",
+			Usage: "the amount to send expressed in millisatoshis, " +
+				"can't be used together with amt",
+		},
 		cli.Int64Flag{
 			Name: "fee_limit",
 			Usage: "maximum fee allowed in satoshis when sending " +
@@ -1103,6 +1144,17 @@ var queryRoutesCommand = cli.Command{
 		blindedBaseFlag,
 		blindedPPMFlag,
 		blindedCLTVFlag,
+		cli.UintFlag{
+			Name: "max_parts",
+			Usage: "(optional) preview a multi-part payment by " +
+				"splitting the amount over at most this many " +
+				"routes",
+		},
+		cli.BoolFlag{
+			Name: "json",
+			Usage: "if set, the routes of a multi-part preview " +
+				"are printed as json",
+		},
 	},
 	Action: actionDecorator(queryRoutes),
 }
@@ -1113,9 +1165,10 @@ func queryRoutes(ctx *cli.Context) error {
 	defer cleanUp()
 
 	var (
-		dest string
-		amt  int64
-		err  error
+		dest    string
+		amt     int64
+		amtMsat int64
+		err     error
 	)
 
 	args := ctx.Args()
@@ -1137,8 +1190,12 @@ func queryRoutes(ctx *cli.Context) error {
 	}
 
 	switch {
+	case ctx.IsSet("amt") && ctx.IsSet("amt_msat"):
+		return fmt.Errorf("amt and amt_msat are mutually exclusive")
 	case ctx.IsSet("amt"):
 		amt = ctx.Int64("amt")
+	case ctx.IsSet("amt_msat"):
+		amtMsat = ctx.Int64("amt_msat")
 	case args.Present():
 		amt, err = strconv.ParseInt(args.First(), 10, 64)
 		if err != nil {
@@ -1186,6 +1243,7 @@ func queryRoutes(ctx *cli.Context) error {
 	req := &lnrpc.QueryRoutesRequest{
 		PubKey:              dest,
 		Amt:                 amt,
+		AmtMsat:             amtMsat,
 		FeeLimit:            feeLimit,
 		FinalCltvDelta:      int32(ctx.Int("final_cltv_delta")),
 		UseMissionControl:   ctx.Bool("use_mc"),
@@ -1196,12 +1254,38 @@ func queryRoutes(ctx *cli.Context) error {
 		BlindedPaymentPaths: blindedRoutes,
 	}
 
-	route, err := client.QueryRoutes(ctxc, req)
+	maxParts := ctx.Uint("max_parts")
+	req.MaxParts = uint32(maxParts)
+
+	resp, err := client.QueryRoutes(ctxc, req)
 	if err != nil {
 		return err
 	}
 
-	printRespJSON(route)
+	// Unless a multi-part preview was requested, the response is always
+	// printed as json.
+	if maxParts <= 1 || ctx.Bool("json") {
+		printRespJSON(resp)
+		return nil
+	}
+
+	var totalAmt, totalFees int64
+	for i, route := range resp.Routes {
+		partAmt := route.TotalAmtMsat - route.TotalFeesMsat
+		totalAmt += partAmt
+		totalFees += route.TotalFeesMsat
+
+		fmt.Printf("Part %d: %d msat, fee %d msat, time lock %d\n",
+			i+1, partAmt, route.TotalFeesMsat, route.TotalTimeLock)
+		for j, hop := range route.Hops {
+			fmt.Printf("    hop %d: chan_id=%d pubkey=%s\n", j+1,
+				hop.ChanId, hop.PubKey)
+		}
+	}
+
+	fmt.Printf("Total: %d msat over %d parts, fee %d msat\n", totalAmt,
+		len(resp.Routes), totalFees)
+
 	return nil
 }
 
@@ -1332,12 +1416,18 @@ var listPaymentsCommand = cli.Command{
 	time on systems with many payments, the count is not returned by
 	default. That feature can be turned on with the --count_total_payments
 	flag.
+
+	The payments are printed as json by default. With the --table flag, a
+	table is printed instead, with the status of each payment in its own
+	column. Payments that are still in flight or failed are only listed
+	with the --include_incomplete flag, which helps to diagnose stuck
+	payments.
 	`,
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name: "include_incomplete",
 			Usage: "if set to true, payments still in flight (or " +
-				"failed) will be returned as well, keeping" +
+				"failed) will be returned as well, keeping " +
 				"indices for payments the same as without " +
 				"the flag",
 		},
@@ -1382,6 +1472,11 @@ var listPaymentsCommand = cli.Command{
 				"payments with creation date less than or " +
 				"equal to it",
 		},
+		cli.BoolFlag{
+			Name: "table",
+			Usage: "if set, the payments are printed as a table " +
+				"instead of json",
+		},
 	},
 	Action: actionDecorator(listPayments),
 }
@@ -1406,10 +1501,62 @@ func listPayments(ctx *cli.Context) error {
 		return err
 	}
 
+	if ctx.Bool("table") {
+		fmt.Print(formatPaymentList(payments))
+		return nil
+	}
+
 	printRespJSON(payments)
 	return nil
 }
 
+// formatPaymentList formats the payments of a list payments response as an
+// ascii table, followed by the index offsets to resume pagination from.
+func formatPaymentList(resp *lnrpc.ListPaymentsResponse) string {
+	t := table.NewWriter()
+
+	t.AppendHeader(table.Row{
+		"INDEX", "CREATED", "PAYMENT_HASH", "STATUS", "AMOUNT", "FEE",
+		"HTLCS", "FAILURE_REASON",
+	})
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "AMOUNT", Align: text.AlignRight},
+		{Name: "FEE", Align: text.AlignRight},
+	})
+
+	for _, payment := range resp.Payments {
+		failureReason := "-"
+		if payment.FailureReason !=
+			lnrpc.PaymentFailureReason_FAILURE_REASON_NONE {
+
+			failureReason = payment.FailureReason.String()
+		}
+
+		created := time.Unix(0, payment.CreationTimeNs)
+
+		t.AppendRow(table.Row{
+			payment.PaymentIndex, created.Format(time.RFC3339),
+			payment.PaymentHash, payment.Status.String(),
+			formatMsat(payment.ValueMsat),
+			formatMsat(payment.FeeMsat), len(payment.Htlcs),
+			failureReason,
+		})
+	}
+
+	b := &bytes.Buffer{}
+	t.SetOutputMirror(b)
+	t.Render()
+
+	fmt.Fprintf(b, "First index offset: %d\n", resp.FirstIndexOffset)
+	fmt.Fprintf(b, "Last index offset:  %d\n", resp.LastIndexOffset)
+	if resp.TotalNumPayments != 0 {
+		fmt.Fprintf(b, "Total payments:     %d\n",
+			resp.TotalNumPayments)
+	}
+
+	return b.String()
+}
+
 var forwardingHistoryCommand = cli.Command{
 	Name:      "fwdinghistory",
 	Category:  "Payments",
@@ -15,9 +15,14 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/lightningnetwork/lnd/lncfg"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/routing"
 	"github.com/lightningnetwork/lnd/routing/route"
@@ -625,6 +630,15 @@ var connectCommand = cli.Command{
 	Description: `
 	Connect to a peer using its <pubkey> and host.
 
+	By default the connection is one-shot: the call blocks until the
+	connection is established, and the daemon won't reconnect once the peer
+	disconnects. With --perm, the daemon instead keeps a persistent
+	connection to the peer, reconnecting with a backoff whenever it is lost,
+	and the call returns without waiting for the initial connection. This
+	is useful to stay connected to channel peers.
+
+	lncli connect --perm <pubkey>@host
+
 	A custom timeout on the connection is supported. For instance, to timeout
 	the connection request in 30 seconds, use the following:
 
@@ -660,6 +674,14 @@ func connectPeer(ctx *cli.Context) error {
 			"pubkey@host:port")
 	}
 
+	// The timeout is sent in whole seconds, so we reject a timeout that
+	// would silently be rounded down to zero, i.e. the global default.
+	timeout := ctx.Duration("timeout")
+	if ctx.IsSet("timeout") && timeout < time.Second {
+		return fmt.Errorf("timeout must be at least 1s, got %v",
+			timeout)
+	}
+
 	addr := &lnrpc.LightningAddress{
 		Pubkey: splitAddr[0],
 		Host:   splitAddr[1],
@@ -667,7 +689,7 @@ func connectPeer(ctx *cli.Context) error {
 	req := &lnrpc.ConnectPeerRequest{
 		Addr:    addr,
 		Perm:    ctx.Bool("perm"),
-		Timeout: uint64(ctx.Duration("timeout").Seconds()),
+		Timeout: uint64(timeout.Seconds()),
 	}
 
 	lnid, err := client.ConnectPeer(ctxc, req)
@@ -745,7 +767,10 @@ var closeChannelCommand = cli.Command{
 	In the case of a cooperative closure, one can manually set the address
 	to deliver funds to upon closure. This is optional, and may only be used
 	if an upfront shutdown address has not already been set. If neither are
-	set the funds will be delivered to a new wallet address.
+	set the funds will be delivered to a new wallet address. The address
+	must belong to the network the node is running on. It is ignored for
+	force closures, as the outputs of the commitment transaction pay to
+	keys of the channel, from where they're swept to the wallet.
 
 	To view which funding_txids/output_indexes can be used for a channel close,
 	see the channel_point values within the listchannels command output.
@@ -799,7 +824,7 @@ var closeChannelCommand = cli.Command{
 			Usage: "(optional) an address to deliver funds " +
 				"upon cooperative channel closing, may only " +
 				"be used if an upfront shutdown address is not " +
-				"already set",
+				"already set; ignored with --force",
 		},
 	},
 	Action: actionDecorator(closeChannel),
@@ -830,13 +855,32 @@ func closeChannel(ctx *cli.Context) error {
 		return err
 	}
 
+	// The delivery address is only used for cooperative closures. We make
+	// sure it belongs to the network of the node before starting the
+	// negotiation.
+	deliveryAddr := ctx.String("delivery_addr")
+	if deliveryAddr != "" && !ctx.Bool("force") {
+		info, err := client.GetInfo(ctxc, &lnrpc.GetInfoRequest{})
+		if err != nil {
+			return err
+		}
+		if len(info.Chains) == 0 {
+			return errors.New("node didn't report its network")
+		}
+
+		err = validateDeliveryAddr(deliveryAddr, info.Chains[0].Network)
+		if err != nil {
+			return err
+		}
+	}
+
 	// TODO(roasbeef): implement time deadline within server
 	req := &lnrpc.CloseChannelRequest{
 		ChannelPoint:    channelPoint,
 		Force:           ctx.Bool("force"),
 		TargetConf:      int32(ctx.Int64("conf_target")),
 		SatPerVbyte:     ctx.Uint64(feeRateFlag),
-		DeliveryAddress: ctx.String("delivery_addr"),
+		DeliveryAddress: deliveryAddr,
 	}
 
 	// After parsing the request, we'll spin up a goroutine that will
@@ -871,6 +915,37 @@ func closeChannel(ctx *cli.Context) error {
 	return nil
 }
 
+// validateDeliveryAddr checks that the passed delivery address can be decoded,
+// and belongs to the given network as reported by getinfo.
+func validateDeliveryAddr(deliveryAddr, network string) error {
+	var params *chaincfg.Params
+	for _, p := range []*chaincfg.Params{
+		&chaincfg.MainNetParams, &chaincfg.TestNet3Params,
+		&chaincfg.RegressionNetParams, &chaincfg.SimNetParams,
+		&chaincfg.SigNetParams,
+	} {
+
+		if lncfg.NormalizeNetwork(p.Name) == network {
+			params = p
+			break
+		}
+	}
+	if params == nil {
+		return fmt.Errorf("unknown network %v", network)
+	}
+
+	addr, err := btcutil.DecodeAddress(deliveryAddr, params)
+	if err != nil {
+		return fmt.Errorf("invalid delivery address: %w", err)
+	}
+	if !addr.IsForNet(params) {
+		return fmt.Errorf("delivery address %v is not for %v",
+			deliveryAddr, network)
+	}
+
+	return nil
+}
+
 // executeChannelClose attempts to close the channel from a request. The closing
 // transaction ID is sent through `txidChan` as soon as it is broadcasted to the
 // network. The block boolean is used to determine if we should block until the
@@ -1162,12 +1237,20 @@ var abandonChannelCommand = cli.Command{
 	Usage:    "Abandons an existing channel.",
 	Description: `
 	Removes all channel state from the database except for a close
-	summary. This method can be used to get rid of permanently unusable
-	channels due to bugs fixed in newer versions of lnd.
+	summary marked as abandoned, without closing the channel on-chain.
+	This method can be used to get rid of permanently unusable channels,
+	e.g. if the peer is gone for good and the funding output can't be
+	spent from our side, or due to bugs fixed in newer versions of lnd.
 
-	Only available when lnd is built in debug mode. The flag
-	--i_know_what_i_am_doing can be set to override the debug/dev mode
-	requirement.
+	WARNING: Abandoning a channel deletes the keys and states needed to
+	claim our funds from it. If the funding transaction ever confirms (or
+	was confirmed), or the peer broadcasts a commitment transaction, the
+	funds in the channel may be lost for good. Make sure a static channel
+	backup of the channel exists before abandoning it.
+
+	As an explicit confirmation of the above, the flag
+	--i_know_what_i_am_doing must always be set. It also overrides the
+	requirement for lnd to be built in debug mode.
 
 	To view which funding_txids/output_indexes can be used for this command,
 	see the channel_point values within the listchannels command output.
@@ -1191,7 +1274,8 @@ var abandonChannelCommand = cli.Command{
 		},
 		cli.BoolFlag{
 			Name: "i_know_what_i_am_doing",
-			Usage: "override the requirement for lnd needing to " +
+			Usage: "required confirmation, which also " +
+				"overrides the requirement for lnd needing to " +
 				"be in dev/debug mode to use this command; " +
 				"when setting this the user attests that " +
 				"they know the danger of using this command " +
@@ -1205,8 +1289,6 @@ var abandonChannelCommand = cli.Command{
 
 func abandonChannel(ctx *cli.Context) error {
 	ctxc := getContext()
-	client, cleanUp := getClient(ctx)
-	defer cleanUp()
 
 	// Show command help if no arguments and flags were provided.
 	if ctx.NArg() == 0 && ctx.NumFlags() == 0 {
@@ -1214,11 +1296,21 @@ func abandonChannel(ctx *cli.Context) error {
 		return nil
 	}
 
+	// As abandoning a channel can result in a loss of funds, we require
+	// the caller to explicitly confirm they're aware of the risk.
+	if !ctx.Bool("i_know_what_i_am_doing") {
+		return fmt.Errorf("abandoning a channel can lead to loss of " +
+			"funds, set --i_know_what_i_am_doing to confirm")
+	}
+
 	channelPoint, err := parseChannelPoint(ctx)
 	if err != nil {
 		return err
 	}
 
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
 	req := &lnrpc.AbandonChannelRequest{
 		ChannelPoint:      channelPoint,
 		IKnowWhatIAmDoing: ctx.Bool("i_know_what_i_am_doing"),
@@ -1315,6 +1407,17 @@ var walletBalanceCommand = cli.Command{
 	Name:     "walletbalance",
 	Category: "Wallet",
 	Usage:    "Compute and display the wallet's current balance.",
+	Description: `
+	Display the balance of the wallet as json. With the --breakdown flag,
+	the balance is printed as text instead, broken down into its confirmed,
+	unconfirmed and locked portions.
+
+	The locked balance consists of outputs that are reserved for channel
+	openings which are still being negotiated, or that have been leased
+	explicitly. Locked outputs can't be spent and aren't included in the
+	confirmed and unconfirmed balances. The anchor reserve is the portion
+	of the confirmed balance that's kept aside to fee bump the
+	commitments of anchor channels.`,
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name: "account",
@@ -1322,6 +1425,11 @@ var walletBalanceCommand = cli.Command{
 				"is shown",
 			Value: "",
 		},
+		cli.BoolFlag{
+			Name: "breakdown",
+			Usage: "if set, the balance is printed as text " +
+				"instead of json",
+		},
 	},
 	Action: actionDecorator(walletBalance),
 }
@@ -1339,7 +1447,19 @@ func walletBalance(ctx *cli.Context) error {
 		return err
 	}
 
-	printRespJSON(resp)
+	if !ctx.Bool("breakdown") {
+		printRespJSON(resp)
+		return nil
+	}
+
+	fmt.Printf("Confirmed balance:    %d sat\n", resp.ConfirmedBalance)
+	fmt.Printf("Unconfirmed balance:  %d sat\n", resp.UnconfirmedBalance)
+	fmt.Printf("Locked balance:       %d sat\n", resp.LockedBalance)
+	fmt.Printf("Anchor reserve:       %d sat\n",
+		resp.ReservedBalanceAnchorChan)
+	fmt.Printf("Total balance:        %d sat (excluding locked)\n",
+		resp.TotalBalance)
+
 	return nil
 }
 
@@ -1367,11 +1487,54 @@ func channelBalance(ctx *cli.Context) error {
 }
 
 var getInfoCommand = cli.Command{
-	Name:   "getinfo",
-	Usage:  "Returns basic information related to the active daemon.",
+	Name:  "getinfo",
+	Usage: "Returns basic information related to the active daemon.",
+	Description: `
+	Displays the identity and version of the node, whether it's synced to
+	the chain and the graph, its best block, its channels and peers, as
+	well as its features and URIs as json.
+
+	The --summary flag prints a human-readable summary of the identity,
+	version and sync state of the node and the number of its active,
+	inactive and pending channels and connected peers instead.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name: "summary",
+			Usage: "if set, a human-readable summary is printed " +
+				"instead of the full json response",
+		},
+	},
 	Action: actionDecorator(getInfo),
 }
 
+// formatGetInfo formats the passed GetInfo response for display.
+func formatGetInfo(resp *lnrpc.GetInfoResponse) string {
+	networks := make([]string, 0, len(resp.Chains))
+	for _, chain := range resp.Chains {
+		networks = append(networks, chain.Chain+" "+chain.Network)
+	}
+
+	bestHeaderTime := time.Unix(resp.BestHeaderTimestamp, 0).UTC()
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "Alias:             %s\n", resp.Alias)
+	fmt.Fprintf(b, "Identity pubkey:   %s\n", resp.IdentityPubkey)
+	fmt.Fprintf(b, "Version:           %s\n", resp.Version)
+	fmt.Fprintf(b, "Network:           %s\n", strings.Join(networks, ", "))
+	fmt.Fprintf(b, "Synced to chain:   %v\n", resp.SyncedToChain)
+	fmt.Fprintf(b, "Synced to graph:   %v\n", resp.SyncedToGraph)
+	fmt.Fprintf(b, "Block height:      %d\n", resp.BlockHeight)
+	fmt.Fprintf(b, "Block hash:        %s\n", resp.BlockHash)
+	fmt.Fprintf(b, "Best header time:  %s\n",
+		bestHeaderTime.Format(time.RFC3339))
+	fmt.Fprintf(b, "Active channels:   %d\n", resp.NumActiveChannels)
+	fmt.Fprintf(b, "Inactive channels: %d\n", resp.NumInactiveChannels)
+	fmt.Fprintf(b, "Pending channels:  %d\n", resp.NumPendingChannels)
+	fmt.Fprintf(b, "Peers:             %d\n", resp.NumPeers)
+
+	return b.String()
+}
+
 func getInfo(ctx *cli.Context) error {
 	ctxc := getContext()
 	client, cleanUp := getClient(ctx)
@@ -1383,6 +1546,11 @@ func getInfo(ctx *cli.Context) error {
 		return err
 	}
 
+	if ctx.Bool("summary") {
+		fmt.Print(formatGetInfo(resp))
+		return nil
+	}
+
 	printRespJSON(resp)
 	return nil
 }
@@ -1435,6 +1603,20 @@ var listChannelsCommand = cli.Command{
 	Name:     "listchannels",
 	Category: "Channels",
 	Usage:    "List all open channels.",
+	Description: `
+	List all open channels as json. Besides the balances of both sides,
+	each channel reports who pays for its commitment transaction:
+
+	    initiator:      whether we opened the channel, in which case we
+	                    pay the commitment fee
+	    commit_fee:     the fee of the current commitment transaction in
+	                    sat
+	    commit_weight:  the weight of the current commitment transaction
+	    fee_per_kw:     the fee rate of the commitment transaction in
+	                    sat/kw
+
+	Use channelstatus to display a single channel in a human-readable
+	form.`,
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "active_only",
@@ -1534,6 +1716,296 @@ func listChannels(ctx *cli.Context) error {
 	return nil
 }
 
+var channelStatusCommand = cli.Command{
+	Name:     "channelstatus",
+	Category: "Channels",
+	Usage:    "Display the live state of one of our open channels.",
+	Description: `
+	Display the balances, commitment fee, the side paying it and the
+	unsettled HTLCs of an open channel from our own perspective, as of its
+	latest commitment. Unlike getchaninfo, which reports the channel as
+	announced in the graph, this also works for private channels.
+
+	The channel can be specified by either its channel point or short
+	channel ID, e.g.:
+
+	    lncli channelstatus --chan_point=<funding_txid>:<output_index>
+	    lncli channelstatus <chan_id>`,
+	ArgsUsage: "[chan_id | chan_point]",
+	Flags: []cli.Flag{
+		cli.Uint64Flag{
+			Name:  "chan_id",
+			Usage: "the 8-byte compact channel ID of the channel",
+		},
+		cli.StringFlag{
+			Name: "chan_point",
+			Usage: "the channel point of the channel, in the " +
+				"form funding_txid:output_index",
+		},
+		cli.BoolFlag{
+			Name: "json",
+			Usage: "if set, the full channel information is " +
+				"printed as json",
+		},
+	},
+	Action: actionDecorator(channelStatus),
+}
+
+func channelStatus(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	var (
+		chanID    uint64
+		chanPoint string
+		err       error
+	)
+	switch {
+	case ctx.IsSet("chan_id"):
+		chanID = ctx.Uint64("chan_id")
+
+	case ctx.IsSet("chan_point"):
+		chanPoint = ctx.String("chan_point")
+
+	case ctx.Args().Present():
+		arg := ctx.Args().First()
+		if strings.Contains(arg, ":") {
+			chanPoint = arg
+			break
+		}
+
+		chanID, err = strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("error parsing chan_id: %w", err)
+		}
+
+	default:
+		return fmt.Errorf("chan_id or chan_point argument missing")
+	}
+
+	if chanPoint != "" {
+		if _, err := parseChanPoint(chanPoint); err != nil {
+			return fmt.Errorf("unable to parse chan_point: %w", err)
+		}
+	}
+
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.ListChannels(ctxc, &lnrpc.ListChannelsRequest{})
+	if err != nil {
+		return err
+	}
+
+	var channel *lnrpc.Channel
+	for _, c := range resp.Channels {
+		if (chanPoint != "" && c.ChannelPoint == chanPoint) ||
+			(chanID != 0 && c.ChanId == chanID) {
+
+			channel = c
+			break
+		}
+	}
+	if channel == nil {
+		return fmt.Errorf("open channel not found")
+	}
+
+	if ctx.Bool("json") {
+		printRespJSON(channel)
+		return nil
+	}
+
+	var pendingIncoming, pendingOutgoing int
+	for _, htlc := range channel.PendingHtlcs {
+		if htlc.Incoming {
+			pendingIncoming++
+		} else {
+			pendingOutgoing++
+		}
+	}
+
+	fmt.Printf("Channel point:        %s\n", channel.ChannelPoint)
+	fmt.Printf("Channel ID:           %d\n", channel.ChanId)
+	fmt.Printf("Remote pubkey:        %s\n", channel.RemotePubkey)
+	fmt.Printf("Active:               %v\n", channel.Active)
+	fmt.Printf("Capacity:             %d sat\n", channel.Capacity)
+	fmt.Printf("Local balance:        %d sat\n", channel.LocalBalance)
+	fmt.Printf("Remote balance:       %d sat\n", channel.RemoteBalance)
+	fmt.Printf("Initiator:            %s\n",
+		channelInitiator(channel.Initiator))
+	fmt.Printf("Commitment fee:       %d sat (%d sat/kw)\n",
+		channel.CommitFee, channel.FeePerKw)
+	fmt.Printf("Commitment weight:    %d wu\n", channel.CommitWeight)
+	fmt.Printf("Unsettled balance:    %d sat (%d incoming, %d "+
+		"outgoing HTLCs)\n", channel.UnsettledBalance, pendingIncoming,
+		pendingOutgoing)
+	fmt.Printf("Number of updates:    %d\n", channel.NumUpdates)
+	fmt.Printf("Total sent:           %d sat\n",
+		channel.TotalSatoshisSent)
+	fmt.Printf("Total received:       %d sat\n",
+		channel.TotalSatoshisReceived)
+
+	return nil
+}
+
+// channelInitiator returns which side opened a channel, and thereby pays the
+// fee of its commitment transaction.
+func channelInitiator(initiator bool) string {
+	if initiator {
+		return "local (we pay the commitment fee)"
+	}
+
+	return "remote (the peer pays the commitment fee)"
+}
+
+var resyncChannelCommand = cli.Command{
+	Name:     "resyncchannel",
+	Category: "Channels",
+	Usage:    "Force a channel reestablish with the channel peer.",
+	Description: `
+	Cycle the connection to the peer of an open channel, which makes both
+	sides exchange channel_reestablish messages for all channels with the
+	peer and retransmit any commitment or revocation the other side is
+	missing. This can be used to recover a channel that got stuck in a
+	desynced state after a reconnection. Only peers the daemon maintains a
+	persistent connection to can be reconnected to.
+
+	Before reconnecting, the sync status of the channel as seen from our
+	local state is reported as one of:
+
+	    OWE_COMMITMENT:      we have to sign a commitment covering
+	                         updates the peer already sent us
+	    AWAITING_REVOCATION: we signed a commitment and are waiting for
+	                         the peer to revoke its prior state
+	    SYNC_STATUS_UNKNOWN: no commitment is outstanding on our side
+
+	A revocation we owe the peer can't be derived from our local state, it
+	is detected and retransmitted during the reestablish itself. The
+	command then waits for the channel to go inactive and become active
+	again, e.g.:
+
+	    lncli resyncchannel --chan_point=<funding_txid>:<output_index>`,
+	ArgsUsage: "chan_point",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "chan_point",
+			Usage: "the channel point of the channel, in the " +
+				"form funding_txid:output_index",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 30 * time.Second,
+			Usage: "how long to wait for the channel to become " +
+				"active again",
+		},
+	},
+	Action: actionDecorator(resyncChannel),
+}
+
+func resyncChannel(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	var chanPointStr string
+	switch {
+	case ctx.IsSet("chan_point"):
+		chanPointStr = ctx.String("chan_point")
+
+	case ctx.Args().Present():
+		chanPointStr = ctx.Args().First()
+
+	default:
+		return fmt.Errorf("chan_point argument missing")
+	}
+
+	chanPoint, err := parseChanPoint(chanPointStr)
+	if err != nil {
+		return fmt.Errorf("unable to parse chan_point: %w", err)
+	}
+
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	// We subscribe to channel events before requesting the resync, so we
+	// can't miss the channel going inactive and active again.
+	ctxt, cancel := context.WithTimeout(ctxc, ctx.Duration("timeout"))
+	defer cancel()
+
+	events, err := client.SubscribeChannelEvents(
+		ctxt, &lnrpc.ChannelEventSubscription{},
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.ResyncChannel(ctxc, &lnrpc.ResyncChannelRequest{
+		ChannelPoint: chanPoint,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Wait for the link to go down and come back up, which happens once
+	// the channel has been reestablished with the peer.
+	var inactive, active bool
+	for !active {
+		event, err := events.Recv()
+		if err != nil {
+			// If we've timed out waiting, we report that the
+			// channel wasn't reestablished instead of failing.
+			if ctxt.Err() != nil {
+				break
+			}
+
+			return err
+		}
+
+		switch event.Type {
+		case lnrpc.ChannelEventUpdate_INACTIVE_CHANNEL:
+			if sameChanPoint(event.GetInactiveChannel(), chanPoint) {
+				inactive = true
+			}
+
+		case lnrpc.ChannelEventUpdate_ACTIVE_CHANNEL:
+			if inactive && sameChanPoint(
+				event.GetActiveChannel(), chanPoint,
+			) {
+
+				active = true
+			}
+		}
+	}
+
+	printJSON(struct {
+		ChannelPoint  string `json:"channel_point"`
+		SyncStatus    string `json:"sync_status"`
+		Reestablished bool   `json:"reestablished"`
+	}{
+		ChannelPoint:  chanPointStr,
+		SyncStatus:    resp.SyncStatus.String(),
+		Reestablished: active,
+	})
+
+	return nil
+}
+
+// sameChanPoint returns true if both channel points reference the same
+// outpoint, regardless of how their funding txid is encoded.
+func sameChanPoint(a, b *lnrpc.ChannelPoint) bool {
+	if a == nil || b == nil || a.OutputIndex != b.OutputIndex {
+		return false
+	}
+
+	txidA, err := lnrpc.GetChanPointFundingTxid(a)
+	if err != nil {
+		return false
+	}
+	txidB, err := lnrpc.GetChanPointFundingTxid(b)
+	if err != nil {
+		return false
+	}
+
+	return *txidA == *txidB
+}
+
 var closedChannelsCommand = cli.Command{
 	Name:     "closedchannels",
 	Category: "Channels",
@@ -1792,11 +2264,18 @@ var debugLevelCommand = cli.Command{
 	Description: `Logging level for all subsystems {trace, debug, info, warn, error, critical, off}
 	You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems
 
-	Use show to list available subsystems`,
+	Use show to list available subsystems along with their current log
+	levels`,
 	Flags: []cli.Flag{
 		cli.BoolFlag{
-			Name:  "show",
-			Usage: "if true, then the list of available sub-systems will be printed out",
+			Name: "show",
+			Usage: "if true, then the list of available " +
+				"sub-systems and their current log levels " +
+				"will be printed out",
+		},
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "if set, the sub-systems are printed as json",
 		},
 		cli.StringFlag{
 			Name:  "level",
@@ -1820,10 +2299,46 @@ func debugLevel(ctx *cli.Context) error {
 		return err
 	}
 
-	printRespJSON(resp)
+	if !req.Show || ctx.Bool("json") {
+		printRespJSON(resp)
+		return nil
+	}
+
+	fmt.Print(formatDebugLevels(resp))
+
 	return nil
 }
 
+// formatDebugLevels renders the sub-systems of a debuglevel --show response as
+// a table along with their current log levels. Older daemons don't report the
+// levels, in which case only the sub-systems are listed.
+func formatDebugLevels(resp *lnrpc.DebugLevelResponse) string {
+	levels := make(map[string]string)
+	if resp.LevelSpec != "" {
+		for _, pair := range strings.Split(resp.LevelSpec, ",") {
+			subsystem, level, _ := strings.Cut(pair, "=")
+			levels[subsystem] = level
+		}
+	}
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{"SUBSYSTEM", "LEVEL"})
+	for _, subsystem := range strings.Fields(resp.SubSystems) {
+		level, ok := levels[subsystem]
+		if !ok {
+			level = "unknown"
+		}
+
+		t.AppendRow(table.Row{subsystem, level})
+	}
+
+	b := &bytes.Buffer{}
+	t.SetOutputMirror(b)
+	t.Render()
+
+	return b.String()
+}
+
 var listChainTxnsCommand = cli.Command{
 	Name:     "listchaintxns",
 	Category: "On-chain",
@@ -1886,7 +2401,22 @@ var stopCommand = cli.Command{
 	Usage: "Stop and shutdown the daemon.",
 	Description: `
 	Gracefully stop all daemon subsystems before stopping the daemon itself.
-	This is equivalent to stopping it using CTRL-C.`,
+	This is equivalent to stopping it using CTRL-C.
+
+	If the --force flag is set, the daemon won't wait for in-flight HTLCs
+	or channel close negotiations to finish, and will exit once the
+	daemon's force-shutdown-timeout has passed even if some subsystems are
+	still shutting down. This is
+	useful if an unresponsive peer causes the graceful shutdown to hang.
+	Note that channels with such pending operations may need to be
+	reconnected and resynchronized with their peer on the next start.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name: "force",
+			Usage: "don't wait for in-flight operations to " +
+				"complete before exiting",
+		},
+	},
 	Action: actionDecorator(stopDaemon),
 }
 
@@ -1895,7 +2425,9 @@ func stopDaemon(ctx *cli.Context) error {
 	client, cleanUp := getClient(ctx)
 	defer cleanUp()
 
-	_, err := client.StopDaemon(ctxc, &lnrpc.StopRequest{})
+	_, err := client.StopDaemon(ctxc, &lnrpc.StopRequest{
+		Force: ctx.Bool("force"),
+	})
 	if err != nil {
 		return err
 	}
@@ -1912,12 +2444,23 @@ var signMessageCommand = cli.Command{
 	Sign msg with the resident node's private key.
 	Returns the signature as a zbase32 string.
 
+	Instead of passing the message as an argument, it can be read from a
+	file using --msg_file, or from stdin by passing --msg_file=-. The
+	file content is signed byte for byte, so binary messages are
+	supported and a trailing newline is part of the signed message.
+
 	Positional arguments and flags can be used interchangeably but not at the same time!`,
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "msg",
 			Usage: "the message to sign",
 		},
+		cli.StringFlag{
+			Name: "msg_file",
+			Usage: "the path to a file containing the exact " +
+				"message bytes to sign, or - to read them " +
+				"from stdin",
+		},
 	},
 	Action: actionDecorator(signMessage),
 }
@@ -1927,15 +2470,9 @@ func signMessage(ctx *cli.Context) error {
 	client, cleanUp := getClient(ctx)
 	defer cleanUp()
 
-	var msg []byte
-
-	switch {
-	case ctx.IsSet("msg"):
-		msg = []byte(ctx.String("msg"))
-	case ctx.Args().Present():
-		msg = []byte(ctx.Args().First())
-	default:
-		return fmt.Errorf("msg argument missing")
+	msg, _, err := parseMessageArg(ctx, os.Stdin)
+	if err != nil {
+		return err
 	}
 
 	resp, err := client.SignMessage(ctxc, &lnrpc.SignMessageRequest{Msg: msg})
@@ -1947,6 +2484,51 @@ func signMessage(ctx *cli.Context) error {
 	return nil
 }
 
+// parseMessageArg returns the message to sign or verify, which is either
+// passed using the --msg flag, read from the file given by --msg_file (or
+// from stdin if the path is -), or passed as the first positional argument.
+// Messages read from a file are returned byte for byte, without any newline
+// handling. The remaining positional arguments are returned as well.
+func parseMessageArg(ctx *cli.Context, stdin io.Reader) ([]byte, cli.Args,
+	error) {
+
+	args := ctx.Args()
+	if ctx.IsSet("msg") && ctx.IsSet("msg_file") {
+		return nil, nil, fmt.Errorf("cannot set both msg and msg_file")
+	}
+
+	switch {
+	case ctx.IsSet("msg"):
+		return []byte(ctx.String("msg")), args, nil
+
+	case ctx.IsSet("msg_file"):
+		msgFile := ctx.String("msg_file")
+		if msgFile == "-" {
+			msg, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to read "+
+					"msg from stdin: %w", err)
+			}
+
+			return msg, args, nil
+		}
+
+		msg, err := os.ReadFile(lncfg.CleanAndExpandPath(msgFile))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read msg_file: "+
+				"%w", err)
+		}
+
+		return msg, args, nil
+
+	case args.Present():
+		return []byte(args.First()), args.Tail(), nil
+
+	default:
+		return nil, nil, fmt.Errorf("msg argument missing")
+	}
+}
+
 var verifyMessageCommand = cli.Command{
 	Name:      "verifymessage",
 	Category:  "Wallet",
@@ -1957,12 +2539,23 @@ var verifyMessageCommand = cli.Command{
 	The signature must be zbase32 encoded and signed with the private key of
 	an active node in the resident node's channel database.
 
+	Instead of passing the message as an argument, it can be read from a
+	file using --msg_file, or from stdin by passing --msg_file=-. The
+	file content is verified byte for byte, so binary messages are
+	supported and a trailing newline is part of the verified message.
+
 	Positional arguments and flags can be used interchangeably but not at the same time!`,
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "msg",
 			Usage: "the message to verify",
 		},
+		cli.StringFlag{
+			Name: "msg_file",
+			Usage: "the path to a file containing the exact " +
+				"message bytes to verify, or - to read them " +
+				"from stdin",
+		},
 		cli.StringFlag{
 			Name:  "sig",
 			Usage: "the zbase32 encoded signature of the message",
@@ -1976,21 +2569,11 @@ func verifyMessage(ctx *cli.Context) error {
 	client, cleanUp := getClient(ctx)
 	defer cleanUp()
 
-	var (
-		msg []byte
-		sig string
-	)
-
-	args := ctx.Args()
+	var sig string
 
-	switch {
-	case ctx.IsSet("msg"):
-		msg = []byte(ctx.String("msg"))
-	case args.Present():
-		msg = []byte(ctx.Args().First())
-		args = args.Tail()
-	default:
-		return fmt.Errorf("msg argument missing")
+	msg, args, err := parseMessageArg(ctx, os.Stdin)
+	if err != nil {
+		return err
 	}
 
 	switch {
@@ -2018,10 +2601,106 @@ var feeReportCommand = cli.Command{
 	Usage:    "Display the current fee policies of all active channels.",
 	Description: `
 	Returns the current fee policies of all active channels.
-	Fee policies can be updated using the updatechanpolicy command.`,
+	Fee policies can be updated using the updatechanpolicy command.
+
+	The policies are followed by a summary of the routing fees earned over
+	the past day, week and month, and the average fee policy across all
+	active channels. The --json flag prints the policies and the summary
+	as json.`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name: "json",
+			Usage: "if set, the fee policies and the summary are " +
+				"printed as json",
+		},
+	},
 	Action: actionDecorator(feeReport),
 }
 
+// feeReportSummary aggregates the fee report of all active channels.
+type feeReportSummary struct {
+	// DayFeeSum is the total fee revenue in satoshis over the past day.
+	DayFeeSum uint64 `json:"day_fee_sum"`
+
+	// WeekFeeSum is the total fee revenue in satoshis over the past week.
+	WeekFeeSum uint64 `json:"week_fee_sum"`
+
+	// MonthFeeSum is the total fee revenue in satoshis over the past
+	// month.
+	MonthFeeSum uint64 `json:"month_fee_sum"`
+
+	// NumChannels is the number of active channels in the report.
+	NumChannels int `json:"num_channels"`
+
+	// AvgBaseFeeMsat is the average base fee across all active channels.
+	AvgBaseFeeMsat float64 `json:"avg_base_fee_msat"`
+
+	// AvgFeePerMil is the average proportional fee rate across all active
+	// channels, in parts per million.
+	AvgFeePerMil float64 `json:"avg_fee_per_mil"`
+}
+
+// feeReportJSON is the json output of the feereport command.
+type feeReportJSON struct {
+	ChannelFees []*lnrpc.ChannelFeeReport `json:"channel_fees"`
+	Summary     *feeReportSummary         `json:"summary"`
+}
+
+// newFeeReportSummary aggregates the passed fee report.
+func newFeeReportSummary(resp *lnrpc.FeeReportResponse) *feeReportSummary {
+	summary := &feeReportSummary{
+		DayFeeSum:   resp.DayFeeSum,
+		WeekFeeSum:  resp.WeekFeeSum,
+		MonthFeeSum: resp.MonthFeeSum,
+		NumChannels: len(resp.ChannelFees),
+	}
+	if summary.NumChannels == 0 {
+		return summary
+	}
+
+	var baseFeeSum, feePerMilSum int64
+	for _, channelFee := range resp.ChannelFees {
+		baseFeeSum += channelFee.BaseFeeMsat
+		feePerMilSum += channelFee.FeePerMil
+	}
+
+	numChannels := float64(summary.NumChannels)
+	summary.AvgBaseFeeMsat = float64(baseFeeSum) / numChannels
+	summary.AvgFeePerMil = float64(feePerMilSum) / numChannels
+
+	return summary
+}
+
+// formatFeeReport formats the fee policies of the passed fee report as an
+// ascii table, followed by the summary.
+func formatFeeReport(resp *lnrpc.FeeReportResponse,
+	summary *feeReportSummary) string {
+
+	t := table.NewWriter()
+	t.AppendHeader(table.Row{
+		"CHAN_ID", "CHANNEL_POINT", "BASE_FEE_MSAT", "FEE_PER_MIL",
+	})
+	for _, channelFee := range resp.ChannelFees {
+		t.AppendRow(table.Row{
+			channelFee.ChanId, channelFee.ChannelPoint,
+			channelFee.BaseFeeMsat, channelFee.FeePerMil,
+		})
+	}
+
+	b := &bytes.Buffer{}
+	t.SetOutputMirror(b)
+	t.Render()
+
+	fmt.Fprintf(b, "Fees earned:      %d sat (day), %d sat (week), %d "+
+		"sat (month)\n", summary.DayFeeSum, summary.WeekFeeSum,
+		summary.MonthFeeSum)
+	fmt.Fprintf(b, "Active channels:  %d\n", summary.NumChannels)
+	fmt.Fprintf(b, "Average base fee: %.3f msat\n", summary.AvgBaseFeeMsat)
+	fmt.Fprintf(b, "Average fee rate: %.3f ppm\n", summary.AvgFeePerMil)
+
+	return b.String()
+}
+
 func feeReport(ctx *cli.Context) error {
 	ctxc := getContext()
 	client, cleanUp := getClient(ctx)
@@ -2033,7 +2712,18 @@ func feeReport(ctx *cli.Context) error {
 		return err
 	}
 
-	printRespJSON(resp)
+	summary := newFeeReportSummary(resp)
+
+	if ctx.Bool("json") {
+		printJSON(&feeReportJSON{
+			ChannelFees: resp.ChannelFees,
+			Summary:     summary,
+		})
+
+		return nil
+	}
+
+	fmt.Print(formatFeeReport(resp, summary))
 	return nil
 }
 
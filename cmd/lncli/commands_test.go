@@ -1,13 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli"
 )
 
 // TestParseChanPoint tests parseChanPoint with various
@@ -121,3 +128,380 @@ func TestParseTimeLockDelta(t *testing.T) {
 		}
 	}
 }
+
+// TestParseMessageArg asserts that messages read from a file or stdin are
+// passed through byte for byte.
+func TestParseMessageArg(t *testing.T) {
+	t.Parallel()
+
+	binaryMsg := []byte{0x00, 0xff, '\r', '\n', 'a', '\n'}
+	msgFile := filepath.Join(t.TempDir(), "msg")
+	require.NoError(t, os.WriteFile(msgFile, binaryMsg, 0600))
+
+	newContext := func(args ...string) *cli.Context {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		set.String("msg", "", "")
+		set.String("msg_file", "", "")
+		require.NoError(t, set.Parse(args))
+
+		return cli.NewContext(nil, set, nil)
+	}
+
+	// The message can be read from a file, leaving the positional
+	// arguments untouched.
+	msg, args, err := parseMessageArg(
+		newContext("--msg_file", msgFile, "sig"), nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, binaryMsg, msg)
+	require.Equal(t, "sig", args.First())
+
+	// The same applies to reading from stdin.
+	msg, _, err = parseMessageArg(
+		newContext("--msg_file", "-"), bytes.NewReader(binaryMsg),
+	)
+	require.NoError(t, err)
+	require.Equal(t, binaryMsg, msg)
+
+	// A positional message consumes the first argument.
+	msg, args, err = parseMessageArg(newContext("hello", "sig"), nil)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), msg)
+	require.Equal(t, "sig", args.First())
+
+	// Setting both flags is rejected, as is a missing message.
+	_, _, err = parseMessageArg(
+		newContext("--msg", "hello", "--msg_file", msgFile), nil,
+	)
+	require.Error(t, err)
+
+	_, _, err = parseMessageArg(newContext(), nil)
+	require.Error(t, err)
+}
+
+// TestFormatPaymentList asserts that incomplete payments are listed with their
+// status and failure reason next to the completed ones.
+func TestFormatPaymentList(t *testing.T) {
+	t.Parallel()
+
+	resp := &lnrpc.ListPaymentsResponse{
+		Payments: []*lnrpc.Payment{{
+			PaymentHash:  "aa",
+			ValueMsat:    100_500,
+			FeeMsat:      1_000,
+			Status:       lnrpc.Payment_SUCCEEDED,
+			PaymentIndex: 1,
+			Htlcs:        []*lnrpc.HTLCAttempt{{}},
+		}, {
+			PaymentHash:  "bb",
+			ValueMsat:    200_000,
+			Status:       lnrpc.Payment_IN_FLIGHT,
+			PaymentIndex: 2,
+		}, {
+			PaymentHash:  "cc",
+			ValueMsat:    300_000,
+			Status:       lnrpc.Payment_FAILED,
+			PaymentIndex: 3,
+			FailureReason: lnrpc.
+				PaymentFailureReason_FAILURE_REASON_NO_ROUTE,
+		}},
+		FirstIndexOffset: 1,
+		LastIndexOffset:  3,
+	}
+
+	out := formatPaymentList(resp)
+	lines := strings.Split(out, "\n")
+
+	// Each payment is printed on its own row.
+	findRow := func(hash string) string {
+		for _, line := range lines {
+			if strings.Contains(line, " "+hash+" ") {
+				return line
+			}
+		}
+		t.Fatalf("payment %v not found in:\n%v", hash, out)
+
+		return ""
+	}
+
+	row := findRow("aa")
+	require.Contains(t, row, "SUCCEEDED")
+	require.Contains(t, row, "100.5")
+	require.Contains(t, row, " 1 ")
+
+	row = findRow("bb")
+	require.Contains(t, row, "IN_FLIGHT")
+
+	row = findRow("cc")
+	require.Contains(t, row, "FAILED")
+	require.Contains(t, row, "FAILURE_REASON_NO_ROUTE")
+
+	require.Contains(t, out, "First index offset: 1\n")
+	require.Contains(t, out, "Last index offset:  3\n")
+	require.NotContains(t, out, "Total payments")
+}
+
+// TestFeeReportSummary asserts that the fee report summary holds the fee
+// revenue of the report and averages the fee policies of all channels.
+func TestFeeReportSummary(t *testing.T) {
+	t.Parallel()
+
+	resp := &lnrpc.FeeReportResponse{
+		ChannelFees: []*lnrpc.ChannelFeeReport{{
+			ChanId:       1,
+			ChannelPoint: "aa:0",
+			BaseFeeMsat:  1000,
+			FeePerMil:    100,
+		}, {
+			ChanId:       2,
+			ChannelPoint: "bb:1",
+			BaseFeeMsat:  0,
+			FeePerMil:    1,
+		}},
+		DayFeeSum:   5,
+		WeekFeeSum:  50,
+		MonthFeeSum: 500,
+	}
+
+	summary := newFeeReportSummary(resp)
+	require.Equal(t, &feeReportSummary{
+		DayFeeSum:      5,
+		WeekFeeSum:     50,
+		MonthFeeSum:    500,
+		NumChannels:    2,
+		AvgBaseFeeMsat: 500,
+		AvgFeePerMil:   50.5,
+	}, summary)
+
+	out := formatFeeReport(resp, summary)
+	require.Contains(t, out, "aa:0")
+	require.Contains(t, out, "bb:1")
+	require.Contains(
+		t, out, "Fees earned:      5 sat (day), 50 sat (week), 500 "+
+			"sat (month)\n",
+	)
+	require.Contains(t, out, "Active channels:  2\n")
+	require.Contains(t, out, "Average base fee: 500.000 msat\n")
+	require.Contains(t, out, "Average fee rate: 50.500 ppm\n")
+
+	// Without any active channels, the averages are zero.
+	summary = newFeeReportSummary(&lnrpc.FeeReportResponse{DayFeeSum: 1})
+	require.Equal(t, &feeReportSummary{DayFeeSum: 1}, summary)
+}
+
+// TestParseRouteHints asserts that route hints are parsed from JSON and that
+// malformed hints are rejected.
+func TestParseRouteHints(t *testing.T) {
+	t.Parallel()
+
+	const nodeID = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959" +
+		"f2815b16f81798"
+
+	hopHint := func(fields string) string {
+		return fmt.Sprintf(`{"node_id": "%s", "chan_id": "1234", `+
+			`"fee_base_msat": 1000, `+
+			`"fee_proportional_millionths": 1%s}`, nodeID, fields)
+	}
+
+	routeHints, err := parseRouteHints(fmt.Sprintf(
+		`[{"hop_hints": [%s, %s]}, {"hop_hints": [%s]}]`,
+		hopHint(`, "cltv_expiry_delta": 40`), hopHint(""),
+		hopHint(""),
+	))
+	require.NoError(t, err)
+	require.Len(t, routeHints, 2)
+	require.Len(t, routeHints[0].HopHints, 2)
+	hint := routeHints[0].HopHints[0]
+	require.Equal(t, nodeID, hint.NodeId)
+	require.EqualValues(t, 1234, hint.ChanId)
+	require.EqualValues(t, 1000, hint.FeeBaseMsat)
+	require.EqualValues(t, 1, hint.FeeProportionalMillionths)
+	require.EqualValues(t, 40, hint.CltvExpiryDelta)
+
+	tooManyHints := strings.Repeat(
+		`{"hop_hints": [`+hopHint("")+`]},`, maxRouteHints+1,
+	)
+	invalidHints := []string{
+		`not json`,
+		`[]`,
+		`[{"hop_hints": []}]`,
+		`[{"hop_hints": [{"node_id": "00", "chan_id": "1"}]}]`,
+		`[{"hop_hints": [{"node_id": "` + nodeID + `"}]}]`,
+		`[{"hop_hints": [` + hopHint(`, "cltv_expiry_delta": 65536`) +
+			`]}]`,
+		`[` + strings.TrimSuffix(tooManyHints, ",") + `]`,
+	}
+	for _, hints := range invalidHints {
+		_, err := parseRouteHints(hints)
+		require.Error(t, err, hints)
+	}
+}
+
+// TestValidateDeliveryAddr tests that a delivery address is only accepted for
+// the network it belongs to.
+func TestValidateDeliveryAddr(t *testing.T) {
+	t.Parallel()
+
+	const (
+		mainnetAddr = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+		testnetAddr = "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+		legacyAddr  = "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"
+	)
+
+	testCases := []struct {
+		name    string
+		addr    string
+		network string
+		err     string
+	}{{
+		name:    "mainnet segwit",
+		addr:    mainnetAddr,
+		network: "mainnet",
+	}, {
+		name:    "mainnet legacy",
+		addr:    legacyAddr,
+		network: "mainnet",
+	}, {
+		name:    "testnet segwit",
+		addr:    testnetAddr,
+		network: "testnet",
+	}, {
+		name:    "mainnet address on testnet",
+		addr:    mainnetAddr,
+		network: "testnet",
+		err:     "is not for testnet",
+	}, {
+		name:    "legacy mainnet address on regtest",
+		addr:    legacyAddr,
+		network: "regtest",
+		err:     "invalid delivery address",
+	}, {
+		name:    "testnet address on mainnet",
+		addr:    testnetAddr,
+		network: "mainnet",
+		err:     "is not for mainnet",
+	}, {
+		name:    "garbage",
+		addr:    "not an address",
+		network: "mainnet",
+		err:     "invalid delivery address",
+	}, {
+		name:    "unknown network",
+		addr:    mainnetAddr,
+		network: "foonet",
+		err:     "unknown network foonet",
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateDeliveryAddr(tc.addr, tc.network)
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+// TestFormatGetInfo asserts that the sync status, best block and channel
+// counts of the node are displayed.
+func TestFormatGetInfo(t *testing.T) {
+	t.Parallel()
+
+	out := formatGetInfo(&lnrpc.GetInfoResponse{
+		Alias:          "alice",
+		IdentityPubkey: "02aa",
+		Version:        "0.1.0",
+		Chains: []*lnrpc.Chain{{
+			Chain:   "bitcoin",
+			Network: "regtest",
+		}},
+		SyncedToChain:       true,
+		BlockHeight:         800000,
+		BlockHash:           "00ff",
+		BestHeaderTimestamp: 1700000000,
+		NumActiveChannels:   3,
+		NumInactiveChannels: 2,
+		NumPendingChannels:  1,
+		NumPeers:            4,
+	})
+
+	require.Equal(t, "Alias:             alice\n"+
+		"Identity pubkey:   02aa\n"+
+		"Version:           0.1.0\n"+
+		"Network:           bitcoin regtest\n"+
+		"Synced to chain:   true\n"+
+		"Synced to graph:   false\n"+
+		"Block height:      800000\n"+
+		"Block hash:        00ff\n"+
+		"Best header time:  2023-11-14T22:13:20Z\n"+
+		"Active channels:   3\n"+
+		"Inactive channels: 2\n"+
+		"Pending channels:  1\n"+
+		"Peers:             4\n", out)
+}
+
+// TestFormatInvoiceList asserts that invoices are listed with their state and
+// followed by the index offsets to resume pagination from.
+func TestFormatInvoiceList(t *testing.T) {
+	t.Parallel()
+
+	out := formatInvoiceList(&lnrpc.ListInvoiceResponse{
+		Invoices: []*lnrpc.Invoice{{
+			Memo:        "coffee",
+			RHash:       []byte{0xaa, 0xbb},
+			ValueMsat:   100_500,
+			AmtPaidMsat: 100_500,
+			State:       lnrpc.Invoice_SETTLED,
+			AddIndex:    7,
+		}, {
+			RHash:     []byte{0xcc},
+			ValueMsat: 2_000,
+			State:     lnrpc.Invoice_OPEN,
+			AddIndex:  8,
+		}},
+		FirstIndexOffset: 7,
+		LastIndexOffset:  8,
+	})
+
+	require.Contains(t, out, "coffee")
+	require.Contains(t, out, "aabb")
+	require.Contains(t, out, "SETTLED")
+	require.Contains(t, out, "OPEN")
+	require.Contains(t, out, "100.5")
+	require.Contains(t, out, "First index offset: 7\n")
+	require.Contains(t, out, "Last index offset:  8\n")
+}
+
+// TestFormatDebugLevels asserts that the sub-systems are listed along with
+// their current log levels, which are unknown if the daemon doesn't report
+// them.
+func TestFormatDebugLevels(t *testing.T) {
+	t.Parallel()
+
+	out := formatDebugLevels(&lnrpc.DebugLevelResponse{
+		SubSystems: "HSWC PEER SRVR",
+		LevelSpec:  "HSWC=debug,PEER=info,SRVR=off",
+	})
+	require.Equal(t, "+-----------+-------+\n"+
+		"| SUBSYSTEM | LEVEL |\n"+
+		"+-----------+-------+\n"+
+		"| HSWC      | debug |\n"+
+		"| PEER      | info  |\n"+
+		"| SRVR      | off   |\n"+
+		"+-----------+-------+\n", out)
+
+	out = formatDebugLevels(&lnrpc.DebugLevelResponse{
+		SubSystems: "HSWC",
+	})
+	require.Equal(t, "+-----------+---------+\n"+
+		"| SUBSYSTEM | LEVEL   |\n"+
+		"+-----------+---------+\n"+
+		"| HSWC      | unknown |\n"+
+		"+-----------+---------+\n", out)
+}
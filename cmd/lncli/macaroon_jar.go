@@ -23,6 +23,7 @@ type macaroonJar struct {
 	Default string           `json:"default,omitempty"`
 	Timeout int64            `json:"timeout,omitempty"`
 	IP      string           `json:"ip,omitempty"`
+	Nonce   bool             `json:"nonce,omitempty"`
 	Jar     []*macaroonEntry `json:"jar"`
 }
 
@@ -6,6 +6,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -13,6 +14,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/lightningnetwork/lnd"
@@ -24,6 +26,7 @@ import (
 	"github.com/urfave/cli"
 	"golang.org/x/term"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 )
@@ -45,8 +48,10 @@ const (
 	envVarMacaroonPath    = "LNCLI_MACAROONPATH"
 	envVarMacaroonTimeout = "LNCLI_MACAROONTIMEOUT"
 	envVarMacaroonIP      = "LNCLI_MACAROONIP"
+	envVarMacaroonNonce   = "LNCLI_MACAROONNONCE"
 	envVarProfile         = "LNCLI_PROFILE"
 	envVarMacFromJar      = "LNCLI_MACFROMJAR"
+	envVarConnTimeout     = "LNCLI_CONNTIMEOUT"
 )
 
 var (
@@ -56,6 +61,16 @@ var (
 	// maxMsgRecvSize is the largest message our client will receive. We
 	// set this to 200MiB atm.
 	maxMsgRecvSize = grpc.MaxCallRecvMsgSize(lnrpc.MaxGrpcMsgSize)
+
+	// connBackoff is the backoff used between connection attempts while
+	// waiting for the daemon to become available, if a connection
+	// timeout is set.
+	connBackoff = backoff.Config{
+		BaseDelay:  100 * time.Millisecond,
+		Multiplier: 2,
+		Jitter:     0.2,
+		MaxDelay:   5 * time.Second,
+	}
 )
 
 func fatal(err error) {
@@ -178,7 +193,6 @@ func getClientConn(ctx *cli.Context, skipMacaroons bool) *grpc.ClientConn {
 			// server clock is ahead of the client clock (or invalid
 			// altogether if, in the latter case, this time is more
 			// than 60 seconds).
-			// TODO(aakselrod): add better anti-replay protection.
 			macaroons.TimeoutConstraint(profile.Macaroons.Timeout),
 
 			// Lock macaroon down to a specific IP address.
@@ -187,6 +201,22 @@ func getClientConn(ctx *cli.Context, skipMacaroons bool) *grpc.ClientConn {
 			// ... Add more constraints if needed.
 		}
 
+		// If requested, we also add a random nonce which makes the
+		// macaroon unique within its validity time. The server can
+		// then track the nonces it has seen to reject a leaked
+		// macaroon that is replayed from a different connection.
+		if profile.Macaroons.Nonce {
+			var nonce [macaroons.NonceSize]byte
+			if _, err := rand.Read(nonce[:]); err != nil {
+				fatal(fmt.Errorf("unable to generate macaroon "+
+					"nonce: %v", err))
+			}
+			macConstraints = append(
+				macConstraints,
+				macaroons.NonceConstraint(nonce),
+			)
+		}
+
 		// Apply constraints to the macaroon.
 		constrainedMac, err := macaroons.AddConstraints(
 			mac, macConstraints...,
@@ -225,8 +255,33 @@ func getClientConn(ctx *cli.Context, skipMacaroons bool) *grpc.ClientConn {
 
 	opts = append(opts, grpc.WithDefaultCallOptions(maxMsgRecvSize))
 
-	conn, err := grpc.Dial(profile.RPCServer, opts...)
-	if err != nil {
+	// By default we don't wait for the connection to be established, so
+	// any command fails right away if the daemon isn't reachable. If a
+	// connection timeout is set, we instead block until the connection
+	// is ready, retrying with an exponential backoff in the meantime.
+	dialCtx := context.Background()
+	connTimeout := ctx.GlobalDuration("conn_timeout")
+	if connTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(dialCtx, connTimeout)
+		defer cancel()
+
+		opts = append(
+			opts, grpc.WithBlock(), grpc.WithReturnConnectionError(),
+			grpc.WithConnectParams(grpc.ConnectParams{
+				Backoff:           connBackoff,
+				MinConnectTimeout: connTimeout,
+			}),
+		)
+	}
+
+	conn, err := grpc.DialContext(dialCtx, profile.RPCServer, opts...)
+	switch {
+	case err != nil && connTimeout > 0:
+		fatal(fmt.Errorf("unable to connect to RPC server within %v: "+
+			"%v", connTimeout, err))
+
+	case err != nil:
 		fatal(fmt.Errorf("unable to connect to RPC server: %v", err))
 	}
 
@@ -406,6 +461,15 @@ func main() {
 			Usage:  "If set, lock macaroon to specific IP address.",
 			EnvVar: envVarMacaroonIP,
 		},
+		cli.BoolFlag{
+			Name: "macaroonnonce",
+			Usage: "If set, add a random nonce to the macaroon " +
+				"in addition to its validity time, which " +
+				"allows the server to detect replay from " +
+				"other hosts. The macaroontimeout must not " +
+				"exceed 10 minutes.",
+			EnvVar: envVarMacaroonNonce,
+		},
 		cli.StringFlag{
 			Name: "profile, p",
 			Usage: "Instead of reading settings from command " +
@@ -431,6 +495,15 @@ func main() {
 				"to lnd. This flag may be specified multiple " +
 				"times. The format is: \"key:value\".",
 		},
+		cli.DurationFlag{
+			Name: "conn_timeout",
+			Usage: "If set, wait up to this long for the RPC " +
+				"server to become available, retrying with " +
+				"an exponential backoff, instead of failing " +
+				"right away. Useful when running lncli right " +
+				"after starting lnd.",
+			EnvVar: envVarConnTimeout,
+		},
 		cli.BoolFlag{
 			Name: "insecure",
 			Usage: "Connect to the rpc server without TLS " +
@@ -468,6 +541,8 @@ func main() {
 		lookupInvoiceCommand,
 		listInvoicesCommand,
 		listChannelsCommand,
+		channelStatusCommand,
+		resyncChannelCommand,
 		closedChannelsCommand,
 		listPaymentsCommand,
 		describeGraphCommand,
@@ -209,6 +209,7 @@ func profileFromContext(ctx *cli.Context, store, skipMacaroons bool) (
 		Default: macEntry.Name,
 		Timeout: ctx.GlobalInt64("macaroontimeout"),
 		IP:      ctx.GlobalString("macaroonip"),
+		Nonce:   ctx.GlobalBool("macaroonnonce"),
 		Jar:     []*macaroonEntry{macEntry},
 	}
 
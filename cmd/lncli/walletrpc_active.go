@@ -267,11 +267,31 @@ var bumpCloseFeeCommand = cli.Command{
 	Usage:     "Bumps the fee of a channel closing transaction.",
 	ArgsUsage: "channel_point",
 	Description: `
-	This command allows the fee of a channel closing transaction to be
-	increased by using the child-pays-for-parent mechanism. It will instruct
-	the sweeper to sweep the anchor outputs of transactions in the set
-	of valid commitments for the specified channel at the requested fee
-	rate or confirmation target.
+	This command allows the fee of a channel closing transaction, or of
+	the transactions resolving a force closed channel, to be increased.
+
+	If the closing transaction of the channel is still unconfirmed, the
+	child-pays-for-parent mechanism is used: the sweeper is instructed to
+	sweep the anchor outputs of transactions in the set of valid
+	commitments for the specified channel at the requested fee rate or
+	confirmation target.
+
+	If the commitment transaction of a force closed channel has already
+	confirmed, the sweeper is instead instructed to rebuild the pending
+	sweeps of the channel's outputs at the requested fee rate, replacing
+	the lower fee transactions through the Replace-By-Fee (RBF) policy.
+	This includes the HTLC outputs on the commitment transaction. For
+	anchor channels, the second-level HTLC transactions are aggregated by
+	the sweeper as well, so their fee is bumped as part of the sweep of
+	the corresponding commitment output. Once a second-level HTLC
+	transaction has confirmed, only the sweep of its output, which becomes
+	possible after the CSV delay has expired, can be bumped. Outputs that
+	aren't yet eligible to be swept have no pending sweep and can't be
+	bumped until they are. Anchor sweeps are left alone at this point, as
+	the anchors no longer help to confirm the commitment.
+
+	The command fails if none of the channel's outputs have a pending
+	sweep, for example because they're already confirmed.
 	`,
 	Flags: []cli.Flag{
 		cli.Uint64Flag{
@@ -318,12 +338,12 @@ func bumpCloseFee(ctx *cli.Context) error {
 		return err
 	}
 
-	// Fetch all waiting close channels.
+	// Fetch all pending close channels.
 	client, cleanUp := getClient(ctx)
 	defer cleanUp()
 
-	// Fetch waiting close channel commitments.
-	commitments, err := getWaitingCloseCommitments(
+	// Fetch the transactions of the channel whose outputs may be swept.
+	closeTxids, anchorsOnly, err := getCloseTxids(
 		ctxc, client, channelPoint,
 	)
 	if err != nil {
@@ -341,19 +361,17 @@ func bumpCloseFee(ctx *cli.Context) error {
 		return err
 	}
 
-	// Match pending sweeps with commitments of the channel for which a bump
-	// is requested and bump their fees.
-	commitSet := map[string]struct{}{
-		commitments.LocalTxid:  {},
-		commitments.RemoteTxid: {},
-	}
-	if commitments.RemotePendingTxid != "" {
-		commitSet[commitments.RemotePendingTxid] = struct{}{}
-	}
-
+	// Match pending sweeps with the transactions of the channel for which
+	// a bump is requested and bump their fees.
+	var numBumped int
 	for _, sweep := range sweeps.PendingSweeps {
-		// Only bump anchor sweeps.
-		if sweep.WitnessType != walletrpc.WitnessType_COMMITMENT_ANCHOR {
+		// Only bump anchor sweeps if the closing transaction is still
+		// unconfirmed. Once it confirmed, the anchors no longer help
+		// to confirm it, so their negatively yielding sweeps must not
+		// be forced.
+		isAnchor := sweep.WitnessType ==
+			walletrpc.WitnessType_COMMITMENT_ANCHOR
+		if isAnchor != anchorsOnly {
 			continue
 		}
 
@@ -362,11 +380,12 @@ func bumpCloseFee(ctx *cli.Context) error {
 		if err != nil {
 			return err
 		}
-		if _, match := commitSet[sweepTxID.String()]; !match {
+		if _, match := closeTxids[sweepTxID.String()]; !match {
 			continue
 		}
 
-		// Bump fee of the anchor sweep.
+		// Bump fee of the sweep. Anchor outputs are negatively
+		// yielding, so their sweep needs to be forced.
 		fmt.Printf("Bumping fee of %v:%v\n",
 			sweepTxID, sweep.Outpoint.OutputIndex)
 
@@ -374,34 +393,81 @@ func bumpCloseFee(ctx *cli.Context) error {
 			Outpoint:    sweep.Outpoint,
 			TargetConf:  uint32(ctx.Uint64("conf_target")),
 			SatPerVbyte: ctx.Uint64(feeRateFlag),
-			Force:       true,
+			Force:       isAnchor,
 		})
 		if err != nil {
 			return err
 		}
+		numBumped++
+	}
+
+	if numBumped == 0 {
+		return fmt.Errorf("no pending sweeps found for channel %v, "+
+			"its outputs are either already confirmed or not yet "+
+			"eligible to be swept", channelPoint)
 	}
 
 	return nil
 }
 
-func getWaitingCloseCommitments(ctxc context.Context,
-	client lnrpc.LightningClient, channelPoint string) (
-	*lnrpc.PendingChannelsResponse_Commitments, error) {
+// getCloseTxids returns the set of transaction ids of the closing
+// transactions of the given channel whose outputs may be swept. If the
+// closing transaction is still unconfirmed, the txids of all valid
+// commitments are returned, and only their anchors should be swept, which is
+// indicated by the returned boolean.
+func getCloseTxids(ctxc context.Context, client lnrpc.LightningClient,
+	channelPoint string) (map[string]struct{}, bool, error) {
 
 	req := &lnrpc.PendingChannelsRequest{}
 	resp, err := client.PendingChannels(ctxc, req)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	// Lookup the channel commit tx hashes.
+	// If the channel is waiting for its closing transaction to confirm,
+	// we'll look up the commit tx hashes.
 	for _, channel := range resp.WaitingCloseChannels {
-		if channel.Channel.ChannelPoint == channelPoint {
-			return channel.Commitments, nil
+		if channel.Channel.ChannelPoint != channelPoint {
+			continue
+		}
+
+		commitments := channel.Commitments
+		txids := map[string]struct{}{
+			commitments.LocalTxid:  {},
+			commitments.RemoteTxid: {},
 		}
+		if commitments.RemotePendingTxid != "" {
+			txids[commitments.RemotePendingTxid] = struct{}{}
+		}
+
+		return txids, true, nil
+	}
+
+	// Otherwise, the commitment may have confirmed already, in which case
+	// the commitment outputs and any second-level HTLC outputs are still
+	// being resolved.
+	for _, channel := range resp.PendingForceClosingChannels {
+		if channel.Channel.ChannelPoint != channelPoint {
+			continue
+		}
+
+		txids := map[string]struct{}{
+			channel.ClosingTxid: {},
+		}
+		for _, htlc := range channel.PendingHtlcs {
+			op, err := NewProtoOutPoint(htlc.Outpoint)
+			if err != nil {
+				return nil, false, err
+			}
+			txids[op.TxidStr] = struct{}{}
+		}
+
+		return txids, false, nil
 	}
 
-	return nil, errors.New("channel not found")
+	return nil, false, fmt.Errorf("channel %v not found among pending "+
+		"closing channels, its closing transaction may already be "+
+		"confirmed and fully resolved", channelPoint)
 }
 
 var listSweepsCommand = cli.Command{
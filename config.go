@@ -40,6 +40,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc/signrpc"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/macaroons"
 	"github.com/lightningnetwork/lnd/routing"
 	"github.com/lightningnetwork/lnd/signal"
 	"github.com/lightningnetwork/lnd/sweep"
@@ -106,6 +107,11 @@ const (
 	// out and return false if it hasn't yet received a response.
 	defaultAcceptorTimeout = 15 * time.Second
 
+	// defaultForceShutdownTimeout is the grace period given to the daemon
+	// to shut down after a forced StopDaemon request, before the process
+	// is terminated.
+	defaultForceShutdownTimeout = 5 * time.Second
+
 	defaultAlias = ""
 	defaultColor = "#3399FF"
 
@@ -308,6 +314,11 @@ type Config struct {
 	MaxLogFileSize  int           `long:"maxlogfilesize" description:"Maximum logfile size in MB"`
 	AcceptorTimeout time.Duration `long:"acceptortimeout" description:"Time after which an RPCAcceptor will time out and return false if it hasn't yet received a response"`
 
+	MacaroonNonceExpiry time.Duration `long:"macaroonnonceexpiry" description:"The duration for which the nonce of a macaroon is bound to the host that first presented it. Macaroons carrying a nonce must expire within this duration. Valid time units are {ms, s, m, h}."`
+	MaxMacaroonNonces   int           `long:"maxmacaroonnonces" description:"The maximum number of macaroon nonces remembered per host. Once a host exceeds it, its oldest nonce is forgotten."`
+
+	ForceShutdownTimeout time.Duration `long:"force-shutdown-timeout" description:"The maximum time to wait for a graceful shutdown to complete after a forced StopDaemon request before the process is terminated. Valid time units are {ms, s, m, h}."`
+
 	LetsEncryptDir    string `long:"letsencryptdir" description:"The directory to store Let's Encrypt certificates within"`
 	LetsEncryptListen string `long:"letsencryptlisten" description:"The IP:port on which lnd will listen for Let's Encrypt challenges. Let's Encrypt will always try to contact on port 80. Often non-root processes are not allowed to bind to ports lower than 1024. This configuration option allows a different port to be used, but must be used in combination with port forwarding from port 80. This configuration can also be used to specify another IP address to listen on, for example an IPv6 address."`
 	LetsEncryptDomain string `long:"letsencryptdomain" description:"Request a Let's Encrypt certificate for this domain. Note that the certificate is only requested and stored when the first rpc connection comes in."`
@@ -348,6 +359,7 @@ type Config struct {
 
 	UnsafeDisconnect   bool   `long:"unsafe-disconnect" description:"DEPRECATED: Allows the rpcserver to intentionally disconnect from peers with open channels. THIS FLAG WILL BE REMOVED IN 0.10.0"`
 	UnsafeReplay       bool   `long:"unsafe-replay" description:"Causes a link to replay the adds on its commitment txn after starting up, this enables testing of the sphinx replay logic."`
+	VerifyHtlcSigs     bool   `long:"verify-htlc-sigs" description:"Verify the stored HTLC signatures of each channel's local commitment when the channel is loaded, and don't start any channel whose signatures fail to verify. This allows detecting corrupted channel state early at the cost of a slower startup."`
 	MaxPendingChannels int    `long:"maxpendingchannels" description:"The maximum number of incoming pending channels permitted per peer."`
 	BackupFilePath     string `long:"backupfilepath" description:"The target location of the channel backup file"`
 
@@ -409,6 +421,8 @@ type Config struct {
 
 	RejectPush bool `long:"rejectpush" description:"If true, lnd will not accept channel opening requests with non-zero push amounts. This should prevent accidental pushes to merchant nodes."`
 
+	ClampReserveToDust bool `long:"clampreservetodust" description:"If true, a channel reserve below the dust limit is raised to the dust limit instead of rejecting the channel as mandated by BOLT #2."`
+
 	RejectHTLC bool `long:"rejecthtlc" description:"If true, lnd will not forward any HTLCs that are meant as onward payments. This option will still allow lnd to send HTLCs and receive HTLCs but lnd won't be used as a hop."`
 
 	// RequireInterceptor determines whether the HTLC interceptor is
@@ -550,6 +564,11 @@ func DefaultConfig() Config {
 		AcceptorTimeout:   defaultAcceptorTimeout,
 		WSPingInterval:    lnrpc.DefaultPingInterval,
 		WSPongWait:        lnrpc.DefaultPongWait,
+
+		MacaroonNonceExpiry: macaroons.DefaultNonceExpiry,
+		MaxMacaroonNonces:   macaroons.DefaultMaxNonces,
+
+		ForceShutdownTimeout: defaultForceShutdownTimeout,
 		Bitcoin: &lncfg.Chain{
 			MinHTLCIn:     chainreg.DefaultBitcoinMinHTLCInMSat,
 			MinHTLCOut:    chainreg.DefaultBitcoinMinHTLCOutMSat,
@@ -619,9 +638,10 @@ func DefaultConfig() Config {
 		},
 		net: &tor.ClearNet{},
 		Workers: &lncfg.Workers{
-			Read:  lncfg.DefaultReadWorkers,
-			Write: lncfg.DefaultWriteWorkers,
-			Sig:   lncfg.DefaultSigWorkers,
+			Read:            lncfg.DefaultReadWorkers,
+			Write:           lncfg.DefaultWriteWorkers,
+			Sig:             lncfg.DefaultSigWorkers,
+			SigDrainTimeout: lnwallet.DefaultSigPoolDrainTimeout,
 		},
 		Caches: &lncfg.Caches{
 			RejectCacheSize:  channeldb.DefaultRejectCacheSize,
@@ -1563,6 +1583,19 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		return nil, mkErr("maxbackoff must be greater than minbackoff")
 	}
 
+	if cfg.MacaroonNonceExpiry <= 0 {
+		return nil, mkErr("macaroonnonceexpiry must be positive")
+	}
+	if cfg.MaxMacaroonNonces <= 0 {
+		return nil, mkErr("maxmacaroonnonces must be positive")
+	}
+
+	// A forced shutdown must give the daemon at least some time to shut
+	// down gracefully.
+	if cfg.ForceShutdownTimeout <= 0 {
+		return nil, mkErr("force-shutdown-timeout must be positive")
+	}
+
 	// Newer versions of lnd added a new sub-config for bolt-specific
 	// parameters. However, we want to also allow existing users to use the
 	// value on the top-level config. If the outer config value is set,
@@ -31,6 +31,7 @@ import (
 	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/chainreg"
 	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/clock"
 	"github.com/lightningnetwork/lnd/invoices"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/kvdb"
@@ -405,6 +406,11 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 		macaroonService, err = macaroons.NewService(
 			rootKeyStore, "lnd", walletInitParams.StatelessInit,
 			macaroons.IPLockChecker,
+			macaroons.NonceChecker(
+				d.cfg.MacaroonNonceExpiry,
+				d.cfg.MaxMacaroonNonces,
+				clock.NewDefaultClock(),
+			),
 			macaroons.CustomChecker(interceptorChain),
 		)
 		if err != nil {
@@ -696,6 +702,10 @@ func (d *DefaultWalletImpl) BuildChainControl(
 		DefaultConstraints: partialChainControl.ChannelConstraints,
 		NetParams:          *walletConfig.NetParams,
 	}
+	if d.cfg.ClampReserveToDust {
+		lnWalletConfig.ReserveBelowDustPolicy =
+			lnwallet.ReserveBelowDustClamp
+	}
 
 	// The broadcast is already always active for neutrino nodes, so we
 	// don't want to create a rebroadcast loop.
@@ -811,6 +821,10 @@ func (d *RPCSignerWalletImpl) BuildChainControl(
 		DefaultConstraints: partialChainControl.ChannelConstraints,
 		NetParams:          *walletConfig.NetParams,
 	}
+	if d.cfg.ClampReserveToDust {
+		lnWalletConfig.ReserveBelowDustPolicy =
+			lnwallet.ReserveBelowDustClamp
+	}
 
 	// We've created the wallet configuration now, so we can finish
 	// initializing the main chain control.
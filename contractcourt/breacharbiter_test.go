@@ -2254,6 +2254,21 @@ func createInitChannels(t *testing.T, revocationWindow int) (
 		return nil, nil, err
 	}
 
+	// Encode the initial state number within both commitments, as it's
+	// validated once the channels are restored. Alice is the initiator.
+	obfuscator := lnwallet.DeriveStateHintObfuscator(
+		aliceCfg.PaymentBasePoint.PubKey,
+		bobCfg.PaymentBasePoint.PubKey,
+	)
+	err = lnwallet.SetStateNumHint(aliceCommitTx, 0, obfuscator)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = lnwallet.SetStateNumHint(bobCommitTx, 0, obfuscator)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	dbAlice, err := channeldb.Open(t.TempDir())
 	if err != nil {
 		return nil, nil, err
@@ -1199,5 +1199,27 @@ func (c *ChainArbitrator) SubscribeChannelEvents(
 	return watcher.SubscribeChannelEvents(), nil
 }
 
+// RegisterBreachHandler registers a handler that will be called synchronously
+// with the retribution of a contract breach detected for the target channel,
+// instead of handing it off through the ContractBreach closure of the config.
+// The error returned by the handler is treated as the breach arbiter's ACK,
+// so it must only be nil once the breach info has been preserved.
+func (c *ChainArbitrator) RegisterBreachHandler(chanPoint wire.OutPoint,
+	handler BreachHandler) error {
+
+	// First, we'll attempt to look up the active watcher for this channel.
+	// If we can't find it, then we'll return an error back to the caller.
+	c.Lock()
+	watcher, ok := c.activeWatchers[chanPoint]
+	c.Unlock()
+	if !ok {
+		return fmt.Errorf("unable to find watcher for: %v", chanPoint)
+	}
+
+	watcher.RegisterBreachHandler(handler)
+
+	return nil
+}
+
 // TODO(roasbeef): arbitration reports
 //  * types: contested, waiting for success conf, etc
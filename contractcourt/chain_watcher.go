@@ -224,8 +224,19 @@ type chainWatcher struct {
 	// clientSubscriptions is a map that keeps track of all the active
 	// client subscriptions for events related to this channel.
 	clientSubscriptions map[uint64]*ChainEventSubscription
+
+	// breachHandler is an optional handler that, if registered, is called
+	// with the retribution of a detected contract breach instead of
+	// handing it off through the contractBreach closure of the config.
+	breachHandler BreachHandler
 }
 
+// BreachHandler is a function that processes the retribution of a detected
+// contract breach synchronously. It should only return a nil error once the
+// necessary breach info has been preserved, as the channel will be marked as
+// closed afterwards.
+type BreachHandler func(*lnwallet.BreachRetribution) error
+
 // newChainWatcher returns a new instance of a chainWatcher for a channel given
 // the chan point to watch, and also a notifier instance that will allow us to
 // detect on chain events.
@@ -682,13 +693,10 @@ func (c *chainWatcher) closeObserver(spendNtfn *chainntnfs.SpendEvent) {
 		}
 
 		// Next, we'll check to see if this is a cooperative channel
-		// closure or not. This is characterized by having an input
-		// sequence number that's finalized. This won't happen with
-		// regular commitment transactions due to the state hint
-		// encoding scheme.
-		if commitTxBroadcast.TxIn[0].Sequence == wire.MaxTxInSequenceNum {
-			// TODO(roasbeef): rare but possible, need itest case
-			// for
+		// closure or not. This is characterized by the spending
+		// transaction lacking the state hint every commitment
+		// transaction carries in its sequence and lock time.
+		if isCoopCloseTx(commitTxBroadcast) {
 			err := c.dispatchCooperativeClose(commitSpend)
 			if err != nil {
 				log.Errorf("unable to handle co op close: %v", err)
@@ -743,6 +751,37 @@ func (c *chainWatcher) closeObserver(spendNtfn *chainntnfs.SpendEvent) {
 	}
 }
 
+// isCoopCloseTx returns true if the passed spend of the funding output is a
+// cooperative close transaction. Commitment transactions encode their state
+// hint within the sequence of their single input and their lock time, which
+// fixes the upper byte of the sequence to 0x80, disabling relative lock times,
+// and the upper byte of the lock time to 0x20, see lnwallet.SetStateNumHint.
+// A cooperative close carries no state hint: its input either has a final
+// sequence, or one signaling RBF, and it pays out to at most one output per
+// party.
+func isCoopCloseTx(tx *wire.MsgTx) bool {
+	if len(tx.TxIn) != 1 {
+		return false
+	}
+
+	// A final sequence can't be produced by the state hint encoding, so
+	// there's no need to look any further.
+	sequence := tx.TxIn[0].Sequence
+	if sequence == wire.MaxTxInSequenceNum {
+		return true
+	}
+
+	if len(tx.TxOut) == 0 || len(tx.TxOut) > 2 {
+		return false
+	}
+
+	const upperByteMask = 0xff000000
+	sequenceHint := sequence&upperByteMask == wire.SequenceLockTimeDisabled
+	lockTimeHint := tx.LockTime&upperByteMask == lnwallet.TimelockShift
+
+	return !(sequenceHint && lockTimeHint)
+}
+
 // handleKnownLocalState checks whether the passed spend is a local state that
 // is known to us (the current state). If so we will act on this state using
 // the passed chainSet. If this is not a known local state, false is returned.
@@ -1187,6 +1226,19 @@ func (c *chainWatcher) dispatchRemoteForceClose(
 	return nil
 }
 
+// RegisterBreachHandler registers a handler that will be called with the
+// retribution of a detected contract breach, replacing the hand off to the
+// breach arbiter. The error returned by the handler is treated as the
+// breach arbiter's ACK: only if it's nil will subscribers be notified of the
+// breach, allowing the channel to be marked as closed. Registering a nil
+// handler restores the default behavior.
+func (c *chainWatcher) RegisterBreachHandler(handler BreachHandler) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.breachHandler = handler
+}
+
 // dispatchContractBreach processes a detected contract breached by the remote
 // party. This method is to be called once we detect that the remote party has
 // broadcast a prior revoked commitment state. This method well prepare all the
@@ -1205,6 +1257,16 @@ func (c *chainWatcher) dispatchContractBreach(spendEvent *chainntnfs.SpendDetail
 		return fmt.Errorf("unable to mark channel as borked: %v", err)
 	}
 
+	// Failing to record the breach in the event log of the channel
+	// shouldn't keep us from punishing the remote party.
+	err := c.cfg.chanState.AppendChannelEvent(
+		channeldb.EventBreachDetected, broadcastStateNum,
+	)
+	if err != nil {
+		log.Errorf("Unable to record breach of ChannelPoint(%v): %v",
+			c.cfg.chanState.FundingOutpoint, err)
+	}
+
 	spendHeight := uint32(spendEvent.SpendingHeight)
 
 	log.Debugf("Punishment breach retribution created: %v",
@@ -1243,12 +1305,20 @@ func (c *chainWatcher) dispatchContractBreach(spendEvent *chainntnfs.SpendDetail
 		closeSummary.LastChanSyncMsg = chanSync
 	}
 
-	// Hand the retribution info over to the breach arbiter. This function
-	// will wait for a response from the breach arbiter and then proceed to
-	// send a BreachCloseInfo to the channel arbitrator. The channel arb
-	// will then mark the channel as closed after resolutions and the
-	// commit set are logged in the arbitrator log.
-	if err := c.cfg.contractBreach(retribution); err != nil {
+	// Hand the retribution info over to the breach arbiter, or to the
+	// registered breach handler if there is one. This function will wait
+	// for a response from either of them and then proceed to send a
+	// BreachCloseInfo to the channel arbitrator. The channel arb will then
+	// mark the channel as closed after resolutions and the commit set are
+	// logged in the arbitrator log.
+	c.Lock()
+	handleBreach := c.breachHandler
+	c.Unlock()
+	if handleBreach == nil {
+		handleBreach = c.cfg.contractBreach
+	}
+
+	if err := handleBreach(retribution); err != nil {
 		log.Errorf("unable to hand breached contract off to "+
 			"breachArbiter: %v", err)
 		return err
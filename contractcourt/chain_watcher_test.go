@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/channeldb"
@@ -599,3 +600,217 @@ func TestChainWatcherLocalForceCloseDetect(t *testing.T) {
 		})
 	}
 }
+
+// TestChainWatcherBreachHandler tests that a registered breach handler is
+// called with the retribution of a detected breach instead of the
+// contractBreach closure, and that its returned error is treated as the ACK
+// of the breach.
+func TestChainWatcherBreachHandler(t *testing.T) {
+	t.Parallel()
+
+	// First, we'll create two channels which already have established a
+	// commitment contract between themselves.
+	aliceChannel, bobChannel, err := lnwallet.CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We'll save Bob's current commitment, and advance the state such
+	// that it's revoked.
+	bobRevokedCommit := bobChannel.State().LocalCommitment.CommitTx
+	err = lnwallet.ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err, "unable to complete state transition")
+
+	// With the channels created, we'll now create a chain watcher instance
+	// which will be watching for any closes of Alice's channel. As we'll
+	// register a breach handler, the contractBreach closure should never
+	// be called.
+	aliceNotifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	aliceChainWatcher, err := newChainWatcher(chainWatcherConfig{
+		chanState: aliceChannel.State(),
+		notifier:  aliceNotifier,
+		signer:    aliceChannel.Signer,
+		contractBreach: func(*lnwallet.BreachRetribution) error {
+			t.Errorf("contractBreach called despite breach " +
+				"handler")
+
+			return nil
+		},
+		extractStateNumHint: lnwallet.GetStateNumHint,
+	})
+	require.NoError(t, err, "unable to create chain watcher")
+
+	retributions := make(chan *lnwallet.BreachRetribution, 1)
+	aliceChainWatcher.RegisterBreachHandler(
+		func(retribution *lnwallet.BreachRetribution) error {
+			retributions <- retribution
+			return nil
+		},
+	)
+
+	err = aliceChainWatcher.Start()
+	require.NoError(t, err, "unable to start chain watcher")
+	defer aliceChainWatcher.Stop()
+
+	chanEvents := aliceChainWatcher.SubscribeChannelEvents()
+
+	// We'll now simulate Bob broadcasting his revoked commitment.
+	bobTxHash := bobRevokedCommit.TxHash()
+	aliceNotifier.SpendChan <- &chainntnfs.SpendDetail{
+		SpenderTxHash: &bobTxHash,
+		SpendingTx:    bobRevokedCommit,
+	}
+
+	// The breach handler should be called with the retribution for the
+	// revoked state.
+	select {
+	case retribution := <-retributions:
+		require.Equal(t, bobTxHash, retribution.BreachTxHash)
+		require.Zero(t, retribution.RevokedStateNum)
+
+	case <-time.After(time.Second * 15):
+		t.Fatalf("breach handler not called")
+	}
+
+	// As the handler ACK'd the breach, subscribers should be notified.
+	select {
+	case breachInfo := <-chanEvents.ContractBreach:
+		require.Equal(t, bobTxHash, breachInfo.CommitHash)
+
+	case <-time.After(time.Second * 15):
+		t.Fatalf("didn't receive contract breach event")
+	}
+}
+
+// TestIsCoopCloseTx asserts that cooperative close transactions are told
+// apart from commitment transactions by the absence of a state hint.
+func TestIsCoopCloseTx(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, _, err := lnwallet.CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	fundingTxIn := wire.TxIn{
+		PreviousOutPoint: aliceChannel.State().FundingOutpoint,
+	}
+	newCoopCloseTx := func(opts ...lnwallet.CloseTxOpt) *wire.MsgTx {
+		return lnwallet.CreateCooperativeCloseTx(
+			fundingTxIn, 0, 0, 1000, 2000, testCoopCloseScript(1),
+			testCoopCloseScript(2), opts...,
+		)
+	}
+
+	testCases := []struct {
+		name   string
+		tx     *wire.MsgTx
+		isCoop bool
+	}{
+		{
+			name:   "commitment",
+			tx:     aliceChannel.State().LocalCommitment.CommitTx,
+			isCoop: false,
+		},
+		{
+			name:   "coop close with final sequence",
+			tx:     newCoopCloseTx(),
+			isCoop: true,
+		},
+		{
+			name:   "coop close signaling rbf",
+			tx:     newCoopCloseTx(lnwallet.WithRBFCloseTx()),
+			isCoop: true,
+		},
+		{
+			name: "multiple inputs",
+			tx: &wire.MsgTx{
+				TxIn:  []*wire.TxIn{&fundingTxIn, &fundingTxIn},
+				TxOut: newCoopCloseTx().TxOut,
+			},
+			isCoop: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.isCoop, isCoopCloseTx(tc.tx))
+		})
+	}
+}
+
+// TestChainWatcherCoopCloseDetect tests that the chain watcher classifies a
+// cooperative close signaling RBF as such, rather than as an unknown
+// commitment of the remote party.
+func TestChainWatcherCoopCloseDetect(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, _, err := lnwallet.CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	aliceScript := testCoopCloseScript(1)
+	aliceNotifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	aliceChainWatcher, err := newChainWatcher(chainWatcherConfig{
+		chanState:           aliceChannel.State(),
+		notifier:            aliceNotifier,
+		signer:              aliceChannel.Signer,
+		extractStateNumHint: lnwallet.GetStateNumHint,
+		isOurAddr: func(addr btcutil.Address) bool {
+			script := addr.ScriptAddress()
+			return bytes.Equal(script, aliceScript[2:])
+		},
+	})
+	require.NoError(t, err, "unable to create chain watcher")
+	require.NoError(t, aliceChainWatcher.Start())
+	defer aliceChainWatcher.Stop()
+
+	chanEvents := aliceChainWatcher.SubscribeChannelEvents()
+
+	// Broadcast a cooperative close which signals RBF, which pays Alice
+	// 1000 sat.
+	closeTx := lnwallet.CreateCooperativeCloseTx(
+		wire.TxIn{
+			PreviousOutPoint: aliceChannel.State().FundingOutpoint,
+		},
+		0, 0, 1000, 2000, aliceScript, testCoopCloseScript(2),
+		lnwallet.WithRBFCloseTx(),
+	)
+	closeTxHash := closeTx.TxHash()
+	aliceNotifier.SpendChan <- &chainntnfs.SpendDetail{
+		SpenderTxHash: &closeTxHash,
+		SpendingTx:    closeTx,
+	}
+
+	select {
+	case closeInfo := <-chanEvents.CooperativeClosure:
+		require.Equal(
+			t, channeldb.CooperativeClose, closeInfo.CloseType,
+		)
+		require.Equal(t, closeTxHash, closeInfo.ClosingTXID)
+		require.EqualValues(t, 1000, closeInfo.SettledBalance)
+
+	case <-chanEvents.RemoteUnilateralClosure:
+		t.Fatalf("coop close classified as unilateral close")
+
+	case <-time.After(time.Second * 15):
+		t.Fatalf("didn't receive cooperative close event")
+	}
+}
+
+// testCoopCloseScript returns a P2WPKH delivery script for a cooperative close
+// whose key hash is derived from the passed byte.
+func testCoopCloseScript(b byte) []byte {
+	return append([]byte{0x00, 0x14}, bytes.Repeat([]byte{b}, 20)...)
+}
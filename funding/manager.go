@@ -4690,6 +4690,7 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 	}
 	err = lnwallet.VerifyConstraints(
 		channelConstraints, resCtx.maxLocalCsv, capacity,
+		f.cfg.Wallet.Cfg.ReserveBelowDustPolicy,
 	)
 	if err != nil {
 		_, reserveErr := f.cancelReservationCtx(peerKey, chanID, false)
@@ -4702,6 +4703,11 @@ func (f *Manager) handleInitFundingMsg(msg *InitFundingMsg) {
 		return
 	}
 
+	// The reserve we require from the remote party may have been clamped
+	// to our dust limit.
+	chanReserve = channelConstraints.ChanReserve
+	resCtx.remoteChanReserve = chanReserve
+
 	// When opening a script enforced channel lease, include the required
 	// expiry TLV record in our proposal.
 	var leaseExpiry *lnwire.LeaseExpiry
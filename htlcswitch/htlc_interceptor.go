@@ -0,0 +1,325 @@
+package htlcswitch
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/htlcswitch/hop"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// ErrHtlcNotParked is returned when resuming or canceling an HTLC that isn't
+// held by the HTLC interceptor of the link.
+var ErrHtlcNotParked = errors.New("htlc not parked")
+
+// HtlcInterceptAction is the decision of an HtlcInterceptor about an incoming
+// HTLC.
+type HtlcInterceptAction uint8
+
+const (
+	// HtlcInterceptAccept accepts the HTLC, which is forwarded as usual.
+	HtlcInterceptAccept HtlcInterceptAction = iota
+
+	// HtlcInterceptHold parks the HTLC in the link until it's either
+	// resumed with ResumeParkedHtlc, or canceled with CancelParkedHtlc, or
+	// about to expire.
+	HtlcInterceptHold
+
+	// HtlcInterceptReject fails the HTLC back with the failure code
+	// returned by the interceptor.
+	HtlcInterceptReject
+)
+
+// String returns a human-readable name of the action.
+func (a HtlcInterceptAction) String() string {
+	switch a {
+	case HtlcInterceptAccept:
+		return "accept"
+
+	case HtlcInterceptHold:
+		return "hold"
+
+	case HtlcInterceptReject:
+		return "reject"
+
+	default:
+		return fmt.Sprintf("unknown action %d", uint8(a))
+	}
+}
+
+// HtlcInterceptor is a callback invoked by the link with every incoming HTLC
+// to be forwarded, once it's locked in and passed the validation of the link,
+// but before it's handed over to the switch. It decides whether the HTLC is
+// forwarded, held or rejected, which allows implementing routing policies
+// such as rate limiting. When rejecting an HTLC, it also returns the failure
+// code the HTLC is failed back with, or zero to fail it with
+// temporary_channel_failure. The failure code is ignored for the other
+// actions.
+//
+// The decision is persisted in the forwarding package of the HTLC, so the
+// interceptor isn't consulted again when the package is reprocessed after a
+// restart: a held HTLC stays parked, and a rejected one is failed back again
+// if the fail wasn't signed yet. A held HTLC is failed back with
+// expiry_too_soon once its incoming expiry is within the link's
+// InterceptRejectDelta of the best height.
+//
+// NOTE: The interceptor is called from the goroutine processing the updates of
+// the link, so it must not block.
+type HtlcInterceptor func(packet InterceptedPacket) (HtlcInterceptAction,
+	lnwire.FailCode)
+
+// parkedHtlc is an incoming HTLC held by the HTLC interceptor of a link.
+type parkedHtlc struct {
+	pd         *lnwallet.PaymentDescriptor
+	obfuscator hop.ErrorEncrypter
+
+	// packet is the packet handed over to the switch once the HTLC is
+	// resumed.
+	packet *htlcPacket
+}
+
+// parkedHtlcReq is a request to resume or cancel a parked HTLC, served by the
+// goroutine processing the updates of the link.
+type parkedHtlcReq struct {
+	htlcIndex uint64
+
+	// resume is true if the HTLC is forwarded, otherwise it's failed back
+	// with failCode.
+	resume   bool
+	failCode lnwire.FailCode
+
+	err chan error
+}
+
+// interceptedPacket returns the details of the packet handed over to the HTLC
+// interceptor.
+func interceptedPacket(packet *htlcPacket) InterceptedPacket {
+	htlc := packet.htlc.(*lnwire.UpdateAddHTLC)
+
+	return InterceptedPacket{
+		IncomingCircuit: models.CircuitKey{
+			ChanID: packet.incomingChanID,
+			HtlcID: packet.incomingHTLCID,
+		},
+		OutgoingChanID: packet.outgoingChanID,
+		Hash:           htlc.PaymentHash,
+		OutgoingExpiry: htlc.Expiry,
+		OutgoingAmount: htlc.Amount,
+		IncomingAmount: packet.incomingAmount,
+		IncomingExpiry: packet.incomingTimeout,
+		CustomRecords:  packet.customRecords,
+		OnionBlob:      htlc.OnionBlob,
+	}
+}
+
+// interceptHtlc lets the HTLC interceptor decide about the passed packet, and
+// persists the decision in the forwarding package at the given height if the
+// HTLC isn't accepted. A nil intercept is returned for accepted HTLCs.
+func (l *channelLink) interceptHtlc(height uint64, index uint16,
+	packet *htlcPacket) (*channeldb.HtlcIntercept, error) {
+
+	if l.cfg.HtlcInterceptor == nil {
+		return nil, nil
+	}
+
+	var intercept *channeldb.HtlcIntercept
+
+	action, failCode := l.cfg.HtlcInterceptor(interceptedPacket(packet))
+	switch action {
+	case HtlcInterceptAccept:
+		return nil, nil
+
+	case HtlcInterceptHold:
+		intercept = &channeldb.HtlcIntercept{
+			State: channeldb.HtlcInterceptHeld,
+		}
+
+	case HtlcInterceptReject:
+		if failCode == 0 {
+			failCode = lnwire.CodeTemporaryChannelFailure
+		}
+
+		intercept = &channeldb.HtlcIntercept{
+			State:    channeldb.HtlcInterceptFailed,
+			FailCode: failCode,
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown intercept action: %v", action)
+	}
+
+	l.log.Debugf("Intercepted HTLC %v: %v", packet.incomingHTLCID, action)
+
+	err := l.channel.SetHtlcIntercept(height, index, intercept)
+	if err != nil {
+		return nil, err
+	}
+
+	return intercept, nil
+}
+
+// parkHtlc holds the passed HTLC until it's resumed or canceled.
+func (l *channelLink) parkHtlc(pd *lnwallet.PaymentDescriptor,
+	obfuscator hop.ErrorEncrypter, packet *htlcPacket) {
+
+	l.parkedMtx.Lock()
+	defer l.parkedMtx.Unlock()
+
+	l.parkedHtlcs[pd.HtlcIndex] = &parkedHtlc{
+		pd:         pd,
+		obfuscator: obfuscator,
+		packet:     packet,
+	}
+}
+
+// failExpiringParkedHtlcs fails back the parked HTLCs whose incoming expiry is
+// within InterceptRejectDelta blocks of the given height with
+// expiry_too_soon, to prevent the upstream peer from force closing the channel
+// over an HTLC that is held for too long. The fails are persisted like a
+// cancel, and signed right away. False is returned if the link failed.
+func (l *channelLink) failExpiringParkedHtlcs(height uint32) bool {
+	rejectHeight := height + l.cfg.InterceptRejectDelta
+
+	var expiring []uint64
+	l.parkedMtx.RLock()
+	for htlcIndex, parked := range l.parkedHtlcs {
+		if parked.packet.incomingTimeout <= rejectHeight {
+			expiring = append(expiring, htlcIndex)
+		}
+	}
+	l.parkedMtx.RUnlock()
+
+	if len(expiring) == 0 {
+		return true
+	}
+
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i] < expiring[j]
+	})
+
+	for _, htlcIndex := range expiring {
+		l.log.Infof("Parked HTLC %v expires within %v blocks of "+
+			"height %v, failing it back", htlcIndex,
+			l.cfg.InterceptRejectDelta, height)
+
+		err := l.resolveParkedHtlc(&parkedHtlcReq{
+			htlcIndex: htlcIndex,
+			failCode:  lnwire.CodeExpiryTooSoon,
+		})
+		if err != nil {
+			l.fail(LinkFailureError{code: ErrInternalError},
+				"unable to fail expiring htlc: %v", err)
+
+			return false
+		}
+	}
+
+	return l.updateCommitTxOrFail()
+}
+
+// resolveParkedHtlc forwards or fails back the parked HTLC targeted by the
+// request. The outcome is persisted before acting on it, so the HTLC is
+// handled the same way if the forwarding package is reprocessed.
+func (l *channelLink) resolveParkedHtlc(req *parkedHtlcReq) error {
+	l.parkedMtx.RLock()
+	parked, ok := l.parkedHtlcs[req.htlcIndex]
+	l.parkedMtx.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrHtlcNotParked, req.htlcIndex)
+	}
+
+	intercept := &channeldb.HtlcIntercept{
+		State: channeldb.HtlcInterceptResumed,
+	}
+	if !req.resume {
+		failCode := req.failCode
+		if failCode == 0 {
+			failCode = lnwire.CodeTemporaryChannelFailure
+		}
+
+		intercept = &channeldb.HtlcIntercept{
+			State:    channeldb.HtlcInterceptFailed,
+			FailCode: failCode,
+		}
+	}
+
+	sourceRef := parked.pd.SourceRef
+	err := l.channel.SetHtlcIntercept(
+		sourceRef.Height, sourceRef.Index, intercept,
+	)
+	if err != nil {
+		return err
+	}
+
+	l.parkedMtx.Lock()
+	delete(l.parkedHtlcs, req.htlcIndex)
+	l.parkedMtx.Unlock()
+
+	if req.resume {
+		l.log.Debugf("Resuming parked HTLC %v", req.htlcIndex)
+
+		l.forwardBatch(false, parked.packet)
+
+		return nil
+	}
+
+	l.log.Debugf("Canceling parked HTLC %v: %v", req.htlcIndex,
+		intercept.FailCode)
+
+	l.failInterceptedHtlc(parked.pd, parked.obfuscator, intercept.FailCode)
+
+	return nil
+}
+
+// failInterceptedHtlc fails back an HTLC rejected or canceled by the HTLC
+// interceptor with the given failure code. Failure codes with the BADONION
+// flag set are sent in update_fail_malformed_htlc, all others in
+// update_fail_htlc.
+func (l *channelLink) failInterceptedHtlc(pd *lnwallet.PaymentDescriptor,
+	obfuscator hop.ErrorEncrypter, failCode lnwire.FailCode) {
+
+	if failCode&lnwire.FlagBadOnion != 0 {
+		l.sendMalformedHTLCError(
+			pd.HtlcIndex, failCode, pd.OnionBlob, pd.SourceRef,
+		)
+
+		return
+	}
+
+	var failure lnwire.FailureMessage
+	switch failCode {
+	case lnwire.CodeExpiryTooSoon:
+		failure = l.createFailureWithUpdate(true, l.ShortChanID(),
+			func(upd *lnwire.ChannelUpdate) lnwire.FailureMessage {
+				return lnwire.NewExpiryTooSoon(*upd)
+			},
+		)
+
+	case lnwire.CodeTemporaryNodeFailure:
+		failure = &lnwire.FailTemporaryNodeFailure{}
+
+	case lnwire.CodePermanentChannelFailure:
+		failure = &lnwire.FailPermanentChannelFailure{}
+
+	// Any other failure code is reported as a temporary channel failure,
+	// as the rejection is specific to this channel.
+	default:
+		if failCode != lnwire.CodeTemporaryChannelFailure {
+			l.log.Debugf("Reporting failure %v of HTLC %v as %v",
+				failCode, pd.HtlcIndex,
+				lnwire.CodeTemporaryChannelFailure)
+		}
+
+		failure = l.createFailureWithUpdate(true, l.ShortChanID(),
+			func(upd *lnwire.ChannelUpdate) lnwire.FailureMessage {
+				return lnwire.NewTemporaryChannelFailure(upd)
+			},
+		)
+	}
+
+	l.sendHTLCError(pd, NewLinkError(failure), obfuscator, false)
+}
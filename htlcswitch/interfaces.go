@@ -138,6 +138,17 @@ type ChannelUpdateHandler interface {
 	// clean. This can be used with dynamic commitment negotiation or coop
 	// close negotiation which require a clean channel state.
 	ShutdownIfChannelClean() error
+
+	// ParkedHtlcs returns the indexes of the incoming HTLCs held by the
+	// HTLC interceptor of the link.
+	ParkedHtlcs() []uint64
+
+	// ResumeParkedHtlc forwards the held HTLC with the given index.
+	ResumeParkedHtlc(htlcIndex uint64) error
+
+	// CancelParkedHtlc fails back the held HTLC with the given index with
+	// the passed failure code.
+	CancelParkedHtlc(htlcIndex uint64, failCode lnwire.FailCode) error
 }
 
 // ChannelLink is an interface which represents the subsystem for managing the
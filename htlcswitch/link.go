@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	prand "math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-errors/errors"
 	"github.com/lightningnetwork/lnd/build"
+	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
 	"github.com/lightningnetwork/lnd/contractcourt"
@@ -271,6 +273,21 @@ type ChannelLinkConfig struct {
 	// GetAliases is used by the link and switch to fetch the set of
 	// aliases for a given link.
 	GetAliases func(base lnwire.ShortChannelID) []lnwire.ShortChannelID
+
+	// HtlcInterceptor, if set, decides whether every incoming HTLC to be
+	// forwarded is handed over to the switch, held or rejected.
+	HtlcInterceptor HtlcInterceptor
+
+	// InterceptRejectDelta defines the number of blocks before the
+	// expiry of an incoming HTLC held by the HTLC interceptor at which we
+	// fail it back, to prevent the upstream peer from force closing the
+	// channel.
+	InterceptRejectDelta uint32
+
+	// ChainNotifier is used to receive the new blocks the parked HTLCs
+	// are checked against for their expiry. It's only used if an
+	// HtlcInterceptor is set.
+	ChainNotifier chainntnfs.ChainNotifier
 }
 
 // shutdownReq contains an error channel that will be used by the channelLink
@@ -344,6 +361,16 @@ type channelLink struct {
 	// service shutdown requests from ShutdownIfChannelClean calls.
 	shutdownRequest chan *shutdownReq
 
+	// parkedHtlcRequest is a channel that the channelLink will listen on
+	// to service ResumeParkedHtlc and CancelParkedHtlc calls.
+	parkedHtlcRequest chan *parkedHtlcReq
+
+	// parkedHtlcs are the incoming HTLCs held by the HTLC interceptor,
+	// indexed by their HTLC index. They're only added and removed by the
+	// htlcManager goroutine, parkedMtx guards reads by other callers.
+	parkedHtlcs map[uint64]*parkedHtlc
+	parkedMtx   sync.RWMutex
+
 	// updateFeeTimer is the timer responsible for updating the link's
 	// commitment fee every time it fires.
 	updateFeeTimer *time.Timer
@@ -384,16 +411,20 @@ func NewChannelLink(cfg ChannelLinkConfig,
 
 	logPrefix := fmt.Sprintf("ChannelLink(%v):", channel.ChannelPoint())
 
-	return &channelLink{
-		cfg:             cfg,
-		channel:         channel,
-		shortChanID:     channel.ShortChanID(),
-		shutdownRequest: make(chan *shutdownReq),
-		hodlMap:         make(map[models.CircuitKey]hodlHtlc),
-		hodlQueue:       queue.NewConcurrentQueue(10),
-		log:             build.NewPrefixLog(logPrefix, log),
-		quit:            make(chan struct{}),
+	l := &channelLink{
+		cfg:               cfg,
+		channel:           channel,
+		shortChanID:       channel.ShortChanID(),
+		shutdownRequest:   make(chan *shutdownReq),
+		parkedHtlcRequest: make(chan *parkedHtlcReq),
+		parkedHtlcs:       make(map[uint64]*parkedHtlc),
+		hodlMap:           make(map[models.CircuitKey]hodlHtlc),
+		hodlQueue:         queue.NewConcurrentQueue(10),
+		log:               build.NewPrefixLog(logPrefix, log),
+		quit:              make(chan struct{}),
 	}
+
+	return l
 }
 
 // A compile time check to ensure channelLink implements the ChannelLink
@@ -1108,6 +1139,23 @@ func (l *channelLink) htlcManager() {
 		go l.fwdPkgGarbager()
 	}
 
+	// If an HTLC interceptor is set, we watch new blocks to fail back the
+	// parked HTLCs before they expire. The current block is delivered
+	// right away, which covers the HTLCs parked again by resolveFwdPkgs.
+	var blockEpochs <-chan *chainntnfs.BlockEpoch
+	if l.cfg.HtlcInterceptor != nil {
+		blockEpochStream, err :=
+			l.cfg.ChainNotifier.RegisterBlockEpochNtfn(nil)
+		if err != nil {
+			l.fail(LinkFailureError{code: ErrInternalError},
+				"unable to register for block epochs: %v", err)
+			return
+		}
+		defer blockEpochStream.Cancel()
+
+		blockEpochs = blockEpochStream.Epochs
+	}
+
 	for {
 		// We must always check if we failed at some point processing
 		// the last update before processing the next.
@@ -1275,6 +1323,31 @@ func (l *channelLink) htlcManager() {
 			// an error and continue.
 			req.err <- ErrLinkFailedShutdown
 
+		case blockEpoch, ok := <-blockEpochs:
+			if !ok {
+				l.log.Warnf("Block epoch stream stopped, no " +
+					"longer watching parked HTLCs")
+				blockEpochs = nil
+				continue
+			}
+
+			height := uint32(blockEpoch.Height)
+			if !l.failExpiringParkedHtlcs(height) {
+				return
+			}
+
+		case req := <-l.parkedHtlcRequest:
+			err := l.resolveParkedHtlc(req)
+			req.err <- err
+
+			// A canceled HTLC was just failed back, so we sign the
+			// fail right away.
+			if err == nil && !req.resume {
+				if !l.updateCommitTxOrFail() {
+					return
+				}
+			}
+
 		case <-l.quit:
 			return
 		}
@@ -1915,6 +1988,21 @@ func (l *channelLink) handleUpstreamMsg(msg lnwire.Message) {
 			HtlcSigs:   msg.HtlcSigs,
 			PartialSig: msg.PartialSig,
 		})
+		if errors.Is(err, lnwallet.ErrSigPoolShuttingDown) {
+			// The signatures couldn't be checked as we're shutting
+			// down, so we just disconnect. The remote peer will
+			// retransmit the commitment once we reconnect.
+			l.fail(
+				LinkFailureError{
+					code:          ErrShuttingDown,
+					FailureAction: LinkFailureDisconnect,
+				},
+				"ChannelPoint(%v): unable to verify new "+
+					"commitment: %v",
+				l.channel.ChannelPoint(), err,
+			)
+			return
+		}
 		if err != nil {
 			// If we were unable to reconstruct their proposed
 			// commitment, then we'll examine the type of error. If
@@ -1926,6 +2014,8 @@ func (l *channelLink) handleUpstreamMsg(msg lnwire.Message) {
 				sendData = []byte(err.Error())
 			case *lnwallet.InvalidHtlcSigError:
 				sendData = []byte(err.Error())
+			case *lnwallet.ErrFeeMismatch:
+				sendData = []byte(err.Error())
 			}
 			l.fail(
 				LinkFailureError{
@@ -2087,6 +2177,13 @@ func (l *channelLink) handleUpstreamMsg(msg lnwire.Message) {
 			return
 		}
 
+		// The channel failed back the HTLCs with a rejected onion that
+		// were just locked in, so we send the fails to our peer before
+		// our next commitment covers them.
+		for _, fail := range l.channel.StagedFails() {
+			l.cfg.Peer.SendMessage(false, fail)
+		}
+
 		// The revocation window opened up. If there are pending local
 		// updates, try to update the commit tx. Pending updates could
 		// already have been present because of a previously failed
@@ -2777,6 +2874,69 @@ func (l *channelLink) ShutdownIfChannelClean() error {
 	}
 }
 
+// ParkedHtlcs returns the indexes of the incoming HTLCs that are currently
+// held by the HTLC interceptor, in ascending order.
+//
+// NOTE: Part of the ChannelUpdateHandler interface.
+func (l *channelLink) ParkedHtlcs() []uint64 {
+	l.parkedMtx.RLock()
+	defer l.parkedMtx.RUnlock()
+
+	htlcIndexes := make([]uint64, 0, len(l.parkedHtlcs))
+	for htlcIndex := range l.parkedHtlcs {
+		htlcIndexes = append(htlcIndexes, htlcIndex)
+	}
+	sort.Slice(htlcIndexes, func(i, j int) bool {
+		return htlcIndexes[i] < htlcIndexes[j]
+	})
+
+	return htlcIndexes
+}
+
+// ResumeParkedHtlc hands the held HTLC with the given index over to the
+// switch.
+//
+// NOTE: Part of the ChannelUpdateHandler interface.
+func (l *channelLink) ResumeParkedHtlc(htlcIndex uint64) error {
+	return l.requestParkedHtlc(&parkedHtlcReq{
+		htlcIndex: htlcIndex,
+		resume:    true,
+	})
+}
+
+// CancelParkedHtlc fails back the held HTLC with the given index with the
+// passed failure code, or temporary_channel_failure if it's zero, and signs
+// the fail with a new commitment.
+//
+// NOTE: Part of the ChannelUpdateHandler interface.
+func (l *channelLink) CancelParkedHtlc(htlcIndex uint64,
+	failCode lnwire.FailCode) error {
+
+	return l.requestParkedHtlc(&parkedHtlcReq{
+		htlcIndex: htlcIndex,
+		failCode:  failCode,
+	})
+}
+
+// requestParkedHtlc hands the passed request over to the htlcManager goroutine
+// and waits for it to be served.
+func (l *channelLink) requestParkedHtlc(req *parkedHtlcReq) error {
+	req.err = make(chan error, 1)
+
+	select {
+	case l.parkedHtlcRequest <- req:
+	case <-l.quit:
+		return ErrLinkShuttingDown
+	}
+
+	select {
+	case err := <-req.err:
+		return err
+	case <-l.quit:
+		return ErrLinkShuttingDown
+	}
+}
+
 // updateChannelFee updates the commitment fee-per-kw on this channel by
 // committing to an update_fee message.
 func (l *channelLink) updateChannelFee(feePerKw chainfee.SatPerKWeight) error {
@@ -2968,7 +3128,10 @@ func (l *channelLink) processRemoteAdds(fwdPkg *channeldb.FwdPkg,
 	var switchPackets []*htlcPacket
 
 	for i, pd := range lockedInHtlcs {
-		idx := uint16(i)
+		// The adds of the package aren't necessarily all handed over
+		// to us, e.g. if the channel rejected the onion of some of
+		// them, so we refer to each add by its index in the package.
+		idx := pd.SourceRef.Index
 
 		if fwdPkg.State == channeldb.FwdStateProcessed &&
 			fwdPkg.AckFilter.Contains(idx) {
@@ -3166,32 +3329,69 @@ func (l *channelLink) processRemoteAdds(fwdPkg *channeldb.FwdPkg,
 				continue
 			}
 
-			// Now that this add has been reprocessed, only append
-			// it to our list of packets to forward to the switch
-			// this is the first time processing the add. If the
-			// fwd pkg has already been processed, then we entered
-			// the above section to recreate a previous error.  If
-			// the packet had previously been forwarded, it would
-			// have been added to switchPackets at the top of this
-			// section.
-			if fwdPkg.State == channeldb.FwdStateLockedIn {
-				updatePacket := &htlcPacket{
-					incomingChanID:  l.ShortChanID(),
-					incomingHTLCID:  pd.HtlcIndex,
-					outgoingChanID:  fwdInfo.NextHop,
-					sourceRef:       pd.SourceRef,
-					incomingAmount:  pd.Amount,
-					amount:          addMsg.Amount,
-					htlc:            addMsg,
-					obfuscator:      obfuscator,
-					incomingTimeout: pd.Timeout,
-					outgoingTimeout: fwdInfo.OutgoingCTLV,
-					customRecords:   pld.CustomRecords(),
+			updatePacket := &htlcPacket{
+				incomingChanID:  l.ShortChanID(),
+				incomingHTLCID:  pd.HtlcIndex,
+				outgoingChanID:  fwdInfo.NextHop,
+				sourceRef:       pd.SourceRef,
+				incomingAmount:  pd.Amount,
+				amount:          addMsg.Amount,
+				htlc:            addMsg,
+				obfuscator:      obfuscator,
+				incomingTimeout: pd.Timeout,
+				outgoingTimeout: fwdInfo.OutgoingCTLV,
+				customRecords:   pld.CustomRecords(),
+			}
+
+			// If the HTLC interceptor held or rejected this add
+			// before, we stick to its persisted decision.
+			var intercept *channeldb.HtlcIntercept
+			if decision, ok := fwdPkg.Intercepts[idx]; ok {
+				intercept = &decision
+			} else {
+				// Now that this add has been reprocessed, only
+				// let it through if this is the first time
+				// processing the add. If the fwd pkg has
+				// already been processed, then we entered the
+				// above section to recreate a previous error.
+				// If the packet had previously been forwarded,
+				// it would have been added to switchPackets at
+				// the top of this section.
+				if fwdPkg.State != channeldb.FwdStateLockedIn {
+					continue
 				}
 
+				intercept, err = l.interceptHtlc(
+					fwdPkg.Height, idx, updatePacket,
+				)
+				if err != nil {
+					l.fail(LinkFailureError{
+						code: ErrInternalError,
+					}, "unable to intercept htlc: %v", err)
+
+					return
+				}
+			}
+
+			switch {
+			case intercept == nil:
 				fwdPkg.FwdFilter.Set(idx)
 				switchPackets = append(switchPackets,
 					updatePacket)
+
+			case intercept.State == channeldb.HtlcInterceptHeld:
+				l.parkHtlc(pd, obfuscator, updatePacket)
+
+			// A resumed add was handed over to the switch before,
+			// which ignores it if its circuit is already open.
+			case intercept.State == channeldb.HtlcInterceptResumed:
+				switchPackets = append(switchPackets,
+					updatePacket)
+
+			case intercept.State == channeldb.HtlcInterceptFailed:
+				l.failInterceptedHtlc(
+					pd, obfuscator, intercept.FailCode,
+				)
 			}
 		}
 	}
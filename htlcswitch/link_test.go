@@ -23,6 +23,7 @@ import (
 	"github.com/davecgh/go-spew/spew"
 	sphinx "github.com/lightningnetwork/lightning-onion"
 	"github.com/lightningnetwork/lnd/build"
+	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
 	"github.com/lightningnetwork/lnd/contractcourt"
@@ -32,6 +33,7 @@ import (
 	invpkg "github.com/lightningnetwork/lnd/invoices"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lnpeer"
+	"github.com/lightningnetwork/lnd/lntest/mock"
 	"github.com/lightningnetwork/lnd/lntest/wait"
 	"github.com/lightningnetwork/lnd/lntypes"
 	"github.com/lightningnetwork/lnd/lnwallet"
@@ -2531,13 +2533,15 @@ func TestChannelLinkBandwidthConsistency(t *testing.T) {
 	aliceLink.HandleChannelUpdate(htlc)
 	time.Sleep(time.Millisecond * 500)
 
-	// No changes before the HTLC is locked in.
-	assertLinkBandwidth(t, aliceLink, aliceStartingBandwidth)
+	// As the initiator, Alice will have to pay the htlc fee once the
+	// HTLC is locked in, so it's already accounted for as soon as she
+	// receives it.
+	assertLinkBandwidth(t, aliceLink, aliceStartingBandwidth-htlcFee)
 	if err := updateState(tmr, coreLink, bobChannel, false); err != nil {
 		t.Fatalf("unable to update state: %v", err)
 	}
 
-	// After lock-in, Alice will have to pay the htlc fee.
+	// After lock-in, the bandwidth should remain the same.
 	assertLinkBandwidth(t, aliceLink, aliceStartingBandwidth-htlcFee)
 
 	addPkt = htlcPacket{
@@ -4489,6 +4493,9 @@ func (h *persistentLinkHarness) restartLink(
 		HtlcNotifier:            aliceSwitch.cfg.HtlcNotifier,
 		SyncStates:              syncStates,
 		GetAliases:              getAliases,
+		HtlcInterceptor:         h.coreLink.cfg.HtlcInterceptor,
+		InterceptRejectDelta:    h.coreLink.cfg.InterceptRejectDelta,
+		ChainNotifier:           h.coreLink.cfg.ChainNotifier,
 	}
 
 	aliceLink := NewChannelLink(aliceCfg, aliceChannel)
@@ -5322,6 +5329,11 @@ func (*mockPackager) SetFwdFilter(tx kvdb.RwTx, height uint64,
 	return nil
 }
 
+func (*mockPackager) SetHtlcIntercept(tx kvdb.RwTx, height uint64,
+	index uint16, intercept *channeldb.HtlcIntercept) error {
+	return nil
+}
+
 func (*mockPackager) AckAddHtlcs(tx kvdb.RwTx,
 	addRefs ...channeldb.AddRef) error {
 	return nil
@@ -6894,3 +6906,317 @@ func TestChannelLinkShortFailureRelay(t *testing.T) {
 	default:
 	}
 }
+
+// mockHtlcInterceptor is an HTLC interceptor returning a configurable
+// decision, which keeps track of the number of HTLCs it intercepted.
+type mockHtlcInterceptor struct {
+	action   HtlcInterceptAction
+	failCode lnwire.FailCode
+	calls    int
+
+	mtx sync.Mutex
+}
+
+// intercept returns the configured decision.
+//
+// NOTE: This is the HtlcInterceptor of the link.
+func (m *mockHtlcInterceptor) intercept(_ InterceptedPacket) (
+	HtlcInterceptAction, lnwire.FailCode) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.calls++
+
+	return m.action, m.failCode
+}
+
+// setDecision sets the decision returned for the next intercepted HTLCs.
+func (m *mockHtlcInterceptor) setDecision(action HtlcInterceptAction,
+	failCode lnwire.FailCode) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.action = action
+	m.failCode = failCode
+}
+
+// numCalls returns the number of HTLCs intercepted so far.
+func (m *mockHtlcInterceptor) numCalls() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.calls
+}
+
+// generateForwardHtlc generates an htlc from Bob to Alice, which Alice is
+// asked to forward over a channel unknown to her switch.
+func generateForwardHtlc(t *testing.T, id uint64) *lnwire.UpdateAddHTLC {
+	t.Helper()
+
+	var nextHop [8]byte
+	binary.BigEndian.PutUint64(nextHop[:], 1234)
+
+	htlcAmt := lnwire.NewMSatFromSatoshis(10000)
+	htlcExpiry := testStartingHeight + testInvoiceCltvExpiry
+	hops := []*hop.Payload{
+		hop.NewLegacyPayload(&sphinx.HopData{
+			Realm:         [1]byte{}, // hop.BitcoinNetwork
+			NextAddress:   nextHop,
+			ForwardAmount: uint64(htlcAmt),
+			OutgoingCltv:  uint32(htlcExpiry),
+		}),
+	}
+	blob, err := generateRoute(hops...)
+	require.NoError(t, err, "unable to generate route")
+
+	_, htlc, _, err := generatePayment(
+		htlcAmt, htlcAmt, uint32(htlcExpiry), blob,
+	)
+	require.NoError(t, err, "unable to create payment")
+
+	htlc.ID = id
+
+	return htlc
+}
+
+// interceptRejectDelta is the number of blocks before their expiry at which
+// the htlcs held by the HTLC interceptor are failed back in the tests.
+const interceptRejectDelta = 3
+
+// newInterceptorLinkHarness creates a link intercepting the incoming htlcs
+// with the returned interceptor, and starts it.
+func newInterceptorLinkHarness(t *testing.T) (*persistentLinkHarness,
+	*linkTestContext, *mockHtlcInterceptor) {
+
+	t.Helper()
+
+	const chanAmt = btcutil.SatoshiPerBitcoin * 5
+
+	aliceLink, bobChannel, _, start, restore, err :=
+		newSingleLinkTestHarness(t, chanAmt, 0)
+	require.NoError(t, err, "unable to create link")
+
+	interceptor := &mockHtlcInterceptor{}
+	aliceCfg := &aliceLink.(*channelLink).cfg
+	aliceCfg.HtlcInterceptor = interceptor.intercept
+	aliceCfg.InterceptRejectDelta = interceptRejectDelta
+	aliceCfg.ChainNotifier = &mock.ChainNotifier{
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+	}
+
+	alice := newPersistentLinkHarness(t, aliceLink, nil, restore)
+
+	require.NoError(t, start(), "unable to start test harness")
+
+	ctx := &linkTestContext{
+		t:          t,
+		aliceLink:  alice.link,
+		aliceMsgs:  alice.msgs,
+		bobChannel: bobChannel,
+	}
+
+	return alice, ctx, interceptor
+}
+
+// lockInForwardHtlcs locks in the given htlcs from Bob to Alice. Alice is put
+// into hodl.Commit mode before the htlcs are locked in, such that the fails
+// she sends back aren't committed and have to be reproduced after a restart.
+func lockInForwardHtlcs(alice *persistentLinkHarness, ctx *linkTestContext,
+	htlcs ...*lnwire.UpdateAddHTLC) {
+
+	for _, htlc := range htlcs {
+		ctx.sendHtlcBobToAlice(htlc)
+	}
+	ctx.sendCommitSigBobToAlice(len(htlcs))
+	ctx.receiveRevAndAckAliceToBob()
+	ctx.receiveCommitSigAliceToBob(len(htlcs))
+
+	alice.coreLink.cfg.HodlMask = hodl.Commit.Mask()
+
+	ctx.sendRevAndAckBobToAlice()
+}
+
+// restartInterceptorLink restarts Alice's link in hodl.Commit mode, and points
+// the test context to the new link.
+func restartInterceptorLink(alice *persistentLinkHarness,
+	ctx *linkTestContext) {
+
+	alice.restart(false, false, hodl.Commit)
+	ctx.aliceLink = alice.link
+	ctx.aliceMsgs = alice.msgs
+}
+
+// assertFailAliceToBob asserts that Alice fails back the htlc with the given
+// id and failure code.
+func assertFailAliceToBob(t *testing.T, ctx *linkTestContext, id uint64,
+	code lnwire.FailCode) {
+
+	t.Helper()
+
+	var msg lnwire.Message
+	select {
+	case msg = <-ctx.aliceMsgs:
+	case <-time.After(15 * time.Second):
+		t.Fatalf("did not receive message")
+	}
+
+	failMsg, ok := msg.(*lnwire.UpdateFailHTLC)
+	require.Truef(t, ok, "expected UpdateFailHTLC, got %T", msg)
+	require.Equal(t, id, failMsg.ID)
+
+	// The mock obfuscator prefixes the plain failure with a fake hmac.
+	failure, err := lnwire.DecodeFailure(
+		bytes.NewReader(failMsg.Reason[len(fakeHmac):]), 0,
+	)
+	require.NoError(t, err, "unable to decode failure")
+	require.Equal(t, code, failure.Code())
+}
+
+// TestChannelLinkInterceptorAcceptRestart asserts that an htlc accepted by the
+// HTLC interceptor is forwarded again after a restart, without consulting the
+// interceptor again.
+func TestChannelLinkInterceptorAcceptRestart(t *testing.T) {
+	t.Parallel()
+
+	alice, ctx, interceptor := newInterceptorLinkHarness(t)
+
+	// The accepted htlc is handed over to the switch, which fails it back
+	// as the outgoing channel is unknown.
+	lockInForwardHtlcs(alice, ctx, generateForwardHtlc(t, 0))
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodeUnknownNextPeer)
+	require.Equal(t, 1, interceptor.numCalls())
+
+	// After the restart, the fail wasn't committed yet, so it must be sent
+	// again, even if the interceptor would now reject the htlc.
+	interceptor.setDecision(
+		HtlcInterceptReject, lnwire.CodePermanentChannelFailure,
+	)
+	restartInterceptorLink(alice, ctx)
+
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodeUnknownNextPeer)
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Equal(t, 1, interceptor.numCalls())
+}
+
+// TestChannelLinkInterceptorRejectRestart asserts that an htlc rejected by the
+// HTLC interceptor is failed back again after a restart if the fail wasn't
+// committed, instead of being forwarded.
+func TestChannelLinkInterceptorRejectRestart(t *testing.T) {
+	t.Parallel()
+
+	alice, ctx, interceptor := newInterceptorLinkHarness(t)
+	interceptor.setDecision(
+		HtlcInterceptReject, lnwire.CodePermanentChannelFailure,
+	)
+
+	lockInForwardHtlcs(alice, ctx, generateForwardHtlc(t, 0))
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodePermanentChannelFailure)
+	ctx.assertNoMsgFromAlice(time.Second)
+
+	// Even if the interceptor would now accept the htlc, the persisted
+	// rejection is reproduced after the restart.
+	interceptor.setDecision(HtlcInterceptAccept, 0)
+	restartInterceptorLink(alice, ctx)
+
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodePermanentChannelFailure)
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Equal(t, 1, interceptor.numCalls())
+}
+
+// TestChannelLinkInterceptorHoldRestart asserts that htlcs held by the HTLC
+// interceptor stay parked after a restart, and that they can be resumed and
+// canceled afterwards.
+func TestChannelLinkInterceptorHoldRestart(t *testing.T) {
+	t.Parallel()
+
+	alice, ctx, interceptor := newInterceptorLinkHarness(t)
+	interceptor.setDecision(HtlcInterceptHold, 0)
+
+	lockInForwardHtlcs(
+		alice, ctx, generateForwardHtlc(t, 0), generateForwardHtlc(t, 1),
+	)
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Equal(t, []uint64{0, 1}, alice.link.ParkedHtlcs())
+
+	// The htlcs are parked again after the restart, even if the
+	// interceptor would now reject them.
+	interceptor.setDecision(
+		HtlcInterceptReject, lnwire.CodePermanentChannelFailure,
+	)
+	restartInterceptorLink(alice, ctx)
+
+	require.Eventually(t, func() bool {
+		return len(alice.link.ParkedHtlcs()) == 2
+	}, 15*time.Second, 10*time.Millisecond)
+	require.Equal(t, []uint64{0, 1}, alice.link.ParkedHtlcs())
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Equal(t, 2, interceptor.numCalls())
+
+	// Resuming the first htlc hands it over to the switch, which fails it
+	// back.
+	require.NoError(t, alice.link.ResumeParkedHtlc(0))
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodeUnknownNextPeer)
+
+	// Canceling the second one fails it back with the given code.
+	err := alice.link.CancelParkedHtlc(1, lnwire.CodeTemporaryNodeFailure)
+	require.NoError(t, err)
+	assertFailAliceToBob(t, ctx, 1, lnwire.CodeTemporaryNodeFailure)
+
+	require.Empty(t, alice.link.ParkedHtlcs())
+	err = alice.link.ResumeParkedHtlc(0)
+	require.ErrorIs(t, err, ErrHtlcNotParked)
+
+	ctx.assertNoMsgFromAlice(time.Second)
+}
+
+// sendBlockEpoch notifies Alice's link of a new block at the given height.
+func sendBlockEpoch(t *testing.T, alice *persistentLinkHarness,
+	height int32) {
+
+	t.Helper()
+
+	notifier := alice.coreLink.cfg.ChainNotifier.(*mock.ChainNotifier)
+	select {
+	case notifier.EpochChan <- &chainntnfs.BlockEpoch{Height: height}:
+	case <-time.After(15 * time.Second):
+		t.Fatalf("block epoch not consumed")
+	}
+}
+
+// TestChannelLinkInterceptorHoldExpiry asserts that htlcs held by the HTLC
+// interceptor are failed back once their incoming expiry is within the
+// link's InterceptRejectDelta of the best height, and that the fail is
+// reproduced after a restart.
+func TestChannelLinkInterceptorHoldExpiry(t *testing.T) {
+	t.Parallel()
+
+	alice, ctx, interceptor := newInterceptorLinkHarness(t)
+	interceptor.setDecision(HtlcInterceptHold, 0)
+
+	htlc := generateForwardHtlc(t, 0)
+	lockInForwardHtlcs(alice, ctx, htlc)
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Equal(t, []uint64{0}, alice.link.ParkedHtlcs())
+
+	// A block before the reject window doesn't affect the htlc.
+	rejectHeight := int32(htlc.Expiry - interceptRejectDelta)
+	sendBlockEpoch(t, alice, rejectHeight-1)
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Equal(t, []uint64{0}, alice.link.ParkedHtlcs())
+
+	// Once the htlc is about to expire, it's failed back.
+	sendBlockEpoch(t, alice, rejectHeight)
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodeExpiryTooSoon)
+	require.Empty(t, alice.link.ParkedHtlcs())
+
+	// The fail wasn't committed, so it's sent again after a restart
+	// instead of parking the htlc again.
+	restartInterceptorLink(alice, ctx)
+
+	assertFailAliceToBob(t, ctx, 0, lnwire.CodeExpiryTooSoon)
+	ctx.assertNoMsgFromAlice(time.Second)
+	require.Empty(t, alice.link.ParkedHtlcs())
+	require.Equal(t, 1, interceptor.numCalls())
+}
@@ -51,6 +51,11 @@ const (
 	// circuit map. This is non-fatal and will resolve itself (usually
 	// within several minutes).
 	ErrCircuitError
+
+	// ErrShuttingDown indicates that a subsystem of our node the link
+	// depends on is shutting down. This says nothing about the state of
+	// the channel, so the error isn't sent to the remote peer.
+	ErrShuttingDown
 )
 
 // LinkFailureAction is an enum-like type that describes the action that should
@@ -122,6 +127,8 @@ func (e LinkFailureError) Error() string {
 		return "unable to resume channel, recovery required"
 	case ErrCircuitError:
 		return "non-fatal circuit map error"
+	case ErrShuttingDown:
+		return "shutting down"
 	default:
 		return "unknown error"
 	}
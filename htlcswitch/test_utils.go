@@ -239,6 +239,26 @@ func createTestChannel(t *testing.T, alicePrivKey, bobPrivKey []byte,
 		return nil, nil, err
 	}
 
+	// Encode the initial state number within both commitments, as it's
+	// validated once the channels are restored.
+	obfuscator := lnwallet.DeriveStateHintObfuscator(
+		aliceCfg.PaymentBasePoint.PubKey, bobCfg.PaymentBasePoint.PubKey,
+	)
+	if !isAliceInitiator {
+		obfuscator = lnwallet.DeriveStateHintObfuscator(
+			bobCfg.PaymentBasePoint.PubKey,
+			aliceCfg.PaymentBasePoint.PubKey,
+		)
+	}
+	err = lnwallet.SetStateNumHint(aliceCommitTx, 0, obfuscator)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = lnwallet.SetStateNumHint(bobCommitTx, 0, obfuscator)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	dbAlice, err := channeldb.Open(t.TempDir())
 	if err != nil {
 		return nil, nil, err
@@ -1,6 +1,9 @@
 package lncfg
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 const (
 	// DefaultReadWorkers is the default maximum number of concurrent
@@ -29,6 +32,10 @@ type Workers struct {
 
 	// Sig is the maximum number of concurrent sig pool workers.
 	Sig int `long:"sig" description:"Maximum number of concurrent sig pool workers. This number should be proportional to the number of CPUs on the host."`
+
+	// SigDrainTimeout is the time the sig pool waits for pending jobs to
+	// be processed when shutting down.
+	SigDrainTimeout time.Duration `long:"sigdraintimeout" description:"The time the sig pool waits for pending signing and verification jobs to be processed on shutdown. New jobs are refused in the meantime. Set to 0 to shut down right away."`
 }
 
 // Validate checks the Workers configuration to ensure that the input values are
@@ -46,6 +53,10 @@ func (w *Workers) Validate() error {
 		return fmt.Errorf("number of sig workers (%d) must be "+
 			"positive", w.Sig)
 	}
+	if w.SigDrainTimeout < 0 {
+		return fmt.Errorf("sig pool drain timeout (%v) must not be "+
+			"negative", w.SigDrainTimeout)
+	}
 
 	return nil
 }
@@ -2,6 +2,7 @@ package lncfg_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lightningnetwork/lnd/lncfg"
 )
@@ -13,7 +14,8 @@ const (
 )
 
 // TestValidateWorkers asserts that validating the Workers config only succeeds
-// if all fields specify a positive number of workers.
+// if all fields specify a positive number of workers and the sig pool drain
+// timeout isn't negative.
 func TestValidateWorkers(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -62,6 +64,25 @@ func TestValidateWorkers(t *testing.T) {
 				Sig:   0,
 			},
 		},
+		{
+			name: "sig drain timeout zero valid",
+			cfg: &lncfg.Workers{
+				Read:            1,
+				Write:           1,
+				Sig:             1,
+				SigDrainTimeout: 0,
+			},
+			valid: true,
+		},
+		{
+			name: "sig drain timeout negative invalid",
+			cfg: &lncfg.Workers{
+				Read:            1,
+				Write:           1,
+				Sig:             1,
+				SigDrainTimeout: -time.Second,
+			},
+		},
 		{
 			name: "read min invalid",
 			cfg: &lncfg.Workers{
@@ -141,6 +141,12 @@ func Main(cfg *Config, lisCfg ListenerCfg, implCfg *ImplementationCfg,
 
 	defer func() {
 		ltndLog.Info("Shutdown complete\n")
+
+		// All subsystems have been stopped at this point, so a
+		// pending forced shutdown no longer needs to kill the
+		// process.
+		interceptor.ShutdownComplete()
+
 		err := cfg.LogWriter.Close()
 		if err != nil {
 			ltndLog.Errorf("Could not close log rotator: %v", err)
@@ -180,6 +180,15 @@ type LightningClient interface {
 	// never broadcast. Only available for non-externally funded channels in dev
 	// build.
 	AbandonChannel(ctx context.Context, in *AbandonChannelRequest, opts ...grpc.CallOption) (*AbandonChannelResponse, error)
+	// lncli: `resyncchannel`
+	// ResyncChannel cycles the connection to the peer of an open channel, which
+	// makes both sides exchange channel_reestablish messages for all channels
+	// with the peer and retransmit any commitment or revocation the other side
+	// is missing. The sync status of the channel as seen from our local state
+	// before the reconnection is returned. Only peers we maintain a persistent
+	// connection to can be reconnected to, so this fails for inbound peers we
+	// don't know an address for.
+	ResyncChannel(ctx context.Context, in *ResyncChannelRequest, opts ...grpc.CallOption) (*ResyncChannelResponse, error)
 	// Deprecated: Do not use.
 	// lncli: `sendpayment`
 	// Deprecated, use routerrpc.SendPaymentV2. SendPayment dispatches a
@@ -807,6 +816,15 @@ func (c *lightningClient) AbandonChannel(ctx context.Context, in *AbandonChannel
 	return out, nil
 }
 
+func (c *lightningClient) ResyncChannel(ctx context.Context, in *ResyncChannelRequest, opts ...grpc.CallOption) (*ResyncChannelResponse, error) {
+	out := new(ResyncChannelResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ResyncChannel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Deprecated: Do not use.
 func (c *lightningClient) SendPayment(ctx context.Context, opts ...grpc.CallOption) (Lightning_SendPaymentClient, error) {
 	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[6], "/lnrpc.Lightning/SendPayment", opts...)
@@ -1484,6 +1502,15 @@ type LightningServer interface {
 	// never broadcast. Only available for non-externally funded channels in dev
 	// build.
 	AbandonChannel(context.Context, *AbandonChannelRequest) (*AbandonChannelResponse, error)
+	// lncli: `resyncchannel`
+	// ResyncChannel cycles the connection to the peer of an open channel, which
+	// makes both sides exchange channel_reestablish messages for all channels
+	// with the peer and retransmit any commitment or revocation the other side
+	// is missing. The sync status of the channel as seen from our local state
+	// before the reconnection is returned. Only peers we maintain a persistent
+	// connection to can be reconnected to, so this fails for inbound peers we
+	// don't know an address for.
+	ResyncChannel(context.Context, *ResyncChannelRequest) (*ResyncChannelResponse, error)
 	// Deprecated: Do not use.
 	// lncli: `sendpayment`
 	// Deprecated, use routerrpc.SendPaymentV2. SendPayment dispatches a
@@ -1803,6 +1830,9 @@ func (UnimplementedLightningServer) CloseChannel(*CloseChannelRequest, Lightning
 func (UnimplementedLightningServer) AbandonChannel(context.Context, *AbandonChannelRequest) (*AbandonChannelResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AbandonChannel not implemented")
 }
+func (UnimplementedLightningServer) ResyncChannel(context.Context, *ResyncChannelRequest) (*ResyncChannelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResyncChannel not implemented")
+}
 func (UnimplementedLightningServer) SendPayment(Lightning_SendPaymentServer) error {
 	return status.Errorf(codes.Unimplemented, "method SendPayment not implemented")
 }
@@ -2460,6 +2490,24 @@ func _Lightning_AbandonChannel_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_ResyncChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResyncChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ResyncChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ResyncChannel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ResyncChannel(ctx, req.(*ResyncChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Lightning_SendPayment_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(LightningServer).SendPayment(&lightningSendPaymentServer{stream})
 }
@@ -3293,6 +3341,10 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AbandonChannel",
 			Handler:    _Lightning_AbandonChannel_Handler,
 		},
+		{
+			MethodName: "ResyncChannel",
+			Handler:    _Lightning_ResyncChannel_Handler,
+		},
 		{
 			MethodName: "SendPaymentSync",
 			Handler:    _Lightning_SendPaymentSync_Handler,
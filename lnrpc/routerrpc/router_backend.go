@@ -149,6 +149,12 @@ func (r *RouterBackend) QueryRoutes(ctx context.Context,
 		return nil, err
 	}
 
+	// If requested, preview a multi-part payment by splitting the amount
+	// over multiple routes.
+	if in.MaxParts > 1 {
+		return r.queryRouteParts(routeReq, in.MaxParts)
+	}
+
 	// Query the channel router for a possible path to the destination that
 	// can carry `in.Amt` satoshis _including_ the total fee required on
 	// the route
@@ -172,6 +178,124 @@ func (r *RouterBackend) QueryRoutes(ctx context.Context,
 	return routeResp, nil
 }
 
+// queryRouteParts previews a multi-part payment by splitting the amount of the
+// passed route request into an increasing number of equal parts, up to
+// maxParts, until a route is found for every part. The amount carried by the
+// route of a part is reserved on each of its node pairs, so a pair is only
+// used by subsequent parts if its capacity is sufficient for all of them. The
+// returned response contains one route per part, and the probability that all
+// parts succeed.
+func (r *RouterBackend) queryRouteParts(routeReq *routing.RouteRequest,
+	maxParts uint32) (*lnrpc.QueryRoutesResponse, error) {
+
+	var lastErr error
+	for numParts := uint32(1); numParts <= maxParts; numParts++ {
+		if routeReq.Amount < lnwire.MilliSatoshi(numParts) {
+			break
+		}
+
+		routes, successProb, err := r.querySplitRoutes(
+			routeReq, numParts,
+		)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		rpcRoutes := make([]*lnrpc.Route, 0, len(routes))
+		for _, partRoute := range routes {
+			rpcRoute, err := r.MarshallRoute(partRoute)
+			if err != nil {
+				return nil, err
+			}
+			rpcRoutes = append(rpcRoutes, rpcRoute)
+		}
+
+		return &lnrpc.QueryRoutesResponse{
+			Routes:      rpcRoutes,
+			SuccessProb: successProb,
+		}, nil
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("amount of %v is too small to be split",
+			routeReq.Amount)
+	}
+
+	return nil, fmt.Errorf("unable to split %v over at most %d parts: %w",
+		routeReq.Amount, maxParts, lastErr)
+}
+
+// querySplitRoutes finds a route for each of numParts equal parts of the
+// amount of the passed route request. A node pair is only used by a part if
+// its capacity is sufficient to also carry the amounts of the earlier parts
+// routed over it.
+func (r *RouterBackend) querySplitRoutes(routeReq *routing.RouteRequest,
+	numParts uint32) ([]*route.Route, float64, error) {
+
+	var (
+		restrictions = routeReq.Restrictions
+		parts        = lnwire.MilliSatoshi(numParts)
+		feeLimit     = restrictions.FeeLimit
+		routes       = make([]*route.Route, 0, numParts)
+		successProb  = 1.0
+	)
+
+	reserved := make(map[routing.DirectedNodePair]lnwire.MilliSatoshi)
+	for i := uint32(0); i < numParts; i++ {
+		// The fee limit applies to the payment as a whole, so it's
+		// split the same way as the amount. Any remainder of the split
+		// is added to the first part.
+		partAmt := routeReq.Amount / parts
+		partRestrictions := *restrictions
+		partRestrictions.FeeLimit = feeLimit / parts
+		if i == 0 {
+			partAmt += routeReq.Amount % parts
+			partRestrictions.FeeLimit += feeLimit % parts
+		}
+
+		partRestrictions.ProbabilitySource = func(fromNode,
+			toNode route.Vertex, amt lnwire.MilliSatoshi,
+			capacity btcutil.Amount) float64 {
+
+			pair := routing.NewDirectedNodePair(fromNode, toNode)
+			capMsat := lnwire.NewMSatFromSatoshis(capacity)
+			if capacity > 0 && reserved[pair]+amt > capMsat {
+				return 0
+			}
+
+			return restrictions.ProbabilitySource(
+				fromNode, toNode, amt, capacity,
+			)
+		}
+
+		partReq := *routeReq
+		partReq.Amount = partAmt
+		partReq.Restrictions = &partRestrictions
+
+		partRoute, prob, err := r.FindRoute(&partReq)
+		if err != nil {
+			return nil, 0, fmt.Errorf("part %d of %d: %w", i+1,
+				numParts, err)
+		}
+
+		routes = append(routes, partRoute)
+		successProb *= prob
+
+		// Reserve the amount that flows over each of the node pairs of
+		// the route, including the fees of the subsequent hops.
+		prev, hopAmt := partRoute.SourcePubKey, partRoute.TotalAmount
+		for _, hop := range partRoute.Hops {
+			pair := routing.NewDirectedNodePair(prev, hop.PubKeyBytes)
+			reserved[pair] += hopAmt
+
+			prev, hopAmt = hop.PubKeyBytes, hop.AmtToForward
+		}
+	}
+
+	return routes, successProb, nil
+}
+
 func parsePubKey(key string) (route.Vertex, error) {
 	pubKeyBytes, err := hex.DecodeString(key)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil"
@@ -241,6 +242,134 @@ func testQueryRoutes(t *testing.T, useMissionControl bool, useMsat bool,
 	}
 }
 
+// TestQueryRouteParts asserts that the amount of a query routes request is
+// split over multiple routes if max parts is set, and that node pairs are only
+// shared by parts if their capacity is sufficient.
+func TestQueryRouteParts(t *testing.T) {
+	t.Parallel()
+
+	dest, err := route.NewVertexFromStr(destKey)
+	require.NoError(t, err)
+
+	// queryParts queries a split of 100k sats over at most maxParts
+	// routes. The graph consists of the two paths source -> node1 -> dest
+	// and source -> node2 -> dest, whose pairs have the passed capacity.
+	// No route can carry more than maxRouteAmt.
+	queryParts := func(maxParts uint32, capacity btcutil.Amount,
+		maxRouteAmt lnwire.MilliSatoshi) (*lnrpc.QueryRoutesResponse,
+		error) {
+
+		findRoute := func(req *routing.RouteRequest) (*route.Route,
+			float64, error) {
+
+			// The fee limit is split along with the amount.
+			require.InDelta(
+				t, float64(1001*req.Amount/100_000_000),
+				float64(req.Restrictions.FeeLimit), 2,
+			)
+
+			if req.Amount > maxRouteAmt {
+				return nil, 0, errors.New("no route")
+			}
+
+			prob := req.Restrictions.ProbabilitySource
+			for i, next := range []route.Vertex{node1, node2} {
+				if prob(req.Source, next, req.Amount,
+					capacity) == 0 {
+
+					continue
+				}
+				if prob(next, dest, req.Amount, capacity) == 0 {
+					continue
+				}
+
+				hops := []*route.Hop{
+					{
+						PubKeyBytes:  next,
+						ChannelID:    uint64(i + 1),
+						AmtToForward: req.Amount,
+					},
+					{
+						PubKeyBytes:  dest,
+						ChannelID:    uint64(i + 10),
+						AmtToForward: req.Amount,
+					},
+				}
+				r, err := route.NewRouteFromHops(
+					req.Amount, 144, req.Source, hops,
+				)
+
+				return r, 0.5, err
+			}
+
+			return nil, 0, errors.New("no route")
+		}
+
+		backend := &RouterBackend{
+			FindRoute:        findRoute,
+			SelfNode:         sourceKey,
+			MaxTotalTimelock: 1000,
+			FetchChannelCapacity: func(chanID uint64) (
+				btcutil.Amount, error) {
+
+				return capacity, nil
+			},
+			MissionControl: &mockMissionControl{},
+		}
+
+		return backend.QueryRoutes(
+			context.Background(), &lnrpc.QueryRoutesRequest{
+				PubKey:         destKey,
+				AmtMsat:        100_000_000,
+				FinalCltvDelta: 100,
+				FeeLimit: &lnrpc.FeeLimit{
+					Limit: &lnrpc.FeeLimit_FixedMsat{
+						FixedMsat: 1001,
+					},
+				},
+				MaxParts: maxParts,
+			},
+		)
+	}
+
+	// If the amount fits into a single route, it isn't split.
+	resp, err := queryParts(3, 200_000, 100_000_000)
+	require.NoError(t, err)
+	require.Len(t, resp.Routes, 1)
+	require.EqualValues(t, 100_000_000, resp.Routes[0].TotalAmtMsat)
+
+	// With a capacity of 60k sats per pair, two parts of 50k sats can't
+	// share a pair, so they're routed through different nodes.
+	resp, err = queryParts(3, 60_000, 100_000_000)
+	require.NoError(t, err)
+	require.Len(t, resp.Routes, 2)
+	require.NotEqual(
+		t, resp.Routes[0].Hops[0].PubKey, resp.Routes[1].Hops[0].PubKey,
+	)
+	require.InDelta(t, 0.25, resp.SuccessProb, 0.0001)
+
+	// If the capacity is sufficient, parts may share the first hop, so a
+	// node with a single suitable peer is able to split as well.
+	resp, err = queryParts(3, 200_000, 40_000_000)
+	require.NoError(t, err)
+	require.Len(t, resp.Routes, 3)
+	for _, r := range resp.Routes {
+		require.Equal(
+			t, resp.Routes[0].Hops[0].PubKey, r.Hops[0].PubKey,
+		)
+	}
+
+	var total int64
+	for _, r := range resp.Routes {
+		total += r.TotalAmtMsat
+	}
+	require.EqualValues(t, 100_000_000, total)
+
+	// If no split up to max parts succeeds, the last error is returned.
+	_, err = queryParts(2, 200_000, 40_000_000)
+	require.ErrorContains(t, err, "no route")
+}
+
 type mockMissionControl struct {
 	MissionControl
 }
@@ -128,6 +128,11 @@ type ChanCloseCfg struct {
 	// FeeEstimator is used to estimate the absolute starting co-op close
 	// fee.
 	FeeEstimator CoopFeeEstimator
+
+	// AnySegwitShutdown should be set if option_shutdown_anysegwit has
+	// been negotiated with the remote party, in which case taproot and
+	// future segwit versions are accepted as delivery scripts.
+	AnySegwitShutdown bool
 }
 
 // ChanCloser is a state machine that handles the cooperative channel closure
@@ -778,6 +783,7 @@ func (c *ChanCloser) ProcessCloseMsg(msg lnwire.Message) ([]lnwire.Message,
 			}
 		}
 
+		closeOpts = append(closeOpts, c.deliveryScriptOpts()...)
 		closeTx, _, err := c.cfg.Channel.CompleteCooperativeClose(
 			localSig, remoteSig, c.localDeliveryScript,
 			c.remoteDeliveryScript, remoteProposedFee, closeOpts...,
@@ -843,6 +849,20 @@ func (c *ChanCloser) ProcessCloseMsg(msg lnwire.Message) ([]lnwire.Message,
 	}
 }
 
+// deliveryScriptOpts returns the close options that set the script classes
+// accepted as delivery scripts of the closing transaction.
+func (c *ChanCloser) deliveryScriptOpts() []lnwallet.ChanCloseOpt {
+	if !c.cfg.AnySegwitShutdown {
+		return nil
+	}
+
+	return []lnwallet.ChanCloseOpt{
+		lnwallet.WithDeliveryScriptClasses(
+			lnwallet.AnySegwitDeliveryScriptClasses...,
+		),
+	}
+}
+
 // proposeCloseSigned attempts to propose a new signature for the closing
 // transaction for a channel based on the prior fee negotiations and our current
 // compromise fee.
@@ -861,6 +881,7 @@ func (c *ChanCloser) proposeCloseSigned(fee btcutil.Amount) (*lnwire.ClosingSign
 		}
 	}
 
+	closeOpts = append(closeOpts, c.deliveryScriptOpts()...)
 	rawSig, _, _, err := c.cfg.Channel.CreateCloseProposal(
 		fee, c.localDeliveryScript, c.remoteDeliveryScript,
 		closeOpts...,
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -34,6 +35,13 @@ import (
 )
 
 var (
+	// ErrRestoredChannel is returned when a caller attempts to update the
+	// state of a channel that was restored from a static channel backup.
+	// Such channels can only be used to carry out the data loss
+	// protection protocol with the remote party.
+	ErrRestoredChannel = errors.New("channel was restored from a " +
+		"backup, operation disallowed")
+
 	// ErrChanClosing is returned when a caller attempts to close a channel
 	// that has already been closed or is in the process of being closed.
 	ErrChanClosing = fmt.Errorf("channel is being closed, operation disallowed")
@@ -93,6 +101,13 @@ var (
 	ErrInvalidLocalUnrevokedCommitPoint = fmt.Errorf("unrevoked commit " +
 		"point is invalid")
 
+	// ErrInconsistentChanSync is returned in strict chan sync mode if the
+	// heights claimed in the remote party's ChannelReestablish message
+	// are inconsistent with the recovery fields they provided, see
+	// WithStrictChanSync.
+	ErrInconsistentChanSync = errors.New("chan sync heights inconsistent " +
+		"with recovery data")
+
 	// ErrCommitSyncRemoteDataLoss is returned in the case that we receive
 	// a ChannelReestablish message from the remote that advertises a
 	// NextLocalCommitHeight that is lower than what they have already
@@ -122,6 +137,97 @@ var (
 	// we've likely lost data ourselves.
 	ErrForceCloseLocalDataLoss = errors.New("cannot force close " +
 		"channel with local data loss")
+
+	// ErrNonStandardDeliveryScript is returned when a delivery script of
+	// a cooperative close transaction isn't one of the allowed script
+	// types.
+	ErrNonStandardDeliveryScript = errors.New("non-standard delivery " +
+		"script")
+
+	// ErrStateHintMismatch is returned when the state hint of our latest
+	// local commitment transaction doesn't decode to its commitment
+	// height. This usually indicates the payment base points of the
+	// channel are misconfigured.
+	ErrStateHintMismatch = errors.New("state hint mismatch")
+
+	// ErrInvalidStoredHtlcSig is returned when an HTLC signature of our
+	// current local commitment that's stored on disk doesn't verify.
+	ErrInvalidStoredHtlcSig = errors.New("invalid stored htlc signature")
+
+	// ErrUpfrontShutdownScriptMismatch is returned when a cooperative
+	// close is attempted with a local delivery script that differs from
+	// the upfront shutdown script we committed to at channel open.
+	ErrUpfrontShutdownScriptMismatch = errors.New("delivery script does " +
+		"not match upfront shutdown script")
+
+	// ErrCoopCloseFinalized is returned when a cooperative close is
+	// attempted to be aborted after the closing transaction has already
+	// been completed or broadcast.
+	ErrCoopCloseFinalized = errors.New("cooperative close already " +
+		"finalized")
+
+	// ErrCoopCloseSigned is returned when a cooperative close is attempted
+	// to be aborted after we handed out our signature for a closing
+	// transaction.
+	ErrCoopCloseSigned = errors.New("cooperative close already signed")
+
+	// ErrCloseFeeMismatch is returned when a cooperative close is
+	// proposed or completed with a fee other than the one agreed upon by
+	// ResolveCloseProposal.
+	ErrCloseFeeMismatch = errors.New("close fee doesn't match agreed " +
+		"upon fee")
+
+	// ErrDustExposureExceeded is returned when a proposed dust HTLC would
+	// push the total value of dust HTLCs on either commitment above the
+	// maximum dust exposure of the channel.
+	ErrDustExposureExceeded = errors.New("htlc would exceed max dust " +
+		"exposure")
+
+	// ErrInvalidCsvDelay is reported when the CSV delay of either party's
+	// commitment outputs is zero or exceeds the maximum we allow.
+	ErrInvalidCsvDelay = errors.New("invalid csv delay")
+
+	// ErrUnknownRemoteCommit is returned when a transaction spending the
+	// funding output doesn't match any commitment of the remote party
+	// that we're aware of.
+	ErrUnknownRemoteCommit = errors.New("unknown remote commitment")
+
+	// ErrHtlcSigCountMismatch is returned when the number of HTLC
+	// signatures received along with a new commitment doesn't match the
+	// number of HTLC outputs on that commitment.
+	ErrHtlcSigCountMismatch = errors.New("htlc signature count mismatch")
+
+	// ErrRevokedHeightOutOfRange is returned when requesting information
+	// about remote commitment heights that haven't been revoked yet.
+	ErrRevokedHeightOutOfRange = errors.New("revoked height out of range")
+
+	// ErrRevocationStateMismatch is returned by AuditRevocationState and
+	// ImportRevocationState when the revocation producer, revocation store
+	// and commitment heights of the channel aren't consistent with each
+	// other.
+	ErrRevocationStateMismatch = errors.New("revocation state mismatch")
+
+	// ErrNoPendingRemoteCommit is returned when attempting to discard the
+	// pending remote commitment, while all commitments we extended to the
+	// remote party were already revoked.
+	ErrNoPendingRemoteCommit = errors.New("no pending remote commitment")
+
+	// ErrCannotDiscardRemoteCommit is returned when the pending remote
+	// commitment can't be discarded without leaving the channel in an
+	// inconsistent state.
+	ErrCannotDiscardRemoteCommit = errors.New("unable to discard pending " +
+		"remote commitment")
+
+	// ErrFeeRateBelowFloor is returned when the remote party proposes a
+	// commitment fee rate below the minimum relay fee rate of our fee
+	// estimator, as the commitment transaction couldn't be broadcast.
+	ErrFeeRateBelowFloor = errors.New("fee rate below relay fee floor")
+
+	// ErrCircularHtlc is returned when an HTLC is added while an HTLC with
+	// the same payment hash is pending in the opposite direction of the
+	// channel, and circular HTLCs are rejected.
+	ErrCircularHtlc = errors.New("payment hash pending in opposite " +
+		"direction")
 )
 
 // ErrCommitSyncLocalDataLoss is returned in the case that we receive a valid
@@ -148,43 +254,96 @@ func (e *ErrCommitSyncLocalDataLoss) Error() string {
 		e.CommitPoint.SerializeCompressed())
 }
 
-// channelState is an enum like type which represents the current state of a
+// ErrRevocationChainBroken is returned by ReceiveRevocation if the revocation
+// secret sent by the remote party can't be added to our revocation store, as
+// it doesn't chain with the secrets it revealed for prior commitments.
+type ErrRevocationChainBroken struct {
+	// height is the height of the remote commitment the secret was meant
+	// to revoke.
+	height uint64
+
+	// derivedPoint is the commitment point derived from the secret.
+	derivedPoint *btcec.PublicKey
+
+	// expectedPoint is the commitment point of the remote commitment
+	// being revoked.
+	expectedPoint *btcec.PublicKey
+
+	// err is the error returned by the revocation store.
+	err error
+}
+
+// Error returns a detailed error string including the commitment points of the
+// revocation.
+func (e *ErrRevocationChainBroken) Error() string {
+	return fmt.Sprintf("revocation chain broken at height=%v, "+
+		"derived_commit_point=%x, expected_commit_point=%x: %v",
+		e.height, e.derivedPoint.SerializeCompressed(),
+		e.expectedPoint.SerializeCompressed(), e.err)
+}
+
+// Unwrap returns the error returned by the revocation store.
+func (e *ErrRevocationChainBroken) Unwrap() error {
+	return e.err
+}
+
+// ChannelState is an enum like type which represents the current state of a
 // particular channel.
-// TODO(roasbeef): actually update state
-type channelState uint8
+type ChannelState uint8
 
 const (
-	// channelPending indicates this channel is still going through the
-	// funding workflow, and isn't yet open.
-	channelPending channelState = iota // nolint: unused
-
-	// channelOpen represents an open, active channel capable of
+	// ChannelOpen represents an open, active channel capable of
 	// sending/receiving HTLCs.
-	channelOpen
+	ChannelOpen ChannelState = iota
 
-	// channelClosing represents a channel which is in the process of being
+	// ChannelClosing represents a channel which is in the process of being
 	// closed.
-	channelClosing
+	ChannelClosing
 
-	// channelClosed represents a channel which has been fully closed. Note
+	// ChannelClosed represents a channel which has been fully closed. Note
 	// that before a channel can be closed, ALL pending HTLCs must be
 	// settled/removed.
-	channelClosed
+	ChannelClosed
 
-	// channelDispute indicates that an un-cooperative closure has been
+	// ChannelDispute indicates that an un-cooperative closure has been
 	// detected within the channel.
-	channelDispute
-
-	// channelPendingPayment indicates that there a currently outstanding
-	// HTLCs within the channel.
-	channelPendingPayment // nolint:unused
+	ChannelDispute
 )
 
+// String returns a human readable representation of the channel state.
+func (c ChannelState) String() string {
+	switch c {
+	case ChannelOpen:
+		return "open"
+
+	case ChannelClosing:
+		return "closing"
+
+	case ChannelClosed:
+		return "closed"
+
+	case ChannelDispute:
+		return "dispute"
+
+	default:
+		return fmt.Sprintf("unknown<%d>", uint8(c))
+	}
+}
+
+// statusUpdateBufferSize is the number of status transitions that are
+// buffered for a consumer of StatusUpdates before older transitions are
+// dropped.
+const statusUpdateBufferSize = 10
+
 // PaymentHash represents the sha256 of a random value. This hash is used to
 // uniquely track incoming/outgoing payments within this channel, as well as
 // payments requested by the wallet/daemon.
 type PaymentHash [32]byte
 
+// PreimageObserver is a callback invoked with the payment hash and preimage of
+// an HTLC whenever the preimage becomes known to a channel.
+type PreimageObserver func(PaymentHash, [32]byte)
+
 // updateType is the exact type of an entry within the shared HTLC log.
 type updateType uint8
 
@@ -348,7 +507,9 @@ type PaymentDescriptor struct {
 	removeCommitHeightLocal  uint64
 
 	// OnionBlob is an opaque blob which is used to complete multi-hop
-	// routing.
+	// routing. It is always copied from the fixed size onion packet of
+	// an lnwire.UpdateAddHTLC or a channeldb.HTLC, so its length is
+	// lnwire.OnionPacketSize.
 	//
 	// NOTE: Populated only on add payment descriptor entry types.
 	OnionBlob []byte
@@ -393,6 +554,33 @@ type PaymentDescriptor struct {
 	isForwarded bool
 }
 
+// DebugString returns a single line representation of the payment descriptor,
+// including the unexported indexes and commit heights, for diagnostics.
+func (pd *PaymentDescriptor) DebugString() string {
+	var details string
+	switch pd.EntryType {
+	case Add:
+		details = fmt.Sprintf("htlc_index=%d amt=%v hash=%x timeout=%d",
+			pd.HtlcIndex, pd.Amount, pd.RHash[:], pd.Timeout)
+
+	case Settle, Fail, MalformedFail:
+		details = fmt.Sprintf("parent_index=%d amt=%v", pd.ParentIndex,
+			pd.Amount)
+
+	case FeeUpdate:
+		details = fmt.Sprintf("fee_rate=%v sat/kw",
+			int64(pd.Amount.ToSatoshis()))
+	}
+
+	return fmt.Sprintf("%v log_index=%d %v output_index(local=%d, "+
+		"remote=%d) add_height(local=%d, remote=%d) "+
+		"remove_height(local=%d, remote=%d)", pd.EntryType,
+		pd.LogIndex, details, pd.localOutputIndex,
+		pd.remoteOutputIndex, pd.addCommitHeightLocal,
+		pd.addCommitHeightRemote, pd.removeCommitHeightLocal,
+		pd.removeCommitHeightRemote)
+}
+
 // PayDescsFromRemoteLogUpdates converts a slice of LogUpdates received from the
 // remote peer into PaymentDescriptors to inform a link's forwarding decisions.
 //
@@ -791,6 +979,43 @@ func (c *commitment) toDiskCommit(ourCommit bool) *channeldb.ChannelCommitment {
 	return commit
 }
 
+// checkBalanceInvariant asserts that the balances of both parties, the
+// amounts of all HTLCs and the commitment fee, along with the value of the
+// anchors for anchor channels, add up to the capacity of the channel. An
+// ErrBalanceInvariant detailing the amounts is returned otherwise.
+func (c *commitment) checkBalanceInvariant(capacity btcutil.Amount,
+	chanType channeldb.ChannelType) error {
+
+	var htlcTotal lnwire.MilliSatoshi
+	for _, htlc := range c.outgoingHTLCs {
+		htlcTotal += htlc.Amount
+	}
+	for _, htlc := range c.incomingHTLCs {
+		htlcTotal += htlc.Amount
+	}
+
+	var anchors btcutil.Amount
+	if chanType.HasAnchors() {
+		anchors = 2 * anchorSize
+	}
+
+	total := c.ourBalance + c.theirBalance + htlcTotal +
+		lnwire.NewMSatFromSatoshis(c.fee+anchors)
+	if total == lnwire.NewMSatFromSatoshis(capacity) {
+		return nil
+	}
+
+	return ErrBalanceInvariant{
+		height:       c.height,
+		ourBalance:   c.ourBalance,
+		theirBalance: c.theirBalance,
+		htlcTotal:    htlcTotal,
+		fee:          c.fee,
+		anchors:      anchors,
+		capacity:     capacity,
+	}
+}
+
 // diskHtlcToPayDesc converts an HTLC previously written to disk within a
 // commitment state to the form required to manipulate in memory within the
 // commitment struct and updateLog. This function is used when we need to
@@ -1029,6 +1254,12 @@ func (s *commitmentChain) advanceTail() {
 	s.commitments.Remove(s.commitments.Front())
 }
 
+// removeTip removes the latest commitment added to the chain. This must only
+// be done for an unacked commitment.
+func (s *commitmentChain) removeTip() {
+	s.commitments.Remove(s.commitments.Back())
+}
+
 // tip returns the latest commitment added to the chain.
 func (s *commitmentChain) tip() *commitment {
 	return s.commitments.Back().Value.(*commitment)
@@ -1046,6 +1277,23 @@ func (s *commitmentChain) hasUnackedCommitment() bool {
 	return s.commitments.Front() != s.commitments.Back()
 }
 
+// numUnacked returns the number of commitments that follow the tail of the
+// chain, and thus haven't been ACKed yet.
+func (s *commitmentChain) numUnacked() int {
+	return s.commitments.Len() - 1
+}
+
+// next returns the commitment following the tail of the chain, which is the
+// one that becomes the new tail once the tail is revoked. If there are no
+// unacked commitments, the tail itself is returned.
+func (s *commitmentChain) next() *commitment {
+	if !s.hasUnackedCommitment() {
+		return s.tail()
+	}
+
+	return s.commitments.Front().Next().Value.(*commitment)
+}
+
 // updateLog is an append-only log that stores updates to a node's commitment
 // chain. This structure can be seen as the "mempool" within Lightning where
 // changes are stored before they're committed to the chain. Once an entry has
@@ -1087,6 +1335,44 @@ type updateLog struct {
 	// HTLC is one that's present in the log, and has as a pending fail or
 	// settle that's attempting to consume it.
 	modifiedHtlcs map[uint64]struct{}
+
+	// overflow, if set, is the store that HTLCs are spilled to once the
+	// log holds more than maxInMemory entries.
+	overflow updateLogOverflow
+
+	// maxInMemory is the number of entries above which HTLCs are spilled
+	// to the overflow store.
+	maxInMemory int
+
+	// incoming denotes whether the HTLCs added to this log are incoming
+	// from our point of view.
+	incoming bool
+
+	// spilledHtlcs maps the index of each HTLC that currently resides in
+	// the overflow store to an in-memory summary of it.
+	spilledHtlcs map[uint64]*spilledHtlc
+}
+
+// debugString returns a multi-line representation of the update log, listing
+// its counters and all entries along with whether an HTLC has a pending
+// modification.
+func (u *updateLog) debugString() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "log_index=%d htlc_counter=%d entries=%d "+
+		"spilled=%d\n", u.logIndex, u.htlcCounter, u.Len(),
+		u.numSpilledHtlcs())
+
+	for e := u.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+
+		modified := ""
+		if pd.EntryType == Add && u.htlcHasModification(pd.HtlcIndex) {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(&b, "  %v%v\n", pd.DebugString(), modified)
+	}
+
+	return b.String()
 }
 
 // newUpdateLog creates a new updateLog instance.
@@ -1098,6 +1384,7 @@ func newUpdateLog(logIndex, htlcCounter uint64) *updateLog {
 		logIndex:      logIndex,
 		htlcCounter:   htlcCounter,
 		modifiedHtlcs: make(map[uint64]struct{}),
+		spilledHtlcs:  make(map[uint64]*spilledHtlc),
 	}
 }
 
@@ -1138,11 +1425,24 @@ func (u *updateLog) appendHtlc(pd *PaymentDescriptor) {
 }
 
 // lookupHtlc attempts to look up an offered HTLC according to its offer
-// index. If the entry isn't found, then a nil pointer is returned.
+// index. If the HTLC was spilled to the overflow store, it's restored to the
+// log. If the entry isn't found, then a nil pointer is returned.
 func (u *updateLog) lookupHtlc(i uint64) *PaymentDescriptor {
 	htlc, ok := u.htlcIndex[i]
 	if !ok {
-		return nil
+		if _, spilled := u.spilledHtlcs[i]; !spilled {
+			return nil
+		}
+
+		pd, err := u.restoreSpilledHtlc(i)
+		if err != nil {
+			walletLog.Errorf("Unable to restore spilled htlc %v: "+
+				"%v", i, err)
+
+			return nil
+		}
+
+		return pd
 	}
 
 	return htlc.Value.(*PaymentDescriptor)
@@ -1276,7 +1576,14 @@ type LightningChannel struct {
 	// the commitment transaction that spends the multi-sig output.
 	signDesc *input.SignDescriptor
 
-	status channelState
+	// status is the current state of the channel. It should only be
+	// modified through setStatus, which notifies statusUpdates of any
+	// transition.
+	status ChannelState
+
+	// statusUpdates receives the new state of the channel on every
+	// transition.
+	statusUpdates chan ChannelState
 
 	// ChanPoint is the funding outpoint of this channel.
 	ChanPoint *wire.OutPoint
@@ -1344,6 +1651,81 @@ type LightningChannel struct {
 	// fundingOutput is the funding output (script+value).
 	fundingOutput wire.TxOut
 
+	// revocationWindow is the maximum number of commitments we'll extend
+	// to the remote party before we require a revocation from them.
+	revocationWindow uint16
+
+	// maxCommitWeight is the maximum weight of a commitment transaction
+	// that new HTLCs may result in.
+	maxCommitWeight int64
+
+	// maxDustExposure is the maximum total value of dust HTLCs we allow on
+	// either commitment. A value of zero disables the limit.
+	maxDustExposure lnwire.MilliSatoshi
+
+	// minCLTVDelta is the minimum number of blocks an incoming HTLC must
+	// have left until its expiry when received. A value of zero disables
+	// the check.
+	minCLTVDelta uint32
+
+	// feeFloorEstimator, if set, provides the minimum relay fee rate that
+	// fee updates from the remote party must meet.
+	feeFloorEstimator chainfee.Estimator
+
+	// feeFloorMargin is the percentage the relay fee floor is raised by
+	// before comparing it against fee updates from the remote party.
+	feeFloorMargin uint32
+
+	// strictChanSync indicates that ChannelReestablish messages with
+	// recovery fields that are inconsistent with the claimed heights are
+	// rejected.
+	strictChanSync bool
+
+	// rejectCircularHtlcs indicates that HTLCs whose payment hash is
+	// pending in the opposite direction of the channel are rejected,
+	// instead of only being flagged.
+	rejectCircularHtlcs bool
+
+	// metrics counts the state machine updates processed by the channel.
+	metrics ChannelMetrics
+
+	// commitPoints caches the secrets and points of our commitments that
+	// were recently derived from our revocation producer.
+	commitPoints *commitPointCache
+
+	// reserveObserver, if set, is notified when a party is below its
+	// reserve after settled HTLCs are locked in.
+	reserveObserver ReserveObserver
+
+	// preimageObserver, if set, is notified of every preimage that becomes
+	// known when settling an HTLC.
+	preimageObserver PreimageObserver
+
+	// lastCloseFee is the fee paid by the cooperative close transaction
+	// completed by CompleteCooperativeClose, if any.
+	lastCloseFee *btcutil.Amount
+
+	// localCloseFee is the fee of our outstanding close proposal created
+	// by CreateCloseProposal, if any.
+	localCloseFee *btcutil.Amount
+
+	// agreedCloseFee is the fee of the cooperative close transaction
+	// agreed upon by ResolveCloseProposal, if any.
+	agreedCloseFee *btcutil.Amount
+
+	// onionValidator, if set, validates the onion of every HTLC received
+	// from the remote party.
+	onionValidator OnionValidator
+
+	// rejectedHtlcs maps the indexes of the incoming HTLCs whose onion was
+	// rejected by the onion validator to the failure code they're failed
+	// back with once they're locked in.
+	rejectedHtlcs map[uint64]lnwire.FailCode
+
+	// stagedFails are the wire messages of the fails of rejected HTLCs
+	// that weren't returned by StagedFails yet.
+	stagedFails []lnwire.Message
+
 	sync.RWMutex
 }
 
@@ -1367,15 +1749,171 @@ func WithRemoteMusigNonces(nonces *musig2.Nonces) ChannelOpt {
 	}
 }
 
+// DefaultRevocationWindow is the default number of commitments we'll extend
+// to the remote party before requiring a revocation, as mandated by BOLT#2.
+const DefaultRevocationWindow = 1
+
+// WithRevocationWindow sets the maximum number of commitments we'll extend to
+// the remote party without having received a revocation for their prior
+// states. A window larger than one requires the remote party to hand out
+// additional commitment points, see AddRemoteCommitPoints.
+func WithRevocationWindow(window uint16) ChannelOpt {
+	return func(o *channelOpts) {
+		o.revocationWindow = window
+	}
+}
+
+// DefaultMaxCommitWeight is the default maximum weight of a commitment
+// transaction. It matches the standardness limit enforced by most nodes, as a
+// heavier commitment transaction wouldn't be relayed.
+const DefaultMaxCommitWeight = blockchain.MaxBlockWeight / 10
+
+// WithMaxCommitWeight sets the maximum weight of a commitment transaction.
+// Any new HTLC that would cause either commitment to exceed this weight is
+// rejected with ErrMaxWeightCost. Existing commitments aren't checked, so a
+// channel whose commitments exceed a lowered maximum can still be loaded and
+// have its HTLCs resolved.
+func WithMaxCommitWeight(weight int64) ChannelOpt {
+	return func(o *channelOpts) {
+		o.maxCommitWeight = weight
+	}
+}
+
+// WithMaxDustExposure sets the maximum total value of dust HTLCs that may be
+// pending on either commitment, see BOLT#2's max_dust_htlc_exposure_msat. As
+// dust HTLCs don't have an output on the commitment transaction, their value
+// is lost to miners if the channel is force closed while they're pending. Any
+// dust HTLC added by either party that would push the exposure above this
+// value is rejected with ErrDustExposureExceeded. By default the exposure
+// isn't limited.
+func WithMaxDustExposure(maxExposure lnwire.MilliSatoshi) ChannelOpt {
+	return func(o *channelOpts) {
+		o.maxDustExposure = maxExposure
+	}
+}
+
+// DefaultMaxCsvDelay is the default CSV delay of the to_self outputs of
+// either commitment above which a warning is logged when loading a channel.
+// It's well above the delays we negotiate by default, as the bound is only
+// meant to catch absurd values.
+const DefaultMaxCsvDelay = 10000
+
+// WithMaxCsvDelay sets the CSV delay of either party's to_self output above
+// which a warning is logged when loading the channel. The delays are enforced
+// when the channel is negotiated, see VerifyConstraints, so existing channels
+// can always be loaded.
+func WithMaxCsvDelay(maxDelay uint16) ChannelOpt {
+	return func(o *channelOpts) {
+		o.maxCsvDelay = maxDelay
+	}
+}
+
+// WithMinCLTVDelta sets the minimum number of blocks between the current height
+// and the expiry of an incoming HTLC. HTLCs received through
+// ReceiveHTLCAtHeight that expire sooner are rejected with an
+// ErrHtlcExpiryTooSoon error, as we wouldn't be able to safely forward them.
+func WithMinCLTVDelta(delta uint32) ChannelOpt {
+	return func(o *channelOpts) {
+		o.minCLTVDelta = delta
+	}
+}
+
+// WithRelayFeeFloor makes ReceiveUpdateFee reject fee updates from the remote
+// party with ErrFeeRateBelowFloor if the proposed fee rate is below the
+// minimum relay fee rate of the given estimator, raised by marginPercent
+// percent. Accepting such a fee rate would leave us with a commitment
+// transaction that can't be broadcast. As the relay fee rate may rise with
+// the mempool minimum fee, a margin allows rejecting fee rates that are
+// barely above it.
+func WithRelayFeeFloor(estimator chainfee.Estimator,
+	marginPercent uint32) ChannelOpt {
+
+	return func(o *channelOpts) {
+		o.feeFloorEstimator = estimator
+		o.feeFloorMargin = marginPercent
+	}
+}
+
+// WithStrictChanSync makes ProcessChanSyncMsg reject ChannelReestablish
+// messages whose recovery fields are inconsistent with the claimed heights
+// with ErrInconsistentChanSync, instead of treating missing recovery fields as
+// the remote party not supporting data loss protection. In particular, a
+// message claiming a non-zero RemoteCommitTailHeight must carry the last
+// commit secret we revealed to the remote party and their unrevoked commit
+// point, so their claim can be verified.
+func WithStrictChanSync() ChannelOpt {
+	return func(o *channelOpts) {
+		o.strictChanSync = true
+	}
+}
+
+// WithRejectCircularHtlcs makes AddHTLC and ReceiveHTLC reject HTLCs whose
+// payment hash matches an HTLC that is pending in the opposite direction of
+// the channel with ErrCircularHtlc. Such circular HTLCs are a sign of a
+// routing bug or an attack. Without this option, they're only flagged in the
+// log and counted in the channel metrics.
+func WithRejectCircularHtlcs() ChannelOpt {
+	return func(o *channelOpts) {
+		o.rejectCircularHtlcs = true
+	}
+}
+
 // channelOpts is the set of options used to create a new channel.
 type channelOpts struct {
 	localNonce  *musig2.Nonces
 	remoteNonce *musig2.Nonces
+
+	revocationWindow uint16
+
+	maxCommitWeight int64
+
+	maxDustExposure lnwire.MilliSatoshi
+
+	maxCsvDelay uint16
+
+	minCLTVDelta uint32
+
+	maxInMemoryUpdates int
+
+	feeFloorEstimator chainfee.Estimator
+	feeFloorMargin    uint32
+
+	strictChanSync bool
+
+	rejectCircularHtlcs bool
 }
 
 // defaultChannelOpts returns the set of default options for a new channel.
 func defaultChannelOpts() *channelOpts {
-	return &channelOpts{}
+	return &channelOpts{
+		revocationWindow: DefaultRevocationWindow,
+		maxCommitWeight:  DefaultMaxCommitWeight,
+		maxCsvDelay:      DefaultMaxCsvDelay,
+	}
+}
+
+// validateCsvDelays checks that the CSV delays of both parties are within the
+// range of 1 to maxDelay blocks. A delay of zero would allow the owner of a
+// revoked commitment to sweep their output before it can be penalized, while
+// an overly large delay would lock up funds for an unreasonable amount of
+// time after a force close.
+func validateCsvDelays(state *channeldb.OpenChannel, maxDelay uint16) error {
+	delays := []struct {
+		party string
+		delay uint16
+	}{
+		{"local", state.LocalChanCfg.CsvDelay},
+		{"remote", state.RemoteChanCfg.CsvDelay},
+	}
+	for _, d := range delays {
+		if d.delay == 0 || d.delay > maxDelay {
+			return fmt.Errorf("%w: %v csv delay of %v not in range "+
+				"[1, %v]", ErrInvalidCsvDelay, d.party, d.delay,
+				maxDelay)
+		}
+	}
+
+	return nil
 }
 
 // NewLightningChannel creates a new, active payment channel given an
@@ -1392,6 +1930,26 @@ func NewLightningChannel(signer input.Signer,
 		optFunc(opts)
 	}
 
+	// Each taproot commitment is signed with a fresh musig2 session,
+	// which requires a round trip with the remote party, so we can't
+	// pipeline commitments for such channels.
+	switch {
+	case opts.revocationWindow == 0:
+		return nil, fmt.Errorf("revocation window must be at least 1")
+
+	case opts.revocationWindow > 1 && state.ChanType.IsTaproot():
+		return nil, fmt.Errorf("revocation window of %v not supported "+
+			"for taproot channels", opts.revocationWindow)
+	}
+
+	// The delays were checked when the channel was negotiated. Refusing
+	// to load a channel here would leave its funds stuck, so we only
+	// warn about channels that slipped through with an absurd delay.
+	if err := validateCsvDelays(state, opts.maxCsvDelay); err != nil {
+		walletLog.Warnf("ChannelPoint(%v): %v", state.FundingOutpoint,
+			err)
+	}
+
 	localCommit := state.LocalCommitment
 	remoteCommit := state.RemoteCommitment
 
@@ -1403,6 +1961,8 @@ func NewLightningChannel(signer input.Signer,
 	remoteUpdateLog := newUpdateLog(
 		localCommit.RemoteLogIndex, localCommit.RemoteHtlcIndex,
 	)
+	localUpdateLog.setOverflow(state, false, opts.maxInMemoryUpdates)
+	remoteUpdateLog.setOverflow(state, true, opts.maxInMemoryUpdates)
 
 	logPrefix := fmt.Sprintf("ChannelPoint(%v):", state.FundingOutpoint)
 
@@ -1429,6 +1989,18 @@ func NewLightningChannel(signer input.Signer,
 		RemoteFundingKey:     state.RemoteChanCfg.MultiSigKey.PubKey,
 		taprootNonceProducer: taprootNonceProducer,
 		log:                  build.NewPrefixLog(logPrefix, walletLog),
+		revocationWindow:     opts.revocationWindow,
+		maxCommitWeight:      opts.maxCommitWeight,
+		maxDustExposure:      opts.maxDustExposure,
+		minCLTVDelta:         opts.minCLTVDelta,
+		feeFloorEstimator:    opts.feeFloorEstimator,
+		feeFloorMargin:       opts.feeFloorMargin,
+		strictChanSync:       opts.strictChanSync,
+		rejectCircularHtlcs:  opts.rejectCircularHtlcs,
+		status:               ChannelOpen,
+		statusUpdates:        make(chan ChannelState, statusUpdateBufferSize),
+		rejectedHtlcs:        make(map[uint64]lnwire.FailCode),
+		commitPoints:         newCommitPointCache(commitPointCacheSize),
 	}
 
 	switch {
@@ -1453,6 +2025,13 @@ func NewLightningChannel(signer input.Signer,
 		}
 	}
 
+	// Before restoring any state, we'll make sure the state hint
+	// obfuscator derived from the payment base points recovers the height
+	// of our latest local commitment.
+	if err := lc.validateStateHint(); err != nil {
+		return nil, err
+	}
+
 	// With the main channel struct reconstructed, we'll now restore the
 	// commitment state in memory and also the update logs themselves.
 	err = lc.restoreCommitState(&localCommit, &remoteCommit)
@@ -1470,6 +2049,73 @@ func NewLightningChannel(signer input.Signer,
 	return lc, nil
 }
 
+// NewChannelFromShell creates a new channel from the passed channel shell
+// alone, without any channel state from the database. The shell is typically
+// created from a static channel backup with chanbackup.NewChannelShell, which
+// re-derives our keys of the channel and our revocation producer. As the
+// channel doesn't have any commitment state, it can only be used to carry out
+// the data loss protection protocol with the remote party: we send them a
+// channel reestablish message, see State().ChanSyncMsg, which makes them force
+// close the channel, and ProcessChanSyncMsg returns the commitment point we
+// need to sweep our funds from their commitment. Any attempt to update the
+// channel state is rejected with ErrRestoredChannel.
+//
+// NOTE: The channel is never written to disk, and no sig pool is set up, as
+// the channel can't sign any commitment.
+func NewChannelFromShell(chanShell *channeldb.ChannelShell,
+	signer input.Signer, chanOpts ...ChannelOpt) (*LightningChannel,
+	error) {
+
+	chanShell.MarkRestored()
+
+	return NewLightningChannel(signer, chanShell.Chan, nil, chanOpts...)
+}
+
+// validateStateHint ensures the state hint carried by our latest local
+// commitment transaction decodes to the commitment height we have on disk. If
+// it doesn't, the state hint obfuscator derived from the payment base points
+// is likely misconfigured, and we wouldn't be able to recognize our
+// commitments on chain. Channels without a stored commitment transaction,
+// e.g. restored ones, are skipped.
+func (lc *LightningChannel) validateStateHint() error {
+	commitTx := lc.channelState.LocalCommitment.CommitTx
+	if commitTx == nil || len(commitTx.TxIn) != 1 {
+		return nil
+	}
+
+	height := lc.channelState.LocalCommitment.CommitHeight
+	hint := GetStateNumHint(commitTx, lc.commitBuilder.obfuscator)
+	if hint != height {
+		lc.log.Errorf("State hint of local commitment %v decodes to "+
+			"height %v, expected %v", commitTx.TxHash(), hint,
+			height)
+
+		return fmt.Errorf("%w: commitment %v decodes to height %v, "+
+			"expected %v", ErrStateHintMismatch, commitTx.TxHash(),
+			hint, height)
+	}
+
+	return nil
+}
+
+// CommitmentStateHint returns the commitment height encoded within the
+// locktime and sequence of the passed commitment transaction, using the state
+// hint obfuscator of the channel. As both parties derive the same obfuscator,
+// this works for commitment transactions of either party.
+func (lc *LightningChannel) CommitmentStateHint(tx *wire.MsgTx) (uint64,
+	error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	if tx == nil || len(tx.TxIn) != 1 {
+		return 0, fmt.Errorf("commitment transaction must have " +
+			"exactly one input")
+	}
+
+	return GetStateNumHint(tx, lc.commitBuilder.obfuscator), nil
+}
+
 // createSignDesc derives the SignDescriptor for commitment transactions from
 // other fields on the LightningChannel.
 func (lc *LightningChannel) createSignDesc() error {
@@ -1525,10 +2171,104 @@ func (lc *LightningChannel) createSignDesc() error {
 // events do so properly.
 func (lc *LightningChannel) ResetState() {
 	lc.Lock()
-	lc.status = channelOpen
+	lc.setStatus(ChannelOpen)
 	lc.Unlock()
 }
 
+// PrepareForReconnect resets the transient state of the channel that's bound
+// to a single connection with the remote party, such that the channel can be
+// re-synchronized using ProcessChanSyncMsg after a reconnection. Unlike
+// ResetState, the status of the channel is left untouched, so a channel that's
+// closing stays closing.
+//
+// The following state is reset:
+//   - For taproot channels, the musig2 sessions bound to the nonces of the
+//     prior connection are discarded, and a fresh verification nonce is
+//     generated to be exchanged within the next channel reestablishment.
+//
+// The following state is preserved:
+//   - The local and remote commitment chains, including any remote
+//     commitments that haven't been revoked yet, which will be retransmitted
+//     by ProcessChanSyncMsg if the remote party didn't receive them.
+//   - The local and remote update logs, including any updates that haven't
+//     been locked in yet.
+//   - The status of the channel.
+//
+// Signature and verification jobs are only in flight while the channel mutex
+// is held, so there are no outstanding jobs to cancel once this method is able
+// to acquire it.
+func (lc *LightningChannel) PrepareForReconnect() error {
+	lc.Lock()
+	defer lc.Unlock()
+
+	if !lc.channelState.ChanType.IsTaproot() {
+		return nil
+	}
+
+	lc.musigSessions = nil
+
+	var err error
+	lc.pendingVerificationNonce, err = channeldb.NewMusigVerificationNonce(
+		lc.channelState.LocalChanCfg.MultiSigKey.PubKey,
+		lc.currentHeight+1, lc.taprootNonceProducer,
+	)
+
+	return err
+}
+
+// setStatus transitions the channel to the given state and notifies the
+// status updates channel. The notification never blocks: if the consumer
+// isn't keeping up, the oldest buffered transition is dropped to make room
+// for the new one, such that the latest state is always delivered.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) setStatus(status ChannelState) {
+	if lc.status == status {
+		return
+	}
+
+	lc.log.Debugf("channel state transition: %v -> %v", lc.status,
+		status)
+
+	lc.status = status
+
+	select {
+	case lc.statusUpdates <- status:
+		return
+	default:
+	}
+
+	// The buffer is full, so we'll drop the oldest transition. As we hold
+	// the channel's lock, we're the only sender, so there's room for the
+	// new state afterwards unless the consumer drained the buffer in the
+	// meantime, which is fine as well.
+	select {
+	case <-lc.statusUpdates:
+	default:
+	}
+	select {
+	case lc.statusUpdates <- status:
+	default:
+	}
+}
+
+// Status returns the current state of the channel.
+func (lc *LightningChannel) Status() ChannelState {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.status
+}
+
+// StatusUpdates returns a channel that receives the new state of the channel
+// on every state transition, e.g. once a cooperative close is initiated or a
+// force close is carried out. The channel is buffered, and the state machine
+// never blocks on it. If the consumer falls behind, the oldest transitions
+// are dropped, so the last value received always reflects the current state.
+func (lc *LightningChannel) StatusUpdates() <-chan ChannelState {
+	return lc.statusUpdates
+}
+
 // logUpdateToPayDesc converts a LogUpdate into a matching PaymentDescriptor
 // entry that can be re-inserted into the update log. This method is used when
 // we extended a state to the remote party, but the connection was obstructed
@@ -1853,13 +2593,10 @@ func (lc *LightningChannel) restoreCommitState(
 	// outputs (if any) we'll need to regenerate the current revocation for
 	// this current un-revoked state as well as retrieve the current
 	// revocation for the remote party.
-	ourRevPreImage, err := lc.channelState.RevocationProducer.AtIndex(
-		lc.currentHeight,
-	)
+	localCommitPoint, err := lc.commitPointAt(lc.currentHeight)
 	if err != nil {
 		return err
 	}
-	localCommitPoint := input.ComputeCommitmentPoint(ourRevPreImage[:])
 	remoteCommitPoint := lc.channelState.RemoteCurrentRevocation
 
 	// With the revocation state reconstructed, we can now convert the disk
@@ -1896,27 +2633,47 @@ func (lc *LightningChannel) restoreCommitState(
 		}),
 	)
 
-	var (
-		pendingRemoteCommit     *commitment
-		pendingRemoteCommitDiff *channeldb.CommitDiff
-		pendingRemoteKeyChain   *CommitmentKeyRing
-	)
+	// A channel restored from a backup never had any updates, so there
+	// are no pending commitments or updates to restore. As the channel
+	// may not even have a database, we return early.
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return nil
+	}
 
-	// Next, we'll check to see if we have an un-acked commitment state we
-	// extended to the remote party but which was never ACK'd.
-	pendingRemoteCommitDiff, err = lc.channelState.RemoteCommitChainTip()
-	if err != nil && err != channeldb.ErrNoPendingCommit {
+	// Next, we'll check to see if we have any un-acked commitment states
+	// we extended to the remote party but which were never ACK'd.
+	pendingRemoteCommitDiffs, err := lc.channelState.RemoteCommitChainPending()
+	if err != nil {
 		return err
 	}
 
-	if pendingRemoteCommitDiff != nil {
+	var (
+		pendingRemoteCommits   []*commitment
+		pendingRemoteKeyChains []*CommitmentKeyRing
+		pendingCommitPoints    = append(
+			[]*btcec.PublicKey{lc.channelState.RemoteNextRevocation},
+			lc.channelState.RemoteCommitPoints...,
+		)
+	)
+	for i, pendingRemoteCommitDiff := range pendingRemoteCommitDiffs {
+		// Each pending commitment consumed one of the commitment
+		// points of the remote party, starting with their next
+		// revocation.
+		if i >= len(pendingCommitPoints) ||
+			pendingCommitPoints[i] == nil {
+
+			return fmt.Errorf("missing commitment point for pending "+
+				"remote commitment at height %v",
+				pendingRemoteCommitDiff.Commitment.CommitHeight)
+		}
+		pendingCommitPoint := pendingCommitPoints[i]
+
 		// If we have a pending remote commitment, then we'll also
 		// reconstruct the original commitment for that state,
 		// inserting it into the remote party's commitment chain. We
 		// don't pass our commit point as we don't have the
 		// corresponding state for the local commitment chain.
-		pendingCommitPoint := lc.channelState.RemoteNextRevocation
-		pendingRemoteCommit, err = lc.diskCommitToMemCommit(
+		pendingRemoteCommit, err := lc.diskCommitToMemCommit(
 			false, &pendingRemoteCommitDiff.Commitment,
 			nil, pendingCommitPoint,
 		)
@@ -1933,10 +2690,17 @@ func (lc *LightningChannel) restoreCommitState(
 
 		// We'll also re-create the set of commitment keys needed to
 		// fully re-derive the state.
-		pendingRemoteKeyChain = DeriveCommitmentKeys(
+		pendingRemoteKeyChain := DeriveCommitmentKeys(
 			pendingCommitPoint, false, lc.channelState.ChanType,
 			&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
 		)
+
+		pendingRemoteCommits = append(
+			pendingRemoteCommits, pendingRemoteCommit,
+		)
+		pendingRemoteKeyChains = append(
+			pendingRemoteKeyChains, pendingRemoteKeyChain,
+		)
 	}
 
 	// Fetch remote updates that we have acked but not yet signed for.
@@ -1953,10 +2717,10 @@ func (lc *LightningChannel) restoreCommitState(
 
 	// Finally, with the commitment states restored, we'll now restore the
 	// state logs based on the current local+remote commit, and any pending
-	// remote commit that exists.
+	// remote commits that exist.
 	err = lc.restoreStateLogs(
-		localCommit, remoteCommit, pendingRemoteCommit,
-		pendingRemoteCommitDiff, pendingRemoteKeyChain,
+		localCommit, remoteCommit, pendingRemoteCommits,
+		pendingRemoteCommitDiffs, pendingRemoteKeyChains,
 		unsignedAckedUpdates, remoteUnsignedLocalUpdates,
 	)
 	if err != nil {
@@ -1971,9 +2735,10 @@ func (lc *LightningChannel) restoreCommitState(
 // remote) for each HTLC read from disk. This method is required to sync the
 // in-memory state of the state machine with that read from persistent storage.
 func (lc *LightningChannel) restoreStateLogs(
-	localCommitment, remoteCommitment, pendingRemoteCommit *commitment,
-	pendingRemoteCommitDiff *channeldb.CommitDiff,
-	pendingRemoteKeys *CommitmentKeyRing,
+	localCommitment, remoteCommitment *commitment,
+	pendingRemoteCommits []*commitment,
+	pendingRemoteCommitDiffs []*channeldb.CommitDiff,
+	pendingRemoteKeys []*CommitmentKeyRing,
 	unsignedAckedUpdates,
 	remoteUnsignedLocalUpdates []channeldb.LogUpdate) error {
 
@@ -1985,10 +2750,12 @@ func (lc *LightningChannel) restoreStateLogs(
 	outgoingLocalAddHeights := make(map[uint64]uint64)
 
 	// We start by setting the height of the incoming HTLCs on the pending
-	// remote commitment. We set these heights first since if there are
-	// duplicates, these will be overwritten by the lower height of the
-	// remoteCommitment below.
-	if pendingRemoteCommit != nil {
+	// remote commitments, starting with the highest one. We set these
+	// heights first since if there are duplicates, these will be
+	// overwritten by the lower heights of the prior pending commitments
+	// and the remoteCommitment below.
+	for i := len(pendingRemoteCommits) - 1; i >= 0; i-- {
+		pendingRemoteCommit := pendingRemoteCommits[i]
 		for _, r := range pendingRemoteCommit.incomingHTLCs {
 			incomingRemoteAddHeights[r.HtlcIndex] =
 				pendingRemoteCommit.height
@@ -2094,11 +2861,11 @@ func (lc *LightningChannel) restoreStateLogs(
 		lc.localUpdateLog.restoreHtlc(&htlc)
 	}
 
-	// If we have a dangling (un-acked) commit for the remote party, then we
-	// restore the updates leading up to this commit.
-	if pendingRemoteCommit != nil {
+	// If we have any dangling (un-acked) commits for the remote party, then
+	// we restore the updates leading up to each of these commits in order.
+	for i, pendingRemoteCommitDiff := range pendingRemoteCommitDiffs {
 		err := lc.restorePendingLocalUpdates(
-			pendingRemoteCommitDiff, pendingRemoteKeys,
+			pendingRemoteCommitDiff, pendingRemoteKeys[i],
 		)
 		if err != nil {
 			return err
@@ -2109,7 +2876,7 @@ func (lc *LightningChannel) restoreStateLogs(
 	// in our next signature.
 	err := lc.restorePendingRemoteUpdates(
 		unsignedAckedUpdates, localCommitment.height,
-		pendingRemoteCommit,
+		pendingRemoteCommits,
 	)
 	if err != nil {
 		return err
@@ -2127,7 +2894,7 @@ func (lc *LightningChannel) restoreStateLogs(
 func (lc *LightningChannel) restorePendingRemoteUpdates(
 	unsignedAckedUpdates []channeldb.LogUpdate,
 	localCommitmentHeight uint64,
-	pendingRemoteCommit *commitment) error {
+	pendingRemoteCommits []*commitment) error {
 
 	lc.log.Debugf("Restoring %v dangling remote updates",
 		len(unsignedAckedUpdates))
@@ -2168,11 +2935,14 @@ func (lc *LightningChannel) restorePendingRemoteUpdates(
 		// If we have a pending commitment for them, and this update
 		// is included in that commit, then we'll use this commitment
 		// height as this commitment will include these updates for
-		// their new remote commitment.
-		if pendingRemoteCommit != nil {
+		// their new remote commitment. If there are multiple pending
+		// commitments, the lowest one including the update is used.
+		for _, pendingRemoteCommit := range pendingRemoteCommits {
 			if logIdx < pendingRemoteCommit.theirMessageIndex {
 				height = pendingRemoteCommit.height
 				heightSet = true
+
+				break
 			}
 		}
 
@@ -2892,18 +3662,391 @@ func createBreachRetributionLegacy(revokedLog *channeldb.ChannelCommitment,
 	}, ourAmt, theirAmt, nil
 }
 
-// HtlcIsDust determines if an HTLC output is dust or not depending on two
-// bits: if the HTLC is incoming and if the HTLC will be placed on our
-// commitment transaction, or theirs. These two pieces of information are
-// require as we currently used second-level HTLC transactions as off-chain
-// covenants. Depending on the two bits, we'll either be using a timeout or
-// success transaction which have different weights.
-func HtlcIsDust(chanType channeldb.ChannelType,
-	incoming, ourCommit bool, feePerKw chainfee.SatPerKWeight,
-	htlcAmt, dustLimit btcutil.Amount) bool {
+// AuditRevocationState checks that the revocation state of the channel is
+// consistent with the current commitment heights:
+//
+//   - the in-memory commitment chains start at the persisted heights.
+//   - the revocation store holds the secrets of exactly the remote commitments
+//     below the current one.
+//   - the current remote commitment point isn't one that was already revoked.
+//   - our current commitment was built using the commitment point derived
+//     from our revocation producer at its height.
+//
+// An ErrRevocationStateMismatch error detailing the divergence is returned if
+// any of these checks fail.
+func (lc *LightningChannel) AuditRevocationState() error {
+	lc.RLock()
+	defer lc.RUnlock()
 
-	// First we'll determine the fee required for this HTLC based on if this is
-	// an incoming HTLC or not, and also on whose commitment transaction it
+	chanState := lc.channelState
+
+	// Restored channels don't have any commitment state, so there's
+	// nothing to check.
+	if chanState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return nil
+	}
+
+	localHeight := chanState.LocalCommitment.CommitHeight
+	remoteHeight := chanState.RemoteCommitment.CommitHeight
+
+	if tail := lc.localCommitChain.tail().height; tail != localHeight {
+		return fmt.Errorf("%w: local commit chain tail at height %v, "+
+			"local commitment at height %v",
+			ErrRevocationStateMismatch, tail, localHeight)
+	}
+	if tail := lc.remoteCommitChain.tail().height; tail != remoteHeight {
+		return fmt.Errorf("%w: remote commit chain tail at height %v, "+
+			"remote commitment at height %v",
+			ErrRevocationStateMismatch, tail, remoteHeight)
+	}
+
+	return lc.checkRevocationState(
+		chanState.RevocationProducer, chanState.RevocationStore,
+	)
+}
+
+// checkRevocationState checks that the passed revocation producer and store
+// are consistent with the current commitment heights of the channel. See
+// AuditRevocationState for the checks carried out.
+//
+// NOTE: This method requires the channel lock to be held.
+func (lc *LightningChannel) checkRevocationState(producer shachain.Producer,
+	store shachain.Store) error {
+
+	chanState := lc.channelState
+	localHeight := chanState.LocalCommitment.CommitHeight
+	remoteHeight := chanState.RemoteCommitment.CommitHeight
+
+	// The remote party revealed the secrets of all their commitments
+	// below the current one, but not of the current one itself.
+	_, err := store.LookUp(remoteHeight)
+	if err == nil {
+		return fmt.Errorf("%w: revocation store contains secret for "+
+			"unrevoked remote height %v",
+			ErrRevocationStateMismatch, remoteHeight)
+	}
+	if remoteHeight > 0 {
+		lastSecret, err := store.LookUp(remoteHeight - 1)
+		if err != nil {
+			return fmt.Errorf("%w: revocation store missing secret "+
+				"for revoked remote height %v: %v",
+				ErrRevocationStateMismatch, remoteHeight-1, err)
+		}
+
+		// If the current remote commitment point matches the one of
+		// the last revoked state, the points weren't rotated after
+		// the last revocation.
+		lastPoint := input.ComputeCommitmentPoint(lastSecret[:])
+		current := chanState.RemoteCurrentRevocation
+		if current != nil && current.IsEqual(lastPoint) {
+			return fmt.Errorf("%w: current remote commitment point "+
+				"belongs to revoked height %v",
+				ErrRevocationStateMismatch, remoteHeight-1)
+		}
+	}
+
+	// Finally, we'll make sure that our revocation producer yields the
+	// commitment point our current commitment was created with, by
+	// checking that the to_local output derived from it is present. If
+	// our output was trimmed, there's nothing we can compare against.
+	localCommit := &chanState.LocalCommitment
+	localBalance := localCommit.LocalBalance.ToSatoshis()
+	if localBalance < chanState.LocalChanCfg.DustLimit {
+		return nil
+	}
+
+	commitPoint, err := lc.commitPoints.point(producer, localHeight)
+	if err != nil {
+		return fmt.Errorf("%w: unable to derive commitment secret for "+
+			"local height %v: %v", ErrRevocationStateMismatch,
+			localHeight, err)
+	}
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, true, chanState.ChanType,
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+	)
+
+	var leaseExpiry uint32
+	if chanState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+	toLocalScript, err := CommitScriptToSelf(
+		chanState.ChanType, chanState.IsInitiator, keyRing.ToLocalKey,
+		keyRing.RevocationKey, uint32(chanState.LocalChanCfg.CsvDelay),
+		leaseExpiry,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, txOut := range localCommit.CommitTx.TxOut {
+		if bytes.Equal(txOut.PkScript, toLocalScript.PkScript()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: commitment point from revocation producer "+
+		"doesn't match local commitment at height %v",
+		ErrRevocationStateMismatch, localHeight)
+}
+
+// revocationStateVersion is the version of the serialization format used by
+// ExportRevocationState.
+const revocationStateVersion uint8 = 0
+
+// ExportRevocationState serializes the revocation producer and revocation
+// store of the channel, such that the channel can be migrated to another node
+// or replica using ImportRevocationState.
+//
+// NOTE: The serialized state includes the root of our revocation producer,
+// which allows deriving the secrets of all our commitments. It must be
+// handled with the same care as the channel database itself.
+func (lc *LightningChannel) ExportRevocationState() ([]byte, error) {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	var b bytes.Buffer
+	if err := b.WriteByte(revocationStateVersion); err != nil {
+		return nil, err
+	}
+
+	err := channeldb.WriteElements(
+		&b, lc.channelState.RevocationProducer,
+		lc.channelState.RevocationStore,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// ImportRevocationState replaces the revocation producer and revocation store
+// of the channel with the ones serialized by ExportRevocationState, and
+// persists them. This allows a channel whose revocation state is missing or
+// lags behind its commitments, e.g. on a replica, to catch up. The imported
+// state must be consistent with the commitments of the channel as checked by
+// AuditRevocationState, otherwise ErrRevocationStateMismatch is returned and
+// the channel is left untouched.
+func (lc *LightningChannel) ImportRevocationState(state []byte) error {
+	lc.Lock()
+	defer lc.Unlock()
+
+	chanState := lc.channelState
+	if chanState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return ErrRestoredChannel
+	}
+
+	r := bytes.NewReader(state)
+	version, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if version != revocationStateVersion {
+		return fmt.Errorf("unknown revocation state version %v",
+			version)
+	}
+
+	var (
+		producer shachain.Producer
+		store    shachain.Store
+	)
+	if err := channeldb.ReadElements(r, &producer, &store); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("%v trailing bytes in revocation state",
+			r.Len())
+	}
+
+	if err := lc.checkRevocationState(producer, store); err != nil {
+		return err
+	}
+
+	// If our to_local output is trimmed, the checks above can't verify
+	// the imported producer against our commitment. Unless the channel
+	// doesn't have a producer yet, we'll make sure it yields the same
+	// secrets as the existing one instead.
+	localCommit := &chanState.LocalCommitment
+	localBalance := localCommit.LocalBalance.ToSatoshis()
+	if localBalance < chanState.LocalChanCfg.DustLimit &&
+		chanState.RevocationProducer != nil {
+
+		localHeight := localCommit.CommitHeight
+		ourSecret, err := lc.commitSecretAt(localHeight)
+		if err != nil {
+			return err
+		}
+		secret, err := producer.AtIndex(localHeight)
+		if err != nil {
+			return err
+		}
+		if *secret != *ourSecret {
+			return fmt.Errorf("%w: imported revocation producer "+
+				"diverges at local height %v",
+				ErrRevocationStateMismatch, localHeight)
+		}
+	}
+
+	// The existing store may lag behind, but the secrets it holds must
+	// be the ones of the imported store. Secrets of different shachains
+	// don't collide, so it's enough to compare the latest one.
+	if chanState.RevocationStore != nil {
+		numSecrets := numStoreSecrets(
+			chanState.RevocationStore,
+			chanState.RemoteCommitment.CommitHeight,
+		)
+		if numSecrets > 0 {
+			height := numSecrets - 1
+			ourSecret, err := chanState.RevocationStore.LookUp(
+				height,
+			)
+			if err != nil {
+				return err
+			}
+			secret, err := store.LookUp(height)
+			if err != nil {
+				return err
+			}
+			if *secret != *ourSecret {
+				return fmt.Errorf("%w: imported revocation "+
+					"store diverges at remote height %v",
+					ErrRevocationStateMismatch, height)
+			}
+		}
+	}
+
+	return chanState.ReplaceRevocationState(producer, store)
+}
+
+// numStoreSecrets returns the number of consecutive secrets, starting at
+// height zero, that the passed store holds, up to maxSecrets.
+func numStoreSecrets(store shachain.Store, maxSecrets uint64) uint64 {
+	// Secrets are added in order, so the store holds the secrets of all
+	// heights below the number we're looking for, and none above.
+	return uint64(sort.Search(int(maxSecrets), func(i int) bool {
+		_, err := store.LookUp(uint64(i))
+		return err != nil
+	}))
+}
+
+// RevokedStateInfo houses the material needed to sweep the commitment outputs
+// of a single revoked remote commitment, should it ever be broadcast.
+type RevokedStateInfo struct {
+	// Height is the height of the revoked remote commitment.
+	Height uint64
+
+	// CommitSecret is the revocation secret the remote party handed us
+	// for this commitment.
+	CommitSecret chainhash.Hash
+
+	// CommitPoint is the commitment point derived from CommitSecret.
+	CommitPoint *btcec.PublicKey
+
+	// KeyRing contains the keys used to construct the scripts of the
+	// revoked commitment.
+	KeyRing *CommitmentKeyRing
+
+	// TheirScript is the to-local script of the remote party, which we
+	// can sweep using the revocation clause.
+	TheirScript input.ScriptDescriptor
+
+	// OurScript is the to-remote script paying to us.
+	OurScript input.ScriptDescriptor
+
+	// OurDelay is the CSV delay on OurScript, which is non-zero for
+	// anchor channels.
+	OurDelay uint32
+}
+
+// RevokedStateSweepInfo returns the sweep material of all revoked remote
+// commitments within the given (inclusive) range of heights, using the
+// revocation secrets from the channel's RevocationStore. Only heights below
+// the current remote commitment height can be requested, otherwise
+// ErrRevokedHeightOutOfRange is returned.
+func (lc *LightningChannel) RevokedStateSweepInfo(fromHeight,
+	toHeight uint64) ([]RevokedStateInfo, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	chanState := lc.channelState
+
+	// All remote commitments below the current one have been revoked,
+	// so the secrets for those are the only ones in the store.
+	numRevoked := chanState.RemoteCommitment.CommitHeight
+	if fromHeight > toHeight || toHeight >= numRevoked {
+		return nil, fmt.Errorf("%w: requested heights [%v, %v], "+
+			"revoked heights [0, %d)", ErrRevokedHeightOutOfRange,
+			fromHeight, toHeight, numRevoked)
+	}
+
+	var leaseExpiry uint32
+	if chanState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+	isRemoteInitiator := !chanState.IsInitiator
+	theirDelay := uint32(chanState.RemoteChanCfg.CsvDelay)
+
+	infos := make([]RevokedStateInfo, 0, toHeight-fromHeight+1)
+	for height := fromHeight; height <= toHeight; height++ {
+		commitSecret, err := chanState.RevocationStore.LookUp(height)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up revocation "+
+				"secret for height %v: %w", height, err)
+		}
+		commitPoint := input.ComputeCommitmentPoint(commitSecret[:])
+
+		// These are the same scripts NewBreachRetribution derives
+		// when the remote party broadcasts this revoked state.
+		keyRing := DeriveCommitmentKeys(
+			commitPoint, false, chanState.ChanType,
+			&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+		)
+		ourScript, ourDelay, err := CommitScriptToRemote(
+			chanState.ChanType, isRemoteInitiator,
+			keyRing.ToRemoteKey, leaseExpiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+		theirScript, err := CommitScriptToSelf(
+			chanState.ChanType, isRemoteInitiator,
+			keyRing.ToLocalKey, keyRing.RevocationKey, theirDelay,
+			leaseExpiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, RevokedStateInfo{
+			Height:       height,
+			CommitSecret: *commitSecret,
+			CommitPoint:  commitPoint,
+			KeyRing:      keyRing,
+			TheirScript:  theirScript,
+			OurScript:    ourScript,
+			OurDelay:     ourDelay,
+		})
+	}
+
+	return infos, nil
+}
+
+// HtlcIsDust determines if an HTLC output is dust or not depending on two
+// bits: if the HTLC is incoming and if the HTLC will be placed on our
+// commitment transaction, or theirs. These two pieces of information are
+// require as we currently used second-level HTLC transactions as off-chain
+// covenants. Depending on the two bits, we'll either be using a timeout or
+// success transaction which have different weights. The weights are taken from
+// the HtlcTxWeights profile of the channel type, so the dust boundary differs
+// between e.g. legacy and anchor channels at the same fee rate.
+func HtlcIsDust(chanType channeldb.ChannelType,
+	incoming, ourCommit bool, feePerKw chainfee.SatPerKWeight,
+	htlcAmt, dustLimit btcutil.Amount) bool {
+
+	// First we'll determine the fee required for this HTLC based on if this is
+	// an incoming HTLC or not, and also on whose commitment transaction it
 	// will be placed on.
 	var htlcFee btcutil.Amount
 	switch {
@@ -2945,9 +4088,45 @@ type htlcView struct {
 
 // fetchHTLCView returns all the candidate HTLC updates which should be
 // considered for inclusion within a commitment based on the passed HTLC log
-// indexes.
+// indexes. HTLCs that were spilled to the overflow store of either log are
+// included through their in-memory summaries, which suffice to evaluate
+// balances, fees and dust, but lack the data needed to build the commitment
+// outputs. Use fetchCommitHTLCView for the latter.
 func (lc *LightningChannel) fetchHTLCView(theirLogIndex, ourLogIndex uint64) *htlcView {
-	var ourHTLCs []*PaymentDescriptor
+	return lc.buildHTLCView(
+		lc.localUpdateLog.spilledHtlcSummaries(),
+		lc.remoteUpdateLog.spilledHtlcSummaries(),
+		theirLogIndex, ourLogIndex,
+	)
+}
+
+// fetchCommitHTLCView is like fetchHTLCView, but reads HTLCs that were spilled
+// to the overflow store back in full, as needed to build a commitment.
+func (lc *LightningChannel) fetchCommitHTLCView(theirLogIndex,
+	ourLogIndex uint64) (*htlcView, error) {
+
+	ourSpilled, err := lc.localUpdateLog.fetchSpilledHtlcs()
+	if err != nil {
+		return nil, err
+	}
+	theirSpilled, err := lc.remoteUpdateLog.fetchSpilledHtlcs()
+	if err != nil {
+		return nil, err
+	}
+
+	return lc.buildHTLCView(
+		ourSpilled, theirSpilled, theirLogIndex, ourLogIndex,
+	), nil
+}
+
+// buildHTLCView extends the passed spilled HTLCs of both logs with the
+// entries of the in-memory logs that are active at the passed log indexes.
+func (lc *LightningChannel) buildHTLCView(ourHTLCs,
+	theirHTLCs []*PaymentDescriptor, theirLogIndex,
+	ourLogIndex uint64) *htlcView {
+
+	// Spilled HTLCs are older than any entry remaining in the logs, so
+	// we start out with them to keep the view ordered by log index.
 	for e := lc.localUpdateLog.Front(); e != nil; e = e.Next() {
 		htlc := e.Value.(*PaymentDescriptor)
 
@@ -2959,7 +4138,6 @@ func (lc *LightningChannel) fetchHTLCView(theirLogIndex, ourLogIndex uint64) *ht
 		}
 	}
 
-	var theirHTLCs []*PaymentDescriptor
 	for e := lc.remoteUpdateLog.Front(); e != nil; e = e.Next() {
 		htlc := e.Value.(*PaymentDescriptor)
 
@@ -2983,7 +4161,9 @@ func (lc *LightningChannel) fetchHTLCView(theirLogIndex, ourLogIndex uint64) *ht
 // both local and remote commitment transactions in order to sign or verify new
 // commitment updates. A fully populated commitment is returned which reflects
 // the proper balances for both sides at this point in the commitment chain.
-func (lc *LightningChannel) fetchCommitmentView(remoteChain bool,
+// If mutateState is false, the update logs aren't modified, which allows the
+// commitment to be previewed without extending the commitment chain.
+func (lc *LightningChannel) fetchCommitmentView(remoteChain, mutateState bool,
 	ourLogIndex, ourHtlcIndex, theirLogIndex, theirHtlcIndex uint64,
 	keyRing *CommitmentKeyRing) (*commitment, error) {
 
@@ -3001,9 +4181,12 @@ func (lc *LightningChannel) fetchCommitmentView(remoteChain bool,
 	// the balances on the commitment transaction accordingly. Note that
 	// these balances will be *before* taking a commitment fee from the
 	// initiator.
-	htlcView := lc.fetchHTLCView(theirLogIndex, ourLogIndex)
+	htlcView, err := lc.fetchCommitHTLCView(theirLogIndex, ourLogIndex)
+	if err != nil {
+		return nil, err
+	}
 	ourBalance, theirBalance, _, filteredHTLCView, err := lc.computeView(
-		htlcView, remoteChain, true,
+		htlcView, remoteChain, mutateState,
 	)
 	if err != nil {
 		return nil, err
@@ -3144,14 +4327,6 @@ func (lc *LightningChannel) evaluateHTLCView(view *htlcView, ourBalance,
 			continue
 		}
 
-		// If we're settling an inbound HTLC, and it hasn't been
-		// processed yet, then increment our state tracking the total
-		// number of satoshis we've received within the channel.
-		if mutateState && entry.EntryType == Settle && !remoteChain &&
-			entry.removeCommitHeightLocal == 0 {
-			lc.channelState.TotalMSatReceived += entry.Amount
-		}
-
 		addEntry, err := lc.fetchParent(entry, remoteChain, true)
 		if err != nil {
 			return nil, err
@@ -3176,15 +4351,6 @@ func (lc *LightningChannel) evaluateHTLCView(view *htlcView, ourBalance,
 			continue
 		}
 
-		// If the remote party is settling one of our outbound HTLC's,
-		// and it hasn't been processed, yet, the increment our state
-		// tracking the total number of satoshis we've sent within the
-		// channel.
-		if mutateState && entry.EntryType == Settle && !remoteChain &&
-			entry.removeCommitHeightLocal == 0 {
-			lc.channelState.TotalMSatSent += entry.Amount
-		}
-
 		addEntry, err := lc.fetchParent(entry, remoteChain, false)
 		if err != nil {
 			return nil, err
@@ -3687,6 +4853,13 @@ func (lc *LightningChannel) createCommitDiff(
 		logUpdates = append(logUpdates, logUpdate)
 	}
 
+	// The remote party expects the updates to be retransmitted in the
+	// order they were originally sent, so we'll sort them by their log
+	// index rather than relying on the order of the update log.
+	sort.Slice(logUpdates, func(i, j int) bool {
+		return logUpdates[i].LogIndex < logUpdates[j].LogIndex
+	})
+
 	// With the set of log updates mapped into wire messages, we'll now
 	// convert the in-memory commit into a format suitable for writing to
 	// disk.
@@ -3799,6 +4972,14 @@ func (lc *LightningChannel) getUnsignedAckedUpdates() []channeldb.LogUpdate {
 	return logUpdates
 }
 
+// allowsZeroValueHtlcs returns true if the given channel type permits HTLCs
+// that don't carry any value. None of the currently defined channel types do,
+// as such HTLCs only add degenerate entries to the commitment, but a future
+// channel type (e.g. for AMP placeholder shards) may opt in here.
+func allowsZeroValueHtlcs(_ channeldb.ChannelType) bool {
+	return false
+}
+
 // validateCommitmentSanity is used to validate the current state of the
 // commitment transaction in terms of the ChannelConstraints that we and our
 // remote peer agreed upon during the funding workflow. The
@@ -3861,6 +5042,16 @@ func (lc *LightningChannel) validateCommitmentSanity(theirLogCounter,
 			ErrBelowChanReserve)
 	}
 
+	// Make sure the resulting commitment transaction can still be relayed
+	// across the network. This is only enforced for new HTLCs, so that
+	// commitments of existing channels that already exceed a lowered
+	// maximum can still be signed, e.g. to resolve their HTLCs.
+	isAdd := predictOurAdd != nil || predictTheirAdd != nil
+	if isAdd && commitWeight > lc.maxCommitWeight {
+		return fmt.Errorf("%w: weight %v exceeds maximum of %v",
+			ErrMaxWeightCost, commitWeight, lc.maxCommitWeight)
+	}
+
 	// Ensure that the fee being applied is enough to be relayed across the
 	// network in a reasonable time frame.
 	if feePerKw < chainfee.FeePerKwFloor {
@@ -3910,8 +5101,13 @@ func (lc *LightningChannel) validateCommitmentSanity(theirLogCounter,
 				amtInFlight += entry.Amount
 				numInFlight++
 
-				// Check that the HTLC amount is positive.
-				if entry.Amount == 0 {
+				// Check that the HTLC amount is positive,
+				// unless the channel type explicitly permits
+				// zero-value HTLCs.
+				if entry.Amount == 0 && !allowsZeroValueHtlcs(
+					lc.channelState.ChanType,
+				) {
+
 					return ErrInvalidHTLCAmt
 				}
 
@@ -3988,6 +5184,25 @@ type NewCommitState struct {
 	PendingHTLCs []channeldb.HTLC
 }
 
+// nextRemoteCommitPoint returns the commitment point of the remote party for
+// the next commitment we'll extend to them, taking into account any
+// commitments that are still awaiting a revocation. Nil is returned if the
+// point isn't known yet.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) nextRemoteCommitPoint() *btcec.PublicKey {
+	unacked := lc.remoteCommitChain.numUnacked()
+	if unacked == 0 {
+		return lc.channelState.RemoteNextRevocation
+	}
+
+	if unacked > len(lc.channelState.RemoteCommitPoints) {
+		return nil
+	}
+
+	return lc.channelState.RemoteCommitPoints[unacked-1]
+}
+
 // SignNextCommitment signs a new commitment which includes any previous
 // unsettled HTLCs, any new HTLCs, and any modifications to prior HTLCs
 // committed in previous commitment updates. Signing a new commitment
@@ -4003,76 +5218,403 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 	lc.Lock()
 	defer lc.Unlock()
 
-	// Check for empty commit sig. This should never happen, but we don't
-	// dare to fail hard here. We assume peers can deal with the empty sig
-	// and continue channel operation. We log an error so that the bug
-	// causing this can be tracked down.
-	if !lc.oweCommitment(true) {
-		lc.log.Errorf("sending empty commit sig")
-	}
+	newCommitState, _, err := lc.signNextCommitment()
 
-	var (
-		sig        lnwire.Sig
-		partialSig *lnwire.PartialSigWithNonce
-		htlcSigs   []lnwire.Sig
-	)
+	return newCommitState, err
+}
 
-	// If we're awaiting for an ACK to a commitment signature, or if we
-	// don't yet have the initial next revocation point of the remote
-	// party, then we're unable to create new states. Each time we create a
-	// new state, we consume a prior revocation point.
-	commitPoint := lc.channelState.RemoteNextRevocation
-	unacked := lc.remoteCommitChain.hasUnackedCommitment()
-	if unacked || commitPoint == nil {
-		lc.log.Tracef("waiting for remote ack=%v, nil "+
-			"RemoteNextRevocation: %v", unacked, commitPoint == nil)
-		return nil, ErrNoWindow
-	}
+// CommitmentView is a read-only snapshot of a commitment transaction of the
+// channel. The balances are from our point of view, regardless of which
+// party's commitment this is.
+type CommitmentView struct {
+	// Height is the height of the commitment.
+	Height uint64
 
-	// Determine the last update on the remote log that has been locked in.
-	remoteACKedIndex := lc.localCommitChain.tail().theirMessageIndex
-	remoteHtlcIndex := lc.localCommitChain.tail().theirHtlcIndex
+	// Txid is the hash of the commitment transaction.
+	Txid chainhash.Hash
 
-	// Before we extend this new commitment to the remote commitment chain,
-	// ensure that we aren't violating any of the constraints the remote
-	// party set up when we initially set up the channel. If we are, then
-	// we'll abort this state transition.
-	err := lc.validateCommitmentSanity(
-		remoteACKedIndex, lc.localUpdateLog.logIndex, true, nil, nil,
-	)
-	if err != nil {
-		return nil, err
+	// LocalBalance is our balance on the commitment, after subtracting
+	// the commitment fee and anchors if we're the initiator.
+	LocalBalance lnwire.MilliSatoshi
+
+	// RemoteBalance is the balance of the remote party on the commitment,
+	// after subtracting the commitment fee and anchors if they're the
+	// initiator.
+	RemoteBalance lnwire.MilliSatoshi
+
+	// Fee is the fee paid by the commitment transaction.
+	Fee btcutil.Amount
+
+	// FeePerKw is the fee rate the commitment fee is based on.
+	FeePerKw chainfee.SatPerKWeight
+
+	// NumOutgoingHTLCs is the number of HTLCs we offered on the
+	// commitment, including dust HTLCs.
+	NumOutgoingHTLCs int
+
+	// NumIncomingHTLCs is the number of HTLCs offered to us on the
+	// commitment, including dust HTLCs.
+	NumIncomingHTLCs int
+}
+
+// newCommitmentView creates a read-only snapshot of the passed commitment.
+func newCommitmentView(c *commitment) *CommitmentView {
+	return &CommitmentView{
+		Height:           c.height,
+		Txid:             c.txn.TxHash(),
+		LocalBalance:     c.ourBalance,
+		RemoteBalance:    c.theirBalance,
+		Fee:              c.fee,
+		FeePerKw:         c.feePerKw,
+		NumOutgoingHTLCs: len(c.outgoingHTLCs),
+		NumIncomingHTLCs: len(c.incomingHTLCs),
 	}
+}
 
-	// Grab the next commitment point for the remote party. This will be
-	// used within fetchCommitmentView to derive all the keys necessary to
-	// construct the commitment state.
-	keyRing := DeriveCommitmentKeys(
-		commitPoint, false, lc.channelState.ChanType,
-		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
-	)
+// SignNextCommitmentWithView is identical to SignNextCommitment, but also
+// returns a snapshot of the new remote commitment the signatures are for. This
+// saves callers that are interested in the new state, e.g. for logging or
+// metrics, from having to re-derive it.
+func (lc *LightningChannel) SignNextCommitmentWithView() (*NewCommitState,
+	*CommitmentView, error) {
 
-	// Create a new commitment view which will calculate the evaluated
-	// state of the remote node's new commitment including our latest added
-	// HTLCs. The view includes the latest balances for both sides on the
-	// remote node's chain, and also update the addition height of any new
-	// HTLC log entries. When we creating a new remote view, we include
-	// _all_ of our changes (pending or committed) but only the remote
-	// node's changes up to the last change we've ACK'd.
-	newCommitView, err := lc.fetchCommitmentView(
-		true, lc.localUpdateLog.logIndex, lc.localUpdateLog.htlcCounter,
-		remoteACKedIndex, remoteHtlcIndex, keyRing,
-	)
+	lc.Lock()
+	defer lc.Unlock()
+
+	newCommitState, newCommitView, err := lc.signNextCommitment()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	lc.log.Tracef("extending remote chain to height %v, "+
-		"local_log=%v, remote_log=%v",
-		newCommitView.height,
-		lc.localUpdateLog.logIndex, remoteACKedIndex)
+	return newCommitState, newCommitmentView(newCommitView), nil
+}
 
-	lc.log.Tracef("remote chain: our_balance=%v, "+
+// CommitOutputType describes what an output on a commitment transaction pays
+// to, from our point of view.
+type CommitOutputType uint8
+
+const (
+	// CommitOutputOurBalance is the output paying out our settled
+	// balance.
+	CommitOutputOurBalance CommitOutputType = iota
+
+	// CommitOutputTheirBalance is the output paying out the remote
+	// party's settled balance.
+	CommitOutputTheirBalance
+
+	// CommitOutputOurAnchor is the anchor output spendable by us.
+	CommitOutputOurAnchor
+
+	// CommitOutputTheirAnchor is the anchor output spendable by the
+	// remote party.
+	CommitOutputTheirAnchor
+
+	// CommitOutputIncomingHTLC is an HTLC output offered to us by the
+	// remote party.
+	CommitOutputIncomingHTLC
+
+	// CommitOutputOutgoingHTLC is an HTLC output we offered to the remote
+	// party.
+	CommitOutputOutgoingHTLC
+)
+
+// String returns a human readable name of the output type.
+func (c CommitOutputType) String() string {
+	switch c {
+	case CommitOutputOurBalance:
+		return "OurBalance"
+	case CommitOutputTheirBalance:
+		return "TheirBalance"
+	case CommitOutputOurAnchor:
+		return "OurAnchor"
+	case CommitOutputTheirAnchor:
+		return "TheirAnchor"
+	case CommitOutputIncomingHTLC:
+		return "IncomingHTLC"
+	case CommitOutputOutgoingHTLC:
+		return "OutgoingHTLC"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(c))
+	}
+}
+
+// HTLCRef references what an output of a commitment transaction corresponds
+// to, either one of the HTLCs or one of the balance or anchor outputs.
+type HTLCRef struct {
+	// Type is the kind of the output.
+	Type CommitOutputType
+
+	// HtlcIndex is the index of the HTLC in the update log of the party
+	// that offered it. This is only set for HTLC outputs.
+	HtlcIndex uint64
+
+	// RHash is the payment hash of the HTLC. This is only set for HTLC
+	// outputs.
+	RHash PaymentHash
+
+	// Amount is the value of the output.
+	Amount btcutil.Amount
+}
+
+// CommitmentOutputMap returns a map from the output index of the latest
+// commitment transaction of either the local or remote chain, after BIP 69
+// sorting, to what that output pays to. This can be used to build watchtower
+// blobs or to inspect a commitment without re-deriving its scripts.
+func (lc *LightningChannel) CommitmentOutputMap(
+	remoteChain bool) (map[int]HTLCRef, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	chanState := lc.channelState
+
+	commitChain := lc.localCommitChain
+	ownerCfg, otherCfg := &chanState.LocalChanCfg, &chanState.RemoteChanCfg
+	ownerInitiator := chanState.IsInitiator
+	if remoteChain {
+		commitChain = lc.remoteCommitChain
+		ownerCfg, otherCfg = otherCfg, ownerCfg
+		ownerInitiator = !ownerInitiator
+	}
+	commit := commitChain.tip()
+
+	// To tell apart the non-HTLC outputs, we'll re-derive the scripts
+	// used for the commitment, which requires the commitment point of
+	// its owner at this height.
+	var (
+		commitPoint *btcec.PublicKey
+		err         error
+	)
+	if remoteChain {
+		commitPoint, err = lc.remoteCommitPoint(commit.height)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		commitPoint, err = lc.commitPointAt(commit.height)
+		if err != nil {
+			return nil, err
+		}
+	}
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, !remoteChain, chanState.ChanType,
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+	)
+
+	var leaseExpiry uint32
+	if chanState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+	toLocalScript, err := CommitScriptToSelf(
+		chanState.ChanType, ownerInitiator, keyRing.ToLocalKey,
+		keyRing.RevocationKey, uint32(ownerCfg.CsvDelay), leaseExpiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+	toRemoteScript, _, err := CommitScriptToRemote(
+		chanState.ChanType, ownerInitiator, keyRing.ToRemoteKey,
+		leaseExpiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The to_local output of the commitment pays to its owner, so we
+	// need to flip the meaning of the outputs for the remote commitment.
+	scriptTypes := map[string]CommitOutputType{
+		string(toLocalScript.PkScript()):  CommitOutputOurBalance,
+		string(toRemoteScript.PkScript()): CommitOutputTheirBalance,
+	}
+	if remoteChain {
+		scriptTypes = map[string]CommitOutputType{
+			string(toLocalScript.PkScript()):  CommitOutputTheirBalance,
+			string(toRemoteScript.PkScript()): CommitOutputOurBalance,
+		}
+	}
+
+	if chanState.ChanType.HasAnchors() {
+		ownerAnchor, otherAnchor, err := CommitScriptAnchors(
+			chanState.ChanType, ownerCfg, otherCfg, keyRing,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		ownerType, otherType := CommitOutputOurAnchor,
+			CommitOutputTheirAnchor
+		if remoteChain {
+			ownerType, otherType = otherType, ownerType
+		}
+		scriptTypes[string(ownerAnchor.PkScript())] = ownerType
+		scriptTypes[string(otherAnchor.PkScript())] = otherType
+	}
+
+	outputMap := make(map[int]HTLCRef, len(commit.txn.TxOut))
+
+	// The HTLC outputs were already located when the commitment was
+	// created, so we can use the output indexes stored in the payment
+	// descriptors.
+	addHtlcs := func(htlcs []PaymentDescriptor,
+		outputType CommitOutputType) {
+
+		for _, htlc := range htlcs {
+			outputIndex := htlc.localOutputIndex
+			if remoteChain {
+				outputIndex = htlc.remoteOutputIndex
+			}
+
+			// Dust HTLCs don't have an output on the commitment.
+			if outputIndex < 0 {
+				continue
+			}
+
+			outputMap[int(outputIndex)] = HTLCRef{
+				Type:      outputType,
+				HtlcIndex: htlc.HtlcIndex,
+				RHash:     htlc.RHash,
+				Amount:    htlc.Amount.ToSatoshis(),
+			}
+		}
+	}
+	addHtlcs(commit.incomingHTLCs, CommitOutputIncomingHTLC)
+	addHtlcs(commit.outgoingHTLCs, CommitOutputOutgoingHTLC)
+
+	for i, txOut := range commit.txn.TxOut {
+		if _, ok := outputMap[i]; ok {
+			continue
+		}
+
+		outputType, ok := scriptTypes[string(txOut.PkScript)]
+		if !ok {
+			return nil, fmt.Errorf("unable to identify output %v of "+
+				"commitment at height %v", i, commit.height)
+		}
+
+		outputMap[i] = HTLCRef{
+			Type:   outputType,
+			Amount: btcutil.Amount(txOut.Value),
+		}
+	}
+
+	return outputMap, nil
+}
+
+// remoteCommitPoint returns the commitment point of the remote party that was
+// used to create their commitment at the given height. This is either their
+// current commitment, or one of the pending commitments we've extended to
+// them.
+func (lc *LightningChannel) remoteCommitPoint(
+	height uint64) (*btcec.PublicKey, error) {
+
+	currentHeight := lc.channelState.RemoteCommitment.CommitHeight
+	switch {
+	case height == currentHeight:
+		return lc.channelState.RemoteCurrentRevocation, nil
+
+	case height == currentHeight+1:
+		return lc.channelState.RemoteNextRevocation, nil
+
+	// Any pending commitments after the first one were created with the
+	// additional points they handed out to extend our revocation window.
+	case height > currentHeight+1 &&
+		height-currentHeight-2 <
+			uint64(len(lc.channelState.RemoteCommitPoints)):
+
+		return lc.channelState.RemoteCommitPoints[height-currentHeight-2],
+			nil
+
+	default:
+		return nil, fmt.Errorf("no commitment point for remote "+
+			"commitment %v", height)
+	}
+}
+
+// signNextCommitment signs a new commitment for the remote party and extends
+// their commitment chain with it, see SignNextCommitment. The new commitment
+// is returned along with the signatures.
+//
+// NOTE: This method MUST be called with the channel's mutex held.
+func (lc *LightningChannel) signNextCommitment() (*NewCommitState,
+	*commitment, error) {
+
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return nil, nil, ErrRestoredChannel
+	}
+
+	// Check for empty commit sig. This should never happen, but we don't
+	// dare to fail hard here. We assume peers can deal with the empty sig
+	// and continue channel operation. We log an error so that the bug
+	// causing this can be tracked down.
+	if !lc.oweCommitment(true) {
+		lc.log.Errorf("sending empty commit sig")
+	}
+
+	var (
+		sig        lnwire.Sig
+		partialSig *lnwire.PartialSigWithNonce
+		htlcSigs   []lnwire.Sig
+	)
+
+	// If we're awaiting ACKs for as many commitment signatures as our
+	// revocation window allows, or if we don't yet have the commitment
+	// point of the remote party for the next state, then we're unable to
+	// create new states. Each time we create a new state, we consume a
+	// prior revocation point.
+	commitPoint := lc.nextRemoteCommitPoint()
+	unacked := lc.remoteCommitChain.numUnacked()
+	if unacked >= int(lc.revocationWindow) || commitPoint == nil {
+		lc.log.Tracef("waiting for remote ack, unacked=%v, "+
+			"window=%v, nil commit point: %v", unacked,
+			lc.revocationWindow, commitPoint == nil)
+		return nil, nil, ErrNoWindow
+	}
+
+	// Determine the last update on the remote log that has been locked in.
+	remoteACKedIndex := lc.localCommitChain.tail().theirMessageIndex
+	remoteHtlcIndex := lc.localCommitChain.tail().theirHtlcIndex
+
+	// Before we extend this new commitment to the remote commitment chain,
+	// ensure that we aren't violating any of the constraints the remote
+	// party set up when we initially set up the channel. If we are, then
+	// we'll abort this state transition.
+	err := lc.validateCommitmentSanity(
+		remoteACKedIndex, lc.localUpdateLog.logIndex, true, nil, nil,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Grab the next commitment point for the remote party. This will be
+	// used within fetchCommitmentView to derive all the keys necessary to
+	// construct the commitment state.
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, false, lc.channelState.ChanType,
+		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
+	)
+
+	// Create a new commitment view which will calculate the evaluated
+	// state of the remote node's new commitment including our latest added
+	// HTLCs. The view includes the latest balances for both sides on the
+	// remote node's chain, and also update the addition height of any new
+	// HTLC log entries. When we creating a new remote view, we include
+	// _all_ of our changes (pending or committed) but only the remote
+	// node's changes up to the last change we've ACK'd.
+	newCommitView, err := lc.fetchCommitmentView(
+		true, true, lc.localUpdateLog.logIndex,
+		lc.localUpdateLog.htlcCounter, remoteACKedIndex,
+		remoteHtlcIndex, keyRing,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lc.log.Tracef("extending remote chain to height %v, "+
+		"local_log=%v, remote_log=%v",
+		newCommitView.height,
+		lc.localUpdateLog.logIndex, remoteACKedIndex)
+
+	lc.log.Tracef("remote chain: our_balance=%v, "+
 		"their_balance=%v, commit_tx: %v",
 		newCommitView.ourBalance,
 		newCommitView.theirBalance,
@@ -4095,7 +5637,11 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 		&lc.channelState.RemoteChanCfg, newCommitView,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	signCtx := lc.signContext(newCommitView.height, false)
+	for i := range sigBatch {
+		sigBatch[i].SignCtx = signCtx
 	}
 	lc.sigPool.SubmitSignBatch(sigBatch)
 
@@ -4114,7 +5660,7 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 		)
 		if err != nil {
 			close(cancelChan)
-			return nil, err
+			return nil, nil, err
 		}
 
 		partialSig = musig.ToWireSig()
@@ -4122,17 +5668,15 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 		lc.signDesc.SigHashes = input.NewTxSigHashesV0Only(
 			newCommitView.txn,
 		)
-		rawSig, err := lc.Signer.SignOutputRaw(
-			newCommitView.txn, lc.signDesc,
-		)
+		rawSig, err := lc.signCommitTx(newCommitView.txn, signCtx)
 		if err != nil {
 			close(cancelChan)
-			return nil, err
+			return nil, nil, err
 		}
 		sig, err = lnwire.NewSigFromSignature(rawSig)
 		if err != nil {
 			close(cancelChan)
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -4153,7 +5697,7 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 		// jobs.
 		if jobResp.Err != nil {
 			close(cancelChan)
-			return nil, jobResp.Err
+			return nil, nil, jobResp.Err
 		}
 
 		htlcSigs = append(htlcSigs, jobResp.Sig)
@@ -4164,11 +5708,11 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 	// can retransmit it if necessary.
 	commitDiff, err := lc.createCommitDiff(newCommitView, sig, htlcSigs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	err = lc.channelState.AppendRemoteCommitChain(commitDiff)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// TODO(roasbeef): check that one eclair bug
@@ -4178,6 +5722,7 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 	// Extend the remote commitment chain by one with the addition of our
 	// latest commitment update.
 	lc.remoteCommitChain.addCommitment(newCommitView)
+	lc.metrics.CommitmentsSigned++
 
 	return &NewCommitState{
 		CommitSigs: &CommitSigs{
@@ -4186,7 +5731,137 @@ func (lc *LightningChannel) SignNextCommitment() (*NewCommitState, error) {
 			PartialSig: partialSig,
 		},
 		PendingHTLCs: commitDiff.Commitment.Htlcs,
-	}, nil
+	}, newCommitView, nil
+}
+
+// DiscardPendingRemoteCommit discards the latest commitment we signed for the
+// remote party, as long as they haven't revoked their prior commitment yet.
+// This is meant for the case where we persisted the commitment, but crashed
+// before sending it, and decided not to resend it afterwards, e.g. because
+// the remote party is gone. The commitment is removed from disk and the tip of
+// the remote commitment chain is rewound to the prior commitment.
+//
+// As unsigned updates are dropped on reconnection, our updates covered by
+// the commitment are removed from the update log as well, such that the log
+// matches the state we'd restore after a restart. HTLCs we added this way are
+// never offered, so the caller must fail them back. The commitment can't be
+// discarded if it settles or fails HTLCs, as the forwarding packages were
+// already acked when it was persisted, or if we added updates after signing
+// it. Taproot channels aren't supported, as the musig2 nonces used for the
+// signature can't be reused.
+//
+// NOTE: Discarding a commitment the remote party received is unsafe. Once
+// discarded, we're unable to retransmit it, and we can't sweep the outputs of
+// its HTLCs if the remote party broadcasts it.
+func (lc *LightningChannel) DiscardPendingRemoteCommit() error {
+	lc.Lock()
+	defer lc.Unlock()
+
+	if !lc.remoteCommitChain.hasUnackedCommitment() {
+		return ErrNoPendingRemoteCommit
+	}
+
+	if lc.channelState.ChanType.IsTaproot() {
+		return fmt.Errorf("%w: taproot channels aren't supported",
+			ErrCannotDiscardRemoteCommit)
+	}
+
+	// The commitment prior to the tip is the one the remote chain is
+	// rewound to.
+	tip := lc.remoteCommitChain.tip()
+	prevElem := lc.remoteCommitChain.commitments.Back().Prev()
+	prevTip := prevElem.Value.(*commitment)
+
+	if lc.localUpdateLog.logIndex != tip.ourMessageIndex {
+		return fmt.Errorf("%w: updates were added after signing",
+			ErrCannotDiscardRemoteCommit)
+	}
+
+	// Collect our updates that were first signed for by the tip, which
+	// are the ones we'll remove from the log.
+	var discarded []*PaymentDescriptor
+	for e := lc.localUpdateLog.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.LogIndex < prevTip.ourMessageIndex {
+			continue
+		}
+
+		switch pd.EntryType {
+		case Settle, Fail, MalformedFail:
+			return fmt.Errorf("%w: commitment removes htlc %d",
+				ErrCannotDiscardRemoteCommit, pd.ParentIndex)
+		}
+
+		discarded = append(discarded, pd)
+	}
+
+	err := lc.channelState.DiscardRemoteCommitChainTip(tip.height)
+	if err != nil {
+		return err
+	}
+
+	for _, pd := range discarded {
+		if pd.EntryType == Add {
+			lc.localUpdateLog.removeHtlc(pd.HtlcIndex)
+		} else {
+			lc.localUpdateLog.removeUpdate(pd.LogIndex)
+		}
+	}
+	lc.localUpdateLog.logIndex = prevTip.ourMessageIndex
+	lc.localUpdateLog.htlcCounter = prevTip.ourHtlcIndex
+
+	// The remaining entries may have been processed for the remote chain
+	// when signing the tip, which must be undone for them to be included
+	// in the next commitment again.
+	resetHeights := func(log *updateLog) {
+		for e := log.Front(); e != nil; e = e.Next() {
+			pd := e.Value.(*PaymentDescriptor)
+			if pd.addCommitHeightRemote == tip.height {
+				pd.addCommitHeightRemote = 0
+			}
+			if pd.removeCommitHeightRemote == tip.height {
+				pd.removeCommitHeightRemote = 0
+			}
+		}
+	}
+	resetHeights(lc.localUpdateLog)
+	resetHeights(lc.remoteUpdateLog)
+
+	lc.remoteCommitChain.removeTip()
+
+	lc.log.Infof("Discarded pending remote commitment at height %v, "+
+		"removed %v local updates", tip.height, len(discarded))
+
+	return nil
+}
+
+// validateChanSyncRecovery checks that the data loss protection fields of the
+// passed ChannelReestablish message are consistent with the heights it claims.
+// Either both the last commit secret and the unrevoked commit point are set,
+// or neither is. The last commit secret must be zero for a commit tail height
+// of zero, as no secret was revealed yet, and a non-zero commit tail height
+// can only be verified if the recovery fields are set.
+func validateChanSyncRecovery(msg *lnwire.ChannelReestablish) error {
+	var zeroSecret [32]byte
+	hasSecret := msg.LastRemoteCommitSecret != zeroSecret
+	hasPoint := msg.LocalUnrevokedCommitPoint != nil
+
+	switch {
+	case hasSecret && !hasPoint:
+		return fmt.Errorf("%w: last commit secret set without "+
+			"unrevoked commit point", ErrInconsistentChanSync)
+
+	case !hasPoint && msg.RemoteCommitTailHeight != 0:
+		return fmt.Errorf("%w: remote commit tail height %v claimed "+
+			"without recovery fields", ErrInconsistentChanSync,
+			msg.RemoteCommitTailHeight)
+
+	case hasSecret && msg.RemoteCommitTailHeight == 0:
+		return fmt.Errorf("%w: last commit secret set for remote "+
+			"commit tail height 0", ErrInconsistentChanSync)
+	}
+
+	return nil
 }
 
 // ProcessChanSyncMsg processes a ChannelReestablish message sent by the remote
@@ -4224,6 +5899,15 @@ func (lc *LightningChannel) ProcessChanSyncMsg(
 		closedCircuits []models.CircuitKey
 	)
 
+	// In strict mode, we first make sure the recovery fields are
+	// consistent with the heights the remote party claims.
+	if lc.strictChanSync {
+		if err := validateChanSyncRecovery(msg); err != nil {
+			lc.log.Errorf("sync failed: %v", err)
+			return nil, nil, nil, err
+		}
+	}
+
 	// If the remote party included the optional fields, then we'll verify
 	// their correctness first, as it will influence our decisions below.
 	hasRecoveryOptions := msg.LocalUnrevokedCommitPoint != nil
@@ -4231,7 +5915,7 @@ func (lc *LightningChannel) ProcessChanSyncMsg(
 		// We'll check that they've really sent a valid commit
 		// secret from our shachain for our prior height, but only if
 		// this isn't the first state.
-		heightSecret, err := lc.channelState.RevocationProducer.AtIndex(
+		heightSecret, err := lc.commitSecretAt(
 			msg.RemoteCommitTailHeight - 1,
 		)
 		if err != nil {
@@ -4430,67 +6114,107 @@ func (lc *LightningChannel) ProcessChanSyncMsg(
 	// They have received our latest commitment, life is good.
 	case msg.NextLocalCommitHeight == remoteTipHeight+1:
 
-	// We owe them a commitment if the tip of their chain (from our Pov) is
-	// equal to what they think their next commit height should be. We'll
-	// re-send all the updates necessary to recreate this state, along
-	// with the commit sig.
-	case msg.NextLocalCommitHeight == remoteTipHeight:
+	// We owe them one or more commitments if the tip of their chain (from
+	// our Pov) is equal to or beyond what they think their next commit
+	// height should be. We'll re-send all the updates necessary to
+	// recreate these states, along with the commit sigs.
+	case msg.NextLocalCommitHeight <= remoteTipHeight:
 		lc.log.Debugf("sync: remote's next commit height is %v, while "+
 			"we believe it is %v, we owe them a commitment",
 			msg.NextLocalCommitHeight, remoteTipHeight+1)
 
-		// Grab the current remote chain tip from the database.  This
-		// commit diff contains all the information required to re-sync
-		// our states.
-		commitDiff, err := lc.channelState.RemoteCommitChainTip()
+		// Grab the pending remote commitments from the database.
+		// These commit diffs contain all the information required to
+		// re-sync our states.
+		commitDiffs, err := lc.channelState.RemoteCommitChainPending()
 		if err != nil {
 			return nil, nil, nil, err
 		}
 
-		var commitUpdates []lnwire.Message
+		// Any commitment signed after the revocation we may owe them
+		// commits to all the remote updates we've ACK'd so far. We use
+		// this to figure out where the revocation has to be placed in
+		// between the retransmitted commitments.
+		localACKedIndex := lc.localCommitChain.tail().theirMessageIndex
 
-		// Next, we'll need to send over any updates we sent as part of
-		// this new proposed commitment state.
-		for _, logUpdate := range commitDiff.LogUpdates {
-			commitUpdates = append(commitUpdates, logUpdate.UpdateMsg)
-		}
+		var (
+			commitUpdates []lnwire.Message
+			revocationIdx = -1
+		)
+		for _, commitDiff := range commitDiffs {
+			// Skip any commitments they've already received.
+			height := commitDiff.Commitment.CommitHeight
+			if height < msg.NextLocalCommitHeight {
+				continue
+			}
 
-		// With the batch of updates accumulated, we'll now re-send the
-		// original CommitSig message required to re-sync their remote
-		// commitment chain with our local version of their chain.
-		//
-		// TODO(roasbeef): need to re-sign commitment states w/
-		// fresh nonce
-		commitUpdates = append(commitUpdates, commitDiff.CommitSig)
+			if revocationIdx == -1 &&
+				commitDiff.Commitment.RemoteLogIndex ==
+					localACKedIndex {
 
-		// NOTE: If a revocation is not owed, then updates is empty.
-		if lc.channelState.LastWasRevoke {
-			// If lastWasRevoke is set to true, a revocation was last and we
-			// need to reorder the updates so that the revocation stored in
-			// updates comes after the LogUpdates+CommitSig.
-			//
-			// ---logupdates--->
-			// ---commitsig---->
-			// ---revocation--->
-			updates = append(commitUpdates, updates...)
-		} else {
-			// Otherwise, the revocation should come before LogUpdates
-			// + CommitSig.
+				revocationIdx = len(commitUpdates)
+			}
+
+			// Next, we'll need to send over any updates we sent as
+			// part of this new proposed commitment state.
+			for _, logUpdate := range commitDiff.LogUpdates {
+				commitUpdates = append(
+					commitUpdates, logUpdate.UpdateMsg,
+				)
+			}
+
+			// With the batch of updates accumulated, we'll now
+			// re-send the original CommitSig message required to
+			// re-sync their remote commitment chain with our local
+			// version of their chain.
 			//
-			// ---revocation--->
-			// ---logupdates--->
-			// ---commitsig---->
-			updates = append(updates, commitUpdates...)
-		}
+			// TODO(roasbeef): need to re-sign commitment states w/
+			// fresh nonce
+			commitUpdates = append(commitUpdates, commitDiff.CommitSig)
 
-		openedCircuits = commitDiff.OpenedCircuitKeys
-		closedCircuits = commitDiff.ClosedCircuitKeys
+			openedCircuits = append(
+				openedCircuits, commitDiff.OpenedCircuitKeys...,
+			)
+			closedCircuits = append(
+				closedCircuits, commitDiff.ClosedCircuitKeys...,
+			)
+		}
 
-	// There should be no other possible states as long as the commit chain
-	// can have at most two elements. If that's the case, something is
-	// wrong.
-	default:
-		lc.log.Errorf("sync failed: remote's next commit height is %v, "+
+		// NOTE: If a revocation is not owed, then updates is empty.
+		switch {
+		// If lastWasRevoke is set to true, a revocation was last and we
+		// need to reorder the updates so that the revocation stored in
+		// updates comes after the LogUpdates+CommitSig.
+		//
+		// ---logupdates--->
+		// ---commitsig---->
+		// ---revocation--->
+		case lc.channelState.LastWasRevoke:
+			revocationIdx = len(commitUpdates)
+
+		// Otherwise, the revocation should come before the LogUpdates
+		// + CommitSig of the first commitment that was signed after
+		// it.
+		//
+		// ---revocation--->
+		// ---logupdates--->
+		// ---commitsig---->
+		case revocationIdx == -1:
+			revocationIdx = 0
+		}
+
+		resync := make(
+			[]lnwire.Message, 0, len(updates)+len(commitUpdates),
+		)
+		resync = append(resync, commitUpdates[:revocationIdx]...)
+		resync = append(resync, updates...)
+		updates = append(resync, commitUpdates[revocationIdx:]...)
+
+	// There should be no other possible states as long as the commit chain
+	// is bounded by the revocation window. If that's the case, something
+	// is wrong.
+	default:
+		lc.log.Errorf("sync failed: remote's next commit height is %v, "+
 			"while we believe it is %v!",
 			msg.NextLocalCommitHeight, remoteTipHeight)
 		return nil, nil, nil, ErrCannotSyncCommitChains
@@ -4521,6 +6245,15 @@ func (lc *LightningChannel) ProcessChanSyncMsg(
 	// TODO(roasbeef): verify this in the spec...
 	case msg.NextLocalCommitHeight == remoteTailHeight+2:
 		commitPoint = lc.channelState.RemoteNextRevocation
+
+	// If we've extended them more commitments than that, the highest
+	// unrevoked point is one of the additional points they handed out to
+	// extend our revocation window.
+	case msg.NextLocalCommitHeight > remoteTailHeight+2:
+		idx := msg.NextLocalCommitHeight - remoteTailHeight - 3
+		if idx < uint64(len(lc.channelState.RemoteCommitPoints)) {
+			commitPoint = lc.channelState.RemoteCommitPoints[idx]
+		}
 	}
 
 	// Only if this is a tweakless channel will we attempt to verify the
@@ -4646,6 +6379,22 @@ func genHtlcSigValidationJobs(localCommitmentView *commitment,
 		len(localCommitmentView.outgoingHTLCs))
 	verifyJobs := make([]VerifyJob, 0, numHtlcs)
 
+	// The signatures are consumed in the order of the HTLC outputs on the
+	// commitment transaction, so before doing anything else, we make sure
+	// we've received exactly one signature per HTLC output. Dust HTLCs
+	// don't have an output, and therefore no signature either. Otherwise
+	// a missing or superfluous signature would surface as an invalid
+	// signature for whichever HTLC it'd be matched with.
+	numHtlcOutputs := len(localCommitmentView.incomingHTLCIndex) +
+		len(localCommitmentView.outgoingHTLCIndex)
+	if len(htlcSigs) != numHtlcOutputs {
+		return nil, fmt.Errorf("%w: commitment at height %v has %v "+
+			"htlc outputs (%v htlcs including dust), but got %v "+
+			"signatures", ErrHtlcSigCountMismatch,
+			localCommitmentView.height, numHtlcOutputs, numHtlcs,
+			len(htlcSigs))
+	}
+
 	// We'll iterate through each output in the commitment transaction,
 	// populating the sigHash closure function if it's detected to be an
 	// HLTC output. Given the sighash, and the signing key, we'll be able
@@ -4724,12 +6473,6 @@ func genHtlcSigValidationJobs(localCommitmentView *commitment,
 				return sigHash, nil
 			}
 
-			// Make sure there are more signatures left.
-			if i >= len(htlcSigs) {
-				return nil, fmt.Errorf("not enough HTLC " +
-					"signatures")
-			}
-
 			// If this is a taproot channel, then we'll convert it
 			// to a schnorr signature, so we can get correct type
 			// from ToSignature below.
@@ -4811,12 +6554,6 @@ func genHtlcSigValidationJobs(localCommitmentView *commitment,
 				return sigHash, nil
 			}
 
-			// Make sure there are more signatures left.
-			if i >= len(htlcSigs) {
-				return nil, fmt.Errorf("not enough HTLC " +
-					"signatures")
-			}
-
 			// If this is a taproot channel, then we'll convert it
 			// to a schnorr signature, so we can get correct type
 			// from ToSignature below.
@@ -4848,13 +6585,6 @@ func genHtlcSigValidationJobs(localCommitmentView *commitment,
 		i++
 	}
 
-	// If we received a number of HTLC signatures that doesn't match our
-	// commitment, we'll return an error now.
-	if len(htlcSigs) != i {
-		return nil, fmt.Errorf("number of htlc sig mismatch. "+
-			"Expected %v sigs, got %v", i, len(htlcSigs))
-	}
-
 	return verifyJobs, nil
 }
 
@@ -4901,6 +6631,34 @@ func (i *InvalidPartialCommitSigError) Error() string {
 		i.invalidPartialSigError)
 }
 
+// ErrFeeMismatch is returned when the remote party's commitment signature
+// doesn't verify against the commitment we reconstructed, but does verify
+// against the same commitment built with a different fee rate. This indicates
+// that both parties disagree on the set of fee updates covered by the new
+// commitment, e.g. because an update_fee message was lost.
+type ErrFeeMismatch struct {
+	InvalidCommitSigError
+
+	// localFeePerKw is the fee rate of the commitment we reconstructed.
+	localFeePerKw chainfee.SatPerKWeight
+
+	// remoteFeePerKw is the fee rate the remote party's signature commits
+	// to.
+	remoteFeePerKw chainfee.SatPerKWeight
+}
+
+// Error returns a detailed error string including both fee rates.
+func (e *ErrFeeMismatch) Error() string {
+	return fmt.Sprintf("rejected commitment: commit_height=%v, fee "+
+		"mismatch: local_fee_per_kw=%v, remote_fee_per_kw=%v, "+
+		"commit_tx=%x", e.commitHeight, int64(e.localFeePerKw),
+		int64(e.remoteFeePerKw), e.commitTx)
+}
+
+// A compile time flag to ensure that ErrFeeMismatch implements the error
+// interface.
+var _ error = (*ErrFeeMismatch)(nil)
+
 // InvalidHtlcSigError is a struct that implements the error interface to
 // report a failure to validate an htlc signature from a remote peer. We'll use
 // the items in this struct to generate a rich error message for the remote
@@ -4930,6 +6688,95 @@ func (i *InvalidHtlcSigError) Error() string {
 // error interface.
 var _ error = (*InvalidCommitSigError)(nil)
 
+// findCommitSigFeeRate checks whether the passed commitment signature is valid
+// for the given commitment view rebuilt with any other fee rate known to the
+// channel, namely the fee rates of both commitment chain tips and those of all
+// fee updates within the update logs. If so, the matching fee rate is
+// returned. As the commitment transaction itself is never exchanged, the fee
+// rate the remote party used can only be recovered if it's one of these.
+func (lc *LightningChannel) findCommitSigFeeRate(view *commitment,
+	keyRing *CommitmentKeyRing, sig input.Signature,
+	verifyKey *btcec.PublicKey) (chainfee.SatPerKWeight, bool) {
+
+	candidates := []chainfee.SatPerKWeight{
+		lc.localCommitChain.tip().feePerKw,
+		lc.remoteCommitChain.tip().feePerKw,
+	}
+	updateLogs := []*updateLog{lc.localUpdateLog, lc.remoteUpdateLog}
+	for _, updates := range updateLogs {
+		for e := updates.Front(); e != nil; e = e.Next() {
+			pd := e.Value.(*PaymentDescriptor)
+			if pd.EntryType != FeeUpdate {
+				continue
+			}
+
+			candidates = append(candidates, chainfee.SatPerKWeight(
+				pd.Amount.ToSatoshis(),
+			))
+		}
+	}
+
+	// The balances of the view already have the commitment fee deducted
+	// from the initiator, so we'll add it back before rebuilding.
+	ourBalance, theirBalance := view.ourBalance, view.theirBalance
+	if lc.channelState.IsInitiator {
+		ourBalance += lnwire.NewMSatFromSatoshis(view.fee)
+	} else {
+		theirBalance += lnwire.NewMSatFromSatoshis(view.fee)
+	}
+
+	htlcs := &htlcView{}
+	for i := range view.outgoingHTLCs {
+		htlcs.ourUpdates = append(
+			htlcs.ourUpdates, &view.outgoingHTLCs[i],
+		)
+	}
+	for i := range view.incomingHTLCs {
+		htlcs.theirUpdates = append(
+			htlcs.theirUpdates, &view.incomingHTLCs[i],
+		)
+	}
+
+	multiSigScript := lc.signDesc.WitnessScript
+	capacity := int64(lc.channelState.Capacity)
+	checked := map[chainfee.SatPerKWeight]struct{}{
+		view.feePerKw: {},
+	}
+	for _, feePerKw := range candidates {
+		if _, ok := checked[feePerKw]; ok {
+			continue
+		}
+		checked[feePerKw] = struct{}{}
+
+		htlcs.feePerKw = feePerKw
+		commitTx, err := lc.commitBuilder.createUnsignedCommitmentTx(
+			ourBalance, theirBalance, true, feePerKw, view.height,
+			htlcs, keyRing,
+		)
+		if err != nil {
+			continue
+		}
+
+		prevFetcher := txscript.NewCannedPrevOutputFetcher(
+			multiSigScript, capacity,
+		)
+		hashCache := txscript.NewTxSigHashes(commitTx.txn, prevFetcher)
+		sigHash, err := txscript.CalcWitnessSigHash(
+			multiSigScript, hashCache, txscript.SigHashAll,
+			commitTx.txn, 0, capacity,
+		)
+		if err != nil {
+			continue
+		}
+
+		if sig.Verify(sigHash, verifyKey) {
+			return feePerKw, true
+		}
+	}
+
+	return 0, false
+}
+
 // ReceiveNewCommitment process a signature for a new commitment state sent by
 // the remote party. This method should be called in response to the
 // remote party initiating a new change, or when the remote party sends a
@@ -4975,11 +6822,10 @@ func (lc *LightningChannel) ReceiveNewCommitment(commitSigs *CommitSigs) error {
 	// as this will be needed to derive the keys required to construct the
 	// commitment.
 	nextHeight := lc.currentHeight + 1
-	commitSecret, err := lc.channelState.RevocationProducer.AtIndex(nextHeight)
+	commitPoint, err := lc.commitPointAt(nextHeight)
 	if err != nil {
 		return err
 	}
-	commitPoint := input.ComputeCommitmentPoint(commitSecret[:])
 	keyRing := DeriveCommitmentKeys(
 		commitPoint, true, lc.channelState.ChanType,
 		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
@@ -4990,7 +6836,7 @@ func (lc *LightningChannel) ReceiveNewCommitment(commitSigs *CommitSigs) error {
 	// we know of in the remote node's HTLC log, but only our local changes
 	// up to the last change the remote node has ACK'd.
 	localCommitmentView, err := lc.fetchCommitmentView(
-		false, localACKedIndex, localHtlcIndex,
+		false, true, localACKedIndex, localHtlcIndex,
 		lc.remoteUpdateLog.logIndex, lc.remoteUpdateLog.htlcCounter,
 		keyRing,
 	)
@@ -5123,12 +6969,30 @@ func (lc *LightningChannel) ReceiveNewCommitment(commitSigs *CommitSigs) error {
 			var txBytes bytes.Buffer
 			_ = localCommitTx.Serialize(&txBytes)
 
-			return &InvalidCommitSigError{
+			sigErr := InvalidCommitSigError{
 				commitHeight: nextHeight,
 				commitSig:    commitSigs.CommitSig.ToSignatureBytes(), //nolint:lll
 				sigHash:      sigHash,
 				commitTx:     txBytes.Bytes(),
 			}
+
+			// An invalid signature is commonly caused by both
+			// parties disagreeing on the fee rate of the
+			// commitment. Check whether the signature is valid for
+			// any other fee rate we know of, to be able to report
+			// a more actionable error.
+			remoteFee, ok := lc.findCommitSigFeeRate(
+				localCommitmentView, keyRing, cSig, verifyKey,
+			)
+			if ok {
+				return &ErrFeeMismatch{
+					InvalidCommitSigError: sigErr,
+					localFeePerKw:         localCommitmentView.feePerKw, //nolint:lll
+					remoteFeePerKw:        remoteFee,
+				}
+			}
+
+			return &sigErr
 		}
 	}
 
@@ -5141,6 +7005,13 @@ func (lc *LightningChannel) ReceiveNewCommitment(commitSigs *CommitSigs) error {
 		if htlcErr != nil {
 			close(cancelChan)
 
+			// If the sig pool refused the job as it's shutting
+			// down, the signature hasn't been checked at all, so it
+			// must not be reported as invalid.
+			if errors.Is(htlcErr.error, ErrSigPoolShuttingDown) {
+				return ErrSigPoolShuttingDown
+			}
+
 			sig, err := lnwire.NewSigFromSignature(
 				htlcErr.Sig,
 			)
@@ -5181,6 +7052,129 @@ func (lc *LightningChannel) ReceiveNewCommitment(commitSigs *CommitSigs) error {
 	}
 
 	lc.localCommitChain.addCommitment(localCommitmentView)
+	lc.metrics.CommitmentsReceived++
+
+	return nil
+}
+
+// VerifyStoredHTLCSigs verifies the HTLC signatures of our current local
+// commitment as stored on disk. The commitment key ring and the second level
+// HTLC transactions are re-derived, such that each stored signature can be
+// verified against the sighash of its transaction. This allows us to detect
+// corrupted channel state before we'd need to broadcast it. If any of the
+// signatures doesn't verify, ErrInvalidStoredHtlcSig is returned.
+func (lc *LightningChannel) VerifyStoredHTLCSigs() error {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	// A channel restored from a backup doesn't have any commitment state
+	// to verify.
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return nil
+	}
+
+	localCommit := lc.channelState.LocalCommitment
+	commitPoint, err := lc.commitPointAt(localCommit.CommitHeight)
+	if err != nil {
+		return err
+	}
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, true, lc.channelState.ChanType,
+		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
+	)
+
+	feePerKw := chainfee.SatPerKWeight(localCommit.FeePerKw)
+	incoming, outgoing, err := lc.extractPayDescs(
+		localCommit.CommitHeight, feePerKw, localCommit.Htlcs, keyRing,
+		nil, true,
+	)
+	if err != nil {
+		return err
+	}
+
+	// We'll now reconstruct a view of our commitment, indexing each
+	// non-dust HTLC by the output it's located at.
+	commitView := &commitment{
+		txn:               localCommit.CommitTx,
+		feePerKw:          feePerKw,
+		isOurs:            true,
+		incomingHTLCs:     incoming,
+		outgoingHTLCs:     outgoing,
+		incomingHTLCIndex: make(map[int32]*PaymentDescriptor),
+		outgoingHTLCIndex: make(map[int32]*PaymentDescriptor),
+	}
+	for i := range commitView.incomingHTLCs {
+		htlc := &commitView.incomingHTLCs[i]
+		if htlc.localOutputIndex >= 0 {
+			commitView.incomingHTLCIndex[htlc.localOutputIndex] = htlc
+		}
+	}
+	for i := range commitView.outgoingHTLCs {
+		htlc := &commitView.outgoingHTLCs[i]
+		if htlc.localOutputIndex >= 0 {
+			commitView.outgoingHTLCIndex[htlc.localOutputIndex] = htlc
+		}
+	}
+
+	// The signatures need to be ordered by the output index of their
+	// HTLC, the same way as they were sent to us.
+	storedSigs := make(map[int32][]byte, len(localCommit.Htlcs))
+	for _, htlc := range localCommit.Htlcs {
+		if htlc.OutputIndex >= 0 {
+			storedSigs[htlc.OutputIndex] = htlc.Signature
+		}
+	}
+	htlcSigs := make([]lnwire.Sig, 0, len(storedSigs))
+	for index := range localCommit.CommitTx.TxOut {
+		rawSig, ok := storedSigs[int32(index)]
+		if !ok {
+			continue
+		}
+
+		sig, err := input.ParseSignature(rawSig)
+		if err != nil {
+			return fmt.Errorf("%w: output %v: %v",
+				ErrInvalidStoredHtlcSig, index, err)
+		}
+		wireSig, err := lnwire.NewSigFromSignature(sig)
+		if err != nil {
+			return fmt.Errorf("%w: output %v: %v",
+				ErrInvalidStoredHtlcSig, index, err)
+		}
+
+		htlcSigs = append(htlcSigs, wireSig)
+	}
+
+	var leaseExpiry uint32
+	if lc.channelState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = lc.channelState.ThawHeight
+	}
+	verifyJobs, err := genHtlcSigValidationJobs(
+		commitView, keyRing, htlcSigs, lc.channelState.ChanType,
+		lc.channelState.IsInitiator, leaseExpiry,
+		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
+	)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidStoredHtlcSig, err)
+	}
+
+	cancelChan := make(chan struct{})
+	defer close(cancelChan)
+
+	verifyResps := lc.sigPool.SubmitVerifyBatch(verifyJobs, cancelChan)
+	for i := 0; i < len(verifyJobs); i++ {
+		htlcErr := <-verifyResps
+		if htlcErr == nil {
+			continue
+		}
+
+		if errors.Is(htlcErr.error, ErrSigPoolShuttingDown) {
+			return ErrSigPoolShuttingDown
+		}
+
+		return fmt.Errorf("%w: htlc index %v", ErrInvalidStoredHtlcSig,
+			htlcErr.HtlcIndex)
+	}
 
 	return nil
 }
@@ -5229,6 +7223,95 @@ func (lc *LightningChannel) IsChannelClean() bool {
 	return true
 }
 
+// SyncDiagnostics describes the state of the commitment dance of a channel,
+// i.e. which messages are still owed by either party before both commitment
+// chains are in sync. It allows finding out why a channel is stuck.
+type SyncDiagnostics struct {
+	// OweCommitment is true if we need to send a commitment signature, as
+	// our local commitment or update log holds updates that aren't
+	// included in the remote commitment yet.
+	OweCommitment bool
+
+	// OweRevocation is true if we've received a new commitment, but
+	// haven't revoked our prior commitment yet.
+	OweRevocation bool
+
+	// PendingRemoteCommit is true if we've signed a new remote
+	// commitment, and are waiting for the remote party to revoke their
+	// prior commitment.
+	PendingRemoteCommit bool
+
+	// RemoteOwesCommitment is true if the remote party needs to send us a
+	// commitment signature, as our remote commitment or their update log
+	// holds updates that aren't included in our local commitment yet.
+	RemoteOwesCommitment bool
+
+	// UnsignedLocalUpdates is the number of our updates that aren't
+	// included in the remote commitment yet.
+	UnsignedLocalUpdates uint64
+
+	// UnsignedRemoteUpdates is the number of updates of the remote party
+	// that aren't included in our local commitment yet.
+	UnsignedRemoteUpdates uint64
+}
+
+// Synced returns true if no messages are owed by either party, meaning both
+// commitment chains are in sync.
+func (d *SyncDiagnostics) Synced() bool {
+	return !d.OweCommitment && !d.OweRevocation &&
+		!d.PendingRemoteCommit && !d.RemoteOwesCommitment
+}
+
+// String returns a human-readable summary of why the channel isn't synced.
+func (d *SyncDiagnostics) String() string {
+	if d.Synced() {
+		return "synced"
+	}
+
+	var reasons []string
+	if d.OweCommitment {
+		reasons = append(reasons, fmt.Sprintf("we owe a commitment "+
+			"(unsigned local updates: %d)", d.UnsignedLocalUpdates))
+	}
+	if d.OweRevocation {
+		reasons = append(reasons, "we owe a revocation")
+	}
+	if d.PendingRemoteCommit {
+		reasons = append(reasons, "waiting for the remote revocation")
+	}
+	if d.RemoteOwesCommitment {
+		reasons = append(reasons, fmt.Sprintf("remote owes a "+
+			"commitment (unsigned remote updates: %d)",
+			d.UnsignedRemoteUpdates))
+	}
+
+	return strings.Join(reasons, ", ")
+}
+
+// SyncDiagnostics returns a description of the messages that are still owed by
+// either party to get both commitment chains in sync. Unlike IsChannelClean,
+// HTLCs that are locked in on both commitments don't affect the result.
+func (lc *LightningChannel) SyncDiagnostics() *SyncDiagnostics {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	lastLocalCommit := lc.localCommitChain.tip()
+	lastRemoteCommit := lc.remoteCommitChain.tip()
+
+	localChain, remoteChain := lc.localCommitChain, lc.remoteCommitChain
+
+	return &SyncDiagnostics{
+		OweCommitment:        lc.oweCommitment(true),
+		OweRevocation:        localChain.hasUnackedCommitment(),
+		PendingRemoteCommit:  remoteChain.hasUnackedCommitment(),
+		RemoteOwesCommitment: lc.oweCommitment(false),
+		UnsignedLocalUpdates: lc.localUpdateLog.logIndex -
+			lastRemoteCommit.ourMessageIndex,
+		UnsignedRemoteUpdates: lc.remoteUpdateLog.logIndex -
+			lastLocalCommit.theirMessageIndex,
+	}
+}
+
 // OweCommitment returns a boolean value reflecting whether we need to send
 // out a commitment signature because there are outstanding local updates and/or
 // updates in the local commit tx that aren't reflected in the remote commit tx
@@ -5318,6 +7401,15 @@ func (lc *LightningChannel) RevokeCurrentCommitment() (*lnwire.RevokeAndAck,
 	lc.Lock()
 	defer lc.Unlock()
 
+	// In debug builds, we make sure the commitment we're about to accept
+	// doesn't leak any funds before revoking our current one.
+	if balanceInvariantChecks {
+		err := lc.checkBalanceInvariant(lc.localCommitChain.next())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	revocationMsg, err := lc.generateRevocation(lc.currentHeight)
 	if err != nil {
 		return nil, nil, nil, err
@@ -5358,15 +7450,51 @@ func (lc *LightningChannel) RevokeCurrentCommitment() (*lnwire.RevokeAndAck,
 		&lc.channelState.FundingOutpoint,
 	)
 
+	lc.metrics.RevocationsSent++
+
 	return revocationMsg, newCommitment.Htlcs, finalHtlcs, nil
 }
 
+// checkBalanceInvariant checks the balance invariant of the passed commitment,
+// see commitment.checkBalanceInvariant, and logs the amounts of the commitment
+// if it's violated.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) checkBalanceInvariant(c *commitment) error {
+	err := c.checkBalanceInvariant(
+		lc.channelState.Capacity, lc.channelState.ChanType,
+	)
+	if err != nil {
+		lc.log.Errorf("Commitment failed balance check: %v", err)
+	}
+
+	return err
+}
+
+// PeekRevocation returns the revocation message RevokeCurrentCommitment would
+// send for our current commitment, without revoking it. Neither the local
+// commitment chain nor the persisted channel state is modified, so the message
+// can be computed any number of times, e.g. by recovery flows that need to
+// inspect it.
+//
+// NOTE: This method must not be used in the normal state transition dance, as
+// sending the returned message without advancing our state would desync the
+// channel. Use RevokeCurrentCommitment instead.
+func (lc *LightningChannel) PeekRevocation() (*lnwire.RevokeAndAck, error) {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.generateRevocation(lc.currentHeight)
+}
+
 // ReceiveRevocation processes a revocation sent by the remote party for the
 // lowest unrevoked commitment within their commitment chain. We receive a
 // revocation either during the initial session negotiation wherein revocation
 // windows are extended, or in response to a state update that we initiate. If
 // successful, then the remote commitment chain is advanced by a single
-// commitment, and a log compaction is attempted.
+// commitment, and a log compaction is attempted. If multiple commitments are
+// pending, the revocation always applies to the lowest one, and the
+// remaining commitments stay pending.
 //
 // The returned values correspond to:
 //  1. The forwarding package corresponding to the remote commitment height
@@ -5384,21 +7512,47 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 	lc.Lock()
 	defer lc.Unlock()
 
+	// In debug builds, we make sure the remote commitment that becomes
+	// their new tail doesn't leak any funds before accepting the
+	// revocation.
+	if balanceInvariantChecks {
+		err := lc.checkBalanceInvariant(lc.remoteCommitChain.next())
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	// If the remote party extended our revocation window, then we already
+	// know the commitment point they're handing us with this revocation,
+	// so we'll make sure they're not attempting to swap it out.
+	remoteCommitPoints := lc.channelState.RemoteCommitPoints
+	if len(remoteCommitPoints) > 0 &&
+		!remoteCommitPoints[0].IsEqual(revMsg.NextRevocationKey) {
+
+		return nil, nil, nil, nil, fmt.Errorf("next revocation key " +
+			"mismatch")
+	}
+
 	// Ensure that the new pre-image can be placed in preimage store.
 	store := lc.channelState.RevocationStore
 	revocation, err := chainhash.NewHash(revMsg.Revocation[:])
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
+	currentCommitPoint := lc.channelState.RemoteCurrentRevocation
+	derivedCommitPoint := input.ComputeCommitmentPoint(revMsg.Revocation[:])
 	if err := store.AddNextEntry(revocation); err != nil {
-		return nil, nil, nil, nil, err
+		return nil, nil, nil, nil, &ErrRevocationChainBroken{
+			height:        lc.remoteCommitChain.tail().height,
+			derivedPoint:  derivedCommitPoint,
+			expectedPoint: currentCommitPoint,
+			err:           err,
+		}
 	}
 
 	// Verify that if we use the commitment point computed based off of the
 	// revealed secret to derive a revocation key with our revocation base
 	// point, then it matches the current revocation of the remote party.
-	currentCommitPoint := lc.channelState.RemoteCurrentRevocation
-	derivedCommitPoint := input.ComputeCommitmentPoint(revMsg.Revocation[:])
 	if !derivedCommitPoint.IsEqual(currentCommitPoint) {
 		return nil, nil, nil, nil, fmt.Errorf("revocation key mismatch")
 	}
@@ -5410,6 +7564,9 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 	// in the message.
 	lc.channelState.RemoteCurrentRevocation = lc.channelState.RemoteNextRevocation
 	lc.channelState.RemoteNextRevocation = revMsg.NextRevocationKey
+	if len(remoteCommitPoints) > 0 {
+		lc.channelState.RemoteCommitPoints = remoteCommitPoints[1:]
+	}
 
 	lc.log.Tracef("remote party accepted state transition, revoked height "+
 		"%v, now at %v",
@@ -5485,7 +7642,20 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 			addIndex++
 
 			pd.isForwarded = true
-			addsToForward = append(addsToForward, pd)
+
+			// HTLCs with a rejected onion are recorded in the
+			// forwarding package, but failed back right away.
+			failCode, rejected := lc.rejectedHtlcs[pd.HtlcIndex]
+			if !rejected {
+				addsToForward = append(addsToForward, pd)
+				break
+			}
+
+			err := lc.failRejectedHtlc(pd, failCode)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			delete(lc.rejectedHtlcs, pd.HtlcIndex)
 
 		case pd.EntryType != Add && committedRmv && shouldFwdRmv:
 			// Construct a reference specifying the location that
@@ -5557,9 +7727,9 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 		}
 	}
 
-	// We use the remote commitment chain's tip as it will soon become the tail
-	// once advanceTail is called.
-	remoteMessageIndex := lc.remoteCommitChain.tip().ourMessageIndex
+	// We use the commitment following the remote commitment chain's tail
+	// as it will soon become the tail once advanceTail is called.
+	remoteMessageIndex := lc.remoteCommitChain.next().ourMessageIndex
 	localMessageIndex := lc.localCommitChain.tail().ourMessageIndex
 
 	localPeerUpdates := lc.unsignedLocalUpdates(
@@ -5604,6 +7774,18 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 		lc.musigSessions.RemoteSession = newRemoteSession
 	}
 
+	// Once the remote party can no longer broadcast a commitment which
+	// still holds the HTLCs settled in their new tail, the settles are
+	// irreversible, so we account for them in the totals of the channel.
+	// This happens exactly once for each settle, as the tail only passes
+	// its remove height once. The totals are persisted along with the
+	// revocation below.
+	sent, received, numSettled := lc.settledHtlcAmounts(remoteChainTail)
+	prevSent := lc.channelState.TotalMSatSent
+	prevReceived := lc.channelState.TotalMSatReceived
+	lc.channelState.TotalMSatSent += sent
+	lc.channelState.TotalMSatReceived += received
+
 	// At this point, the revocation has been accepted, and we've rotated
 	// the current revocation key+hash for the remote party. Therefore we
 	// sync now to ensure the revocation producer state is consistent with
@@ -5614,6 +7796,9 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 		ourOutputIndex, theirOutputIndex,
 	)
 	if err != nil {
+		lc.channelState.TotalMSatSent = prevSent
+		lc.channelState.TotalMSatReceived = prevReceived
+
 		return nil, nil, nil, nil, err
 	}
 
@@ -5621,6 +7806,12 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 	// chain, we can advance their chain by a single commitment.
 	lc.remoteCommitChain.advanceTail()
 
+	// Settles never decrease a balance, but they may leave a party below
+	// its reserve that was below it before, which is worth reporting.
+	if numSettled > 0 {
+		lc.checkReserves()
+	}
+
 	// As we've just completed a new state transition, attempt to see if we
 	// can remove any entries from the update log which have been removed
 	// from the PoV of both commitment chains.
@@ -5629,11 +7820,58 @@ func (lc *LightningChannel) ReceiveRevocation(revMsg *lnwire.RevokeAndAck) (
 		remoteChainTail,
 	)
 
+	// If the logs still hold too many entries, spill the HTLCs that are
+	// now irrevocably committed. This is only an optimization, so we keep
+	// the entries in memory if this fails.
+	for _, updates := range []*updateLog{
+		lc.localUpdateLog, lc.remoteUpdateLog,
+	} {
+
+		err := updates.spillHtlcs(localChainTail, remoteChainTail)
+		if err != nil {
+			lc.log.Warnf("Unable to spill update log: %v", err)
+		}
+	}
+
 	remoteHTLCs := lc.channelState.RemoteCommitment.Htlcs
 
+	lc.metrics.RevocationsReceived++
+
 	return fwdPkg, addsToForward, settleFailsToForward, remoteHTLCs, nil
 }
 
+// settledHtlcAmounts returns the total amounts sent and received within the
+// channel by the HTLCs that were settled in the remote commitment at the given
+// height, along with the number of settled HTLCs.
+func (lc *LightningChannel) settledHtlcAmounts(remoteChainTail uint64) (
+	lnwire.MilliSatoshi, lnwire.MilliSatoshi, int) {
+
+	var (
+		sent, received lnwire.MilliSatoshi
+		numSettled     int
+	)
+	for e := lc.localUpdateLog.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.EntryType == Settle &&
+			pd.removeCommitHeightRemote == remoteChainTail {
+
+			received += pd.Amount
+			numSettled++
+		}
+	}
+	for e := lc.remoteUpdateLog.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.EntryType == Settle &&
+			pd.removeCommitHeightRemote == remoteChainTail {
+
+			sent += pd.Amount
+			numSettled++
+		}
+	}
+
+	return sent, received, numSettled
+}
+
 // LoadFwdPkgs loads any pending log updates from disk and returns the payment
 // descriptors to be processed by the link.
 func (lc *LightningChannel) LoadFwdPkgs() ([]*channeldb.FwdPkg, error) {
@@ -5664,6 +7902,40 @@ func (lc *LightningChannel) SetFwdFilter(height uint64,
 	return lc.channelState.SetFwdFilter(height, fwdFilter)
 }
 
+// SetHtlcIntercept writes the decision of the HTLC interceptor about the Add at
+// the given index in the forwarding package at the remote commitment height.
+func (lc *LightningChannel) SetHtlcIntercept(height uint64, index uint16,
+	intercept *channeldb.HtlcIntercept) error {
+
+	return lc.channelState.SetHtlcIntercept(height, index, intercept)
+}
+
+// SetPreimageObserver registers a callback which is invoked with the payment
+// hash and preimage whenever an HTLC is settled by either party, so the
+// preimage can be propagated to other channels. Passing nil removes the
+// observer.
+//
+// NOTE: The observer is called while the channel's lock is held, so it must
+// not call back into the channel.
+func (lc *LightningChannel) SetPreimageObserver(observer PreimageObserver) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	lc.preimageObserver = observer
+}
+
+// notifyPreimage hands the preimage of a settled HTLC to the preimage
+// observer, if one is registered.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) notifyPreimage(rHash [32]byte, preimage [32]byte) {
+	if lc.preimageObserver == nil {
+		return
+	}
+
+	lc.preimageObserver(rHash, preimage)
+}
+
 // RemoveFwdPkgs permanently deletes the forwarding package at the given heights.
 func (lc *LightningChannel) RemoveFwdPkgs(heights ...uint64) error {
 	return lc.channelState.RemoveFwdPkgs(heights...)
@@ -5677,15 +7949,46 @@ func (lc *LightningChannel) NextRevocationKey() (*btcec.PublicKey, error) {
 	lc.RLock()
 	defer lc.RUnlock()
 
+	return lc.commitPointAt(lc.currentHeight + 1)
+}
+
+// NextLocalCommitTxid returns the txid of the next local commitment
+// transaction, i.e. the commitment the remote party extends our commitment
+// chain with once they sign all updates we know of. The unsigned commitment is
+// built without modifying the channel state. As the txid doesn't commit to
+// the witness, it matches the txid of the commitment once signed, which lets
+// callers e.g. register for its spend ahead of time.
+func (lc *LightningChannel) NextLocalCommitTxid() (chainhash.Hash, error) {
+	// Building the commitment view annotates the update log entries with
+	// their output scripts, so we need the write lock.
+	lc.Lock()
+	defer lc.Unlock()
+
 	nextHeight := lc.currentHeight + 1
-	revocation, err := lc.channelState.RevocationProducer.AtIndex(nextHeight)
+	commitPoint, err := lc.commitPointAt(nextHeight)
 	if err != nil {
-		return nil, err
+		return chainhash.Hash{}, err
 	}
-
-	return input.ComputeCommitmentPoint(revocation[:]), nil
-}
-
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, true, lc.channelState.ChanType,
+		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
+	)
+
+	// Like in ReceiveNewCommitment, the commitment includes all of the
+	// remote party's updates, but only our updates they've ACK'd.
+	remoteTail := lc.remoteCommitChain.tail()
+	commitView, err := lc.fetchCommitmentView(
+		false, false, remoteTail.ourMessageIndex,
+		remoteTail.ourHtlcIndex, lc.remoteUpdateLog.logIndex,
+		lc.remoteUpdateLog.htlcCounter, keyRing,
+	)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+
+	return commitView.txn.TxHash(), nil
+}
+
 // InitNextRevocation inserts the passed commitment point as the _next_
 // revocation to be used when creating a new commitment state for the remote
 // party. This function MUST be called before the channel can accept or propose
@@ -5716,16 +8019,66 @@ func (lc *LightningChannel) AddHTLC(htlc *lnwire.UpdateAddHTLC,
 	lc.Lock()
 	defer lc.Unlock()
 
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return 0, ErrRestoredChannel
+	}
+
 	pd := lc.htlcAddDescriptor(htlc, openKey)
 	if err := lc.validateAddHtlc(pd); err != nil {
 		return 0, err
 	}
 
+	if err := lc.checkCircularHtlc(pd, false); err != nil {
+		return 0, err
+	}
+
 	lc.localUpdateLog.appendHtlc(pd)
+	lc.metrics.HtlcsAdded++
 
 	return pd.HtlcIndex, nil
 }
 
+// checkCircularHtlc flags the passed HTLC if an HTLC with the same payment
+// hash is pending in the opposite direction of the channel, meaning it was
+// added and isn't being settled or failed yet. If circular HTLCs are rejected,
+// ErrCircularHtlc is returned in that case.
+//
+// NOTE: HTLCs spilled to the overflow store aren't considered, as only their
+// amounts are kept in memory.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) checkCircularHtlc(pd *PaymentDescriptor,
+	incoming bool) error {
+
+	oppositeLog, direction := lc.remoteUpdateLog, "outgoing"
+	if incoming {
+		oppositeLog, direction = lc.localUpdateLog, "incoming"
+	}
+
+	for e := oppositeLog.Front(); e != nil; e = e.Next() {
+		htlc := e.Value.(*PaymentDescriptor)
+		if htlc.EntryType != Add || htlc.RHash != pd.RHash ||
+			oppositeLog.htlcHasModification(htlc.HtlcIndex) {
+
+			continue
+		}
+
+		lc.metrics.CircularHtlcs++
+		lc.log.Warnf("Circular HTLC detected: payment hash %x of "+
+			"new %v HTLC matches pending HTLC %v in opposite "+
+			"direction", pd.RHash[:], direction, htlc.HtlcIndex)
+
+		if !lc.rejectCircularHtlcs {
+			return nil
+		}
+
+		return fmt.Errorf("%w: payment hash %x matches htlc %d",
+			ErrCircularHtlc, pd.RHash[:], htlc.HtlcIndex)
+	}
+
+	return nil
+}
+
 // GetDustSum takes in a boolean that determines which commitment to evaluate
 // the dust sum on. The return value is the sum of dust on the desired
 // commitment tx.
@@ -5735,6 +8088,14 @@ func (lc *LightningChannel) GetDustSum(remote bool) lnwire.MilliSatoshi {
 	lc.RLock()
 	defer lc.RUnlock()
 
+	return lc.getDustSum(remote)
+}
+
+// getDustSum returns the sum of the HTLCs in both update logs that are dust on
+// the local or remote commitment.
+//
+// NOTE: This method MUST be called with the channel's mutex held.
+func (lc *LightningChannel) getDustSum(remote bool) lnwire.MilliSatoshi {
 	var dustSum lnwire.MilliSatoshi
 
 	dustLimit := lc.channelState.LocalChanCfg.DustLimit
@@ -5766,6 +8127,15 @@ func (lc *LightningChannel) GetDustSum(remote bool) lnwire.MilliSatoshi {
 			dustSum += pd.Amount
 		}
 	}
+	for _, htlc := range lc.localUpdateLog.spilledHtlcs {
+		if HtlcIsDust(
+			chanType, false, !remote, feeRate,
+			htlc.amount.ToSatoshis(), dustLimit,
+		) {
+
+			dustSum += htlc.amount
+		}
+	}
 
 	// Grab all of their HTLCs and evaluate against the dust limit.
 	for e := lc.remoteUpdateLog.Front(); e != nil; e = e.Next() {
@@ -5785,10 +8155,155 @@ func (lc *LightningChannel) GetDustSum(remote bool) lnwire.MilliSatoshi {
 			dustSum += pd.Amount
 		}
 	}
+	for _, htlc := range lc.remoteUpdateLog.spilledHtlcs {
+		if HtlcIsDust(
+			chanType, true, !remote, feeRate,
+			htlc.amount.ToSatoshis(), dustLimit,
+		) {
+
+			dustSum += htlc.amount
+		}
+	}
 
 	return dustSum
 }
 
+// DustTransitions returns the indexes of the HTLCs in the update logs that
+// would change their dust status on either commitment if the fee rate of the
+// channel was updated to the passed fee rate. An HTLC that becomes dust loses
+// its output and adds to the fee of the commitment, while an HTLC that is no
+// longer dust gets its own output. This allows callers to assess the impact
+// of a fee update before proposing or accepting it.
+//
+// NOTE: Both parties index their HTLCs independently, so the returned slices
+// may contain the same index for one of our HTLCs and one of theirs.
+func (lc *LightningChannel) DustTransitions(
+	newFeePerKw chainfee.SatPerKWeight) (nowDust, nowMaterial []uint64) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	// The dust status of an HTLC is evaluated against the latest
+	// commitment of each party, as the fee update is applied to both.
+	commits := []struct {
+		ourCommit bool
+		feePerKw  chainfee.SatPerKWeight
+		dustLimit btcutil.Amount
+	}{
+		{
+			ourCommit: true,
+			feePerKw:  lc.localCommitChain.tip().feePerKw,
+			dustLimit: lc.channelState.LocalChanCfg.DustLimit,
+		},
+		{
+			ourCommit: false,
+			feePerKw:  lc.remoteCommitChain.tip().feePerKw,
+			dustLimit: lc.channelState.RemoteChanCfg.DustLimit,
+		},
+	}
+
+	chanType := lc.channelState.ChanType
+	classify := func(htlcIndex uint64, amt lnwire.MilliSatoshi,
+		incoming bool) {
+
+		for _, c := range commits {
+			wasDust := HtlcIsDust(
+				chanType, incoming, c.ourCommit, c.feePerKw,
+				amt.ToSatoshis(), c.dustLimit,
+			)
+			isDust := HtlcIsDust(
+				chanType, incoming, c.ourCommit, newFeePerKw,
+				amt.ToSatoshis(), c.dustLimit,
+			)
+
+			// A fee update moves the dust boundary of both
+			// commitments in the same direction, so an HTLC can
+			// only flip one way and is reported once.
+			switch {
+			case !wasDust && isDust:
+				nowDust = append(nowDust, htlcIndex)
+				return
+
+			case wasDust && !isDust:
+				nowMaterial = append(nowMaterial, htlcIndex)
+				return
+			}
+		}
+	}
+
+	for e := lc.localUpdateLog.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.EntryType == Add {
+			classify(pd.HtlcIndex, pd.Amount, false)
+		}
+	}
+	for htlcIndex, htlc := range lc.localUpdateLog.spilledHtlcs {
+		classify(htlcIndex, htlc.amount, false)
+	}
+	for e := lc.remoteUpdateLog.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.EntryType == Add {
+			classify(pd.HtlcIndex, pd.Amount, true)
+		}
+	}
+	for htlcIndex, htlc := range lc.remoteUpdateLog.spilledHtlcs {
+		classify(htlcIndex, htlc.amount, true)
+	}
+
+	sort.Slice(nowDust, func(i, j int) bool {
+		return nowDust[i] < nowDust[j]
+	})
+	sort.Slice(nowMaterial, func(i, j int) bool {
+		return nowMaterial[i] < nowMaterial[j]
+	})
+
+	return nowDust, nowMaterial
+}
+
+// validateDustExposure checks that adding the passed HTLC doesn't push the
+// dust exposure of either commitment above the configured maximum. The HTLC
+// is either offered by us or by the remote party, depending on incoming. Only
+// dust HTLCs are rejected, as an HTLC that has its own output doesn't add to
+// the exposure.
+//
+// NOTE: This method MUST be called with the channel's mutex held.
+func (lc *LightningChannel) validateDustExposure(pd *PaymentDescriptor,
+	incoming bool) error {
+
+	if lc.maxDustExposure == 0 {
+		return nil
+	}
+
+	chanType := lc.channelState.ChanType
+	amt := pd.Amount.ToSatoshis()
+
+	for _, remote := range []bool{false, true} {
+		dustLimit := lc.channelState.LocalChanCfg.DustLimit
+		commit := lc.channelState.LocalCommitment
+		if remote {
+			dustLimit = lc.channelState.RemoteChanCfg.DustLimit
+			commit = lc.channelState.RemoteCommitment
+		}
+
+		feeRate := chainfee.SatPerKWeight(commit.FeePerKw)
+		if !HtlcIsDust(
+			chanType, incoming, !remote, feeRate, amt, dustLimit,
+		) {
+
+			continue
+		}
+
+		exposure := lc.getDustSum(remote) + pd.Amount
+		if exposure > lc.maxDustExposure {
+			return fmt.Errorf("%w: exposure of %v on commitment "+
+				"of remote=%v, max %v", ErrDustExposureExceeded,
+				exposure, remote, lc.maxDustExposure)
+		}
+	}
+
+	return nil
+}
+
 // MayAddOutgoingHtlc validates whether we can add an outgoing htlc to this
 // channel. We don't have a circuit for this htlc, because we just want to test
 // that we have slots for a potential htlc so we use a "mock" htlc to validate
@@ -5835,6 +8350,30 @@ func (lc *LightningChannel) MayAddOutgoingHtlc(amt lnwire.MilliSatoshi) error {
 	return nil
 }
 
+// CanAddHTLC returns nil if an outgoing HTLC of the given amount would be
+// accepted by AddHTLC at this instant. The same balance, reserve, commitment
+// fee and max HTLC number checks are run against both commitment chains, but
+// the update log isn't modified. Unlike MayAddOutgoingHtlc, the amount isn't
+// substituted if zero, so a zero amount results in ErrInvalidHTLCAmt.
+//
+// NOTE: As the remote party may add updates concurrently, a nil error isn't a
+// guarantee that a later call to AddHTLC will succeed.
+func (lc *LightningChannel) CanAddHTLC(amt lnwire.MilliSatoshi) error {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	// The circuit key is only referenced once the HTLC is committed, so
+	// we can safely use a blank one for this speculative descriptor.
+	pd := lc.htlcAddDescriptor(
+		&lnwire.UpdateAddHTLC{
+			Amount: amt,
+		},
+		&models.CircuitKey{},
+	)
+
+	return lc.validateAddHtlc(pd)
+}
+
 // htlcAddDescriptor returns a payment descriptor for the htlc and open key
 // provided to add to our local update log.
 func (lc *LightningChannel) htlcAddDescriptor(htlc *lnwire.UpdateAddHTLC,
@@ -5859,6 +8398,12 @@ func (lc *LightningChannel) validateAddHtlc(pd *PaymentDescriptor) error {
 	// must keep on the commitment transactions.
 	remoteACKedIndex := lc.localCommitChain.tail().theirMessageIndex
 
+	// If the HTLC is dust, it mustn't push our dust exposure over the
+	// limit.
+	if err := lc.validateDustExposure(pd, false); err != nil {
+		return err
+	}
+
 	// First we'll check whether this HTLC can be added to the remote
 	// commitment transaction without violation any of the constraints.
 	err := lc.validateCommitmentSanity(
@@ -5891,6 +8436,45 @@ func (lc *LightningChannel) ReceiveHTLC(htlc *lnwire.UpdateAddHTLC) (uint64, err
 	lc.Lock()
 	defer lc.Unlock()
 
+	return lc.receiveHTLC(htlc)
+}
+
+// ReceiveHTLCAtHeight is identical to ReceiveHTLC, but additionally rejects the
+// HTLC with an ErrHtlcExpiryTooSoon error if its expiry doesn't leave at least
+// the configured minimum CLTV delta relative to the passed current block
+// height.
+func (lc *LightningChannel) ReceiveHTLCAtHeight(htlc *lnwire.UpdateAddHTLC,
+	currentHeight uint32) (uint64, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	// An HTLC expiring within less than the minimum delta from now
+	// wouldn't give us enough time to forward it and claim it back
+	// on-chain if needed.
+	if lc.minCLTVDelta != 0 &&
+		htlc.Expiry < currentHeight+lc.minCLTVDelta {
+
+		return 0, ErrHtlcExpiryTooSoon{
+			expiry:        htlc.Expiry,
+			currentHeight: currentHeight,
+			minDelta:      lc.minCLTVDelta,
+		}
+	}
+
+	return lc.receiveHTLC(htlc)
+}
+
+// receiveHTLC adds an HTLC to the remote update log.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) receiveHTLC(
+	htlc *lnwire.UpdateAddHTLC) (uint64, error) {
+
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return 0, ErrRestoredChannel
+	}
+
 	if htlc.ID != lc.remoteUpdateLog.htlcCounter {
 		return 0, fmt.Errorf("ID %d on HTLC add does not match expected next "+
 			"ID %d", htlc.ID, lc.remoteUpdateLog.htlcCounter)
@@ -5908,6 +8492,12 @@ func (lc *LightningChannel) ReceiveHTLC(htlc *lnwire.UpdateAddHTLC) (uint64, err
 
 	localACKedIndex := lc.remoteCommitChain.tail().ourMessageIndex
 
+	// We also bound the value of incoming dust HTLCs, as those are lost if
+	// the channel is force closed.
+	if err := lc.validateDustExposure(pd, true); err != nil {
+		return 0, err
+	}
+
 	// Clamp down on the number of HTLC's we can receive by checking the
 	// commitment sanity.
 	err := lc.validateCommitmentSanity(
@@ -5917,11 +8507,57 @@ func (lc *LightningChannel) ReceiveHTLC(htlc *lnwire.UpdateAddHTLC) (uint64, err
 		return 0, err
 	}
 
+	if err := lc.checkCircularHtlc(pd, true); err != nil {
+		return 0, err
+	}
+
+	// If the onion validator rejects the onion, the HTLC is failed back
+	// as malformed once it's locked in.
+	failCode, err := lc.validateOnion(htlc)
+	if err != nil {
+		return 0, err
+	}
+	if failCode != 0 {
+		return lc.rejectHtlc(pd, failCode), nil
+	}
+
 	lc.remoteUpdateLog.appendHtlc(pd)
+	lc.metrics.HtlcsAdded++
 
 	return pd.HtlcIndex, nil
 }
 
+// DumpUpdateLogs returns a human readable listing of all entries in both the
+// local and remote update logs. This is intended to diagnose channels that are
+// stuck, e.g. due to HTLCs that can't be found when settling or failing them.
+func (lc *LightningChannel) DumpUpdateLogs() string {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.dumpUpdateLogs()
+}
+
+// dumpUpdateLogs returns a listing of both update logs.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) dumpUpdateLogs() string {
+	return fmt.Sprintf("local update log: %vremote update log: %v",
+		lc.localUpdateLog.debugString(),
+		lc.remoteUpdateLog.debugString())
+}
+
+// unknownHtlcIndexErr returns an ErrUnknownHtlcIndex error for the given HTLC
+// index, logging the current update logs to help diagnose why the HTLC
+// couldn't be found.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) unknownHtlcIndexErr(htlcIndex uint64) error {
+	lc.log.Debugf("Unable to find HTLC with index %v, update logs: %v",
+		htlcIndex, newLogClosure(lc.dumpUpdateLogs))
+
+	return ErrUnknownHtlcIndex{lc.ShortChanID(), htlcIndex}
+}
+
 // SettleHTLC attempts to settle an existing outstanding received HTLC. The
 // remote log index of the HTLC settled is returned in order to facilitate
 // creating the corresponding wire message. In the case the supplied preimage
@@ -5953,20 +8589,44 @@ func (lc *LightningChannel) SettleHTLC(preimage [32]byte,
 	lc.Lock()
 	defer lc.Unlock()
 
+	_, err := lc.settleHTLC(
+		preimage, htlcIndex, sourceRef, destRef, closeKey,
+	)
+	if err != nil {
+		return err
+	}
+
+	lc.metrics.HtlcsSettled++
+	lc.notifyPreimage(sha256.Sum256(preimage[:]), preimage)
+
+	return nil
+}
+
+// settleHTLC appends a Settle entry for the received HTLC with the given index
+// to the local update log, and returns it.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) settleHTLC(preimage [32]byte,
+	htlcIndex uint64, sourceRef *channeldb.AddRef,
+	destRef *channeldb.SettleFailRef,
+	closeKey *models.CircuitKey) (*PaymentDescriptor, error) {
+
 	htlc := lc.remoteUpdateLog.lookupHtlc(htlcIndex)
 	if htlc == nil {
-		return ErrUnknownHtlcIndex{lc.ShortChanID(), htlcIndex}
+		return nil, lc.unknownHtlcIndexErr(htlcIndex)
 	}
 
 	// Now that we know the HTLC exists, before checking to see if the
 	// preimage matches, we'll ensure that we haven't already attempted to
 	// modify the HTLC.
 	if lc.remoteUpdateLog.htlcHasModification(htlcIndex) {
-		return ErrHtlcIndexAlreadySettled(htlcIndex)
+		return nil, ErrHtlcIndexAlreadySettled(htlcIndex)
 	}
 
 	if htlc.RHash != sha256.Sum256(preimage[:]) {
-		return ErrInvalidSettlePreimage{preimage[:], htlc.RHash[:]}
+		return nil, ErrInvalidSettlePreimage{
+			preimage[:], htlc.RHash[:],
+		}
 	}
 
 	pd := &PaymentDescriptor{
@@ -5987,7 +8647,52 @@ func (lc *LightningChannel) SettleHTLC(preimage [32]byte,
 	// duplicate settle.
 	lc.remoteUpdateLog.markHtlcModified(htlcIndex)
 
-	return nil
+	return pd, nil
+}
+
+// SettleAndSign settles the received HTLC with the given index like
+// SettleHTLC, and immediately signs a new commitment covering the settle like
+// SignNextCommitment. Both happen while holding the channel's lock, so no
+// other update can slip in between, which saves a forwarding node a round of
+// lock contention on the latency-sensitive settle path.
+//
+// The operation is atomic: if the new commitment can't be signed, e.g.
+// because we're still waiting for the revocation of the remote party, the
+// settle is removed from the update log again and the error is returned. The
+// HTLC can then be settled again later.
+//
+// NOTE: It is okay for sourceRef, destRef, and closeKey to be nil when unit
+// testing the wallet.
+func (lc *LightningChannel) SettleAndSign(preimage [32]byte,
+	htlcIndex uint64, sourceRef *channeldb.AddRef,
+	destRef *channeldb.SettleFailRef,
+	closeKey *models.CircuitKey) (*NewCommitState, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	pd, err := lc.settleHTLC(
+		preimage, htlcIndex, sourceRef, destRef, closeKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	newCommitState, _, err := lc.signNextCommitment()
+	if err != nil {
+		// As the settle is the latest entry of our local log and no
+		// commitment covers it, we can safely undo it.
+		lc.localUpdateLog.removeUpdate(pd.LogIndex)
+		lc.localUpdateLog.logIndex--
+		delete(lc.remoteUpdateLog.modifiedHtlcs, htlcIndex)
+
+		return nil, err
+	}
+
+	lc.metrics.HtlcsSettled++
+	lc.notifyPreimage(sha256.Sum256(preimage[:]), preimage)
+
+	return newCommitState, nil
 }
 
 // ReceiveHTLCSettle attempts to settle an existing outgoing HTLC indexed by an
@@ -6000,7 +8705,7 @@ func (lc *LightningChannel) ReceiveHTLCSettle(preimage [32]byte, htlcIndex uint6
 
 	htlc := lc.localUpdateLog.lookupHtlc(htlcIndex)
 	if htlc == nil {
-		return ErrUnknownHtlcIndex{lc.ShortChanID(), htlcIndex}
+		return lc.unknownHtlcIndexErr(htlcIndex)
 	}
 
 	// Now that we know the HTLC exists, before checking to see if the
@@ -6030,6 +8735,9 @@ func (lc *LightningChannel) ReceiveHTLCSettle(preimage [32]byte, htlcIndex uint6
 	// duplicate settle.
 	lc.localUpdateLog.markHtlcModified(htlcIndex)
 
+	lc.metrics.HtlcsSettled++
+	lc.notifyPreimage(htlc.RHash, preimage)
+
 	return nil
 }
 
@@ -6066,7 +8774,7 @@ func (lc *LightningChannel) FailHTLC(htlcIndex uint64, reason []byte,
 
 	htlc := lc.remoteUpdateLog.lookupHtlc(htlcIndex)
 	if htlc == nil {
-		return ErrUnknownHtlcIndex{lc.ShortChanID(), htlcIndex}
+		return lc.unknownHtlcIndexErr(htlcIndex)
 	}
 
 	// Now that we know the HTLC exists, we'll ensure that we haven't
@@ -6094,71 +8802,224 @@ func (lc *LightningChannel) FailHTLC(htlcIndex uint64, reason []byte,
 	// duplicate fail.
 	lc.remoteUpdateLog.markHtlcModified(htlcIndex)
 
+	lc.metrics.HtlcsFailed++
+
 	return nil
 }
 
-// MalformedFailHTLC attempts to fail a targeted HTLC by its payment hash,
-// inserting an entry which will remove the target log entry within the next
-// commitment update. This method is intended to be called in order to cancel
-// in _incoming_ HTLC.
+// FailAllPendingIncoming appends a Fail entry for every incoming HTLC that was
+// processed by the link without being handed over to the switch, and that
+// hasn't yet been settled or failed. The reason of each fail is obtained from
+// failReason, as it must be encrypted with the error encrypter of the HTLC's
+// own onion. It returns the HTLC indexes of the newly failed HTLCs in
+// ascending order, so the caller can send the corresponding UpdateFailHTLC
+// messages. HTLCs that already have a pending modification are skipped, which
+// makes repeated calls safe. This is intended to drain a channel before a
+// cooperative close.
 //
-// The additional sourceRef specifies the location of the Add HTLC within a
-// forwarding package that this HTLC is failing. This value should never be
-// empty.
+// Only the adds of forwarding packages in the processed state are considered,
+// and among them neither the ones set in the package's FwdFilter, whose
+// outgoing HTLCs may still be settled downstream, nor the ones held or
+// rejected by an HTLC interceptor. Each fail references its add within the
+// forwarding package, such that the add is acked once the fail is signed.
 //
-// NOTE: It is okay for sourceRef to be nil when unit testing the wallet.
-func (lc *LightningChannel) MalformedFailHTLC(htlcIndex uint64,
-	failCode lnwire.FailCode, shaOnionBlob [sha256.Size]byte,
-	sourceRef *channeldb.AddRef) error {
+// NOTE: failReason is called with the channel's lock held, so it must not call
+// back into the channel.
+func (lc *LightningChannel) FailAllPendingIncoming(
+	failReason func(htlc *PaymentDescriptor) (lnwire.OpaqueReason,
+		error)) ([]uint64, error) {
 
 	lc.Lock()
 	defer lc.Unlock()
 
-	htlc := lc.remoteUpdateLog.lookupHtlc(htlcIndex)
-	if htlc == nil {
-		return ErrUnknownHtlcIndex{lc.ShortChanID(), htlcIndex}
+	fwdPkgs, err := lc.channelState.LoadFwdPkgs()
+	if err != nil {
+		return nil, err
 	}
 
-	// Now that we know the HTLC exists, we'll ensure that we haven't
-	// already attempted to fail the HTLC.
-	if lc.remoteUpdateLog.htlcHasModification(htlcIndex) {
-		return ErrHtlcIndexAlreadyFailed(htlcIndex)
-	}
+	// Collect the adds that are safe to fail, along with their location
+	// within their forwarding package.
+	sourceRefs := make(map[uint64]channeldb.AddRef)
+	for _, fwdPkg := range fwdPkgs {
+		// The adds of a package that wasn't processed yet are handed
+		// over to the switch once the link processes it.
+		if fwdPkg.State != channeldb.FwdStateProcessed {
+			continue
+		}
 
-	pd := &PaymentDescriptor{
-		Amount:       htlc.Amount,
-		RHash:        htlc.RHash,
-		ParentIndex:  htlcIndex,
-		LogIndex:     lc.localUpdateLog.logIndex,
-		EntryType:    MalformedFail,
-		FailCode:     failCode,
-		ShaOnionBlob: shaOnionBlob,
-		SourceRef:    sourceRef,
-	}
+		for i, add := range fwdPkg.Adds {
+			idx := uint16(i)
+			if fwdPkg.FwdFilter.Contains(idx) ||
+				fwdPkg.AckFilter.Contains(idx) {
 
-	lc.localUpdateLog.appendUpdate(pd)
+				continue
+			}
 
-	// With the fail added to the remote log, we'll now mark the HTLC as
-	// modified to prevent ourselves from accidentally attempting a
-	// duplicate fail.
-	lc.remoteUpdateLog.markHtlcModified(htlcIndex)
+			if _, ok := fwdPkg.Intercepts[idx]; ok {
+				continue
+			}
 
-	return nil
-}
+			addMsg, ok := add.UpdateMsg.(*lnwire.UpdateAddHTLC)
+			if !ok {
+				continue
+			}
 
-// ReceiveFailHTLC attempts to cancel a targeted HTLC by its log index,
-// inserting an entry which will remove the target log entry within the next
-// commitment update. This method should be called in response to the upstream
-// party cancelling an outgoing HTLC.
-func (lc *LightningChannel) ReceiveFailHTLC(htlcIndex uint64, reason []byte,
-) error {
+			sourceRefs[addMsg.ID] = channeldb.AddRef{
+				Height: fwdPkg.Height,
+				Index:  idx,
+			}
+		}
+	}
 
-	lc.Lock()
+	// Any spilled HTLCs are irrevocably committed and thus eligible, so
+	// we restore them to the log first.
+	for htlcIndex := range lc.remoteUpdateLog.spilledHtlcs {
+		lc.remoteUpdateLog.lookupHtlc(htlcIndex)
+	}
+
+	// We create the failures before failing any HTLC, so that we don't
+	// leave fails behind which the caller doesn't know about if we can't
+	// create one of them.
+	var (
+		htlcs   []*PaymentDescriptor
+		reasons []lnwire.OpaqueReason
+	)
+	for e := lc.remoteUpdateLog.Front(); e != nil; e = e.Next() {
+		htlc := e.Value.(*PaymentDescriptor)
+		if htlc.EntryType != Add {
+			continue
+		}
+
+		if _, ok := sourceRefs[htlc.HtlcIndex]; !ok {
+			continue
+		}
+
+		// An HTLC can only be failed once it has been irrevocably
+		// committed to by both parties.
+		if htlc.addCommitHeightLocal == 0 ||
+			htlc.addCommitHeightRemote == 0 {
+
+			continue
+		}
+
+		if lc.remoteUpdateLog.htlcHasModification(htlc.HtlcIndex) {
+			continue
+		}
+
+		reason, err := failReason(htlc)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create failure for "+
+				"htlc %d: %w", htlc.HtlcIndex, err)
+		}
+
+		htlcs = append(htlcs, htlc)
+		reasons = append(reasons, reason)
+	}
+
+	failed := make([]uint64, 0, len(htlcs))
+	for i, htlc := range htlcs {
+		sourceRef := sourceRefs[htlc.HtlcIndex]
+
+		pd := &PaymentDescriptor{
+			Amount:      htlc.Amount,
+			RHash:       htlc.RHash,
+			ParentIndex: htlc.HtlcIndex,
+			LogIndex:    lc.localUpdateLog.logIndex,
+			EntryType:   Fail,
+			FailReason:  reasons[i],
+			SourceRef:   &sourceRef,
+		}
+
+		lc.localUpdateLog.appendUpdate(pd)
+		lc.remoteUpdateLog.markHtlcModified(htlc.HtlcIndex)
+		lc.metrics.HtlcsFailed++
+
+		failed = append(failed, htlc.HtlcIndex)
+	}
+
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i] < failed[j]
+	})
+
+	return failed, nil
+}
+
+// MalformedFailHTLC attempts to fail a targeted HTLC by its payment hash,
+// inserting an entry which will remove the target log entry within the next
+// commitment update. This method is intended to be called in order to cancel
+// in _incoming_ HTLC.
+//
+// The additional sourceRef specifies the location of the Add HTLC within a
+// forwarding package that this HTLC is failing. This value should never be
+// empty.
+//
+// NOTE: It is okay for sourceRef to be nil when unit testing the wallet.
+func (lc *LightningChannel) MalformedFailHTLC(htlcIndex uint64,
+	failCode lnwire.FailCode, shaOnionBlob [sha256.Size]byte,
+	sourceRef *channeldb.AddRef) error {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	return lc.malformedFailHTLC(
+		htlcIndex, failCode, shaOnionBlob, sourceRef,
+	)
+}
+
+// malformedFailHTLC is the unlocked version of MalformedFailHTLC.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) malformedFailHTLC(htlcIndex uint64,
+	failCode lnwire.FailCode, shaOnionBlob [sha256.Size]byte,
+	sourceRef *channeldb.AddRef) error {
+
+	htlc := lc.remoteUpdateLog.lookupHtlc(htlcIndex)
+	if htlc == nil {
+		return lc.unknownHtlcIndexErr(htlcIndex)
+	}
+
+	// Now that we know the HTLC exists, we'll ensure that we haven't
+	// already attempted to fail the HTLC.
+	if lc.remoteUpdateLog.htlcHasModification(htlcIndex) {
+		return ErrHtlcIndexAlreadyFailed(htlcIndex)
+	}
+
+	pd := &PaymentDescriptor{
+		Amount:       htlc.Amount,
+		RHash:        htlc.RHash,
+		ParentIndex:  htlcIndex,
+		LogIndex:     lc.localUpdateLog.logIndex,
+		EntryType:    MalformedFail,
+		FailCode:     failCode,
+		ShaOnionBlob: shaOnionBlob,
+		SourceRef:    sourceRef,
+	}
+
+	lc.localUpdateLog.appendUpdate(pd)
+
+	// With the fail added to the remote log, we'll now mark the HTLC as
+	// modified to prevent ourselves from accidentally attempting a
+	// duplicate fail.
+	lc.remoteUpdateLog.markHtlcModified(htlcIndex)
+
+	lc.metrics.HtlcsFailed++
+
+	return nil
+}
+
+// ReceiveFailHTLC attempts to cancel a targeted HTLC by its log index,
+// inserting an entry which will remove the target log entry within the next
+// commitment update. This method should be called in response to the upstream
+// party cancelling an outgoing HTLC.
+func (lc *LightningChannel) ReceiveFailHTLC(htlcIndex uint64, reason []byte,
+) error {
+
+	lc.Lock()
 	defer lc.Unlock()
 
 	htlc := lc.localUpdateLog.lookupHtlc(htlcIndex)
 	if htlc == nil {
-		return ErrUnknownHtlcIndex{lc.ShortChanID(), htlcIndex}
+		return lc.unknownHtlcIndexErr(htlcIndex)
 	}
 
 	// Now that we know the HTLC exists, we'll ensure that they haven't
@@ -6183,9 +9044,180 @@ func (lc *LightningChannel) ReceiveFailHTLC(htlcIndex uint64, reason []byte,
 	// duplicate fail.
 	lc.localUpdateLog.markHtlcModified(htlcIndex)
 
+	lc.metrics.HtlcsFailed++
+
+	return nil
+}
+
+// receiveMalformedFailHTLC cancels the outgoing HTLC with the given index in
+// response to the remote party failing it due to a malformed onion. The link
+// converts such fails into regular ones and uses ReceiveFailHTLC instead, but
+// replaying a persisted update must yield the same entry the state logs are
+// restored with.
+func (lc *LightningChannel) receiveMalformedFailHTLC(htlcIndex uint64,
+	failCode lnwire.FailCode, shaOnionBlob [sha256.Size]byte) error {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	htlc := lc.localUpdateLog.lookupHtlc(htlcIndex)
+	if htlc == nil {
+		return lc.unknownHtlcIndexErr(htlcIndex)
+	}
+
+	if lc.localUpdateLog.htlcHasModification(htlcIndex) {
+		return ErrHtlcIndexAlreadyFailed(htlcIndex)
+	}
+
+	pd := &PaymentDescriptor{
+		Amount:       htlc.Amount,
+		RHash:        htlc.RHash,
+		ParentIndex:  htlc.HtlcIndex,
+		LogIndex:     lc.remoteUpdateLog.logIndex,
+		EntryType:    MalformedFail,
+		FailCode:     failCode,
+		ShaOnionBlob: shaOnionBlob,
+	}
+
+	lc.remoteUpdateLog.appendUpdate(pd)
+	lc.localUpdateLog.markHtlcModified(htlcIndex)
+
+	lc.metrics.HtlcsFailed++
+
 	return nil
 }
 
+// ApplyLogUpdate replays a persisted log update by passing it to the method
+// that handles the update when it's created, e.g. AddHTLC, SettleHTLC or
+// UpdateFee for our own updates, and ReceiveHTLC, ReceiveHTLCSettle or
+// ReceiveUpdateFee for updates of the remote party. The remote flag indicates
+// which party proposed the update. This allows recovery and testing code to
+// replay a stream of updates, such as the ones of a CommitDiff, uniformly.
+//
+// The log index of the update is assigned anew by the update log, while the
+// index of an added HTLC must match the next HTLC index of the respective log,
+// so HTLCs are replayed in the order they were offered.
+//
+// NOTE: The forwarding package references and circuit keys of the updates
+// aren't persisted, so they're nil for the replayed updates. Updates must not
+// be applied concurrently with other updates of the channel.
+func (lc *LightningChannel) ApplyLogUpdate(update *channeldb.LogUpdate,
+	remote bool) error {
+
+	if remote {
+		return lc.applyRemoteLogUpdate(update)
+	}
+
+	switch msg := update.UpdateMsg.(type) {
+	case *lnwire.UpdateAddHTLC:
+		lc.RLock()
+		nextIndex := lc.localUpdateLog.htlcCounter
+		lc.RUnlock()
+
+		if msg.ID != nextIndex {
+			return fmt.Errorf("ID %d on HTLC add does not match "+
+				"expected next ID %d", msg.ID, nextIndex)
+		}
+
+		_, err := lc.AddHTLC(msg, nil)
+		return err
+
+	case *lnwire.UpdateFulfillHTLC:
+		return lc.SettleHTLC(
+			msg.PaymentPreimage, msg.ID, nil, nil, nil,
+		)
+
+	case *lnwire.UpdateFailHTLC:
+		return lc.FailHTLC(msg.ID, msg.Reason, nil, nil, nil)
+
+	case *lnwire.UpdateFailMalformedHTLC:
+		return lc.MalformedFailHTLC(
+			msg.ID, msg.FailureCode, msg.ShaOnionBlob, nil,
+		)
+
+	case *lnwire.UpdateFee:
+		return lc.UpdateFee(chainfee.SatPerKWeight(msg.FeePerKw))
+
+	default:
+		return fmt.Errorf("unknown log update message type %T",
+			update.UpdateMsg)
+	}
+}
+
+// applyRemoteLogUpdate replays a persisted log update of the remote party, see
+// ApplyLogUpdate.
+func (lc *LightningChannel) applyRemoteLogUpdate(
+	update *channeldb.LogUpdate) error {
+
+	switch msg := update.UpdateMsg.(type) {
+	case *lnwire.UpdateAddHTLC:
+		_, err := lc.ReceiveHTLC(msg)
+		return err
+
+	case *lnwire.UpdateFulfillHTLC:
+		return lc.ReceiveHTLCSettle(msg.PaymentPreimage, msg.ID)
+
+	case *lnwire.UpdateFailHTLC:
+		return lc.ReceiveFailHTLC(msg.ID, msg.Reason)
+
+	case *lnwire.UpdateFailMalformedHTLC:
+		return lc.receiveMalformedFailHTLC(
+			msg.ID, msg.FailureCode, msg.ShaOnionBlob,
+		)
+
+	case *lnwire.UpdateFee:
+		return lc.ReceiveUpdateFee(
+			chainfee.SatPerKWeight(msg.FeePerKw),
+		)
+
+	default:
+		return fmt.Errorf("unknown log update message type %T",
+			update.UpdateMsg)
+	}
+}
+
+// ChannelMetrics counts the state machine updates a channel has processed
+// since it was loaded. HTLC updates are counted regardless of which party
+// proposed them.
+type ChannelMetrics struct {
+	// CommitmentsSigned is the number of new commitments we signed for
+	// the remote party.
+	CommitmentsSigned uint64
+
+	// CommitmentsReceived is the number of new commitments signed by the
+	// remote party that we accepted.
+	CommitmentsReceived uint64
+
+	// RevocationsSent is the number of our prior states we revoked.
+	RevocationsSent uint64
+
+	// RevocationsReceived is the number of revocations we accepted from
+	// the remote party.
+	RevocationsReceived uint64
+
+	// HtlcsAdded is the number of HTLCs added to the update logs.
+	HtlcsAdded uint64
+
+	// HtlcsSettled is the number of HTLCs settled.
+	HtlcsSettled uint64
+
+	// HtlcsFailed is the number of HTLCs failed, including malformed
+	// fails.
+	HtlcsFailed uint64
+
+	// CircularHtlcs is the number of HTLCs whose payment hash was pending
+	// in the opposite direction of the channel when they were added.
+	CircularHtlcs uint64
+}
+
+// ChannelMetrics returns a snapshot of the update counters of the channel.
+func (lc *LightningChannel) ChannelMetrics() ChannelMetrics {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.metrics
+}
+
 // ChannelPoint returns the outpoint of the original funding transaction which
 // created this active channel. This outpoint is used throughout various
 // subsystems to uniquely identify an open channel.
@@ -6221,6 +9253,35 @@ func (lc *LightningChannel) AbsoluteThawHeight() (uint32, error) {
 	return lc.channelState.AbsoluteThawHeight()
 }
 
+// signContext returns the context of the commitment at the given height, to be
+// passed to a PolicySigner.
+func (lc *LightningChannel) signContext(height uint64,
+	localCommit bool) *SignContext {
+
+	return &SignContext{
+		ChanPoint:    lc.channelState.FundingOutpoint,
+		CommitHeight: height,
+		LocalCommit:  localCommit,
+	}
+}
+
+// signCommitTx signs the funding input of the passed commitment transaction
+// using the channel's sign descriptor, which must have its sighashes set for
+// the transaction. If our signer is a PolicySigner, the signature is requested
+// along with the passed context.
+func (lc *LightningChannel) signCommitTx(commitTx *wire.MsgTx,
+	signCtx *SignContext) (input.Signature, error) {
+
+	policySigner, ok := lc.Signer.(PolicySigner)
+	if !ok {
+		return lc.Signer.SignOutputRaw(commitTx, lc.signDesc)
+	}
+
+	return policySigner.SignOutputRawWithContext(
+		commitTx, lc.signDesc, signCtx,
+	)
+}
+
 // getSignedCommitTx function take the latest commitment transaction and
 // populate it with witness data.
 func (lc *LightningChannel) getSignedCommitTx() (*wire.MsgTx, error) {
@@ -6313,7 +9374,9 @@ func (lc *LightningChannel) getSignedCommitTx() (*wire.MsgTx, error) {
 		// With this, we then generate the full witness so the caller
 		// can broadcast a fully signed transaction.
 		lc.signDesc.SigHashes = input.NewTxSigHashesV0Only(commitTx)
-		ourSig, err := lc.Signer.SignOutputRaw(commitTx, lc.signDesc)
+		ourSig, err := lc.signCommitTx(
+			commitTx, lc.signContext(localCommit.CommitHeight, true),
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -6558,6 +9621,90 @@ func NewUnilateralCloseSummary(chanState *channeldb.OpenChannel, signer input.Si
 	}, nil
 }
 
+// RecoverUnilateralClose rebuilds the UnilateralCloseSummary for a commitment
+// transaction of the remote party that spent the funding output of the
+// channel, using the state of the channel alone. This can be used to recover
+// the sign descriptors needed to sweep our outputs, e.g. the to_remote output,
+// if the remote party force closed and the summary created at the time wasn't
+// persisted before a restart. Both their current commitment and any pending
+// commitments we've extended to them are recognized. An error wrapping
+// ErrUnknownRemoteCommit is returned if the transaction isn't any of those.
+func (lc *LightningChannel) RecoverUnilateralClose(spendTx *wire.MsgTx,
+	spendHeight uint32) (*UnilateralCloseSummary, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	remoteCommit, commitPoint, err := lc.remoteCommitForTx(spendTx)
+	if err != nil {
+		return nil, err
+	}
+
+	spendTxid := spendTx.TxHash()
+	commitSpend := &chainntnfs.SpendDetail{
+		SpentOutPoint:  &lc.channelState.FundingOutpoint,
+		SpenderTxHash:  &spendTxid,
+		SpendingTx:     spendTx,
+		SpendingHeight: int32(spendHeight),
+	}
+
+	return NewUnilateralCloseSummary(
+		lc.channelState, lc.Signer, commitSpend, *remoteCommit,
+		commitPoint,
+	)
+}
+
+// remoteCommitForTx returns the remote commitment that matches the passed
+// transaction, along with the commitment point of the remote party it was
+// created with.
+//
+// NOTE: This method MUST be called with the channel's mutex held.
+func (lc *LightningChannel) remoteCommitForTx(
+	tx *wire.MsgTx) (*channeldb.ChannelCommitment, *btcec.PublicKey,
+	error) {
+
+	// Without any remote commitments, e.g. for restored channels, we
+	// can't recognize the transaction.
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusRestored) {
+		return nil, nil, fmt.Errorf("%w: channel was restored",
+			ErrUnknownRemoteCommit)
+	}
+
+	txid := tx.TxHash()
+
+	remoteCommit := &lc.channelState.RemoteCommitment
+	if remoteCommit.CommitTx != nil &&
+		remoteCommit.CommitTx.TxHash() == txid {
+
+		return remoteCommit, lc.channelState.RemoteCurrentRevocation,
+			nil
+	}
+
+	// Otherwise, the transaction may be one of the commitments we've
+	// extended to them that they haven't revoked their prior state for
+	// yet.
+	pending, err := lc.channelState.RemoteCommitChainPending()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, diff := range pending {
+		if diff.Commitment.CommitTx.TxHash() != txid {
+			continue
+		}
+
+		commitPoint, err := lc.remoteCommitPoint(
+			diff.Commitment.CommitHeight,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return &diff.Commitment, commitPoint, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %v", ErrUnknownRemoteCommit, txid)
+}
+
 // IncomingHtlcResolution houses the information required to sweep any incoming
 // HTLC's that we know the preimage to. We'll need to sweep an HTLC manually
 // using this struct if we need to go on-chain for any reason, or if we detect
@@ -7312,36 +10459,171 @@ type LocalForceCloseSummary struct {
 	AnchorResolution *AnchorResolution
 }
 
-// ForceClose executes a unilateral closure of the transaction at the current
-// lowest commitment height of the channel. Following a force closure, all
-// state transitions, or modifications to the state update logs will be
-// rejected. Additionally, this function also returns a LocalForceCloseSummary
-// which includes the necessary details required to sweep all the time-locked
-// outputs within the commitment transaction.
-//
-// TODO(roasbeef): all methods need to abort if in dispute state
-func (lc *LightningChannel) ForceClose() (*LocalForceCloseSummary, error) {
-	lc.Lock()
-	defer lc.Unlock()
+// SweepItem describes an output of a force closed channel that we can sweep,
+// along with the height at which it matures.
+type SweepItem struct {
+	// OutPoint is the outpoint to sweep. For HTLCs that need to go to the
+	// second level first, this is the output of the second level
+	// transaction.
+	OutPoint wire.OutPoint
 
-	// If we've detected local data loss for this channel, then we won't
-	// allow a force close, as it may be the case that we have a dated
-	// version of the commitment, or this is actually a channel shell.
-	if lc.channelState.HasChanStatus(channeldb.ChanStatusLocalDataLoss) {
-		return nil, fmt.Errorf("%w: channel_state=%v",
-			ErrForceCloseLocalDataLoss,
-			lc.channelState.ChanStatus())
-	}
+	// Value is the value of the output.
+	Value btcutil.Amount
 
-	commitTx, err := lc.getSignedCommitTx()
-	if err != nil {
-		return nil, err
+	// SignDesc is the sign descriptor required to sweep the output.
+	SignDesc *input.SignDescriptor
+
+	// SecondLevelTx is the signed second level transaction that creates
+	// the output, or nil if the output is part of the commitment
+	// transaction itself.
+	SecondLevelTx *wire.MsgTx
+
+	// MaturityHeight is the earliest block height at which the output can
+	// be swept.
+	MaturityHeight uint32
+}
+
+// SweepSchedule returns all outputs of the force close summary we can sweep,
+// ordered by the height at which they mature. As the commitment transaction
+// may not have confirmed yet, the maturity heights assume that it, and any
+// second level transaction, confirms as soon as it's valid, at currentHeight
+// at the earliest. The to-self output matures after its CSV delay, and HTLCs
+// that need to go to the second level first wait for their CLTV expiry, if
+// any, followed by the CSV delay of the second level output.
+//
+// NOTE: Anchor outputs are not included, as they're swept to bump the fee of
+// the commitment instead of to recover funds.
+func (s *LocalForceCloseSummary) SweepSchedule(
+	currentHeight uint32) []SweepItem {
+
+	var items []SweepItem
+
+	if s.CommitResolution != nil {
+		signDesc := &s.CommitResolution.SelfOutputSignDesc
+		items = append(items, SweepItem{
+			OutPoint: s.CommitResolution.SelfOutPoint,
+			Value:    btcutil.Amount(signDesc.Output.Value),
+			SignDesc: signDesc,
+			MaturityHeight: currentHeight +
+				s.CommitResolution.MaturityDelay,
+		})
+	}
+
+	if s.HtlcResolutions == nil {
+		return items
+	}
+
+	for i := range s.HtlcResolutions.IncomingHTLCs {
+		htlc := &s.HtlcResolutions.IncomingHTLCs[i]
+
+		// The success transaction can be broadcast right away, so the
+		// output only has to wait for its CSV delay.
+		value := btcutil.Amount(htlc.SweepSignDesc.Output.Value)
+		items = append(items, SweepItem{
+			OutPoint:       htlc.ClaimOutpoint,
+			Value:          value,
+			SignDesc:       &htlc.SweepSignDesc,
+			SecondLevelTx:  htlc.SignedSuccessTx,
+			MaturityHeight: currentHeight + htlc.CsvDelay,
+		})
+	}
+
+	for i := range s.HtlcResolutions.OutgoingHTLCs {
+		htlc := &s.HtlcResolutions.OutgoingHTLCs[i]
+
+		// The timeout transaction, or the direct sweep of the HTLC,
+		// is only valid once the HTLC expired.
+		expiryHeight := currentHeight
+		if htlc.Expiry > expiryHeight {
+			expiryHeight = htlc.Expiry
+		}
+
+		// A direct sweep also needs the output's CSV delay to pass
+		// since the commitment confirmed, while a second level output
+		// needs it to pass since the timeout transaction confirmed.
+		maturityHeight := expiryHeight + htlc.CsvDelay
+		if htlc.SignedTimeoutTx == nil {
+			maturityHeight = currentHeight + htlc.CsvDelay
+			if expiryHeight > maturityHeight {
+				maturityHeight = expiryHeight
+			}
+		}
+
+		value := btcutil.Amount(htlc.SweepSignDesc.Output.Value)
+		items = append(items, SweepItem{
+			OutPoint:       htlc.ClaimOutpoint,
+			Value:          value,
+			SignDesc:       &htlc.SweepSignDesc,
+			SecondLevelTx:  htlc.SignedTimeoutTx,
+			MaturityHeight: maturityHeight,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].MaturityHeight < items[j].MaturityHeight
+	})
+
+	return items
+}
+
+// forceCloseOpts is the set of options used to modify a force close.
+type forceCloseOpts struct {
+	skipContractResolutions bool
+}
+
+// ForceCloseOpt is a functional option that can be used to modify a force
+// close.
+type ForceCloseOpt func(*forceCloseOpts)
+
+// WithSkipContractResolutions makes ForceClose skip the generation of the HTLC
+// resolutions, which requires signing a second level transaction for each HTLC
+// on the commitment. The returned summary only contains the signed commitment
+// transaction and the resolutions of our to-self and anchor outputs, so the
+// commitment can be broadcast quickly. The HTLC resolutions can be generated
+// afterwards with LocalHtlcResolutions.
+func WithSkipContractResolutions() ForceCloseOpt {
+	return func(o *forceCloseOpts) {
+		o.skipContractResolutions = true
+	}
+}
+
+// ForceClose executes a unilateral closure of the transaction at the current
+// lowest commitment height of the channel. Following a force closure, all
+// state transitions, or modifications to the state update logs will be
+// rejected. Additionally, this function also returns a LocalForceCloseSummary
+// which includes the necessary details required to sweep all the time-locked
+// outputs within the commitment transaction.
+//
+// TODO(roasbeef): all methods need to abort if in dispute state
+func (lc *LightningChannel) ForceClose(
+	closeOpts ...ForceCloseOpt) (*LocalForceCloseSummary, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	opts := &forceCloseOpts{}
+	for _, optFunc := range closeOpts {
+		optFunc(opts)
+	}
+
+	// If we've detected local data loss for this channel, then we won't
+	// allow a force close, as it may be the case that we have a dated
+	// version of the commitment, or this is actually a channel shell.
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusLocalDataLoss) {
+		return nil, fmt.Errorf("%w: channel_state=%v",
+			ErrForceCloseLocalDataLoss,
+			lc.channelState.ChanStatus())
+	}
+
+	commitTx, err := lc.getSignedCommitTx()
+	if err != nil {
+		return nil, err
 	}
 
 	localCommitment := lc.channelState.LocalCommitment
-	summary, err := NewLocalForceCloseSummary(
+	summary, err := newLocalForceCloseSummary(
 		lc.channelState, lc.Signer, commitTx,
-		localCommitment.CommitHeight,
+		localCommitment.CommitHeight, opts.skipContractResolutions,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("unable to gen force close "+
@@ -7350,32 +10632,61 @@ func (lc *LightningChannel) ForceClose() (*LocalForceCloseSummary, error) {
 
 	// Set the channel state to indicate that the channel is now in a
 	// contested state.
-	lc.status = channelDispute
+	lc.setStatus(ChannelDispute)
 
 	return summary, nil
 }
 
-// NewLocalForceCloseSummary generates a LocalForceCloseSummary from the given
-// channel state.  The passed commitTx must be a fully signed commitment
-// transaction corresponding to localCommit.
-func NewLocalForceCloseSummary(chanState *channeldb.OpenChannel,
-	signer input.Signer, commitTx *wire.MsgTx, stateNum uint64) (
-	*LocalForceCloseSummary, error) {
+// CommitOutputInfo describes the outputs of our signed commitment transaction
+// that we can spend from with a child transaction, e.g. to bump the fee of the
+// commitment using CPFP.
+type CommitOutputInfo struct {
+	// Txid is the txid of the commitment transaction.
+	Txid chainhash.Hash
 
-	// Re-derive the original pkScript for to-self output within the
-	// commitment transaction. We'll need this to find the corresponding
-	// output in the commitment transaction and potentially for creating
-	// the sign descriptor.
-	csvTimeout := uint32(chanState.LocalChanCfg.CsvDelay)
+	// Anchor is the resolution of our anchor output, which can be spent
+	// as soon as the commitment is broadcast. It is nil if the channel
+	// has no anchors, or our anchor isn't present on the commitment.
+	Anchor *AnchorResolution
 
-	// We use the passed state num to derive our scripts, since in case
-	// this is after recovery, our latest channels state might not be up to
-	// date.
-	revocation, err := chanState.RevocationProducer.AtIndex(stateNum)
+	// ToSelf is the resolution of our to_local output. As it's encumbered
+	// by a relative time-lock, it can only be spent once the commitment
+	// has confirmed and the maturity delay has passed. It is nil if our
+	// balance is dust.
+	ToSelf *CommitOutputResolution
+}
+
+// SignedCommitTxWithInfo returns our latest fully signed commitment
+// transaction, along with the outpoints and sign descriptors of the outputs
+// we can spend from it. Unlike ForceClose, this doesn't mark the channel as
+// being in dispute, so it can be used to prepare a fee bump ahead of
+// broadcasting the commitment.
+func (lc *LightningChannel) SignedCommitTxWithInfo() (*wire.MsgTx,
+	*CommitOutputInfo, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	// Like for a force close, we won't hand out a commitment that might
+	// be outdated.
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusLocalDataLoss) {
+		return nil, nil, fmt.Errorf("%w: channel_state=%v",
+			ErrForceCloseLocalDataLoss,
+			lc.channelState.ChanStatus())
+	}
+
+	commitTx, err := lc.getSignedCommitTx()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	chanState := lc.channelState
+	commitPoint, err := lc.commitPointAt(
+		chanState.LocalCommitment.CommitHeight,
+	)
+	if err != nil {
+		return nil, nil, err
 	}
-	commitPoint := input.ComputeCommitmentPoint(revocation[:])
 	keyRing := DeriveCommitmentKeys(
 		commitPoint, true, chanState.ChanType,
 		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
@@ -7385,6 +10696,38 @@ func NewLocalForceCloseSummary(chanState *channeldb.OpenChannel,
 	if chanState.ChanType.HasLeaseExpiration() {
 		leaseExpiry = chanState.ThawHeight
 	}
+	toSelf, err := newLocalCommitResolution(
+		chanState, commitTx, keyRing, leaseExpiry,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	anchor, err := NewAnchorResolution(chanState, commitTx, keyRing, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return commitTx, &CommitOutputInfo{
+		Txid:   commitTx.TxHash(),
+		Anchor: anchor,
+		ToSelf: toSelf,
+	}, nil
+}
+
+// newLocalCommitResolution returns the resolution of our delayed to_local
+// output within our commitment transaction, or nil if the output doesn't exist
+// as it's dust. The key ring must be the one of the commitment.
+func newLocalCommitResolution(chanState *channeldb.OpenChannel,
+	commitTx *wire.MsgTx, keyRing *CommitmentKeyRing,
+	leaseExpiry uint32) (*CommitOutputResolution, error) {
+
+	// Re-derive the original pkScript for to-self output within the
+	// commitment transaction. We'll need this to find the corresponding
+	// output in the commitment transaction and potentially for creating
+	// the sign descriptor.
+	csvTimeout := uint32(chanState.LocalChanCfg.CsvDelay)
+
 	toLocalScript, err := CommitScriptToSelf(
 		chanState.ChanType, chanState.IsInitiator, keyRing.ToLocalKey,
 		keyRing.RevocationKey, csvTimeout, leaseExpiry,
@@ -7468,6 +10811,72 @@ func NewLocalForceCloseSummary(chanState *channeldb.OpenChannel,
 		}
 	}
 
+	return commitResolution, nil
+}
+
+// NewLocalForceCloseSummary generates a LocalForceCloseSummary from the given
+// channel state.  The passed commitTx must be a fully signed commitment
+// transaction corresponding to localCommit.
+func NewLocalForceCloseSummary(chanState *channeldb.OpenChannel,
+	signer input.Signer, commitTx *wire.MsgTx, stateNum uint64) (
+	*LocalForceCloseSummary, error) {
+
+	return newLocalForceCloseSummary(
+		chanState, signer, commitTx, stateNum, false,
+	)
+}
+
+// newLocalForceCloseSummary generates a LocalForceCloseSummary from the given
+// channel state. If skipContractResolutions is true, the HTLC resolutions are
+// left empty.
+func newLocalForceCloseSummary(chanState *channeldb.OpenChannel,
+	signer input.Signer, commitTx *wire.MsgTx, stateNum uint64,
+	skipContractResolutions bool) (*LocalForceCloseSummary, error) {
+
+	// We use the passed state num to derive our scripts, since in case
+	// this is after recovery, our latest channels state might not be up to
+	// date.
+	revocation, err := chanState.RevocationProducer.AtIndex(stateNum)
+	if err != nil {
+		return nil, err
+	}
+	commitPoint := input.ComputeCommitmentPoint(revocation[:])
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, true, chanState.ChanType,
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+	)
+
+	var leaseExpiry uint32
+	if chanState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+	commitResolution, err := newLocalCommitResolution(
+		chanState, commitTx, keyRing, leaseExpiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The anchor resolution doesn't require any signatures, so we always
+	// generate it.
+	anchorResolution, err := NewAnchorResolution(
+		chanState, commitTx, keyRing, true,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to gen anchor "+
+			"resolution: %w", err)
+	}
+
+	if skipContractResolutions {
+		return &LocalForceCloseSummary{
+			ChanPoint:        chanState.FundingOutpoint,
+			CloseTx:          commitTx,
+			CommitResolution: commitResolution,
+			ChanSnapshot:     *chanState.Snapshot(),
+			AnchorResolution: anchorResolution,
+		}, nil
+	}
+
 	// Once the delay output has been found (if it exists), then we'll also
 	// need to create a series of sign descriptors for any lingering
 	// outgoing HTLC's that we'll need to claim as well. If this is after
@@ -7484,14 +10893,6 @@ func NewLocalForceCloseSummary(chanState *channeldb.OpenChannel,
 		return nil, fmt.Errorf("unable to gen htlc resolution: %w", err)
 	}
 
-	anchorResolution, err := NewAnchorResolution(
-		chanState, commitTx, keyRing, true,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to gen anchor "+
-			"resolution: %w", err)
-	}
-
 	return &LocalForceCloseSummary{
 		ChanPoint:        chanState.FundingOutpoint,
 		CloseTx:          commitTx,
@@ -7502,10 +10903,122 @@ func NewLocalForceCloseSummary(chanState *channeldb.OpenChannel,
 	}, nil
 }
 
+// LocalHtlcResolutions generates the resolutions of the HTLCs on our latest
+// local commitment transaction, i.e. the commitment broadcast by ForceClose.
+// This is used to obtain the resolutions after a force close that skipped
+// them, see WithSkipContractResolutions.
+func (lc *LightningChannel) LocalHtlcResolutions() (*HtlcResolutions, error) {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	chanState := lc.channelState
+	localCommit := chanState.LocalCommitment
+
+	commitPoint, err := lc.commitPointAt(localCommit.CommitHeight)
+	if err != nil {
+		return nil, err
+	}
+	keyRing := DeriveCommitmentKeys(
+		commitPoint, true, chanState.ChanType,
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+	)
+
+	var leaseExpiry uint32
+	if chanState.ChanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+
+	// The resolutions only reference the commitment by its txid, so we
+	// don't need its witness.
+	return extractHtlcResolutions(
+		chainfee.SatPerKWeight(localCommit.FeePerKw), true, lc.Signer,
+		localCommit.Htlcs, keyRing, &chanState.LocalChanCfg,
+		&chanState.RemoteChanCfg, localCommit.CommitTx,
+		chanState.ChanType, chanState.IsInitiator, leaseExpiry,
+	)
+}
+
+// SweepableLocalBalance returns the value we'd recover from our delayed
+// to-self output on the current local commitment after force closing, i.e.
+// the output value minus the fee of a transaction that sweeps it back into our
+// wallet at the given fee rate once the CSV delay has expired. Zero is
+// returned if our output is trimmed from the commitment, or if the swept
+// amount would be dust.
+//
+// NOTE: The channel state machine doesn't track whether the output was
+// already swept, callers that have seen the sweep confirm should no longer
+// rely on the returned value.
+func (lc *LightningChannel) SweepableLocalBalance(
+	sweepFeePerKw btcutil.Amount) btcutil.Amount {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	chanState := lc.channelState
+
+	// If our balance is below our dust limit, then the to-self output was
+	// trimmed from the commitment, and there's nothing to sweep.
+	localBalance := chanState.LocalCommitment.LocalBalance.ToSatoshis()
+	if localBalance < chanState.LocalChanCfg.DustLimit {
+		return 0
+	}
+
+	// Estimate the weight of a transaction that spends the to-self output
+	// using the time lock path into a single wallet output.
+	var (
+		weightEstimate input.TxWeightEstimator
+		outputDust     btcutil.Amount
+	)
+	switch {
+	case chanState.ChanType.IsTaproot():
+		weightEstimate.AddWitnessInput(input.TaprootToLocalWitnessSize)
+		weightEstimate.AddP2TROutput()
+		outputDust = DustLimitForSize(input.P2TRSize)
+
+	case chanState.ChanType.HasLeaseExpiration() && chanState.IsInitiator:
+		weightEstimate.AddWitnessInput(
+			input.ToLocalTimeoutWitnessSize +
+				input.LeaseWitnessScriptSizeOverhead,
+		)
+		weightEstimate.AddP2WKHOutput()
+		outputDust = DustLimitForSize(input.P2WPKHSize)
+
+	default:
+		weightEstimate.AddWitnessInput(input.ToLocalTimeoutWitnessSize)
+		weightEstimate.AddP2WKHOutput()
+		outputDust = DustLimitForSize(input.P2WPKHSize)
+	}
+
+	sweepFee := chainfee.SatPerKWeight(sweepFeePerKw).FeeForWeight(
+		int64(weightEstimate.Weight()),
+	)
+
+	// If the output doesn't cover the fee, or the remainder would be dust,
+	// then it isn't economical to sweep.
+	if localBalance-sweepFee < outputDust {
+		return 0
+	}
+
+	return localBalance - sweepFee
+}
+
 // chanCloseOpt is a functional option that can be used to modify the co-op
 // close process.
 type chanCloseOpt struct {
 	musigSession *MusigSession
+
+	// deliveryScriptClasses is the set of script classes that are
+	// accepted for both the local and remote delivery scripts.
+	deliveryScriptClasses []txscript.ScriptClass
+
+	// initiatorFirstOutputs indicates that the outputs of the close
+	// transaction should be ordered with the channel initiator's output
+	// first, instead of using BIP 69.
+	initiatorFirstOutputs bool
+
+	// proposalPolicy is the rule used to pick one of two conflicting
+	// close proposals.
+	proposalPolicy CloseProposalPolicy
 }
 
 // ChanCloseOpt is a closure type that cen be used to modify the set of default
@@ -7514,7 +11027,9 @@ type ChanCloseOpt func(*chanCloseOpt)
 
 // defaultCloseOpts is the default set of close options.
 func defaultCloseOpts() *chanCloseOpt {
-	return &chanCloseOpt{}
+	return &chanCloseOpt{
+		deliveryScriptClasses: DefaultDeliveryScriptClasses,
+	}
 }
 
 // WithCoopCloseMusigSession can be used to apply an existing musig2 session to
@@ -7526,44 +11041,146 @@ func WithCoopCloseMusigSession(session *MusigSession) ChanCloseOpt {
 	}
 }
 
-// CreateCloseProposal is used by both parties in a cooperative channel close
-// workflow to generate proposed close transactions and signatures. This method
-// should only be executed once all pending HTLCs (if any) on the channel have
-// been cleared/removed. Upon completion, the source channel will shift into
-// the "closing" state, which indicates that all incoming/outgoing HTLC
-// requests should be rejected. A signature for the closing transaction is
-// returned.
+// WithDeliveryScriptClasses overrides the set of script classes that are
+// accepted as delivery scripts of the co-op close transaction. By default
+// DefaultDeliveryScriptClasses is used.
+func WithDeliveryScriptClasses(classes ...txscript.ScriptClass) ChanCloseOpt {
+	return func(opts *chanCloseOpt) {
+		opts.deliveryScriptClasses = classes
+	}
+}
+
+// WithInitiatorFirstCloseOutputs orders the outputs of the co-op close
+// transaction with the output of the channel initiator first, instead of
+// sorting them according to BIP 69. This must only be used with peers that
+// are known to expect this order, e.g. based on a negotiated feature, as
+// both parties need to construct the same transaction.
+func WithInitiatorFirstCloseOutputs() ChanCloseOpt {
+	return func(opts *chanCloseOpt) {
+		opts.initiatorFirstOutputs = true
+	}
+}
+
+// CloseProposalPolicy is the rule used to pick the fee of the cooperative
+// close transaction if both parties concurrently proposed a close with
+// different fees. Both parties must use the same policy to agree on the fee.
+type CloseProposalPolicy uint8
+
+const (
+	// CloseProposalInitiatorWins picks the proposal of the channel
+	// initiator, as the initiator pays the fee of the close transaction.
+	// This is the default policy.
+	CloseProposalInitiatorWins CloseProposalPolicy = iota
+
+	// CloseProposalLowerFeeWins picks the proposal with the lower fee.
+	CloseProposalLowerFeeWins
+)
+
+// String returns a human-readable description of the policy.
+func (p CloseProposalPolicy) String() string {
+	switch p {
+	case CloseProposalInitiatorWins:
+		return "initiator wins"
+
+	case CloseProposalLowerFeeWins:
+		return "lower fee wins"
+
+	default:
+		return fmt.Sprintf("unknown policy %d", uint8(p))
+	}
+}
+
+// WithCloseProposalPolicy sets the rule used by ResolveCloseProposal to pick
+// one of two conflicting close proposals. By default the proposal of the
+// channel initiator is picked.
+func WithCloseProposalPolicy(policy CloseProposalPolicy) ChanCloseOpt {
+	return func(opts *chanCloseOpt) {
+		opts.proposalPolicy = policy
+	}
+}
+
+// DefaultDeliveryScriptClasses is the default set of script classes we accept
+// as delivery scripts of a co-op close transaction: P2PKH, P2SH, P2WPKH and
+// P2WSH.
+var DefaultDeliveryScriptClasses = []txscript.ScriptClass{
+	txscript.PubKeyHashTy,
+	txscript.ScriptHashTy,
+	txscript.WitnessV0PubKeyHashTy,
+	txscript.WitnessV0ScriptHashTy,
+}
+
+// AnySegwitDeliveryScriptClasses extends DefaultDeliveryScriptClasses with
+// taproot and future segwit versions. It must only be used if
+// option_shutdown_anysegwit has been negotiated with the remote party.
+var AnySegwitDeliveryScriptClasses = append(
+	append([]txscript.ScriptClass{}, DefaultDeliveryScriptClasses...),
+	txscript.WitnessV1TaprootTy, txscript.WitnessUnknownTy,
+)
+
+// validateDeliveryScripts ensures that both the local and remote delivery
+// scripts are of one of the allowed script classes, so we never sign a co-op
+// close transaction that won't be relayed by the network.
+func validateDeliveryScripts(allowed []txscript.ScriptClass,
+	scripts ...[]byte) error {
+
+	for _, script := range scripts {
+		class := txscript.GetScriptClass(script)
+
+		var isAllowed bool
+		for _, allowedClass := range allowed {
+			if class == allowedClass {
+				isAllowed = true
+				break
+			}
+		}
+
+		if !isAllowed {
+			return fmt.Errorf("%w: %x is of type %v",
+				ErrNonStandardDeliveryScript, script, class)
+		}
+	}
+
+	return nil
+}
+
+// createCoopCloseTx builds the unsigned cooperative close transaction paying
+// out the current settled balances minus the proposed fee to the passed
+// delivery scripts. Our settled balance after fees is returned along with the
+// transaction.
 //
-// TODO(roasbeef): caller should initiate signal to reject all incoming HTLCs,
-// settle any in flight.
-func (lc *LightningChannel) CreateCloseProposal(proposedFee btcutil.Amount,
-	localDeliveryScript []byte, remoteDeliveryScript []byte,
-	closeOpts ...ChanCloseOpt) (input.Signature, *chainhash.Hash,
-	btcutil.Amount, error) {
+// NOTE: This method MUST be called with the channel's mutex held.
+func (lc *LightningChannel) createCoopCloseTx(proposedFee btcutil.Amount,
+	localDeliveryScript, remoteDeliveryScript []byte,
+	opts *chanCloseOpt) (*wire.MsgTx, btcutil.Amount, error) {
 
-	lc.Lock()
-	defer lc.Unlock()
+	// If we committed to an upfront shutdown script when opening the
+	// channel, then we're only allowed to pay out to that script.
+	upfrontScript := lc.channelState.LocalShutdownScript
+	if len(upfrontScript) != 0 &&
+		!bytes.Equal(upfrontScript, localDeliveryScript) {
 
-	// If we've already closed the channel, then ignore this request.
-	if lc.status == channelClosed {
-		// TODO(roasbeef): check to ensure no pending payments
-		return nil, nil, 0, ErrChanClosing
+		return nil, 0, fmt.Errorf("%w: expected %x, got %x",
+			ErrUpfrontShutdownScriptMismatch, upfrontScript,
+			localDeliveryScript)
 	}
 
-	opts := defaultCloseOpts()
-	for _, optFunc := range closeOpts {
-		optFunc(opts)
+	// Before building the close transaction, make sure that both outputs
+	// will be standard.
+	err := validateDeliveryScripts(
+		opts.deliveryScriptClasses, localDeliveryScript,
+		remoteDeliveryScript,
+	)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// Get the final balances after subtracting the proposed fee, taking
-	// care not to persist the adjusted balance, as the feeRate may change
-	// during the channel closing process.
+	// Get the final balances after subtracting the proposed fee.
 	ourBalance, theirBalance, err := CoopCloseBalance(
 		lc.channelState.ChanType, lc.channelState.IsInitiator,
 		proposedFee, lc.channelState.LocalCommitment,
 	)
 	if err != nil {
-		return nil, nil, 0, err
+		return nil, 0, err
 	}
 
 	var closeTxOpts []CloseTxOpt
@@ -7573,6 +11190,12 @@ func (lc *LightningChannel) CreateCloseProposal(proposedFee btcutil.Amount,
 		closeTxOpts = append(closeTxOpts, WithRBFCloseTx())
 	}
 
+	if opts.initiatorFirstOutputs {
+		closeTxOpts = append(closeTxOpts, WithInitiatorFirstOutputs(
+			lc.channelState.IsInitiator,
+		))
+	}
+
 	closeTx := CreateCooperativeCloseTx(
 		fundingTxIn(lc.channelState), lc.channelState.LocalChanCfg.DustLimit,
 		lc.channelState.RemoteChanCfg.DustLimit, ourBalance, theirBalance,
@@ -7584,6 +11207,82 @@ func (lc *LightningChannel) CreateCloseProposal(proposedFee btcutil.Amount,
 	// negative output.
 	tx := btcutil.NewTx(closeTx)
 	if err := blockchain.CheckTransactionSanity(tx); err != nil {
+		return nil, 0, err
+	}
+
+	return closeTx, ourBalance, nil
+}
+
+// SimulateCooperativeClose builds the cooperative close transaction that would
+// result from closing the channel with the proposed fee and delivery scripts,
+// without signing it. The unsigned transaction is returned along with our
+// settled balance after fees. Unlike CreateCloseProposal, the status of the
+// channel isn't modified, so this can be used to preview a close.
+func (lc *LightningChannel) SimulateCooperativeClose(proposedFee btcutil.Amount,
+	localDeliveryScript, remoteDeliveryScript []byte,
+	closeOpts ...ChanCloseOpt) (*wire.MsgTx, btcutil.Amount, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	if lc.status == ChannelClosed {
+		return nil, 0, ErrChanClosing
+	}
+
+	opts := defaultCloseOpts()
+	for _, optFunc := range closeOpts {
+		optFunc(opts)
+	}
+
+	return lc.createCoopCloseTx(
+		proposedFee, localDeliveryScript, remoteDeliveryScript, opts,
+	)
+}
+
+// CreateCloseProposal is used by both parties in a cooperative channel close
+// workflow to generate proposed close transactions and signatures. This method
+// should only be executed once all pending HTLCs (if any) on the channel have
+// been cleared/removed. Upon completion, the source channel will shift into
+// the "closing" state, which indicates that all incoming/outgoing HTLC
+// requests should be rejected. A signature for the closing transaction is
+// returned. ErrNonStandardDeliveryScript is returned if either of the delivery
+// scripts isn't of an allowed type, see WithDeliveryScriptClasses, and
+// ErrUpfrontShutdownScriptMismatch if the local delivery script doesn't match
+// the upfront shutdown script committed to at channel open.
+//
+// TODO(roasbeef): caller should initiate signal to reject all incoming HTLCs,
+// settle any in flight.
+func (lc *LightningChannel) CreateCloseProposal(proposedFee btcutil.Amount,
+	localDeliveryScript []byte, remoteDeliveryScript []byte,
+	closeOpts ...ChanCloseOpt) (input.Signature, *chainhash.Hash,
+	btcutil.Amount, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	// If we've already closed the channel, then ignore this request.
+	if lc.status == ChannelClosed {
+		// TODO(roasbeef): check to ensure no pending payments
+		return nil, nil, 0, ErrChanClosing
+	}
+
+	if lc.agreedCloseFee != nil && proposedFee != *lc.agreedCloseFee {
+		return nil, nil, 0, fmt.Errorf("%w: proposed %v, agreed %v",
+			ErrCloseFeeMismatch, proposedFee, *lc.agreedCloseFee)
+	}
+
+	opts := defaultCloseOpts()
+	for _, optFunc := range closeOpts {
+		optFunc(opts)
+	}
+
+	// Build the close transaction, taking care not to persist the
+	// adjusted balance, as the feeRate may change during the channel
+	// closing process.
+	closeTx, ourBalance, err := lc.createCoopCloseTx(
+		proposedFee, localDeliveryScript, remoteDeliveryScript, opts,
+	)
+	if err != nil {
 		return nil, nil, 0, err
 	}
 
@@ -7610,17 +11309,117 @@ func (lc *LightningChannel) CreateCloseProposal(proposedFee btcutil.Amount,
 
 	// As everything checks out, indicate in the channel status that a
 	// channel closure has been initiated.
-	lc.status = channelClosing
+	lc.setStatus(ChannelClosing)
+	lc.localCloseFee = &proposedFee
 
 	closeTXID := closeTx.TxHash()
 	return sig, &closeTXID, ourBalance, nil
 }
 
+// ResolveCloseProposal returns the fee of the cooperative close transaction
+// given the fee of the close proposal we received from the remote party. If we
+// have an outstanding proposal of our own with a different fee, i.e. both
+// parties proposed a close concurrently, the fee is picked according to the
+// policy set by WithCloseProposalPolicy. As both parties apply the same rule,
+// they agree on the fee without another round of negotiation. Otherwise the
+// remote party's fee is returned.
+//
+// The returned fee is recorded as the agreed upon fee, and both
+// CreateCloseProposal and CompleteCooperativeClose reject any other fee with
+// ErrCloseFeeMismatch until the close is aborted. If our proposal lost, the
+// caller must create a new proposal with the agreed upon fee.
+func (lc *LightningChannel) ResolveCloseProposal(remoteFee btcutil.Amount,
+	closeOpts ...ChanCloseOpt) (btcutil.Amount, error) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	if lc.status == ChannelClosed {
+		return 0, ErrChanClosing
+	}
+
+	opts := defaultCloseOpts()
+	for _, optFunc := range closeOpts {
+		optFunc(opts)
+	}
+
+	agreedFee := remoteFee
+	if lc.localCloseFee != nil && *lc.localCloseFee != remoteFee {
+		localFee := *lc.localCloseFee
+
+		switch opts.proposalPolicy {
+		case CloseProposalInitiatorWins:
+			if lc.channelState.IsInitiator {
+				agreedFee = localFee
+			}
+
+		case CloseProposalLowerFeeWins:
+			if localFee < remoteFee {
+				agreedFee = localFee
+			}
+
+		default:
+			return 0, fmt.Errorf("unknown close proposal policy: "+
+				"%v", opts.proposalPolicy)
+		}
+
+		lc.log.Infof("Resolved conflicting close proposals with "+
+			"local fee %v and remote fee %v to %v (%v)", localFee,
+			remoteFee, agreedFee, opts.proposalPolicy)
+	}
+
+	lc.agreedCloseFee = &agreedFee
+
+	return agreedFee, nil
+}
+
+// AbortCooperativeClose gives up on the cooperative close negotiation of the
+// channel, such as when the remote party stops responding after the shutdown
+// exchange, and forgets any close proposal of the remote party resolved by
+// ResolveCloseProposal. The abort is only possible until we sign a closing
+// transaction with CreateCloseProposal: from then on the remote party can
+// broadcast it at any time, which would make any HTLC added afterwards vanish
+// on chain, so ErrCoopCloseSigned is returned instead. ErrCoopCloseFinalized is
+// returned if the closing transaction was already completed by
+// CompleteCooperativeClose, or marked as broadcast.
+func (lc *LightningChannel) AbortCooperativeClose() error {
+	lc.Lock()
+	defer lc.Unlock()
+
+	if lc.status == ChannelClosed || lc.channelState.HasChanStatus(
+		channeldb.ChanStatusCoopBroadcasted,
+	) {
+
+		return ErrCoopCloseFinalized
+	}
+
+	if lc.localCloseFee != nil {
+		return ErrCoopCloseSigned
+	}
+
+	if lc.agreedCloseFee != nil {
+		lc.log.Infof("Aborting cooperative close")
+	}
+
+	lc.agreedCloseFee = nil
+
+	return nil
+}
+
 // CompleteCooperativeClose completes the cooperative closure of the target
 // active lightning channel. A fully signed closure transaction as well as the
 // signature itself are returned. Additionally, we also return our final
 // settled balance, which reflects any fees we may have paid.
 //
+// ErrNonStandardDeliveryScript is returned if either of the delivery scripts
+// isn't of an allowed type, see WithDeliveryScriptClasses.
+// ErrUpfrontShutdownScriptMismatch is returned if the local delivery script
+// doesn't match our upfront shutdown script, if one was set.
+// ErrCloseFeeMismatch is returned if the proposed fee differs from the fee
+// agreed upon by ResolveCloseProposal. The remote signature is verified
+// against the close transaction built from the fee and delivery scripts, so
+// it must commit to exactly this fee and output set.
+//
 // NOTE: The passed local and remote sigs are expected to be fully complete
 // signatures including the proper sighash byte.
 func (lc *LightningChannel) CompleteCooperativeClose(
@@ -7633,50 +11432,32 @@ func (lc *LightningChannel) CompleteCooperativeClose(
 	defer lc.Unlock()
 
 	// If the channel is already closed, then ignore this request.
-	if lc.status == channelClosed {
+	if lc.status == ChannelClosed {
 		// TODO(roasbeef): check to ensure no pending payments
 		return nil, 0, ErrChanClosing
 	}
 
+	if lc.agreedCloseFee != nil && proposedFee != *lc.agreedCloseFee {
+		return nil, 0, fmt.Errorf("%w: proposed %v, agreed %v",
+			ErrCloseFeeMismatch, proposedFee, *lc.agreedCloseFee)
+	}
+
 	opts := defaultCloseOpts()
 	for _, optFunc := range closeOpts {
 		optFunc(opts)
 	}
 
-	// Get the final balances after subtracting the proposed fee.
-	ourBalance, theirBalance, err := CoopCloseBalance(
-		lc.channelState.ChanType, lc.channelState.IsInitiator,
-		proposedFee, lc.channelState.LocalCommitment,
-	)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	var closeTxOpts []CloseTxOpt
-
-	// If this is a taproot channel, then we use an RBF'able funding input.
-	if lc.channelState.ChanType.IsTaproot() {
-		closeTxOpts = append(closeTxOpts, WithRBFCloseTx())
-	}
-
 	// Create the transaction used to return the current settled balance
 	// on this active channel back to both parties. In this current model,
 	// the initiator pays full fees for the cooperative close transaction.
-	closeTx := CreateCooperativeCloseTx(
-		fundingTxIn(lc.channelState), lc.channelState.LocalChanCfg.DustLimit,
-		lc.channelState.RemoteChanCfg.DustLimit, ourBalance, theirBalance,
-		localDeliveryScript, remoteDeliveryScript, closeTxOpts...,
+	closeTx, ourBalance, err := lc.createCoopCloseTx(
+		proposedFee, localDeliveryScript, remoteDeliveryScript, opts,
 	)
-
-	// Ensure that the transaction doesn't explicitly validate any
-	// consensus rules such as being too big, or having any value with a
-	// negative output.
-	tx := btcutil.NewTx(closeTx)
-	prevOut := lc.signDesc.Output
-	if err := blockchain.CheckTransactionSanity(tx); err != nil {
+	if err != nil {
 		return nil, 0, err
 	}
 
+	prevOut := lc.signDesc.Output
 	prevOutputFetcher := txscript.NewCannedPrevOutputFetcher(
 		prevOut.PkScript, prevOut.Value,
 	)
@@ -7737,11 +11518,35 @@ func (lc *LightningChannel) CompleteCooperativeClose(
 	// As the transaction is sane, and the scripts are valid we'll mark the
 	// channel now as closed as the closure transaction should get into the
 	// chain in a timely manner and possibly be re-broadcast by the wallet.
-	lc.status = channelClosed
+	lc.setStatus(ChannelClosed)
+
+	// Record the fee actually paid by the transaction, which can be
+	// larger than the proposed fee if a dust output was omitted.
+	var totalOut btcutil.Amount
+	for _, txOut := range closeTx.TxOut {
+		totalOut += btcutil.Amount(txOut.Value)
+	}
+	closeFee := lc.channelState.Capacity - totalOut
+	lc.lastCloseFee = &closeFee
 
 	return closeTx, ourBalance, nil
 }
 
+// LastCloseFee returns the fee paid by the cooperative close transaction
+// returned by the last successful call to CompleteCooperativeClose, computed
+// as the channel capacity minus the total value of its outputs. The boolean
+// is false if no cooperative close has been completed.
+func (lc *LightningChannel) LastCloseFee() (btcutil.Amount, bool) {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	if lc.lastCloseFee == nil {
+		return 0, false
+	}
+
+	return *lc.lastCloseFee, true
+}
+
 // AnchorResolutions is a set of anchor resolutions that's being used when
 // sweeping anchors during local channel force close.
 type AnchorResolutions struct {
@@ -7771,13 +11576,10 @@ func (lc *LightningChannel) NewAnchorResolutions() (*AnchorResolutions,
 	var resolutions AnchorResolutions
 
 	// Add anchor for local commitment tx, if any.
-	revocation, err := lc.channelState.RevocationProducer.AtIndex(
-		lc.currentHeight,
-	)
+	localCommitPoint, err := lc.commitPointAt(lc.currentHeight)
 	if err != nil {
 		return nil, err
 	}
-	localCommitPoint := input.ComputeCommitmentPoint(revocation[:])
 	localKeyRing := DeriveCommitmentKeys(
 		localCommitPoint, true, lc.channelState.ChanType,
 		&lc.channelState.LocalChanCfg, &lc.channelState.RemoteChanCfg,
@@ -7958,6 +11760,86 @@ func NewAnchorResolution(chanState *channeldb.OpenChannel,
 	}, nil
 }
 
+// CapacityBreakdown describes how the capacity of a channel is partitioned on
+// the current local commitment. All amounts are expressed in millisatoshis,
+// and together they add up to the full channel capacity.
+type CapacityBreakdown struct {
+	// LocalReserve is the part of our balance held back as the channel
+	// reserve. If our balance is below the reserve, this is the full
+	// balance.
+	LocalReserve lnwire.MilliSatoshi
+
+	// RemoteReserve is the part of the remote party's balance held back
+	// as the channel reserve. If their balance is below the reserve, this
+	// is their full balance.
+	RemoteReserve lnwire.MilliSatoshi
+
+	// LocalAvailable is our balance above the channel reserve.
+	LocalAvailable lnwire.MilliSatoshi
+
+	// RemoteAvailable is the remote party's balance above the channel
+	// reserve.
+	RemoteAvailable lnwire.MilliSatoshi
+
+	// CommittedHTLCs is the total value of all HTLCs on the commitment,
+	// including those that are trimmed to dust.
+	CommittedHTLCs lnwire.MilliSatoshi
+
+	// CommitFee is the fee of the commitment transaction, paid by the
+	// channel initiator.
+	CommitFee lnwire.MilliSatoshi
+
+	// AnchorOutputs is the value of the anchor outputs of the commitment,
+	// which is zero for channels without anchors.
+	AnchorOutputs lnwire.MilliSatoshi
+}
+
+// CapacityBreakdown returns how the capacity of the channel is partitioned
+// between the reserves, the available balances, the committed HTLCs and the
+// commitment fee, as of the current local commitment.
+func (lc *LightningChannel) CapacityBreakdown() CapacityBreakdown {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	chanState := lc.channelState
+	localCommit := chanState.LocalCommitment
+
+	// splitReserve splits the balance into the part covered by the
+	// reserve and the part available above it.
+	splitReserve := func(balance lnwire.MilliSatoshi,
+		reserve btcutil.Amount) (lnwire.MilliSatoshi,
+		lnwire.MilliSatoshi) {
+
+		reserveMSat := lnwire.NewMSatFromSatoshis(reserve)
+		if balance <= reserveMSat {
+			return balance, 0
+		}
+
+		return reserveMSat, balance - reserveMSat
+	}
+
+	var breakdown CapacityBreakdown
+	breakdown.LocalReserve, breakdown.LocalAvailable = splitReserve(
+		localCommit.LocalBalance, chanState.LocalChanCfg.ChanReserve,
+	)
+	breakdown.RemoteReserve, breakdown.RemoteAvailable = splitReserve(
+		localCommit.RemoteBalance, chanState.RemoteChanCfg.ChanReserve,
+	)
+
+	for _, htlc := range localCommit.Htlcs {
+		breakdown.CommittedHTLCs += htlc.Amt
+	}
+
+	breakdown.CommitFee = lnwire.NewMSatFromSatoshis(localCommit.CommitFee)
+	if chanState.ChanType.HasAnchors() {
+		breakdown.AnchorOutputs = lnwire.NewMSatFromSatoshis(
+			2 * anchorSize,
+		)
+	}
+
+	return breakdown
+}
+
 // AvailableBalance returns the current balance available for sending within
 // the channel. By available balance, we mean that if at this very instance a
 // new commitment were to be created which evals all the log entries, what
@@ -7982,8 +11864,24 @@ func (lc *LightningChannel) availableBalance() (lnwire.MilliSatoshi, int64) {
 	// We'll grab the current set of log updates that the remote has
 	// ACKed.
 	remoteACKedIndex := lc.localCommitChain.tip().theirMessageIndex
-	htlcView := lc.fetchHTLCView(remoteACKedIndex,
-		lc.localUpdateLog.logIndex)
+	htlcView := lc.fetchHTLCView(
+		remoteACKedIndex, lc.localUpdateLog.logIndex,
+	)
+
+	// Incoming HTLCs that the remote party already sent us, but that
+	// aren't yet part of our commitment, will be manifested on the next
+	// commitments as well. As they add weight that we must pay the fee
+	// for if we're the initiator, we add them to the view. We only
+	// include their adds, as their settles and fails could only increase
+	// our balance, which we can't rely on until they're committed.
+	for e := lc.remoteUpdateLog.Front(); e != nil; e = e.Next() {
+		htlc := e.Value.(*PaymentDescriptor)
+		if htlc.LogIndex < remoteACKedIndex || htlc.EntryType != Add {
+			continue
+		}
+
+		htlcView.theirUpdates = append(htlcView.theirUpdates, htlc)
+	}
 
 	// Calculate our available balance from our local commitment.
 	// TODO(halseth): could reuse parts validateCommitmentSanity to do this
@@ -8123,6 +12021,88 @@ func (lc *LightningChannel) availableCommitmentBalance(view *htlcView,
 	return ourBalance, commitWeight
 }
 
+// LiquidityReport returns how much we can currently receive (inbound) and send
+// (outbound) over the channel. The outbound liquidity is our available
+// balance, see AvailableBalance, which already excludes our channel reserve
+// and, if we're the initiator, the commitment fee for an additional HTLC. The
+// inbound liquidity is the symmetric figure for the remote party: their
+// balance minus their channel reserve, and minus the commitment fee for an
+// additional HTLC if they're the initiator. Both figures are evaluated on the
+// local and the remote commitment, and the lower one is reported.
+func (lc *LightningChannel) LiquidityReport() (inbound,
+	outbound lnwire.MilliSatoshi) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	outbound, _ = lc.availableBalance()
+
+	// Like in availableBalance, but from the remote party's point of
+	// view: we take all of their updates and ours they've ACK'd, along
+	// with our adds that will manifest on the next commitments.
+	localACKedIndex := lc.remoteCommitChain.tip().ourMessageIndex
+	htlcView := lc.fetchHTLCView(
+		lc.remoteUpdateLog.logIndex, localACKedIndex,
+	)
+	for e := lc.localUpdateLog.Front(); e != nil; e = e.Next() {
+		htlc := e.Value.(*PaymentDescriptor)
+		if htlc.LogIndex < localACKedIndex || htlc.EntryType != Add {
+			continue
+		}
+
+		htlcView.ourUpdates = append(htlcView.ourUpdates, htlc)
+	}
+
+	inbound = lc.inboundCommitmentBalance(htlcView, false)
+	remoteInbound := lc.inboundCommitmentBalance(htlcView, true)
+	if remoteInbound < inbound {
+		inbound = remoteInbound
+	}
+
+	return inbound, outbound
+}
+
+// inboundCommitmentBalance returns the balance the remote party has available
+// for sending HTLCs on the local or remote commitment given the htlcView.
+//
+// NOTE: This method MUST be called with the channel's mutex held.
+func (lc *LightningChannel) inboundCommitmentBalance(view *htlcView,
+	remoteChain bool) lnwire.MilliSatoshi {
+
+	_, theirBalance, commitWeight, filteredView, err := lc.computeView(
+		view, remoteChain, false,
+	)
+	if err != nil {
+		lc.log.Errorf("Unable to fetch inbound liquidity: %v", err)
+		return 0
+	}
+
+	// The remote party can never spend from their channel reserve.
+	theirReserve := lnwire.NewMSatFromSatoshis(
+		lc.channelState.RemoteChanCfg.ChanReserve,
+	)
+	if theirBalance <= theirReserve {
+		return 0
+	}
+	theirBalance -= theirReserve
+
+	// If they're the initiator, they also have to pay the commitment fee
+	// for the HTLC they add.
+	if !lc.channelState.IsInitiator {
+		htlcCommitFee := lnwire.NewMSatFromSatoshis(
+			filteredView.feePerKw.FeeForWeight(
+				commitWeight + input.HTLCWeight,
+			),
+		)
+		if theirBalance <= htlcCommitFee {
+			return 0
+		}
+		theirBalance -= htlcCommitFee
+	}
+
+	return theirBalance
+}
+
 // StateSnapshot returns a snapshot of the current fully committed state within
 // the channel.
 func (lc *LightningChannel) StateSnapshot() *channeldb.ChannelSnapshot {
@@ -8190,6 +12170,21 @@ func (lc *LightningChannel) UpdateFee(feePerKw chainfee.SatPerKWeight) error {
 		return err
 	}
 
+	// If a fee update we proposed earlier isn't covered by a commitment
+	// signature yet, the new rate supersedes it. We coalesce both into the
+	// existing update, so only a single fee update carrying the latest
+	// rate is committed. The remote party applies all fee updates it
+	// received in order, so it arrives at the same rate.
+	if pd := lc.unsignedLocalFeeUpdate(); pd != nil {
+		lc.log.Debugf("Fee update to %v sat/kw supersedes unsigned "+
+			"fee update to %v sat/kw", int64(feePerKw),
+			int64(pd.Amount.ToSatoshis()))
+
+		pd.Amount = lnwire.NewMSatFromSatoshis(btcutil.Amount(feePerKw))
+
+		return nil
+	}
+
 	pd := &PaymentDescriptor{
 		LogIndex:  lc.localUpdateLog.logIndex,
 		Amount:    lnwire.NewMSatFromSatoshis(btcutil.Amount(feePerKw)),
@@ -8201,6 +12196,51 @@ func (lc *LightningChannel) UpdateFee(feePerKw chainfee.SatPerKWeight) error {
 	return nil
 }
 
+// unsignedLocalFeeUpdate returns the latest fee update in our local update log
+// that isn't covered by a remote commitment yet, or nil if there is none.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) unsignedLocalFeeUpdate() *PaymentDescriptor {
+	signedIndex := lc.remoteCommitChain.tip().ourMessageIndex
+
+	for e := lc.localUpdateLog.Back(); e != nil; e = e.Prev() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.LogIndex < signedIndex {
+			return nil
+		}
+
+		if pd.EntryType == FeeUpdate {
+			return pd
+		}
+	}
+
+	return nil
+}
+
+// checkRelayFeeFloor returns ErrFeeRateBelowFloor if the passed fee rate is
+// below the minimum relay fee rate of the fee floor estimator, raised by the
+// configured margin. No check is done if no estimator is set.
+func (lc *LightningChannel) checkRelayFeeFloor(
+	feePerKw chainfee.SatPerKWeight) error {
+
+	if lc.feeFloorEstimator == nil {
+		return nil
+	}
+
+	relayFee := lc.feeFloorEstimator.RelayFeePerKW()
+	floor := relayFee + relayFee*chainfee.SatPerKWeight(
+		lc.feeFloorMargin,
+	)/100
+
+	if feePerKw < floor {
+		return fmt.Errorf("%w: fee_update=%v sat/kw, relay fee=%v "+
+			"sat/kw, floor=%v sat/kw", ErrFeeRateBelowFloor,
+			int64(feePerKw), int64(relayFee), int64(floor))
+	}
+
+	return nil
+}
+
 // ReceiveUpdateFee handles an updated fee sent from remote. This method will
 // return an error if called as channel initiator.
 func (lc *LightningChannel) ReceiveUpdateFee(feePerKw chainfee.SatPerKWeight) error {
@@ -8213,13 +12253,31 @@ func (lc *LightningChannel) ReceiveUpdateFee(feePerKw chainfee.SatPerKWeight) er
 		return fmt.Errorf("received fee update as initiator")
 	}
 
-	// TODO(roasbeef): or just modify to use the other balance?
+	// If we know the relay fee floor, we'll make sure that the commitment
+	// transaction would still be relayed at the proposed fee rate.
+	if err := lc.checkRelayFeeFloor(feePerKw); err != nil {
+		return err
+	}
+
 	pd := &PaymentDescriptor{
 		LogIndex:  lc.remoteUpdateLog.logIndex,
 		Amount:    lnwire.NewMSatFromSatoshis(btcutil.Amount(feePerKw)),
 		EntryType: FeeUpdate,
 	}
 
+	// As the remote party is the initiator, they pay the commitment fee.
+	// Before accepting the update, we'll make sure that applying it to our
+	// next local commitment won't dip their balance below the reserve we
+	// require them to keep, mirroring the check done in validateFeeRate
+	// for our own fee updates.
+	err := lc.validateCommitmentSanity(
+		lc.remoteUpdateLog.logIndex, lc.localUpdateLog.logIndex,
+		false, nil, pd,
+	)
+	if err != nil {
+		return err
+	}
+
 	lc.remoteUpdateLog.appendUpdate(pd)
 
 	return nil
@@ -8232,7 +12290,7 @@ func (lc *LightningChannel) generateRevocation(height uint64) (*lnwire.RevokeAnd
 	// Now that we've accept a new state transition, we send the remote
 	// party the revocation for our current commitment state.
 	revocationMsg := &lnwire.RevokeAndAck{}
-	commitSecret, err := lc.channelState.RevocationProducer.AtIndex(height)
+	commitSecret, err := lc.commitSecretAt(height)
 	if err != nil {
 		return nil, err
 	}
@@ -8252,14 +12310,10 @@ func (lc *LightningChannel) generateRevocation(height uint64) (*lnwire.RevokeAnd
 	//
 	// Put simply in the window slides to the left by one.
 	revHeight := height + 2
-	nextCommitSecret, err := lc.channelState.RevocationProducer.AtIndex(
-		revHeight,
-	)
+	revocationMsg.NextRevocationKey, err = lc.commitPointAt(revHeight)
 	if err != nil {
 		return nil, err
 	}
-
-	revocationMsg.NextRevocationKey = input.ComputeCommitmentPoint(nextCommitSecret[:])
 	revocationMsg.ChanID = lnwire.NewChanIDFromOutPoint(
 		&lc.channelState.FundingOutpoint,
 	)
@@ -8288,6 +12342,14 @@ type closeTxOpts struct {
 	// enableRBF indicates whether the cooperative close tx should signal
 	// RBF or not.
 	enableRBF bool
+
+	// initiatorFirst indicates that the outputs should be ordered with
+	// the output of the channel initiator first, instead of using BIP 69.
+	initiatorFirst bool
+
+	// localInitiator indicates whether we're the channel initiator. It's
+	// only used if initiatorFirst is set.
+	localInitiator bool
 }
 
 // defaultCloseTxOpts returns a closeTxOpts struct with default values.
@@ -8308,12 +12370,24 @@ func WithRBFCloseTx() CloseTxOpt {
 	}
 }
 
+// WithInitiatorFirstOutputs signals that the outputs of the cooperative close
+// tx shouldn't be sorted according to BIP 69, but placed with the output of
+// the channel initiator first. This is needed to interop with implementations
+// that don't sort the outputs.
+func WithInitiatorFirstOutputs(localInitiator bool) CloseTxOpt {
+	return func(o *closeTxOpts) {
+		o.initiatorFirst = true
+		o.localInitiator = localInitiator
+	}
+}
+
 // CreateCooperativeCloseTx creates a transaction which if signed by both
 // parties, then broadcast cooperatively closes an active channel. The creation
 // of the closure transaction is modified by a boolean indicating if the party
 // constructing the channel is the initiator of the closure. Currently it is
 // expected that the initiator pays the transaction fees for the closing
-// transaction in full.
+// transaction in full. The outputs are sorted according to BIP 69, unless
+// WithInitiatorFirstOutputs is passed.
 func CreateCooperativeCloseTx(fundingTxIn wire.TxIn,
 	localDust, remoteDust, ourBalance, theirBalance btcutil.Amount,
 	ourDeliveryScript, theirDeliveryScript []byte,
@@ -8339,20 +12413,35 @@ func CreateCooperativeCloseTx(fundingTxIn wire.TxIn,
 
 	// Create both cooperative closure outputs, properly respecting the
 	// dust limits of both parties.
+	var ourOutput, theirOutput *wire.TxOut
 	if ourBalance >= localDust {
-		closeTx.AddTxOut(&wire.TxOut{
+		ourOutput = &wire.TxOut{
 			PkScript: ourDeliveryScript,
 			Value:    int64(ourBalance),
-		})
+		}
 	}
 	if theirBalance >= remoteDust {
-		closeTx.AddTxOut(&wire.TxOut{
+		theirOutput = &wire.TxOut{
 			PkScript: theirDeliveryScript,
 			Value:    int64(theirBalance),
-		})
+		}
+	}
+
+	// Unless the initiator's output is to be placed first, the order we
+	// add the outputs in doesn't matter, as they're sorted afterwards.
+	outputs := []*wire.TxOut{ourOutput, theirOutput}
+	if opts.initiatorFirst && !opts.localInitiator {
+		outputs = []*wire.TxOut{theirOutput, ourOutput}
+	}
+	for _, output := range outputs {
+		if output != nil {
+			closeTx.AddTxOut(output)
+		}
 	}
 
-	txsort.InPlaceSort(closeTx)
+	if !opts.initiatorFirst {
+		txsort.InPlaceSort(closeTx)
+	}
 
 	return closeTx
 }
@@ -8403,6 +12492,19 @@ func (lc *LightningChannel) CalcFee(feeRate chainfee.SatPerKWeight) btcutil.Amou
 	return feeRate.FeeForWeight(CommitWeight(lc.channelState.ChanType))
 }
 
+// CloseFeePaid returns the fee we pay to miners to close this channel. While
+// the channel is open, this is the fee of our current commitment if we're the
+// initiator, which UpdateCommitment replaces on every state transition rather
+// than accumulating it, as only a single commitment can ever confirm. Once a
+// closing transaction has been broadcast, it reflects the fee of that
+// transaction instead.
+func (lc *LightningChannel) CloseFeePaid() btcutil.Amount {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.channelState.CloseFeePaid
+}
+
 // MaxFeeRate returns the maximum fee rate given an allocation of the channel
 // initiator's spendable balance along with the local reserve amount. This can
 // be useful to determine when we should stop proposing fee updates that exceed
@@ -8521,6 +12623,46 @@ func (lc *LightningChannel) RemoteNextRevocation() *btcec.PublicKey {
 	return lc.channelState.RemoteNextRevocation
 }
 
+// AddRemoteCommitPoints extends our revocation window by the given commitment
+// points of the remote party. The points must be for the commitments
+// following the one of their next revocation, in order. Combined with a
+// revocation window larger than one, this allows us to extend multiple
+// commitments to the remote party before receiving a revocation.
+func (lc *LightningChannel) AddRemoteCommitPoints(
+	points ...*btcec.PublicKey) error {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	return lc.channelState.AppendRemoteCommitPoints(points...)
+}
+
+// LocalCommitPoints returns the next num commitment points for our commitment
+// chain following the one we've already handed out as our next revocation.
+// These can be given to the remote party to extend their revocation window
+// towards us.
+func (lc *LightningChannel) LocalCommitPoints(
+	num uint16) ([]*btcec.PublicKey, error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	points := make([]*btcec.PublicKey, 0, num)
+	for i := uint64(0); i < uint64(num); i++ {
+		// Our next revocation is for the height following our current
+		// one, so the additional points start one height beyond that.
+		height := lc.currentHeight + 2 + i
+		commitPoint, err := lc.commitPointAt(height)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, commitPoint)
+	}
+
+	return points, nil
+}
+
 // IsInitiator returns true if we were the ones that initiated the funding
 // workflow which led to the creation of this channel. Otherwise, it returns
 // false.
@@ -8540,6 +12682,28 @@ func (lc *LightningChannel) CommitFeeRate() chainfee.SatPerKWeight {
 	return chainfee.SatPerKWeight(lc.channelState.LocalCommitment.FeePerKw)
 }
 
+// FeeRateHistory returns the persisted history of fee rate changes committed
+// to our local commitment transaction, ordered from oldest to newest.
+func (lc *LightningChannel) FeeRateHistory() ([]channeldb.FeeRateUpdate,
+	error) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.channelState.FeeRateHistory()
+}
+
+// EventLog returns the persisted log of significant events of the channel,
+// such as signed states, received revocations and fee updates, ordered from
+// oldest to newest. It's meant to reconstruct what happened to a channel
+// after the fact.
+func (lc *LightningChannel) EventLog() ([]channeldb.ChannelEvent, error) {
+	lc.RLock()
+	defer lc.RUnlock()
+
+	return lc.channelState.EventLog()
+}
+
 // IsPending returns true if the channel's funding transaction has been fully
 // confirmed, and false otherwise.
 func (lc *LightningChannel) IsPending() bool {
@@ -8609,11 +12773,36 @@ func (lc *LightningChannel) ActiveHtlcs() []channeldb.HTLC {
 	return lc.channelState.ActiveHtlcs()
 }
 
+// HTLCsByCommitment returns the HTLCs present on our current commitment and
+// on the current commitment of the remote party, i.e. the tails of both
+// commitment chains. In the middle of a state transition the two sets can
+// differ, as each commitment locks in updates once it's revoked by its owner.
+// In both sets, the Incoming flag is from our point of view, and dust HTLCs
+// are included with an output index of -1.
+func (lc *LightningChannel) HTLCsByCommitment() (localHTLCs,
+	remoteHTLCs []channeldb.HTLC) {
+
+	lc.RLock()
+	defer lc.RUnlock()
+
+	localHTLCs = lc.localCommitChain.tail().toDiskCommit(true).Htlcs
+	remoteHTLCs = lc.remoteCommitChain.tail().toDiskCommit(false).Htlcs
+
+	return localHTLCs, remoteHTLCs
+}
+
 // LocalChanReserve returns our local ChanReserve requirement for the remote party.
 func (lc *LightningChannel) LocalChanReserve() btcutil.Amount {
 	return lc.channelState.LocalChanCfg.ChanReserve
 }
 
+// RemoteReserve returns the ChanReserve the remote party is required to keep
+// on their side of the channel. Any update that would decrease their balance
+// below this amount is rejected.
+func (lc *LightningChannel) RemoteReserve() btcutil.Amount {
+	return lc.channelState.RemoteChanCfg.ChanReserve
+}
+
 // NextLocalHtlcIndex returns the next unallocated local htlc index. To ensure
 // this always returns the next index that has been not been allocated, this
 // will first try to examine any pending commitments, before falling back to the
@@ -8640,8 +12829,9 @@ func (lc *LightningChannel) FwdMinHtlc() lnwire.MilliSatoshi {
 // have height greater than or equal to localMessageIndex (not on our commit),
 // and height less than remoteMessageIndex (on the remote commit).
 //
-// NOTE: remoteMessageIndex is the height on the tip because this is called
-// before the tail is advanced to the tip during ReceiveRevocation.
+// NOTE: remoteMessageIndex is the height on the commitment following the tail
+// because this is called before the tail is advanced during
+// ReceiveRevocation.
 func (lc *LightningChannel) unsignedLocalUpdates(remoteMessageIndex,
 	localMessageIndex uint64, chanID lnwire.ChannelID) []channeldb.LogUpdate {
 
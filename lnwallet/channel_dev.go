@@ -0,0 +1,50 @@
+//go:build dev
+// +build dev
+
+package lnwallet
+
+// balanceInvariantChecks switches on the balance invariant check of every
+// commitment that is accepted by RevokeCurrentCommitment or
+// ReceiveRevocation.
+const balanceInvariantChecks = true
+
+// InjectPreimage settles the incoming HTLC at the passed index of the remote
+// update log using the given preimage. An error is returned if the HTLC is
+// unknown, has already been modified, or if the preimage doesn't match the
+// payment hash of the HTLC. The settle is added to our local update log, so
+// it'll be included in our next commitment signature.
+//
+// NOTE: THIS METHOD IS INTENDED FOR TESTING PURPOSES ONLY, and is only
+// compiled into binaries built with the dev build tag. It can be used to feed a
+// preimage into an HTLC that's being held, e.g. by the hodl flags, without
+// going through the switch.
+func (lc *LightningChannel) InjectPreimage(htlcIndex uint64,
+	preimage [32]byte) error {
+
+	lc.log.Debugf("Injecting preimage for incoming htlc index %v",
+		htlcIndex)
+
+	return lc.SettleHTLC(preimage, htlcIndex, nil, nil, nil)
+}
+
+// SetStateHintObfuscator overrides the obfuscator used to encode the
+// commitment height within the locktime and sequence of the remote commitment
+// transactions we sign from now on. Our own commitments, and the decoding of
+// state hints by CommitmentStateHint, keep using the derived obfuscator, so
+// the channel still passes the state hint validation when it's loaded again.
+//
+// NOTE: THIS METHOD IS INTENDED FOR TESTING PURPOSES ONLY, and is only
+// compiled into binaries built with the dev build tag. It can be used by
+// interop tests to check how the counterparty handles a commitment whose state
+// hint doesn't match its own obfuscator.
+func (lc *LightningChannel) SetStateHintObfuscator(
+	obfuscator [StateHintSize]byte) {
+
+	lc.Lock()
+	defer lc.Unlock()
+
+	lc.log.Debugf("Overriding remote state hint obfuscator with %x",
+		obfuscator)
+
+	lc.commitBuilder.remoteObfuscator = &obfuscator
+}
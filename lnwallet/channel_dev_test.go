@@ -0,0 +1,145 @@
+//go:build dev
+// +build dev
+
+package lnwallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInjectPreimage asserts that a preimage injected for a held incoming HTLC
+// is validated against its payment hash, and settles the HTLC once the
+// settle is locked in.
+func TestInjectPreimage(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Alice adds an HTLC to Bob, which is then locked in.
+	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	htlc, preimage := createHTLC(1, htlcAmt)
+	htlc.ID = 0
+
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	htlcIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Injecting a preimage for an unknown HTLC, or one that doesn't match
+	// the payment hash, should fail.
+	err = bobChannel.InjectPreimage(htlcIndex+1, preimage)
+	require.ErrorAs(t, err, &ErrUnknownHtlcIndex{})
+
+	var wrongPreimage [32]byte
+	err = bobChannel.InjectPreimage(htlcIndex, wrongPreimage)
+	require.ErrorAs(t, err, &ErrInvalidSettlePreimage{})
+
+	// The correct preimage should be accepted, but only once.
+	require.NoError(t, bobChannel.InjectPreimage(htlcIndex, preimage))
+	err = bobChannel.InjectPreimage(htlcIndex, preimage)
+	require.ErrorIs(t, err, ErrHtlcIndexAlreadySettled(htlcIndex))
+
+	// Alice receives the settle, and once it's locked in the HTLC amount
+	// should be credited to Bob.
+	bobBalance := bobChannel.channelState.LocalCommitment.LocalBalance
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimage, htlcIndex))
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
+
+	require.Equal(
+		t, bobBalance+htlcAmt,
+		bobChannel.channelState.LocalCommitment.LocalBalance,
+	)
+	require.Empty(t, bobChannel.channelState.LocalCommitment.Htlcs)
+	require.Empty(t, aliceChannel.channelState.LocalCommitment.Htlcs)
+}
+
+// TestSetStateHintObfuscator asserts that overriding the state hint
+// obfuscator changes the state hint of the commitments we sign, which the
+// remote party then rejects, while our own commitments keep their state hint,
+// so the channel can still be loaded.
+func TestSetStateHintObfuscator(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	obfuscator := [StateHintSize]byte{1, 2, 3, 4, 5, 6}
+	aliceChannel.SetStateHintObfuscator(obfuscator)
+
+	// Bob's commitment signed by Alice now carries a state hint Bob
+	// doesn't expect, so her signature doesn't verify.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.ErrorAs(t, err, new(*InvalidCommitSigError))
+
+	// Alice still decodes state hints using the derived obfuscator, and
+	// her own commitment is untouched, so she can be loaded again.
+	commitTx := aliceChannel.channelState.LocalCommitment.CommitTx
+	hint, err := aliceChannel.CommitmentStateHint(commitTx)
+	require.NoError(t, err)
+	require.Equal(
+		t, aliceChannel.channelState.LocalCommitment.CommitHeight,
+		hint,
+	)
+
+	_, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool,
+	)
+	require.NoError(t, err)
+}
+
+// TestBalanceInvariantChecks asserts that a commitment with a corrupted
+// balance is rejected by RevokeCurrentCommitment and ReceiveRevocation.
+func TestBalanceInvariantChecks(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// We corrupt the balance of the new commitments of both chains, which
+	// makes Bob refuse to revoke his current commitment, and Alice refuse
+	// to accept his revocation.
+	bobChannel.localCommitChain.next().theirBalance++
+	_, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.ErrorAs(t, err, &ErrBalanceInvariant{})
+
+	bobChannel.localCommitChain.next().theirBalance--
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+
+	aliceChannel.remoteCommitChain.next().ourBalance++
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.ErrorAs(t, err, &ErrBalanceInvariant{})
+}
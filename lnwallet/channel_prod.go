@@ -0,0 +1,9 @@
+//go:build !dev
+// +build !dev
+
+package lnwallet
+
+// balanceInvariantChecks switches off the balance invariant check of every
+// commitment that is accepted by RevokeCurrentCommitment or
+// ReceiveRevocation.
+const balanceInvariantChecks = false
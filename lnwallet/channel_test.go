@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"container/list"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"net"
 	"reflect"
 	"runtime"
+	"sync"
 	"testing"
 	"testing/quick"
 
@@ -20,11 +24,14 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/lightningnetwork/lnd/chainntnfs"
+	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lntypes"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
 	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/shachain"
 	"github.com/stretchr/testify/require"
 )
 
@@ -692,6 +699,252 @@ func testCommitHTLCSigTieBreak(t *testing.T, restart bool) {
 	require.NoError(t, err, "unable to receive bob's commitment")
 }
 
+// TestSignNextCommitmentWithView asserts that the commitment view returned
+// along with the signatures for a new remote commitment matches the
+// commitment the remote party ends up with.
+func TestSignNextCommitmentWithView(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Alice offers an HTLC to Bob, and Bob offers one to Alice.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	htlc, _ := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	htlc, _ = createHTLC(0, htlcAmt)
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	aliceBalance := aliceChannel.channelState.RemoteCommitment.LocalBalance
+	bobBalance := aliceChannel.channelState.RemoteCommitment.RemoteBalance
+
+	// Alice signs a new commitment for Bob. As Bob's HTLC hasn't been
+	// ACK'd by Alice yet, only her own HTLC is included.
+	aliceNewCommit, view, err := aliceChannel.SignNextCommitmentWithView()
+	require.NoError(t, err)
+
+	require.EqualValues(t, 1, view.Height)
+	require.Equal(t, 1, view.NumOutgoingHTLCs)
+	require.Zero(t, view.NumIncomingHTLCs)
+	require.Len(t, aliceNewCommit.PendingHTLCs, 1)
+
+	// Alice is the initiator, so the additional commitment fee for the
+	// HTLC output is deducted from her balance.
+	prevFee := aliceChannel.channelState.RemoteCommitment.CommitFee
+	feeDelta := lnwire.NewMSatFromSatoshis(view.Fee - prevFee)
+	require.Equal(t, aliceBalance-htlcAmt-feeDelta, view.LocalBalance)
+	require.Equal(t, bobBalance, view.RemoteBalance)
+
+	// Once Bob receives the signatures, his new local commitment should
+	// match the view, mirrored to his point of view.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	bobCommit := bobChannel.localCommitChain.tip()
+	require.Equal(t, bobCommit.txn.TxHash(), view.Txid)
+	require.Equal(t, bobCommit.height, view.Height)
+	require.Equal(t, bobCommit.theirBalance, view.LocalBalance)
+	require.Equal(t, bobCommit.ourBalance, view.RemoteBalance)
+	require.Equal(t, bobCommit.fee, view.Fee)
+	require.Equal(t, bobCommit.feePerKw, view.FeePerKw)
+	require.Len(t, bobCommit.incomingHTLCs, view.NumOutgoingHTLCs)
+}
+
+// TestDumpUpdateLogs asserts that the update log dump lists the entries of
+// both logs along with their pending modifications.
+func TestDumpUpdateLogs(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	htlc, preimage := createHTLC(0, lnwire.NewMSatFromSatoshis(20000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+
+	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+
+	// Bob's remote log contains Alice's HTLC, which now has a pending
+	// settle in his local log.
+	addLine := fmt.Sprintf("Add log_index=0 htlc_index=0 amt=%v hash=%x "+
+		"timeout=%d output_index(local=", htlc.Amount,
+		htlc.PaymentHash[:], htlc.Expiry)
+	settleLine := fmt.Sprintf("Settle log_index=0 parent_index=0 amt=%v",
+		htlc.Amount)
+
+	dump := bobChannel.DumpUpdateLogs()
+	require.Contains(t, dump, settleLine)
+	require.Contains(t, dump, addLine)
+	require.Contains(t, dump, "(modified)")
+	require.Contains(t, dump, "log_index=1 htlc_counter=1 entries=1")
+
+	// Settling an HTLC that doesn't exist fails with an unknown index
+	// error.
+	err = bobChannel.SettleHTLC(preimage, 1, nil, nil, nil)
+	require.ErrorAs(t, err, &ErrUnknownHtlcIndex{})
+}
+
+// TestCommitmentOutputMap asserts that the output map returned for the local
+// and remote commitments matches the outputs of the commitment transactions.
+func TestCommitmentOutputMap(t *testing.T) {
+	t.Run("tweakless", func(t *testing.T) {
+		testCommitmentOutputMap(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("anchors", func(t *testing.T) {
+		testCommitmentOutputMap(
+			t, channeldb.SingleFunderTweaklessBit|
+				channeldb.AnchorOutputsBit,
+		)
+	})
+}
+
+func testCommitmentOutputMap(t *testing.T, chanType channeldb.ChannelType) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Alice offers a regular and a dust HTLC to Bob, while Bob offers
+	// one HTLC to Alice.
+	aliceHtlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20000))
+	_, err = aliceChannel.AddHTLC(aliceHtlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(aliceHtlc)
+	require.NoError(t, err)
+
+	dustHtlc, _ := createHTLC(1, lnwire.NewMSatFromSatoshis(100))
+	_, err = aliceChannel.AddHTLC(dustHtlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(dustHtlc)
+	require.NoError(t, err)
+
+	bobHtlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(30000))
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err)
+
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err, "unable to complete state update")
+
+	// assertOutputMap checks that the output map of the given commitment
+	// covers all of its outputs, with the balance outputs matching the
+	// balances and the HTLC outputs matching the expected HTLCs.
+	assertOutputMap := func(lc *LightningChannel, remoteChain bool,
+		incoming, outgoing *lnwire.UpdateAddHTLC) {
+
+		t.Helper()
+
+		outputMap, err := lc.CommitmentOutputMap(remoteChain)
+		require.NoError(t, err)
+
+		commit := lc.localCommitChain.tip()
+		if remoteChain {
+			commit = lc.remoteCommitChain.tip()
+		}
+		require.Len(t, outputMap, len(commit.txn.TxOut))
+
+		numAnchors := 0
+		for i, txOut := range commit.txn.TxOut {
+			ref, ok := outputMap[i]
+			require.True(t, ok, "output %v missing", i)
+			require.EqualValues(t, txOut.Value, ref.Amount)
+
+			switch ref.Type {
+			case CommitOutputOurBalance:
+				require.Equal(
+					t, commit.ourBalance.ToSatoshis(),
+					ref.Amount,
+				)
+
+			case CommitOutputTheirBalance:
+				require.Equal(
+					t, commit.theirBalance.ToSatoshis(),
+					ref.Amount,
+				)
+
+			case CommitOutputOurAnchor, CommitOutputTheirAnchor:
+				require.Equal(t, anchorSize, ref.Amount)
+				numAnchors++
+
+			case CommitOutputIncomingHTLC:
+				require.Equal(t, incoming.ID, ref.HtlcIndex)
+				require.EqualValues(
+					t, incoming.PaymentHash, ref.RHash,
+				)
+				require.Equal(
+					t, incoming.Amount.ToSatoshis(),
+					ref.Amount,
+				)
+
+			case CommitOutputOutgoingHTLC:
+				require.Equal(t, outgoing.ID, ref.HtlcIndex)
+				require.EqualValues(
+					t, outgoing.PaymentHash, ref.RHash,
+				)
+				require.Equal(
+					t, outgoing.Amount.ToSatoshis(),
+					ref.Amount,
+				)
+			}
+		}
+
+		if chanType.HasAnchors() {
+			require.Equal(t, 2, numAnchors)
+		}
+	}
+
+	// Both balance outputs, the two non-dust HTLCs and any anchors should
+	// be present on all commitments.
+	assertOutputMap(aliceChannel, false, bobHtlc, aliceHtlc)
+	assertOutputMap(aliceChannel, true, bobHtlc, aliceHtlc)
+	assertOutputMap(bobChannel, false, aliceHtlc, bobHtlc)
+	assertOutputMap(bobChannel, true, aliceHtlc, bobHtlc)
+
+	// Alice now offers another HTLC and signs a new commitment for Bob,
+	// which he hasn't revoked his prior state for yet. The map of this
+	// pending commitment should include both of Alice's non-dust HTLCs.
+	aliceHtlc2, _ := createHTLC(2, lnwire.NewMSatFromSatoshis(40000))
+	_, err = aliceChannel.AddHTLC(aliceHtlc2, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(aliceHtlc2)
+	require.NoError(t, err)
+
+	_, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+
+	outputMap, err := aliceChannel.CommitmentOutputMap(true)
+	require.NoError(t, err)
+	require.Len(
+		t, outputMap, len(aliceChannel.remoteCommitChain.tip().txn.TxOut),
+	)
+
+	var numOutgoing int
+	for _, ref := range outputMap {
+		if ref.Type == CommitOutputOutgoingHTLC {
+			numOutgoing++
+		}
+	}
+	require.Equal(t, 2, numOutgoing)
+}
+
 // TestCooperativeChannelClosure checks that the coop close process finishes
 // with an agreement from both parties, and that the final balances of the
 // close tx check out.
@@ -726,8 +979,8 @@ func testCoopClose(t *testing.T, testCase *coopCloseTestCase) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	aliceDeliveryScript := bobsPrivKey[:]
-	bobDeliveryScript := testHdSeed[:]
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
 
 	aliceFeeRate := chainfee.SatPerKWeight(
 		aliceChannel.channelState.LocalCommitment.FeePerKw,
@@ -798,1649 +1051,1722 @@ func testCoopClose(t *testing.T, testCase *coopCloseTestCase) {
 	}
 }
 
-// TestForceClose checks that the resulting ForceCloseSummary is correct when a
-// peer is ForceClosing the channel. Will check outputs both above and below
-// the dust limit. Additionally, we'll ensure that the node which executed the
-// force close generates HTLC resolutions that are capable of sweeping both
-// incoming and outgoing HTLC's.
-func TestForceClose(t *testing.T) {
-	t.Run("tweakless", func(t *testing.T) {
-		testForceClose(t, &forceCloseTestCase{
-			chanType:             channeldb.SingleFunderTweaklessBit,
-			expectedCommitWeight: input.CommitWeight,
-		})
-	})
-	t.Run("anchors", func(t *testing.T) {
-		testForceClose(t, &forceCloseTestCase{
-			chanType: channeldb.SingleFunderTweaklessBit |
-				channeldb.AnchorOutputsBit,
-			expectedCommitWeight: input.AnchorCommitWeight,
-			anchorAmt:            anchorSize * 2,
-		})
-	})
-	t.Run("taproot", func(t *testing.T) {
-		testForceClose(t, &forceCloseTestCase{
-			chanType: channeldb.SingleFunderTweaklessBit |
-				channeldb.AnchorOutputsBit |
-				channeldb.SimpleTaprootFeatureBit,
-			expectedCommitWeight: input.TaprootCommitWeight,
-			anchorAmt:            anchorSize * 2,
-		})
-	})
-}
-
-type forceCloseTestCase struct {
-	chanType             channeldb.ChannelType
-	expectedCommitWeight int64
-	anchorAmt            btcutil.Amount
-}
-
-func testForceClose(t *testing.T, testCase *forceCloseTestCase) {
+// TestCoopCloseInitiatorFirstOutputs asserts that the outputs of the co-op
+// close transaction are placed with the channel initiator's output first if
+// requested, and sorted according to BIP 69 otherwise.
+func TestCoopCloseInitiatorFirstOutputs(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, testCase.chanType,
+	// With equal values, BIP 69 orders the outputs by their script, so
+	// ours would be placed last.
+	ourScript := append(
+		[]byte{0x00, 0x14}, bytes.Repeat([]byte{0xff}, 20)...,
 	)
-	require.NoError(t, err, "unable to create test channels")
+	theirScript := append([]byte{0x00, 0x14}, make([]byte, 20)...)
 
-	bobAmount := bobChannel.channelState.LocalCommitment.LocalBalance
+	const (
+		dust    = btcutil.Amount(354)
+		balance = btcutil.Amount(100_000)
+	)
 
-	// First, we'll add an outgoing HTLC from Alice to Bob, such that it
-	// will still be present within the broadcast commitment transaction.
-	// We'll ensure that the HTLC amount is above Alice's dust limit.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
-	}
+	createTx := func(theirBalance btcutil.Amount,
+		opts ...CloseTxOpt) *wire.MsgTx {
 
-	// We'll also a distinct HTLC from Bob -> Alice. This way, Alice will
-	// have both an incoming and outgoing HTLC on her commitment
-	// transaction.
-	htlcBob, preimageBob := createHTLC(0, htlcAmount)
-	if _, err := bobChannel.AddHTLC(htlcBob, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	if _, err := aliceChannel.ReceiveHTLC(htlcBob); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
+		return CreateCooperativeCloseTx(
+			wire.TxIn{}, dust, dust, balance, theirBalance,
+			ourScript, theirScript, opts...,
+		)
 	}
+	scripts := func(tx *wire.MsgTx) [][]byte {
+		var scripts [][]byte
+		for _, txOut := range tx.TxOut {
+			scripts = append(scripts, txOut.PkScript)
+		}
 
-	// Next, we'll perform two state transitions to ensure that both HTLC's
-	// get fully locked-in.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
-	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
+		return scripts
 	}
 
-	// With the cache populated, we'll now attempt the force close
-	// initiated by Alice.
-	closeSummary, err := aliceChannel.ForceClose()
-	require.NoError(t, err, "unable to force close channel")
+	require.Equal(
+		t, [][]byte{theirScript, ourScript}, scripts(createTx(balance)),
+	)
+	require.Equal(
+		t, [][]byte{ourScript, theirScript},
+		scripts(createTx(balance, WithInitiatorFirstOutputs(true))),
+	)
+	require.Equal(
+		t, [][]byte{theirScript, ourScript},
+		scripts(createTx(balance, WithInitiatorFirstOutputs(false))),
+	)
 
-	// Alice should detect that she can sweep the outgoing HTLC after a
-	// timeout, but also that she's able to sweep in incoming HTLC Bob sent
-	// her.
-	if len(closeSummary.HtlcResolutions.OutgoingHTLCs) != 1 {
-		t.Fatalf("alice out htlc resolutions not populated: expected %v "+
-			"htlcs, got %v htlcs",
-			1, len(closeSummary.HtlcResolutions.OutgoingHTLCs))
-	}
-	if len(closeSummary.HtlcResolutions.IncomingHTLCs) != 1 {
-		t.Fatalf("alice in htlc resolutions not populated: expected %v "+
-			"htlcs, got %v htlcs",
-			1, len(closeSummary.HtlcResolutions.IncomingHTLCs))
-	}
+	// A dust output is still omitted.
+	require.Equal(
+		t, [][]byte{ourScript},
+		scripts(createTx(dust-1, WithInitiatorFirstOutputs(false))),
+	)
 
-	// Verify the anchor resolutions for the anchor commitment format.
-	if testCase.chanType.HasAnchors() {
-		// Check the close summary resolution.
-		anchorRes := closeSummary.AnchorResolution
-		if anchorRes == nil {
-			t.Fatal("expected anchor resolution")
-		}
-		if anchorRes.CommitAnchor.Hash != closeSummary.CloseTx.TxHash() {
-			t.Fatal("commit tx not referenced by anchor res")
-		}
-		if anchorRes.AnchorSignDescriptor.Output.Value !=
-			int64(anchorSize) {
+	// If both parties close the channel with the option set, they should
+	// arrive at the same transaction, paying the initiator Alice first.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-			t.Fatal("unexpected anchor size")
-		}
-		if anchorRes.AnchorSignDescriptor.WitnessScript == nil {
-			t.Fatal("expected anchor witness script")
-		}
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
 
-		// Check the pre-confirmation resolutions.
-		res, err := aliceChannel.NewAnchorResolutions()
-		if err != nil {
-			t.Fatalf("pre-confirmation resolution error: %v", err)
-		}
+	fee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	))
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		fee, aliceDeliveryScript, bobDeliveryScript,
+		WithInitiatorFirstCloseOutputs(),
+	)
+	require.NoError(t, err, "unable to create alice coop close proposal")
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		fee, bobDeliveryScript, aliceDeliveryScript,
+		WithInitiatorFirstCloseOutputs(),
+	)
+	require.NoError(t, err, "unable to create bob coop close proposal")
 
-		// Check we have the expected anchor resolutions.
-		require.NotNil(t, res.Local, "expected local anchor resolution")
-		require.NotNil(t,
-			res.Remote, "expected remote anchor resolution",
-		)
-		require.Nil(t,
-			res.RemotePending, "expected no anchor resolution",
-		)
-	}
+	aliceCloseTx, _, err := aliceChannel.CompleteCooperativeClose(
+		aliceSig, bobSig, aliceDeliveryScript, bobDeliveryScript, fee,
+		WithInitiatorFirstCloseOutputs(),
+	)
+	require.NoError(t, err, "unable to complete alice cooperative close")
+	bobCloseTx, _, err := bobChannel.CompleteCooperativeClose(
+		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript, fee,
+		WithInitiatorFirstCloseOutputs(),
+	)
+	require.NoError(t, err, "unable to complete bob cooperative close")
 
-	// The SelfOutputSignDesc should be non-nil since the output to-self is
-	// non-dust.
-	aliceCommitResolution := closeSummary.CommitResolution
-	if aliceCommitResolution == nil {
-		t.Fatalf("alice fails to include to-self output in " +
-			"ForceCloseSummary")
-	}
+	require.Equal(t, aliceCloseTx.TxHash(), bobCloseTx.TxHash())
+	require.Equal(
+		t, [][]byte{aliceDeliveryScript, bobDeliveryScript},
+		scripts(aliceCloseTx),
+	)
+}
 
-	// The rest of the close summary should have been populated properly.
-	aliceDelayPoint := aliceChannel.channelState.LocalChanCfg.DelayBasePoint
-	if !aliceCommitResolution.SelfOutputSignDesc.KeyDesc.PubKey.IsEqual(
-		aliceDelayPoint.PubKey,
-	) {
-		t.Fatalf("alice incorrect pubkey in SelfOutputSignDesc")
-	}
+// genP2WPKHScript returns a P2WPKH script paying to the hash of the passed
+// bytes.
+func genP2WPKHScript(t *testing.T, data []byte) []byte {
+	t.Helper()
 
-	// Factoring in the fee rate, Alice's amount should properly reflect
-	// that we've added two additional HTLC to the commitment transaction.
-	totalCommitWeight := testCase.expectedCommitWeight +
-		(input.HTLCWeight * 2)
-	feePerKw := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	script, err := input.WitnessPubKeyHash(data)
+	require.NoError(t, err)
+
+	return script
+}
+
+// TestSimulateCooperativeClose asserts that simulating a co-op close yields
+// the same transaction as an actual close, without modifying the status of
+// the channel.
+func TestSimulateCooperativeClose(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
-	commitFee := feePerKw.FeeForWeight(totalCommitWeight)
+	require.NoError(t, err, "unable to create test channels")
 
-	expectedAmount := (aliceChannel.Capacity / 2) -
-		htlcAmount.ToSatoshis() - commitFee - testCase.anchorAmt
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
 
-	if aliceCommitResolution.SelfOutputSignDesc.Output.Value != int64(expectedAmount) {
-		t.Fatalf("alice incorrect output value in SelfOutputSignDesc, "+
-			"expected %v, got %v", int64(expectedAmount),
-			aliceCommitResolution.SelfOutputSignDesc.Output.Value)
-	}
+	aliceFee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	))
 
-	// Alice's listed CSV delay should also match the delay that was
-	// pre-committed to at channel opening.
-	if aliceCommitResolution.MaturityDelay !=
-		uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay) {
+	simTx, simBalance, err := aliceChannel.SimulateCooperativeClose(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
+	require.Equal(t, ChannelOpen, aliceChannel.Status())
 
-		t.Fatalf("alice: incorrect local CSV delay in ForceCloseSummary, "+
-			"expected %v, got %v",
-			aliceChannel.channelState.LocalChanCfg.CsvDelay,
-			aliceCommitResolution.MaturityDelay)
+	// The simulated transaction shouldn't be signed.
+	for _, txIn := range simTx.TxIn {
+		require.Empty(t, txIn.Witness)
 	}
 
-	// Next, we'll ensure that the second level HTLC transaction it itself
-	// spendable, and also that the delivery output (with delay) itself has
-	// a valid sign descriptor.
-	htlcResolution := closeSummary.HtlcResolutions.OutgoingHTLCs[0]
-	outHtlcIndex := htlcResolution.SignedTimeoutTx.TxIn[0].PreviousOutPoint.Index
-	senderHtlcPkScript := closeSummary.CloseTx.TxOut[outHtlcIndex].PkScript
+	// Now we'll actually close the channel, which should result in the
+	// same transaction and balance as the simulation.
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
+	require.Equal(t, ChannelClosing, aliceChannel.Status())
 
-	// First, verify that the second level transaction can properly spend
-	// the multi-sig clause within the output on the commitment transaction
-	// that produces this HTLC.
-	timeoutTx := htlcResolution.SignedTimeoutTx
-	prevOutputFetcher := txscript.NewCannedPrevOutputFetcher(
-		senderHtlcPkScript, int64(htlcAmount.ToSatoshis()),
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		aliceFee, bobDeliveryScript, aliceDeliveryScript,
 	)
-	hashCache := txscript.NewTxSigHashes(timeoutTx, prevOutputFetcher)
-	vm, err := txscript.NewEngine(
-		senderHtlcPkScript,
-		timeoutTx, 0, txscript.StandardVerifyFlags, nil,
-		hashCache, int64(htlcAmount.ToSatoshis()), prevOutputFetcher,
+	require.NoError(t, err)
+
+	closeTx, closeBalance, err := aliceChannel.CompleteCooperativeClose(
+		aliceSig, bobSig, aliceDeliveryScript, bobDeliveryScript,
+		aliceFee,
 	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc timeout spend is invalid: %v", err)
-	}
+	require.NoError(t, err)
+	require.Equal(t, simTx.TxHash(), closeTx.TxHash())
+	require.Equal(t, simBalance, closeBalance)
+}
 
-	// Next, we'll ensure that we can spend the output of the second level
-	// transaction given a properly crafted sweep transaction.
-	sweepTx := wire.NewMsgTx(2)
-	sweepTx.AddTxIn(&wire.TxIn{
-		PreviousOutPoint: wire.OutPoint{
-			Hash:  htlcResolution.SignedTimeoutTx.TxHash(),
-			Index: 0,
-		},
-	})
-	sweepTx.AddTxOut(&wire.TxOut{
-		PkScript: senderHtlcPkScript,
-		Value:    htlcResolution.SweepSignDesc.Output.Value,
-	})
-	htlcResolution.SweepSignDesc.InputIndex = 0
+// TestCoopCloseDeliveryScriptValidation asserts that a co-op close is only
+// created or completed if both delivery scripts are of an allowed type.
+func TestCoopCloseDeliveryScriptValidation(t *testing.T) {
+	t.Parallel()
 
-	csvDelay := uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay)
-	if testCase.chanType.IsTaproot() {
-		sweepTx.TxIn[0].Sequence = input.LockTimeToSequence(
-			false, csvDelay,
-		)
-		sweepTx.TxIn[0].Witness, err = input.TaprootHtlcSpendSuccess(
-			aliceChannel.Signer, &htlcResolution.SweepSignDesc,
-			sweepTx, nil, nil,
-		)
-	} else {
-		sweepTx.TxIn[0].Witness, err = input.HtlcSpendSuccess(
-			aliceChannel.Signer, &htlcResolution.SweepSignDesc,
-			sweepTx, csvDelay,
-		)
-	}
-	require.NoError(t, err, "unable to gen witness for timeout output")
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// With the witness fully populated for the success spend from the
-	// second-level transaction, we ensure that the scripts properly
-	// validate given the information within the htlc resolution struct.
-	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
-		htlcResolution.SweepSignDesc.Output.PkScript,
-		htlcResolution.SweepSignDesc.Output.Value,
+	validScript := genP2WPKHScript(t, bobsPrivKey)
+
+	_, alicePub := btcec.PrivKeyFromBytes(testWalletPrivKey)
+	_, bobPub := btcec.PrivKeyFromBytes(bobsPrivKey)
+	multiSigScript, err := input.GenMultiSigScript(
+		alicePub.SerializeCompressed(), bobPub.SerializeCompressed(),
 	)
-	hashCache = txscript.NewTxSigHashes(sweepTx, prevOutFetcher)
-	vm, err = txscript.NewEngine(
-		htlcResolution.SweepSignDesc.Output.PkScript,
-		sweepTx, 0, txscript.StandardVerifyFlags, nil,
-		hashCache, htlcResolution.SweepSignDesc.Output.Value,
-		prevOutFetcher,
+	require.NoError(t, err)
+
+	aliceFee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	))
+
+	// A bare multisig script isn't standard, so neither a local nor a
+	// remote delivery script of that type should be accepted.
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, multiSigScript, validScript,
 	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc timeout spend is invalid: %v", err)
-	}
+	require.ErrorIs(t, err, ErrNonStandardDeliveryScript)
 
-	// Finally, the txid of the commitment transaction and the one returned
-	// as the closing transaction should also match.
-	closeTxHash := closeSummary.CloseTx.TxHash()
-	commitTxHash := aliceChannel.channelState.LocalCommitment.CommitTx.TxHash()
-	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
-		t.Fatalf("alice: incorrect close transaction txid")
-	}
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, validScript, multiSigScript,
+	)
+	require.ErrorIs(t, err, ErrNonStandardDeliveryScript)
 
-	// We'll now perform similar set of checks to ensure that Alice is able
-	// to sweep the output that Bob sent to her on-chain with knowledge of
-	// the preimage.
-	inHtlcResolution := closeSummary.HtlcResolutions.IncomingHTLCs[0]
-	inHtlcIndex := inHtlcResolution.SignedSuccessTx.TxIn[0].PreviousOutPoint.Index
-	receiverHtlcScript := closeSummary.CloseTx.TxOut[inHtlcIndex].PkScript
+	_, _, err = bobChannel.CompleteCooperativeClose(
+		nil, nil, validScript, multiSigScript, aliceFee,
+	)
+	require.ErrorIs(t, err, ErrNonStandardDeliveryScript)
 
-	// With the original pkscript located, we'll now verify that the second
-	// level transaction can spend from the multi-sig out. Supply the
-	// preimage manually. This is usually done by the contract resolver
-	// before publication.
-	successTx := inHtlcResolution.SignedSuccessTx
+	// If the caller explicitly allows bare multisig outputs, the proposal
+	// should go through.
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, multiSigScript, validScript,
+		WithDeliveryScriptClasses(
+			txscript.MultiSigTy, txscript.WitnessV0PubKeyHashTy,
+		),
+	)
+	require.NoError(t, err)
 
-	// For taproot channels, the preimage goes into a slightly different
-	// location.
-	if testCase.chanType.IsTaproot() {
-		successTx.TxIn[0].Witness[2] = preimageBob[:]
-	} else {
-		successTx.TxIn[0].Witness[3] = preimageBob[:]
-	}
+	// Restricting the allowed set should in turn reject otherwise
+	// standard scripts.
+	_, _, err = bobChannel.CompleteCooperativeClose(
+		nil, nil, validScript, validScript, aliceFee,
+		WithDeliveryScriptClasses(txscript.WitnessV0ScriptHashTy),
+	)
+	require.ErrorIs(t, err, ErrNonStandardDeliveryScript)
 
-	prevOuts := txscript.NewCannedPrevOutputFetcher(
-		receiverHtlcScript, int64(htlcAmount.ToSatoshis()),
+	// A taproot delivery script is only accepted once the caller opts
+	// into option_shutdown_anysegwit.
+	taprootScript, err := input.PayToTaprootScript(bobPub)
+	require.NoError(t, err)
+
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, validScript, taprootScript,
 	)
-	hashCache = txscript.NewTxSigHashes(successTx, prevOuts)
-	vm, err = txscript.NewEngine(
-		receiverHtlcScript,
-		successTx, 0, txscript.StandardVerifyFlags, nil,
-		hashCache, int64(htlcAmount.ToSatoshis()), prevOuts,
+	require.ErrorIs(t, err, ErrNonStandardDeliveryScript)
+
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, validScript, taprootScript,
+		WithDeliveryScriptClasses(AnySegwitDeliveryScriptClasses...),
 	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc success spend is invalid: %v", err)
-	}
+	require.NoError(t, err)
 
-	// Finally, we'll construct a transaction to spend the produced
-	// second-level output with the attached SignDescriptor.
-	sweepTx = wire.NewMsgTx(2)
-	sweepTx.AddTxIn(&wire.TxIn{
-		PreviousOutPoint: inHtlcResolution.ClaimOutpoint,
-	})
-	sweepTx.AddTxOut(&wire.TxOut{
-		PkScript: receiverHtlcScript,
-		Value:    inHtlcResolution.SweepSignDesc.Output.Value,
-	})
-	inHtlcResolution.SweepSignDesc.InputIndex = 0
-	if testCase.chanType.IsTaproot() {
-		sweepTx.TxIn[0].Sequence = input.LockTimeToSequence(
-			false, csvDelay,
-		)
-		sweepTx.TxIn[0].Witness, err = input.TaprootHtlcSpendSuccess(
-			aliceChannel.Signer, &inHtlcResolution.SweepSignDesc,
-			sweepTx, nil, nil,
-		)
-	} else {
-		sweepTx.TxIn[0].Witness, err = input.HtlcSpendSuccess(
-			aliceChannel.Signer, &inHtlcResolution.SweepSignDesc,
-			sweepTx,
-			uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay),
-		)
-	}
-	require.NoError(t, err, "unable to gen witness for timeout output")
+	// Finally, a regular co-op close between two P2WPKH scripts should
+	// succeed.
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		aliceFee, validScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
 
-	// The spend we create above spending the second level HTLC output
-	// should validate without any issues.
-	prevOuts = txscript.NewCannedPrevOutputFetcher(
-		inHtlcResolution.SweepSignDesc.Output.PkScript,
-		inHtlcResolution.SweepSignDesc.Output.Value,
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		aliceFee, bobDeliveryScript, validScript,
 	)
-	hashCache = txscript.NewTxSigHashes(sweepTx, prevOuts)
-	vm, err = txscript.NewEngine(
-		inHtlcResolution.SweepSignDesc.Output.PkScript,
-		sweepTx, 0, txscript.StandardVerifyFlags, nil,
-		hashCache, inHtlcResolution.SweepSignDesc.Output.Value,
-		prevOuts,
+	require.NoError(t, err)
+
+	_, _, err = bobChannel.CompleteCooperativeClose(
+		bobSig, aliceSig, bobDeliveryScript, validScript, aliceFee,
 	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc timeout spend is invalid: %v", err)
-	}
+	require.NoError(t, err)
+}
 
-	// Check the same for Bob's ForceCloseSummary.
-	closeSummary, err = bobChannel.ForceClose()
-	require.NoError(t, err, "unable to force close channel")
-	bobCommitResolution := closeSummary.CommitResolution
-	if bobCommitResolution == nil {
-		t.Fatalf("bob fails to include to-self output in ForceCloseSummary")
-	}
-	bobDelayPoint := bobChannel.channelState.LocalChanCfg.DelayBasePoint
-	if !bobCommitResolution.SelfOutputSignDesc.KeyDesc.PubKey.IsEqual(bobDelayPoint.PubKey) {
-		t.Fatalf("bob incorrect pubkey in SelfOutputSignDesc")
+// TestCoopCloseConcurrentProposals asserts that both parties agree on the fee
+// of the close transaction if they concurrently propose a close with
+// different fees.
+func TestCoopCloseConcurrentProposals(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name          string
+		policy        CloseProposalPolicy
+		initiatorWins bool
+	}{
+		{
+			name:          "initiator wins",
+			policy:        CloseProposalInitiatorWins,
+			initiatorWins: true,
+		},
+		{
+			name:          "lower fee wins",
+			policy:        CloseProposalLowerFeeWins,
+			initiatorWins: false,
+		},
 	}
-	if bobCommitResolution.SelfOutputSignDesc.Output.Value !=
-		int64(bobAmount.ToSatoshis()-htlcAmount.ToSatoshis()) {
 
-		t.Fatalf("bob incorrect output value in SelfOutputSignDesc, "+
-			"expected %v, got %v",
-			bobAmount.ToSatoshis(),
-			int64(bobCommitResolution.SelfOutputSignDesc.Output.Value))
-	}
-	if bobCommitResolution.MaturityDelay !=
-		uint32(bobChannel.channelState.LocalChanCfg.CsvDelay) {
-
-		t.Fatalf("bob: incorrect local CSV delay in ForceCloseSummary, "+
-			"expected %v, got %v",
-			bobChannel.channelState.LocalChanCfg.CsvDelay,
-			bobCommitResolution.MaturityDelay)
-	}
-
-	closeTxHash = closeSummary.CloseTx.TxHash()
-	commitTxHash = bobChannel.channelState.LocalCommitment.CommitTx.TxHash()
-	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
-		t.Fatalf("bob: incorrect close transaction txid")
-	}
+	for _, tc := range testCases {
+		tc := tc
 
-	// As we didn't add the preimage of Alice's HTLC to bob's preimage
-	// cache, he should only detect that he can sweep only his outgoing
-	// HTLC upon force close.
-	if len(closeSummary.HtlcResolutions.OutgoingHTLCs) != 1 {
-		t.Fatalf("alice out htlc resolutions not populated: expected %v "+
-			"htlcs, got %v htlcs",
-			1, len(closeSummary.HtlcResolutions.OutgoingHTLCs))
-	}
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
 
-	// Bob should recognize that the incoming HTLC is there, but the
-	// preimage should be empty as he doesn't have the knowledge required
-	// to sweep it.
-	if len(closeSummary.HtlcResolutions.IncomingHTLCs) != 1 {
-		t.Fatalf("bob in htlc resolutions not populated: expected %v "+
-			"htlcs, got %v htlcs",
-			1, len(closeSummary.HtlcResolutions.IncomingHTLCs))
+			testCoopCloseConcurrentProposals(
+				t, tc.policy, tc.initiatorWins,
+			)
+		})
 	}
 }
 
-// TestForceCloseDustOutput tests that if either side force closes with an
-// active dust output (for only a single party due to asymmetric dust values),
-// then the force close summary is well crafted.
-func TestForceCloseDustOutput(t *testing.T) {
-	t.Parallel()
+func testCoopCloseConcurrentProposals(t *testing.T,
+	policy CloseProposalPolicy, initiatorWins bool) {
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// We set both node's channel reserves to 0, to make sure
-	// they can create small dust outputs without going under
-	// their channel reserves.
-	aliceChannel.channelState.LocalChanCfg.ChanReserve = 0
-	bobChannel.channelState.LocalChanCfg.ChanReserve = 0
-	aliceChannel.channelState.RemoteChanCfg.ChanReserve = 0
-	bobChannel.channelState.RemoteChanCfg.ChanReserve = 0
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
 
-	htlcAmount := lnwire.NewMSatFromSatoshis(500)
+	// Alice, the initiator, proposes a higher fee than Bob, so the two
+	// policies pick different proposals.
+	feeRate := aliceChannel.CommitFeeRate()
+	aliceFee := aliceChannel.CalcFee(feeRate * 2)
+	bobFee := bobChannel.CalcFee(feeRate)
 
-	aliceAmount := aliceChannel.channelState.LocalCommitment.LocalBalance
-	bobAmount := bobChannel.channelState.LocalCommitment.LocalBalance
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		bobFee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err)
 
-	// Have Bobs' to-self output be below her dust limit and check
-	// ForceCloseSummary again on both peers.
-	htlc, preimage := createHTLC(0, bobAmount-htlcAmount)
-	bobHtlcIndex, err := bobChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "alice unable to add htlc")
-	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "bob unable to receive htlc")
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
-	}
+	// Both parties receive the other's proposal and resolve the conflict
+	// to the same fee.
+	policyOpt := WithCloseProposalPolicy(policy)
+	aliceAgreed, err := aliceChannel.ResolveCloseProposal(
+		bobFee, policyOpt,
+	)
+	require.NoError(t, err)
+	bobAgreed, err := bobChannel.ResolveCloseProposal(aliceFee, policyOpt)
+	require.NoError(t, err)
+	require.Equal(t, aliceAgreed, bobAgreed)
 
-	// Settle HTLC and sign new commitment.
-	err = aliceChannel.SettleHTLC(preimage, aliceHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "bob unable to settle inbound htlc")
-	err = bobChannel.ReceiveHTLCSettle(preimage, bobHtlcIndex)
-	if err != nil {
-		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
+	expectedFee := bobFee
+	if initiatorWins {
+		expectedFee = aliceFee
 	}
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
-	}
-
-	aliceAmount = aliceChannel.channelState.LocalCommitment.LocalBalance
-	bobAmount = bobChannel.channelState.LocalCommitment.RemoteBalance
+	require.Equal(t, expectedFee, aliceAgreed)
 
-	closeSummary, err := aliceChannel.ForceClose()
-	require.NoError(t, err, "unable to force close channel")
+	// The signature of the losing proposal can't be used to complete the
+	// close, and the loser re-signs with the agreed upon fee.
+	if initiatorWins {
+		_, _, err = bobChannel.CompleteCooperativeClose(
+			bobSig, aliceSig, bobDeliveryScript,
+			aliceDeliveryScript, bobFee,
+		)
+		require.ErrorIs(t, err, ErrCloseFeeMismatch)
 
-	// Alice's to-self output should still be in the commitment
-	// transaction.
-	commitResolution := closeSummary.CommitResolution
-	if commitResolution == nil {
-		t.Fatalf("alice fails to include to-self output in " +
-			"ForceCloseSummary")
-	}
-	if !commitResolution.SelfOutputSignDesc.KeyDesc.PubKey.IsEqual(
-		aliceChannel.channelState.LocalChanCfg.DelayBasePoint.PubKey,
-	) {
-		t.Fatalf("alice incorrect pubkey in SelfOutputSignDesc")
-	}
-	if commitResolution.SelfOutputSignDesc.Output.Value !=
-		int64(aliceAmount.ToSatoshis()) {
-		t.Fatalf("alice incorrect output value in SelfOutputSignDesc, "+
-			"expected %v, got %v",
-			aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis(),
-			commitResolution.SelfOutputSignDesc.Output.Value)
-	}
+		bobSig, _, _, err = bobChannel.CreateCloseProposal(
+			expectedFee, bobDeliveryScript, aliceDeliveryScript,
+		)
+		require.NoError(t, err)
+	} else {
+		_, _, err = aliceChannel.CompleteCooperativeClose(
+			aliceSig, bobSig, aliceDeliveryScript,
+			bobDeliveryScript, aliceFee,
+		)
+		require.ErrorIs(t, err, ErrCloseFeeMismatch)
 
-	if commitResolution.MaturityDelay !=
-		uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay) {
-		t.Fatalf("alice: incorrect local CSV delay in ForceCloseSummary, "+
-			"expected %v, got %v",
-			aliceChannel.channelState.LocalChanCfg.CsvDelay,
-			commitResolution.MaturityDelay)
+		aliceSig, _, _, err = aliceChannel.CreateCloseProposal(
+			expectedFee, aliceDeliveryScript, bobDeliveryScript,
+		)
+		require.NoError(t, err)
 	}
 
-	closeTxHash := closeSummary.CloseTx.TxHash()
-	commitTxHash := aliceChannel.channelState.LocalCommitment.CommitTx.TxHash()
-	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
-		t.Fatalf("alice: incorrect close transaction txid")
-	}
+	// Proposals with any other fee are rejected from now on.
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		expectedFee+1, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.ErrorIs(t, err, ErrCloseFeeMismatch)
 
-	closeSummary, err = bobChannel.ForceClose()
-	require.NoError(t, err, "unable to force close channel")
+	// Both parties now complete the same close transaction.
+	aliceCloseTx, _, err := aliceChannel.CompleteCooperativeClose(
+		aliceSig, bobSig, aliceDeliveryScript, bobDeliveryScript,
+		expectedFee,
+	)
+	require.NoError(t, err)
+	bobCloseTx, _, err := bobChannel.CompleteCooperativeClose(
+		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
+		expectedFee,
+	)
+	require.NoError(t, err)
+	require.Equal(t, aliceCloseTx.TxHash(), bobCloseTx.TxHash())
 
-	// Bob's to-self output is below Bob's dust value and should be
-	// reflected in the ForceCloseSummary.
-	commitResolution = closeSummary.CommitResolution
-	if commitResolution != nil {
-		t.Fatalf("bob incorrectly includes to-self output in " +
-			"ForceCloseSummary")
-	}
+	closeFee, ok := aliceChannel.LastCloseFee()
+	require.True(t, ok)
+	require.Equal(t, expectedFee, closeFee)
+}
 
-	closeTxHash = closeSummary.CloseTx.TxHash()
-	commitTxHash = bobChannel.channelState.LocalCommitment.CommitTx.TxHash()
-	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
-		t.Fatalf("bob: incorrect close transaction txid")
-	}
+// TestCoopCloseUpfrontShutdownScript asserts that a co-op close can only pay
+// out to our upfront shutdown script if we committed to one, and to any
+// script otherwise.
+func TestCoopCloseUpfrontShutdownScript(t *testing.T) {
+	t.Run("no upfront script", func(t *testing.T) {
+		testCoopCloseUpfrontShutdownScript(t, false)
+	})
+	t.Run("upfront script", func(t *testing.T) {
+		testCoopCloseUpfrontShutdownScript(t, true)
+	})
 }
 
-// TestDustHTLCFees checks that fees are calculated correctly when HTLCs fall
-// below the nodes' dust limit. In these cases, the amount of the dust HTLCs
-// should be applied to the commitment transaction fee.
-func TestDustHTLCFees(t *testing.T) {
+func testCoopCloseUpfrontShutdownScript(t *testing.T, upfront bool) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	aliceStartingBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
+	otherScript := genP2WPKHScript(t, testWalletPrivKey)
 
-	// This HTLC amount should be lower than the dust limits of both nodes.
-	htlcAmount := lnwire.NewMSatFromSatoshis(100)
-	htlc, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("bob unable to receive htlc: %v", err)
-	}
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
+	if upfront {
+		aliceChannel.channelState.LocalShutdownScript =
+			aliceDeliveryScript
+		bobChannel.channelState.RemoteShutdownScript =
+			aliceDeliveryScript
 	}
 
-	// After the transition, we'll ensure that we performed fee accounting
-	// properly. Namely, the local+remote+commitfee values should add up to
-	// the total capacity of the channel. This same should hold for both
-	// sides.
-	totalSatoshisAlice := (aliceChannel.channelState.LocalCommitment.LocalBalance +
-		aliceChannel.channelState.LocalCommitment.RemoteBalance +
-		lnwire.NewMSatFromSatoshis(aliceChannel.channelState.LocalCommitment.CommitFee))
-	if totalSatoshisAlice+htlcAmount != lnwire.NewMSatFromSatoshis(aliceChannel.Capacity) {
-		t.Fatalf("alice's funds leaked: total satoshis are %v, but channel "+
-			"capacity is %v", int64(totalSatoshisAlice),
-			int64(aliceChannel.Capacity))
-	}
-	totalSatoshisBob := (bobChannel.channelState.LocalCommitment.LocalBalance +
-		bobChannel.channelState.LocalCommitment.RemoteBalance +
-		lnwire.NewMSatFromSatoshis(bobChannel.channelState.LocalCommitment.CommitFee))
-	if totalSatoshisBob+htlcAmount != lnwire.NewMSatFromSatoshis(bobChannel.Capacity) {
-		t.Fatalf("bob's funds leaked: total satoshis are %v, but channel "+
-			"capacity is %v", int64(totalSatoshisBob),
-			int64(bobChannel.Capacity))
-	}
+	aliceFee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	))
 
-	// The commitment fee paid should be the same, as there have been no
-	// new material outputs added.
-	defaultFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 0)
-	if aliceChannel.channelState.LocalCommitment.CommitFee != defaultFee {
-		t.Fatalf("dust htlc amounts not subtracted from commitment fee "+
-			"expected %v, got %v", defaultFee,
-			aliceChannel.channelState.LocalCommitment.CommitFee)
-	}
-	if bobChannel.channelState.LocalCommitment.CommitFee != defaultFee {
-		t.Fatalf("dust htlc amounts not subtracted from commitment fee "+
-			"expected %v, got %v", defaultFee,
-			bobChannel.channelState.LocalCommitment.CommitFee)
-	}
+	// Attempt to close to a script other than the one Alice may have
+	// committed to. This should only fail if she has an upfront script.
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, otherScript, bobDeliveryScript,
+	)
+	if upfront {
+		require.ErrorIs(t, err, ErrUpfrontShutdownScriptMismatch)
+		require.Equal(t, ChannelOpen, aliceChannel.Status())
 
-	// Alice's final balance should reflect the HTLC deficit even though
-	// the HTLC was paid to fees as it was trimmed.
-	aliceEndBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
-	aliceExpectedBalance := aliceStartingBalance - htlcAmount
-	if aliceEndBalance != aliceExpectedBalance {
-		t.Fatalf("alice not credited for dust: expected %v, got %v",
-			aliceExpectedBalance, aliceEndBalance)
+		_, _, err = aliceChannel.CompleteCooperativeClose(
+			nil, nil, otherScript, bobDeliveryScript, aliceFee,
+		)
+		require.ErrorIs(t, err, ErrUpfrontShutdownScriptMismatch)
+	} else {
+		require.NoError(t, err)
 	}
+
+	// Closing to the committed script should always succeed.
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
+
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		aliceFee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err)
+
+	_, _, err = aliceChannel.CompleteCooperativeClose(
+		aliceSig, bobSig, aliceDeliveryScript, bobDeliveryScript,
+		aliceFee,
+	)
+	require.NoError(t, err)
 }
 
-// TestHTLCDustLimit checks the situation in which an HTLC is larger than one
-// channel participant's dust limit, but smaller than the other participant's
-// dust limit. In this case, the participants' commitment chains will diverge.
-// In one commitment chain, the HTLC will be added as normal, in the other
-// chain, the amount of the HTLC will contribute to the fees to be paid.
-func TestHTLCDustLimit(t *testing.T) {
+// TestAbortCooperativeClose asserts that a co-op close can only be aborted as
+// long as we didn't sign a closing transaction, after which the channel can be
+// used again.
+func TestAbortCooperativeClose(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// The amount of the HTLC should be above Alice's dust limit and below
-	// Bob's dust limit.
-	htlcSat := (btcutil.Amount(500) + HtlcTimeoutFee(
-		aliceChannel.channelState.ChanType,
-		chainfee.SatPerKWeight(
-			aliceChannel.channelState.LocalCommitment.FeePerKw,
-		),
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
+
+	aliceFee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
 	))
-	htlcAmount := lnwire.NewMSatFromSatoshis(htlcSat)
 
-	htlc, preimage := createHTLC(0, htlcAmount)
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "alice unable to add htlc")
-	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "bob unable to receive htlc")
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
-	}
+	// Aborting a channel that isn't closing should be a no-op.
+	require.NoError(t, aliceChannel.AbortCooperativeClose())
+	require.Equal(t, ChannelOpen, aliceChannel.Status())
 
-	// At this point, Alice's commitment transaction should have an HTLC,
-	// while Bob's should not, because the value falls beneath his dust
-	// limit. The amount of the HTLC should be applied to fees in Bob's
-	// commitment transaction.
-	aliceCommitment := aliceChannel.localCommitChain.tip()
-	if len(aliceCommitment.txn.TxOut) != 3 {
-		t.Fatalf("incorrect # of outputs: expected %v, got %v",
-			3, len(aliceCommitment.txn.TxOut))
-	}
-	bobCommitment := bobChannel.localCommitChain.tip()
-	if len(bobCommitment.txn.TxOut) != 2 {
-		t.Fatalf("incorrect # of outputs: expected %v, got %v",
-			2, len(bobCommitment.txn.TxOut))
-	}
-	defaultFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 0)
-	if bobChannel.channelState.LocalCommitment.CommitFee != defaultFee {
-		t.Fatalf("dust htlc amount was subtracted from commitment fee "+
-			"expected %v, got %v", defaultFee,
-			bobChannel.channelState.LocalCommitment.CommitFee)
-	}
+	// Bob resolves a close proposal of Alice, but gives up on the close
+	// before signing his own proposal.
+	_, err = bobChannel.ResolveCloseProposal(aliceFee)
+	require.NoError(t, err)
+	require.NoError(t, bobChannel.AbortCooperativeClose())
+	require.Nil(t, bobChannel.agreedCloseFee)
+	require.Equal(t, ChannelOpen, bobChannel.Status())
 
-	// Settle HTLC and create a new commitment state.
-	err = bobChannel.SettleHTLC(preimage, bobHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "bob unable to settle inbound htlc")
-	err = aliceChannel.ReceiveHTLCSettle(preimage, aliceHtlcIndex)
-	if err != nil {
-		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
-	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("state transition error: %v", err)
-	}
+	// The channel should be usable again, so an HTLC can be added and
+	// locked in.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(10_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// At this point, for Alice's commitment chains, the value of the HTLC
-	// should have been added to Alice's balance and TotalSatoshisSent.
-	commitment := aliceChannel.localCommitChain.tip()
-	if len(commitment.txn.TxOut) != 2 {
-		t.Fatalf("incorrect # of outputs: expected %v, got %v",
-			2, len(commitment.txn.TxOut))
-	}
-	if aliceChannel.channelState.TotalMSatSent != htlcAmount {
-		t.Fatalf("alice satoshis sent incorrect: expected %v, got %v",
-			htlcAmount, aliceChannel.channelState.TotalMSatSent)
-	}
-}
+	// Once Alice signed a close proposal, Bob could broadcast the closing
+	// transaction at any time, so she can no longer abort.
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
+	require.Equal(t, ChannelClosing, aliceChannel.Status())
 
-// TestHTLCSigNumber tests that a received commitment is only accepted if it
-// comes with the exact number of valid HTLC signatures.
-func TestHTLCSigNumber(t *testing.T) {
-	t.Parallel()
+	err = aliceChannel.AbortCooperativeClose()
+	require.ErrorIs(t, err, ErrCoopCloseSigned)
+	require.Equal(t, ChannelClosing, aliceChannel.Status())
 
-	// createChanWithHTLC is a helper method that sets ut two channels, and
-	// adds HTLCs with the passed values to the channels.
-	createChanWithHTLC := func(htlcValues ...btcutil.Amount) (
-		*LightningChannel, *LightningChannel) {
+	// Once the close has been completed, it can no longer be aborted.
+	aliceChannel, bobChannel, err = CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-		// Create a test channel funded evenly with Alice having 5 BTC,
-		// and Bob having 5 BTC. Alice's dustlimit is 200 sat, while
-		// Bob has 1300 sat.
-		aliceChannel, bobChannel, err := CreateTestChannels(
-			t, channeldb.SingleFunderTweaklessBit,
-		)
-		if err != nil {
-			t.Fatalf("unable to create test channels: %v", err)
-		}
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
 
-		for i, htlcSat := range htlcValues {
-			htlcMsat := lnwire.NewMSatFromSatoshis(htlcSat)
-			htlc, _ := createHTLC(i, htlcMsat)
-			_, err := aliceChannel.AddHTLC(htlc, nil)
-			if err != nil {
-				t.Fatalf("alice unable to add htlc: %v", err)
-			}
-			_, err = bobChannel.ReceiveHTLC(htlc)
-			if err != nil {
-				t.Fatalf("bob unable to receive htlc: %v", err)
-			}
-		}
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		aliceFee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err)
 
-		return aliceChannel, bobChannel
-	}
+	_, _, err = aliceChannel.CompleteCooperativeClose(
+		aliceSig, bobSig, aliceDeliveryScript, bobDeliveryScript,
+		aliceFee,
+	)
+	require.NoError(t, err)
 
-	// Calculate two values that will be below and above Bob's dust limit.
-	estimator := chainfee.NewStaticEstimator(6000, 0)
-	feePerKw, err := estimator.EstimateFeePerKW(1)
-	require.NoError(t, err, "unable to get fee")
+	err = aliceChannel.AbortCooperativeClose()
+	require.ErrorIs(t, err, ErrCoopCloseFinalized)
+	require.Equal(t, ChannelClosed, aliceChannel.Status())
 
-	belowDust := btcutil.Amount(500) + HtlcTimeoutFee(
-		channeldb.SingleFunderTweaklessBit, feePerKw,
-	)
-	aboveDust := btcutil.Amount(1400) + HtlcSuccessFee(
-		channeldb.SingleFunderTweaklessBit, feePerKw,
-	)
+	// Bob hasn't completed the close himself, but once he marks the
+	// closing transaction as broadcast, he can't abort either.
+	closeTx := wire.NewMsgTx(2)
+	require.NoError(t, bobChannel.MarkCoopBroadcasted(closeTx, false))
 
-	// ===================================================================
-	// Test that Bob will reject a commitment if Alice doesn't send enough
-	// HTLC signatures.
-	// ===================================================================
-	aliceChannel, bobChannel := createChanWithHTLC(aboveDust, aboveDust)
+	err = bobChannel.AbortCooperativeClose()
+	require.ErrorIs(t, err, ErrCoopCloseFinalized)
+}
 
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "Error signing next commitment")
+// TestStateHintValidation asserts that a channel can only be restored if the
+// state hint of its latest local commitment decodes to its commitment height.
+func TestStateHintValidation(t *testing.T) {
+	t.Parallel()
 
-	if len(aliceNewCommit.HtlcSigs) != 2 {
-		t.Fatalf("expected 2 htlc sig, instead got %v",
-			len(aliceNewCommit.HtlcSigs))
-	}
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Now discard one signature from the htlcSig slice. Bob should reject
-	// the commitment because of this.
-	aliceNewCommitCopy := *aliceNewCommit
-	aliceNewCommitCopy.HtlcSigs = aliceNewCommitCopy.HtlcSigs[1:]
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommitCopy.CommitSigs)
-	if err == nil {
-		t.Fatalf("Expected Bob to reject signatures")
-	}
+	// Restoring the channel as is should succeed, as the state hint of the
+	// initial commitment has been properly encoded.
+	state := aliceChannel.channelState
+	_, err = NewLightningChannel(
+		aliceChannel.Signer, state, aliceChannel.sigPool,
+	)
+	require.NoError(t, err)
 
-	// ===================================================================
-	// Test that Bob will reject a commitment if Alice doesn't send any
-	// HTLC signatures.
-	// ===================================================================
-	aliceChannel, bobChannel = createChanWithHTLC(aboveDust)
+	// If the remote payment base point is misconfigured, the derived
+	// obfuscator no longer recovers the height of the stored commitment,
+	// so restoring the channel should fail.
+	remoteBasePoint := state.RemoteChanCfg.PaymentBasePoint
+	state.RemoteChanCfg.PaymentBasePoint = bobChannel.channelState.
+		RemoteChanCfg.DelayBasePoint
+	_, err = NewLightningChannel(
+		aliceChannel.Signer, state, aliceChannel.sigPool,
+	)
+	require.ErrorIs(t, err, ErrStateHintMismatch)
+	state.RemoteChanCfg.PaymentBasePoint = remoteBasePoint
 
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "Error signing next commitment")
+	// A commitment height that doesn't match the stored commitment should
+	// be rejected as well.
+	state.LocalCommitment.CommitHeight++
+	_, err = NewLightningChannel(
+		aliceChannel.Signer, state, aliceChannel.sigPool,
+	)
+	require.ErrorIs(t, err, ErrStateHintMismatch)
+	state.LocalCommitment.CommitHeight--
 
-	if len(aliceNewCommit.HtlcSigs) != 1 {
-		t.Fatalf("expected 1 htlc sig, instead got %v",
-			len(aliceNewCommit.HtlcSigs))
-	}
+	// Without a stored commitment transaction there's nothing to
+	// validate against.
+	state.LocalCommitment.CommitTx = nil
+	_, err = NewLightningChannel(
+		aliceChannel.Signer, state, aliceChannel.sigPool,
+	)
+	require.NoError(t, err)
+}
 
-	// Now just give Bob an empty htlcSig slice. He should reject the
-	// commitment because of this.
-	aliceCommitCopy := *aliceNewCommit.CommitSigs
-	aliceCommitCopy.HtlcSigs = []lnwire.Sig{}
-	err = bobChannel.ReceiveNewCommitment(&aliceCommitCopy)
-	if err == nil {
-		t.Fatalf("Expected Bob to reject signatures")
-	}
+// TestCsvDelayValidation asserts that CSV delays outside the range of 1 to the
+// maximum delay are detected, while channels with such delays can still be
+// loaded.
+func TestCsvDelayValidation(t *testing.T) {
+	t.Parallel()
 
-	// ==============================================================
-	// Test that sigs are not returned for HTLCs below dust limit.
-	// ==============================================================
-	aliceChannel, bobChannel = createChanWithHTLC(belowDust)
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "Error signing next commitment")
+	const maxDelay = 1000
 
-	// Since the HTLC is below Bob's dust limit, Alice won't need to send
-	// any signatures for this HTLC.
-	if len(aliceNewCommit.HtlcSigs) != 0 {
-		t.Fatalf("expected no htlc sigs, instead got %v",
-			len(aliceNewCommit.HtlcSigs))
+	testCases := []struct {
+		name        string
+		localDelay  uint16
+		remoteDelay uint16
+		valid       bool
+	}{
+		{"min delays", 1, 1, true},
+		{"max delays", maxDelay, maxDelay, true},
+		{"zero local delay", 0, 1, false},
+		{"zero remote delay", 1, 0, false},
+		{"local delay above max", maxDelay + 1, maxDelay, false},
+		{"remote delay above max", maxDelay, maxDelay + 1, false},
 	}
 
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "Bob failed receiving commitment")
+	state := aliceChannel.channelState
+	for _, tc := range testCases {
+		state.LocalChanCfg.CsvDelay = tc.localDelay
+		state.RemoteChanCfg.CsvDelay = tc.remoteDelay
 
-	// ================================================================
-	// Test that sigs are correctly returned for HTLCs above dust limit.
-	// ================================================================
-	aliceChannel, bobChannel = createChanWithHTLC(aboveDust)
+		err := validateCsvDelays(state, maxDelay)
+		if tc.valid {
+			require.NoError(t, err, tc.name)
+		} else {
+			require.ErrorIs(t, err, ErrInvalidCsvDelay, tc.name)
+		}
 
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "Error signing next commitment")
+		// The channel is loaded regardless, so its funds don't get
+		// stuck.
+		_, err = NewLightningChannel(
+			aliceChannel.Signer, state, aliceChannel.sigPool,
+			WithMaxCsvDelay(maxDelay),
+		)
+		require.NoError(t, err, tc.name)
+	}
 
-	// Since the HTLC is above Bob's dust limit, Alice should send a
-	// signature for this HTLC.
-	if len(aliceNewCommit.HtlcSigs) != 1 {
-		t.Fatalf("expected 1 htlc sig, instead got %v",
-			len(aliceNewCommit.HtlcSigs))
+	// A zero delay is refused when negotiating the channel.
+	constraints := &channeldb.ChannelConstraints{
+		DustLimit:        DustLimitForSize(input.UnknownWitnessSize),
+		ChanReserve:      10_000,
+		MaxPendingAmount: lnwire.NewMSatFromSatoshis(100_000),
+		MinHTLC:          1,
+		MaxAcceptedHtlcs: input.MaxHTLCNumber / 2,
+		CsvDelay:         0,
 	}
+	err = VerifyConstraints(
+		constraints, maxDelay, 1_000_000, ReserveBelowDustReject,
+	)
+	require.ErrorContains(t, err, "CSV delay of zero")
 
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "Bob failed receiving commitment")
+	constraints.CsvDelay = 1
+	err = VerifyConstraints(
+		constraints, maxDelay, 1_000_000, ReserveBelowDustReject,
+	)
+	require.NoError(t, err)
+}
 
-	// ====================================================================
-	// Test that Bob will not validate a received commitment if Alice sends
-	// signatures for HTLCs below the dust limit.
-	// ====================================================================
-	aliceChannel, bobChannel = createChanWithHTLC(belowDust, aboveDust)
+// TestCloseFeePaid asserts that only the initiator accounts for the fee of
+// its current commitment, and that the fee is replaced rather than
+// accumulated on every state transition.
+func TestCloseFeePaid(t *testing.T) {
+	t.Parallel()
 
-	// Alice should produce only one signature, since one HTLC is below
-	// dust.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "Error signing next commitment")
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	if len(aliceNewCommit.HtlcSigs) != 1 {
-		t.Fatalf("expected 1 htlc sig, instead got %v",
-			len(aliceNewCommit.HtlcSigs))
-	}
+	// Alice is the initiator, so after a state transition with a new fee
+	// rate she should account for the fee of her new commitment.
+	fee := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) * 2
+	require.NoError(t, aliceChannel.UpdateFee(fee))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(fee))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Add an extra signature.
-	aliceNewCommit.HtlcSigs = append(
-		aliceNewCommit.HtlcSigs, aliceNewCommit.HtlcSigs[0],
-	)
+	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
+	require.Equal(t, commitFee, aliceChannel.CloseFeePaid())
+	require.Zero(t, bobChannel.CloseFeePaid())
 
-	// Bob should reject these signatures since they don't match the number
-	// of HTLCs above dust.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err == nil {
-		t.Fatalf("Expected Bob to reject signatures")
-	}
+	// Another state transition without a fee change should leave the
+	// close fee untouched.
+	htlc, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	require.Equal(
+		t, aliceChannel.channelState.LocalCommitment.CommitFee,
+		aliceChannel.CloseFeePaid(),
+	)
+	require.Zero(t, bobChannel.CloseFeePaid())
 }
 
-// TestChannelBalanceDustLimit tests the condition when the remaining balance
-// for one of the channel participants is so small as to be considered dust. In
-// this case, the output for that participant is removed and all funds (minus
-// fees) in the commitment transaction are allocated to the remaining channel
-// participant.
-//
-// TODO(roasbeef): test needs to be fixed after reserve limits are done
-func TestChannelBalanceDustLimit(t *testing.T) {
+// TestChannelStatusUpdates asserts that the state of the channel transitions
+// as expected throughout a cooperative close and a force close, and that
+// every transition is delivered through the status updates channel without
+// blocking the state machine.
+func TestChannelStatusUpdates(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// To allow Alice's balance to get beneath her dust limit, set the
-	// channel reserve to be 0.
-	aliceChannel.channelState.LocalChanCfg.ChanReserve = 0
-	bobChannel.channelState.RemoteChanCfg.ChanReserve = 0
+	require.Equal(t, ChannelOpen, aliceChannel.Status())
+	require.Equal(t, ChannelOpen, bobChannel.Status())
 
-	// This amount should leave an amount larger than Alice's dust limit
-	// once fees have been subtracted, but smaller than Bob's dust limit.
-	// We account in fees for the HTLC we will be adding.
-	defaultFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 1)
-	aliceBalance := aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis()
-	htlcSat := aliceBalance - defaultFee
-	htlcSat += HtlcSuccessFee(
-		aliceChannel.channelState.ChanType,
-		chainfee.SatPerKWeight(
-			aliceChannel.channelState.LocalCommitment.FeePerKw,
-		),
-	)
+	// assertStatus asserts that the given channel is in the expected state
+	// and that the transition was delivered.
+	assertStatus := func(lc *LightningChannel, expected ChannelState) {
+		t.Helper()
 
-	htlcAmount := lnwire.NewMSatFromSatoshis(htlcSat)
+		require.Equal(t, expected, lc.Status())
 
-	htlc, preimage := createHTLC(0, htlcAmount)
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "alice unable to add htlc")
-	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "bob unable to receive htlc")
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("state transition error: %v", err)
-	}
-	err = bobChannel.SettleHTLC(preimage, bobHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "bob unable to settle inbound htlc")
-	err = aliceChannel.ReceiveHTLCSettle(preimage, aliceHtlcIndex)
-	if err != nil {
-		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
-	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("state transition error: %v", err)
+		select {
+		case status := <-lc.StatusUpdates():
+			require.Equal(t, expected, status)
+
+		default:
+			t.Fatalf("no status update for %v", expected)
+		}
 	}
 
-	// At the conclusion of this test, in Bob's commitment chains, the
-	// output for Alice's balance should have been removed as dust, leaving
-	// only a single output that will send the remaining funds in the
-	// channel to Bob.
-	commitment := bobChannel.localCommitChain.tip()
-	if len(commitment.txn.TxOut) != 1 {
-		t.Fatalf("incorrect # of outputs: expected %v, got %v",
-			1, len(commitment.txn.TxOut))
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
+
+	// Creating a close proposal marks the channel as closing.
+	fee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	))
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		fee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err)
+	assertStatus(aliceChannel, ChannelClosing)
+
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		fee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err)
+	assertStatus(bobChannel, ChannelClosing)
+
+	// Completing the close marks it as closed, after which no further
+	// proposals are accepted.
+	_, _, err = aliceChannel.CompleteCooperativeClose(
+		aliceSig, bobSig, aliceDeliveryScript, bobDeliveryScript, fee,
+	)
+	require.NoError(t, err)
+	assertStatus(aliceChannel, ChannelClosed)
+
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		fee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.ErrorIs(t, err, ErrChanClosing)
+
+	// Resetting the state of Bob's channel re-opens it, and force closing
+	// it moves it into the dispute state.
+	bobChannel.ResetState()
+	assertStatus(bobChannel, ChannelOpen)
+
+	_, err = bobChannel.ForceClose()
+	require.NoError(t, err)
+	assertStatus(bobChannel, ChannelDispute)
+
+	// A consumer that doesn't keep up shouldn't stall the state machine.
+	// Once it catches up, the last transition it receives must reflect
+	// the current state.
+	for i := 0; i < statusUpdateBufferSize*2; i++ {
+		bobChannel.ResetState()
+		_, err := bobChannel.ForceClose()
+		require.NoError(t, err)
 	}
-	if aliceChannel.channelState.TotalMSatSent != htlcAmount {
-		t.Fatalf("alice satoshis sent incorrect: expected %v, got %v",
-			htlcAmount, aliceChannel.channelState.TotalMSatSent)
+
+	var lastStatus ChannelState
+	for len(bobChannel.StatusUpdates()) > 0 {
+		lastStatus = <-bobChannel.StatusUpdates()
 	}
+	require.Equal(t, ChannelDispute, lastStatus)
+	require.Equal(t, ChannelDispute, bobChannel.Status())
 }
 
-func TestStateUpdatePersistence(t *testing.T) {
+// TestForceClose checks that the resulting ForceCloseSummary is correct when a
+// peer is ForceClosing the channel. Will check outputs both above and below
+// the dust limit. Additionally, we'll ensure that the node which executed the
+// force close generates HTLC resolutions that are capable of sweeping both
+// incoming and outgoing HTLC's.
+func TestForceClose(t *testing.T) {
+	t.Run("tweakless", func(t *testing.T) {
+		testForceClose(t, &forceCloseTestCase{
+			chanType:             channeldb.SingleFunderTweaklessBit,
+			expectedCommitWeight: input.CommitWeight,
+		})
+	})
+	t.Run("anchors", func(t *testing.T) {
+		testForceClose(t, &forceCloseTestCase{
+			chanType: channeldb.SingleFunderTweaklessBit |
+				channeldb.AnchorOutputsBit,
+			expectedCommitWeight: input.AnchorCommitWeight,
+			anchorAmt:            anchorSize * 2,
+		})
+	})
+	t.Run("taproot", func(t *testing.T) {
+		testForceClose(t, &forceCloseTestCase{
+			chanType: channeldb.SingleFunderTweaklessBit |
+				channeldb.AnchorOutputsBit |
+				channeldb.SimpleTaprootFeatureBit,
+			expectedCommitWeight: input.TaprootCommitWeight,
+			anchorAmt:            anchorSize * 2,
+		})
+	})
+}
+
+type forceCloseTestCase struct {
+	chanType             channeldb.ChannelType
+	expectedCommitWeight int64
+	anchorAmt            btcutil.Amount
+}
+
+func testForceClose(t *testing.T, testCase *forceCloseTestCase) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+		t, testCase.chanType,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	htlcAmt := lnwire.NewMSatFromSatoshis(5000)
-
-	var fakeOnionBlob [lnwire.OnionPacketSize]byte
-	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
+	bobAmount := bobChannel.channelState.LocalCommitment.LocalBalance
 
-	// Alice adds 3 HTLCs to the update log, while Bob adds a single HTLC.
-	var alicePreimage [32]byte
-	copy(alicePreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xbb}, 32))
-	for i := 0; i < 3; i++ {
-		rHash := sha256.Sum256(alicePreimage[:])
-		h := &lnwire.UpdateAddHTLC{
-			ID:          uint64(i),
-			PaymentHash: rHash,
-			Amount:      htlcAmt,
-			Expiry:      uint32(10),
-			OnionBlob:   fakeOnionBlob,
-		}
+	// First, we'll add an outgoing HTLC from Alice to Bob, such that it
+	// will still be present within the broadcast commitment transaction.
+	// We'll ensure that the HTLC amount is above Alice's dust limit.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
 
-		if _, err := aliceChannel.AddHTLC(h, nil); err != nil {
-			t.Fatalf("unable to add alice's htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(h); err != nil {
-			t.Fatalf("unable to recv alice's htlc: %v", err)
-		}
+	// We'll also a distinct HTLC from Bob -> Alice. This way, Alice will
+	// have both an incoming and outgoing HTLC on her commitment
+	// transaction.
+	htlcBob, preimageBob := createHTLC(0, htlcAmount)
+	if _, err := bobChannel.AddHTLC(htlcBob, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
 	}
-	rHash := sha256.Sum256(bobPreimage[:])
-	bobh := &lnwire.UpdateAddHTLC{
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
-		OnionBlob:   fakeOnionBlob,
+	if _, err := aliceChannel.ReceiveHTLC(htlcBob); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
 	}
-	if _, err := bobChannel.AddHTLC(bobh, nil); err != nil {
-		t.Fatalf("unable to add bob's htlc: %v", err)
+
+	// Next, we'll perform two state transitions to ensure that both HTLC's
+	// get fully locked-in.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
 	}
-	if _, err := aliceChannel.ReceiveHTLC(bobh); err != nil {
-		t.Fatalf("unable to recv bob's htlc: %v", err)
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
 	}
 
-	// Also add a fee update to the update logs.
-	fee := chainfee.SatPerKWeight(333)
-	if err := aliceChannel.UpdateFee(fee); err != nil {
-		t.Fatalf("unable to send fee update")
+	// With the cache populated, we'll now attempt the force close
+	// initiated by Alice.
+	closeSummary, err := aliceChannel.ForceClose()
+	require.NoError(t, err, "unable to force close channel")
+
+	// Alice should detect that she can sweep the outgoing HTLC after a
+	// timeout, but also that she's able to sweep in incoming HTLC Bob sent
+	// her.
+	if len(closeSummary.HtlcResolutions.OutgoingHTLCs) != 1 {
+		t.Fatalf("alice out htlc resolutions not populated: expected %v "+
+			"htlcs, got %v htlcs",
+			1, len(closeSummary.HtlcResolutions.OutgoingHTLCs))
 	}
-	if err := bobChannel.ReceiveUpdateFee(fee); err != nil {
-		t.Fatalf("unable to receive fee update")
+	if len(closeSummary.HtlcResolutions.IncomingHTLCs) != 1 {
+		t.Fatalf("alice in htlc resolutions not populated: expected %v "+
+			"htlcs, got %v htlcs",
+			1, len(closeSummary.HtlcResolutions.IncomingHTLCs))
 	}
 
-	// Helper method that asserts the expected number of updates are found
-	// in the update logs.
-	assertNumLogUpdates := func(numAliceUpdates, numBobUpdates int) {
-		if aliceChannel.localUpdateLog.Len() != numAliceUpdates {
-			t.Fatalf("expected %d local updates, found %d",
-				numAliceUpdates,
-				aliceChannel.localUpdateLog.Len())
+	// Verify the anchor resolutions for the anchor commitment format.
+	if testCase.chanType.HasAnchors() {
+		// Check the close summary resolution.
+		anchorRes := closeSummary.AnchorResolution
+		if anchorRes == nil {
+			t.Fatal("expected anchor resolution")
 		}
-		if aliceChannel.remoteUpdateLog.Len() != numBobUpdates {
-			t.Fatalf("expected %d remote updates, found %d",
-				numBobUpdates,
-				aliceChannel.remoteUpdateLog.Len())
+		if anchorRes.CommitAnchor.Hash != closeSummary.CloseTx.TxHash() {
+			t.Fatal("commit tx not referenced by anchor res")
 		}
+		if anchorRes.AnchorSignDescriptor.Output.Value !=
+			int64(anchorSize) {
 
-		if bobChannel.localUpdateLog.Len() != numBobUpdates {
-			t.Fatalf("expected %d local updates, found %d",
-				numBobUpdates,
-				bobChannel.localUpdateLog.Len())
+			t.Fatal("unexpected anchor size")
 		}
-		if bobChannel.remoteUpdateLog.Len() != numAliceUpdates {
-			t.Fatalf("expected %d remote updates, found %d",
-				numAliceUpdates,
-				bobChannel.remoteUpdateLog.Len())
+		if anchorRes.AnchorSignDescriptor.WitnessScript == nil {
+			t.Fatal("expected anchor witness script")
 		}
-	}
 
-	// Both nodes should now have Alice's 3 Adds and 1 FeeUpdate in the
-	// log, and Bob's 1 Add.
-	assertNumLogUpdates(4, 1)
+		// Check the pre-confirmation resolutions.
+		res, err := aliceChannel.NewAnchorResolutions()
+		if err != nil {
+			t.Fatalf("pre-confirmation resolution error: %v", err)
+		}
 
-	// Next, Alice initiates a state transition to include the HTLC's she
-	// added above in a new commitment state.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete alice's state transition: %v", err)
+		// Check we have the expected anchor resolutions.
+		require.NotNil(t, res.Local, "expected local anchor resolution")
+		require.NotNil(t,
+			res.Remote, "expected remote anchor resolution",
+		)
+		require.Nil(t,
+			res.RemotePending, "expected no anchor resolution",
+		)
 	}
 
-	// Since the HTLC Bob sent wasn't included in Bob's version of the
-	// commitment transaction (but it was in Alice's, as he ACK'd her
-	// changes before creating a new state), Bob needs to trigger another
-	// state update in order to re-sync their states.
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
+	// The SelfOutputSignDesc should be non-nil since the output to-self is
+	// non-dust.
+	aliceCommitResolution := closeSummary.CommitResolution
+	if aliceCommitResolution == nil {
+		t.Fatalf("alice fails to include to-self output in " +
+			"ForceCloseSummary")
 	}
 
-	// After the state transition the fee update is fully locked in, and
-	// should've been removed from both channels' update logs.
-	if aliceChannel.localCommitChain.tail().feePerKw != fee {
-		t.Fatalf("fee not locked in")
-	}
-	if bobChannel.localCommitChain.tail().feePerKw != fee {
-		t.Fatalf("fee not locked in")
+	// The rest of the close summary should have been populated properly.
+	aliceDelayPoint := aliceChannel.channelState.LocalChanCfg.DelayBasePoint
+	if !aliceCommitResolution.SelfOutputSignDesc.KeyDesc.PubKey.IsEqual(
+		aliceDelayPoint.PubKey,
+	) {
+		t.Fatalf("alice incorrect pubkey in SelfOutputSignDesc")
 	}
-	assertNumLogUpdates(3, 1)
 
-	// The latest commitment from both sides should have all the HTLCs.
-	numAliceOutgoing := aliceChannel.localCommitChain.tail().outgoingHTLCs
-	numAliceIncoming := aliceChannel.localCommitChain.tail().incomingHTLCs
-	if len(numAliceOutgoing) != 3 {
-		t.Fatalf("expected %v htlcs, instead got %v", 3, numAliceOutgoing)
-	}
-	if len(numAliceIncoming) != 1 {
-		t.Fatalf("expected %v htlcs, instead got %v", 1, numAliceIncoming)
-	}
-	numBobOutgoing := bobChannel.localCommitChain.tail().outgoingHTLCs
-	numBobIncoming := bobChannel.localCommitChain.tail().incomingHTLCs
-	if len(numBobOutgoing) != 1 {
-		t.Fatalf("expected %v htlcs, instead got %v", 1, numBobOutgoing)
+	// Factoring in the fee rate, Alice's amount should properly reflect
+	// that we've added two additional HTLC to the commitment transaction.
+	totalCommitWeight := testCase.expectedCommitWeight +
+		(input.HTLCWeight * 2)
+	feePerKw := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	commitFee := feePerKw.FeeForWeight(totalCommitWeight)
+
+	expectedAmount := (aliceChannel.Capacity / 2) -
+		htlcAmount.ToSatoshis() - commitFee - testCase.anchorAmt
+
+	if aliceCommitResolution.SelfOutputSignDesc.Output.Value != int64(expectedAmount) {
+		t.Fatalf("alice incorrect output value in SelfOutputSignDesc, "+
+			"expected %v, got %v", int64(expectedAmount),
+			aliceCommitResolution.SelfOutputSignDesc.Output.Value)
 	}
-	if len(numBobIncoming) != 3 {
-		t.Fatalf("expected %v htlcs, instead got %v", 3, numBobIncoming)
+
+	// Alice's listed CSV delay should also match the delay that was
+	// pre-committed to at channel opening.
+	if aliceCommitResolution.MaturityDelay !=
+		uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay) {
+
+		t.Fatalf("alice: incorrect local CSV delay in ForceCloseSummary, "+
+			"expected %v, got %v",
+			aliceChannel.channelState.LocalChanCfg.CsvDelay,
+			aliceCommitResolution.MaturityDelay)
 	}
 
-	// TODO(roasbeef): also ensure signatures were stored
-	//  * ensure expiry matches
+	// Next, we'll ensure that the second level HTLC transaction it itself
+	// spendable, and also that the delivery output (with delay) itself has
+	// a valid sign descriptor.
+	htlcResolution := closeSummary.HtlcResolutions.OutgoingHTLCs[0]
+	outHtlcIndex := htlcResolution.SignedTimeoutTx.TxIn[0].PreviousOutPoint.Index
+	senderHtlcPkScript := closeSummary.CloseTx.TxOut[outHtlcIndex].PkScript
 
-	// Now fetch both of the channels created above from disk to simulate a
-	// node restart with persistence.
-	alicePub := aliceChannel.channelState.IdentityPub
-	aliceChannels, err := aliceChannel.channelState.Db.FetchOpenChannels(
-		alicePub,
+	// First, verify that the second level transaction can properly spend
+	// the multi-sig clause within the output on the commitment transaction
+	// that produces this HTLC.
+	timeoutTx := htlcResolution.SignedTimeoutTx
+	prevOutputFetcher := txscript.NewCannedPrevOutputFetcher(
+		senderHtlcPkScript, int64(htlcAmount.ToSatoshis()),
 	)
-	require.NoError(t, err, "unable to fetch channel")
-	bobPub := bobChannel.channelState.IdentityPub
-	bobChannels, err := bobChannel.channelState.Db.FetchOpenChannels(bobPub)
-	require.NoError(t, err, "unable to fetch channel")
-
-	aliceChannelNew, err := NewLightningChannel(
-		aliceChannel.Signer, aliceChannels[0], aliceChannel.sigPool,
+	hashCache := txscript.NewTxSigHashes(timeoutTx, prevOutputFetcher)
+	vm, err := txscript.NewEngine(
+		senderHtlcPkScript,
+		timeoutTx, 0, txscript.StandardVerifyFlags, nil,
+		hashCache, int64(htlcAmount.ToSatoshis()), prevOutputFetcher,
 	)
-	require.NoError(t, err, "unable to create new channel")
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc timeout spend is invalid: %v", err)
+	}
 
-	bobChannelNew, err := NewLightningChannel(
-		bobChannel.Signer, bobChannels[0], bobChannel.sigPool,
-	)
-	require.NoError(t, err, "unable to create new channel")
+	// Next, we'll ensure that we can spend the output of the second level
+	// transaction given a properly crafted sweep transaction.
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{
+			Hash:  htlcResolution.SignedTimeoutTx.TxHash(),
+			Index: 0,
+		},
+	})
+	sweepTx.AddTxOut(&wire.TxOut{
+		PkScript: senderHtlcPkScript,
+		Value:    htlcResolution.SweepSignDesc.Output.Value,
+	})
+	htlcResolution.SweepSignDesc.InputIndex = 0
 
-	// The state update logs of the new channels and the old channels
-	// should now be identical other than the height the HTLCs were added.
-	if aliceChannel.localUpdateLog.logIndex !=
-		aliceChannelNew.localUpdateLog.logIndex {
-		t.Fatalf("alice log counter: expected %v, got %v",
-			aliceChannel.localUpdateLog.logIndex,
-			aliceChannelNew.localUpdateLog.logIndex)
-	}
-	if aliceChannel.remoteUpdateLog.logIndex !=
-		aliceChannelNew.remoteUpdateLog.logIndex {
-		t.Fatalf("alice log counter: expected %v, got %v",
-			aliceChannel.remoteUpdateLog.logIndex,
-			aliceChannelNew.remoteUpdateLog.logIndex)
-	}
-	if aliceChannel.localUpdateLog.Len() !=
-		aliceChannelNew.localUpdateLog.Len() {
-		t.Fatalf("alice log len: expected %v, got %v",
-			aliceChannel.localUpdateLog.Len(),
-			aliceChannelNew.localUpdateLog.Len())
-	}
-	if aliceChannel.remoteUpdateLog.Len() !=
-		aliceChannelNew.remoteUpdateLog.Len() {
-		t.Fatalf("alice log len: expected %v, got %v",
-			aliceChannel.remoteUpdateLog.Len(),
-			aliceChannelNew.remoteUpdateLog.Len())
-	}
-	if bobChannel.localUpdateLog.logIndex !=
-		bobChannelNew.localUpdateLog.logIndex {
-		t.Fatalf("bob log counter: expected %v, got %v",
-			bobChannel.localUpdateLog.logIndex,
-			bobChannelNew.localUpdateLog.logIndex)
-	}
-	if bobChannel.remoteUpdateLog.logIndex !=
-		bobChannelNew.remoteUpdateLog.logIndex {
-		t.Fatalf("bob log counter: expected %v, got %v",
-			bobChannel.remoteUpdateLog.logIndex,
-			bobChannelNew.remoteUpdateLog.logIndex)
+	csvDelay := uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay)
+	if testCase.chanType.IsTaproot() {
+		sweepTx.TxIn[0].Sequence = input.LockTimeToSequence(
+			false, csvDelay,
+		)
+		sweepTx.TxIn[0].Witness, err = input.TaprootHtlcSpendSuccess(
+			aliceChannel.Signer, &htlcResolution.SweepSignDesc,
+			sweepTx, nil, nil,
+		)
+	} else {
+		sweepTx.TxIn[0].Witness, err = input.HtlcSpendSuccess(
+			aliceChannel.Signer, &htlcResolution.SweepSignDesc,
+			sweepTx, csvDelay,
+		)
 	}
-	if bobChannel.localUpdateLog.Len() !=
-		bobChannelNew.localUpdateLog.Len() {
-		t.Fatalf("bob log len: expected %v, got %v",
-			bobChannel.localUpdateLog.Len(),
-			bobChannelNew.localUpdateLog.Len())
+	require.NoError(t, err, "unable to gen witness for timeout output")
+
+	// With the witness fully populated for the success spend from the
+	// second-level transaction, we ensure that the scripts properly
+	// validate given the information within the htlc resolution struct.
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+		htlcResolution.SweepSignDesc.Output.PkScript,
+		htlcResolution.SweepSignDesc.Output.Value,
+	)
+	hashCache = txscript.NewTxSigHashes(sweepTx, prevOutFetcher)
+	vm, err = txscript.NewEngine(
+		htlcResolution.SweepSignDesc.Output.PkScript,
+		sweepTx, 0, txscript.StandardVerifyFlags, nil,
+		hashCache, htlcResolution.SweepSignDesc.Output.Value,
+		prevOutFetcher,
+	)
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc timeout spend is invalid: %v", err)
 	}
-	if bobChannel.remoteUpdateLog.Len() !=
-		bobChannelNew.remoteUpdateLog.Len() {
-		t.Fatalf("bob log len: expected %v, got %v",
-			bobChannel.remoteUpdateLog.Len(),
-			bobChannelNew.remoteUpdateLog.Len())
+
+	// Finally, the txid of the commitment transaction and the one returned
+	// as the closing transaction should also match.
+	closeTxHash := closeSummary.CloseTx.TxHash()
+	commitTxHash := aliceChannel.channelState.LocalCommitment.CommitTx.TxHash()
+	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
+		t.Fatalf("alice: incorrect close transaction txid")
 	}
 
-	// TODO(roasbeef): expand test to also ensure state revocation log has
-	// proper pk scripts
+	// We'll now perform similar set of checks to ensure that Alice is able
+	// to sweep the output that Bob sent to her on-chain with knowledge of
+	// the preimage.
+	inHtlcResolution := closeSummary.HtlcResolutions.IncomingHTLCs[0]
+	inHtlcIndex := inHtlcResolution.SignedSuccessTx.TxIn[0].PreviousOutPoint.Index
+	receiverHtlcScript := closeSummary.CloseTx.TxOut[inHtlcIndex].PkScript
 
-	// Newly generated pkScripts for HTLCs should be the same as in the old channel.
-	for _, entry := range aliceChannel.localUpdateLog.htlcIndex {
-		htlc := entry.Value.(*PaymentDescriptor)
-		restoredHtlc := aliceChannelNew.localUpdateLog.lookupHtlc(htlc.HtlcIndex)
-		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
-			t.Fatalf("alice ourPkScript in ourLog: expected %X, got %X",
-				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
-		}
-		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
-			t.Fatalf("alice theirPkScript in ourLog: expected %X, got %X",
-				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
-		}
+	// With the original pkscript located, we'll now verify that the second
+	// level transaction can spend from the multi-sig out. Supply the
+	// preimage manually. This is usually done by the contract resolver
+	// before publication.
+	successTx := inHtlcResolution.SignedSuccessTx
+
+	// For taproot channels, the preimage goes into a slightly different
+	// location.
+	if testCase.chanType.IsTaproot() {
+		successTx.TxIn[0].Witness[2] = preimageBob[:]
+	} else {
+		successTx.TxIn[0].Witness[3] = preimageBob[:]
 	}
-	for _, entry := range aliceChannel.remoteUpdateLog.htlcIndex {
-		htlc := entry.Value.(*PaymentDescriptor)
-		restoredHtlc := aliceChannelNew.remoteUpdateLog.lookupHtlc(htlc.HtlcIndex)
-		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
-			t.Fatalf("alice ourPkScript in theirLog: expected %X, got %X",
-				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
-		}
-		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
-			t.Fatalf("alice theirPkScript in theirLog: expected %X, got %X",
-				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
-		}
+
+	prevOuts := txscript.NewCannedPrevOutputFetcher(
+		receiverHtlcScript, int64(htlcAmount.ToSatoshis()),
+	)
+	hashCache = txscript.NewTxSigHashes(successTx, prevOuts)
+	vm, err = txscript.NewEngine(
+		receiverHtlcScript,
+		successTx, 0, txscript.StandardVerifyFlags, nil,
+		hashCache, int64(htlcAmount.ToSatoshis()), prevOuts,
+	)
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc success spend is invalid: %v", err)
 	}
-	for _, entry := range bobChannel.localUpdateLog.htlcIndex {
-		htlc := entry.Value.(*PaymentDescriptor)
-		restoredHtlc := bobChannelNew.localUpdateLog.lookupHtlc(htlc.HtlcIndex)
-		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
-			t.Fatalf("bob ourPkScript in ourLog: expected %X, got %X",
-				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
-		}
-		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
-			t.Fatalf("bob theirPkScript in ourLog: expected %X, got %X",
-				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
-		}
-	}
-	for _, entry := range bobChannel.remoteUpdateLog.htlcIndex {
-		htlc := entry.Value.(*PaymentDescriptor)
-		restoredHtlc := bobChannelNew.remoteUpdateLog.lookupHtlc(htlc.HtlcIndex)
-		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
-			t.Fatalf("bob ourPkScript in theirLog: expected %X, got %X",
-				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
-		}
-		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
-			t.Fatalf("bob theirPkScript in theirLog: expected %X, got %X",
-				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
-		}
+
+	// Finally, we'll construct a transaction to spend the produced
+	// second-level output with the attached SignDescriptor.
+	sweepTx = wire.NewMsgTx(2)
+	sweepTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: inHtlcResolution.ClaimOutpoint,
+	})
+	sweepTx.AddTxOut(&wire.TxOut{
+		PkScript: receiverHtlcScript,
+		Value:    inHtlcResolution.SweepSignDesc.Output.Value,
+	})
+	inHtlcResolution.SweepSignDesc.InputIndex = 0
+	if testCase.chanType.IsTaproot() {
+		sweepTx.TxIn[0].Sequence = input.LockTimeToSequence(
+			false, csvDelay,
+		)
+		sweepTx.TxIn[0].Witness, err = input.TaprootHtlcSpendSuccess(
+			aliceChannel.Signer, &inHtlcResolution.SweepSignDesc,
+			sweepTx, nil, nil,
+		)
+	} else {
+		sweepTx.TxIn[0].Witness, err = input.HtlcSpendSuccess(
+			aliceChannel.Signer, &inHtlcResolution.SweepSignDesc,
+			sweepTx,
+			uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay),
+		)
 	}
+	require.NoError(t, err, "unable to gen witness for timeout output")
 
-	// Now settle all the HTLCs, then force a state update. The state
-	// update should succeed as both sides have identical.
-	for i := 0; i < 3; i++ {
-		err := bobChannelNew.SettleHTLC(alicePreimage, uint64(i), nil, nil, nil)
-		if err != nil {
-			t.Fatalf("unable to settle htlc #%v: %v", i, err)
-		}
-		err = aliceChannelNew.ReceiveHTLCSettle(alicePreimage, uint64(i))
-		if err != nil {
-			t.Fatalf("unable to settle htlc#%v: %v", i, err)
-		}
+	// The spend we create above spending the second level HTLC output
+	// should validate without any issues.
+	prevOuts = txscript.NewCannedPrevOutputFetcher(
+		inHtlcResolution.SweepSignDesc.Output.PkScript,
+		inHtlcResolution.SweepSignDesc.Output.Value,
+	)
+	hashCache = txscript.NewTxSigHashes(sweepTx, prevOuts)
+	vm, err = txscript.NewEngine(
+		inHtlcResolution.SweepSignDesc.Output.PkScript,
+		sweepTx, 0, txscript.StandardVerifyFlags, nil,
+		hashCache, inHtlcResolution.SweepSignDesc.Output.Value,
+		prevOuts,
+	)
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc timeout spend is invalid: %v", err)
 	}
-	err = aliceChannelNew.SettleHTLC(bobPreimage, 0, nil, nil, nil)
-	require.NoError(t, err, "unable to settle htlc")
-	err = bobChannelNew.ReceiveHTLCSettle(bobPreimage, 0)
-	require.NoError(t, err, "unable to settle htlc")
 
-	// Similar to the two transitions above, as both Bob and Alice added
-	// entries to the update log before a state transition was initiated by
-	// either side, both sides are required to trigger an update in order
-	// to lock in their changes.
-	if err := ForceStateTransition(aliceChannelNew, bobChannelNew); err != nil {
-		t.Fatalf("unable to update commitments: %v", err)
+	// Check the same for Bob's ForceCloseSummary.
+	closeSummary, err = bobChannel.ForceClose()
+	require.NoError(t, err, "unable to force close channel")
+	bobCommitResolution := closeSummary.CommitResolution
+	if bobCommitResolution == nil {
+		t.Fatalf("bob fails to include to-self output in ForceCloseSummary")
 	}
-	if err := ForceStateTransition(bobChannelNew, aliceChannelNew); err != nil {
-		t.Fatalf("unable to update commitments: %v", err)
+	bobDelayPoint := bobChannel.channelState.LocalChanCfg.DelayBasePoint
+	if !bobCommitResolution.SelfOutputSignDesc.KeyDesc.PubKey.IsEqual(bobDelayPoint.PubKey) {
+		t.Fatalf("bob incorrect pubkey in SelfOutputSignDesc")
 	}
+	if bobCommitResolution.SelfOutputSignDesc.Output.Value !=
+		int64(bobAmount.ToSatoshis()-htlcAmount.ToSatoshis()) {
 
-	// The amounts transferred should been updated as per the amounts in
-	// the HTLCs
-	if aliceChannelNew.channelState.TotalMSatSent != htlcAmt*3 {
-		t.Fatalf("expected %v alice satoshis sent, got %v",
-			htlcAmt*3, aliceChannelNew.channelState.TotalMSatSent)
-	}
-	if aliceChannelNew.channelState.TotalMSatReceived != htlcAmt {
-		t.Fatalf("expected %v alice satoshis received, got %v",
-			htlcAmt, aliceChannelNew.channelState.TotalMSatReceived)
+		t.Fatalf("bob incorrect output value in SelfOutputSignDesc, "+
+			"expected %v, got %v",
+			bobAmount.ToSatoshis(),
+			int64(bobCommitResolution.SelfOutputSignDesc.Output.Value))
 	}
-	if bobChannelNew.channelState.TotalMSatSent != htlcAmt {
-		t.Fatalf("expected %v bob satoshis sent, got %v",
-			htlcAmt, bobChannel.channelState.TotalMSatSent)
+	if bobCommitResolution.MaturityDelay !=
+		uint32(bobChannel.channelState.LocalChanCfg.CsvDelay) {
+
+		t.Fatalf("bob: incorrect local CSV delay in ForceCloseSummary, "+
+			"expected %v, got %v",
+			bobChannel.channelState.LocalChanCfg.CsvDelay,
+			bobCommitResolution.MaturityDelay)
 	}
-	if bobChannelNew.channelState.TotalMSatReceived != htlcAmt*3 {
-		t.Fatalf("expected %v bob satoshis sent, got %v",
-			htlcAmt*3, bobChannel.channelState.TotalMSatReceived)
+
+	closeTxHash = closeSummary.CloseTx.TxHash()
+	commitTxHash = bobChannel.channelState.LocalCommitment.CommitTx.TxHash()
+	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
+		t.Fatalf("bob: incorrect close transaction txid")
 	}
 
-	// As a final test, we'll ensure that the HTLC counters for both sides
-	// has been persisted properly. If we instruct Alice to add a new HTLC,
-	// it should have an index of 3. If we instruct Bob to do the
-	// same, it should have an index of 1.
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(bobh, nil)
-	require.NoError(t, err, "unable to add htlc")
-	if aliceHtlcIndex != 3 {
-		t.Fatalf("wrong htlc index: expected %v, got %v", 3, aliceHtlcIndex)
+	// As we didn't add the preimage of Alice's HTLC to bob's preimage
+	// cache, he should only detect that he can sweep only his outgoing
+	// HTLC upon force close.
+	if len(closeSummary.HtlcResolutions.OutgoingHTLCs) != 1 {
+		t.Fatalf("alice out htlc resolutions not populated: expected %v "+
+			"htlcs, got %v htlcs",
+			1, len(closeSummary.HtlcResolutions.OutgoingHTLCs))
 	}
-	bobHtlcIndex, err := bobChannel.AddHTLC(bobh, nil)
-	require.NoError(t, err, "unable to add htlc")
-	if bobHtlcIndex != 1 {
-		t.Fatalf("wrong htlc index: expected %v, got %v", 1, aliceHtlcIndex)
+
+	// Bob should recognize that the incoming HTLC is there, but the
+	// preimage should be empty as he doesn't have the knowledge required
+	// to sweep it.
+	if len(closeSummary.HtlcResolutions.IncomingHTLCs) != 1 {
+		t.Fatalf("bob in htlc resolutions not populated: expected %v "+
+			"htlcs, got %v htlcs",
+			1, len(closeSummary.HtlcResolutions.IncomingHTLCs))
 	}
 }
 
-func TestCancelHTLC(t *testing.T) {
+// TestSweepSchedule asserts that the sweep schedule of a force close lists our
+// to-self output and the outputs of our HTLCs ordered by the height at which
+// they mature.
+func TestSweepSchedule(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
-	require.NoError(t, err, "unable to create test channels")
+	require.NoError(t, err)
 
-	// Add a new HTLC from Alice to Bob, then trigger a new state
-	// transition in order to include it in the latest state.
-	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	// Alice offers two HTLCs to Bob, the one added first timing out
+	// last.
+	const currentHeight = 100
+	expiries := []uint32{currentHeight + 500, currentHeight + 20}
+	for i, expiry := range expiries {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(20_000))
+		htlc.Expiry = expiry
 
-	var preImage [32]byte
-	copy(preImage[:], bytes.Repeat([]byte{0xaa}, 32))
-	htlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: sha256.Sum256(preImage[:]),
-		Amount:      htlcAmt,
-		Expiry:      10,
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
 	}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "unable to add alice htlc")
-	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "unable to add bob htlc")
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to create new commitment state: %v", err)
-	}
+	summary, err := aliceChannel.ForceClose()
+	require.NoError(t, err)
 
-	// With the HTLC committed, Alice's balance should reflect the clearing
-	// of the new HTLC.
-	aliceExpectedBalance := btcutil.Amount(btcutil.SatoshiPerBitcoin*4) -
-		calcStaticFee(channeldb.SingleFunderTweaklessBit, 1)
-	if aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis() !=
-		aliceExpectedBalance {
-		t.Fatalf("Alice's balance is wrong: expected %v, got %v",
-			aliceExpectedBalance,
-			aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis())
-	}
+	schedule := summary.SweepSchedule(currentHeight)
+	require.Len(t, schedule, 3)
 
-	// Now, with the HTLC committed on both sides, trigger a cancellation
-	// from Bob to Alice, removing the HTLC.
-	err = bobChannel.FailHTLC(bobHtlcIndex, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveFailHTLC(aliceHtlcIndex, []byte("bad"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+	// The to-self output matures first, once its CSV delay passed.
+	commitRes := summary.CommitResolution
+	require.Equal(t, commitRes.SelfOutPoint, schedule[0].OutPoint)
+	require.EqualValues(
+		t, commitRes.SelfOutputSignDesc.Output.Value,
+		schedule[0].Value,
+	)
+	require.Equal(t, &commitRes.SelfOutputSignDesc, schedule[0].SignDesc)
+	require.Nil(t, schedule[0].SecondLevelTx)
+	require.Equal(
+		t, currentHeight+commitRes.MaturityDelay,
+		schedule[0].MaturityHeight,
+	)
 
-	// Now trigger another state transition, the HTLC should now be removed
-	// from both sides, with balances reflected.
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to create new commitment: %v", err)
-	}
+	// The HTLCs follow in order of their expiry, each maturing once the
+	// CSV delay of its second level output passed.
+	htlcRes := summary.HtlcResolutions.OutgoingHTLCs
+	require.Len(t, htlcRes, 2)
+	for i, expiry := range []uint32{expiries[1], expiries[0]} {
+		item := schedule[i+1]
 
-	// Now HTLCs should be present on the commitment transaction for either
-	// side.
-	if len(aliceChannel.localCommitChain.tip().outgoingHTLCs) != 0 ||
-		len(aliceChannel.remoteCommitChain.tip().outgoingHTLCs) != 0 {
-		t.Fatalf("htlc's still active from alice's POV")
-	}
-	if len(aliceChannel.localCommitChain.tip().incomingHTLCs) != 0 ||
-		len(aliceChannel.remoteCommitChain.tip().incomingHTLCs) != 0 {
-		t.Fatalf("htlc's still active from alice's POV")
-	}
-	if len(bobChannel.localCommitChain.tip().outgoingHTLCs) != 0 ||
-		len(bobChannel.remoteCommitChain.tip().outgoingHTLCs) != 0 {
-		t.Fatalf("htlc's still active from bob's POV")
-	}
-	if len(bobChannel.localCommitChain.tip().incomingHTLCs) != 0 ||
-		len(bobChannel.remoteCommitChain.tip().incomingHTLCs) != 0 {
-		t.Fatalf("htlc's still active from bob's POV")
+		var res *OutgoingHtlcResolution
+		for j := range htlcRes {
+			if htlcRes[j].Expiry == expiry {
+				res = &htlcRes[j]
+			}
+		}
+		require.NotNil(t, res)
+
+		require.Equal(t, res.ClaimOutpoint, item.OutPoint)
+		require.EqualValues(
+			t, res.SweepSignDesc.Output.Value, item.Value,
+		)
+		require.Equal(t, &res.SweepSignDesc, item.SignDesc)
+		require.Equal(t, res.SignedTimeoutTx, item.SecondLevelTx)
+		require.Equal(t, expiry+res.CsvDelay, item.MaturityHeight)
 	}
+}
 
-	expectedBalance := btcutil.Amount(btcutil.SatoshiPerBitcoin * 5)
-	staticFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 0)
-	if aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis() !=
-		expectedBalance-staticFee {
+// lockInTestHtlcs adds numHtlcs HTLCs offered by each party to the channel, and
+// locks them in. The channels are recreated with a maximum commitment weight
+// that allows for all HTLCs.
+func lockInTestHtlcs(t testing.TB, aliceChannel, bobChannel *LightningChannel,
+	numHtlcs int) (*LightningChannel, *LightningChannel) {
 
-		t.Fatalf("balance is wrong: expected %v, got %v",
-			aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis(),
-			expectedBalance-staticFee)
-	}
-	if aliceChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis() !=
-		expectedBalance {
+	aliceChannel, err := NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithMaxCommitWeight(math.MaxInt64),
+	)
+	require.NoError(t, err)
+	bobChannel, err = NewLightningChannel(
+		bobChannel.Signer, bobChannel.channelState,
+		bobChannel.sigPool, WithMaxCommitWeight(math.MaxInt64),
+	)
+	require.NoError(t, err)
 
-		t.Fatalf("balance is wrong: expected %v, got %v",
-			aliceChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis(),
-			expectedBalance)
-	}
-	if bobChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis() !=
-		expectedBalance {
+	htlcAmt := lnwire.NewMSatFromSatoshis(20_000)
+	for i := 0; i < numHtlcs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
 
-		t.Fatalf("balance is wrong: expected %v, got %v",
-			bobChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis(),
-			expectedBalance)
+		htlc, _ = createHTLC(i, htlcAmt)
+		_, err = bobChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = aliceChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
 	}
-	if bobChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis() !=
-		expectedBalance-staticFee {
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-		t.Fatalf("balance is wrong: expected %v, got %v",
-			bobChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis(),
-			expectedBalance-staticFee)
-	}
+	return aliceChannel, bobChannel
 }
 
-func TestCooperativeCloseDustAdherence(t *testing.T) {
+// TestForceCloseSkipContractResolutions asserts that a force close that skips
+// the contract resolutions returns the same commitment, to-self and anchor
+// resolutions as a regular one, and that the HTLC resolutions can be
+// generated later.
+func TestForceCloseSkipContractResolutions(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+		t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	aliceFeeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	)
-	bobFeeRate := chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
+	aliceChannel, _ = lockInTestHtlcs(t, aliceChannel, bobChannel, 3)
+
+	summary, err := aliceChannel.ForceClose(WithSkipContractResolutions())
+	require.NoError(t, err)
+	require.Nil(t, summary.HtlcResolutions)
+	require.NotNil(t, summary.AnchorResolution)
+	require.NotNil(t, summary.CommitResolution)
+
+	fullSummary, err := aliceChannel.ForceClose()
+	require.NoError(t, err)
+	require.Equal(t, fullSummary.CloseTx, summary.CloseTx)
+	require.Equal(t, fullSummary.CommitResolution, summary.CommitResolution)
+	require.Equal(t, fullSummary.AnchorResolution, summary.AnchorResolution)
+
+	// The HTLC resolutions generated afterwards match the ones of the
+	// regular force close.
+	htlcResolutions, err := aliceChannel.LocalHtlcResolutions()
+	require.NoError(t, err)
+	require.Len(t, htlcResolutions.IncomingHTLCs, 3)
+	require.Len(t, htlcResolutions.OutgoingHTLCs, 3)
+	require.Equal(t, fullSummary.HtlcResolutions, htlcResolutions)
+}
+
+// BenchmarkForceClose compares the cost of a force close with and without the
+// generation of the contract resolutions on a channel with 400 HTLCs.
+func BenchmarkForceClose(b *testing.B) {
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		b, channeldb.SingleFunderTweaklessBit,
 	)
+	require.NoError(b, err, "unable to create test channels")
 
-	setDustLimit := func(dustVal btcutil.Amount) {
-		aliceChannel.channelState.LocalChanCfg.DustLimit = dustVal
-		aliceChannel.channelState.RemoteChanCfg.DustLimit = dustVal
-		bobChannel.channelState.LocalChanCfg.DustLimit = dustVal
-		bobChannel.channelState.RemoteChanCfg.DustLimit = dustVal
-	}
+	aliceChannel, _ = lockInTestHtlcs(b, aliceChannel, bobChannel, 200)
 
-	resetChannelState := func() {
-		aliceChannel.status = channelOpen
-		bobChannel.status = channelOpen
+	benchmarks := []struct {
+		name string
+		opts []ForceCloseOpt
+	}{
+		{
+			name: "with resolutions",
+		},
+		{
+			name: "skip resolutions",
+			opts: []ForceCloseOpt{WithSkipContractResolutions()},
+		},
 	}
 
-	setBalances := func(aliceBalance, bobBalance lnwire.MilliSatoshi) {
-		aliceChannel.channelState.LocalCommitment.LocalBalance = aliceBalance
-		aliceChannel.channelState.LocalCommitment.RemoteBalance = bobBalance
-		bobChannel.channelState.LocalCommitment.LocalBalance = bobBalance
-		bobChannel.channelState.LocalCommitment.RemoteBalance = aliceBalance
-	}
+	for _, bm := range benchmarks {
+		bm := bm
 
-	aliceDeliveryScript := bobsPrivKey[:]
-	bobDeliveryScript := testHdSeed[:]
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
 
-	// We'll start be initializing the limit of both Alice and Bob to 10k
-	// satoshis.
-	dustLimit := btcutil.Amount(10000)
-	setDustLimit(dustLimit)
+			for i := 0; i < b.N; i++ {
+				_, err := aliceChannel.ForceClose(bm.opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
 
-	// Both sides currently have over 1 BTC settled as part of their
-	// balances. As a result, performing a cooperative closure now result
-	// in both sides having an output within the closure transaction.
-	aliceFee := btcutil.Amount(aliceChannel.CalcFee(aliceFeeRate)) + 1000
-	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
-		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+// BenchmarkSignNextCommitment measures signing a new commitment on a channel
+// with 400 HTLCs, along with the share of computing the sighash midstate of
+// the commitment transaction.
+func BenchmarkSignNextCommitment(b *testing.B) {
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		b, channeldb.SingleFunderTweaklessBit,
 	)
-	require.NoError(t, err, "unable to close channel")
+	require.NoError(b, err, "unable to create test channels")
 
-	bobFee := btcutil.Amount(bobChannel.CalcFee(bobFeeRate)) + 1000
-	bobSig, _, _, err := bobChannel.CreateCloseProposal(
-		bobFee, bobDeliveryScript, aliceDeliveryScript,
-	)
-	require.NoError(t, err, "unable to close channel")
+	aliceChannel, _ = lockInTestHtlcs(b, aliceChannel, bobChannel, 200)
 
-	closeTx, _, err := bobChannel.CompleteCooperativeClose(
-		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
-		bobFee,
-	)
-	require.NoError(t, err, "unable to accept channel close")
+	b.Run("sign", func(b *testing.B) {
+		b.ReportAllocs()
 
-	// The closure transaction should have exactly two outputs.
-	if len(closeTx.TxOut) != 2 {
-		t.Fatalf("close tx has wrong number of outputs: expected %v "+
-			"got %v", 2, len(closeTx.TxOut))
-	}
+		for i := 0; i < b.N; i++ {
+			_, err := aliceChannel.SignNextCommitment()
+			if err != nil {
+				b.Fatal(err)
+			}
 
-	// We'll reset the channel states before proceeding to our next test.
-	resetChannelState()
+			// Discard the commitment so the revocation window
+			// allows signing the next one.
+			b.StopTimer()
+			err = aliceChannel.DiscardPendingRemoteCommit()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+		}
+	})
 
-	// Next we'll modify the current balances and dust limits such that
-	// Bob's current balance is _below_ his dust limit.
-	aliceBal := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
-	bobBal := lnwire.NewMSatFromSatoshis(250)
-	setBalances(aliceBal, bobBal)
+	b.Run("commit sighashes", func(b *testing.B) {
+		commitTx := aliceChannel.remoteCommitChain.tail().txn
 
-	// Attempt another cooperative channel closure. It should succeed
-	// without any issues.
-	aliceSig, _, _, err = aliceChannel.CreateCloseProposal(
-		aliceFee, aliceDeliveryScript, bobDeliveryScript,
-	)
-	require.NoError(t, err, "unable to close channel")
+		b.ReportAllocs()
 
-	bobSig, _, _, err = bobChannel.CreateCloseProposal(
-		bobFee, bobDeliveryScript, aliceDeliveryScript,
-	)
-	require.NoError(t, err, "unable to close channel")
+		for i := 0; i < b.N; i++ {
+			_ = input.NewTxSigHashesV0Only(commitTx)
+		}
+	})
+}
 
-	closeTx, _, err = bobChannel.CompleteCooperativeClose(
-		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
-		bobFee,
+// BenchmarkRestoreChannel measures restoring a channel at a high commitment
+// height, along with deriving the commitment points that are handed out to
+// the remote party once the channel is reestablished.
+func BenchmarkRestoreChannel(b *testing.B) {
+	aliceChannel, _, err := CreateTestChannels(
+		b, channeldb.SingleFunderTweaklessBit,
 	)
-	require.NoError(t, err, "unable to accept channel close")
+	require.NoError(b, err, "unable to create test channels")
 
-	// The closure transaction should only have a single output, and that
-	// output should be Alice's balance.
-	if len(closeTx.TxOut) != 1 {
-		t.Fatalf("close tx has wrong number of outputs: expected %v "+
-			"got %v", 1, len(closeTx.TxOut))
-	}
-	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
-	aliceExpectedBalance := aliceBal.ToSatoshis() - aliceFee + commitFee
-	if closeTx.TxOut[0].Value != int64(aliceExpectedBalance) {
-		t.Fatalf("alice's balance is incorrect: expected %v, got %v",
-			aliceExpectedBalance,
-			int64(closeTx.TxOut[0].Value))
-	}
+	const commitHeight = 100_000
+	chanState := aliceChannel.channelState
+	chanState.LocalCommitment.CommitHeight = commitHeight
+	chanState.RemoteCommitment.CommitHeight = commitHeight
 
-	// We'll modify the current balances and dust limits such that
-	// Alice's current balance is too low to pay the proposed fee.
-	setBalances(bobBal, aliceBal)
-	resetChannelState()
+	b.ReportAllocs()
+	b.ResetTimer()
 
-	// Attempting to close with this fee now should fail, since Alice
-	// cannot afford it.
-	_, _, _, err = aliceChannel.CreateCloseProposal(
-		aliceFee, aliceDeliveryScript, bobDeliveryScript,
-	)
-	if err == nil {
-		t.Fatalf("expected error")
+	for i := 0; i < b.N; i++ {
+		channel, err := NewLightningChannel(
+			aliceChannel.Signer, chanState, aliceChannel.sigPool,
+		)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := channel.NextRevocationKey(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := channel.NextLocalCommitTxid(); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := channel.LocalCommitPoints(1); err != nil {
+			b.Fatal(err)
+		}
 	}
+}
 
-	// Finally, we'll modify the current balances and dust limits such that
-	// Alice's balance after paying the coop fee is _below_ her dust limit.
-	lowBalance := lnwire.NewMSatFromSatoshis(aliceFee) + 1000
-	setBalances(lowBalance, aliceBal)
-	resetChannelState()
+// TestSignedCommitTxWithInfo asserts that the output info returned along with
+// our signed commitment points to the matching outputs of the transaction, and
+// that the channel remains usable afterwards.
+func TestSignedCommitTxWithInfo(t *testing.T) {
+	t.Run("tweakless", func(t *testing.T) {
+		testSignedCommitTxWithInfo(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("anchors", func(t *testing.T) {
+		testSignedCommitTxWithInfo(
+			t, channeldb.SingleFunderTweaklessBit|
+				channeldb.AnchorOutputsBit,
+		)
+	})
+}
 
-	// Our final attempt at another cooperative channel closure. It should
-	// succeed without any issues.
-	aliceSig, _, _, err = aliceChannel.CreateCloseProposal(
-		aliceFee, aliceDeliveryScript, bobDeliveryScript,
-	)
-	require.NoError(t, err, "unable to close channel")
+func testSignedCommitTxWithInfo(t *testing.T,
+	chanType channeldb.ChannelType) {
 
-	bobSig, _, _, err = bobChannel.CreateCloseProposal(
-		bobFee, bobDeliveryScript, aliceDeliveryScript,
-	)
-	require.NoError(t, err, "unable to close channel")
+	t.Parallel()
 
-	closeTx, _, err = bobChannel.CompleteCooperativeClose(
-		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
-		bobFee,
-	)
-	require.NoError(t, err, "unable to accept channel close")
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
 
-	// The closure transaction should only have a single output, and that
-	// output should be Bob's balance.
-	if len(closeTx.TxOut) != 1 {
-		t.Fatalf("close tx has wrong number of outputs: expected %v "+
-			"got %v", 1, len(closeTx.TxOut))
-	}
-	if closeTx.TxOut[0].Value != int64(aliceBal.ToSatoshis()) {
-		t.Fatalf("bob's balance is incorrect: expected %v, got %v",
-			aliceBal.ToSatoshis(), closeTx.TxOut[0].Value)
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "alice unable to add htlc")
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "bob unable to recv htlc")
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	commitTx, info, err := aliceChannel.SignedCommitTxWithInfo()
+	require.NoError(t, err)
+	require.Equal(t, commitTx.TxHash(), info.Txid)
+	require.NotEmpty(t, commitTx.TxIn[0].Witness)
+
+	// Our to_local output should be found at the returned index.
+	require.NotNil(t, info.ToSelf)
+	require.Equal(t, info.Txid, info.ToSelf.SelfOutPoint.Hash)
+	toSelfOut := commitTx.TxOut[info.ToSelf.SelfOutPoint.Index]
+	require.Equal(t, info.ToSelf.SelfOutputSignDesc.Output, toSelfOut)
+
+	// The same goes for our anchor, if the channel has anchors.
+	if chanType.HasAnchors() {
+		require.NotNil(t, info.Anchor)
+		require.Equal(t, info.Txid, info.Anchor.CommitAnchor.Hash)
+		anchorOut := commitTx.TxOut[info.Anchor.CommitAnchor.Index]
+		require.Equal(
+			t, info.Anchor.AnchorSignDescriptor.Output, anchorOut,
+		)
+		require.EqualValues(t, anchorSize, anchorOut.Value)
+	} else {
+		require.Nil(t, info.Anchor)
 	}
+
+	// Retrieving the signed commitment doesn't put the channel into
+	// dispute, so it can still be updated.
+	htlc, _ = createHTLC(1, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "alice unable to add htlc")
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "bob unable to recv htlc")
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 }
 
-// TestUpdateFeeAdjustments tests that the state machine is able to properly
-// accept valid fee changes, as well as reject any invalid fee updates.
-func TestUpdateFeeAdjustments(t *testing.T) {
+// TestForceCloseDustOutput tests that if either side force closes with an
+// active dust output (for only a single party due to asymmetric dust values),
+// then the force close summary is well crafted.
+func TestForceCloseDustOutput(t *testing.T) {
 	t.Parallel()
 
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll grab the current base fee rate as we'll be using this
-	// to make relative adjustments int he fee rate.
-	baseFeeRate := aliceChannel.channelState.LocalCommitment.FeePerKw
-
-	// We'll first try to increase the fee rate 5x, this should be able to
-	// be committed without any issue.
-	newFee := chainfee.SatPerKWeight(baseFeeRate * 5)
+	// We set both node's channel reserves to 0, to make sure
+	// they can create small dust outputs without going under
+	// their channel reserves.
+	aliceChannel.channelState.LocalChanCfg.ChanReserve = 0
+	bobChannel.channelState.LocalChanCfg.ChanReserve = 0
+	aliceChannel.channelState.RemoteChanCfg.ChanReserve = 0
+	bobChannel.channelState.RemoteChanCfg.ChanReserve = 0
 
-	if err := aliceChannel.UpdateFee(newFee); err != nil {
-		t.Fatalf("unable to alice update fee: %v", err)
-	}
-	if err := bobChannel.ReceiveUpdateFee(newFee); err != nil {
-		t.Fatalf("unable to bob update fee: %v", err)
-	}
+	htlcAmount := lnwire.NewMSatFromSatoshis(500)
 
-	// With the fee updates applied, we'll now initiate a state transition
-	// to ensure the fee update is locked in.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to create new commitment: %v", err)
-	}
+	aliceAmount := aliceChannel.channelState.LocalCommitment.LocalBalance
+	bobAmount := bobChannel.channelState.LocalCommitment.LocalBalance
 
-	// We'll now attempt to increase the fee rate 1,000,000x of the base
-	// fee.  This should result in an error as Alice won't be able to pay
-	// this new fee rate.
-	newFee = chainfee.SatPerKWeight(baseFeeRate * 1000000)
-	if err := aliceChannel.UpdateFee(newFee); err == nil {
-		t.Fatalf("alice should reject the fee rate")
+	// Have Bobs' to-self output be below her dust limit and check
+	// ForceCloseSummary again on both peers.
+	htlc, preimage := createHTLC(0, bobAmount-htlcAmount)
+	bobHtlcIndex, err := bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "alice unable to add htlc")
+	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "bob unable to receive htlc")
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
 	}
 
-	// Finally, we'll attempt to adjust the fee down and use a fee which is
-	// smaller than the initial base fee rate. The fee application and
-	// state transition should proceed without issue.
-	newFee = chainfee.SatPerKWeight(baseFeeRate / 10)
-	if err := aliceChannel.UpdateFee(newFee); err != nil {
-		t.Fatalf("unable to alice update fee: %v", err)
-	}
-	if err := bobChannel.ReceiveUpdateFee(newFee); err != nil {
-		t.Fatalf("unable to bob update fee: %v", err)
+	// Settle HTLC and sign new commitment.
+	err = aliceChannel.SettleHTLC(preimage, aliceHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "bob unable to settle inbound htlc")
+	err = bobChannel.ReceiveHTLCSettle(preimage, bobHtlcIndex)
+	if err != nil {
+		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
 	}
 	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to create new commitment: %v", err)
+		t.Fatalf("Can't update the channel state: %v", err)
 	}
-}
 
-// TestUpdateFeeFail tests that the signature verification will fail if they
-// fee updates are out of sync.
-func TestUpdateFeeFail(t *testing.T) {
-	t.Parallel()
+	aliceAmount = aliceChannel.channelState.LocalCommitment.LocalBalance
+	bobAmount = bobChannel.channelState.LocalCommitment.RemoteBalance
 
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	closeSummary, err := aliceChannel.ForceClose()
+	require.NoError(t, err, "unable to force close channel")
 
-	// Bob receives the update, that will apply to his commitment
+	// Alice's to-self output should still be in the commitment
 	// transaction.
-	if err := bobChannel.ReceiveUpdateFee(333); err != nil {
-		t.Fatalf("unable to apply fee update: %v", err)
+	commitResolution := closeSummary.CommitResolution
+	if commitResolution == nil {
+		t.Fatalf("alice fails to include to-self output in " +
+			"ForceCloseSummary")
+	}
+	if !commitResolution.SelfOutputSignDesc.KeyDesc.PubKey.IsEqual(
+		aliceChannel.channelState.LocalChanCfg.DelayBasePoint.PubKey,
+	) {
+		t.Fatalf("alice incorrect pubkey in SelfOutputSignDesc")
+	}
+	if commitResolution.SelfOutputSignDesc.Output.Value !=
+		int64(aliceAmount.ToSatoshis()) {
+		t.Fatalf("alice incorrect output value in SelfOutputSignDesc, "+
+			"expected %v, got %v",
+			aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis(),
+			commitResolution.SelfOutputSignDesc.Output.Value)
 	}
 
-	// Alice sends signature for commitment that does not cover any fee
-	// update.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
+	if commitResolution.MaturityDelay !=
+		uint32(aliceChannel.channelState.LocalChanCfg.CsvDelay) {
+		t.Fatalf("alice: incorrect local CSV delay in ForceCloseSummary, "+
+			"expected %v, got %v",
+			aliceChannel.channelState.LocalChanCfg.CsvDelay,
+			commitResolution.MaturityDelay)
+	}
 
-	// Bob verifies this commit, meaning that he checks that it is
-	// consistent everything he has received. This should fail, since he got
-	// the fee update, but Alice never sent it.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err == nil {
-		t.Fatalf("expected bob to fail receiving alice's signature")
+	closeTxHash := closeSummary.CloseTx.TxHash()
+	commitTxHash := aliceChannel.channelState.LocalCommitment.CommitTx.TxHash()
+	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
+		t.Fatalf("alice: incorrect close transaction txid")
+	}
+
+	closeSummary, err = bobChannel.ForceClose()
+	require.NoError(t, err, "unable to force close channel")
+
+	// Bob's to-self output is below Bob's dust value and should be
+	// reflected in the ForceCloseSummary.
+	commitResolution = closeSummary.CommitResolution
+	if commitResolution != nil {
+		t.Fatalf("bob incorrectly includes to-self output in " +
+			"ForceCloseSummary")
 	}
 
+	closeTxHash = closeSummary.CloseTx.TxHash()
+	commitTxHash = bobChannel.channelState.LocalCommitment.CommitTx.TxHash()
+	if !bytes.Equal(closeTxHash[:], commitTxHash[:]) {
+		t.Fatalf("bob: incorrect close transaction txid")
+	}
 }
 
-// TestUpdateFeeConcurrentSig tests that the channel can properly handle a fee
-// update that it receives concurrently with signing its next commitment.
-func TestUpdateFeeConcurrentSig(t *testing.T) {
+// TestSweepableLocalBalance asserts that the sweepable local balance matches
+// the value of the to-self output of the force close, minus the projected
+// sweep fee, and that it's zero if there's nothing worth sweeping.
+func TestSweepableLocalBalance(t *testing.T) {
 	t.Parallel()
 
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
-
-	paymentPreimage := bytes.Repeat([]byte{1}, 32)
-	paymentHash := sha256.Sum256(paymentPreimage)
-	htlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: paymentHash,
-		Amount:      btcutil.SatoshiPerBitcoin,
-		Expiry:      uint32(5),
+	testCases := []struct {
+		name         string
+		chanType     channeldb.ChannelType
+		witnessSize  int
+		pkScriptSize int
+	}{
+		{
+			name:         "tweakless",
+			chanType:     channeldb.SingleFunderTweaklessBit,
+			witnessSize:  input.ToLocalTimeoutWitnessSize,
+			pkScriptSize: input.P2WPKHSize,
+		},
+		{
+			name: "taproot",
+			chanType: channeldb.SimpleTaprootFeatureBit |
+				channeldb.AnchorOutputsBit |
+				channeldb.ZeroHtlcTxFeeBit |
+				channeldb.SingleFunderTweaklessBit,
+			witnessSize:  input.TaprootToLocalWitnessSize,
+			pkScriptSize: input.P2TRSize,
+		},
 	}
 
-	// First Alice adds the outgoing HTLC to her local channel's state
-	// update log. Then Alice sends this wire message over to Bob who
-	// adds this htlc to his remote state update log.
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
-	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
 
-	// Simulate Alice sending update fee message to bob.
-	fee := chainfee.SatPerKWeight(333)
-	if err := aliceChannel.UpdateFee(fee); err != nil {
-		t.Fatalf("unable to send fee update")
+			testSweepableLocalBalance(
+				t, testCase.chanType, testCase.witnessSize,
+				testCase.pkScriptSize,
+			)
+		})
 	}
+}
 
-	// Alice signs a commitment, and sends this to bob.
-	aliceNewCommits, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
+func testSweepableLocalBalance(t *testing.T, chanType channeldb.ChannelType,
+	witnessSize, pkScriptSize int) {
 
-	// At the same time, Bob signs a commitment.
-	bobNewCommits, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign alice's commitment")
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
 
-	// ...that Alice receives.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommits.CommitSigs)
-	require.NoError(t, err, "alice unable to process bob's new commitment")
+	// Send a payment from Alice to Bob, such that both parties have a
+	// fully signed commitment reflecting the new balances.
+	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	htlc, preimage := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Now let Bob receive the fee update + commitment that Alice sent.
-	if err := bobChannel.ReceiveUpdateFee(fee); err != nil {
-		t.Fatalf("unable to receive fee update")
-	}
+	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveHTLCSettle(preimage, 0)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
 
-	// Bob receives this signature message, and verifies that it is
-	// consistent with the state he had for Alice, including the received
-	// HTLC and fee update.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's new commitment")
+	const sweepFeePerKw = btcutil.Amount(2500)
 
-	if chainfee.SatPerKWeight(bobChannel.channelState.LocalCommitment.FeePerKw) == fee {
-		t.Fatalf("bob's feePerKw was unexpectedly locked in")
-	}
+	var weightEstimate input.TxWeightEstimator
+	weightEstimate.AddWitnessInput(witnessSize)
+	weightEstimate.AddTxOutput(&wire.TxOut{
+		PkScript: make([]byte, pkScriptSize),
+	})
+	sweepFee := chainfee.SatPerKWeight(sweepFeePerKw).FeeForWeight(
+		int64(weightEstimate.Weight()),
+	)
 
-	// Bob can revoke the prior commitment he had. This should lock in the
-	// fee update for him.
-	_, _, _, err = bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to generate bob revocation")
+	// The sweepable balance should be the value of the to-self output
+	// created by a force close, minus the fee to sweep it.
+	sweepable := aliceChannel.SweepableLocalBalance(sweepFeePerKw)
 
-	if chainfee.SatPerKWeight(bobChannel.channelState.LocalCommitment.FeePerKw) != fee {
-		t.Fatalf("bob's feePerKw was not locked in")
-	}
+	closeSummary, err := aliceChannel.ForceClose()
+	require.NoError(t, err, "unable to force close channel")
+	require.NotNil(t, closeSummary.CommitResolution)
+
+	selfOutput := closeSummary.CommitResolution.SelfOutputSignDesc.Output
+	require.Equal(t, btcutil.Amount(selfOutput.Value)-sweepFee, sweepable)
+
+	// A fee rate so high that the remainder is dust should result in
+	// nothing being sweepable.
+	require.Zero(t, aliceChannel.SweepableLocalBalance(
+		btcutil.Amount(selfOutput.Value)*1000,
+	))
+
+	// Finally, if the to-self output is trimmed, then there's nothing to
+	// sweep either.
+	dustLimit := aliceChannel.channelState.LocalChanCfg.DustLimit
+	aliceChannel.channelState.LocalCommitment.LocalBalance =
+		lnwire.NewMSatFromSatoshis(dustLimit - 1)
+	require.Zero(t, aliceChannel.SweepableLocalBalance(0))
 }
 
-// TestUpdateFeeSenderCommits verifies that the state machine progresses as
-// expected if we send a fee update, and then the sender of the fee update
-// sends a commitment signature.
-func TestUpdateFeeSenderCommits(t *testing.T) {
+// TestDustHTLCFees checks that fees are calculated correctly when HTLCs fall
+// below the nodes' dust limit. In these cases, the amount of the dust HTLCs
+// should be applied to the commitment transaction fee.
+func TestDustHTLCFees(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
@@ -2451,105 +2777,72 @@ func TestUpdateFeeSenderCommits(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	paymentPreimage := bytes.Repeat([]byte{1}, 32)
-	paymentHash := sha256.Sum256(paymentPreimage)
-	htlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: paymentHash,
-		Amount:      btcutil.SatoshiPerBitcoin,
-		Expiry:      uint32(5),
-	}
+	aliceStartingBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
 
-	// First Alice adds the outgoing HTLC to her local channel's state
-	// update log. Then Alice sends this wire message over to Bob who
-	// adds this htlc to his remote state update log.
+	// This HTLC amount should be lower than the dust limits of both nodes.
+	htlcAmount := lnwire.NewMSatFromSatoshis(100)
+	htlc, _ := createHTLC(0, htlcAmount)
 	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+		t.Fatalf("alice unable to add htlc: %v", err)
 	}
 	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+		t.Fatalf("bob unable to receive htlc: %v", err)
 	}
-
-	// Simulate Alice sending update fee message to bob.
-	fee := chainfee.SatPerKWeight(333)
-	aliceChannel.UpdateFee(fee)
-	bobChannel.ReceiveUpdateFee(fee)
-
-	// Alice signs a commitment, which will cover everything sent to Bob
-	// (the HTLC and the fee update), and everything acked by Bob (nothing
-	// so far).
-	aliceNewCommits, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
-
-	// Bob receives this signature message, and verifies that it is
-	// consistent with the state he had for Alice, including the received
-	// HTLC and fee update.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's new commitment")
-
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) == fee {
-
-		t.Fatalf("bob's feePerKw was unexpectedly locked in")
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
 	}
 
-	// Bob can revoke the prior commitment he had. This should lock in the
-	// fee update for him.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to generate bob revocation")
+	// After the transition, we'll ensure that we performed fee accounting
+	// properly. Namely, the local+remote+commitfee values should add up to
+	// the total capacity of the channel. This same should hold for both
+	// sides.
+	totalSatoshisAlice := (aliceChannel.channelState.LocalCommitment.LocalBalance +
+		aliceChannel.channelState.LocalCommitment.RemoteBalance +
+		lnwire.NewMSatFromSatoshis(aliceChannel.channelState.LocalCommitment.CommitFee))
+	if totalSatoshisAlice+htlcAmount != lnwire.NewMSatFromSatoshis(aliceChannel.Capacity) {
+		t.Fatalf("alice's funds leaked: total satoshis are %v, but channel "+
+			"capacity is %v", int64(totalSatoshisAlice),
+			int64(aliceChannel.Capacity))
+	}
+	totalSatoshisBob := (bobChannel.channelState.LocalCommitment.LocalBalance +
+		bobChannel.channelState.LocalCommitment.RemoteBalance +
+		lnwire.NewMSatFromSatoshis(bobChannel.channelState.LocalCommitment.CommitFee))
+	if totalSatoshisBob+htlcAmount != lnwire.NewMSatFromSatoshis(bobChannel.Capacity) {
+		t.Fatalf("bob's funds leaked: total satoshis are %v, but channel "+
+			"capacity is %v", int64(totalSatoshisBob),
+			int64(bobChannel.Capacity))
+	}
 
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) != fee {
+	// The commitment fee paid should be the same, as there have been no
+	// new material outputs added.
+	defaultFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 0)
+	if aliceChannel.channelState.LocalCommitment.CommitFee != defaultFee {
+		t.Fatalf("dust htlc amounts not subtracted from commitment fee "+
+			"expected %v, got %v", defaultFee,
+			aliceChannel.channelState.LocalCommitment.CommitFee)
+	}
+	if bobChannel.channelState.LocalCommitment.CommitFee != defaultFee {
+		t.Fatalf("dust htlc amounts not subtracted from commitment fee "+
+			"expected %v, got %v", defaultFee,
+			bobChannel.channelState.LocalCommitment.CommitFee)
+	}
 
-		t.Fatalf("bob's feePerKw was not locked in")
+	// Alice's final balance should reflect the HTLC deficit even though
+	// the HTLC was paid to fees as it was trimmed.
+	aliceEndBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
+	aliceExpectedBalance := aliceStartingBalance - htlcAmount
+	if aliceEndBalance != aliceExpectedBalance {
+		t.Fatalf("alice not credited for dust: expected %v, got %v",
+			aliceExpectedBalance, aliceEndBalance)
 	}
+}
 
-	// Bob commits to all updates he has received from Alice. This includes
-	// the HTLC he received, and the fee update.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign alice's commitment")
-
-	// Alice receives the revocation of the old one, and can now assume
-	// that Bob's received everything up to the signature she sent,
-	// including the HTLC and fee update.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to process bob's revocation")
-
-	// Alice receives new signature from Bob, and assumes this covers the
-	// changes.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to process bob's new commitment")
-
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) == fee {
-
-		t.Fatalf("alice's feePerKw was unexpectedly locked in")
-	}
-
-	// Alice can revoke the old commitment, which will lock in the fee
-	// update.
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke alice channel")
-
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) != fee {
-
-		t.Fatalf("alice's feePerKw was not locked in")
-	}
-
-	// Bob receives revocation from Alice.
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to process alice's revocation")
-
-}
-
-// TestUpdateFeeReceiverCommits tests that the state machine progresses as
-// expected if we send a fee update, and then the receiver of the fee update
-// sends a commitment signature.
-func TestUpdateFeeReceiverCommits(t *testing.T) {
+// TestHTLCDustLimit checks the situation in which an HTLC is larger than one
+// channel participant's dust limit, but smaller than the other participant's
+// dust limit. In this case, the participants' commitment chains will diverge.
+// In one commitment chain, the HTLC will be added as normal, in the other
+// chain, the amount of the HTLC will contribute to the fees to be paid.
+func TestHTLCDustLimit(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
@@ -2560,124 +2853,231 @@ func TestUpdateFeeReceiverCommits(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	paymentPreimage := bytes.Repeat([]byte{1}, 32)
-	paymentHash := sha256.Sum256(paymentPreimage)
-	htlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: paymentHash,
-		Amount:      btcutil.SatoshiPerBitcoin,
-		Expiry:      uint32(5),
+	// The amount of the HTLC should be above Alice's dust limit and below
+	// Bob's dust limit.
+	htlcSat := (btcutil.Amount(500) + HtlcTimeoutFee(
+		aliceChannel.channelState.ChanType,
+		chainfee.SatPerKWeight(
+			aliceChannel.channelState.LocalCommitment.FeePerKw,
+		),
+	))
+	htlcAmount := lnwire.NewMSatFromSatoshis(htlcSat)
+
+	htlc, preimage := createHTLC(0, htlcAmount)
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "alice unable to add htlc")
+	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "bob unable to receive htlc")
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
 	}
 
-	// First Alice adds the outgoing HTLC to her local channel's state
-	// update log. Then Alice sends this wire message over to Bob who
-	// adds this htlc to his remote state update log.
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+	// At this point, Alice's commitment transaction should have an HTLC,
+	// while Bob's should not, because the value falls beneath his dust
+	// limit. The amount of the HTLC should be applied to fees in Bob's
+	// commitment transaction.
+	aliceCommitment := aliceChannel.localCommitChain.tip()
+	if len(aliceCommitment.txn.TxOut) != 3 {
+		t.Fatalf("incorrect # of outputs: expected %v, got %v",
+			3, len(aliceCommitment.txn.TxOut))
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+	bobCommitment := bobChannel.localCommitChain.tip()
+	if len(bobCommitment.txn.TxOut) != 2 {
+		t.Fatalf("incorrect # of outputs: expected %v, got %v",
+			2, len(bobCommitment.txn.TxOut))
+	}
+	defaultFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 0)
+	if bobChannel.channelState.LocalCommitment.CommitFee != defaultFee {
+		t.Fatalf("dust htlc amount was subtracted from commitment fee "+
+			"expected %v, got %v", defaultFee,
+			bobChannel.channelState.LocalCommitment.CommitFee)
 	}
 
-	// Simulate Alice sending update fee message to bob
-	fee := chainfee.SatPerKWeight(333)
-	aliceChannel.UpdateFee(fee)
-	bobChannel.ReceiveUpdateFee(fee)
+	// Settle HTLC and create a new commitment state.
+	err = bobChannel.SettleHTLC(preimage, bobHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "bob unable to settle inbound htlc")
+	err = aliceChannel.ReceiveHTLCSettle(preimage, aliceHtlcIndex)
+	if err != nil {
+		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
+	}
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("state transition error: %v", err)
+	}
 
-	// Bob commits to every change he has sent since last time (none). He
-	// does not commit to the received HTLC and fee update, since Alice
-	// cannot know if he has received them.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
+	// At this point, for Alice's commitment chains, the value of the HTLC
+	// should have been added to Alice's balance and TotalSatoshisSent.
+	commitment := aliceChannel.localCommitChain.tip()
+	if len(commitment.txn.TxOut) != 2 {
+		t.Fatalf("incorrect # of outputs: expected %v, got %v",
+			2, len(commitment.txn.TxOut))
+	}
+	if aliceChannel.channelState.TotalMSatSent != htlcAmount {
+		t.Fatalf("alice satoshis sent incorrect: expected %v, got %v",
+			htlcAmount, aliceChannel.channelState.TotalMSatSent)
+	}
+}
 
-	// Alice receives this signature message, and verifies that it is
-	// consistent with the remote state, not including any of the updates.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's new commitment")
+// TestHTLCSigNumber tests that a received commitment is only accepted if it
+// comes with the exact number of valid HTLC signatures.
+func TestHTLCSigNumber(t *testing.T) {
+	t.Parallel()
 
-	// Alice can revoke the prior commitment she had, this will ack
-	// everything received before last commitment signature, but in this
-	// case that is nothing.
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to generate bob revocation")
+	// createChanWithHTLC is a helper method that sets ut two channels, and
+	// adds HTLCs with the passed values to the channels.
+	createChanWithHTLC := func(htlcValues ...btcutil.Amount) (
+		*LightningChannel, *LightningChannel) {
 
-	// Bob receives the revocation of the old commitment
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "alice unable to process bob's revocation")
+		// Create a test channel funded evenly with Alice having 5 BTC,
+		// and Bob having 5 BTC. Alice's dustlimit is 200 sat, while
+		// Bob has 1300 sat.
+		aliceChannel, bobChannel, err := CreateTestChannels(
+			t, channeldb.SingleFunderTweaklessBit,
+		)
+		if err != nil {
+			t.Fatalf("unable to create test channels: %v", err)
+		}
 
-	// Alice will sign next commitment. Since she sent the revocation, she
-	// also ack'ed everything received, but in this case this is nothing.
-	// Since she sent the two updates, this signature will cover those two.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign alice's commitment")
+		for i, htlcSat := range htlcValues {
+			htlcMsat := lnwire.NewMSatFromSatoshis(htlcSat)
+			htlc, _ := createHTLC(i, htlcMsat)
+			_, err := aliceChannel.AddHTLC(htlc, nil)
+			if err != nil {
+				t.Fatalf("alice unable to add htlc: %v", err)
+			}
+			_, err = bobChannel.ReceiveHTLC(htlc)
+			if err != nil {
+				t.Fatalf("bob unable to receive htlc: %v", err)
+			}
+		}
 
-	// Bob gets the signature for the new commitment from Alice. He assumes
-	// this covers everything received from alice, including the two updates.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to process bob's new commitment")
+		return aliceChannel, bobChannel
+	}
 
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) == fee {
+	// Calculate two values that will be below and above Bob's dust limit.
+	estimator := chainfee.NewStaticEstimator(6000, 0)
+	feePerKw, err := estimator.EstimateFeePerKW(1)
+	require.NoError(t, err, "unable to get fee")
 
-		t.Fatalf("bob's feePerKw was unexpectedly locked in")
-	}
+	belowDust := btcutil.Amount(500) + HtlcTimeoutFee(
+		channeldb.SingleFunderTweaklessBit, feePerKw,
+	)
+	aboveDust := btcutil.Amount(1400) + HtlcSuccessFee(
+		channeldb.SingleFunderTweaklessBit, feePerKw,
+	)
 
-	// Bob can revoke the old commitment. This will ack what he has
-	// received, including the HTLC and fee update. This will lock in the
-	// fee update for bob.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke alice channel")
+	// ===================================================================
+	// Test that Bob will reject a commitment if Alice doesn't send enough
+	// HTLC signatures.
+	// ===================================================================
+	aliceChannel, bobChannel := createChanWithHTLC(aboveDust, aboveDust)
 
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) != fee {
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "Error signing next commitment")
 
-		t.Fatalf("bob's feePerKw was not locked in")
+	if len(aliceNewCommit.HtlcSigs) != 2 {
+		t.Fatalf("expected 2 htlc sig, instead got %v",
+			len(aliceNewCommit.HtlcSigs))
 	}
 
-	// Bob will send a new signature, which will cover what he just acked:
-	// the HTLC and fee update.
-	bobNewCommit, err = bobChannel.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
-
-	// Alice receives revocation from Bob, and can now be sure that Bob
-	// received the two updates, and they are considered locked in.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "bob unable to process alice's revocation")
+	// Now discard one signature from the htlcSig slice. Bob should reject
+	// the commitment because of this.
+	aliceNewCommitCopy := *aliceNewCommit
+	aliceNewCommitCopy.HtlcSigs = aliceNewCommitCopy.HtlcSigs[1:]
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommitCopy.CommitSigs)
+	require.ErrorIs(t, err, ErrHtlcSigCountMismatch)
 
-	// Alice will receive the signature from Bob, which will cover what was
-	// just acked by his revocation.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to process bob's new commitment")
+	// ===================================================================
+	// Test that Bob will reject a commitment if Alice doesn't send any
+	// HTLC signatures.
+	// ===================================================================
+	aliceChannel, bobChannel = createChanWithHTLC(aboveDust)
 
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) == fee {
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "Error signing next commitment")
 
-		t.Fatalf("alice's feePerKw was unexpectedly locked in")
+	if len(aliceNewCommit.HtlcSigs) != 1 {
+		t.Fatalf("expected 1 htlc sig, instead got %v",
+			len(aliceNewCommit.HtlcSigs))
 	}
 
-	// After Alice now revokes her old commitment, the fee update should
-	// lock in.
-	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to generate bob revocation")
+	// Now just give Bob an empty htlcSig slice. He should reject the
+	// commitment because of this.
+	aliceCommitCopy := *aliceNewCommit.CommitSigs
+	aliceCommitCopy.HtlcSigs = []lnwire.Sig{}
+	err = bobChannel.ReceiveNewCommitment(&aliceCommitCopy)
+	require.ErrorIs(t, err, ErrHtlcSigCountMismatch)
 
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) != fee {
+	// ==============================================================
+	// Test that sigs are not returned for HTLCs below dust limit.
+	// ==============================================================
+	aliceChannel, bobChannel = createChanWithHTLC(belowDust)
 
-		t.Fatalf("Alice's feePerKw was not locked in")
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "Error signing next commitment")
+
+	// Since the HTLC is below Bob's dust limit, Alice won't need to send
+	// any signatures for this HTLC.
+	if len(aliceNewCommit.HtlcSigs) != 0 {
+		t.Fatalf("expected no htlc sigs, instead got %v",
+			len(aliceNewCommit.HtlcSigs))
 	}
 
-	// Bob receives revocation from Alice.
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to process alice's revocation")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "Bob failed receiving commitment")
+
+	// ================================================================
+	// Test that sigs are correctly returned for HTLCs above dust limit.
+	// ================================================================
+	aliceChannel, bobChannel = createChanWithHTLC(aboveDust)
+
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "Error signing next commitment")
+
+	// Since the HTLC is above Bob's dust limit, Alice should send a
+	// signature for this HTLC.
+	if len(aliceNewCommit.HtlcSigs) != 1 {
+		t.Fatalf("expected 1 htlc sig, instead got %v",
+			len(aliceNewCommit.HtlcSigs))
+	}
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "Bob failed receiving commitment")
+
+	// ====================================================================
+	// Test that Bob will not validate a received commitment if Alice sends
+	// signatures for HTLCs below the dust limit.
+	// ====================================================================
+	aliceChannel, bobChannel = createChanWithHTLC(belowDust, aboveDust)
+
+	// Alice should produce only one signature, since one HTLC is below
+	// dust.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "Error signing next commitment")
+
+	if len(aliceNewCommit.HtlcSigs) != 1 {
+		t.Fatalf("expected 1 htlc sig, instead got %v",
+			len(aliceNewCommit.HtlcSigs))
+	}
+
+	// Add an extra signature.
+	aliceNewCommit.HtlcSigs = append(
+		aliceNewCommit.HtlcSigs, aliceNewCommit.HtlcSigs[0],
+	)
+
+	// Bob should reject these signatures since they don't match the number
+	// of HTLCs above dust.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.ErrorIs(t, err, ErrHtlcSigCountMismatch)
 }
 
-// TestUpdateFeeReceiverSendsUpdate tests that receiving a fee update as channel
-// initiator fails, and that trying to initiate fee update as non-initiation
-// fails.
-func TestUpdateFeeReceiverSendsUpdate(t *testing.T) {
+// TestChannelBalanceDustLimit tests the condition when the remaining balance
+// for one of the channel participants is so small as to be considered dust. In
+// this case, the output for that participant is removed and all funds (minus
+// fees) in the commitment transaction are allocated to the remaining channel
+// participant.
+//
+// TODO(roasbeef): test needs to be fixed after reserve limits are done
+func TestChannelBalanceDustLimit(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
@@ -2688,24 +3088,60 @@ func TestUpdateFeeReceiverSendsUpdate(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Since Alice is the channel initiator, she should fail when receiving
-	// fee update
-	fee := chainfee.SatPerKWeight(333)
-	err = aliceChannel.ReceiveUpdateFee(fee)
-	if err == nil {
-		t.Fatalf("expected alice to fail receiving fee update")
+	// To allow Alice's balance to get beneath her dust limit, set the
+	// channel reserve to be 0.
+	aliceChannel.channelState.LocalChanCfg.ChanReserve = 0
+	bobChannel.channelState.RemoteChanCfg.ChanReserve = 0
+
+	// This amount should leave an amount larger than Alice's dust limit
+	// once fees have been subtracted, but smaller than Bob's dust limit.
+	// We account in fees for the HTLC we will be adding.
+	defaultFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 1)
+	aliceBalance := aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis()
+	htlcSat := aliceBalance - defaultFee
+	htlcSat += HtlcSuccessFee(
+		aliceChannel.channelState.ChanType,
+		chainfee.SatPerKWeight(
+			aliceChannel.channelState.LocalCommitment.FeePerKw,
+		),
+	)
+
+	htlcAmount := lnwire.NewMSatFromSatoshis(htlcSat)
+
+	htlc, preimage := createHTLC(0, htlcAmount)
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "alice unable to add htlc")
+	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "bob unable to receive htlc")
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("state transition error: %v", err)
+	}
+	err = bobChannel.SettleHTLC(preimage, bobHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "bob unable to settle inbound htlc")
+	err = aliceChannel.ReceiveHTLCSettle(preimage, aliceHtlcIndex)
+	if err != nil {
+		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
+	}
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("state transition error: %v", err)
 	}
 
-	// Similarly, initiating fee update should fail for Bob.
-	err = bobChannel.UpdateFee(fee)
-	if err == nil {
-		t.Fatalf("expected bob to fail initiating fee update")
+	// At the conclusion of this test, in Bob's commitment chains, the
+	// output for Alice's balance should have been removed as dust, leaving
+	// only a single output that will send the remaining funds in the
+	// channel to Bob.
+	commitment := bobChannel.localCommitChain.tip()
+	if len(commitment.txn.TxOut) != 1 {
+		t.Fatalf("incorrect # of outputs: expected %v, got %v",
+			1, len(commitment.txn.TxOut))
+	}
+	if aliceChannel.channelState.TotalMSatSent != htlcAmount {
+		t.Fatalf("alice satoshis sent incorrect: expected %v, got %v",
+			htlcAmount, aliceChannel.channelState.TotalMSatSent)
 	}
 }
 
-// Test that if multiple update fee messages are sent consecutively, then the
-// last one is the one that is being committed to.
-func TestUpdateFeeMultipleUpdates(t *testing.T) {
+func TestStateUpdatePersistence(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
@@ -2716,249 +3152,133 @@ func TestUpdateFeeMultipleUpdates(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Simulate Alice sending update fee message to bob.
-	fee1 := chainfee.SatPerKWeight(333)
-	fee2 := chainfee.SatPerKWeight(333)
-	fee := chainfee.SatPerKWeight(333)
-	aliceChannel.UpdateFee(fee1)
-	aliceChannel.UpdateFee(fee2)
-	aliceChannel.UpdateFee(fee)
-
-	// Alice signs a commitment, which will cover everything sent to Bob
-	// (the HTLC and the fee update), and everything acked by Bob (nothing
-	// so far).
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
+	htlcAmt := lnwire.NewMSatFromSatoshis(5000)
 
-	bobChannel.ReceiveUpdateFee(fee1)
-	bobChannel.ReceiveUpdateFee(fee2)
-	bobChannel.ReceiveUpdateFee(fee)
+	var fakeOnionBlob [lnwire.OnionPacketSize]byte
+	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
 
-	// Bob receives this signature message, and verifies that it is
-	// consistent with the state he had for Alice, including the received
-	// HTLC and fee update.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's new commitment")
+	// Alice adds 3 HTLCs to the update log, while Bob adds a single HTLC.
+	var alicePreimage [32]byte
+	copy(alicePreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xbb}, 32))
+	for i := 0; i < 3; i++ {
+		rHash := sha256.Sum256(alicePreimage[:])
+		h := &lnwire.UpdateAddHTLC{
+			ID:          uint64(i),
+			PaymentHash: rHash,
+			Amount:      htlcAmt,
+			Expiry:      uint32(10),
+			OnionBlob:   fakeOnionBlob,
+		}
 
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) == fee {
+		if _, err := aliceChannel.AddHTLC(h, nil); err != nil {
+			t.Fatalf("unable to add alice's htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(h); err != nil {
+			t.Fatalf("unable to recv alice's htlc: %v", err)
+		}
+	}
+	rHash := sha256.Sum256(bobPreimage[:])
+	bobh := &lnwire.UpdateAddHTLC{
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
+		OnionBlob:   fakeOnionBlob,
+	}
+	if _, err := bobChannel.AddHTLC(bobh, nil); err != nil {
+		t.Fatalf("unable to add bob's htlc: %v", err)
+	}
+	if _, err := aliceChannel.ReceiveHTLC(bobh); err != nil {
+		t.Fatalf("unable to recv bob's htlc: %v", err)
+	}
 
-		t.Fatalf("bob's feePerKw was unexpectedly locked in")
+	// Also add a fee update to the update logs.
+	fee := chainfee.SatPerKWeight(333)
+	if err := aliceChannel.UpdateFee(fee); err != nil {
+		t.Fatalf("unable to send fee update")
+	}
+	if err := bobChannel.ReceiveUpdateFee(fee); err != nil {
+		t.Fatalf("unable to receive fee update")
 	}
 
-	// Alice sending more fee updates now should not mess up the old fee
-	// they both committed to.
-	fee3 := chainfee.SatPerKWeight(444)
-	fee4 := chainfee.SatPerKWeight(555)
-	fee5 := chainfee.SatPerKWeight(666)
-	aliceChannel.UpdateFee(fee3)
-	aliceChannel.UpdateFee(fee4)
-	aliceChannel.UpdateFee(fee5)
-	bobChannel.ReceiveUpdateFee(fee3)
-	bobChannel.ReceiveUpdateFee(fee4)
-	bobChannel.ReceiveUpdateFee(fee5)
+	// Helper method that asserts the expected number of updates are found
+	// in the update logs.
+	assertNumLogUpdates := func(numAliceUpdates, numBobUpdates int) {
+		if aliceChannel.localUpdateLog.Len() != numAliceUpdates {
+			t.Fatalf("expected %d local updates, found %d",
+				numAliceUpdates,
+				aliceChannel.localUpdateLog.Len())
+		}
+		if aliceChannel.remoteUpdateLog.Len() != numBobUpdates {
+			t.Fatalf("expected %d remote updates, found %d",
+				numBobUpdates,
+				aliceChannel.remoteUpdateLog.Len())
+		}
 
-	// Bob can revoke the prior commitment he had. This should lock in the
-	// fee update for him.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to generate bob revocation")
+		if bobChannel.localUpdateLog.Len() != numBobUpdates {
+			t.Fatalf("expected %d local updates, found %d",
+				numBobUpdates,
+				bobChannel.localUpdateLog.Len())
+		}
+		if bobChannel.remoteUpdateLog.Len() != numAliceUpdates {
+			t.Fatalf("expected %d remote updates, found %d",
+				numAliceUpdates,
+				bobChannel.remoteUpdateLog.Len())
+		}
+	}
 
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) != fee {
+	// Both nodes should now have Alice's 3 Adds and 1 FeeUpdate in the
+	// log, and Bob's 1 Add.
+	assertNumLogUpdates(4, 1)
 
-		t.Fatalf("bob's feePerKw was not locked in")
+	// Next, Alice initiates a state transition to include the HTLC's she
+	// added above in a new commitment state.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete alice's state transition: %v", err)
 	}
 
-	// Bob commits to all updates he has received from Alice. This includes
-	// the HTLC he received, and the fee update.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign alice's commitment")
-
-	// Alice receives the revocation of the old one, and can now assume that
-	// Bob's received everything up to the signature she sent, including the
-	// HTLC and fee update.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to process bob's revocation")
-
-	// Alice receives new signature from Bob, and assumes this covers the
-	// changes.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatalf("alice unable to process bob's new commitment: %v", err)
-	}
-
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) == fee {
-
-		t.Fatalf("alice's feePerKw was unexpectedly locked in")
-	}
-
-	// Alice can revoke the old commitment, which will lock in the fee
-	// update.
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke alice channel")
-
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) != fee {
-
-		t.Fatalf("alice's feePerKw was not locked in")
-	}
-
-	// Bob receives revocation from Alice.
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to process alice's revocation")
-}
-
-// TestAddHTLCNegativeBalance tests that if enough HTLC's are added to the
-// state machine to drive the balance to zero, then the next HTLC attempted to
-// be added will result in an error being returned.
-func TestAddHTLCNegativeBalance(t *testing.T) {
-	t.Parallel()
-
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
-	aliceChannel, _, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
-
-	// We set the channel reserve to 0, such that we can add HTLCs all the
-	// way to a negative balance.
-	aliceChannel.channelState.LocalChanCfg.ChanReserve = 0
-
-	// First, we'll add 3 HTLCs of 1 BTC each to Alice's commitment.
-	const numHTLCs = 3
-	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
-	for i := 0; i < numHTLCs; i++ {
-		htlc, _ := createHTLC(i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-	}
-
-	// Alice now has an available balance of 2 BTC. We'll add a new HTLC of
-	// value 2 BTC, which should make Alice's balance negative (since she
-	// has to pay a commitment fee).
-	htlcAmt = lnwire.NewMSatFromSatoshis(2 * btcutil.SatoshiPerBitcoin)
-	htlc, _ := createHTLC(numHTLCs+1, htlcAmt)
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
-}
-
-// assertNoChanSyncNeeded is a helper function that asserts that upon restart,
-// two channels conclude that they're fully synchronized and don't need to
-// retransmit any new messages.
-func assertNoChanSyncNeeded(t *testing.T, aliceChannel *LightningChannel,
-	bobChannel *LightningChannel) {
-
-	_, _, line, _ := runtime.Caller(1)
-
-	aliceChanSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
-	if err != nil {
-		t.Fatalf("line #%v: unable to produce chan sync msg: %v",
-			line, err)
-	}
-	bobChanSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	if err != nil {
-		t.Fatalf("line #%v: unable to produce chan sync msg: %v",
-			line, err)
-	}
-
-	// For taproot channels, simulate the link/peer binding the generated
-	// nonces.
-	if aliceChannel.channelState.ChanType.IsTaproot() {
-		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *aliceChanSyncMsg.LocalNonce,
-		}
-		bobChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *bobChanSyncMsg.LocalNonce,
-		}
+	// Since the HTLC Bob sent wasn't included in Bob's version of the
+	// commitment transaction (but it was in Alice's, as he ACK'd her
+	// changes before creating a new state), Bob needs to trigger another
+	// state update in order to re-sync their states.
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceChanSyncMsg)
-	if err != nil {
-		t.Fatalf("line #%v: unable to process ChannelReestablish "+
-			"msg: %v", line, err)
+	// After the state transition the fee update is fully locked in, and
+	// should've been removed from both channels' update logs.
+	if aliceChannel.localCommitChain.tail().feePerKw != fee {
+		t.Fatalf("fee not locked in")
 	}
-	if len(bobMsgsToSend) != 0 {
-		t.Fatalf("line #%v: bob shouldn't have to send any messages, "+
-			"instead wants to send: %v", line, spew.Sdump(bobMsgsToSend))
+	if bobChannel.localCommitChain.tail().feePerKw != fee {
+		t.Fatalf("fee not locked in")
 	}
+	assertNumLogUpdates(3, 1)
 
-	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(bobChanSyncMsg)
-	if err != nil {
-		t.Fatalf("line #%v: unable to process ChannelReestablish "+
-			"msg: %v", line, err)
+	// The latest commitment from both sides should have all the HTLCs.
+	numAliceOutgoing := aliceChannel.localCommitChain.tail().outgoingHTLCs
+	numAliceIncoming := aliceChannel.localCommitChain.tail().incomingHTLCs
+	if len(numAliceOutgoing) != 3 {
+		t.Fatalf("expected %v htlcs, instead got %v", 3, numAliceOutgoing)
 	}
-	if len(bobMsgsToSend) != 0 {
-		t.Fatalf("line #%v: alice shouldn't have to send any "+
-			"messages, instead wants to send: %v", line,
-			spew.Sdump(aliceMsgsToSend))
+	if len(numAliceIncoming) != 1 {
+		t.Fatalf("expected %v htlcs, instead got %v", 1, numAliceIncoming)
 	}
-}
-
-// TestChanSyncFullySynced tests that after a successful commitment exchange,
-// and a forced restart, both nodes conclude that they're fully synchronized
-// and don't need to retransmit any messages.
-func TestChanSyncFullySynced(t *testing.T) {
-	t.Parallel()
-
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
-
-	// If we exchange channel sync messages from the get-go , then both
-	// sides should conclude that no further synchronization is needed.
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
-
-	// Next, we'll create an HTLC for Alice to extend to Bob.
-	var paymentPreimage [32]byte
-	copy(paymentPreimage[:], bytes.Repeat([]byte{1}, 32))
-	paymentHash := sha256.Sum256(paymentPreimage[:])
-	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
-	htlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: paymentHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(5),
+	numBobOutgoing := bobChannel.localCommitChain.tail().outgoingHTLCs
+	numBobIncoming := bobChannel.localCommitChain.tail().incomingHTLCs
+	if len(numBobOutgoing) != 1 {
+		t.Fatalf("expected %v htlcs, instead got %v", 1, numBobOutgoing)
 	}
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "unable to add htlc")
-	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "unable to recv htlc")
-
-	// Then we'll initiate a state transition to lock in this new HTLC.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete alice's state transition: %v", err)
+	if len(numBobIncoming) != 3 {
+		t.Fatalf("expected %v htlcs, instead got %v", 3, numBobIncoming)
 	}
 
-	// At this point, if both sides generate a ChannelReestablish message,
-	// they should both conclude that they're fully in sync.
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
-
-	// If bob settles the HTLC, and then initiates a state transition, they
-	// should both still think that they're in sync.
-	err = bobChannel.SettleHTLC(paymentPreimage, bobHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "unable to settle htlc")
-	err = aliceChannel.ReceiveHTLCSettle(paymentPreimage, aliceHtlcIndex)
-	require.NoError(t, err, "unable to settle htlc")
-
-	// Next, we'll complete Bob's state transition, and assert again that
-	// they think they're fully synced.
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
-	}
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
+	// TODO(roasbeef): also ensure signatures were stored
+	//  * ensure expiry matches
 
-	// Finally, if we simulate a restart on both sides, then both should
-	// still conclude that they don't need to synchronize their state.
+	// Now fetch both of the channels created above from disk to simulate a
+	// node restart with persistence.
 	alicePub := aliceChannel.channelState.IdentityPub
 	aliceChannels, err := aliceChannel.channelState.Db.FetchOpenChannels(
 		alicePub,
@@ -2972,1325 +3292,1042 @@ func TestChanSyncFullySynced(t *testing.T) {
 		aliceChannel.Signer, aliceChannels[0], aliceChannel.sigPool,
 	)
 	require.NoError(t, err, "unable to create new channel")
+
 	bobChannelNew, err := NewLightningChannel(
 		bobChannel.Signer, bobChannels[0], bobChannel.sigPool,
 	)
 	require.NoError(t, err, "unable to create new channel")
 
-	assertNoChanSyncNeeded(t, aliceChannelNew, bobChannelNew)
-}
-
-// restartChannel reads the passed channel from disk, and returns a newly
-// initialized instance. This simulates one party restarting and losing their
-// in memory state.
-func restartChannel(channelOld *LightningChannel) (*LightningChannel, error) {
-	nodePub := channelOld.channelState.IdentityPub
-	nodeChannels, err := channelOld.channelState.Db.FetchOpenChannels(
-		nodePub,
-	)
-	if err != nil {
-		return nil, err
+	// The state update logs of the new channels and the old channels
+	// should now be identical other than the height the HTLCs were added.
+	if aliceChannel.localUpdateLog.logIndex !=
+		aliceChannelNew.localUpdateLog.logIndex {
+		t.Fatalf("alice log counter: expected %v, got %v",
+			aliceChannel.localUpdateLog.logIndex,
+			aliceChannelNew.localUpdateLog.logIndex)
 	}
-
-	channelNew, err := NewLightningChannel(
-		channelOld.Signer, nodeChannels[0],
-		channelOld.sigPool,
-	)
-	if err != nil {
-		return nil, err
+	if aliceChannel.remoteUpdateLog.logIndex !=
+		aliceChannelNew.remoteUpdateLog.logIndex {
+		t.Fatalf("alice log counter: expected %v, got %v",
+			aliceChannel.remoteUpdateLog.logIndex,
+			aliceChannelNew.remoteUpdateLog.logIndex)
+	}
+	if aliceChannel.localUpdateLog.Len() !=
+		aliceChannelNew.localUpdateLog.Len() {
+		t.Fatalf("alice log len: expected %v, got %v",
+			aliceChannel.localUpdateLog.Len(),
+			aliceChannelNew.localUpdateLog.Len())
+	}
+	if aliceChannel.remoteUpdateLog.Len() !=
+		aliceChannelNew.remoteUpdateLog.Len() {
+		t.Fatalf("alice log len: expected %v, got %v",
+			aliceChannel.remoteUpdateLog.Len(),
+			aliceChannelNew.remoteUpdateLog.Len())
+	}
+	if bobChannel.localUpdateLog.logIndex !=
+		bobChannelNew.localUpdateLog.logIndex {
+		t.Fatalf("bob log counter: expected %v, got %v",
+			bobChannel.localUpdateLog.logIndex,
+			bobChannelNew.localUpdateLog.logIndex)
+	}
+	if bobChannel.remoteUpdateLog.logIndex !=
+		bobChannelNew.remoteUpdateLog.logIndex {
+		t.Fatalf("bob log counter: expected %v, got %v",
+			bobChannel.remoteUpdateLog.logIndex,
+			bobChannelNew.remoteUpdateLog.logIndex)
+	}
+	if bobChannel.localUpdateLog.Len() !=
+		bobChannelNew.localUpdateLog.Len() {
+		t.Fatalf("bob log len: expected %v, got %v",
+			bobChannel.localUpdateLog.Len(),
+			bobChannelNew.localUpdateLog.Len())
+	}
+	if bobChannel.remoteUpdateLog.Len() !=
+		bobChannelNew.remoteUpdateLog.Len() {
+		t.Fatalf("bob log len: expected %v, got %v",
+			bobChannel.remoteUpdateLog.Len(),
+			bobChannelNew.remoteUpdateLog.Len())
 	}
 
-	return channelNew, nil
-}
-
-// TestChanSyncOweCommitment tests that if Bob restarts (and then Alice) before
-// he receives Alice's CommitSig message, then Alice concludes that she needs
-// to re-send the CommitDiff. After the diff has been sent, both nodes should
-// resynchronize and be able to complete the dangling commit.
-func TestChanSyncOweCommitment(t *testing.T) {
-	t.Parallel()
-
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
-
-	var fakeOnionBlob [lnwire.OnionPacketSize]byte
-	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
+	// TODO(roasbeef): expand test to also ensure state revocation log has
+	// proper pk scripts
 
-	// We'll start off the scenario with Bob sending 3 HTLC's to Alice in a
-	// single state update.
-	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
-	const numBobHtlcs = 3
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xbb}, 32))
-	for i := 0; i < 3; i++ {
-		rHash := sha256.Sum256(bobPreimage[:])
-		h := &lnwire.UpdateAddHTLC{
-			PaymentHash: rHash,
-			Amount:      htlcAmt,
-			Expiry:      uint32(10),
-			OnionBlob:   fakeOnionBlob,
-			ExtraData:   make([]byte, 0),
+	// Newly generated pkScripts for HTLCs should be the same as in the old channel.
+	for _, entry := range aliceChannel.localUpdateLog.htlcIndex {
+		htlc := entry.Value.(*PaymentDescriptor)
+		restoredHtlc := aliceChannelNew.localUpdateLog.lookupHtlc(htlc.HtlcIndex)
+		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
+			t.Fatalf("alice ourPkScript in ourLog: expected %X, got %X",
+				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
 		}
-
-		htlcIndex, err := bobChannel.AddHTLC(h, nil)
-		if err != nil {
-			t.Fatalf("unable to add bob's htlc: %v", err)
+		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
+			t.Fatalf("alice theirPkScript in ourLog: expected %X, got %X",
+				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
 		}
-
-		h.ID = htlcIndex
-		if _, err := aliceChannel.ReceiveHTLC(h); err != nil {
-			t.Fatalf("unable to recv bob's htlc: %v", err)
+	}
+	for _, entry := range aliceChannel.remoteUpdateLog.htlcIndex {
+		htlc := entry.Value.(*PaymentDescriptor)
+		restoredHtlc := aliceChannelNew.remoteUpdateLog.lookupHtlc(htlc.HtlcIndex)
+		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
+			t.Fatalf("alice ourPkScript in theirLog: expected %X, got %X",
+				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
+		}
+		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
+			t.Fatalf("alice theirPkScript in theirLog: expected %X, got %X",
+				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
 		}
 	}
-
-	chanID := lnwire.NewChanIDFromOutPoint(
-		&aliceChannel.channelState.FundingOutpoint,
-	)
-
-	// With the HTLC's applied to both update logs, we'll initiate a state
-	// transition from Bob.
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
+	for _, entry := range bobChannel.localUpdateLog.htlcIndex {
+		htlc := entry.Value.(*PaymentDescriptor)
+		restoredHtlc := bobChannelNew.localUpdateLog.lookupHtlc(htlc.HtlcIndex)
+		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
+			t.Fatalf("bob ourPkScript in ourLog: expected %X, got %X",
+				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
+		}
+		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
+			t.Fatalf("bob theirPkScript in ourLog: expected %X, got %X",
+				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
+		}
+	}
+	for _, entry := range bobChannel.remoteUpdateLog.htlcIndex {
+		htlc := entry.Value.(*PaymentDescriptor)
+		restoredHtlc := bobChannelNew.remoteUpdateLog.lookupHtlc(htlc.HtlcIndex)
+		if !bytes.Equal(htlc.ourPkScript, restoredHtlc.ourPkScript) {
+			t.Fatalf("bob ourPkScript in theirLog: expected %X, got %X",
+				htlc.ourPkScript[:5], restoredHtlc.ourPkScript[:5])
+		}
+		if !bytes.Equal(htlc.theirPkScript, restoredHtlc.theirPkScript) {
+			t.Fatalf("bob theirPkScript in theirLog: expected %X, got %X",
+				htlc.theirPkScript[:5], restoredHtlc.theirPkScript[:5])
+		}
 	}
 
-	// Next, Alice's settles all 3 HTLC's from Bob, and also adds a new
-	// HTLC of her own.
+	// Now settle all the HTLCs, then force a state update. The state
+	// update should succeed as both sides have identical.
 	for i := 0; i < 3; i++ {
-		err := aliceChannel.SettleHTLC(bobPreimage, uint64(i), nil, nil, nil)
+		err := bobChannelNew.SettleHTLC(alicePreimage, uint64(i), nil, nil, nil)
 		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
+			t.Fatalf("unable to settle htlc #%v: %v", i, err)
 		}
-		err = bobChannel.ReceiveHTLCSettle(bobPreimage, uint64(i))
+		err = aliceChannelNew.ReceiveHTLCSettle(alicePreimage, uint64(i))
 		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
+			t.Fatalf("unable to settle htlc#%v: %v", i, err)
 		}
 	}
-	var alicePreimage [32]byte
-	copy(alicePreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	rHash := sha256.Sum256(alicePreimage[:])
-	aliceHtlc := &lnwire.UpdateAddHTLC{
-		ChanID:      chanID,
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
-		OnionBlob:   fakeOnionBlob,
-		ExtraData:   make([]byte, 0),
+	err = aliceChannelNew.SettleHTLC(bobPreimage, 0, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = bobChannelNew.ReceiveHTLCSettle(bobPreimage, 0)
+	require.NoError(t, err, "unable to settle htlc")
+
+	// Similar to the two transitions above, as both Bob and Alice added
+	// entries to the update log before a state transition was initiated by
+	// either side, both sides are required to trigger an update in order
+	// to lock in their changes.
+	if err := ForceStateTransition(aliceChannelNew, bobChannelNew); err != nil {
+		t.Fatalf("unable to update commitments: %v", err)
+	}
+	if err := ForceStateTransition(bobChannelNew, aliceChannelNew); err != nil {
+		t.Fatalf("unable to update commitments: %v", err)
 	}
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(aliceHtlc, nil)
-	require.NoError(t, err, "unable to add alice's htlc")
-	bobHtlcIndex, err := bobChannel.ReceiveHTLC(aliceHtlc)
-	require.NoError(t, err, "unable to recv alice's htlc")
 
-	// Now we'll begin the core of the test itself. Alice will extend a new
-	// commitment to Bob, but the connection drops before Bob can process
-	// it.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
+	// The amounts transferred should been updated as per the amounts in
+	// the HTLCs
+	if aliceChannelNew.channelState.TotalMSatSent != htlcAmt*3 {
+		t.Fatalf("expected %v alice satoshis sent, got %v",
+			htlcAmt*3, aliceChannelNew.channelState.TotalMSatSent)
+	}
+	if aliceChannelNew.channelState.TotalMSatReceived != htlcAmt {
+		t.Fatalf("expected %v alice satoshis received, got %v",
+			htlcAmt, aliceChannelNew.channelState.TotalMSatReceived)
+	}
+	if bobChannelNew.channelState.TotalMSatSent != htlcAmt {
+		t.Fatalf("expected %v bob satoshis sent, got %v",
+			htlcAmt, bobChannel.channelState.TotalMSatSent)
+	}
+	if bobChannelNew.channelState.TotalMSatReceived != htlcAmt*3 {
+		t.Fatalf("expected %v bob satoshis sent, got %v",
+			htlcAmt*3, bobChannel.channelState.TotalMSatReceived)
+	}
 
-	// Bob doesn't get this message so upon reconnection, they need to
-	// synchronize. Alice should conclude that she owes Bob a commitment,
-	// while Bob should think he's properly synchronized.
-	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
+	// As a final test, we'll ensure that the HTLC counters for both sides
+	// has been persisted properly. If we instruct Alice to add a new HTLC,
+	// it should have an index of 3. If we instruct Bob to do the
+	// same, it should have an index of 1.
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(bobh, nil)
+	require.NoError(t, err, "unable to add htlc")
+	if aliceHtlcIndex != 3 {
+		t.Fatalf("wrong htlc index: expected %v, got %v", 3, aliceHtlcIndex)
+	}
+	bobHtlcIndex, err := bobChannel.AddHTLC(bobh, nil)
+	require.NoError(t, err, "unable to add htlc")
+	if bobHtlcIndex != 1 {
+		t.Fatalf("wrong htlc index: expected %v, got %v", 1, aliceHtlcIndex)
+	}
+}
 
-	// This is a helper function that asserts Alice concludes that she
-	// needs to retransmit the exact commitment that we failed to send
-	// above.
-	assertAliceCommitRetransmit := func() {
-		aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(
-			bobSyncMsg,
-		)
-		if err != nil {
-			t.Fatalf("unable to process chan sync msg: %v", err)
-		}
-		if len(aliceMsgsToSend) != 5 {
-			t.Fatalf("expected alice to send %v messages instead "+
-				"will send %v: %v", 5, len(aliceMsgsToSend),
-				spew.Sdump(aliceMsgsToSend))
-		}
+func TestCancelHTLC(t *testing.T) {
+	t.Parallel()
 
-		// Each of the settle messages that Alice sent should match her
-		// original intent.
-		for i := 0; i < 3; i++ {
-			settleMsg, ok := aliceMsgsToSend[i].(*lnwire.UpdateFulfillHTLC)
-			if !ok {
-				t.Fatalf("expected an HTLC settle message, "+
-					"instead have %v", spew.Sdump(settleMsg))
-			}
-			if settleMsg.ID != uint64(i) {
-				t.Fatalf("wrong ID in settle msg: expected %v, "+
-					"got %v", i, settleMsg.ID)
-			}
-			if settleMsg.ChanID != chanID {
-				t.Fatalf("incorrect chan id: expected %v, got %v",
-					chanID, settleMsg.ChanID)
-			}
-			if settleMsg.PaymentPreimage != bobPreimage {
-				t.Fatalf("wrong pre-image: expected %v, got %v",
-					alicePreimage, settleMsg.PaymentPreimage)
-			}
-		}
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-		// The HTLC add message should be identical.
-		if _, ok := aliceMsgsToSend[3].(*lnwire.UpdateAddHTLC); !ok {
-			t.Fatalf("expected an HTLC add message, instead have %v",
-				spew.Sdump(aliceMsgsToSend[3]))
-		}
-		if !reflect.DeepEqual(aliceHtlc, aliceMsgsToSend[3]) {
-			t.Fatalf("htlc msg doesn't match exactly: "+
-				"expected %v got %v", spew.Sdump(aliceHtlc),
-				spew.Sdump(aliceMsgsToSend[3]))
-		}
+	// Add a new HTLC from Alice to Bob, then trigger a new state
+	// transition in order to include it in the latest state.
+	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
 
-		// Next, we'll ensure that the CommitSig message exactly
-		// matches what Alice originally intended to send.
-		commitSigMsg, ok := aliceMsgsToSend[4].(*lnwire.CommitSig)
-		if !ok {
-			t.Fatalf("expected a CommitSig message, instead have %v",
-				spew.Sdump(aliceMsgsToSend[4]))
-		}
-		if commitSigMsg.CommitSig != aliceNewCommit.CommitSig {
-			t.Fatalf("commit sig msgs don't match: expected "+
-				"%x got %x",
-				aliceNewCommit.CommitSig,
-				commitSigMsg.CommitSig)
-		}
-		if len(commitSigMsg.HtlcSigs) != len(aliceNewCommit.HtlcSigs) {
-			t.Fatalf("wrong number of htlc sigs: expected %v, got %v",
-				len(aliceNewCommit.HtlcSigs),
-				len(commitSigMsg.HtlcSigs))
-		}
-		for i, htlcSig := range commitSigMsg.HtlcSigs {
-			if htlcSig != aliceNewCommit.HtlcSigs[i] {
-				t.Fatalf("htlc sig msgs don't match: "+
-					"expected %x got %x",
-					aliceNewCommit.HtlcSigs[i], htlcSig)
-			}
-		}
+	var preImage [32]byte
+	copy(preImage[:], bytes.Repeat([]byte{0xaa}, 32))
+	htlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: sha256.Sum256(preImage[:]),
+		Amount:      htlcAmt,
+		Expiry:      10,
 	}
 
-	// Alice should detect that she needs to re-send 5 messages: the 3
-	// settles, her HTLC add, and finally her commit sig message.
-	assertAliceCommitRetransmit()
-
-	// From Bob's Pov he has nothing else to send, so he should conclude he
-	// has no further action remaining.
-	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(bobMsgsToSend) != 0 {
-		t.Fatalf("expected bob to send %v messages instead will "+
-			"send %v: %v", 5, len(bobMsgsToSend),
-			spew.Sdump(bobMsgsToSend))
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "unable to add alice htlc")
+	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "unable to add bob htlc")
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to create new commitment state: %v", err)
 	}
 
-	// If we restart Alice, she should still conclude that she needs to
-	// send the exact same set of messages.
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart alice")
-	assertAliceCommitRetransmit()
-
-	// TODO(roasbeef): restart bob as well???
+	// With the HTLC committed, Alice's balance should reflect the clearing
+	// of the new HTLC.
+	aliceExpectedBalance := btcutil.Amount(btcutil.SatoshiPerBitcoin*4) -
+		calcStaticFee(channeldb.SingleFunderTweaklessBit, 1)
+	if aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis() !=
+		aliceExpectedBalance {
+		t.Fatalf("Alice's balance is wrong: expected %v, got %v",
+			aliceExpectedBalance,
+			aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis())
+	}
 
-	// At this point, we should be able to resume the prior state update
-	// without any issues, resulting in Alice settling the 3 htlc's, and
-	// adding one of her own.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's commitment")
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke bob commitment")
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign commitment")
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to recv revocation")
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to rev bob's commitment")
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "alice unable to revoke commitment")
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to recv revocation")
+	// Now, with the HTLC committed on both sides, trigger a cancellation
+	// from Bob to Alice, removing the HTLC.
+	err = bobChannel.FailHTLC(bobHtlcIndex, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveFailHTLC(aliceHtlcIndex, []byte("bad"))
+	require.NoError(t, err, "unable to recv htlc cancel")
 
-	// At this point, we'll now assert that their log states are what we
-	// expect.
-	//
-	// Alice's local log counter should be 4 and her HTLC index 3. She
-	// should detect Bob's remote log counter as being 3 and his HTLC index
-	// 3 as well.
-	if aliceChannel.localUpdateLog.logIndex != 4 {
-		t.Fatalf("incorrect log index: expected %v, got %v", 4,
-			aliceChannel.localUpdateLog.logIndex)
-	}
-	if aliceChannel.localUpdateLog.htlcCounter != 1 {
-		t.Fatalf("incorrect htlc index: expected %v, got %v", 1,
-			aliceChannel.localUpdateLog.htlcCounter)
-	}
-	if aliceChannel.remoteUpdateLog.logIndex != 3 {
-		t.Fatalf("incorrect log index: expected %v, got %v", 3,
-			aliceChannel.localUpdateLog.logIndex)
-	}
-	if aliceChannel.remoteUpdateLog.htlcCounter != 3 {
-		t.Fatalf("incorrect htlc index: expected %v, got %v", 3,
-			aliceChannel.localUpdateLog.htlcCounter)
+	// Now trigger another state transition, the HTLC should now be removed
+	// from both sides, with balances reflected.
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to create new commitment: %v", err)
 	}
 
-	// Bob should also have the same state, but mirrored.
-	if bobChannel.localUpdateLog.logIndex != 3 {
-		t.Fatalf("incorrect log index: expected %v, got %v", 3,
-			bobChannel.localUpdateLog.logIndex)
+	// Now HTLCs should be present on the commitment transaction for either
+	// side.
+	if len(aliceChannel.localCommitChain.tip().outgoingHTLCs) != 0 ||
+		len(aliceChannel.remoteCommitChain.tip().outgoingHTLCs) != 0 {
+		t.Fatalf("htlc's still active from alice's POV")
 	}
-	if bobChannel.localUpdateLog.htlcCounter != 3 {
-		t.Fatalf("incorrect htlc index: expected %v, got %v", 3,
-			bobChannel.localUpdateLog.htlcCounter)
+	if len(aliceChannel.localCommitChain.tip().incomingHTLCs) != 0 ||
+		len(aliceChannel.remoteCommitChain.tip().incomingHTLCs) != 0 {
+		t.Fatalf("htlc's still active from alice's POV")
 	}
-	if bobChannel.remoteUpdateLog.logIndex != 4 {
-		t.Fatalf("incorrect log index: expected %v, got %v", 4,
-			bobChannel.localUpdateLog.logIndex)
+	if len(bobChannel.localCommitChain.tip().outgoingHTLCs) != 0 ||
+		len(bobChannel.remoteCommitChain.tip().outgoingHTLCs) != 0 {
+		t.Fatalf("htlc's still active from bob's POV")
 	}
-	if bobChannel.remoteUpdateLog.htlcCounter != 1 {
-		t.Fatalf("incorrect htlc index: expected %v, got %v", 1,
-			bobChannel.localUpdateLog.htlcCounter)
+	if len(bobChannel.localCommitChain.tip().incomingHTLCs) != 0 ||
+		len(bobChannel.remoteCommitChain.tip().incomingHTLCs) != 0 {
+		t.Fatalf("htlc's still active from bob's POV")
 	}
 
-	// We'll conclude the test by having Bob settle Alice's HTLC, then
-	// initiate a state transition.
-	err = bobChannel.SettleHTLC(alicePreimage, bobHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "unable to settle htlc")
-	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, aliceHtlcIndex)
-	require.NoError(t, err, "unable to settle htlc")
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
-	}
+	expectedBalance := btcutil.Amount(btcutil.SatoshiPerBitcoin * 5)
+	staticFee := calcStaticFee(channeldb.SingleFunderTweaklessBit, 0)
+	if aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis() !=
+		expectedBalance-staticFee {
 
-	// At this point, the final balances of both parties should properly
-	// reflect the amount of HTLC's sent.
-	bobMsatSent := numBobHtlcs * htlcAmt
-	if aliceChannel.channelState.TotalMSatSent != htlcAmt {
-		t.Fatalf("wrong value for msat sent: expected %v, got %v",
-			htlcAmt, aliceChannel.channelState.TotalMSatSent)
+		t.Fatalf("balance is wrong: expected %v, got %v",
+			aliceChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis(),
+			expectedBalance-staticFee)
 	}
-	if aliceChannel.channelState.TotalMSatReceived != bobMsatSent {
-		t.Fatalf("wrong value for msat recv: expected %v, got %v",
-			bobMsatSent, aliceChannel.channelState.TotalMSatReceived)
+	if aliceChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis() !=
+		expectedBalance {
+
+		t.Fatalf("balance is wrong: expected %v, got %v",
+			aliceChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis(),
+			expectedBalance)
 	}
-	if bobChannel.channelState.TotalMSatSent != bobMsatSent {
-		t.Fatalf("wrong value for msat sent: expected %v, got %v",
-			bobMsatSent, bobChannel.channelState.TotalMSatSent)
+	if bobChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis() !=
+		expectedBalance {
+
+		t.Fatalf("balance is wrong: expected %v, got %v",
+			bobChannel.channelState.LocalCommitment.LocalBalance.ToSatoshis(),
+			expectedBalance)
 	}
-	if bobChannel.channelState.TotalMSatReceived != htlcAmt {
-		t.Fatalf("wrong value for msat recv: expected %v, got %v",
-			htlcAmt, bobChannel.channelState.TotalMSatReceived)
+	if bobChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis() !=
+		expectedBalance-staticFee {
+
+		t.Fatalf("balance is wrong: expected %v, got %v",
+			bobChannel.channelState.LocalCommitment.RemoteBalance.ToSatoshis(),
+			expectedBalance-staticFee)
 	}
 }
 
-// TestChanSyncOweCommitmentPendingRemote asserts that local updates are applied
-// to the remote commit across restarts.
-func TestChanSyncOweCommitmentPendingRemote(t *testing.T) {
+// TestLastCloseFee asserts that LastCloseFee reports the fee actually paid by
+// the cooperative close transaction, including any omitted dust output.
+func TestLastCloseFee(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
 
-	var fakeOnionBlob [lnwire.OnionPacketSize]byte
-	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
+	// coopClose closes the channel cooperatively and returns Alice's
+	// channel along with the close transaction.
+	coopClose := func(bobDustLimit btcutil.Amount) (*LightningChannel,
+		*wire.MsgTx, btcutil.Amount) {
 
-	// We'll start off the scenario where Bob send two htlcs to Alice in a
-	// single state update.
-	var preimages []lntypes.Preimage
-	const numHtlcs = 2
-	for id := byte(0); id < numHtlcs; id++ {
-		htlcAmt := lnwire.NewMSatFromSatoshis(20000)
-		var bobPreimage [32]byte
-		copy(bobPreimage[:], bytes.Repeat([]byte{id}, 32))
-		rHash := sha256.Sum256(bobPreimage[:])
-		h := &lnwire.UpdateAddHTLC{
-			PaymentHash: rHash,
-			Amount:      htlcAmt,
-			Expiry:      uint32(10),
-			OnionBlob:   fakeOnionBlob,
-		}
+		aliceChannel, bobChannel, err := CreateTestChannels(
+			t, channeldb.SingleFunderTweaklessBit,
+		)
+		require.NoError(t, err, "unable to create test channels")
 
-		htlcIndex, err := bobChannel.AddHTLC(h, nil)
-		if err != nil {
-			t.Fatalf("unable to add bob's htlc: %v", err)
+		if bobDustLimit != 0 {
+			aliceChannel.channelState.RemoteChanCfg.DustLimit =
+				bobDustLimit
+			bobChannel.channelState.LocalChanCfg.DustLimit =
+				bobDustLimit
 		}
 
-		h.ID = htlcIndex
-		if _, err := aliceChannel.ReceiveHTLC(h); err != nil {
-			t.Fatalf("unable to recv bob's htlc: %v", err)
-		}
+		_, ok := aliceChannel.LastCloseFee()
+		require.False(t, ok)
 
-		preimages = append(preimages, bobPreimage)
-	}
+		fee := aliceChannel.CalcFee(chainfee.SatPerKWeight(
+			aliceChannel.channelState.LocalCommitment.FeePerKw,
+		))
+		aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+			fee, aliceDeliveryScript, bobDeliveryScript,
+		)
+		require.NoError(t, err)
+		bobSig, _, _, err := bobChannel.CreateCloseProposal(
+			fee, bobDeliveryScript, aliceDeliveryScript,
+		)
+		require.NoError(t, err)
 
-	// With the HTLCs applied to both update logs, we'll initiate a state
-	// transition from Bob.
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
+		closeTx, _, err := aliceChannel.CompleteCooperativeClose(
+			aliceSig, bobSig, aliceDeliveryScript,
+			bobDeliveryScript, fee,
+		)
+		require.NoError(t, err)
+
+		return aliceChannel, closeTx, fee
 	}
 
-	// Next, Alice settles the HTLCs from Bob in distinct state updates.
-	for i := 0; i < numHtlcs; i++ {
-		err = aliceChannel.SettleHTLC(preimages[i], uint64(i), nil, nil, nil)
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
-		err = bobChannel.ReceiveHTLCSettle(preimages[i], uint64(i))
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
+	// closeTxFee returns the capacity minus the total outputs of the
+	// close transaction.
+	closeTxFee := func(channel *LightningChannel,
+		closeTx *wire.MsgTx) btcutil.Amount {
 
-		aliceNewCommit, err := aliceChannel.SignNextCommitment()
-		if err != nil {
-			t.Fatalf("unable to sign commitment: %v", err)
-		}
-		err = bobChannel.ReceiveNewCommitment(
-			aliceNewCommit.CommitSigs,
-		)
-		if err != nil {
-			t.Fatalf("unable to receive commitment: %v", err)
-		}
-
-		// Bob revokes his current commitment. After this call
-		// completes, the htlc is settled on the local commitment
-		// transaction. Bob still owes Alice a signature to also settle
-		// the htlc on her local commitment transaction.
-		bobRevoke, _, _, err := bobChannel.RevokeCurrentCommitment()
-		if err != nil {
-			t.Fatalf("unable to revoke commitment: %v", err)
+		fee := channel.channelState.Capacity
+		for _, txOut := range closeTx.TxOut {
+			fee -= btcutil.Amount(txOut.Value)
 		}
 
-		_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevoke)
-		if err != nil {
-			t.Fatalf("unable to revoke commitment: %v", err)
-		}
+		return fee
 	}
 
-	// We restart Bob. This should have no impact on further message that
-	// are generated.
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart bob")
+	// With both outputs present, the fee paid matches the proposed fee.
+	aliceChannel, closeTx, proposedFee := coopClose(0)
+	require.Len(t, closeTx.TxOut, 2)
 
-	// Bob signs the commitment he owes.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
+	closeFee, ok := aliceChannel.LastCloseFee()
+	require.True(t, ok)
+	require.Equal(t, closeTxFee(aliceChannel, closeTx), closeFee)
+	require.Equal(t, proposedFee, closeFee)
 
-	// This commitment is expected to contain no htlcs anymore.
-	if len(bobNewCommit.HtlcSigs) != 0 {
-		t.Fatalf("no htlcs expected, but got %v",
-			len(bobNewCommit.HtlcSigs))
-	}
+	// If Bob's output is below his dust limit, it's omitted and its value
+	// goes to the fee as well.
+	bobBalance := aliceChannel.channelState.LocalCommitment.RemoteBalance
+	aliceChannel, closeTx, proposedFee = coopClose(
+		bobBalance.ToSatoshis() + 1,
+	)
+	require.Len(t, closeTx.TxOut, 1)
 
-	// Get Alice to revoke and trigger Bob to compact his logs.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	aliceRevoke, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevoke)
-	if err != nil {
-		t.Fatal(err)
-	}
+	closeFee, ok = aliceChannel.LastCloseFee()
+	require.True(t, ok)
+	require.Equal(t, closeTxFee(aliceChannel, closeTx), closeFee)
+	require.Equal(t, proposedFee+bobBalance.ToSatoshis(), closeFee)
 }
 
-// testChanSyncOweRevocation is the internal version of
-// TestChanSyncOweRevocation that is parameterized based on the type of channel
-// being used in the test.
-func testChanSyncOweRevocation(t *testing.T, chanType channeldb.ChannelType) {
+func TestCooperativeCloseDustAdherence(t *testing.T) {
+	t.Parallel()
+
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
 	require.NoError(t, err, "unable to create test channels")
 
-	chanID := lnwire.NewChanIDFromOutPoint(
-		&aliceChannel.channelState.FundingOutpoint,
+	aliceFeeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	bobFeeRate := chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
 	)
 
-	// We'll start the test with Bob extending a single HTLC to Alice, and
-	// then initiating a state transition.
-	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	rHash := sha256.Sum256(bobPreimage[:])
-	bobHtlc := &lnwire.UpdateAddHTLC{
-		ChanID:      chanID,
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
+	setDustLimit := func(dustVal btcutil.Amount) {
+		aliceChannel.channelState.LocalChanCfg.DustLimit = dustVal
+		aliceChannel.channelState.RemoteChanCfg.DustLimit = dustVal
+		bobChannel.channelState.LocalChanCfg.DustLimit = dustVal
+		bobChannel.channelState.RemoteChanCfg.DustLimit = dustVal
 	}
-	bobHtlcIndex, err := bobChannel.AddHTLC(bobHtlc, nil)
-	require.NoError(t, err, "unable to add bob's htlc")
-	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(bobHtlc)
-	require.NoError(t, err, "unable to recv bob's htlc")
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
+
+	resetChannelState := func() {
+		aliceChannel.status = ChannelOpen
+		bobChannel.status = ChannelOpen
 	}
 
-	// Next, Alice will settle that single HTLC, the _begin_ the start of a
-	// state transition.
-	err = aliceChannel.SettleHTLC(bobPreimage, aliceHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "unable to settle htlc")
-	err = bobChannel.ReceiveHTLCSettle(bobPreimage, bobHtlcIndex)
-	require.NoError(t, err, "unable to settle htlc")
+	setBalances := func(aliceBalance, bobBalance lnwire.MilliSatoshi) {
+		aliceChannel.channelState.LocalCommitment.LocalBalance = aliceBalance
+		aliceChannel.channelState.LocalCommitment.RemoteBalance = bobBalance
+		bobChannel.channelState.LocalCommitment.LocalBalance = bobBalance
+		bobChannel.channelState.LocalCommitment.RemoteBalance = aliceBalance
+	}
 
-	// We'll model the state transition right up until Alice needs to send
-	// her revocation message to complete the state transition.
-	//
-	// Alice signs the next state, then Bob receives and sends his
-	// revocation message.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's commitment")
+	aliceDeliveryScript := genP2WPKHScript(t, bobsPrivKey)
+	bobDeliveryScript := genP2WPKHScript(t, testHdSeed[:])
 
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke bob commitment")
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign commitment")
+	// We'll start be initializing the limit of both Alice and Bob to 10k
+	// satoshis.
+	dustLimit := btcutil.Amount(10000)
+	setDustLimit(dustLimit)
 
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to recv revocation")
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to rev bob's commitment")
+	// Both sides currently have over 1 BTC settled as part of their
+	// balances. As a result, performing a cooperative closure now result
+	// in both sides having an output within the closure transaction.
+	aliceFee := btcutil.Amount(aliceChannel.CalcFee(aliceFeeRate)) + 1000
+	aliceSig, _, _, err := aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err, "unable to close channel")
 
-	// At this point, we'll simulate the connection breaking down by Bob's
-	// lack of knowledge of the revocation message that Alice just sent.
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "alice unable to revoke commitment")
+	bobFee := btcutil.Amount(bobChannel.CalcFee(bobFeeRate)) + 1000
+	bobSig, _, _, err := bobChannel.CreateCloseProposal(
+		bobFee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err, "unable to close channel")
 
-	// If we fetch the channel sync messages at this state, then Alice
-	// should report that she owes Bob a revocation message, while Bob
-	// thinks they're fully in sync.
-	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
+	closeTx, _, err := bobChannel.CompleteCooperativeClose(
+		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
+		bobFee,
+	)
+	require.NoError(t, err, "unable to accept channel close")
 
-	// For taproot channels, simulate the link/peer binding the generated
-	// nonces.
-	if chanType.IsTaproot() {
-		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *aliceSyncMsg.LocalNonce,
-		}
-		bobChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *bobSyncMsg.LocalNonce,
-		}
+	// The closure transaction should have exactly two outputs.
+	if len(closeTx.TxOut) != 2 {
+		t.Fatalf("close tx has wrong number of outputs: expected %v "+
+			"got %v", 2, len(closeTx.TxOut))
 	}
 
-	assertAliceOwesRevoke := func() {
-		t.Helper()
-
-		aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(
-			bobSyncMsg,
-		)
-		if err != nil {
-			t.Fatalf("unable to process chan sync msg: %v", err)
-		}
-		if len(aliceMsgsToSend) != 1 {
-			t.Fatalf("expected single message retransmission from Alice, "+
-				"instead got %v", spew.Sdump(aliceMsgsToSend))
-		}
-		aliceReRevoke, ok := aliceMsgsToSend[0].(*lnwire.RevokeAndAck)
-		if !ok {
-			t.Fatalf("expected to retransmit revocation msg, instead "+
-				"have: %v", spew.Sdump(aliceMsgsToSend[0]))
-		}
+	// We'll reset the channel states before proceeding to our next test.
+	resetChannelState()
 
-		// Alice should re-send the revocation message for her prior
-		// state.
-		expectedRevocation, err := aliceChannel.generateRevocation(
-			aliceChannel.currentHeight - 1,
-		)
-		if err != nil {
-			t.Fatalf("unable to regenerate revocation: %v", err)
-		}
-		if !reflect.DeepEqual(expectedRevocation, aliceReRevoke) {
-			t.Fatalf("wrong re-revocation: expected %v, got %v",
-				spew.Sdump(expectedRevocation),
-				spew.Sdump(aliceReRevoke))
-		}
-	}
+	// Next we'll modify the current balances and dust limits such that
+	// Bob's current balance is _below_ his dust limit.
+	aliceBal := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	bobBal := lnwire.NewMSatFromSatoshis(250)
+	setBalances(aliceBal, bobBal)
 
-	// From Bob's PoV he shouldn't think that he owes Alice any messages.
-	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(bobMsgsToSend) != 0 {
-		t.Fatalf("expected bob to not retransmit, instead has: %v",
-			spew.Sdump(bobMsgsToSend))
-	}
+	// Attempt another cooperative channel closure. It should succeed
+	// without any issues.
+	aliceSig, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err, "unable to close channel")
 
-	// Alice should detect that she owes Bob a revocation message, and only
-	// that single message.
-	assertAliceOwesRevoke()
+	bobSig, _, _, err = bobChannel.CreateCloseProposal(
+		bobFee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err, "unable to close channel")
 
-	// If we restart Alice, then she should still decide that she owes a
-	// revocation message to Bob.
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart alice")
+	closeTx, _, err = bobChannel.CompleteCooperativeClose(
+		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
+		bobFee,
+	)
+	require.NoError(t, err, "unable to accept channel close")
 
-	// For taproot channels, simulate the link/peer binding the generated
-	// nonces.
-	if chanType.IsTaproot() {
-		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *aliceSyncMsg.LocalNonce,
-		}
-		bobChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *bobSyncMsg.LocalNonce,
-		}
+	// The closure transaction should only have a single output, and that
+	// output should be Alice's balance.
+	if len(closeTx.TxOut) != 1 {
+		t.Fatalf("close tx has wrong number of outputs: expected %v "+
+			"got %v", 1, len(closeTx.TxOut))
+	}
+	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
+	aliceExpectedBalance := aliceBal.ToSatoshis() - aliceFee + commitFee
+	if closeTx.TxOut[0].Value != int64(aliceExpectedBalance) {
+		t.Fatalf("alice's balance is incorrect: expected %v, got %v",
+			aliceExpectedBalance,
+			int64(closeTx.TxOut[0].Value))
 	}
 
-	assertAliceOwesRevoke()
+	// We'll modify the current balances and dust limits such that
+	// Alice's current balance is too low to pay the proposed fee.
+	setBalances(bobBal, aliceBal)
+	resetChannelState()
 
-	// TODO(roasbeef): restart bob too???
+	// Attempting to close with this fee now should fail, since Alice
+	// cannot afford it.
+	_, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
 
-	// We'll continue by then allowing bob to process Alice's revocation
-	// message.
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to recv revocation")
+	// Finally, we'll modify the current balances and dust limits such that
+	// Alice's balance after paying the coop fee is _below_ her dust limit.
+	lowBalance := lnwire.NewMSatFromSatoshis(aliceFee) + 1000
+	setBalances(lowBalance, aliceBal)
+	resetChannelState()
 
-	// Finally, Alice will add an HTLC over her own such that we assert the
-	// channel can continue to receive updates.
-	var alicePreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	rHash = sha256.Sum256(alicePreimage[:])
-	aliceHtlc := &lnwire.UpdateAddHTLC{
-		ChanID:      chanID,
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
+	// Our final attempt at another cooperative channel closure. It should
+	// succeed without any issues.
+	aliceSig, _, _, err = aliceChannel.CreateCloseProposal(
+		aliceFee, aliceDeliveryScript, bobDeliveryScript,
+	)
+	require.NoError(t, err, "unable to close channel")
+
+	bobSig, _, _, err = bobChannel.CreateCloseProposal(
+		bobFee, bobDeliveryScript, aliceDeliveryScript,
+	)
+	require.NoError(t, err, "unable to close channel")
+
+	closeTx, _, err = bobChannel.CompleteCooperativeClose(
+		bobSig, aliceSig, bobDeliveryScript, aliceDeliveryScript,
+		bobFee,
+	)
+	require.NoError(t, err, "unable to accept channel close")
+
+	// The closure transaction should only have a single output, and that
+	// output should be Bob's balance.
+	if len(closeTx.TxOut) != 1 {
+		t.Fatalf("close tx has wrong number of outputs: expected %v "+
+			"got %v", 1, len(closeTx.TxOut))
 	}
-	if _, err := aliceChannel.AddHTLC(aliceHtlc, nil); err != nil {
-		t.Fatalf("unable to add alice's htlc: %v", err)
+	if closeTx.TxOut[0].Value != int64(aliceBal.ToSatoshis()) {
+		t.Fatalf("bob's balance is incorrect: expected %v, got %v",
+			aliceBal.ToSatoshis(), closeTx.TxOut[0].Value)
 	}
-	if _, err := bobChannel.ReceiveHTLC(aliceHtlc); err != nil {
-		t.Fatalf("unable to recv alice's htlc: %v", err)
+}
+
+// TestUpdateFeeAdjustments tests that the state machine is able to properly
+// accept valid fee changes, as well as reject any invalid fee updates.
+func TestUpdateFeeAdjustments(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First, we'll grab the current base fee rate as we'll be using this
+	// to make relative adjustments int he fee rate.
+	baseFeeRate := aliceChannel.channelState.LocalCommitment.FeePerKw
+
+	// We'll first try to increase the fee rate 5x, this should be able to
+	// be committed without any issue.
+	newFee := chainfee.SatPerKWeight(baseFeeRate * 5)
+
+	if err := aliceChannel.UpdateFee(newFee); err != nil {
+		t.Fatalf("unable to alice update fee: %v", err)
 	}
+	if err := bobChannel.ReceiveUpdateFee(newFee); err != nil {
+		t.Fatalf("unable to bob update fee: %v", err)
+	}
+
+	// With the fee updates applied, we'll now initiate a state transition
+	// to ensure the fee update is locked in.
 	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete alice's state transition: %v", err)
+		t.Fatalf("unable to create new commitment: %v", err)
 	}
 
-	// At this point, both sides should detect that they're fully synced.
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
+	// We'll now attempt to increase the fee rate 1,000,000x of the base
+	// fee.  This should result in an error as Alice won't be able to pay
+	// this new fee rate.
+	newFee = chainfee.SatPerKWeight(baseFeeRate * 1000000)
+	if err := aliceChannel.UpdateFee(newFee); err == nil {
+		t.Fatalf("alice should reject the fee rate")
+	}
+
+	// Finally, we'll attempt to adjust the fee down and use a fee which is
+	// smaller than the initial base fee rate. The fee application and
+	// state transition should proceed without issue.
+	newFee = chainfee.SatPerKWeight(baseFeeRate / 10)
+	if err := aliceChannel.UpdateFee(newFee); err != nil {
+		t.Fatalf("unable to alice update fee: %v", err)
+	}
+	if err := bobChannel.ReceiveUpdateFee(newFee); err != nil {
+		t.Fatalf("unable to bob update fee: %v", err)
+	}
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to create new commitment: %v", err)
+	}
 }
 
-// TestChanSyncOweRevocation tests that if Bob restarts (and then Alice) before
-// he receiver's Alice's RevokeAndAck message, then Alice concludes that she
-// needs to re-send the RevokeAndAck. After the revocation has been sent, both
-// nodes should be able to successfully complete another state transition.
-func TestChanSyncOweRevocation(t *testing.T) {
+// TestUpdateFeeFail tests that the signature verification will fail if they
+// fee updates are out of sync.
+func TestUpdateFeeFail(t *testing.T) {
 	t.Parallel()
 
-	t.Run("tweakless", func(t *testing.T) {
-		testChanSyncOweRevocation(t, channeldb.SingleFunderTweaklessBit)
-	})
-	t.Run("taproot", func(t *testing.T) {
-		taprootBits := channeldb.SimpleTaprootFeatureBit |
-			channeldb.AnchorOutputsBit |
-			channeldb.ZeroHtlcTxFeeBit |
-			channeldb.SingleFunderTweaklessBit
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Bob receives the update, that will apply to his commitment
+	// transaction.
+	if err := bobChannel.ReceiveUpdateFee(333); err != nil {
+		t.Fatalf("unable to apply fee update: %v", err)
+	}
+
+	// Alice sends signature for commitment that does not cover any fee
+	// update.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
+
+	// Bob verifies this commit, meaning that he checks that it is
+	// consistent everything he has received. This should fail, since he got
+	// the fee update, but Alice never sent it.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err == nil {
+		t.Fatalf("expected bob to fail receiving alice's signature")
+	}
 
-		testChanSyncOweRevocation(t, taprootBits)
-	})
 }
 
-func testChanSyncOweRevocationAndCommit(t *testing.T,
-	chanType channeldb.ChannelType) {
+// TestUpdateFeeRelayFloor asserts that a fee update below the relay fee floor
+// of the fee estimator, raised by the configured margin, is rejected.
+func TestUpdateFeeRelayFloor(t *testing.T) {
+	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	_, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
 	require.NoError(t, err, "unable to create test channels")
 
-	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	// Without an estimator, only the static fee floor applies.
+	require.NoError(t, bobChannel.ReceiveUpdateFee(1000))
 
-	// We'll kick off the test by having Bob send Alice an HTLC, then lock
-	// it in with a state transition.
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	rHash := sha256.Sum256(bobPreimage[:])
-	bobHtlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
-	}
-	bobHtlcIndex, err := bobChannel.AddHTLC(bobHtlc, nil)
-	require.NoError(t, err, "unable to add bob's htlc")
-	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(bobHtlc)
-	require.NoError(t, err, "unable to recv bob's htlc")
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
+	// Restart Bob with a relay fee of 1000 sat/kw and a margin of 10%,
+	// which puts the floor at 1100 sat/kw.
+	estimator := chainfee.NewStaticEstimator(5000, 1000)
+	bobChannel, err = NewLightningChannel(
+		bobChannel.Signer, bobChannel.channelState,
+		bobChannel.sigPool, WithRelayFeeFloor(estimator, 10),
+	)
+	require.NoError(t, err)
+
+	for _, feePerKw := range []chainfee.SatPerKWeight{1000, 1099} {
+		err := bobChannel.ReceiveUpdateFee(feePerKw)
+		require.ErrorIs(t, err, ErrFeeRateBelowFloor)
 	}
+	require.NoError(t, bobChannel.ReceiveUpdateFee(1100))
+}
 
-	// Next, Alice will settle that incoming HTLC, then we'll start the
-	// core of the test itself.
-	err = aliceChannel.SettleHTLC(bobPreimage, aliceHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "unable to settle htlc")
-	err = bobChannel.ReceiveHTLCSettle(bobPreimage, bobHtlcIndex)
-	require.NoError(t, err, "unable to settle htlc")
+// TestUpdateFeeMismatch asserts that a commitment signature covering a
+// different fee rate than the one we expect is rejected with an
+// ErrFeeMismatch carrying both fee rates.
+func TestUpdateFeeMismatch(t *testing.T) {
+	t.Parallel()
 
-	// Progressing the exchange: Alice will send her signature, Bob will
-	// receive, send a revocation and also a signature for Alice's state.
-	aliceNewCommits, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's commitment")
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Bob generates the revoke and sig message, but the messages don't
-	// reach Alice before the connection dies.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke bob commitment")
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign commitment")
+	// Lock in an HTLC first, so the commitment carries more than just the
+	// balance outputs.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// If we now attempt to resync, then Alice should conclude that she
-	// doesn't need any further updates, while Bob concludes that he needs
-	// to re-send both his revocation and commit sig message.
-	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
+	oldFee := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	newFee := oldFee * 2
 
-	// For taproot channels, simulate the link/peer binding the generated
-	// nonces.
-	if chanType.IsTaproot() {
-		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *aliceSyncMsg.LocalNonce,
-		}
-		bobChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *bobSyncMsg.LocalNonce,
-		}
-	}
+	// Inject a fee update on Bob's side only, so Alice signs a commitment
+	// at the old fee rate while Bob expects the new one.
+	require.NoError(t, bobChannel.ReceiveUpdateFee(newFee))
 
-	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(aliceMsgsToSend) != 0 {
-		t.Fatalf("expected alice to not retransmit, instead she's "+
-			"sending: %v", spew.Sdump(aliceMsgsToSend))
-	}
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-	assertBobSendsRevokeAndCommit := func() {
-		t.Helper()
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	var feeErr *ErrFeeMismatch
+	require.ErrorAs(t, err, &feeErr)
+	require.Equal(t, newFee, feeErr.localFeePerKw)
+	require.Equal(t, oldFee, feeErr.remoteFeePerKw)
+
+	// If instead Alice applies a fee update Bob never received, the fee
+	// rate she signed at is unknown to Bob, so he can only report the
+	// invalid signature.
+	aliceChannel, bobChannel, err = CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+	require.NoError(t, aliceChannel.UpdateFee(newFee))
 
-		bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(
-			aliceSyncMsg,
-		)
-		if err != nil {
-			t.Fatalf("unable to process chan sync msg: %v", err)
-		}
-		if len(bobMsgsToSend) != 2 {
-			t.Fatalf("expected bob to send %v messages, instead "+
-				"sends: %v", 2, spew.Sdump(bobMsgsToSend))
-		}
-		bobReRevoke, ok := bobMsgsToSend[0].(*lnwire.RevokeAndAck)
-		if !ok {
-			t.Fatalf("expected bob to re-send revoke, instead "+
-				"sending: %v", spew.Sdump(bobMsgsToSend[0]))
-		}
-		if !reflect.DeepEqual(bobReRevoke, bobRevocation) {
-			t.Fatalf("revocation msgs don't match: expected %v, "+
-				"got %v", bobRevocation, bobReRevoke)
-		}
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-		bobReCommitSigMsg, ok := bobMsgsToSend[1].(*lnwire.CommitSig)
-		if !ok {
-			t.Fatalf("expected bob to re-send commit sig, "+
-				"instead sending: %v",
-				spew.Sdump(bobMsgsToSend[1]))
-		}
-		if bobReCommitSigMsg.CommitSig != bobNewCommit.CommitSig {
-			t.Fatalf("commit sig msgs don't match: expected %x "+
-				"got %x",
-				bobNewCommit.CommitSigs.CommitSig,
-				bobReCommitSigMsg.CommitSig)
-		}
-		if len(bobReCommitSigMsg.HtlcSigs) !=
-			len(bobNewCommit.HtlcSigs) {
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	var sigErr *InvalidCommitSigError
+	require.ErrorAs(t, err, &sigErr)
+}
 
-			t.Fatalf("wrong number of htlc sigs: expected %v, "+
-				"got %v",
-				len(bobNewCommit.HtlcSigs),
-				len(bobReCommitSigMsg.HtlcSigs))
-		}
-		for i, htlcSig := range bobReCommitSigMsg.HtlcSigs {
-			if htlcSig != bobNewCommit.HtlcSigs[i] {
-				t.Fatalf("htlc sig msgs don't match: "+
-					"expected %x got %x",
-					htlcSig,
-					bobNewCommit.HtlcSigs[i])
-			}
-		}
-	}
+// TestUpdateFeeConcurrentSig tests that the channel can properly handle a fee
+// update that it receives concurrently with signing its next commitment.
+func TestUpdateFeeConcurrentSig(t *testing.T) {
+	t.Parallel()
 
-	// We expect Bob to send exactly two messages: first his revocation
-	// message to Alice, and second his original commit sig message.
-	assertBobSendsRevokeAndCommit()
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// At this point we simulate the connection failing with a restart from
-	// Bob. He should still re-send the exact same set of messages.
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart channel")
+	paymentPreimage := bytes.Repeat([]byte{1}, 32)
+	paymentHash := sha256.Sum256(paymentPreimage)
+	htlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: paymentHash,
+		Amount:      btcutil.SatoshiPerBitcoin,
+		Expiry:      uint32(5),
+	}
 
-	// For taproot channels, simulate the link/peer binding the generated
-	// nonces.
-	if chanType.IsTaproot() {
-		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *aliceSyncMsg.LocalNonce,
-		}
-		bobChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *bobSyncMsg.LocalNonce,
-		}
+	// First Alice adds the outgoing HTLC to her local channel's state
+	// update log. Then Alice sends this wire message over to Bob who
+	// adds this htlc to his remote state update log.
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
 	}
 
-	assertBobSendsRevokeAndCommit()
+	// Simulate Alice sending update fee message to bob.
+	fee := chainfee.SatPerKWeight(333)
+	if err := aliceChannel.UpdateFee(fee); err != nil {
+		t.Fatalf("unable to send fee update")
+	}
 
-	// We'll now finish the state transition by having Alice process both
-	// messages, and send her final revocation.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to recv revocation")
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to recv bob's commitment")
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "alice unable to revoke commitment")
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to recv revocation")
-}
+	// Alice signs a commitment, and sends this to bob.
+	aliceNewCommits, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-// TestChanSyncOweRevocationAndCommit tests that if Alice initiates a state
-// transition with Bob and Bob sends both a RevokeAndAck and CommitSig message
-// but Alice doesn't receive them before the connection dies, then he'll
-// retransmit them both.
-func TestChanSyncOweRevocationAndCommit(t *testing.T) {
-	t.Parallel()
+	// At the same time, Bob signs a commitment.
+	bobNewCommits, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign alice's commitment")
 
-	t.Run("tweakless", func(t *testing.T) {
-		testChanSyncOweRevocationAndCommit(
-			t, channeldb.SingleFunderTweaklessBit,
-		)
-	})
-	t.Run("taproot", func(t *testing.T) {
-		taprootBits := channeldb.SimpleTaprootFeatureBit |
-			channeldb.AnchorOutputsBit |
-			channeldb.ZeroHtlcTxFeeBit |
-			channeldb.SingleFunderTweaklessBit
+	// ...that Alice receives.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommits.CommitSigs)
+	require.NoError(t, err, "alice unable to process bob's new commitment")
 
-		testChanSyncOweRevocationAndCommit(t, taprootBits)
-	})
+	// Now let Bob receive the fee update + commitment that Alice sent.
+	if err := bobChannel.ReceiveUpdateFee(fee); err != nil {
+		t.Fatalf("unable to receive fee update")
+	}
+
+	// Bob receives this signature message, and verifies that it is
+	// consistent with the state he had for Alice, including the received
+	// HTLC and fee update.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's new commitment")
+
+	if chainfee.SatPerKWeight(bobChannel.channelState.LocalCommitment.FeePerKw) == fee {
+		t.Fatalf("bob's feePerKw was unexpectedly locked in")
+	}
+
+	// Bob can revoke the prior commitment he had. This should lock in the
+	// fee update for him.
+	_, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to generate bob revocation")
+
+	if chainfee.SatPerKWeight(bobChannel.channelState.LocalCommitment.FeePerKw) != fee {
+		t.Fatalf("bob's feePerKw was not locked in")
+	}
 }
 
-func testChanSyncOweRevocationAndCommitForceTransition(t *testing.T,
-	chanType channeldb.ChannelType) {
+// TestUpdateFeeSenderCommits verifies that the state machine progresses as
+// expected if we send a fee update, and then the sender of the fee update
+// sends a commitment signature.
+func TestUpdateFeeSenderCommits(t *testing.T) {
+	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
 	require.NoError(t, err, "unable to create test channels")
 
-	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
-
-	// We'll kick off the test by having Bob send Alice an HTLC, then lock
-	// it in with a state transition.
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	rHash := sha256.Sum256(bobPreimage[:])
-	var bobHtlc [2]*lnwire.UpdateAddHTLC
-	bobHtlc[0] = &lnwire.UpdateAddHTLC{
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
-	}
-	bobHtlcIndex, err := bobChannel.AddHTLC(bobHtlc[0], nil)
-	require.NoError(t, err, "unable to add bob's htlc")
-	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(bobHtlc[0])
-	require.NoError(t, err, "unable to recv bob's htlc")
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
+	paymentPreimage := bytes.Repeat([]byte{1}, 32)
+	paymentHash := sha256.Sum256(paymentPreimage)
+	htlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: paymentHash,
+		Amount:      btcutil.SatoshiPerBitcoin,
+		Expiry:      uint32(5),
 	}
 
-	// To ensure the channel sync logic handles the case where the two
-	// commit chains are at different heights, we'll add another HTLC from
-	// Bob to Alice, but let Alice skip the commitment for this state
-	// update.
-	rHash = sha256.Sum256(bytes.Repeat([]byte{0xbb}, 32))
-	bobHtlc[1] = &lnwire.UpdateAddHTLC{
-		PaymentHash: rHash,
-		Amount:      htlcAmt,
-		Expiry:      uint32(10),
-		ID:          1,
+	// First Alice adds the outgoing HTLC to her local channel's state
+	// update log. Then Alice sends this wire message over to Bob who
+	// adds this htlc to his remote state update log.
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
 	}
-	_, err = bobChannel.AddHTLC(bobHtlc[1], nil)
-	require.NoError(t, err, "unable to add bob's htlc")
-	_, err = aliceChannel.ReceiveHTLC(bobHtlc[1])
-	require.NoError(t, err, "unable to recv bob's htlc")
 
-	// Bob signs the new state update, and sends the signature to Alice.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign commitment")
+	// Simulate Alice sending update fee message to bob.
+	fee := chainfee.SatPerKWeight(333)
+	aliceChannel.UpdateFee(fee)
+	bobChannel.ReceiveUpdateFee(fee)
 
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to rev bob's commitment")
+	// Alice signs a commitment, which will cover everything sent to Bob
+	// (the HTLC and the fee update), and everything acked by Bob (nothing
+	// so far).
+	aliceNewCommits, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-	// Alice revokes her current state, but doesn't immediately send a
-	// signature for Bob's updated state. Instead she will issue a new
-	// update before sending a new CommitSig. This will lead to Alice's
-	// local commit chain getting height > remote commit chain.
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "alice unable to revoke commitment")
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to recv revocation")
+	// Bob receives this signature message, and verifies that it is
+	// consistent with the state he had for Alice, including the received
+	// HTLC and fee update.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's new commitment")
 
-	// Next, Alice will settle that incoming HTLC, then we'll start the
-	// core of the test itself.
-	err = aliceChannel.SettleHTLC(bobPreimage, aliceHtlcIndex, nil, nil, nil)
-	require.NoError(t, err, "unable to settle htlc")
-	err = bobChannel.ReceiveHTLCSettle(bobPreimage, bobHtlcIndex)
-	require.NoError(t, err, "unable to settle htlc")
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) == fee {
 
-	// Progressing the exchange: Alice will send her signature, with Bob
-	// processing the new state locally.
-	aliceNewCommits, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's commitment")
+		t.Fatalf("bob's feePerKw was unexpectedly locked in")
+	}
 
-	// Bob then sends his revocation message, but before Alice can process
-	// it (and before he scan send his CommitSig message), then connection
-	// dies.
+	// Bob can revoke the prior commitment he had. This should lock in the
+	// fee update for him.
 	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke bob commitment")
+	require.NoError(t, err, "unable to generate bob revocation")
 
-	// Now if we attempt to synchronize states at this point, Alice should
-	// detect that she owes nothing, while Bob should re-send both his
-	// RevokeAndAck as well as his commitment message.
-	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) != fee {
 
-	// For taproot channels, simulate the link/peer binding the generated
-	// nonces.
-	if chanType.IsTaproot() {
-		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *aliceSyncMsg.LocalNonce,
-		}
-		bobChannel.pendingVerificationNonce = &musig2.Nonces{
-			PubNonce: *bobSyncMsg.LocalNonce,
-		}
+		t.Fatalf("bob's feePerKw was not locked in")
 	}
 
-	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(aliceMsgsToSend) != 0 {
-		t.Fatalf("expected alice to not retransmit, instead she's "+
-			"sending: %v", spew.Sdump(aliceMsgsToSend))
-	}
+	// Bob commits to all updates he has received from Alice. This includes
+	// the HTLC he received, and the fee update.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign alice's commitment")
 
-	// If we process Alice's sync message from Bob's PoV, then he should
-	// send his RevokeAndAck message again. Additionally, the CommitSig
-	// message that he sends should be sufficient to finalize the state
-	// transition.
-	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(bobMsgsToSend) != 2 {
-		t.Fatalf("expected bob to send %v messages, instead "+
-			"sends: %v", 2, spew.Sdump(bobMsgsToSend))
-	}
-	bobReRevoke, ok := bobMsgsToSend[0].(*lnwire.RevokeAndAck)
-	if !ok {
-		t.Fatalf("expected bob to re-send revoke, instead sending: %v",
-			spew.Sdump(bobMsgsToSend[0]))
-	}
-	if !reflect.DeepEqual(bobReRevoke, bobRevocation) {
-		t.Fatalf("revocation msgs don't match: expected %v, got %v",
-			bobRevocation, bobReRevoke)
-	}
+	// Alice receives the revocation of the old one, and can now assume
+	// that Bob's received everything up to the signature she sent,
+	// including the HTLC and fee update.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to process bob's revocation")
 
-	// The second message should be his CommitSig message that he never
-	// sent, but will send in order to force both states to synchronize.
-	bobReCommitSigMsg, ok := bobMsgsToSend[1].(*lnwire.CommitSig)
-	if !ok {
-		t.Fatalf("expected bob to re-send commit sig, instead sending: %v",
-			spew.Sdump(bobMsgsToSend[1]))
-	}
+	// Alice receives new signature from Bob, and assumes this covers the
+	// changes.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to process bob's new commitment")
 
-	// At this point we simulate the connection failing with a restart from
-	// Bob. He should still re-send the exact same set of messages.
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart channel")
-	if len(bobMsgsToSend) != 2 {
-		t.Fatalf("expected bob to send %v messages, instead "+
-			"sends: %v", 2, spew.Sdump(bobMsgsToSend))
-	}
-	bobReRevoke, ok = bobMsgsToSend[0].(*lnwire.RevokeAndAck)
-	if !ok {
-		t.Fatalf("expected bob to re-send revoke, instead sending: %v",
-			spew.Sdump(bobMsgsToSend[0]))
-	}
-	bobSigMsg, ok := bobMsgsToSend[1].(*lnwire.CommitSig)
-	if !ok {
-		t.Fatalf("expected bob to re-send commit sig, instead sending: %v",
-			spew.Sdump(bobMsgsToSend[1]))
-	}
-	if !reflect.DeepEqual(bobReRevoke, bobRevocation) {
-		t.Fatalf("revocation msgs don't match: expected %v, got %v",
-			bobRevocation, bobReRevoke)
-	}
-	if bobReCommitSigMsg.CommitSig != bobSigMsg.CommitSig {
-		t.Fatalf("commit sig msgs don't match: expected %x got %x",
-			bobSigMsg.CommitSig,
-			bobReCommitSigMsg.CommitSig)
-	}
-	if len(bobReCommitSigMsg.HtlcSigs) != len(bobSigMsg.HtlcSigs) {
-		t.Fatalf("wrong number of htlc sigs: expected %v, got %v",
-			len(bobSigMsg.HtlcSigs), len(bobReCommitSigMsg.HtlcSigs))
-	}
-	for i, htlcSig := range bobReCommitSigMsg.HtlcSigs {
-		if htlcSig != bobSigMsg.HtlcSigs[i] {
-			t.Fatalf("htlc sig msgs don't match: "+
-				"expected %x got %x",
-				bobSigMsg.HtlcSigs[i], htlcSig)
-		}
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) == fee {
+
+		t.Fatalf("alice's feePerKw was unexpectedly locked in")
 	}
 
-	// If this is a taproot channel, then we'll also have Bob generate their
-	// current nonce, and also process Alice's.
-	if chanType.IsTaproot() {
-		_, err = bobChannel.GenMusigNonces()
-		require.NoError(t, err)
+	// Alice can revoke the old commitment, which will lock in the fee
+	// update.
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke alice channel")
 
-		aliceNonces, err := aliceChannel.GenMusigNonces()
-		require.NoError(t, err)
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) != fee {
 
-		err = bobChannel.InitRemoteMusigNonces(aliceNonces)
-		require.NoError(t, err)
+		t.Fatalf("alice's feePerKw was not locked in")
 	}
 
-	// Now, we'll continue the exchange, sending Bob's revocation and
-	// signature message to Alice, ending with Alice sending her revocation
-	// message to Bob.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to recv revocation")
-	err = aliceChannel.ReceiveNewCommitment(&CommitSigs{
-		CommitSig:  bobSigMsg.CommitSig,
-		HtlcSigs:   bobSigMsg.HtlcSigs,
-		PartialSig: bobSigMsg.PartialSig,
-	})
-	require.NoError(t, err, "alice unable to rev bob's commitment")
-	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "alice unable to revoke commitment")
+	// Bob receives revocation from Alice.
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to recv revocation")
-}
-
-// TestChanSyncOweRevocationAndCommitForceTransition tests that if Alice
-// initiates a state transition with Bob, but Alice fails to receive his
-// RevokeAndAck and the connection dies before Bob sends his CommitSig message,
-// then Bob will re-send her RevokeAndAck message. Bob will also send and
-// _identical_ CommitSig as he detects his commitment chain is ahead of
-// Alice's.
-func TestChanSyncOweRevocationAndCommitForceTransition(t *testing.T) {
-	t.Parallel()
-
-	t.Run("tweakless", func(t *testing.T) {
-		testChanSyncOweRevocationAndCommitForceTransition(
-			t, channeldb.SingleFunderTweaklessBit,
-		)
-	})
-	t.Run("taproot", func(t *testing.T) {
-		taprootBits := channeldb.SimpleTaprootFeatureBit |
-			channeldb.AnchorOutputsBit |
-			channeldb.ZeroHtlcTxFeeBit |
-			channeldb.SingleFunderTweaklessBit
+	require.NoError(t, err, "bob unable to process alice's revocation")
 
-		testChanSyncOweRevocationAndCommitForceTransition(
-			t, taprootBits,
-		)
-	})
 }
 
-// TestChanSyncFailure tests the various scenarios during channel sync where we
-// should be able to detect that the channels cannot be synced because of
-// invalid state.
-func TestChanSyncFailure(t *testing.T) {
+// TestUpdateFeeReceiverCommits tests that the state machine progresses as
+// expected if we send a fee update, and then the receiver of the fee update
+// sends a commitment signature.
+func TestUpdateFeeReceiverCommits(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderBit,
+		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
-	index := byte(0)
+	paymentPreimage := bytes.Repeat([]byte{1}, 32)
+	paymentHash := sha256.Sum256(paymentPreimage)
+	htlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: paymentHash,
+		Amount:      btcutil.SatoshiPerBitcoin,
+		Expiry:      uint32(5),
+	}
 
-	// advanceState is a helper method to fully advance the channel state
-	// by one.
-	advanceState := func() {
-		t.Helper()
+	// First Alice adds the outgoing HTLC to her local channel's state
+	// update log. Then Alice sends this wire message over to Bob who
+	// adds this htlc to his remote state update log.
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
 
-		// We'll kick off the test by having Bob send Alice an HTLC,
-		// then lock it in with a state transition.
-		var bobPreimage [32]byte
-		copy(bobPreimage[:], bytes.Repeat([]byte{0xaa - index}, 32))
-		rHash := sha256.Sum256(bobPreimage[:])
-		bobHtlc := &lnwire.UpdateAddHTLC{
-			PaymentHash: rHash,
-			Amount:      htlcAmt,
-			Expiry:      uint32(10),
-			ID:          uint64(index),
-		}
-		index++
+	// Simulate Alice sending update fee message to bob
+	fee := chainfee.SatPerKWeight(333)
+	aliceChannel.UpdateFee(fee)
+	bobChannel.ReceiveUpdateFee(fee)
 
-		_, err := bobChannel.AddHTLC(bobHtlc, nil)
-		if err != nil {
-			t.Fatalf("unable to add bob's htlc: %v", err)
-		}
-		_, err = aliceChannel.ReceiveHTLC(bobHtlc)
-		if err != nil {
-			t.Fatalf("unable to recv bob's htlc: %v", err)
-		}
-		err = ForceStateTransition(bobChannel, aliceChannel)
-		if err != nil {
-			t.Fatalf("unable to complete bob's state "+
-				"transition: %v", err)
-		}
-	}
+	// Bob commits to every change he has sent since last time (none). He
+	// does not commit to the received HTLC and fee update, since Alice
+	// cannot know if he has received them.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-	// halfAdvance is a helper method that sends a new commitment signature
-	// from Alice to Bob, but doesn't make Bob revoke his current state.
-	halfAdvance := func() {
-		t.Helper()
+	// Alice receives this signature message, and verifies that it is
+	// consistent with the remote state, not including any of the updates.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's new commitment")
 
-		// We'll kick off the test by having Bob send Alice an HTLC,
-		// then lock it in with a state transition.
-		var bobPreimage [32]byte
-		copy(bobPreimage[:], bytes.Repeat([]byte{0xaa - index}, 32))
-		rHash := sha256.Sum256(bobPreimage[:])
-		bobHtlc := &lnwire.UpdateAddHTLC{
-			PaymentHash: rHash,
-			Amount:      htlcAmt,
-			Expiry:      uint32(10),
-			ID:          uint64(index),
-		}
-		index++
+	// Alice can revoke the prior commitment she had, this will ack
+	// everything received before last commitment signature, but in this
+	// case that is nothing.
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to generate bob revocation")
 
-		_, err := bobChannel.AddHTLC(bobHtlc, nil)
-		if err != nil {
-			t.Fatalf("unable to add bob's htlc: %v", err)
-		}
-		_, err = aliceChannel.ReceiveHTLC(bobHtlc)
-		if err != nil {
-			t.Fatalf("unable to recv bob's htlc: %v", err)
-		}
-
-		aliceNewCommit, err := aliceChannel.SignNextCommitment()
-		if err != nil {
-			t.Fatalf("unable to sign next commit: %v", err)
-		}
-		err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-		if err != nil {
-			t.Fatalf("unable to receive commit sig: %v", err)
-		}
-	}
-
-	// assertLocalDataLoss checks that aliceOld and bobChannel detects that
-	// Alice has lost state during sync.
-	assertLocalDataLoss := func(aliceOld *LightningChannel) {
-		t.Helper()
+	// Bob receives the revocation of the old commitment
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "alice unable to process bob's revocation")
 
-		aliceSyncMsg, err := aliceOld.channelState.ChanSyncMsg()
-		if err != nil {
-			t.Fatalf("unable to produce chan sync msg: %v", err)
-		}
-		bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-		if err != nil {
-			t.Fatalf("unable to produce chan sync msg: %v", err)
-		}
+	// Alice will sign next commitment. Since she sent the revocation, she
+	// also ack'ed everything received, but in this case this is nothing.
+	// Since she sent the two updates, this signature will cover those two.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign alice's commitment")
 
-		// Alice should detect from Bob's message that she lost state.
-		_, _, _, err = aliceOld.ProcessChanSyncMsg(bobSyncMsg)
-		if _, ok := err.(*ErrCommitSyncLocalDataLoss); !ok {
-			t.Fatalf("wrong error, expected "+
-				"ErrCommitSyncLocalDataLoss instead got: %v",
-				err)
-		}
+	// Bob gets the signature for the new commitment from Alice. He assumes
+	// this covers everything received from alice, including the two updates.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to process bob's new commitment")
 
-		// Bob should detect that Alice probably lost state.
-		_, _, _, err = bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
-		if err != ErrCommitSyncRemoteDataLoss {
-			t.Fatalf("wrong error, expected "+
-				"ErrCommitSyncRemoteDataLoss instead got: %v",
-				err)
-		}
-	}
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) == fee {
 
-	// clearBorkedState is a method that allows us to clear the borked
-	// state that will arise after the first chan message sync. We need to
-	// do this in order to be able to continue to update the commitment
-	// state for our test scenarios.
-	clearBorkedState := func() {
-		err = aliceChannel.channelState.ClearChanStatus(
-			channeldb.ChanStatusLocalDataLoss | channeldb.ChanStatusBorked,
-		)
-		if err != nil {
-			t.Fatalf("unable to update channel state: %v", err)
-		}
-		err = bobChannel.channelState.ClearChanStatus(
-			channeldb.ChanStatusLocalDataLoss | channeldb.ChanStatusBorked,
-		)
-		if err != nil {
-			t.Fatalf("unable to update channel state: %v", err)
-		}
+		t.Fatalf("bob's feePerKw was unexpectedly locked in")
 	}
 
-	// Start by advancing the state.
-	advanceState()
-
-	// They should be in sync.
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
-
-	// Make a copy of Alice's state from the database at this point.
-	aliceOld, err := restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart channel")
+	// Bob can revoke the old commitment. This will ack what he has
+	// received, including the HTLC and fee update. This will lock in the
+	// fee update for bob.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke alice channel")
 
-	// Advance the states.
-	advanceState()
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) != fee {
 
-	// Trying to sync up the old version of Alice's channel should detect
-	// that we are out of sync.
-	assertLocalDataLoss(aliceOld)
+		t.Fatalf("bob's feePerKw was not locked in")
+	}
 
-	// Make sure the up-to-date channels still are in sync.
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
+	// Bob will send a new signature, which will cover what he just acked:
+	// the HTLC and fee update.
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-	// Clear the borked state before we attempt to advance.
-	clearBorkedState()
+	// Alice receives revocation from Bob, and can now be sure that Bob
+	// received the two updates, and they are considered locked in.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "bob unable to process alice's revocation")
 
-	// Advance the state again, and do the same check.
-	advanceState()
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
-	assertLocalDataLoss(aliceOld)
+	// Alice will receive the signature from Bob, which will cover what was
+	// just acked by his revocation.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to process bob's new commitment")
 
-	// If we remove the recovery options from Bob's message, Alice cannot
-	// tell if she lost state, since Bob might be lying. She still should
-	// be able to detect that chains cannot be synced.
-	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg.LocalUnrevokedCommitPoint = nil
-	_, _, _, err = aliceOld.ProcessChanSyncMsg(bobSyncMsg)
-	if err != ErrCannotSyncCommitChains {
-		t.Fatalf("wrong error, expected ErrCannotSyncCommitChains "+
-			"instead got: %v", err)
-	}
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) == fee {
 
-	// If Bob lies about the NextLocalCommitHeight, making it greater than
-	// what Alice expect, she cannot tell for sure whether she lost state,
-	// but should detect the desync.
-	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg.NextLocalCommitHeight++
-	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
-	if err != ErrCannotSyncCommitChains {
-		t.Fatalf("wrong error, expected ErrCannotSyncCommitChains "+
-			"instead got: %v", err)
+		t.Fatalf("alice's feePerKw was unexpectedly locked in")
 	}
 
-	// If Bob's NextLocalCommitHeight is lower than what Alice expects, Bob
-	// probably lost state.
-	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg.NextLocalCommitHeight--
-	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
-	if err != ErrCommitSyncRemoteDataLoss {
-		t.Fatalf("wrong error, expected ErrCommitSyncRemoteDataLoss "+
-			"instead got: %v", err)
-	}
+	// After Alice now revokes her old commitment, the fee update should
+	// lock in.
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to generate bob revocation")
 
-	// If Alice and Bob's states are in sync, but Bob is sending the wrong
-	// LocalUnrevokedCommitPoint, Alice should detect this.
-	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	p := bobSyncMsg.LocalUnrevokedCommitPoint.SerializeCompressed()
-	p[4] ^= 0x01
-	modCommitPoint, err := btcec.ParsePubKey(p)
-	require.NoError(t, err, "unable to parse pubkey")
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) != fee {
 
-	bobSyncMsg.LocalUnrevokedCommitPoint = modCommitPoint
-	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
-	if err != ErrInvalidLocalUnrevokedCommitPoint {
-		t.Fatalf("wrong error, expected "+
-			"ErrInvalidLocalUnrevokedCommitPoint instead got: %v",
-			err)
+		t.Fatalf("Alice's feePerKw was not locked in")
 	}
 
-	// Make sure the up-to-date channels still are good.
-	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
-
-	// Clear the borked state before we attempt to advance.
-	clearBorkedState()
-
-	// Finally check that Alice is also able to detect a wrong commit point
-	// when there's a pending remote commit.
-	halfAdvance()
-
-	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg.LocalUnrevokedCommitPoint = modCommitPoint
-	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
-	if err != ErrInvalidLocalUnrevokedCommitPoint {
-		t.Fatalf("wrong error, expected "+
-			"ErrInvalidLocalUnrevokedCommitPoint instead got: %v",
-			err)
-	}
+	// Bob receives revocation from Alice.
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to process alice's revocation")
 }
 
-// TestFeeUpdateRejectInsaneFee tests that if the initiator tries to attach a
-// fee that would put them below their current reserve, then it's rejected by
-// the state machine.
-func TestFeeUpdateRejectInsaneFee(t *testing.T) {
+// TestUpdateFeeReceiverSendsUpdate tests that receiving a fee update as channel
+// initiator fails, and that trying to initiate fee update as non-initiation
+// fails.
+func TestUpdateFeeReceiverSendsUpdate(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
-	aliceChannel, _, err := CreateTestChannels(
+	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Next, we'll try to add a fee rate to Alice which is 1,000,000x her
-	// starting fee rate.
-	startingFeeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	)
-	newFeeRate := startingFeeRate * 1000000
+	// Since Alice is the channel initiator, she should fail when receiving
+	// fee update
+	fee := chainfee.SatPerKWeight(333)
+	err = aliceChannel.ReceiveUpdateFee(fee)
+	if err == nil {
+		t.Fatalf("expected alice to fail receiving fee update")
+	}
 
-	// Both Alice and Bob should reject this new fee rate as it is far too
-	// large.
-	if err := aliceChannel.UpdateFee(newFeeRate); err == nil {
-		t.Fatalf("alice should have rejected fee update")
+	// Similarly, initiating fee update should fail for Bob.
+	err = bobChannel.UpdateFee(fee)
+	if err == nil {
+		t.Fatalf("expected bob to fail initiating fee update")
 	}
 }
 
-// TestChannelRetransmissionFeeUpdate tests that the initiator will include any
-// pending fee updates if it needs to retransmit signatures.
-func TestChannelRetransmissionFeeUpdate(t *testing.T) {
+// Test that if multiple update fee messages are sent consecutively, then the
+// last one is the one that is being committed to.
+func TestUpdateFeeMultipleUpdates(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
@@ -4301,426 +4338,408 @@ func TestChannelRetransmissionFeeUpdate(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll fetch the current fee rate present within the
-	// commitment transactions.
-	startingFeeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	)
+	// Simulate Alice sending update fee message to bob.
+	fee1 := chainfee.SatPerKWeight(333)
+	fee2 := chainfee.SatPerKWeight(333)
+	fee := chainfee.SatPerKWeight(333)
+	aliceChannel.UpdateFee(fee1)
+	aliceChannel.UpdateFee(fee2)
+	aliceChannel.UpdateFee(fee)
 
-	// Next, we'll start a commitment update, with Alice sending a new
-	// update to double the fee rate of the commitment.
-	newFeeRate := startingFeeRate * 2
-	if err := aliceChannel.UpdateFee(newFeeRate); err != nil {
-		t.Fatalf("unable to update fee for Alice's channel: %v", err)
-	}
-	if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
-		t.Fatalf("unable to update fee for Bob's channel: %v", err)
-	}
-
-	// Now, Alice will send a new commitment to Bob, but we'll simulate a
-	// connection failure, so Bob doesn't get her signature.
+	// Alice signs a commitment, which will cover everything sent to Bob
+	// (the HTLC and the fee update), and everything acked by Bob (nothing
+	// so far).
 	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
+	require.NoError(t, err, "alice unable to sign commitment")
 
-	// Restart both channels to simulate a connection restart.
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart alice")
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart channel")
+	bobChannel.ReceiveUpdateFee(fee1)
+	bobChannel.ReceiveUpdateFee(fee2)
+	bobChannel.ReceiveUpdateFee(fee)
 
-	// Bob doesn't get this message so upon reconnection, they need to
-	// synchronize. Alice should conclude that she owes Bob a commitment,
-	// while Bob should think he's properly synchronized.
-	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
-	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to produce chan sync msg")
+	// Bob receives this signature message, and verifies that it is
+	// consistent with the state he had for Alice, including the received
+	// HTLC and fee update.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's new commitment")
 
-	// Bob should detect that he doesn't need to send anything to Alice.
-	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(bobMsgsToSend) != 0 {
-		t.Fatalf("expected bob to send %v messages instead "+
-			"will send %v: %v", 0, len(bobMsgsToSend),
-			spew.Sdump(bobMsgsToSend))
-	}
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) == fee {
 
-	// When Alice processes Bob's chan sync message, she should realize
-	// that she needs to first send a new UpdateFee message, and also a
-	// CommitSig.
-	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(
-		bobSyncMsg,
-	)
-	require.NoError(t, err, "unable to process chan sync msg")
-	if len(aliceMsgsToSend) != 2 {
-		t.Fatalf("expected alice to send %v messages instead "+
-			"will send %v: %v", 2, len(aliceMsgsToSend),
-			spew.Sdump(aliceMsgsToSend))
+		t.Fatalf("bob's feePerKw was unexpectedly locked in")
 	}
 
-	// The first message should be an UpdateFee message.
-	retransFeeMsg, ok := aliceMsgsToSend[0].(*lnwire.UpdateFee)
-	if !ok {
-		t.Fatalf("expected UpdateFee message, instead have: %v",
-			spew.Sdump(aliceMsgsToSend[0]))
-	}
+	// Alice sending more fee updates now should not mess up the old fee
+	// they both committed to.
+	fee3 := chainfee.SatPerKWeight(444)
+	fee4 := chainfee.SatPerKWeight(555)
+	fee5 := chainfee.SatPerKWeight(666)
+	aliceChannel.UpdateFee(fee3)
+	aliceChannel.UpdateFee(fee4)
+	aliceChannel.UpdateFee(fee5)
+	bobChannel.ReceiveUpdateFee(fee3)
+	bobChannel.ReceiveUpdateFee(fee4)
+	bobChannel.ReceiveUpdateFee(fee5)
 
-	// The fee should match exactly the new fee update we applied above.
-	if retransFeeMsg.FeePerKw != uint32(newFeeRate) {
-		t.Fatalf("fee update doesn't match: expected %v, got %v",
-			uint32(newFeeRate), retransFeeMsg)
-	}
+	// Bob can revoke the prior commitment he had. This should lock in the
+	// fee update for him.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to generate bob revocation")
 
-	// The second, should be a CommitSig message, and be identical to the
-	// sig message she sent prior.
-	commitSigMsg, ok := aliceMsgsToSend[1].(*lnwire.CommitSig)
-	if !ok {
-		t.Fatalf("expected a CommitSig message, instead have %v",
-			spew.Sdump(aliceMsgsToSend[1]))
-	}
-	if commitSigMsg.CommitSig != aliceNewCommit.CommitSig {
-		t.Fatalf("commit sig msgs don't match: expected %x got %x",
-			aliceNewCommit.CommitSig, commitSigMsg.CommitSig)
-	}
-	if len(commitSigMsg.HtlcSigs) != len(aliceNewCommit.HtlcSigs) {
-		t.Fatalf("wrong number of htlc sigs: expected %v, got %v",
-			len(aliceNewCommit.HtlcSigs),
-			len(commitSigMsg.HtlcSigs))
-	}
-	for i, htlcSig := range commitSigMsg.HtlcSigs {
-		if htlcSig != aliceNewCommit.HtlcSigs[i] {
-			t.Fatalf("htlc sig msgs don't match: "+
-				"expected %x got %x",
-				aliceNewCommit.HtlcSigs[i], htlcSig)
-		}
-	}
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) != fee {
 
-	// Now, we if re-apply the updates to Bob, we should be able to resume
-	// the commitment update as normal.
-	if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
-		t.Fatalf("unable to update fee for Bob's channel: %v", err)
+		t.Fatalf("bob's feePerKw was not locked in")
 	}
 
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "bob unable to process alice's commitment")
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke bob commitment")
+	// Bob commits to all updates he has received from Alice. This includes
+	// the HTLC he received, and the fee update.
 	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "bob unable to sign commitment")
+	require.NoError(t, err, "bob unable to sign alice's commitment")
+
+	// Alice receives the revocation of the old one, and can now assume that
+	// Bob's received everything up to the signature she sent, including the
+	// HTLC and fee update.
 	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "alice unable to recv revocation")
+	require.NoError(t, err, "alice unable to process bob's revocation")
+
+	// Alice receives new signature from Bob, and assumes this covers the
+	// changes.
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "alice unable to rev bob's commitment")
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "alice unable to revoke commitment")
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to recv revocation")
+	if err != nil {
+		t.Fatalf("alice unable to process bob's new commitment: %v", err)
+	}
 
-	// Both parties should now have the latest fee rate locked-in.
 	if chainfee.SatPerKWeight(
 		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) != newFeeRate {
+	) == fee {
 
-		t.Fatalf("alice's feePerKw was not locked in")
+		t.Fatalf("alice's feePerKw was unexpectedly locked in")
 	}
+
+	// Alice can revoke the old commitment, which will lock in the fee
+	// update.
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke alice channel")
+
 	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) != newFeeRate {
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) != fee {
 
-		t.Fatalf("bob's feePerKw was not locked in")
+		t.Fatalf("alice's feePerKw was not locked in")
 	}
 
-	// Finally, we'll add with adding a new HTLC, then forcing a state
-	// transition. This should also proceed as normal.
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
-	rHash := sha256.Sum256(bobPreimage[:])
-	bobHtlc := &lnwire.UpdateAddHTLC{
-		PaymentHash: rHash,
-		Amount:      lnwire.NewMSatFromSatoshis(20000),
-		Expiry:      uint32(10),
-	}
-	if _, err := bobChannel.AddHTLC(bobHtlc, nil); err != nil {
-		t.Fatalf("unable to add bob's htlc: %v", err)
-	}
-	if _, err := aliceChannel.ReceiveHTLC(bobHtlc); err != nil {
-		t.Fatalf("unable to recv bob's htlc: %v", err)
-	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
-	}
+	// Bob receives revocation from Alice.
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to process alice's revocation")
 }
 
-// TestFeeUpdateOldDiskFormat tests that we properly recover FeeUpdates written
-// to disk using the old format, where the logIndex was not written.
-func TestFeeUpdateOldDiskFormat(t *testing.T) {
+// TestUpdateFeeCoalescing asserts that fee updates proposed before signing a
+// commitment are coalesced, such that only a single fee update carrying the
+// latest rate is committed.
+func TestUpdateFeeCoalescing(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// helper that counts the number of updates, and number of fee updates
-	// in the given log.
-	countLog := func(log *updateLog) (int, int) {
-		var numUpdates, numFee int
+	// feeUpdates returns the fee updates in Alice's local log.
+	feeUpdates := func() []*PaymentDescriptor {
+		var (
+			fees []*PaymentDescriptor
+			log  = aliceChannel.localUpdateLog
+		)
 		for e := log.Front(); e != nil; e = e.Next() {
-			htlc := e.Value.(*PaymentDescriptor)
-			if htlc.EntryType == FeeUpdate {
-				numFee++
+			pd := e.Value.(*PaymentDescriptor)
+			if pd.EntryType == FeeUpdate {
+				fees = append(fees, pd)
 			}
-			numUpdates++
 		}
-		return numUpdates, numFee
-	}
 
-	// helper that asserts that Alice's local log and Bob's remote log
-	// contains the expected number of fee updates and adds.
-	assertLogItems := func(expFee, expAdd int) {
-		t.Helper()
+		return fees
+	}
 
-		expUpd := expFee + expAdd
-		upd, fees := countLog(aliceChannel.localUpdateLog)
-		if upd != expUpd {
-			t.Fatalf("expected %d updates, found %d in Alice's "+
-				"log", expUpd, upd)
-		}
-		if fees != expFee {
-			t.Fatalf("expected %d fee updates, found %d in "+
-				"Alice's log", expFee, fees)
-		}
-		upd, fees = countLog(bobChannel.remoteUpdateLog)
-		if upd != expUpd {
-			t.Fatalf("expected %d updates, found %d in Bob's log",
-				expUpd, upd)
-		}
-		if fees != expFee {
-			t.Fatalf("expected %d fee updates, found %d in Bob's "+
-				"log", expFee, fees)
-		}
+	// Alice proposes three fee updates before signing, which Bob receives
+	// in order.
+	feeRates := []chainfee.SatPerKWeight{333, 444, 555}
+	for _, fee := range feeRates {
+		require.NoError(t, aliceChannel.UpdateFee(fee))
+		require.NoError(t, bobChannel.ReceiveUpdateFee(fee))
 	}
+	finalFee := feeRates[len(feeRates)-1]
 
-	// First, we'll fetch the current fee rate present within the
-	// commitment transactions.
-	startingFeeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	)
-	newFeeRate := startingFeeRate
+	// Alice's log only holds a single fee update with the final rate.
+	fees := feeUpdates()
+	require.Len(t, fees, 1)
+	require.Equal(t, finalFee, chainfee.SatPerKWeight(
+		fees[0].Amount.ToSatoshis(),
+	))
 
-	// We will send a few HTLCs and a fee update.
-	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
-	const numHTLCs = 30
-	var htlcs []*lnwire.UpdateAddHTLC
-	for i := 0; i < numHTLCs; i++ {
-		htlc, _ := createHTLC(i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
-		htlcs = append(htlcs, htlc)
+	// Once Alice signs, the commitment diff she stores for
+	// retransmission contains a single UpdateFee with the final rate.
+	aliceNewCommits, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
 
-		if i%5 != 0 {
-			continue
-		}
+	commitDiff, err := aliceChannel.channelState.RemoteCommitChainTip()
+	require.NoError(t, err)
 
-		// After every 5th HTLC, we'll also include a fee update.
-		newFeeRate += startingFeeRate
-		if err := aliceChannel.UpdateFee(newFeeRate); err != nil {
-			t.Fatalf("unable to update fee for Alice's channel: %v",
-				err)
-		}
-		if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
-			t.Fatalf("unable to update fee for Bob's channel: %v",
-				err)
+	var updateFees []*lnwire.UpdateFee
+	for _, update := range commitDiff.LogUpdates {
+		if msg, ok := update.UpdateMsg.(*lnwire.UpdateFee); ok {
+			updateFees = append(updateFees, msg)
 		}
 	}
-	// Check that the expected number of items is found in the logs.
-	expFee := numHTLCs / 5
-	assertLogItems(expFee, numHTLCs)
+	require.Len(t, updateFees, 1)
+	require.EqualValues(t, finalFee, updateFees[0].FeePerKw)
 
-	// Now, Alice will send a new commitment to Bob, but we'll simulate a
-	// connection failure, so Bob doesn't get the signature.
-	aliceNewCommitSig, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
+	// A fee update proposed after signing can't modify the signed one,
+	// so it's added to the log as a new update.
+	require.NoError(t, aliceChannel.UpdateFee(finalFee+100))
+	fees = feeUpdates()
+	require.Len(t, fees, 2)
+	require.Equal(t, finalFee, chainfee.SatPerKWeight(
+		fees[0].Amount.ToSatoshis(),
+	))
 
-	// Before restarting Alice, to mimic the old format, we fetch the
-	// pending remote commit from disk, set the UpdateFee message's
-	// logIndex to 0, and re-write it.
-	pendingRemoteCommitDiff, err := aliceChannel.channelState.RemoteCommitChainTip()
-	if err != nil {
-		t.Fatal(err)
-	}
-	for i, u := range pendingRemoteCommitDiff.LogUpdates {
-		switch u.UpdateMsg.(type) {
-		case *lnwire.UpdateFee:
-			pendingRemoteCommitDiff.LogUpdates[i].LogIndex = 0
-		}
-	}
-	err = aliceChannel.channelState.AppendRemoteCommitChain(
-		pendingRemoteCommitDiff,
-	)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Restart both channels to simulate a connection restart. This will
-	// trigger a update logs restoration.
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart alice")
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart channel")
-
-	// After a reconnection, Alice will resend the pending updates, that
-	// was not ACKed by Bob, so we re-send the HTLCs and fee updates.
-	newFeeRate = startingFeeRate
-	for i := 0; i < numHTLCs; i++ {
-		htlc := htlcs[i]
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
-
-		if i%5 != 0 {
-			continue
-		}
-
-		newFeeRate += startingFeeRate
-		if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
-			t.Fatalf("unable to update fee for Bob's channel: %v",
-				err)
-		}
-	}
-	assertLogItems(expFee, numHTLCs)
-
-	// We send Alice's commitment signatures, and finish the state
-	// transition.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommitSig.CommitSigs)
+	// Complete the state transition, after which both parties have the
+	// final rate of the signed commitment locked in.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
 	require.NoError(t, err, "bob unable to process alice's commitment")
 	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
 	require.NoError(t, err, "unable to revoke bob commitment")
-	bobNewCommitSigs, err := bobChannel.SignNextCommitment()
+	bobNewCommits, err := bobChannel.SignNextCommitment()
 	require.NoError(t, err, "bob unable to sign commitment")
 	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
 	require.NoError(t, err, "alice unable to recv revocation")
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommitSigs.CommitSigs)
-	require.NoError(t, err, "alice unable to rev bob's commitment")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommits.CommitSigs)
+	require.NoError(t, err, "alice unable to process bob's commitment")
 	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
 	require.NoError(t, err, "alice unable to revoke commitment")
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
 	require.NoError(t, err, "bob unable to recv revocation")
 
-	// Both parties should now have the latest fee rate locked-in.
-	if chainfee.SatPerKWeight(
+	require.EqualValues(
+		t, finalFee, aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	require.EqualValues(
+		t, finalFee, bobChannel.channelState.LocalCommitment.FeePerKw,
+	)
+}
+
+// TestFeeRateHistory tests that each committed fee rate change is recorded in
+// the fee rate history of both parties.
+func TestFeeRateHistory(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Before any updates, there shouldn't be any history.
+	history, err := aliceChannel.FeeRateHistory()
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	initialFee := btcutil.Amount(
 		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) != newFeeRate {
+	)
+	fees := []chainfee.SatPerKWeight{4000, 5000, 3000}
 
-		t.Fatalf("alice's feePerKw was not locked in")
-	}
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) != newFeeRate {
+	var expected []channeldb.FeeRateUpdate
+	oldFee := initialFee
+	for _, fee := range fees {
+		require.NoError(t, aliceChannel.UpdateFee(fee))
+		require.NoError(t, bobChannel.ReceiveUpdateFee(fee))
+		require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-		t.Fatalf("bob's feePerKw was not locked in")
+		expected = append(expected, channeldb.FeeRateUpdate{
+			Height:      aliceChannel.currentHeight,
+			OldFeePerKw: oldFee,
+			NewFeePerKw: btcutil.Amount(fee),
+		})
+		oldFee = btcutil.Amount(fee)
 	}
 
-	// Finally, to trigger a compactLogs execution, we'll add a new HTLC,
-	// then force a state transition.
-	htlc, _ := createHTLC(numHTLCs, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
-	}
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
-	}
+	// A state transition that doesn't change the fee rate shouldn't be
+	// recorded.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(10_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Finally, check the logs to make sure all fee updates have been
-	// removed...
-	assertLogItems(0, numHTLCs+1)
+	history, err = aliceChannel.FeeRateHistory()
+	require.NoError(t, err)
+	require.Equal(t, expected, history)
 
-	// ...and the final fee rate locked in.
-	if chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	) != newFeeRate {
+	history, err = bobChannel.FeeRateHistory()
+	require.NoError(t, err)
+	require.Equal(t, expected, history)
+}
 
-		t.Fatalf("alice's feePerKw was not locked in")
+// TestChannelEventLog asserts that the significant events of a channel are
+// recorded in its event log in the order they happened.
+func TestChannelEventLog(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	events, err := aliceChannel.EventLog()
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	// Alice updates the fee, and signs the first state, which Bob revokes
+	// his prior state for. Once Alice revokes her prior state in turn, the
+	// new fee rate is locked into her commitment.
+	require.NoError(t, aliceChannel.UpdateFee(4000))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(4000))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Finally, Alice closes the channel.
+	closeTx := wire.NewMsgTx(2)
+	require.NoError(
+		t, aliceChannel.MarkCommitmentBroadcasted(closeTx, true),
+	)
+
+	events, err = aliceChannel.EventLog()
+	require.NoError(t, err)
+
+	type event struct {
+		eventType channeldb.ChannelEventType
+		height    uint64
 	}
-	if chainfee.SatPerKWeight(
-		bobChannel.channelState.LocalCommitment.FeePerKw,
-	) != newFeeRate {
+	expected := []event{
+		{channeldb.EventStateSigned, 1},
+		{channeldb.EventRevocationReceived, 0},
+		{channeldb.EventFeeUpdated, 1},
+		{channeldb.EventCloseInitiated, 1},
+	}
+	require.Len(t, events, len(expected))
+	for i, e := range events {
+		require.Equal(t, expected[i], event{e.Type, e.Height})
 
-		t.Fatalf("bob's feePerKw was not locked in")
+		if i > 0 {
+			prev := events[i-1].Timestamp
+			require.False(t, e.Timestamp.Before(prev))
+		}
 	}
 }
 
-// TestChanSyncUnableToSync tests that if Alice or Bob receive an invalid
-// ChannelReestablish messages,then they reject the message and declare the
-// channel un-continuable by returning ErrCannotSyncCommitChains.
-func TestChanSyncUnableToSync(t *testing.T) {
+// TestAddHTLCNegativeBalance tests that if enough HTLC's are added to the
+// state machine to drive the balance to zero, then the next HTLC attempted to
+// be added will result in an error being returned.
+func TestAddHTLCNegativeBalance(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderBit,
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// If we immediately send both sides a "bogus" ChanSync message, then
-	// they both should conclude that they're unable to synchronize the
-	// state.
-	badChanSync := &lnwire.ChannelReestablish{
-		ChanID: lnwire.NewChanIDFromOutPoint(
-			&aliceChannel.channelState.FundingOutpoint,
-		),
-		NextLocalCommitHeight:  1000,
-		RemoteCommitTailHeight: 9000,
+	// We set the channel reserve to 0, such that we can add HTLCs all the
+	// way to a negative balance.
+	aliceChannel.channelState.LocalChanCfg.ChanReserve = 0
+
+	// First, we'll add 3 HTLCs of 1 BTC each to Alice's commitment.
+	const numHTLCs = 3
+	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	for i := 0; i < numHTLCs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
 	}
-	_, _, _, err = bobChannel.ProcessChanSyncMsg(badChanSync)
-	if err != ErrCannotSyncCommitChains {
-		t.Fatalf("expected error instead have: %v", err)
+
+	// Alice now has an available balance of 2 BTC. We'll add a new HTLC of
+	// value 2 BTC, which should make Alice's balance negative (since she
+	// has to pay a commitment fee).
+	htlcAmt = lnwire.NewMSatFromSatoshis(2 * btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(numHTLCs+1, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+}
+
+// assertNoChanSyncNeeded is a helper function that asserts that upon restart,
+// two channels conclude that they're fully synchronized and don't need to
+// retransmit any new messages.
+func assertNoChanSyncNeeded(t *testing.T, aliceChannel *LightningChannel,
+	bobChannel *LightningChannel) {
+
+	_, _, line, _ := runtime.Caller(1)
+
+	aliceChanSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	if err != nil {
+		t.Fatalf("line #%v: unable to produce chan sync msg: %v",
+			line, err)
 	}
-	_, _, _, err = aliceChannel.ProcessChanSyncMsg(badChanSync)
-	if err != ErrCannotSyncCommitChains {
-		t.Fatalf("expected error instead have: %v", err)
+	bobChanSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	if err != nil {
+		t.Fatalf("line #%v: unable to produce chan sync msg: %v",
+			line, err)
+	}
+
+	// For taproot channels, simulate the link/peer binding the generated
+	// nonces.
+	if aliceChannel.channelState.ChanType.IsTaproot() {
+		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *aliceChanSyncMsg.LocalNonce,
+		}
+		bobChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *bobChanSyncMsg.LocalNonce,
+		}
+	}
+
+	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceChanSyncMsg)
+	if err != nil {
+		t.Fatalf("line #%v: unable to process ChannelReestablish "+
+			"msg: %v", line, err)
+	}
+	if len(bobMsgsToSend) != 0 {
+		t.Fatalf("line #%v: bob shouldn't have to send any messages, "+
+			"instead wants to send: %v", line, spew.Sdump(bobMsgsToSend))
+	}
+
+	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(bobChanSyncMsg)
+	if err != nil {
+		t.Fatalf("line #%v: unable to process ChannelReestablish "+
+			"msg: %v", line, err)
+	}
+	if len(bobMsgsToSend) != 0 {
+		t.Fatalf("line #%v: alice shouldn't have to send any "+
+			"messages, instead wants to send: %v", line,
+			spew.Sdump(aliceMsgsToSend))
 	}
 }
 
-// TestChanSyncInvalidLastSecret ensures that if Alice and Bob have completed
-// state transitions in an existing channel, and then send a ChannelReestablish
-// message after a restart, the following holds: if Alice has lost data, so she
-// sends an invalid commit secret then both parties recognize this as possible
-// data loss.
-func TestChanSyncInvalidLastSecret(t *testing.T) {
+// TestChanSyncFullySynced tests that after a successful commitment exchange,
+// and a forced restart, both nodes conclude that they're fully synchronized
+// and don't need to retransmit any messages.
+func TestChanSyncFullySynced(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderBit,
+		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// We'll create a new instances of Alice before doing any state updates
-	// such that we have the initial in memory state at the start of the
-	// channel.
-	aliceOld, err := restartChannel(aliceChannel)
-	if err != nil {
-		t.Fatalf("unable to restart alice")
-	}
+	// If we exchange channel sync messages from the get-go , then both
+	// sides should conclude that no further synchronization is needed.
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
 
-	// First, we'll add an HTLC, and then initiate a state transition
-	// between the two parties such that we actually have a prior
-	// revocation to send.
+	// Next, we'll create an HTLC for Alice to extend to Bob.
 	var paymentPreimage [32]byte
 	copy(paymentPreimage[:], bytes.Repeat([]byte{1}, 32))
 	paymentHash := sha256.Sum256(paymentPreimage[:])
@@ -4730,2313 +4749,2512 @@ func TestChanSyncInvalidLastSecret(t *testing.T) {
 		Amount:      htlcAmt,
 		Expiry:      uint32(5),
 	}
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
-	}
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "unable to add htlc")
+	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "unable to recv htlc")
 
 	// Then we'll initiate a state transition to lock in this new HTLC.
 	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
 		t.Fatalf("unable to complete alice's state transition: %v", err)
 	}
 
-	// Next, we'll restart both parties in order to simulate a connection
-	// re-establishment.
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart alice")
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart bob")
+	// At this point, if both sides generate a ChannelReestablish message,
+	// they should both conclude that they're fully in sync.
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
 
-	// Next, we'll produce the ChanSync messages for both parties.
-	aliceChanSync, err := aliceChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to generate chan sync msg")
-	bobChanSync, err := bobChannel.channelState.ChanSyncMsg()
-	require.NoError(t, err, "unable to generate chan sync msg")
+	// If bob settles the HTLC, and then initiates a state transition, they
+	// should both still think that they're in sync.
+	err = bobChannel.SettleHTLC(paymentPreimage, bobHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = aliceChannel.ReceiveHTLCSettle(paymentPreimage, aliceHtlcIndex)
+	require.NoError(t, err, "unable to settle htlc")
 
-	// We'll modify Alice's sync message to have an invalid commitment
-	// secret.
-	aliceChanSync.LastRemoteCommitSecret[4] ^= 0x01
+	// Next, we'll complete Bob's state transition, and assert again that
+	// they think they're fully synced.
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
+	}
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
 
-	// Alice's former self should conclude that she possibly lost data as
-	// Bob is sending a valid commit secret for the latest state.
-	_, _, _, err = aliceOld.ProcessChanSyncMsg(bobChanSync)
-	if _, ok := err.(*ErrCommitSyncLocalDataLoss); !ok {
-		t.Fatalf("wrong error, expected ErrCommitSyncLocalDataLoss "+
-			"instead got: %v", err)
+	// Finally, if we simulate a restart on both sides, then both should
+	// still conclude that they don't need to synchronize their state.
+	alicePub := aliceChannel.channelState.IdentityPub
+	aliceChannels, err := aliceChannel.channelState.Db.FetchOpenChannels(
+		alicePub,
+	)
+	require.NoError(t, err, "unable to fetch channel")
+	bobPub := bobChannel.channelState.IdentityPub
+	bobChannels, err := bobChannel.channelState.Db.FetchOpenChannels(bobPub)
+	require.NoError(t, err, "unable to fetch channel")
+
+	aliceChannelNew, err := NewLightningChannel(
+		aliceChannel.Signer, aliceChannels[0], aliceChannel.sigPool,
+	)
+	require.NoError(t, err, "unable to create new channel")
+	bobChannelNew, err := NewLightningChannel(
+		bobChannel.Signer, bobChannels[0], bobChannel.sigPool,
+	)
+	require.NoError(t, err, "unable to create new channel")
+
+	assertNoChanSyncNeeded(t, aliceChannelNew, bobChannelNew)
+}
+
+// restartChannel reads the passed channel from disk, and returns a newly
+// initialized instance. This simulates one party restarting and losing their
+// in memory state.
+func restartChannel(channelOld *LightningChannel) (*LightningChannel, error) {
+	nodePub := channelOld.channelState.IdentityPub
+	nodeChannels, err := channelOld.channelState.Db.FetchOpenChannels(
+		nodePub,
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	// Bob should conclude that he should force close the channel, as Alice
-	// cannot continue operation.
-	_, _, _, err = bobChannel.ProcessChanSyncMsg(aliceChanSync)
-	if err != ErrInvalidLastCommitSecret {
-		t.Fatalf("wrong error, expected ErrInvalidLastCommitSecret, "+
-			"instead got: %v", err)
+	channelNew, err := NewLightningChannel(
+		channelOld.Signer, nodeChannels[0],
+		channelOld.sigPool,
+	)
+	if err != nil {
+		return nil, err
 	}
+
+	return channelNew, nil
 }
 
-// TestChanAvailableBandwidth tests the accuracy of the AvailableBalance()
-// method. The value returned from this message should reflect the value
-// returned within the commitment state of a channel after the transition is
-// initiated.
-func TestChanAvailableBandwidth(t *testing.T) {
+// TestRecoverUnilateralClose tests that the summary of a remote force close
+// can be rebuilt after a restart, both for their current commitment and for a
+// commitment we've extended to them but they haven't revoked their prior
+// state for yet.
+func TestRecoverUnilateralClose(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	aliceReserve := lnwire.NewMSatFromSatoshis(
-		aliceChannel.channelState.LocalChanCfg.ChanReserve,
-	)
-	feeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	// Lock in an HTLC from Alice to Bob, such that Bob's commitment has an
+	// HTLC output next to Alice's to_remote output.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Bob's current commitment is the one Alice knows as his current
+	// remote commitment.
+	bobCommitTx := bobChannel.channelState.LocalCommitment.CommitTx
+
+	// Alice restarts, after which she recovers the close summary for Bob's
+	// commitment.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err)
+
+	const spendHeight = 100
+	summary, err := aliceChannel.RecoverUnilateralClose(
+		bobCommitTx, spendHeight,
 	)
-	htlcFee := lnwire.NewMSatFromSatoshis(
-		feeRate.FeeForWeight(input.HTLCWeight),
+	require.NoError(t, err)
+
+	require.EqualValues(
+		t, spendHeight, summary.ChannelCloseSummary.CloseHeight,
 	)
+	require.Len(t, summary.HtlcResolutions.OutgoingHTLCs, 1)
+	require.NotNil(t, summary.CommitResolution)
 
-	assertBandwidthEstimateCorrect := func(aliceInitiate bool) {
-		// With the HTLC's added, we'll now query the AvailableBalance
-		// method for the current available channel bandwidth from
-		// Alice's PoV.
-		aliceAvailableBalance := aliceChannel.AvailableBalance()
+	// The recovered sign descriptor should point to Alice's to_remote
+	// output on Bob's commitment.
+	selfOutpoint := summary.CommitResolution.SelfOutPoint
+	require.Equal(t, bobCommitTx.TxHash(), selfOutpoint.Hash)
+	selfOutput := bobCommitTx.TxOut[selfOutpoint.Index]
+	require.Equal(
+		t, selfOutput.PkScript,
+		summary.CommitResolution.SelfOutputSignDesc.Output.PkScript,
+	)
+	require.Equal(
+		t, selfOutput.Value,
+		summary.CommitResolution.SelfOutputSignDesc.Output.Value,
+	)
 
-		// With this balance obtained, we'll now trigger a state update
-		// to actually determine what the current up to date balance
-		// is.
-		if aliceInitiate {
-			err := ForceStateTransition(aliceChannel, bobChannel)
-			if err != nil {
-				t.Fatalf("unable to complete alice's state "+
-					"transition: %v", err)
-			}
-		} else {
-			err := ForceStateTransition(bobChannel, aliceChannel)
-			if err != nil {
-				t.Fatalf("unable to complete alice's state "+
-					"transition: %v", err)
-			}
-		}
+	// Alice now extends a new commitment to Bob with an updated fee rate,
+	// which Bob receives but broadcasts before revoking his prior state.
+	fee := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) * 2
+	require.NoError(t, aliceChannel.UpdateFee(fee))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(fee))
 
-		// Now, we'll obtain the current available bandwidth in Alice's
-		// latest commitment and compare that to the prior estimate.
-		aliceBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
 
-		// The balance we have available for new HTLCs should be the
-		// current local commitment balance, minus the channel reserve
-		// and the fee for adding an HTLC.
-		expBalance := aliceBalance - aliceReserve - htlcFee
-		if expBalance != aliceAvailableBalance {
-			_, _, line, _ := runtime.Caller(1)
-			t.Fatalf("line: %v, incorrect balance: expected %v, "+
-				"got %v", line, expBalance, aliceAvailableBalance)
-		}
-	}
+	bobPendingCommitTx := bobChannel.localCommitChain.tip().txn
+	require.NotEqual(t, bobCommitTx.TxHash(), bobPendingCommitTx.TxHash())
 
-	// First, we'll add 3 outgoing HTLC's from Alice to Bob.
-	const numHtlcs = 3
-	var htlcAmt lnwire.MilliSatoshi = 100000
-	alicePreimages := make([][32]byte, numHtlcs)
-	for i := 0; i < numHtlcs; i++ {
-		htlc, preImage := createHTLC(i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err)
 
-		alicePreimages[i] = preImage
-	}
+	summary, err = aliceChannel.RecoverUnilateralClose(
+		bobPendingCommitTx, spendHeight,
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t, bobPendingCommitTx.TxHash(),
+		summary.CommitResolution.SelfOutPoint.Hash,
+	)
+	require.Len(t, summary.HtlcResolutions.OutgoingHTLCs, 1)
 
-	assertBandwidthEstimateCorrect(true)
+	// A transaction that isn't one of Bob's commitments, such as Alice's
+	// own, can't be recovered.
+	aliceCommitTx := aliceChannel.channelState.LocalCommitment.CommitTx
+	_, err = aliceChannel.RecoverUnilateralClose(aliceCommitTx, spendHeight)
+	require.ErrorIs(t, err, ErrUnknownRemoteCommit)
+}
 
-	// We'll repeat the same exercise, but with non-dust HTLCs. So we'll
-	// crank up the value of the HTLC's we're adding to the commitment
-	// transaction.
-	htlcAmt = lnwire.NewMSatFromSatoshis(30000)
-	for i := 0; i < numHtlcs; i++ {
-		htlc, preImage := createHTLC(numHtlcs+i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
+// errSignRevokedHeight is returned by mockPolicySigner when asked to sign a
+// remote commitment at a revoked height.
+var errSignRevokedHeight = errors.New("refusing to sign revoked height")
 
-		alicePreimages = append(alicePreimages, preImage)
-	}
+// mockPolicySigner is a PolicySigner that records the context of every
+// signature it generates, and refuses to sign remote commitments at or below
+// the configured revoked height.
+type mockPolicySigner struct {
+	input.Signer
 
-	assertBandwidthEstimateCorrect(true)
+	mu            sync.Mutex
+	revokedHeight uint64
+	contexts      []SignContext
+}
 
-	// Next, we'll have Bob 5 of Alice's HTLC's, and cancel one of them (in
-	// the update log).
-	for i := 0; i < (numHtlcs*2)-1; i++ {
-		preImage := alicePreimages[i]
-		err := bobChannel.SettleHTLC(preImage, uint64(i), nil, nil, nil)
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
-		err = aliceChannel.ReceiveHTLCSettle(preImage, uint64(i))
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
-	}
+// SignOutputRawWithContext signs the passed transaction unless the context
+// refers to a revoked remote commitment.
+func (m *mockPolicySigner) SignOutputRawWithContext(tx *wire.MsgTx,
+	signDesc *input.SignDescriptor,
+	signCtx *SignContext) (input.Signature, error) {
 
-	htlcIndex := uint64((numHtlcs * 2) - 1)
-	err = bobChannel.FailHTLC(htlcIndex, []byte("f"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveFailHTLC(htlcIndex, []byte("bad"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// We must do a state transition before the balance is available
-	// for Alice.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete alice's state "+
-			"transition: %v", err)
+	if !signCtx.LocalCommit && signCtx.CommitHeight <= m.revokedHeight {
+		return nil, errSignRevokedHeight
 	}
 
-	// With the HTLC's settled in the log, we'll now assert that if we
-	// initiate a state transition, then our guess was correct.
-	assertBandwidthEstimateCorrect(false)
+	m.contexts = append(m.contexts, *signCtx)
 
-	// TODO(roasbeef): additional tests from diff starting conditions
+	return m.Signer.SignOutputRaw(tx, signDesc)
 }
 
-// TestChanAvailableBalanceNearHtlcFee checks that we get the expected reported
-// balance when it is close to the htlc fee.
-func TestChanAvailableBalanceNearHtlcFee(t *testing.T) {
+// TestPolicySigner tests that a PolicySigner is handed the context of all
+// commitment and HTLC signatures, and that it can refuse to sign a revoked
+// state.
+func TestPolicySigner(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Alice and Bob start with half the channel capacity.
-	aliceBalance := lnwire.NewMSatFromSatoshis(5 * btcutil.SatoshiPerBitcoin)
-	bobBalance := lnwire.NewMSatFromSatoshis(5 * btcutil.SatoshiPerBitcoin)
+	// Recreate Alice's channel with a policy signer, which is also used
+	// by her sig pool to sign HTLCs.
+	policySigner := &mockPolicySigner{Signer: aliceChannel.Signer}
+	sigPool := NewSigPool(1, policySigner)
+	require.NoError(t, sigPool.Start())
+	t.Cleanup(func() {
+		require.NoError(t, sigPool.Stop())
+	})
 
-	aliceReserve := lnwire.NewMSatFromSatoshis(
-		aliceChannel.channelState.LocalChanCfg.ChanReserve,
-	)
-	bobReserve := lnwire.NewMSatFromSatoshis(
-		bobChannel.channelState.LocalChanCfg.ChanReserve,
+	aliceChannel, err = NewLightningChannel(
+		policySigner, aliceChannel.channelState, sigPool,
 	)
+	require.NoError(t, err)
 
-	aliceDustlimit := lnwire.NewMSatFromSatoshis(
-		aliceChannel.channelState.LocalChanCfg.DustLimit,
-	)
-	feeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	)
-	htlcFee := lnwire.NewMSatFromSatoshis(
-		feeRate.FeeForWeight(input.HTLCWeight),
-	)
-	commitFee := lnwire.NewMSatFromSatoshis(
-		aliceChannel.channelState.LocalCommitment.CommitFee,
-	)
-	htlcTimeoutFee := lnwire.NewMSatFromSatoshis(
-		HtlcTimeoutFee(aliceChannel.channelState.ChanType, feeRate),
-	)
-	htlcSuccessFee := lnwire.NewMSatFromSatoshis(
-		HtlcSuccessFee(aliceChannel.channelState.ChanType, feeRate),
+	// Alice offers an HTLC that's above dust, and signs a new commitment
+	// for Bob including it.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Both the commitment and the HTLC signature should have been
+	// requested with the context of Bob's new commitment.
+	chanPoint := aliceChannel.channelState.FundingOutpoint
+	expectedCtx := SignContext{
+		ChanPoint:    chanPoint,
+		CommitHeight: 1,
+	}
+	require.Equal(
+		t, []SignContext{expectedCtx, expectedCtx},
+		policySigner.contexts,
 	)
 
-	// Helper method to check the current reported balance.
-	checkBalance := func(t *testing.T, expBalanceAlice,
-		expBalanceBob lnwire.MilliSatoshi) {
+	// If the signer considers the next height to be revoked already, it
+	// refuses to sign, and Bob's commitment chain isn't extended.
+	policySigner.revokedHeight = 2
 
-		t.Helper()
-		aliceBalance := aliceChannel.AvailableBalance()
-		if aliceBalance != expBalanceAlice {
-			t.Fatalf("Expected alice balance %v, got %v",
-				expBalanceAlice, aliceBalance)
-		}
+	htlc, _ = createHTLC(1, lnwire.NewMSatFromSatoshis(20000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.SignNextCommitment()
+	require.ErrorIs(t, err, errSignRevokedHeight)
+	require.Zero(t, aliceChannel.remoteCommitChain.numUnacked())
 
-		bobBalance := bobChannel.AvailableBalance()
-		if bobBalance != expBalanceBob {
-			t.Fatalf("Expected bob balance %v, got %v",
-				expBalanceBob, bobBalance)
-		}
+	// Signing our own commitment to force close is always allowed.
+	policySigner.contexts = nil
+	_, err = aliceChannel.ForceClose()
+	require.NoError(t, err)
+	require.Equal(t, []SignContext{{
+		ChanPoint:    chanPoint,
+		CommitHeight: 1,
+		LocalCommit:  true,
+	}}, policySigner.contexts)
+}
+
+// TestVerifyStoredHTLCSigs tests that the HTLC signatures of the local
+// commitment can be verified after a restart, and that a corrupted signature
+// is detected.
+func TestVerifyStoredHTLCSigs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		chanType channeldb.ChannelType
+	}{
+		{
+			name:     "tweakless",
+			chanType: channeldb.SingleFunderTweaklessBit,
+		},
+		{
+			name: "taproot",
+			chanType: channeldb.SimpleTaprootFeatureBit |
+				channeldb.AnchorOutputsBit |
+				channeldb.ZeroHtlcTxFeeBit |
+				channeldb.SingleFunderTweaklessBit,
+		},
 	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
 
-	// Helper method to send an HTLC from Alice to Bob, decreasing Alice's
-	// balance.
-	htlcIndex := uint64(0)
-	sendHtlc := func(htlcAmt lnwire.MilliSatoshi) {
-		t.Helper()
+			testVerifyStoredHTLCSigs(t, tc.chanType)
+		})
+	}
+}
 
-		htlc, preImage := createHTLC(int(htlcIndex), htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
+func testVerifyStoredHTLCSigs(t *testing.T, chanType channeldb.ChannelType) {
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
 
-		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-			t.Fatalf("unable to complete alice's state "+
-				"transition: %v", err)
-		}
+	// Lock in an HTLC in each direction, such that Alice's commitment
+	// carries both an incoming and an outgoing HTLC.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	aliceHtlc, _ := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(aliceHtlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(aliceHtlc)
+	require.NoError(t, err)
 
-		err = bobChannel.SettleHTLC(preImage, htlcIndex, nil, nil, nil)
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
-		err = aliceChannel.ReceiveHTLCSettle(preImage, htlcIndex)
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
+	bobHtlc, _ := createHTLC(0, htlcAmt)
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err)
 
-		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-			t.Fatalf("unable to complete alice's state "+
-				"transition: %v", err)
-		}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-		htlcIndex++
-		aliceBalance -= htlcAmt
-		bobBalance += htlcAmt
-	}
+	// After restarting, the signatures stored on disk should verify.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err)
+	require.NoError(t, aliceChannel.VerifyStoredHTLCSigs())
 
-	// Balance should start out equal to half the channel capacity minus
-	// the commitment fee Alice must pay and the channel reserve. In
-	// addition the HTLC fee will be subtracted fromt the balance to
-	// reflect that this value must be reserved for any payment above the
-	// dust limit.
-	expAliceBalance := aliceBalance - commitFee - aliceReserve - htlcFee
+	htlcs := aliceChannel.channelState.LocalCommitment.Htlcs
+	require.Len(t, htlcs, 2)
 
-	// Bob is not the initiator, so he will have all his balance available,
-	// since Alice pays for fees. Bob only need to keep his balance above
-	// the reserve.
-	expBobBalance := bobBalance - bobReserve
-	checkBalance(t, expAliceBalance, expBobBalance)
+	// Swapping the signatures of both HTLCs leaves them well formed, but
+	// neither of them should verify.
+	htlcs[0].Signature, htlcs[1].Signature = htlcs[1].Signature,
+		htlcs[0].Signature
+	err = aliceChannel.VerifyStoredHTLCSigs()
+	require.ErrorIs(t, err, ErrInvalidStoredHtlcSig)
 
-	// Find the minumim size of a non-dust HTLC.
-	aliceNonDustHtlc := aliceDustlimit + htlcTimeoutFee
+	// A signature that can't even be parsed should be detected as well.
+	htlcs[0].Signature, htlcs[1].Signature = htlcs[1].Signature,
+		htlcs[0].Signature
+	htlcs[0].Signature = []byte{0x01, 0x02}
+	err = aliceChannel.VerifyStoredHTLCSigs()
+	require.ErrorIs(t, err, ErrInvalidStoredHtlcSig)
+}
 
-	// Send a HTLC leaving Alice's remaining balance just enough to have
-	// nonDustHtlc left after paying the commit fee and htlc fee.
-	htlcAmt := aliceBalance - (commitFee + aliceReserve + htlcFee + aliceNonDustHtlc)
-	sendHtlc(htlcAmt)
+// TestPrepareForReconnect tests that a channel is able to resume a partially
+// completed state transition after a reconnection without being reloaded
+// from disk.
+func TestPrepareForReconnect(t *testing.T) {
+	t.Parallel()
 
-	// Now the real balance left will be
-	// nonDustHtlc+commitfee+aliceReserve+htlcfee. The available balance
-	// reported will just be nonDustHtlc, since the rest of the balance is
-	// reserved.
-	expAliceBalance = aliceNonDustHtlc
-	expBobBalance = bobBalance - bobReserve
-	checkBalance(t, expAliceBalance, expBobBalance)
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Send an HTLC using all but one msat of the reported balance.
-	htlcAmt = aliceNonDustHtlc - 1
-	sendHtlc(htlcAmt)
+	// Alice adds an HTLC and signs a new commitment for Bob, but the
+	// connection breaks down before Bob receives her signature.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
 
-	// 1 msat should be left.
-	expAliceBalance = 1
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
 
-	// Bob should still have all his balance available, since even though
-	// Alice cannot afford to add a non-dust HTLC, she can afford to add a
-	// non-dust HTLC from Bob.
-	expBobBalance = bobBalance - bobReserve
-	checkBalance(t, expAliceBalance, expBobBalance)
+	require.NoError(t, aliceChannel.PrepareForReconnect())
+	require.NoError(t, bobChannel.PrepareForReconnect())
+	require.Equal(t, ChannelOpen, aliceChannel.Status())
 
-	// Sendng the last msat.
-	htlcAmt = 1
-	sendHtlc(htlcAmt)
+	// The pending commitment should have been preserved, so upon
+	// reestablishment Alice should retransmit her HTLC along with the
+	// exact same signature, while Bob has nothing to send.
+	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err)
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err)
 
-	// No balance left.
-	expAliceBalance = 0
+	bobMsgs, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
+	require.NoError(t, err)
+	require.Empty(t, bobMsgs)
 
-	// We try to always reserve enough for the non-iniitator to be able to
-	// add an HTLC, hence Bob should still have all his non-reserved
-	// balance available.
-	expBobBalance = bobBalance - bobReserve
-	checkBalance(t, expAliceBalance, expBobBalance)
+	aliceMsgs, _, _, err := aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	require.NoError(t, err)
+	require.Len(t, aliceMsgs, 2)
+	require.IsType(t, &lnwire.UpdateAddHTLC{}, aliceMsgs[0])
 
-	// Even though Alice has a reported balance of 0, this is because we
-	// try to avoid getting into the position where she cannot pay the fee
-	// for Bob adding another HTLC. This means she actually _has_ some
-	// balance left, and we now force the channel into this situation by
-	// sending yet another HTLC. In practice this can also happen if a fee
-	// update eats into Alice's balance.
-	htlcAmt = 1
-	sendHtlc(htlcAmt)
+	commitSig, ok := aliceMsgs[1].(*lnwire.CommitSig)
+	require.True(t, ok)
+	require.Equal(t, aliceNewCommit.CommitSig, commitSig.CommitSig)
 
-	// Now Alice balance is so low that she cannot even afford to add a new
-	// HTLC from Bob to the commitment transaction. Bob's balance should
-	// reflect this, by only reporting dust amount being available. Alice
-	// should still report a zero balance.
+	// Bob should now be able to pick up the retransmitted commitment and
+	// complete the state transition.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
 
-	// Since the dustlimit is different for the two commitments, the
-	// largest HTLC Bob can send that Alice can afford on both commitments
-	// (remember she cannot afford to pay the HTLC fee) is the largest dust
-	// HTLC on Alice's commitemnt, since her dust limit is lower.
-	bobNonDustHtlc := aliceDustlimit + htlcSuccessFee
-	expBobBalance = bobNonDustHtlc - 1
-	expAliceBalance = 0
-	checkBalance(t, expAliceBalance, expBobBalance)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+
+	require.Len(t, aliceChannel.channelState.LocalCommitment.Htlcs, 1)
+	require.Len(t, bobChannel.channelState.LocalCommitment.Htlcs, 1)
 }
 
-// TestChanCommitWeightDustHtlcs checks that we correctly calculate the
-// commitment weight when some HTLCs are dust.
-func TestChanCommitWeightDustHtlcs(t *testing.T) {
+// TestPrepareForReconnectTaproot tests that a taproot channel is able to
+// process a channel reestablishment more than once, as long as it's prepared
+// for the reconnection in between.
+func TestPrepareForReconnectTaproot(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+		t, channeldb.SimpleTaprootFeatureBit|
+			channeldb.AnchorOutputsBit|channeldb.ZeroHtlcTxFeeBit|
+			channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	aliceDustlimit := lnwire.NewMSatFromSatoshis(
-		aliceChannel.channelState.LocalChanCfg.DustLimit,
-	)
-	bobDustlimit := lnwire.NewMSatFromSatoshis(
-		bobChannel.channelState.LocalChanCfg.DustLimit,
-	)
-
-	feeRate := chainfee.SatPerKWeight(
-		aliceChannel.channelState.LocalCommitment.FeePerKw,
-	)
-	htlcTimeoutFee := lnwire.NewMSatFromSatoshis(
-		HtlcTimeoutFee(aliceChannel.channelState.ChanType, feeRate),
-	)
-	htlcSuccessFee := lnwire.NewMSatFromSatoshis(
-		HtlcSuccessFee(aliceChannel.channelState.ChanType, feeRate),
-	)
-
-	// Helper method to add an HTLC from Alice to Bob.
-	htlcIndex := uint64(0)
-	addHtlc := func(htlcAmt lnwire.MilliSatoshi) lntypes.Preimage {
-		t.Helper()
-
-		htlc, preImage := createHTLC(int(htlcIndex), htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
-
-		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-			t.Fatalf("unable to complete alice's state "+
-				"transition: %v", err)
-		}
-
-		return preImage
-	}
-
-	settleHtlc := func(preImage lntypes.Preimage) {
-		t.Helper()
+	syncChannels := func() error {
+		aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+		require.NoError(t, err)
+		bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+		require.NoError(t, err)
 
-		err = bobChannel.SettleHTLC(preImage, htlcIndex, nil, nil, nil)
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
-		}
-		err = aliceChannel.ReceiveHTLCSettle(preImage, htlcIndex)
+		_, _, _, err = bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
 		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
+			return err
 		}
+		_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
 
-		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-			t.Fatalf("unable to complete alice's state "+
-				"transition: %v", err)
-		}
-		htlcIndex++
+		return err
 	}
 
-	// Helper method that fetches the current remote commitment weight
-	// fromt the given channel's POV.
-	remoteCommitWeight := func(lc *LightningChannel) int64 {
-		remoteACKedIndex := lc.localCommitChain.tip().theirMessageIndex
-		htlcView := lc.fetchHTLCView(remoteACKedIndex,
-			lc.localUpdateLog.logIndex)
+	require.NoError(t, aliceChannel.PrepareForReconnect())
+	require.NoError(t, bobChannel.PrepareForReconnect())
+	require.NoError(t, syncChannels())
 
-		_, w := lc.availableCommitmentBalance(
-			htlcView, true,
-		)
+	// The verification nonces have been consumed by the first
+	// reestablishment, so another one should fail.
+	require.Error(t, syncChannels())
 
-		return w
-	}
+	// Once prepared for the reconnection, fresh nonces are available and
+	// the channels should be able to continue.
+	require.NoError(t, aliceChannel.PrepareForReconnect())
+	require.NoError(t, bobChannel.PrepareForReconnect())
+	require.NoError(t, syncChannels())
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+}
 
-	// Start by getting the initial remote commitment weight seen from
-	// Alice's perspective. At this point there are no HTLCs on the
-	// commitment.
-	weight1 := remoteCommitWeight(aliceChannel)
-
-	// Now add an HTLC that will be just below Bob's dustlimit.
-	// Since this is an HTLC added from Alice on Bob's commitment, we will
-	// use the HTLC success fee.
-	bobDustHtlc := bobDustlimit + htlcSuccessFee - 1
-	preimg := addHtlc(bobDustHtlc)
-
-	// Now get the current weight of the remote commitment. We expect it to
-	// not have changed, since the HTLC we added is considered dust.
-	weight2 := remoteCommitWeight(aliceChannel)
-	require.Equal(t, weight1, weight2)
-
-	// In addition, we expect this weight to result in the fee we currently
-	// see being paid on the remote commitent.
-	calcFee := feeRate.FeeForWeight(weight2)
-	remoteCommitFee := aliceChannel.channelState.RemoteCommitment.CommitFee
-	require.Equal(t, calcFee, remoteCommitFee)
-
-	// Settle the HTLC, bringing commitment weight back to base.
-	settleHtlc(preimg)
-
-	// Now we do a similar check from Bob's POV. Start with getting his
-	// current view of Alice's commitment weight.
-	weight1 = remoteCommitWeight(bobChannel)
-
-	// We'll add an HTLC from Alice to Bob, that is just above dust on
-	// Alice's commitment. Now we'll use the timeout fee.
-	aliceDustHtlc := aliceDustlimit + htlcTimeoutFee
-	preimg = addHtlc(aliceDustHtlc)
-
-	// Get the current remote commitment weight from Bob's POV, and ensure
-	// it is now heavier, since Alice added a non-dust HTLC.
-	weight2 = remoteCommitWeight(bobChannel)
-	require.Greater(t, weight2, weight1)
-
-	// Ensure the current remote commit has the expected commitfee.
-	calcFee = feeRate.FeeForWeight(weight2)
-	remoteCommitFee = bobChannel.channelState.RemoteCommitment.CommitFee
-	require.Equal(t, calcFee, remoteCommitFee)
-
-	settleHtlc(preimg)
-}
-
-// TestSignCommitmentFailNotLockedIn tests that a channel will not attempt to
-// create a new state if it doesn't yet know of the next revocation point for
-// the remote party.
-func TestSignCommitmentFailNotLockedIn(t *testing.T) {
+// TestChanSyncOweCommitment tests that if Bob restarts (and then Alice) before
+// he receives Alice's CommitSig message, then Alice concludes that she needs
+// to re-send the CommitDiff. After the diff has been sent, both nodes should
+// resynchronize and be able to complete the dangling commit.
+func TestChanSyncOweCommitment(t *testing.T) {
 	t.Parallel()
 
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
-	aliceChannel, _, err := CreateTestChannels(
+	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Next, we'll modify Alice's internal state to omit knowledge of Bob's
-	// next revocation point.
-	aliceChannel.channelState.RemoteNextRevocation = nil
-
-	// If we now try to initiate a state update, then it should fail as
-	// Alice is unable to actually create a new state.
-	_, err = aliceChannel.SignNextCommitment()
-	if err != ErrNoWindow {
-		t.Fatalf("expected ErrNoWindow, instead have: %v", err)
-	}
-}
-
-// TestLockedInHtlcForwardingSkipAfterRestart ensures that after a restart, a
-// state machine doesn't attempt to re-forward any HTLC's that were already
-// locked in, but in a prior state.
-func TestLockedInHtlcForwardingSkipAfterRestart(t *testing.T) {
-	t.Parallel()
+	var fakeOnionBlob [lnwire.OnionPacketSize]byte
+	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
 
-	// First, we'll make a channel between Alice and Bob.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	// We'll start off the scenario with Bob sending 3 HTLC's to Alice in a
+	// single state update.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	const numBobHtlcs = 3
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xbb}, 32))
+	for i := 0; i < 3; i++ {
+		rHash := sha256.Sum256(bobPreimage[:])
+		h := &lnwire.UpdateAddHTLC{
+			PaymentHash: rHash,
+			Amount:      htlcAmt,
+			Expiry:      uint32(10),
+			OnionBlob:   fakeOnionBlob,
+			ExtraData:   make([]byte, 0),
+		}
 
-	// We'll now add two HTLC's from Alice to Bob, then Alice will initiate
-	// a state transition.
-	var htlcAmt lnwire.MilliSatoshi = 100000
-	htlc, _ := createHTLC(0, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
-	}
-	htlc2, _ := createHTLC(1, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc2, nil); err != nil {
-		t.Fatalf("unable to add htlc2: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc2); err != nil {
-		t.Fatalf("unable to recv htlc2: %v", err)
-	}
+		htlcIndex, err := bobChannel.AddHTLC(h, nil)
+		if err != nil {
+			t.Fatalf("unable to add bob's htlc: %v", err)
+		}
 
-	// We'll now manually initiate a state transition between Alice and
-	// bob.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
+		h.ID = htlcIndex
+		if _, err := aliceChannel.ReceiveHTLC(h); err != nil {
+			t.Fatalf("unable to recv bob's htlc: %v", err)
+		}
 	}
 
-	// Alice should detect that she doesn't need to forward any HTLC's.
-	fwdPkg, _, _, _, err := aliceChannel.ReceiveRevocation(bobRevocation)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(fwdPkg.Adds) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(fwdPkg.Adds))
-	}
-	if len(fwdPkg.SettleFails) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(fwdPkg.SettleFails))
-	}
+	chanID := lnwire.NewChanIDFromOutPoint(
+		&aliceChannel.channelState.FundingOutpoint,
+	)
 
-	// Now, have Bob initiate a transition to lock in the Adds sent by
-	// Alice.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
+	// With the HTLC's applied to both update logs, we'll initiate a state
+	// transition from Bob.
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
+	// Next, Alice's settles all 3 HTLC's from Bob, and also adds a new
+	// HTLC of her own.
+	for i := 0; i < 3; i++ {
+		err := aliceChannel.SettleHTLC(bobPreimage, uint64(i), nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+		err = bobChannel.ReceiveHTLCSettle(bobPreimage, uint64(i))
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
 	}
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
+	var alicePreimage [32]byte
+	copy(alicePreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	rHash := sha256.Sum256(alicePreimage[:])
+	aliceHtlc := &lnwire.UpdateAddHTLC{
+		ChanID:      chanID,
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
+		OnionBlob:   fakeOnionBlob,
+		ExtraData:   make([]byte, 0),
 	}
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(aliceHtlc, nil)
+	require.NoError(t, err, "unable to add alice's htlc")
+	bobHtlcIndex, err := bobChannel.ReceiveHTLC(aliceHtlc)
+	require.NoError(t, err, "unable to recv alice's htlc")
 
-	// Bob should now detect that he now has 2 incoming HTLC's that he can
-	// forward along.
-	fwdPkg, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(fwdPkg.Adds) != 2 {
-		t.Fatalf("bob should forward 2 hltcs, instead has %v",
-			len(fwdPkg.Adds))
-	}
-	if len(fwdPkg.SettleFails) != 0 {
-		t.Fatalf("bob should forward 0 hltcs, instead has %v",
-			len(fwdPkg.SettleFails))
-	}
+	// Now we'll begin the core of the test itself. Alice will extend a new
+	// commitment to Bob, but the connection drops before Bob can process
+	// it.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
 
-	// We'll now restart both Alice and Bob. This emulates a reconnection
-	// between the two peers.
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart alice")
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart bob")
+	// Bob doesn't get this message so upon reconnection, they need to
+	// synchronize. Alice should conclude that she owes Bob a commitment,
+	// while Bob should think he's properly synchronized.
+	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
 
-	// With both nodes restarted, Bob will now attempt to cancel one of
-	// Alice's HTLC's.
-	err = bobChannel.FailHTLC(htlc.ID, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveFailHTLC(htlc.ID, []byte("bad"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+	// This is a helper function that asserts Alice concludes that she
+	// needs to retransmit the exact commitment that we failed to send
+	// above.
+	assertAliceCommitRetransmit := func() {
+		aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(
+			bobSyncMsg,
+		)
+		if err != nil {
+			t.Fatalf("unable to process chan sync msg: %v", err)
+		}
+		if len(aliceMsgsToSend) != 5 {
+			t.Fatalf("expected alice to send %v messages instead "+
+				"will send %v: %v", 5, len(aliceMsgsToSend),
+				spew.Sdump(aliceMsgsToSend))
+		}
 
-	// We'll now initiate another state transition, but this time Bob will
-	// lead.
-	bobNewCommit, err = bobChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// At this point, Bob receives the revocation from Alice, which is now
-	// his signal to examine all the HTLC's that have been locked in to
-	// process.
-	fwdPkg, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Bob should detect that he doesn't need to forward *any* HTLC's, as
-	// he was the one that initiated extending the commitment chain of
-	// Alice.
-	if len(fwdPkg.Adds) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(fwdPkg.Adds))
-	}
-	if len(fwdPkg.SettleFails) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(fwdPkg.SettleFails))
-	}
-
-	// Now, begin another state transition led by Alice, and fail the second
-	// HTLC part-way through the dance.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
-	}
+		// Each of the settle messages that Alice sent should match her
+		// original intent.
+		for i := 0; i < 3; i++ {
+			settleMsg, ok := aliceMsgsToSend[i].(*lnwire.UpdateFulfillHTLC)
+			if !ok {
+				t.Fatalf("expected an HTLC settle message, "+
+					"instead have %v", spew.Sdump(settleMsg))
+			}
+			if settleMsg.ID != uint64(i) {
+				t.Fatalf("wrong ID in settle msg: expected %v, "+
+					"got %v", i, settleMsg.ID)
+			}
+			if settleMsg.ChanID != chanID {
+				t.Fatalf("incorrect chan id: expected %v, got %v",
+					chanID, settleMsg.ChanID)
+			}
+			if settleMsg.PaymentPreimage != bobPreimage {
+				t.Fatalf("wrong pre-image: expected %v, got %v",
+					alicePreimage, settleMsg.PaymentPreimage)
+			}
+		}
 
-	// Failing the HTLC here will cause the update to be included in Alice's
-	// remote log, but it should not be committed by this transition.
-	err = bobChannel.FailHTLC(htlc2.ID, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveFailHTLC(htlc2.ID, []byte("bad"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+		// The HTLC add message should be identical.
+		if _, ok := aliceMsgsToSend[3].(*lnwire.UpdateAddHTLC); !ok {
+			t.Fatalf("expected an HTLC add message, instead have %v",
+				spew.Sdump(aliceMsgsToSend[3]))
+		}
+		if !reflect.DeepEqual(aliceHtlc, aliceMsgsToSend[3]) {
+			t.Fatalf("htlc msg doesn't match exactly: "+
+				"expected %v got %v", spew.Sdump(aliceHtlc),
+				spew.Sdump(aliceMsgsToSend[3]))
+		}
 
-	bobRevocation, _, finalHtlcs, err := bobChannel.
-		RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
+		// Next, we'll ensure that the CommitSig message exactly
+		// matches what Alice originally intended to send.
+		commitSigMsg, ok := aliceMsgsToSend[4].(*lnwire.CommitSig)
+		if !ok {
+			t.Fatalf("expected a CommitSig message, instead have %v",
+				spew.Sdump(aliceMsgsToSend[4]))
+		}
+		if commitSigMsg.CommitSig != aliceNewCommit.CommitSig {
+			t.Fatalf("commit sig msgs don't match: expected "+
+				"%x got %x",
+				aliceNewCommit.CommitSig,
+				commitSigMsg.CommitSig)
+		}
+		if len(commitSigMsg.HtlcSigs) != len(aliceNewCommit.HtlcSigs) {
+			t.Fatalf("wrong number of htlc sigs: expected %v, got %v",
+				len(aliceNewCommit.HtlcSigs),
+				len(commitSigMsg.HtlcSigs))
+		}
+		for i, htlcSig := range commitSigMsg.HtlcSigs {
+			if htlcSig != aliceNewCommit.HtlcSigs[i] {
+				t.Fatalf("htlc sig msgs don't match: "+
+					"expected %x got %x",
+					aliceNewCommit.HtlcSigs[i], htlcSig)
+			}
+		}
 	}
 
-	// Check finalHtlcs for the expected final resolution.
-	require.Len(t, finalHtlcs, 1, "final htlc expected")
-	for _, settled := range finalHtlcs {
-		require.False(t, settled, "final fail expected")
-	}
+	// Alice should detect that she needs to re-send 5 messages: the 3
+	// settles, her HTLC add, and finally her commit sig message.
+	assertAliceCommitRetransmit()
 
-	// Alice should detect that she doesn't need to forward any Adds's, but
-	// that the Fail has been locked in an can be forwarded.
-	_, adds, settleFails, _, err := aliceChannel.ReceiveRevocation(bobRevocation)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(adds) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(adds))
-	}
-	if len(settleFails) != 1 {
-		t.Fatalf("alice should only forward %d HTLC's, instead wants to "+
-			"forward %v htlcs", 1, len(settleFails))
-	}
-	if settleFails[0].ParentIndex != htlc.ID {
-		t.Fatalf("alice should forward fail for htlcid=%d, instead "+
-			"forwarding id=%d", htlc.ID,
-			settleFails[0].ParentIndex)
+	// From Bob's Pov he has nothing else to send, so he should conclude he
+	// has no further action remaining.
+	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(bobMsgsToSend) != 0 {
+		t.Fatalf("expected bob to send %v messages instead will "+
+			"send %v: %v", 5, len(bobMsgsToSend),
+			spew.Sdump(bobMsgsToSend))
 	}
 
-	// We'll now restart both Alice and Bob. This emulates a reconnection
-	// between the two peers.
+	// If we restart Alice, she should still conclude that she needs to
+	// send the exact same set of messages.
 	aliceChannel, err = restartChannel(aliceChannel)
 	require.NoError(t, err, "unable to restart alice")
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart bob")
+	assertAliceCommitRetransmit()
 
-	// Re-add the Fail to both Alice and Bob's channels, as the non-committed
-	// update will not have survived the restart.
-	err = bobChannel.FailHTLC(htlc2.ID, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveFailHTLC(htlc2.ID, []byte("bad"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+	// TODO(roasbeef): restart bob as well???
 
-	// Have Alice initiate a state transition, which does not include the
-	// HTLCs just re-added to the channel state.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
+	// At this point, we should be able to resume the prior state update
+	// without any issues, resulting in Alice settling the 3 htlc's, and
+	// adding one of her own.
 	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
+	require.NoError(t, err, "bob unable to process alice's commitment")
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign commitment")
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to recv revocation")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to rev bob's commitment")
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to recv revocation")
 
-	// Alice should detect that she doesn't need to forward any HTLC's, as
-	// the updates haven't been committed by Bob yet.
-	fwdPkg, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(fwdPkg.Adds) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(fwdPkg.Adds))
+	// At this point, we'll now assert that their log states are what we
+	// expect.
+	//
+	// Alice's local log counter should be 4 and her HTLC index 3. She
+	// should detect Bob's remote log counter as being 3 and his HTLC index
+	// 3 as well.
+	if aliceChannel.localUpdateLog.logIndex != 4 {
+		t.Fatalf("incorrect log index: expected %v, got %v", 4,
+			aliceChannel.localUpdateLog.logIndex)
 	}
-	if len(fwdPkg.SettleFails) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(fwdPkg.SettleFails))
+	if aliceChannel.localUpdateLog.htlcCounter != 1 {
+		t.Fatalf("incorrect htlc index: expected %v, got %v", 1,
+			aliceChannel.localUpdateLog.htlcCounter)
 	}
-
-	// Now initiate a final update from Bob to lock in the final Fail.
-	bobNewCommit, err = bobChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
+	if aliceChannel.remoteUpdateLog.logIndex != 3 {
+		t.Fatalf("incorrect log index: expected %v, got %v", 3,
+			aliceChannel.localUpdateLog.logIndex)
 	}
-
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
+	if aliceChannel.remoteUpdateLog.htlcCounter != 3 {
+		t.Fatalf("incorrect htlc index: expected %v, got %v", 3,
+			aliceChannel.localUpdateLog.htlcCounter)
 	}
 
-	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
+	// Bob should also have the same state, but mirrored.
+	if bobChannel.localUpdateLog.logIndex != 3 {
+		t.Fatalf("incorrect log index: expected %v, got %v", 3,
+			bobChannel.localUpdateLog.logIndex)
 	}
-
-	// Bob should detect that he has nothing to forward, as he hasn't
-	// received any HTLCs.
-	fwdPkg, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	if err != nil {
-		t.Fatal(err)
+	if bobChannel.localUpdateLog.htlcCounter != 3 {
+		t.Fatalf("incorrect htlc index: expected %v, got %v", 3,
+			bobChannel.localUpdateLog.htlcCounter)
 	}
-	if len(fwdPkg.Adds) != 0 {
-		t.Fatalf("bob should forward 4 hltcs, instead has %v",
-			len(fwdPkg.Adds))
+	if bobChannel.remoteUpdateLog.logIndex != 4 {
+		t.Fatalf("incorrect log index: expected %v, got %v", 4,
+			bobChannel.localUpdateLog.logIndex)
 	}
-	if len(fwdPkg.SettleFails) != 0 {
-		t.Fatalf("bob should forward 0 hltcs, instead has %v",
-			len(fwdPkg.SettleFails))
+	if bobChannel.remoteUpdateLog.htlcCounter != 1 {
+		t.Fatalf("incorrect htlc index: expected %v, got %v", 1,
+			bobChannel.localUpdateLog.htlcCounter)
 	}
 
-	// Finally, have Bob initiate a state transition that locks in the Fail
-	// added after the restart.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatal(err)
-	}
-	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
-	if err != nil {
-		t.Fatal(err)
+	// We'll conclude the test by having Bob settle Alice's HTLC, then
+	// initiate a state transition.
+	err = bobChannel.SettleHTLC(alicePreimage, bobHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, aliceHtlcIndex)
+	require.NoError(t, err, "unable to settle htlc")
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	// When Alice receives the revocation, she should detect that she
-	// can now forward the freshly locked-in Fail.
-	_, adds, settleFails, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	if err != nil {
-		t.Fatal(err)
+	// At this point, the final balances of both parties should properly
+	// reflect the amount of HTLC's sent.
+	bobMsatSent := numBobHtlcs * htlcAmt
+	if aliceChannel.channelState.TotalMSatSent != htlcAmt {
+		t.Fatalf("wrong value for msat sent: expected %v, got %v",
+			htlcAmt, aliceChannel.channelState.TotalMSatSent)
 	}
-	if len(adds) != 0 {
-		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
-			"forward %v htlcs", len(adds))
+	if aliceChannel.channelState.TotalMSatReceived != bobMsatSent {
+		t.Fatalf("wrong value for msat recv: expected %v, got %v",
+			bobMsatSent, aliceChannel.channelState.TotalMSatReceived)
 	}
-	if len(settleFails) != 1 {
-		t.Fatalf("alice should only forward one HTLC, instead wants to "+
-			"forward %v htlcs", len(settleFails))
+	if bobChannel.channelState.TotalMSatSent != bobMsatSent {
+		t.Fatalf("wrong value for msat sent: expected %v, got %v",
+			bobMsatSent, bobChannel.channelState.TotalMSatSent)
 	}
-	if settleFails[0].ParentIndex != htlc2.ID {
-		t.Fatalf("alice should forward fail for htlcid=%d, instead "+
-			"forwarding id=%d", htlc2.ID,
-			settleFails[0].ParentIndex)
+	if bobChannel.channelState.TotalMSatReceived != htlcAmt {
+		t.Fatalf("wrong value for msat recv: expected %v, got %v",
+			htlcAmt, bobChannel.channelState.TotalMSatReceived)
 	}
 }
 
-// TestInvalidCommitSigError tests that if the remote party sends us an invalid
-// commitment signature, then we'll reject it and return a special error that
-// contains information to allow the remote party to debug their issues.
-func TestInvalidCommitSigError(t *testing.T) {
+// TestChanSyncOweCommitmentPendingRemote asserts that local updates are applied
+// to the remote commit across restarts.
+func TestChanSyncOweCommitmentPendingRemote(t *testing.T) {
 	t.Parallel()
 
-	// First, we'll make a channel between Alice and Bob.
+	// Create a test channel which will be used for the duration of this
+	// unittest.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// With the channel established, we'll now send a single HTLC from
-	// Alice to Bob.
-	var htlcAmt lnwire.MilliSatoshi = 100000
-	htlc, _ := createHTLC(0, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+	var fakeOnionBlob [lnwire.OnionPacketSize]byte
+	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
+
+	// We'll start off the scenario where Bob send two htlcs to Alice in a
+	// single state update.
+	var preimages []lntypes.Preimage
+	const numHtlcs = 2
+	for id := byte(0); id < numHtlcs; id++ {
+		htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+		var bobPreimage [32]byte
+		copy(bobPreimage[:], bytes.Repeat([]byte{id}, 32))
+		rHash := sha256.Sum256(bobPreimage[:])
+		h := &lnwire.UpdateAddHTLC{
+			PaymentHash: rHash,
+			Amount:      htlcAmt,
+			Expiry:      uint32(10),
+			OnionBlob:   fakeOnionBlob,
+		}
+
+		htlcIndex, err := bobChannel.AddHTLC(h, nil)
+		if err != nil {
+			t.Fatalf("unable to add bob's htlc: %v", err)
+		}
+
+		h.ID = htlcIndex
+		if _, err := aliceChannel.ReceiveHTLC(h); err != nil {
+			t.Fatalf("unable to recv bob's htlc: %v", err)
+		}
+
+		preimages = append(preimages, bobPreimage)
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+
+	// With the HTLCs applied to both update logs, we'll initiate a state
+	// transition from Bob.
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	// Alice will now attempt to initiate a state transition.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign new commit")
+	// Next, Alice settles the HTLCs from Bob in distinct state updates.
+	for i := 0; i < numHtlcs; i++ {
+		err = aliceChannel.SettleHTLC(preimages[i], uint64(i), nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+		err = bobChannel.ReceiveHTLCSettle(preimages[i], uint64(i))
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
 
-	// Before the signature gets to Bob, we'll mutate it, such that the
-	// signature is now actually invalid.
-	aliceSigCopy := aliceNewCommit.CommitSig.Copy()
-	aliceSigCopyBytes := aliceSigCopy.RawBytes()
-	aliceSigCopyBytes[0] ^= 80
-	aliceNewCommit.CommitSig, err = lnwire.NewSigFromWireECDSA(
-		aliceSigCopyBytes,
-	)
-	require.NoError(t, err)
+		aliceNewCommit, err := aliceChannel.SignNextCommitment()
+		if err != nil {
+			t.Fatalf("unable to sign commitment: %v", err)
+		}
+		err = bobChannel.ReceiveNewCommitment(
+			aliceNewCommit.CommitSigs,
+		)
+		if err != nil {
+			t.Fatalf("unable to receive commitment: %v", err)
+		}
 
-	// Bob should reject this new state, and return the proper error.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err == nil {
-		t.Fatalf("bob accepted invalid state but shouldn't have")
+		// Bob revokes his current commitment. After this call
+		// completes, the htlc is settled on the local commitment
+		// transaction. Bob still owes Alice a signature to also settle
+		// the htlc on her local commitment transaction.
+		bobRevoke, _, _, err := bobChannel.RevokeCurrentCommitment()
+		if err != nil {
+			t.Fatalf("unable to revoke commitment: %v", err)
+		}
+
+		_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevoke)
+		if err != nil {
+			t.Fatalf("unable to revoke commitment: %v", err)
+		}
 	}
-	if _, ok := err.(*InvalidCommitSigError); !ok {
-		t.Fatalf("bob sent incorrect error, expected %T, got %T",
-			&InvalidCommitSigError{}, err)
+
+	// We restart Bob. This should have no impact on further message that
+	// are generated.
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart bob")
+
+	// Bob signs the commitment he owes.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// This commitment is expected to contain no htlcs anymore.
+	if len(bobNewCommit.HtlcSigs) != 0 {
+		t.Fatalf("no htlcs expected, but got %v",
+			len(bobNewCommit.HtlcSigs))
+	}
+
+	// Get Alice to revoke and trigger Bob to compact his logs.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceRevoke, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevoke)
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
-// TestChannelUnilateralCloseHtlcResolution tests that in the case of a
-// unilateral channel closure, then the party that didn't broadcast the
-// commitment is able to properly sweep all relevant outputs.
-func TestChannelUnilateralCloseHtlcResolution(t *testing.T) {
+// TestChanPipelineCommitments tests that with a revocation window larger than
+// one, we're able to extend multiple commitments to the remote party before
+// receiving any revocation, and that the pending commitments survive a
+// restart and are retransmitted in order on reestablishment.
+func TestChanPipelineCommitments(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel which will be used for the duration of this
-	// unittest. The channel will be funded evenly with Alice having 5 BTC,
-	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// We'll start off the test by adding an HTLC in both directions, then
-	// initiating enough state transitions to lock both of them in.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
-	}
-	htlcBob, preimageBob := createHTLC(0, htlcAmount)
-	if _, err := bobChannel.AddHTLC(htlcBob, nil); err != nil {
-		t.Fatalf("bob unable to add htlc: %v", err)
-	}
-	if _, err := aliceChannel.ReceiveHTLC(htlcBob); err != nil {
-		t.Fatalf("alice unable to recv add htlc: %v", err)
-	}
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
-	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("Can't update the channel state: %v", err)
-	}
+	// Recreate Alice's channel with a revocation window of two, and have
+	// Bob hand out the additional commitment point required to make use
+	// of it.
+	aliceChannel, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithRevocationWindow(2),
+	)
+	require.NoError(t, err)
 
-	// With both HTLC's locked in, we'll now simulate Bob force closing the
-	// transaction on Alice.
-	bobForceClose, err := bobChannel.ForceClose()
-	require.NoError(t, err, "unable to close")
+	bobPoints, err := bobChannel.LocalCommitPoints(1)
+	require.NoError(t, err)
+	require.NoError(t, aliceChannel.AddRemoteCommitPoints(bobPoints...))
 
-	// We'll then use Bob's transaction to trigger a spend notification for
-	// Alice.
-	closeTx := bobForceClose.CloseTx
-	commitTxHash := closeTx.TxHash()
-	spendDetail := &chainntnfs.SpendDetail{
-		SpendingTx:    closeTx,
-		SpenderTxHash: &commitTxHash,
-	}
-	aliceCloseSummary, err := NewUnilateralCloseSummary(
-		aliceChannel.channelState, aliceChannel.Signer,
-		spendDetail,
-		aliceChannel.channelState.RemoteCommitment,
-		aliceChannel.channelState.RemoteCurrentRevocation,
+	// Alice adds an HTLC and signs for it, and then adds a second HTLC and
+	// signs again without having received a revocation from Bob.
+	const numHtlcs = 2
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	var (
+		htlcs   []*lnwire.UpdateAddHTLC
+		commits []*NewCommitState
 	)
-	require.NoError(t, err, "unable to create alice close summary")
+	for i := 0; i < numHtlcs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err, "unable to add htlc")
 
-	// She should detect that she can sweep both the outgoing HTLC as well
-	// as the incoming one from Bob.
-	if len(aliceCloseSummary.HtlcResolutions.OutgoingHTLCs) != 1 {
-		t.Fatalf("alice out htlc resolutions not populated: expected %v "+
-			"htlcs, got %v htlcs",
-			1, len(aliceCloseSummary.HtlcResolutions.OutgoingHTLCs))
+		commit, err := aliceChannel.SignNextCommitment()
+		require.NoError(t, err, "unable to sign commitment %v", i)
+
+		htlcs = append(htlcs, htlc)
+		commits = append(commits, commit)
 	}
-	if len(aliceCloseSummary.HtlcResolutions.IncomingHTLCs) != 1 {
-		t.Fatalf("alice in htlc resolutions not populated: expected %v "+
-			"htlcs, got %v htlcs",
-			1, len(aliceCloseSummary.HtlcResolutions.IncomingHTLCs))
+
+	// Both commitments should now be pending, and as the window is
+	// exhausted, Alice shouldn't be able to sign another one.
+	require.Equal(t, numHtlcs, aliceChannel.remoteCommitChain.numUnacked())
+	_, err = aliceChannel.SignNextCommitment()
+	require.ErrorIs(t, err, ErrNoWindow)
+
+	pending, err := aliceChannel.channelState.RemoteCommitChainPending()
+	require.NoError(t, err)
+	require.Len(t, pending, numHtlcs)
+
+	// Restart Alice, she should restore both pending commitments.
+	aliceChannel, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithRevocationWindow(2),
+	)
+	require.NoError(t, err)
+	require.Equal(t, numHtlcs, aliceChannel.remoteCommitChain.numUnacked())
+
+	// As Bob hasn't received any of the commitments, Alice should
+	// retransmit all of them in order on reestablishment.
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err)
+	aliceMsgs, _, _, err := aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	require.NoError(t, err)
+	require.Len(t, aliceMsgs, 2*numHtlcs)
+
+	for i := 0; i < numHtlcs; i++ {
+		add, ok := aliceMsgs[2*i].(*lnwire.UpdateAddHTLC)
+		require.True(t, ok)
+		require.Equal(t, htlcs[i].ID, add.ID)
+		require.Equal(t, htlcs[i].PaymentHash, add.PaymentHash)
+
+		commitSig, ok := aliceMsgs[2*i+1].(*lnwire.CommitSig)
+		require.True(t, ok)
+		require.Equal(t, commits[i].CommitSig, commitSig.CommitSig)
 	}
 
-	outHtlcResolution := aliceCloseSummary.HtlcResolutions.OutgoingHTLCs[0]
-	inHtlcResolution := aliceCloseSummary.HtlcResolutions.IncomingHTLCs[0]
+	// Bob now processes the updates and commitments in order, revoking
+	// his prior commitment after each of them.
+	var bobRevocations []*lnwire.RevokeAndAck
+	for i := 0; i < numHtlcs; i++ {
+		_, err := bobChannel.ReceiveHTLC(htlcs[i])
+		require.NoError(t, err, "unable to recv htlc")
 
-	// First, we'll ensure that Alice can directly spend the outgoing HTLC
-	// given a transaction with the proper lock time set.
-	receiverHtlcScript := closeTx.TxOut[outHtlcResolution.ClaimOutpoint.Index].PkScript
-	sweepTx := wire.NewMsgTx(2)
-	sweepTx.AddTxIn(&wire.TxIn{
-		PreviousOutPoint: outHtlcResolution.ClaimOutpoint,
-	})
-	sweepTx.AddTxOut(&wire.TxOut{
-		PkScript: receiverHtlcScript,
-		Value:    outHtlcResolution.SweepSignDesc.Output.Value,
-	})
-	outHtlcResolution.SweepSignDesc.InputIndex = 0
-	outHtlcResolution.SweepSignDesc.SigHashes = input.NewTxSigHashesV0Only(
-		sweepTx,
-	)
-	sweepTx.LockTime = outHtlcResolution.Expiry
+		err = bobChannel.ReceiveNewCommitment(commits[i].CommitSigs)
+		require.NoError(t, err, "unable to receive commitment %v", i)
 
-	// With the transaction constructed, we'll generate a witness that
-	// should be valid for it, and verify using an instance of Script.
-	sweepTx.TxIn[0].Witness, err = input.ReceiverHtlcSpendTimeout(
-		aliceChannel.Signer, &outHtlcResolution.SweepSignDesc,
-		sweepTx, int32(outHtlcResolution.Expiry),
-	)
-	require.NoError(t, err, "unable to witness")
-	vm, err := txscript.NewEngine(
-		outHtlcResolution.SweepSignDesc.Output.PkScript,
-		sweepTx, 0, txscript.StandardVerifyFlags, nil,
-		nil, outHtlcResolution.SweepSignDesc.Output.Value,
-		txscript.NewCannedPrevOutputFetcher(
-			outHtlcResolution.SweepSignDesc.Output.PkScript,
-			outHtlcResolution.SweepSignDesc.Output.Value,
-		),
-	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc timeout spend is invalid: %v", err)
-	}
+		revocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+		require.NoError(t, err, "unable to revoke commitment %v", i)
 
-	// Next, we'll ensure that we're able to sweep the incoming HTLC with a
-	// similar sweep transaction, this time using the payment pre-image.
-	senderHtlcScript := closeTx.TxOut[inHtlcResolution.ClaimOutpoint.Index].PkScript
-	sweepTx = wire.NewMsgTx(2)
-	sweepTx.AddTxIn(&wire.TxIn{
-		PreviousOutPoint: inHtlcResolution.ClaimOutpoint,
-	})
-	sweepTx.AddTxOut(&wire.TxOut{
-		PkScript: senderHtlcScript,
-		Value:    inHtlcResolution.SweepSignDesc.Output.Value,
-	})
-	inHtlcResolution.SweepSignDesc.InputIndex = 0
-	inHtlcResolution.SweepSignDesc.SigHashes = input.NewTxSigHashesV0Only(
-		sweepTx,
-	)
-	sweepTx.TxIn[0].Witness, err = input.SenderHtlcSpendRedeem(
-		aliceChannel.Signer, &inHtlcResolution.SweepSignDesc,
-		sweepTx, preimageBob[:],
-	)
-	if err != nil {
-		t.Fatalf("unable to generate witness for success "+
-			"output: %v", err)
+		bobRevocations = append(bobRevocations, revocation)
 	}
 
-	// Finally, we'll verify the constructed witness to ensure that Alice
-	// can properly sweep the output.
-	vm, err = txscript.NewEngine(
-		inHtlcResolution.SweepSignDesc.Output.PkScript,
-		sweepTx, 0, txscript.StandardVerifyFlags, nil,
-		nil, inHtlcResolution.SweepSignDesc.Output.Value,
-		txscript.NewCannedPrevOutputFetcher(
-			inHtlcResolution.SweepSignDesc.Output.PkScript,
-			inHtlcResolution.SweepSignDesc.Output.Value,
-		),
-	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc timeout spend is invalid: %v", err)
+	// A revocation handing Alice a different commitment point than the
+	// one Bob gave out before should be rejected.
+	badRevocation := *bobRevocations[0]
+	badRevocation.NextRevocationKey = bobChannel.channelState.
+		RemoteCurrentRevocation
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(&badRevocation)
+	require.Error(t, err)
+
+	// Alice receives both revocations, each of them should advance the
+	// tail of Bob's commitment chain by one.
+	for i, revocation := range bobRevocations {
+		_, _, _, _, err := aliceChannel.ReceiveRevocation(revocation)
+		require.NoError(t, err, "unable to receive revocation %v", i)
+
+		require.Equal(
+			t, numHtlcs-i-1,
+			aliceChannel.remoteCommitChain.numUnacked(),
+		)
 	}
-}
+	require.Empty(t, aliceChannel.channelState.RemoteCommitPoints)
 
-// TestChannelUnilateralClosePendingCommit tests that if the remote party
-// broadcasts their pending commit (hasn't yet revoked the lower one), then
-// we'll create a proper unilateral channel clsoure that can sweep the created
-// outputs.
-func TestChannelUnilateralClosePendingCommit(t *testing.T) {
-	t.Parallel()
+	pending, err = aliceChannel.channelState.RemoteCommitChainPending()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	// Finally, Bob signs for Alice's commitment, locking in both HTLCs.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign commitment")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to receive commitment")
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
+	fwdPkg, _, _, _, err := bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to receive revocation")
+	require.Len(t, fwdPkg.Adds, numHtlcs)
+
+	require.Len(t, aliceChannel.channelState.LocalCommitment.Htlcs, numHtlcs)
+	require.Len(t, bobChannel.channelState.LocalCommitment.Htlcs, numHtlcs)
+
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
+}
 
+// testChanSyncOweRevocation is the internal version of
+// TestChanSyncOweRevocation that is parameterized based on the type of channel
+// being used in the test.
+func testChanSyncOweRevocation(t *testing.T, chanType channeldb.ChannelType) {
 	// Create a test channel which will be used for the duration of this
 	// unittest. The channel will be funded evenly with Alice having 5 BTC,
 	// and Bob having 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderBit,
-	)
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll add an HTLC from Alice to Bob, just to be be able to
-	// create a new state transition.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
+	chanID := lnwire.NewChanIDFromOutPoint(
+		&aliceChannel.channelState.FundingOutpoint,
+	)
+
+	// We'll start the test with Bob extending a single HTLC to Alice, and
+	// then initiating a state transition.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	rHash := sha256.Sum256(bobPreimage[:])
+	bobHtlc := &lnwire.UpdateAddHTLC{
+		ChanID:      chanID,
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
+	bobHtlcIndex, err := bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err, "unable to add bob's htlc")
+	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err, "unable to recv bob's htlc")
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	// With the HTLC added, we'll now manually initiate a state transition
-	// from Alice to Bob.
-	_, err = aliceChannel.SignNextCommitment()
-	if err != nil {
-		t.Fatal(err)
-	}
+	// Next, Alice will settle that single HTLC, the _begin_ the start of a
+	// state transition.
+	err = aliceChannel.SettleHTLC(bobPreimage, aliceHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = bobChannel.ReceiveHTLCSettle(bobPreimage, bobHtlcIndex)
+	require.NoError(t, err, "unable to settle htlc")
 
-	// At this point, Alice's commitment chain should have a new pending
-	// commit for Bob. We'll extract it so we can simulate Bob broadcasting
-	// the commitment due to an issue.
-	bobCommit := aliceChannel.remoteCommitChain.tip().txn
-	bobTxHash := bobCommit.TxHash()
-	spendDetail := &chainntnfs.SpendDetail{
-		SpenderTxHash: &bobTxHash,
-		SpendingTx:    bobCommit,
-	}
+	// We'll model the state transition right up until Alice needs to send
+	// her revocation message to complete the state transition.
+	//
+	// Alice signs the next state, then Bob receives and sends his
+	// revocation message.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's commitment")
 
-	// At this point, if we attempt to create a unilateral close summary
-	// using this commitment, but with the wrong state, we should find that
-	// our output wasn't picked up.
-	aliceWrongCloseSummary, err := NewUnilateralCloseSummary(
-		aliceChannel.channelState, aliceChannel.Signer,
-		spendDetail,
-		aliceChannel.channelState.RemoteCommitment,
-		aliceChannel.channelState.RemoteCurrentRevocation,
-	)
-	require.NoError(t, err, "unable to create alice close summary")
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign commitment")
 
-	if aliceWrongCloseSummary.CommitResolution != nil {
-		t.Fatalf("alice shouldn't have found self output")
-	}
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to recv revocation")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to rev bob's commitment")
 
-	// If we create the close summary again, but this time use Alice's
-	// pending commit to Bob, then the unilateral close summary should be
-	// properly populated.
-	aliceRemoteChainTip, err := aliceChannel.channelState.RemoteCommitChainTip()
-	require.NoError(t, err, "unable to fetch remote chain tip")
-	aliceCloseSummary, err := NewUnilateralCloseSummary(
-		aliceChannel.channelState, aliceChannel.Signer,
-		spendDetail,
-		aliceRemoteChainTip.Commitment,
-		aliceChannel.channelState.RemoteNextRevocation,
-	)
-	require.NoError(t, err, "unable to create alice close summary")
+	// At this point, we'll simulate the connection breaking down by Bob's
+	// lack of knowledge of the revocation message that Alice just sent.
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
 
-	// With this proper version, Alice's commit resolution should have been
-	// properly located.
-	if aliceCloseSummary.CommitResolution == nil {
-		t.Fatalf("unable to find alice's commit resolution")
+	// If we fetch the channel sync messages at this state, then Alice
+	// should report that she owes Bob a revocation message, while Bob
+	// thinks they're fully in sync.
+	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+
+	// For taproot channels, simulate the link/peer binding the generated
+	// nonces.
+	if chanType.IsTaproot() {
+		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *aliceSyncMsg.LocalNonce,
+		}
+		bobChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *bobSyncMsg.LocalNonce,
+		}
 	}
 
-	// The proper short channel ID should also be set in Alice's close
-	// channel summary.
-	if aliceCloseSummary.ChannelCloseSummary.ShortChanID !=
-		aliceChannel.ShortChanID() {
+	assertAliceOwesRevoke := func() {
+		t.Helper()
 
-		t.Fatalf("wrong short chan ID, expected %v got %v",
-			aliceChannel.ShortChanID(),
-			aliceCloseSummary.ChannelCloseSummary.ShortChanID)
+		aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(
+			bobSyncMsg,
+		)
+		if err != nil {
+			t.Fatalf("unable to process chan sync msg: %v", err)
+		}
+		if len(aliceMsgsToSend) != 1 {
+			t.Fatalf("expected single message retransmission from Alice, "+
+				"instead got %v", spew.Sdump(aliceMsgsToSend))
+		}
+		aliceReRevoke, ok := aliceMsgsToSend[0].(*lnwire.RevokeAndAck)
+		if !ok {
+			t.Fatalf("expected to retransmit revocation msg, instead "+
+				"have: %v", spew.Sdump(aliceMsgsToSend[0]))
+		}
+
+		// Alice should re-send the revocation message for her prior
+		// state.
+		expectedRevocation, err := aliceChannel.generateRevocation(
+			aliceChannel.currentHeight - 1,
+		)
+		if err != nil {
+			t.Fatalf("unable to regenerate revocation: %v", err)
+		}
+		if !reflect.DeepEqual(expectedRevocation, aliceReRevoke) {
+			t.Fatalf("wrong re-revocation: expected %v, got %v",
+				spew.Sdump(expectedRevocation),
+				spew.Sdump(aliceReRevoke))
+		}
 	}
 
-	aliceSignDesc := aliceCloseSummary.CommitResolution.SelfOutputSignDesc
+	// From Bob's PoV he shouldn't think that he owes Alice any messages.
+	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(bobMsgsToSend) != 0 {
+		t.Fatalf("expected bob to not retransmit, instead has: %v",
+			spew.Sdump(bobMsgsToSend))
+	}
 
-	// Finally, we'll ensure that we're able to properly sweep our output
-	// from using the materials within the unilateral close summary.
-	sweepTx := wire.NewMsgTx(2)
-	sweepTx.AddTxIn(&wire.TxIn{
-		PreviousOutPoint: aliceCloseSummary.CommitResolution.SelfOutPoint,
-	})
-	sweepTx.AddTxOut(&wire.TxOut{
-		PkScript: testHdSeed[:],
-		Value:    aliceSignDesc.Output.Value,
-	})
-	aliceSignDesc.SigHashes = input.NewTxSigHashesV0Only(sweepTx)
-	sweepTx.TxIn[0].Witness, err = input.CommitSpendNoDelay(
-		aliceChannel.Signer, &aliceSignDesc, sweepTx, false,
-	)
-	require.NoError(t, err, "unable to generate sweep witness")
+	// Alice should detect that she owes Bob a revocation message, and only
+	// that single message.
+	assertAliceOwesRevoke()
 
-	// If we validate the signature on the new sweep transaction, it should
-	// be fully valid.
-	vm, err := txscript.NewEngine(
-		aliceSignDesc.Output.PkScript, sweepTx, 0,
-		txscript.StandardVerifyFlags, nil, nil,
-		aliceSignDesc.Output.Value,
-		txscript.NewCannedPrevOutputFetcher(
-			aliceSignDesc.Output.PkScript,
-			aliceSignDesc.Output.Value,
-		),
-	)
-	require.NoError(t, err, "unable to create engine")
-	if err := vm.Execute(); err != nil {
-		t.Fatalf("htlc timeout spend is invalid: %v", err)
+	// If we restart Alice, then she should still decide that she owes a
+	// revocation message to Bob.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart alice")
+
+	// For taproot channels, simulate the link/peer binding the generated
+	// nonces.
+	if chanType.IsTaproot() {
+		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *aliceSyncMsg.LocalNonce,
+		}
+		bobChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *bobSyncMsg.LocalNonce,
+		}
 	}
-}
 
-// TestDesyncHTLCs checks that we cannot add HTLCs that would make the
-// balance negative, when the remote and local update logs are desynced.
-func TestDesyncHTLCs(t *testing.T) {
-	t.Parallel()
+	assertAliceOwesRevoke()
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	// TODO(roasbeef): restart bob too???
 
-	// First add one HTLC of value 4.1 BTC.
-	htlcAmt := lnwire.NewMSatFromSatoshis(4.1 * btcutil.SatoshiPerBitcoin)
-	htlc, _ := createHTLC(0, htlcAmt)
-	aliceIndex, err := aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "unable to add htlc")
-	bobIndex, err := bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "unable to recv htlc")
+	// We'll continue by then allowing bob to process Alice's revocation
+	// message.
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to recv revocation")
 
-	// Lock this HTLC in.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
+	// Finally, Alice will add an HTLC over her own such that we assert the
+	// channel can continue to receive updates.
+	var alicePreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	rHash = sha256.Sum256(alicePreimage[:])
+	aliceHtlc := &lnwire.UpdateAddHTLC{
+		ChanID:      chanID,
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
 	}
-
-	// Now let Bob fail this HTLC.
-	err = bobChannel.FailHTLC(bobIndex, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	if err := aliceChannel.ReceiveFailHTLC(aliceIndex, []byte("bad")); err != nil {
-		t.Fatalf("unable to recv htlc cancel: %v", err)
+	if _, err := aliceChannel.AddHTLC(aliceHtlc, nil); err != nil {
+		t.Fatalf("unable to add alice's htlc: %v", err)
 	}
-
-	// Alice now has gotten all her original balance (5 BTC) back, however,
-	// adding a new HTLC at this point SHOULD fail, since if she adds the
-	// HTLC and signs the next state, Bob cannot assume she received the
-	// FailHTLC, and must assume she doesn't have the necessary balance
-	// available.
-	//
-	// We try adding an HTLC of value 1 BTC, which should fail because the
-	// balance is unavailable.
-	htlcAmt = lnwire.NewMSatFromSatoshis(1 * btcutil.SatoshiPerBitcoin)
-	htlc, _ = createHTLC(1, htlcAmt)
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
-
-	// Now do a state transition, which will ACK the FailHTLC, making Alice
-	// able to add the new HTLC.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
+	if _, err := bobChannel.ReceiveHTLC(aliceHtlc); err != nil {
+		t.Fatalf("unable to recv alice's htlc: %v", err)
 	}
-	if _, err = aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete alice's state transition: %v", err)
 	}
-}
 
-// TODO(roasbeef): testing.Quick test case for retrans!!!
+	// At this point, both sides should detect that they're fully synced.
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
+}
 
-// TestMaxAcceptedHTLCs tests that the correct error message (ErrMaxHTLCNumber)
-// is thrown when a node tries to accept more than MaxAcceptedHTLCs in a
-// channel.
-func TestMaxAcceptedHTLCs(t *testing.T) {
+// TestChanSyncOweRevocation tests that if Bob restarts (and then Alice) before
+// he receiver's Alice's RevokeAndAck message, then Alice concludes that she
+// needs to re-send the RevokeAndAck. After the revocation has been sent, both
+// nodes should be able to successfully complete another state transition.
+func TestChanSyncOweRevocation(t *testing.T) {
 	t.Parallel()
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
+	t.Run("tweakless", func(t *testing.T) {
+		testChanSyncOweRevocation(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("taproot", func(t *testing.T) {
+		taprootBits := channeldb.SimpleTaprootFeatureBit |
+			channeldb.AnchorOutputsBit |
+			channeldb.ZeroHtlcTxFeeBit |
+			channeldb.SingleFunderTweaklessBit
+
+		testChanSyncOweRevocation(t, taprootBits)
+	})
+}
+
+func testChanSyncOweRevocationAndCommit(t *testing.T,
+	chanType channeldb.ChannelType) {
+
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
 	require.NoError(t, err, "unable to create test channels")
 
-	// One over the maximum number of HTLCs that either can accept.
-	const numHTLCs = 12
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
 
-	// Set the remote's required MaxAcceptedHtlcs. This means that Alice
-	// can only offer the remote up to numHTLCs HTLCs.
-	aliceChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
-	bobChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+	// We'll kick off the test by having Bob send Alice an HTLC, then lock
+	// it in with a state transition.
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	rHash := sha256.Sum256(bobPreimage[:])
+	bobHtlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
+	}
+	bobHtlcIndex, err := bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err, "unable to add bob's htlc")
+	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err, "unable to recv bob's htlc")
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
+	}
 
-	// Similarly, set the remote config's MaxAcceptedHtlcs. This means
-	// that the remote will be aware that Bob will only accept up to
-	// numHTLCs at a time.
-	aliceChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
-	bobChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+	// Next, Alice will settle that incoming HTLC, then we'll start the
+	// core of the test itself.
+	err = aliceChannel.SettleHTLC(bobPreimage, aliceHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = bobChannel.ReceiveHTLCSettle(bobPreimage, bobHtlcIndex)
+	require.NoError(t, err, "unable to settle htlc")
 
-	// Each HTLC amount is 0.1 BTC.
-	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+	// Progressing the exchange: Alice will send her signature, Bob will
+	// receive, send a revocation and also a signature for Alice's state.
+	aliceNewCommits, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's commitment")
 
-	// htlcID is used to keep track of the HTLC that Bob will fail back to
-	// Alice.
-	var htlcID uint64
+	// Bob generates the revoke and sig message, but the messages don't
+	// reach Alice before the connection dies.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign commitment")
 
-	// Send the maximum allowed number of HTLCs.
-	for i := 0; i < numHTLCs; i++ {
-		htlc, _ := createHTLC(i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
+	// If we now attempt to resync, then Alice should conclude that she
+	// doesn't need any further updates, while Bob concludes that he needs
+	// to re-send both his revocation and commit sig message.
+	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+
+	// For taproot channels, simulate the link/peer binding the generated
+	// nonces.
+	if chanType.IsTaproot() {
+		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *aliceSyncMsg.LocalNonce,
 		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
+		bobChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *bobSyncMsg.LocalNonce,
 		}
-
-		// Just assign htlcID to the last received HTLC.
-		htlcID = htlc.ID
 	}
 
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to transition state: %v", err)
+	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(aliceMsgsToSend) != 0 {
+		t.Fatalf("expected alice to not retransmit, instead she's "+
+			"sending: %v", spew.Sdump(aliceMsgsToSend))
 	}
 
-	// The next HTLC should fail with ErrMaxHTLCNumber.
-	htlc, _ := createHTLC(numHTLCs, htlcAmt)
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	if err != ErrMaxHTLCNumber {
-		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
-	}
+	assertBobSendsRevokeAndCommit := func() {
+		t.Helper()
 
-	// Receiving the next HTLC should fail.
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != ErrMaxHTLCNumber {
-		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
-	}
-
-	// Bob will fail the htlc specified by htlcID and then force a state
-	// transition.
-	err = bobChannel.FailHTLC(htlcID, []byte{}, nil, nil, nil)
-	require.NoError(t, err, "unable to fail htlc")
+		bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(
+			aliceSyncMsg,
+		)
+		if err != nil {
+			t.Fatalf("unable to process chan sync msg: %v", err)
+		}
+		if len(bobMsgsToSend) != 2 {
+			t.Fatalf("expected bob to send %v messages, instead "+
+				"sends: %v", 2, spew.Sdump(bobMsgsToSend))
+		}
+		bobReRevoke, ok := bobMsgsToSend[0].(*lnwire.RevokeAndAck)
+		if !ok {
+			t.Fatalf("expected bob to re-send revoke, instead "+
+				"sending: %v", spew.Sdump(bobMsgsToSend[0]))
+		}
+		if !reflect.DeepEqual(bobReRevoke, bobRevocation) {
+			t.Fatalf("revocation msgs don't match: expected %v, "+
+				"got %v", bobRevocation, bobReRevoke)
+		}
 
-	if err := aliceChannel.ReceiveFailHTLC(htlcID, []byte{}); err != nil {
-		t.Fatalf("unable to receive fail htlc: %v", err)
-	}
+		bobReCommitSigMsg, ok := bobMsgsToSend[1].(*lnwire.CommitSig)
+		if !ok {
+			t.Fatalf("expected bob to re-send commit sig, "+
+				"instead sending: %v",
+				spew.Sdump(bobMsgsToSend[1]))
+		}
+		if bobReCommitSigMsg.CommitSig != bobNewCommit.CommitSig {
+			t.Fatalf("commit sig msgs don't match: expected %x "+
+				"got %x",
+				bobNewCommit.CommitSigs.CommitSig,
+				bobReCommitSigMsg.CommitSig)
+		}
+		if len(bobReCommitSigMsg.HtlcSigs) !=
+			len(bobNewCommit.HtlcSigs) {
 
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to transition state: %v", err)
+			t.Fatalf("wrong number of htlc sigs: expected %v, "+
+				"got %v",
+				len(bobNewCommit.HtlcSigs),
+				len(bobReCommitSigMsg.HtlcSigs))
+		}
+		for i, htlcSig := range bobReCommitSigMsg.HtlcSigs {
+			if htlcSig != bobNewCommit.HtlcSigs[i] {
+				t.Fatalf("htlc sig msgs don't match: "+
+					"expected %x got %x",
+					htlcSig,
+					bobNewCommit.HtlcSigs[i])
+			}
+		}
 	}
 
-	// Bob should succeed in adding a new HTLC since a previous HTLC was just
-	// failed. We use numHTLCs here since the previous AddHTLC with this index
-	// failed.
-	htlc, _ = createHTLC(numHTLCs, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
-	}
+	// We expect Bob to send exactly two messages: first his revocation
+	// message to Alice, and second his original commit sig message.
+	assertBobSendsRevokeAndCommit()
 
-	// Add a commitment to Bob's commitment chain.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign next commitment")
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to recv new commitment")
+	// At this point we simulate the connection failing with a restart from
+	// Bob. He should still re-send the exact same set of messages.
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart channel")
 
-	// The next HTLC should fail with ErrMaxHTLCNumber. The index is incremented
-	// by one.
-	htlc, _ = createHTLC(numHTLCs+1, htlcAmt)
-	if _, err = aliceChannel.AddHTLC(htlc, nil); err != ErrMaxHTLCNumber {
-		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
+	// For taproot channels, simulate the link/peer binding the generated
+	// nonces.
+	if chanType.IsTaproot() {
+		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *aliceSyncMsg.LocalNonce,
+		}
+		bobChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *bobSyncMsg.LocalNonce,
+		}
 	}
 
-	// Likewise, Bob should not be able to receive this HTLC if Alice can't
-	// add it.
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != ErrMaxHTLCNumber {
-		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
-	}
+	assertBobSendsRevokeAndCommit()
+
+	// We'll now finish the state transition by having Alice process both
+	// messages, and send her final revocation.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to recv revocation")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to recv bob's commitment")
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to recv revocation")
 }
 
-// TestMaxAsynchronousHtlcs tests that Bob correctly receives (and does not
-// fail) an HTLC from Alice when exchanging asynchronous payments. We want to
-// mimic the following case where Bob's commitment transaction is full before
-// starting:
-//
-//	Alice                    Bob
-//
-// 1.         <---settle/fail---
-// 2.         <-------sig-------
-// 3.         --------sig------> (covers an add sent before step 1)
-// 4.         <-------rev-------
-// 5.         --------rev------>
-// 6.         --------add------>
-// 7.         - - - - sig - - ->
-// This represents an asynchronous commitment dance in which both sides are
-// sending signatures at the same time. In step 3, the signature does not
-// cover the recent settle/fail that Bob sent in step 1. However, the add that
-// Alice sends to Bob in step 6 does not overflow Bob's commitment transaction.
-// This is because validateCommitmentSanity counts the HTLC's by ignoring
-// HTLC's which will be removed in the next signature that Alice sends. Thus,
-// the add won't overflow. This is because the signature received in step 7
-// covers the settle/fail in step 1 and makes space for the add in step 6.
-func TestMaxAsynchronousHtlcs(t *testing.T) {
+// TestChanSyncOweRevocationAndCommit tests that if Alice initiates a state
+// transition with Bob and Bob sends both a RevokeAndAck and CommitSig message
+// but Alice doesn't receive them before the connection dies, then he'll
+// retransmit them both.
+func TestChanSyncOweRevocationAndCommit(t *testing.T) {
 	t.Parallel()
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
-
-	// One over the maximum number of HTLCs that either can accept.
-	const numHTLCs = 12
-
-	// Set the remote's required MaxAcceptedHtlcs. This means that Alice
-	// can only offer the remote up to numHTLCs HTLCs.
-	aliceChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
-	bobChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
-
-	// Similarly, set the remote config's MaxAcceptedHtlcs. This means
-	// that the remote will be aware that Bob will only accept up to
-	// numHTLCs at a time.
-	aliceChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
-	bobChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
-
-	// Each HTLC amount is 0.1 BTC.
-	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+	t.Run("tweakless", func(t *testing.T) {
+		testChanSyncOweRevocationAndCommit(
+			t, channeldb.SingleFunderTweaklessBit,
+		)
+	})
+	t.Run("taproot", func(t *testing.T) {
+		taprootBits := channeldb.SimpleTaprootFeatureBit |
+			channeldb.AnchorOutputsBit |
+			channeldb.ZeroHtlcTxFeeBit |
+			channeldb.SingleFunderTweaklessBit
 
-	var htlcID uint64
+		testChanSyncOweRevocationAndCommit(t, taprootBits)
+	})
+}
 
-	// Send the maximum allowed number of HTLCs minus one.
-	for i := 0; i < numHTLCs-1; i++ {
-		htlc, _ := createHTLC(i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
-		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
-		}
+func testChanSyncOweRevocationAndCommitForceTransition(t *testing.T,
+	chanType channeldb.ChannelType) {
 
-		// Just assign htlcID to the last received HTLC.
-		htlcID = htlc.ID
-	}
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
 
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to transition state: %v", err)
-	}
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
 
-	// Send an HTLC to Bob so that Bob's commitment transaction is full.
-	htlc, _ := createHTLC(numHTLCs-1, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+	// We'll kick off the test by having Bob send Alice an HTLC, then lock
+	// it in with a state transition.
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	rHash := sha256.Sum256(bobPreimage[:])
+	var bobHtlc [2]*lnwire.UpdateAddHTLC
+	bobHtlc[0] = &lnwire.UpdateAddHTLC{
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+	bobHtlcIndex, err := bobChannel.AddHTLC(bobHtlc[0], nil)
+	require.NoError(t, err, "unable to add bob's htlc")
+	aliceHtlcIndex, err := aliceChannel.ReceiveHTLC(bobHtlc[0])
+	require.NoError(t, err, "unable to recv bob's htlc")
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	// Fail back an HTLC and sign a commitment as in steps 1 & 2.
-	err = bobChannel.FailHTLC(htlcID, []byte{}, nil, nil, nil)
-	require.NoError(t, err, "unable to fail htlc")
-
-	if err := aliceChannel.ReceiveFailHTLC(htlcID, []byte{}); err != nil {
-		t.Fatalf("unable to receive fail htlc: %v", err)
+	// To ensure the channel sync logic handles the case where the two
+	// commit chains are at different heights, we'll add another HTLC from
+	// Bob to Alice, but let Alice skip the commitment for this state
+	// update.
+	rHash = sha256.Sum256(bytes.Repeat([]byte{0xbb}, 32))
+	bobHtlc[1] = &lnwire.UpdateAddHTLC{
+		PaymentHash: rHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(10),
+		ID:          1,
 	}
+	_, err = bobChannel.AddHTLC(bobHtlc[1], nil)
+	require.NoError(t, err, "unable to add bob's htlc")
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc[1])
+	require.NoError(t, err, "unable to recv bob's htlc")
 
+	// Bob signs the new state update, and sends the signature to Alice.
 	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign next commitment")
+	require.NoError(t, err, "bob unable to sign commitment")
 
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive new commitment")
-
-	// Cover the HTLC referenced with id equal to numHTLCs-1 with a new
-	// signature (step 3).
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign next commitment")
-
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive new commitment")
-
-	// Both sides exchange revocations as in step 4 & 5.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke revocation")
-
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "unable to receive revocation")
+	require.NoError(t, err, "alice unable to rev bob's commitment")
 
+	// Alice revokes her current state, but doesn't immediately send a
+	// signature for Bob's updated state. Instead she will issue a new
+	// update before sending a new CommitSig. This will lead to Alice's
+	// local commit chain getting height > remote commit chain.
 	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke revocation")
-
+	require.NoError(t, err, "alice unable to revoke commitment")
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "unable to receive revocation")
-
-	// Send the final Add which should succeed as in step 6.
-	htlc, _ = createHTLC(numHTLCs, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
-	}
-
-	// Receiving the commitment should succeed as in step 7 since space was
-	// made.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign next commitment")
-
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive new commitment")
-}
+	require.NoError(t, err, "bob unable to recv revocation")
 
-// TestMaxPendingAmount tests that the maximum overall pending HTLC value is met
-// given several HTLCs that, combined, exceed this value. An ErrMaxPendingAmount
-// error should be returned.
-func TestMaxPendingAmount(t *testing.T) {
-	t.Parallel()
+	// Next, Alice will settle that incoming HTLC, then we'll start the
+	// core of the test itself.
+	err = aliceChannel.SettleHTLC(bobPreimage, aliceHtlcIndex, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = bobChannel.ReceiveHTLCSettle(bobPreimage, bobHtlcIndex)
+	require.NoError(t, err, "unable to settle htlc")
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	// Progressing the exchange: Alice will send her signature, with Bob
+	// processing the new state locally.
+	aliceNewCommits, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommits.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's commitment")
 
-	// We set the remote required MaxPendingAmount to 3 BTC. We will
-	// attempt to overflow this value and see if it gives us the
-	// ErrMaxPendingAmount error.
-	maxPending := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin * 3)
+	// Bob then sends his revocation message, but before Alice can process
+	// it (and before he scan send his CommitSig message), then connection
+	// dies.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
 
-	// We set the max pending amount of Alice's config. This mean that she
-	// cannot offer Bob HTLCs with a total value above this limit at a given
-	// time.
-	aliceChannel.channelState.LocalChanCfg.MaxPendingAmount = maxPending
-	bobChannel.channelState.RemoteChanCfg.MaxPendingAmount = maxPending
+	// Now if we attempt to synchronize states at this point, Alice should
+	// detect that she owes nothing, while Bob should re-send both his
+	// RevokeAndAck as well as his commitment message.
+	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
 
-	// First, we'll add 2 HTLCs of 1.5 BTC each to Alice's commitment.
-	// This won't trigger Alice's ErrMaxPendingAmount error.
-	const numHTLCs = 2
-	htlcAmt := lnwire.NewMSatFromSatoshis(1.5 * btcutil.SatoshiPerBitcoin)
-	for i := 0; i < numHTLCs; i++ {
-		htlc, _ := createHTLC(i, htlcAmt)
-		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-			t.Fatalf("unable to add htlc: %v", err)
+	// For taproot channels, simulate the link/peer binding the generated
+	// nonces.
+	if chanType.IsTaproot() {
+		aliceChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *aliceSyncMsg.LocalNonce,
 		}
-		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-			t.Fatalf("unable to recv htlc: %v", err)
+		bobChannel.pendingVerificationNonce = &musig2.Nonces{
+			PubNonce: *bobSyncMsg.LocalNonce,
 		}
 	}
 
-	// We finally add one more HTLC of 0.1 BTC to Alice's commitment. This
-	// SHOULD trigger Alice's ErrMaxPendingAmount error.
-	htlcAmt = lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
-	htlc, _ := createHTLC(numHTLCs, htlcAmt)
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	if err != ErrMaxPendingAmount {
-		t.Fatalf("expected ErrMaxPendingAmount, instead received: %v", err)
+	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(aliceMsgsToSend) != 0 {
+		t.Fatalf("expected alice to not retransmit, instead she's "+
+			"sending: %v", spew.Sdump(aliceMsgsToSend))
 	}
 
-	// And also Bob shouldn't be accepting this HTLC upon calling ReceiveHTLC.
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != ErrMaxPendingAmount {
-		t.Fatalf("expected ErrMaxPendingAmount, instead received: %v", err)
+	// If we process Alice's sync message from Bob's PoV, then he should
+	// send his RevokeAndAck message again. Additionally, the CommitSig
+	// message that he sends should be sufficient to finalize the state
+	// transition.
+	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(bobMsgsToSend) != 2 {
+		t.Fatalf("expected bob to send %v messages, instead "+
+			"sends: %v", 2, spew.Sdump(bobMsgsToSend))
+	}
+	bobReRevoke, ok := bobMsgsToSend[0].(*lnwire.RevokeAndAck)
+	if !ok {
+		t.Fatalf("expected bob to re-send revoke, instead sending: %v",
+			spew.Sdump(bobMsgsToSend[0]))
+	}
+	if !reflect.DeepEqual(bobReRevoke, bobRevocation) {
+		t.Fatalf("revocation msgs don't match: expected %v, got %v",
+			bobRevocation, bobReRevoke)
 	}
-}
-
-func assertChannelBalances(t *testing.T, alice, bob *LightningChannel,
-	aliceBalance, bobBalance btcutil.Amount) {
 
-	_, _, line, _ := runtime.Caller(1)
+	// The second message should be his CommitSig message that he never
+	// sent, but will send in order to force both states to synchronize.
+	bobReCommitSigMsg, ok := bobMsgsToSend[1].(*lnwire.CommitSig)
+	if !ok {
+		t.Fatalf("expected bob to re-send commit sig, instead sending: %v",
+			spew.Sdump(bobMsgsToSend[1]))
+	}
 
-	aliceSelfBalance := alice.channelState.LocalCommitment.LocalBalance.ToSatoshis()
-	aliceBobBalance := alice.channelState.LocalCommitment.RemoteBalance.ToSatoshis()
-	if aliceSelfBalance != aliceBalance {
-		t.Fatalf("line #%v: wrong alice self balance: expected %v, got %v",
-			line, aliceBalance, aliceSelfBalance)
+	// At this point we simulate the connection failing with a restart from
+	// Bob. He should still re-send the exact same set of messages.
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart channel")
+	if len(bobMsgsToSend) != 2 {
+		t.Fatalf("expected bob to send %v messages, instead "+
+			"sends: %v", 2, spew.Sdump(bobMsgsToSend))
 	}
-	if aliceBobBalance != bobBalance {
-		t.Fatalf("line #%v: wrong alice bob's balance: expected %v, got %v",
-			line, bobBalance, aliceBobBalance)
+	bobReRevoke, ok = bobMsgsToSend[0].(*lnwire.RevokeAndAck)
+	if !ok {
+		t.Fatalf("expected bob to re-send revoke, instead sending: %v",
+			spew.Sdump(bobMsgsToSend[0]))
 	}
-
-	bobSelfBalance := bob.channelState.LocalCommitment.LocalBalance.ToSatoshis()
-	bobAliceBalance := bob.channelState.LocalCommitment.RemoteBalance.ToSatoshis()
-	if bobSelfBalance != bobBalance {
-		t.Fatalf("line #%v: wrong bob self balance: expected %v, got %v",
-			line, bobBalance, bobSelfBalance)
+	bobSigMsg, ok := bobMsgsToSend[1].(*lnwire.CommitSig)
+	if !ok {
+		t.Fatalf("expected bob to re-send commit sig, instead sending: %v",
+			spew.Sdump(bobMsgsToSend[1]))
 	}
-	if bobAliceBalance != aliceBalance {
-		t.Fatalf("line #%v: wrong alice bob's balance: expected %v, got %v",
-			line, aliceBalance, bobAliceBalance)
+	if !reflect.DeepEqual(bobReRevoke, bobRevocation) {
+		t.Fatalf("revocation msgs don't match: expected %v, got %v",
+			bobRevocation, bobReRevoke)
+	}
+	if bobReCommitSigMsg.CommitSig != bobSigMsg.CommitSig {
+		t.Fatalf("commit sig msgs don't match: expected %x got %x",
+			bobSigMsg.CommitSig,
+			bobReCommitSigMsg.CommitSig)
+	}
+	if len(bobReCommitSigMsg.HtlcSigs) != len(bobSigMsg.HtlcSigs) {
+		t.Fatalf("wrong number of htlc sigs: expected %v, got %v",
+			len(bobSigMsg.HtlcSigs), len(bobReCommitSigMsg.HtlcSigs))
+	}
+	for i, htlcSig := range bobReCommitSigMsg.HtlcSigs {
+		if htlcSig != bobSigMsg.HtlcSigs[i] {
+			t.Fatalf("htlc sig msgs don't match: "+
+				"expected %x got %x",
+				bobSigMsg.HtlcSigs[i], htlcSig)
+		}
+	}
+
+	// If this is a taproot channel, then we'll also have Bob generate their
+	// current nonce, and also process Alice's.
+	if chanType.IsTaproot() {
+		_, err = bobChannel.GenMusigNonces()
+		require.NoError(t, err)
+
+		aliceNonces, err := aliceChannel.GenMusigNonces()
+		require.NoError(t, err)
+
+		err = bobChannel.InitRemoteMusigNonces(aliceNonces)
+		require.NoError(t, err)
 	}
+
+	// Now, we'll continue the exchange, sending Bob's revocation and
+	// signature message to Alice, ending with Alice sending her revocation
+	// message to Bob.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to recv revocation")
+	err = aliceChannel.ReceiveNewCommitment(&CommitSigs{
+		CommitSig:  bobSigMsg.CommitSig,
+		HtlcSigs:   bobSigMsg.HtlcSigs,
+		PartialSig: bobSigMsg.PartialSig,
+	})
+	require.NoError(t, err, "alice unable to rev bob's commitment")
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to recv revocation")
 }
 
-// TestChanReserve tests that the ErrBelowChanReserve error is thrown when an
-// HTLC is added that causes a node's balance to dip below its channel reserve
-// limit.
-func TestChanReserve(t *testing.T) {
+// TestChanSyncOweRevocationAndCommitForceTransition tests that if Alice
+// initiates a state transition with Bob, but Alice fails to receive his
+// RevokeAndAck and the connection dies before Bob sends his CommitSig message,
+// then Bob will re-send her RevokeAndAck message. Bob will also send and
+// _identical_ CommitSig as he detects his commitment chain is ahead of
+// Alice's.
+func TestChanSyncOweRevocationAndCommitForceTransition(t *testing.T) {
 	t.Parallel()
 
-	setupChannels := func() (*LightningChannel, *LightningChannel) {
-		// We'll kick off the test by creating our channels which both
-		// are loaded with 5 BTC each.
-		aliceChannel, bobChannel, err := CreateTestChannels(
+	t.Run("tweakless", func(t *testing.T) {
+		testChanSyncOweRevocationAndCommitForceTransition(
 			t, channeldb.SingleFunderTweaklessBit,
 		)
-		if err != nil {
-			t.Fatalf("unable to create test channels: %v", err)
-		}
+	})
+	t.Run("taproot", func(t *testing.T) {
+		taprootBits := channeldb.SimpleTaprootFeatureBit |
+			channeldb.AnchorOutputsBit |
+			channeldb.ZeroHtlcTxFeeBit |
+			channeldb.SingleFunderTweaklessBit
 
-		// We set the remote required ChanReserve to 0.5 BTC. We will
-		// attempt to cause Alice's balance to dip below this amount
-		// and test whether it triggers the ErrBelowChanReserve error.
-		aliceMinReserve := btcutil.Amount(0.5 *
-			btcutil.SatoshiPerBitcoin)
+		testChanSyncOweRevocationAndCommitForceTransition(
+			t, taprootBits,
+		)
+	})
+}
 
-		// Alice will need to keep her reserve above aliceMinReserve,
-		// so set this limit to here local config.
-		aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
+// TestChanSyncFailure tests the various scenarios during channel sync where we
+// should be able to detect that the channels cannot be synced because of
+// invalid state.
+func TestChanSyncFailure(t *testing.T) {
+	t.Parallel()
 
-		// During channel opening Bob will also get to know Alice's
-		// minimum reserve, and this will be found in his remote
-		// config.
-		bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-		// We set Bob's channel reserve to a value that is larger than
-		// his current balance in the channel. This will ensure that
-		// after a channel is first opened, Bob can still receive HTLCs
-		// even though his balance is less than his channel reserve.
-		bobMinReserve := btcutil.Amount(6 * btcutil.SatoshiPerBitcoin)
-		bobChannel.channelState.LocalChanCfg.ChanReserve = bobMinReserve
-		aliceChannel.channelState.RemoteChanCfg.ChanReserve = bobMinReserve
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	index := byte(0)
 
-		return aliceChannel, bobChannel
-	}
-	aliceChannel, bobChannel := setupChannels()
+	// advanceState is a helper method to fully advance the channel state
+	// by one.
+	advanceState := func() {
+		t.Helper()
 
-	aliceIndex := 0
-	bobIndex := 0
+		// We'll kick off the test by having Bob send Alice an HTLC,
+		// then lock it in with a state transition.
+		var bobPreimage [32]byte
+		copy(bobPreimage[:], bytes.Repeat([]byte{0xaa - index}, 32))
+		rHash := sha256.Sum256(bobPreimage[:])
+		bobHtlc := &lnwire.UpdateAddHTLC{
+			PaymentHash: rHash,
+			Amount:      htlcAmt,
+			Expiry:      uint32(10),
+			ID:          uint64(index),
+		}
+		index++
 
-	// Add an HTLC that will increase Bob's balance. This should succeed,
-	// since Alice stays above her channel reserve, and Bob increases his
-	// balance (while still being below his channel reserve).
-	//
-	// Resulting balances:
-	//	Alice:	4.5
-	//	Bob:	5.0
-	htlcAmt := lnwire.NewMSatFromSatoshis(0.5 * btcutil.SatoshiPerBitcoin)
-	htlc, _ := createHTLC(aliceIndex, htlcAmt)
-	aliceIndex++
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+		_, err := bobChannel.AddHTLC(bobHtlc, nil)
+		if err != nil {
+			t.Fatalf("unable to add bob's htlc: %v", err)
+		}
+		_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+		if err != nil {
+			t.Fatalf("unable to recv bob's htlc: %v", err)
+		}
+		err = ForceStateTransition(bobChannel, aliceChannel)
+		if err != nil {
+			t.Fatalf("unable to complete bob's state "+
+				"transition: %v", err)
+		}
 	}
 
-	// Force a state transition, making sure this HTLC is considered valid
-	// even though the channel reserves are not met.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
-	}
+	// halfAdvance is a helper method that sends a new commitment signature
+	// from Alice to Bob, but doesn't make Bob revoke his current state.
+	halfAdvance := func() {
+		t.Helper()
 
-	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
-	assertChannelBalances(
-		t, aliceChannel, bobChannel,
-		btcutil.SatoshiPerBitcoin*4.5-commitFee, btcutil.SatoshiPerBitcoin*5,
-	)
+		// We'll kick off the test by having Bob send Alice an HTLC,
+		// then lock it in with a state transition.
+		var bobPreimage [32]byte
+		copy(bobPreimage[:], bytes.Repeat([]byte{0xaa - index}, 32))
+		rHash := sha256.Sum256(bobPreimage[:])
+		bobHtlc := &lnwire.UpdateAddHTLC{
+			PaymentHash: rHash,
+			Amount:      htlcAmt,
+			Expiry:      uint32(10),
+			ID:          uint64(index),
+		}
+		index++
 
-	// Now let Bob try to add an HTLC. This should fail, since it will
-	// decrease his balance, which is already below the channel reserve.
-	//
-	// Resulting balances:
-	//	Alice:	4.5
-	//	Bob:	5.0
-	htlc, _ = createHTLC(bobIndex, htlcAmt)
-	bobIndex++
-	_, err := bobChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+		_, err := bobChannel.AddHTLC(bobHtlc, nil)
+		if err != nil {
+			t.Fatalf("unable to add bob's htlc: %v", err)
+		}
+		_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+		if err != nil {
+			t.Fatalf("unable to recv bob's htlc: %v", err)
+		}
 
-	// Alice will reject this htlc upon receiving the htlc.
-	_, err = aliceChannel.ReceiveHTLC(htlc)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+		aliceNewCommit, err := aliceChannel.SignNextCommitment()
+		if err != nil {
+			t.Fatalf("unable to sign next commit: %v", err)
+		}
+		err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+		if err != nil {
+			t.Fatalf("unable to receive commit sig: %v", err)
+		}
+	}
 
-	// We must setup the channels again, since a violation of the channel
-	// constraints leads to channel shutdown.
-	aliceChannel, bobChannel = setupChannels()
+	// assertLocalDataLoss checks that aliceOld and bobChannel detects that
+	// Alice has lost state during sync.
+	assertLocalDataLoss := func(aliceOld *LightningChannel) {
+		t.Helper()
 
-	aliceIndex = 0
-	bobIndex = 0
+		aliceSyncMsg, err := aliceOld.channelState.ChanSyncMsg()
+		if err != nil {
+			t.Fatalf("unable to produce chan sync msg: %v", err)
+		}
+		bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+		if err != nil {
+			t.Fatalf("unable to produce chan sync msg: %v", err)
+		}
 
-	// Now we'll add HTLC of 3.5 BTC to Alice's commitment, this should put
-	// Alice's balance at 1.5 BTC.
-	//
-	// Resulting balances:
-	//	Alice:	1.5
-	//	Bob:	9.5
-	htlcAmt = lnwire.NewMSatFromSatoshis(3.5 * btcutil.SatoshiPerBitcoin)
+		// Alice should detect from Bob's message that she lost state.
+		_, _, _, err = aliceOld.ProcessChanSyncMsg(bobSyncMsg)
+		if _, ok := err.(*ErrCommitSyncLocalDataLoss); !ok {
+			t.Fatalf("wrong error, expected "+
+				"ErrCommitSyncLocalDataLoss instead got: %v",
+				err)
+		}
 
-	// The first HTLC should successfully be sent.
-	htlc, _ = createHTLC(aliceIndex, htlcAmt)
-	aliceIndex++
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+		// Bob should detect that Alice probably lost state.
+		_, _, _, err = bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
+		if err != ErrCommitSyncRemoteDataLoss {
+			t.Fatalf("wrong error, expected "+
+				"ErrCommitSyncRemoteDataLoss instead got: %v",
+				err)
+		}
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+
+	// clearBorkedState is a method that allows us to clear the borked
+	// state that will arise after the first chan message sync. We need to
+	// do this in order to be able to continue to update the commitment
+	// state for our test scenarios.
+	clearBorkedState := func() {
+		err = aliceChannel.channelState.ClearChanStatus(
+			channeldb.ChanStatusLocalDataLoss | channeldb.ChanStatusBorked,
+		)
+		if err != nil {
+			t.Fatalf("unable to update channel state: %v", err)
+		}
+		err = bobChannel.channelState.ClearChanStatus(
+			channeldb.ChanStatusLocalDataLoss | channeldb.ChanStatusBorked,
+		)
+		if err != nil {
+			t.Fatalf("unable to update channel state: %v", err)
+		}
 	}
 
-	// Add a second HTLC of 1 BTC. This should fail because it will take
-	// Alice's balance all the way down to her channel reserve, but since
-	// she is the initiator the additional transaction fee makes her
-	// balance dip below.
-	htlcAmt = lnwire.NewMSatFromSatoshis(1 * btcutil.SatoshiPerBitcoin)
-	htlc, _ = createHTLC(aliceIndex, htlcAmt)
-	aliceIndex++
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+	// Start by advancing the state.
+	advanceState()
 
-	// Likewise, Bob will reject receiving the htlc because of the same reason.
-	_, err = bobChannel.ReceiveHTLC(htlc)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+	// They should be in sync.
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
 
-	// We must setup the channels again, since a violation of the channel
-	// constraints leads to channel shutdown.
-	aliceChannel, bobChannel = setupChannels()
+	// Make a copy of Alice's state from the database at this point.
+	aliceOld, err := restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart channel")
 
-	aliceIndex = 0
-	bobIndex = 0
+	// Advance the states.
+	advanceState()
 
-	// Add a HTLC of 2 BTC to Alice, and the settle it.
-	// Resulting balances:
-	//	Alice:	3.0
-	//	Bob:	7.0
-	htlcAmt = lnwire.NewMSatFromSatoshis(2 * btcutil.SatoshiPerBitcoin)
-	htlc, preimage := createHTLC(aliceIndex, htlcAmt)
-	aliceIndex++
-	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err, "unable to add htlc")
-	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err, "unable to recv htlc")
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
-	}
+	// Trying to sync up the old version of Alice's channel should detect
+	// that we are out of sync.
+	assertLocalDataLoss(aliceOld)
 
-	commitFee = aliceChannel.channelState.LocalCommitment.CommitFee
-	assertChannelBalances(
-		t, aliceChannel, bobChannel,
-		btcutil.SatoshiPerBitcoin*3-commitFee, btcutil.SatoshiPerBitcoin*5,
-	)
+	// Make sure the up-to-date channels still are in sync.
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
 
-	if err := bobChannel.SettleHTLC(preimage, bobHtlcIndex, nil, nil, nil); err != nil {
-		t.Fatalf("bob unable to settle inbound htlc: %v", err)
-	}
-	if err := aliceChannel.ReceiveHTLCSettle(preimage, aliceHtlcIndex); err != nil {
-		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
-	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
-	}
+	// Clear the borked state before we attempt to advance.
+	clearBorkedState()
 
-	commitFee = aliceChannel.channelState.LocalCommitment.CommitFee
-	assertChannelBalances(
-		t, aliceChannel, bobChannel,
-		btcutil.SatoshiPerBitcoin*3-commitFee, btcutil.SatoshiPerBitcoin*7,
-	)
+	// Advance the state again, and do the same check.
+	advanceState()
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
+	assertLocalDataLoss(aliceOld)
 
-	// And now let Bob add an HTLC of 1 BTC. This will take Bob's balance
-	// all the way down to his channel reserve, but since he is not paying
-	// the fee this is okay.
-	htlcAmt = lnwire.NewMSatFromSatoshis(1 * btcutil.SatoshiPerBitcoin)
-	htlc, _ = createHTLC(bobIndex, htlcAmt)
-	bobIndex++
-	if _, err := bobChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
-	}
-	if _, err := aliceChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+	// If we remove the recovery options from Bob's message, Alice cannot
+	// tell if she lost state, since Bob might be lying. She still should
+	// be able to detect that chains cannot be synced.
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg.LocalUnrevokedCommitPoint = nil
+	_, _, _, err = aliceOld.ProcessChanSyncMsg(bobSyncMsg)
+	if err != ErrCannotSyncCommitChains {
+		t.Fatalf("wrong error, expected ErrCannotSyncCommitChains "+
+			"instead got: %v", err)
 	}
 
-	// Do a last state transition, which should succeed.
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
+	// If Bob lies about the NextLocalCommitHeight, making it greater than
+	// what Alice expect, she cannot tell for sure whether she lost state,
+	// but should detect the desync.
+	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg.NextLocalCommitHeight++
+	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	if err != ErrCannotSyncCommitChains {
+		t.Fatalf("wrong error, expected ErrCannotSyncCommitChains "+
+			"instead got: %v", err)
 	}
 
-	commitFee = aliceChannel.channelState.LocalCommitment.CommitFee
-	assertChannelBalances(
-		t, aliceChannel, bobChannel,
-		btcutil.SatoshiPerBitcoin*3-commitFee, btcutil.SatoshiPerBitcoin*6,
-	)
-}
-
-// TestChanReserveRemoteInitiator tests that the channel reserve of the
-// initiator is accounted for when adding HTLCs, whether the initiator is the
-// local or remote node.
-func TestChanReserveRemoteInitiator(t *testing.T) {
-	t.Parallel()
-
-	// We start out with a channel where both parties have 5 BTC.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	if err != nil {
-		t.Fatal(err)
+	// If Bob's NextLocalCommitHeight is lower than what Alice expects, Bob
+	// probably lost state.
+	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg.NextLocalCommitHeight--
+	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	if err != ErrCommitSyncRemoteDataLoss {
+		t.Fatalf("wrong error, expected ErrCommitSyncRemoteDataLoss "+
+			"instead got: %v", err)
 	}
 
-	// Set Alice's channel reserve to be 5 BTC-commitfee. This means she
-	// has just enough balance to cover the comitment fee, but not enough
-	// to add any more HTLCs to the commitment. Although a reserve this
-	// high is unrealistic, a channel can easily get into a situation
-	// where the initiator cannot pay for the fee of any more HTLCs.
-	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
-	aliceMinReserve := 5*btcutil.SatoshiPerBitcoin - commitFee
+	// If Alice and Bob's states are in sync, but Bob is sending the wrong
+	// LocalUnrevokedCommitPoint, Alice should detect this.
+	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	p := bobSyncMsg.LocalUnrevokedCommitPoint.SerializeCompressed()
+	p[4] ^= 0x01
+	modCommitPoint, err := btcec.ParsePubKey(p)
+	require.NoError(t, err, "unable to parse pubkey")
 
-	aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
-	bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
+	bobSyncMsg.LocalUnrevokedCommitPoint = modCommitPoint
+	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	if err != ErrInvalidLocalUnrevokedCommitPoint {
+		t.Fatalf("wrong error, expected "+
+			"ErrInvalidLocalUnrevokedCommitPoint instead got: %v",
+			err)
+	}
 
-	// Now let Bob attempt to add an HTLC of 0.1 BTC. He has plenty of
-	// money available to spend, but Alice, which is the initiator, cannot
-	// afford any more HTLCs on the commitment transaction because that
-	// would take here below her channel reserve..
-	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
-	htlc, _ := createHTLC(0, htlcAmt)
+	// Make sure the up-to-date channels still are good.
+	assertNoChanSyncNeeded(t, aliceChannel, bobChannel)
 
-	// Bob should refuse to add this HTLC, since he realizes it will create
-	// an invalid commitment.
-	_, err = bobChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+	// Clear the borked state before we attempt to advance.
+	clearBorkedState()
 
-	// Of course Alice will also not have enough balance to add it herself.
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+	// Finally check that Alice is also able to detect a wrong commit point
+	// when there's a pending remote commit.
+	halfAdvance()
 
-	// Same for Alice, she should refuse to accept this second HTLC.
-	_, err = aliceChannel.ReceiveHTLC(htlc)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+	bobSyncMsg, err = bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg.LocalUnrevokedCommitPoint = modCommitPoint
+	_, _, _, err = aliceChannel.ProcessChanSyncMsg(bobSyncMsg)
+	if err != ErrInvalidLocalUnrevokedCommitPoint {
+		t.Fatalf("wrong error, expected "+
+			"ErrInvalidLocalUnrevokedCommitPoint instead got: %v",
+			err)
+	}
 }
 
-// TestChanReserveLocalInitiatorDustHtlc tests that fee the initiator must pay
-// when adding HTLCs is accounted for, even though the HTLC is considered dust
-// by the remote bode.
-func TestChanReserveLocalInitiatorDustHtlc(t *testing.T) {
+// TestFeeUpdateRejectInsaneFee tests that if the initiator tries to attach a
+// fee that would put them below their current reserve, then it's rejected by
+// the state machine.
+func TestFeeUpdateRejectInsaneFee(t *testing.T) {
 	t.Parallel()
 
-	aliceChannel, bobChannel, err := CreateTestChannels(
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, _, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
-	if err != nil {
-		t.Fatal(err)
-	}
+	require.NoError(t, err, "unable to create test channels")
 
-	// The amount of the HTLC should not be considered dust according to
-	// Alice's dust limit (200 sat), but be dust according to Bob's dust
-	// limit (1300 sat). It is considered dust if the amount remaining
-	// after paying the HTLC fee is below the dustlimit, so we choose a
-	// size of 500+htlcFee.
-	htlcSat := btcutil.Amount(500) + HtlcTimeoutFee(
-		aliceChannel.channelState.ChanType,
-		chainfee.SatPerKWeight(
-			aliceChannel.channelState.LocalCommitment.FeePerKw,
-		),
+	// Next, we'll try to add a fee rate to Alice which is 1,000,000x her
+	// starting fee rate.
+	startingFeeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
 	)
+	newFeeRate := startingFeeRate * 1000000
 
-	// Set Alice's channel reserve to be low enough to carry the value of
-	// the HTLC, but not low enough to allow the extra fee from adding the
-	// HTLC to the commitment.
-	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
-	aliceMinReserve := 5*btcutil.SatoshiPerBitcoin - commitFee - htlcSat
-
-	aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
-	bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
-
-	htlcDustAmt := lnwire.NewMSatFromSatoshis(htlcSat)
-	htlc, _ := createHTLC(0, htlcDustAmt)
-
-	// Alice should realize that the fee she must pay to add this HTLC to
-	// the local commitment would take her below the channel reserve.
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	require.ErrorIs(t, err, ErrBelowChanReserve)
+	// Both Alice and Bob should reject this new fee rate as it is far too
+	// large.
+	if err := aliceChannel.UpdateFee(newFeeRate); err == nil {
+		t.Fatalf("alice should have rejected fee update")
+	}
 }
 
-// TestMinHTLC tests that the ErrBelowMinHTLC error is thrown if an HTLC is added
-// that is below the minimm allowed value for HTLCs.
-func TestMinHTLC(t *testing.T) {
+// TestChannelRetransmissionFeeUpdate tests that the initiator will include any
+// pending fee updates if it needs to retransmit signatures.
+func TestChannelRetransmissionFeeUpdate(t *testing.T) {
 	t.Parallel()
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// We set Alice's MinHTLC to 0.1 BTC. We will attempt to send an
-	// HTLC BELOW this value to trigger the ErrBelowMinHTLC error.
-	minValue := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
-
-	// Setting the min value in Alice's local config means that the
-	// remote will not accept any HTLCs of value less than specified.
-	aliceChannel.channelState.LocalChanCfg.MinHTLC = minValue
-	bobChannel.channelState.RemoteChanCfg.MinHTLC = minValue
+	// First, we'll fetch the current fee rate present within the
+	// commitment transactions.
+	startingFeeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
 
-	// First, we will add an HTLC of 0.5 BTC. This will not trigger
-	// ErrBelowMinHTLC.
-	htlcAmt := lnwire.NewMSatFromSatoshis(0.5 * btcutil.SatoshiPerBitcoin)
-	htlc, _ := createHTLC(0, htlcAmt)
-	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
-		t.Fatalf("unable to add htlc: %v", err)
+	// Next, we'll start a commitment update, with Alice sending a new
+	// update to double the fee rate of the commitment.
+	newFeeRate := startingFeeRate * 2
+	if err := aliceChannel.UpdateFee(newFeeRate); err != nil {
+		t.Fatalf("unable to update fee for Alice's channel: %v", err)
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
-		t.Fatalf("unable to recv htlc: %v", err)
+	if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
+		t.Fatalf("unable to update fee for Bob's channel: %v", err)
 	}
 
-	// We add an HTLC below the min value, this should result in
-	// an ErrBelowMinHTLC error.
-	amt := minValue - 100
-	htlc, _ = createHTLC(1, amt)
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	if err != ErrBelowMinHTLC {
-		t.Fatalf("expected ErrBelowMinHTLC, instead received: %v", err)
-	}
+	// Now, Alice will send a new commitment to Bob, but we'll simulate a
+	// connection failure, so Bob doesn't get her signature.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
 
-	// Bob will receive this HTLC, but reject the next received htlc, since
-	// the htlc is too small.
-	_, err = bobChannel.ReceiveHTLC(htlc)
-	if err != ErrBelowMinHTLC {
-		t.Fatalf("expected ErrBelowMinHTLC, instead received: %v", err)
-	}
-}
+	// Restart both channels to simulate a connection restart.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart alice")
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart channel")
 
-// TestInvalidHTLCAmt tests that ErrInvalidHTLCAmt is returned when trying to
-// add HTLCs that don't carry a positive value.
-func TestInvalidHTLCAmt(t *testing.T) {
-	t.Parallel()
+	// Bob doesn't get this message so upon reconnection, they need to
+	// synchronize. Alice should conclude that she owes Bob a commitment,
+	// while Bob should think he's properly synchronized.
+	aliceSyncMsg, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
+	bobSyncMsg, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to produce chan sync msg")
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+	// Bob should detect that he doesn't need to send anything to Alice.
+	bobMsgsToSend, _, _, err := bobChannel.ProcessChanSyncMsg(aliceSyncMsg)
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(bobMsgsToSend) != 0 {
+		t.Fatalf("expected bob to send %v messages instead "+
+			"will send %v: %v", 0, len(bobMsgsToSend),
+			spew.Sdump(bobMsgsToSend))
+	}
+
+	// When Alice processes Bob's chan sync message, she should realize
+	// that she needs to first send a new UpdateFee message, and also a
+	// CommitSig.
+	aliceMsgsToSend, _, _, err := aliceChannel.ProcessChanSyncMsg(
+		bobSyncMsg,
 	)
-	require.NoError(t, err, "unable to create test channels")
+	require.NoError(t, err, "unable to process chan sync msg")
+	if len(aliceMsgsToSend) != 2 {
+		t.Fatalf("expected alice to send %v messages instead "+
+			"will send %v: %v", 2, len(aliceMsgsToSend),
+			spew.Sdump(aliceMsgsToSend))
+	}
 
-	// We'll set the min HTLC values for each party to zero, which
-	// technically would permit zero-value HTLCs.
-	aliceChannel.channelState.LocalChanCfg.MinHTLC = 0
-	bobChannel.channelState.RemoteChanCfg.MinHTLC = 0
+	// The first message should be an UpdateFee message.
+	retransFeeMsg, ok := aliceMsgsToSend[0].(*lnwire.UpdateFee)
+	if !ok {
+		t.Fatalf("expected UpdateFee message, instead have: %v",
+			spew.Sdump(aliceMsgsToSend[0]))
+	}
 
-	// Create a zero-value HTLC.
-	htlcAmt := lnwire.MilliSatoshi(0)
-	htlc, _ := createHTLC(0, htlcAmt)
+	// The fee should match exactly the new fee update we applied above.
+	if retransFeeMsg.FeePerKw != uint32(newFeeRate) {
+		t.Fatalf("fee update doesn't match: expected %v, got %v",
+			uint32(newFeeRate), retransFeeMsg)
+	}
 
-	// Sending or receiving the HTLC should fail with ErrInvalidHTLCAmt.
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	if err != ErrInvalidHTLCAmt {
-		t.Fatalf("expected ErrInvalidHTLCAmt, got: %v", err)
+	// The second, should be a CommitSig message, and be identical to the
+	// sig message she sent prior.
+	commitSigMsg, ok := aliceMsgsToSend[1].(*lnwire.CommitSig)
+	if !ok {
+		t.Fatalf("expected a CommitSig message, instead have %v",
+			spew.Sdump(aliceMsgsToSend[1]))
 	}
-	_, err = bobChannel.ReceiveHTLC(htlc)
-	if err != ErrInvalidHTLCAmt {
-		t.Fatalf("expected ErrInvalidHTLCAmt, got: %v", err)
+	if commitSigMsg.CommitSig != aliceNewCommit.CommitSig {
+		t.Fatalf("commit sig msgs don't match: expected %x got %x",
+			aliceNewCommit.CommitSig, commitSigMsg.CommitSig)
+	}
+	if len(commitSigMsg.HtlcSigs) != len(aliceNewCommit.HtlcSigs) {
+		t.Fatalf("wrong number of htlc sigs: expected %v, got %v",
+			len(aliceNewCommit.HtlcSigs),
+			len(commitSigMsg.HtlcSigs))
+	}
+	for i, htlcSig := range commitSigMsg.HtlcSigs {
+		if htlcSig != aliceNewCommit.HtlcSigs[i] {
+			t.Fatalf("htlc sig msgs don't match: "+
+				"expected %x got %x",
+				aliceNewCommit.HtlcSigs[i], htlcSig)
+		}
+	}
+
+	// Now, we if re-apply the updates to Bob, we should be able to resume
+	// the commitment update as normal.
+	if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
+		t.Fatalf("unable to update fee for Bob's channel: %v", err)
+	}
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's commitment")
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign commitment")
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to recv revocation")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "alice unable to rev bob's commitment")
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to recv revocation")
+
+	// Both parties should now have the latest fee rate locked-in.
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) != newFeeRate {
+
+		t.Fatalf("alice's feePerKw was not locked in")
+	}
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) != newFeeRate {
+
+		t.Fatalf("bob's feePerKw was not locked in")
+	}
+
+	// Finally, we'll add with adding a new HTLC, then forcing a state
+	// transition. This should also proceed as normal.
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xaa}, 32))
+	rHash := sha256.Sum256(bobPreimage[:])
+	bobHtlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: rHash,
+		Amount:      lnwire.NewMSatFromSatoshis(20000),
+		Expiry:      uint32(10),
+	}
+	if _, err := bobChannel.AddHTLC(bobHtlc, nil); err != nil {
+		t.Fatalf("unable to add bob's htlc: %v", err)
+	}
+	if _, err := aliceChannel.ReceiveHTLC(bobHtlc); err != nil {
+		t.Fatalf("unable to recv bob's htlc: %v", err)
+	}
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 }
 
-// TestNewBreachRetributionSkipsDustHtlcs ensures that in the case of a
-// contract breach, all dust HTLCs are ignored and not reflected in the
-// produced BreachRetribution struct. We ignore these HTLCs as they aren't
-// actually manifested on the commitment transaction, as a result we can't
-// actually revoked them.
-func TestNewBreachRetributionSkipsDustHtlcs(t *testing.T) {
+// TestFeeUpdateOldDiskFormat tests that we properly recover FeeUpdates written
+// to disk using the old format, where the logIndex was not written.
+func TestFeeUpdateOldDiskFormat(t *testing.T) {
 	t.Parallel()
 
-	// We'll kick off the test by creating our channels which both are
-	// loaded with 5 BTC each.
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	var fakeOnionBlob [lnwire.OnionPacketSize]byte
-	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
+	// helper that counts the number of updates, and number of fee updates
+	// in the given log.
+	countLog := func(log *updateLog) (int, int) {
+		var numUpdates, numFee int
+		for e := log.Front(); e != nil; e = e.Next() {
+			htlc := e.Value.(*PaymentDescriptor)
+			if htlc.EntryType == FeeUpdate {
+				numFee++
+			}
+			numUpdates++
+		}
+		return numUpdates, numFee
+	}
 
-	// We'll modify the dust settings on both channels to be a predictable
-	// value for the prurpose of the test.
-	dustValue := btcutil.Amount(200)
-	aliceChannel.channelState.LocalChanCfg.DustLimit = dustValue
-	aliceChannel.channelState.RemoteChanCfg.DustLimit = dustValue
-	bobChannel.channelState.LocalChanCfg.DustLimit = dustValue
-	bobChannel.channelState.RemoteChanCfg.DustLimit = dustValue
+	// helper that asserts that Alice's local log and Bob's remote log
+	// contains the expected number of fee updates and adds. As Alice
+	// coalesces her unsigned fee updates, her log holds fewer fee updates
+	// than Bob's.
+	assertLogItems := func(aliceFee, expFee, expAdd int) {
+		t.Helper()
 
-	// We'll now create a series of dust HTLC's, and send then from Alice
-	// to Bob, finally locking both of them in.
-	var bobPreimage [32]byte
-	copy(bobPreimage[:], bytes.Repeat([]byte{0xbb}, 32))
-	for i := 0; i < 3; i++ {
-		rHash := sha256.Sum256(bobPreimage[:])
-		h := &lnwire.UpdateAddHTLC{
-			PaymentHash: rHash,
-			Amount:      lnwire.NewMSatFromSatoshis(dustValue),
-			Expiry:      uint32(10),
-			OnionBlob:   fakeOnionBlob,
+		upd, fees := countLog(aliceChannel.localUpdateLog)
+		if upd != aliceFee+expAdd {
+			t.Fatalf("expected %d updates, found %d in Alice's "+
+				"log", aliceFee+expAdd, upd)
 		}
-
-		htlcIndex, err := aliceChannel.AddHTLC(h, nil)
-		if err != nil {
-			t.Fatalf("unable to add bob's htlc: %v", err)
+		if fees != aliceFee {
+			t.Fatalf("expected %d fee updates, found %d in "+
+				"Alice's log", aliceFee, fees)
 		}
 
-		h.ID = htlcIndex
-		if _, err := bobChannel.ReceiveHTLC(h); err != nil {
-			t.Fatalf("unable to recv bob's htlc: %v", err)
+		expUpd := expFee + expAdd
+		upd, fees = countLog(bobChannel.remoteUpdateLog)
+		if upd != expUpd {
+			t.Fatalf("expected %d updates, found %d in Bob's log",
+				expUpd, upd)
+		}
+		if fees != expFee {
+			t.Fatalf("expected %d fee updates, found %d in Bob's "+
+				"log", expFee, fees)
 		}
 	}
 
-	// With the HTLC's applied to both update logs, we'll initiate a state
-	// transition from Alice.
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete alice's state transition: %v", err)
-	}
+	// First, we'll fetch the current fee rate present within the
+	// commitment transactions.
+	startingFeeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	newFeeRate := startingFeeRate
 
-	// At this point, we'll capture the current state number, as well as
-	// the current commitment.
-	revokedStateNum := aliceChannel.channelState.LocalCommitment.CommitHeight
+	// We will send a few HTLCs and a fee update.
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+	const numHTLCs = 30
+	var htlcs []*lnwire.UpdateAddHTLC
+	for i := 0; i < numHTLCs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
+		htlcs = append(htlcs, htlc)
 
-	// We'll now have Bob settle those HTLC's to Alice and then advance
-	// forward to a new state.
-	for i := 0; i < 3; i++ {
-		err := bobChannel.SettleHTLC(bobPreimage, uint64(i), nil, nil, nil)
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
+		if i%5 != 0 {
+			continue
 		}
-		err = aliceChannel.ReceiveHTLCSettle(bobPreimage, uint64(i))
-		if err != nil {
-			t.Fatalf("unable to settle htlc: %v", err)
+
+		// After every 5th HTLC, we'll also include a fee update.
+		newFeeRate += startingFeeRate
+		if err := aliceChannel.UpdateFee(newFeeRate); err != nil {
+			t.Fatalf("unable to update fee for Alice's channel: %v",
+				err)
+		}
+		if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
+			t.Fatalf("unable to update fee for Bob's channel: %v",
+				err)
 		}
 	}
-	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
-		t.Fatalf("unable to complete bob's state transition: %v", err)
-	}
+	// Check that the expected number of items is found in the logs.
+	expFee := numHTLCs / 5
+	assertLogItems(1, expFee, numHTLCs)
 
-	// At this point, we'll now simulate a contract breach by Bob using the
-	// NewBreachRetribution method.
-	breachTx := aliceChannel.channelState.RemoteCommitment.CommitTx
-	breachRet, err := NewBreachRetribution(
-		aliceChannel.channelState, revokedStateNum, 100, breachTx,
-	)
-	require.NoError(t, err, "unable to create breach retribution")
+	// Now, Alice will send a new commitment to Bob, but we'll simulate a
+	// connection failure, so Bob doesn't get the signature.
+	aliceNewCommitSig, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
 
-	// The retribution shouldn't have any HTLCs set as they were all below
-	// dust for both parties.
-	if len(breachRet.HtlcRetributions) != 0 {
-		t.Fatalf("zero HTLC retributions should have been created, "+
-			"instead %v were", len(breachRet.HtlcRetributions))
-	}
-}
-
-// compareHtlcs compares two PaymentDescriptors.
-func compareHtlcs(htlc1, htlc2 *PaymentDescriptor) error {
-	if htlc1.LogIndex != htlc2.LogIndex {
-		return fmt.Errorf("htlc log index did not match")
+	// Before restarting Alice, to mimic the old format, we fetch the
+	// pending remote commit from disk, set the UpdateFee message's
+	// logIndex to 0, and re-write it.
+	pendingRemoteCommitDiff, err := aliceChannel.channelState.RemoteCommitChainTip()
+	if err != nil {
+		t.Fatal(err)
 	}
-	if htlc1.HtlcIndex != htlc2.HtlcIndex {
-		return fmt.Errorf("htlc index did not match")
+	for i, u := range pendingRemoteCommitDiff.LogUpdates {
+		switch u.UpdateMsg.(type) {
+		case *lnwire.UpdateFee:
+			pendingRemoteCommitDiff.LogUpdates[i].LogIndex = 0
+		}
 	}
-	if htlc1.ParentIndex != htlc2.ParentIndex {
-		return fmt.Errorf("htlc parent index did not match")
+	err = aliceChannel.channelState.AppendRemoteCommitChain(
+		pendingRemoteCommitDiff,
+	)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if htlc1.RHash != htlc2.RHash {
-		return fmt.Errorf("htlc rhash did not match")
-	}
-	return nil
-}
+	// Restart both channels to simulate a connection restart. This will
+	// trigger a update logs restoration.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart alice")
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart channel")
 
-// compareIndexes is a helper method to compare two index maps.
-func compareIndexes(a, b map[uint64]*list.Element) error {
-	for k1, e1 := range a {
-		e2, ok := b[k1]
-		if !ok {
-			return fmt.Errorf("element with key %d "+
-				"not found in b", k1)
-		}
-		htlc1, htlc2 := e1.Value.(*PaymentDescriptor), e2.Value.(*PaymentDescriptor)
-		if err := compareHtlcs(htlc1, htlc2); err != nil {
-			return err
+	// After a reconnection, Alice will resend the pending updates, that
+	// was not ACKed by Bob, so we re-send the HTLCs and fee updates.
+	newFeeRate = startingFeeRate
+	for i := 0; i < numHTLCs; i++ {
+		htlc := htlcs[i]
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
 		}
-	}
 
-	for k1, e1 := range b {
-		e2, ok := a[k1]
-		if !ok {
-			return fmt.Errorf("element with key %d not "+
-				"found in a", k1)
+		if i%5 != 0 {
+			continue
 		}
-		htlc1, htlc2 := e1.Value.(*PaymentDescriptor), e2.Value.(*PaymentDescriptor)
-		if err := compareHtlcs(htlc1, htlc2); err != nil {
-			return err
+
+		newFeeRate += startingFeeRate
+		if err := bobChannel.ReceiveUpdateFee(newFeeRate); err != nil {
+			t.Fatalf("unable to update fee for Bob's channel: %v",
+				err)
 		}
 	}
+	assertLogItems(1, expFee, numHTLCs)
 
-	return nil
-}
+	// We send Alice's commitment signatures, and finish the state
+	// transition.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommitSig.CommitSigs)
+	require.NoError(t, err, "bob unable to process alice's commitment")
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
+	bobNewCommitSigs, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "bob unable to sign commitment")
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "alice unable to recv revocation")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommitSigs.CommitSigs)
+	require.NoError(t, err, "alice unable to rev bob's commitment")
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "alice unable to revoke commitment")
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to recv revocation")
 
-// compareLogs is a helper method to compare two updateLogs.
-func compareLogs(a, b *updateLog) error {
-	if a.logIndex != b.logIndex {
-		return fmt.Errorf("log indexes don't match: %d vs %d",
-			a.logIndex, b.logIndex)
+	// Both parties should now have the latest fee rate locked-in.
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) != newFeeRate {
+
+		t.Fatalf("alice's feePerKw was not locked in")
 	}
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) != newFeeRate {
 
-	if a.htlcCounter != b.htlcCounter {
-		return fmt.Errorf("htlc counters don't match: %d vs %d",
-			a.htlcCounter, b.htlcCounter)
+		t.Fatalf("bob's feePerKw was not locked in")
 	}
 
-	if err := compareIndexes(a.updateIndex, b.updateIndex); err != nil {
-		return fmt.Errorf("update indexes don't match: %v", err)
+	// Finally, to trigger a compactLogs execution, we'll add a new HTLC,
+	// then force a state transition.
+	htlc, _ := createHTLC(numHTLCs, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
 	}
-	if err := compareIndexes(a.htlcIndex, b.htlcIndex); err != nil {
-		return fmt.Errorf("htlc indexes don't match: %v", err)
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
 	}
-
-	if a.Len() != b.Len() {
-		return fmt.Errorf("list lengths not equal: %d vs %d",
-			a.Len(), b.Len())
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
 	}
 
-	e1, e2 := a.Front(), b.Front()
-	for ; e1 != nil; e1, e2 = e1.Next(), e2.Next() {
-		htlc1, htlc2 := e1.Value.(*PaymentDescriptor), e2.Value.(*PaymentDescriptor)
-		if err := compareHtlcs(htlc1, htlc2); err != nil {
-			return err
-		}
+	// Finally, check the logs to make sure all fee updates have been
+	// removed...
+	assertLogItems(0, 0, numHTLCs+1)
+
+	// ...and the final fee rate locked in.
+	if chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) != newFeeRate {
+
+		t.Fatalf("alice's feePerKw was not locked in")
 	}
+	if chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	) != newFeeRate {
 
-	return nil
+		t.Fatalf("bob's feePerKw was not locked in")
+	}
 }
 
-// TestChannelRestoreUpdateLogs makes sure we are able to properly restore the
-// update logs in the case where a different number of HTLCs are locked in on
-// the local, remote and pending remote commitment.
-func TestChannelRestoreUpdateLogs(t *testing.T) {
+// TestChanSyncUnableToSync tests that if Alice or Bob receive an invalid
+// ChannelReestablish messages,then they reject the message and declare the
+// channel un-continuable by returning ErrCannotSyncCommitChains.
+func TestChanSyncUnableToSync(t *testing.T) {
 	t.Parallel()
 
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+		t, channeldb.SingleFunderBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll add an HTLC from Alice to Bob, which we will lock in on
-	// Bob's commit, but not on Alice's.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
+	// If we immediately send both sides a "bogus" ChanSync message, then
+	// they both should conclude that they're unable to synchronize the
+	// state.
+	badChanSync := &lnwire.ChannelReestablish{
+		ChanID: lnwire.NewChanIDFromOutPoint(
+			&aliceChannel.channelState.FundingOutpoint,
+		),
+		NextLocalCommitHeight:  1000,
+		RemoteCommitTailHeight: 9000,
 	}
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
+	_, _, _, err = bobChannel.ProcessChanSyncMsg(badChanSync)
+	if err != ErrCannotSyncCommitChains {
+		t.Fatalf("expected error instead have: %v", err)
 	}
+	_, _, _, err = aliceChannel.ProcessChanSyncMsg(badChanSync)
+	if err != ErrCannotSyncCommitChains {
+		t.Fatalf("expected error instead have: %v", err)
+	}
+}
 
-	// Let Alice sign a new state, which will include the HTLC just sent.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-
-	// Bob receives this commitment signature, and revokes his old state.
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
+// TestChanSyncStrict asserts that in strict chan sync mode, ChannelReestablish
+// messages with recovery fields that are inconsistent with the claimed heights
+// are rejected, while they're handled permissively by default.
+func TestChanSyncStrict(t *testing.T) {
+	t.Parallel()
 
-	// When Alice now receives this revocation, she will advance her remote
-	// commitment chain to the commitment which includes the HTLC just
-	// sent. However her local commitment chain still won't include the
-	// state with the HTLC, since she hasn't received a new commitment
-	// signature from Bob yet.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "unable to receive revocation")
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Now make Alice send and sign an additional HTLC. We don't let Bob
-	// receive it. We do this since we want to check that update logs are
-	// restored properly below, and we'll only restore updates that have
-	// been ACKed.
-	htlcAlice, _ = createHTLC(1, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
+	// Advance the state, so the commit tail heights are non-zero.
+	for i := 0; i < 2; i++ {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(20_000))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
 	}
 
-	// Send the signature covering the HTLC. This is okay, since the local
-	// and remote commit chains are updated in an async fashion. Since the
-	// remote chain was updated with the latest state (since Bob sent the
-	// revocation earlier) we can keep advancing the remote commit chain.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-
-	// After Alice has signed this commitment, her local commitment will
-	// contain no HTLCs, her remote commitment will contain an HTLC with
-	// index 0, and the pending remote commitment (a signed remote
-	// commitment which is not AKCed yet) will contain an additional HTLC
-	// with index 1.
-
-	// We now re-create the channels, mimicking a restart. This should sync
-	// the update logs up to the correct state set up above.
-	newAliceChannel, err := NewLightningChannel(
+	strictChannel, err := NewLightningChannel(
 		aliceChannel.Signer, aliceChannel.channelState,
-		aliceChannel.sigPool,
+		aliceChannel.sigPool, WithStrictChanSync(),
 	)
-	require.NoError(t, err, "unable to create new channel")
+	require.NoError(t, err)
 
-	newBobChannel, err := NewLightningChannel(
-		bobChannel.Signer, bobChannel.channelState,
-		bobChannel.sigPool,
-	)
-	require.NoError(t, err, "unable to create new channel")
+	bobSync, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err)
 
-	// compare all the logs between the old and new channels, to make sure
-	// they all got restored properly.
-	err = compareLogs(aliceChannel.localUpdateLog,
-		newAliceChannel.localUpdateLog)
-	require.NoError(t, err, "alice local log not restored")
+	// A complete message is accepted in strict mode.
+	msgs, _, _, err := strictChannel.ProcessChanSyncMsg(bobSync)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
 
-	err = compareLogs(aliceChannel.remoteUpdateLog,
-		newAliceChannel.remoteUpdateLog)
-	require.NoError(t, err, "alice remote log not restored")
+	testCases := []struct {
+		name string
 
-	err = compareLogs(bobChannel.localUpdateLog,
-		newBobChannel.localUpdateLog)
-	require.NoError(t, err, "bob local log not restored")
+		// modify alters a copy of Bob's sync message.
+		modify func(msg *lnwire.ChannelReestablish)
 
-	err = compareLogs(bobChannel.remoteUpdateLog,
-		newBobChannel.remoteUpdateLog)
-	require.NoError(t, err, "bob remote log not restored")
-}
-
-// fetchNumUpdates counts the number of updateType in the log.
-func fetchNumUpdates(t updateType, log *updateLog) int {
-	num := 0
-	for e := log.Front(); e != nil; e = e.Next() {
-		htlc := e.Value.(*PaymentDescriptor)
-		if htlc.EntryType == t {
-			num++
-		}
-	}
-	return num
-}
-
-// assertInLog checks that the given log contains the expected number of Adds
-// and Fails.
-func assertInLog(t *testing.T, log *updateLog, numAdds, numFails int) {
-	adds := fetchNumUpdates(Add, log)
-	if adds != numAdds {
-		t.Fatalf("expected %d adds, found %d", numAdds, adds)
-	}
-	fails := fetchNumUpdates(Fail, log)
-	if fails != numFails {
-		t.Fatalf("expected %d fails, found %d", numFails, fails)
+		// permissiveErr is the error returned by default, if any.
+		permissiveErr error
+	}{
+		{
+			name: "no recovery fields",
+			modify: func(msg *lnwire.ChannelReestablish) {
+				msg.LastRemoteCommitSecret = [32]byte{}
+				msg.LocalUnrevokedCommitPoint = nil
+			},
+		},
+		{
+			name: "secret without commit point",
+			modify: func(msg *lnwire.ChannelReestablish) {
+				msg.LocalUnrevokedCommitPoint = nil
+			},
+		},
+		{
+			name: "higher tail height without recovery fields",
+			modify: func(msg *lnwire.ChannelReestablish) {
+				msg.RemoteCommitTailHeight++
+				msg.LastRemoteCommitSecret = [32]byte{}
+				msg.LocalUnrevokedCommitPoint = nil
+			},
+			permissiveErr: ErrCannotSyncCommitChains,
+		},
+		{
+			name: "secret for tail height zero",
+			modify: func(msg *lnwire.ChannelReestablish) {
+				msg.RemoteCommitTailHeight = 0
+			},
+			permissiveErr: ErrCommitSyncRemoteDataLoss,
+		},
 	}
-}
-
-// assertInLogs asserts that the expected number of Adds and Fails occurs in
-// the local and remote update log of the given channel.
-func assertInLogs(t *testing.T, channel *LightningChannel, numAddsLocal,
-	numFailsLocal, numAddsRemote, numFailsRemote int) {
-	assertInLog(t, channel.localUpdateLog, numAddsLocal, numFailsLocal)
-	assertInLog(t, channel.remoteUpdateLog, numAddsRemote, numFailsRemote)
-}
 
-// restoreAndAssert creates a new LightningChannel from the given channel's
-// state, and asserts that the new channel has had its logs restored to the
-// expected state.
-func restoreAndAssert(t *testing.T, channel *LightningChannel, numAddsLocal,
-	numFailsLocal, numAddsRemote, numFailsRemote int) {
+	for _, tc := range testCases {
+		msg := *bobSync
+		tc.modify(&msg)
 
-	newChannel, err := NewLightningChannel(
-		channel.Signer, channel.channelState,
-		channel.sigPool,
-	)
-	require.NoError(t, err, "unable to create new channel")
+		_, _, _, err := aliceChannel.ProcessChanSyncMsg(&msg)
+		if tc.permissiveErr == nil {
+			require.NoError(t, err, tc.name)
+		} else {
+			require.ErrorIs(t, err, tc.permissiveErr, tc.name)
+		}
 
-	assertInLog(t, newChannel.localUpdateLog, numAddsLocal, numFailsLocal)
-	assertInLog(t, newChannel.remoteUpdateLog, numAddsRemote, numFailsRemote)
+		_, _, _, err = strictChannel.ProcessChanSyncMsg(&msg)
+		require.ErrorIs(t, err, ErrInconsistentChanSync, tc.name)
+	}
 }
 
-// TestChannelRestoreUpdateLogsFailedHTLC runs through a scenario where an
-// HTLC is added and failed, and asserts along the way that we would restore
-// the update logs of the channel to the expected state at any point.
-func TestChannelRestoreUpdateLogsFailedHTLC(t *testing.T) {
+// TestChanSyncInvalidLastSecret ensures that if Alice and Bob have completed
+// state transitions in an existing channel, and then send a ChannelReestablish
+// message after a restart, the following holds: if Alice has lost data, so she
+// sends an invalid commit secret then both parties recognize this as possible
+// data loss.
+func TestChanSyncInvalidLastSecret(t *testing.T) {
 	t.Parallel()
 
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+		t, channeldb.SingleFunderBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll add an HTLC from Alice to Bob, and lock it in for both.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
+	// We'll create a new instances of Alice before doing any state updates
+	// such that we have the initial in memory state at the start of the
+	// channel.
+	aliceOld, err := restartChannel(aliceChannel)
+	if err != nil {
+		t.Fatalf("unable to restart alice")
 	}
-	// The htlc Alice sent should be in her local update log.
-	assertInLogs(t, aliceChannel, 1, 0, 0, 0)
-
-	// A restore at this point should NOT restore this update, as it is not
-	// locked in anywhere yet.
-	restoreAndAssert(t, aliceChannel, 0, 0, 0, 0)
 
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
+	// First, we'll add an HTLC, and then initiate a state transition
+	// between the two parties such that we actually have a prior
+	// revocation to send.
+	var paymentPreimage [32]byte
+	copy(paymentPreimage[:], bytes.Repeat([]byte{1}, 32))
+	paymentHash := sha256.Sum256(paymentPreimage[:])
+	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	htlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: paymentHash,
+		Amount:      htlcAmt,
+		Expiry:      uint32(5),
+	}
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
 	}
 
-	// Lock in the Add on both sides.
+	// Then we'll initiate a state transition to lock in this new HTLC.
 	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
+		t.Fatalf("unable to complete alice's state transition: %v", err)
 	}
 
-	// Since it is locked in, Alice should have the Add in the local log,
-	// and it should be restored during restoration.
-	assertInLogs(t, aliceChannel, 1, 0, 0, 0)
-	restoreAndAssert(t, aliceChannel, 1, 0, 0, 0)
+	// Next, we'll restart both parties in order to simulate a connection
+	// re-establishment.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart alice")
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart bob")
 
-	// Now we make Bob fail this HTLC.
-	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
+	// Next, we'll produce the ChanSync messages for both parties.
+	aliceChanSync, err := aliceChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to generate chan sync msg")
+	bobChanSync, err := bobChannel.channelState.ChanSyncMsg()
+	require.NoError(t, err, "unable to generate chan sync msg")
 
-	err = aliceChannel.ReceiveFailHTLC(0, []byte("failreason"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+	// We'll modify Alice's sync message to have an invalid commitment
+	// secret.
+	aliceChanSync.LastRemoteCommitSecret[4] ^= 0x01
 
-	// This Fail update should have been added to Alice's remote update log.
-	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
+	// Alice's former self should conclude that she possibly lost data as
+	// Bob is sending a valid commit secret for the latest state.
+	_, _, _, err = aliceOld.ProcessChanSyncMsg(bobChanSync)
+	if _, ok := err.(*ErrCommitSyncLocalDataLoss); !ok {
+		t.Fatalf("wrong error, expected ErrCommitSyncLocalDataLoss "+
+			"instead got: %v", err)
+	}
 
-	// Restoring should restore the HTLC added to Alice's local log, but
-	// NOT the Fail sent by Bob, since it is not locked in.
-	restoreAndAssert(t, aliceChannel, 1, 0, 0, 0)
+	// Bob should conclude that he should force close the channel, as Alice
+	// cannot continue operation.
+	_, _, _, err = bobChannel.ProcessChanSyncMsg(aliceChanSync)
+	if err != ErrInvalidLastCommitSecret {
+		t.Fatalf("wrong error, expected ErrInvalidLastCommitSecret, "+
+			"instead got: %v", err)
+	}
+}
 
-	// Bob sends a signature.
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
+// backupKeyRing is a key ring that derives the keys of a test channel by
+// their key family.
+type backupKeyRing struct {
+	keychain.SecretKeyRing
 
-	// When Alice receives Bob's new commitment, the logs will stay the
-	// same until she revokes her old state. The Fail will still not be
-	// restored during a restoration.
-	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
-	restoreAndAssert(t, aliceChannel, 1, 0, 0, 0)
+	keys map[keychain.KeyFamily]*btcec.PrivateKey
+}
 
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "bob unable to process alice's revocation")
+// DeriveKey returns the key of the family of the passed key locator.
+func (k *backupKeyRing) DeriveKey(
+	keyLoc keychain.KeyLocator) (keychain.KeyDescriptor, error) {
 
-	// At this point Alice has advanced her local commitment chain to a
-	// commitment with no HTLCs left. The current state on her remote
-	// commitment chain, however, still has the HTLC active, as she hasn't
-	// sent a new signature yet. If we'd now restart and restore, the htlc
-	// failure update should still be waiting for inclusion in Alice's next
-	// signature. Otherwise the produced signature would be invalid.
-	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
-	restoreAndAssert(t, aliceChannel, 1, 0, 0, 1)
+	key, ok := k.keys[keyLoc.Family]
+	if !ok {
+		return keychain.KeyDescriptor{}, fmt.Errorf("unknown key "+
+			"family %v", keyLoc.Family)
+	}
 
-	// Now send a signature from Alice. This will give Bob a new commitment
-	// where the HTLC is removed.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
+	return keychain.KeyDescriptor{
+		KeyLocator: keyLoc,
+		PubKey:     key.PubKey(),
+	}, nil
+}
 
-	// When sending a new commitment, Alice will add a pending commit to
-	// her remote chain. Since the unsigned acked updates aren't deleted
-	// until we receive a revocation, the fail should still be present.
-	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
-	restoreAndAssert(t, aliceChannel, 1, 0, 0, 1)
+// DerivePrivKey returns the private key of the passed public key.
+func (k *backupKeyRing) DerivePrivKey(
+	keyDesc keychain.KeyDescriptor) (*btcec.PrivateKey, error) {
 
-	// When Alice receives Bob's revocation, the Fail is irrevocably locked
-	// in on both sides. She should compact the logs, removing the HTLC and
-	// the corresponding Fail from the local update log.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "unable to receive revocation")
+	for _, key := range k.keys {
+		if key.PubKey().IsEqual(keyDesc.PubKey) {
+			return key, nil
+		}
+	}
 
-	assertInLogs(t, aliceChannel, 0, 0, 0, 0)
-	restoreAndAssert(t, aliceChannel, 0, 0, 0, 0)
+	return nil, fmt.Errorf("unknown key %x",
+		keyDesc.PubKey.SerializeCompressed())
 }
 
-// TestDuplicateFailRejection tests that if either party attempts to fail an
-// HTLC twice, then we'll reject the second fail attempt.
-func TestDuplicateFailRejection(t *testing.T) {
+// TestNewChannelFromShell asserts that a channel created from the shell of a
+// static channel backup alone carries out the data loss protection protocol
+// with the remote party, and rejects any state updates.
+func TestNewChannelFromShell(t *testing.T) {
 	t.Parallel()
 
 	aliceChannel, bobChannel, err := CreateTestChannels(
@@ -7044,132 +7262,225 @@ func TestDuplicateFailRejection(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll add an HTLC from Alice to Bob, and lock it in for both
-	// parties.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
+	// Advance the channel by a few states, so Bob's commitment point
+	// isn't the initial one.
+	for i := 0; i < 3; i++ {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(20_000))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
 	}
-	_, err = bobChannel.ReceiveHTLC(htlcAlice)
-	require.NoError(t, err, "unable to recv htlc")
 
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
+	// Alice backs up her channel. The keys of her channel config are
+	// derived by their family when restoring.
+	backup := chanbackup.NewSingle(aliceChannel.channelState, nil)
+	cfg := &backup.LocalChanCfg
+	cfg.MultiSigKey.Family = keychain.KeyFamilyMultiSig
+	cfg.RevocationBasePoint.Family = keychain.KeyFamilyRevocationBase
+	cfg.PaymentBasePoint.Family = keychain.KeyFamilyPaymentBase
+	cfg.DelayBasePoint.Family = keychain.KeyFamilyDelayBase
+	cfg.HtlcBasePoint.Family = keychain.KeyFamilyHtlcBase
+
+	aliceKeys := aliceChannel.Signer.(*input.MockSigner).Privkeys
+	keyRing := &backupKeyRing{
+		keys: map[keychain.KeyFamily]*btcec.PrivateKey{
+			keychain.KeyFamilyMultiSig:       aliceKeys[0],
+			keychain.KeyFamilyRevocationBase: aliceKeys[1],
+			keychain.KeyFamilyPaymentBase:    aliceKeys[2],
+			keychain.KeyFamilyDelayBase:      aliceKeys[3],
+			keychain.KeyFamilyHtlcBase:       aliceKeys[4],
+		},
 	}
 
-	// With the HTLC locked in, we'll now have Bob fail the HTLC back to
-	// Alice.
-	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	if err := aliceChannel.ReceiveFailHTLC(0, []byte("bad")); err != nil {
-		t.Fatalf("unable to recv htlc cancel: %v", err)
-	}
+	chanShell, err := chanbackup.NewChannelShell(backup, keyRing)
+	require.NoError(t, err)
+	restored, err := NewChannelFromShell(chanShell, aliceChannel.Signer)
+	require.NoError(t, err)
 
-	// If we attempt to fail it AGAIN, then both sides should reject this
-	// second failure attempt.
-	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
-	if err == nil {
-		t.Fatalf("duplicate HTLC failure attempt should have failed")
-	}
-	if err := aliceChannel.ReceiveFailHTLC(0, []byte("bad")); err == nil {
-		t.Fatalf("duplicate HTLC failure attempt should have failed")
-	}
+	// The restored channel has Alice's keys and revocation producer.
+	restoredState := restored.State()
+	require.True(t, restoredState.HasChanStatus(
+		channeldb.ChanStatusRestored,
+	))
+	require.True(t, restoredState.LocalChanCfg.PaymentBasePoint.PubKey.
+		IsEqual(aliceKeys[2].PubKey()))
+	secret, err := restoredState.RevocationProducer.AtIndex(3)
+	require.NoError(t, err)
+	aliceSecret, err := aliceChannel.channelState.RevocationProducer.
+		AtIndex(3)
+	require.NoError(t, err)
+	require.Equal(t, aliceSecret, secret)
 
-	// We'll now have Bob sign a new commitment to lock in the HTLC fail
-	// for Alice.
-	_, err = bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commit")
+	// The restored channel's reestablish message makes Bob force close
+	// the channel.
+	restoredSync, err := restoredState.ChanSyncMsg()
+	require.NoError(t, err)
+	_, _, _, err = bobChannel.ProcessChanSyncMsg(restoredSync)
+	require.ErrorIs(t, err, ErrCommitSyncRemoteDataLoss)
 
-	// We'll now force a restart for Bob and Alice, so we can test the
-	// persistence related portion of this assertion.
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart channel")
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart channel")
+	// Bob's reestablish message provides the commitment point needed to
+	// sweep our funds from his commitment.
+	bobSync, err := bobChannel.State().ChanSyncMsg()
+	require.NoError(t, err)
+	_, _, _, err = restored.ProcessChanSyncMsg(bobSync)
 
-	// If we try to fail the same HTLC again, then we should get an error.
-	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
-	if err == nil {
-		t.Fatalf("duplicate HTLC failure attempt should have failed")
-	}
+	var errDataLoss *ErrCommitSyncLocalDataLoss
+	require.ErrorAs(t, err, &errDataLoss)
+	require.Equal(t, backup.FundingOutpoint, errDataLoss.ChannelPoint)
+	require.True(t, errDataLoss.CommitPoint.IsEqual(
+		bobSync.LocalUnrevokedCommitPoint,
+	))
 
-	// Alice on the other hand should accept the failure again, as she
-	// dropped all items in the logs which weren't committed.
-	if err := aliceChannel.ReceiveFailHTLC(0, []byte("bad")); err != nil {
-		t.Fatalf("unable to recv htlc cancel: %v", err)
-	}
+	// Any update of the channel state is rejected.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20_000))
+	_, err = restored.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrRestoredChannel)
+	_, err = restored.ReceiveHTLC(htlc)
+	require.ErrorIs(t, err, ErrRestoredChannel)
+	_, err = restored.SignNextCommitment()
+	require.ErrorIs(t, err, ErrRestoredChannel)
 }
 
-// TestDuplicateSettleRejection tests that if either party attempts to settle
-// an HTLC twice, then we'll reject the second settle attempt.
-func TestDuplicateSettleRejection(t *testing.T) {
+// TestChanAvailableBandwidth tests the accuracy of the AvailableBalance()
+// method. The value returned from this message should reflect the value
+// returned within the commitment state of a channel after the transition is
+// initiated.
+func TestChanAvailableBandwidth(t *testing.T) {
 	t.Parallel()
 
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// First, we'll add an HTLC from Alice to Bob, and lock it in for both
-	// parties.
-	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
-	htlcAlice, alicePreimage := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	_, err = bobChannel.ReceiveHTLC(htlcAlice)
-	require.NoError(t, err, "unable to recv htlc")
+	aliceReserve := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalChanCfg.ChanReserve,
+	)
+	feeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	htlcFee := lnwire.NewMSatFromSatoshis(
+		feeRate.FeeForWeight(input.HTLCWeight),
+	)
 
-	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
-		t.Fatalf("unable to complete state update: %v", err)
-	}
+	assertBandwidthEstimateCorrect := func(aliceInitiate bool) {
+		// With the HTLC's added, we'll now query the AvailableBalance
+		// method for the current available channel bandwidth from
+		// Alice's PoV.
+		aliceAvailableBalance := aliceChannel.AvailableBalance()
 
-	// With the HTLC locked in, we'll now have Bob settle the HTLC back to
-	// Alice.
-	err = bobChannel.SettleHTLC(alicePreimage, uint64(0), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, uint64(0))
-	require.NoError(t, err, "unable to recv htlc cancel")
+		// With this balance obtained, we'll now trigger a state update
+		// to actually determine what the current up to date balance
+		// is.
+		if aliceInitiate {
+			err := ForceStateTransition(aliceChannel, bobChannel)
+			if err != nil {
+				t.Fatalf("unable to complete alice's state "+
+					"transition: %v", err)
+			}
+		} else {
+			err := ForceStateTransition(bobChannel, aliceChannel)
+			if err != nil {
+				t.Fatalf("unable to complete alice's state "+
+					"transition: %v", err)
+			}
+		}
 
-	// If we attempt to fail it AGAIN, then both sides should reject this
-	// second failure attempt.
-	err = bobChannel.SettleHTLC(alicePreimage, uint64(0), nil, nil, nil)
-	if err == nil {
-		t.Fatalf("duplicate HTLC failure attempt should have failed")
+		// Now, we'll obtain the current available bandwidth in Alice's
+		// latest commitment and compare that to the prior estimate.
+		aliceBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
+
+		// The balance we have available for new HTLCs should be the
+		// current local commitment balance, minus the channel reserve
+		// and the fee for adding an HTLC.
+		expBalance := aliceBalance - aliceReserve - htlcFee
+		if expBalance != aliceAvailableBalance {
+			_, _, line, _ := runtime.Caller(1)
+			t.Fatalf("line: %v, incorrect balance: expected %v, "+
+				"got %v", line, expBalance, aliceAvailableBalance)
+		}
 	}
-	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, uint64(0))
-	if err == nil {
-		t.Fatalf("duplicate HTLC failure attempt should have failed")
+
+	// First, we'll add 3 outgoing HTLC's from Alice to Bob.
+	const numHtlcs = 3
+	var htlcAmt lnwire.MilliSatoshi = 100000
+	alicePreimages := make([][32]byte, numHtlcs)
+	for i := 0; i < numHtlcs; i++ {
+		htlc, preImage := createHTLC(i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
+
+		alicePreimages[i] = preImage
 	}
 
-	// We'll now have Bob sign a new commitment to lock in the HTLC fail
-	// for Alice.
-	_, err = bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commit")
+	assertBandwidthEstimateCorrect(true)
 
-	// We'll now force a restart for Bob and Alice, so we can test the
-	// persistence related portion of this assertion.
-	bobChannel, err = restartChannel(bobChannel)
-	require.NoError(t, err, "unable to restart channel")
-	aliceChannel, err = restartChannel(aliceChannel)
-	require.NoError(t, err, "unable to restart channel")
+	// We'll repeat the same exercise, but with non-dust HTLCs. So we'll
+	// crank up the value of the HTLC's we're adding to the commitment
+	// transaction.
+	htlcAmt = lnwire.NewMSatFromSatoshis(30000)
+	for i := 0; i < numHtlcs; i++ {
+		htlc, preImage := createHTLC(numHtlcs+i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
 
-	// If we try to fail the same HTLC again, then we should get an error.
-	err = bobChannel.SettleHTLC(alicePreimage, uint64(0), nil, nil, nil)
-	if err == nil {
-		t.Fatalf("duplicate HTLC failure attempt should have failed")
+		alicePreimages = append(alicePreimages, preImage)
 	}
 
-	// Alice on the other hand should accept the failure again, as she
-	// dropped all items in the logs which weren't committed.
-	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, uint64(0))
+	assertBandwidthEstimateCorrect(true)
+
+	// Next, we'll have Bob 5 of Alice's HTLC's, and cancel one of them (in
+	// the update log).
+	for i := 0; i < (numHtlcs*2)-1; i++ {
+		preImage := alicePreimages[i]
+		err := bobChannel.SettleHTLC(preImage, uint64(i), nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+		err = aliceChannel.ReceiveHTLCSettle(preImage, uint64(i))
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+	}
+
+	htlcIndex := uint64((numHtlcs * 2) - 1)
+	err = bobChannel.FailHTLC(htlcIndex, []byte("f"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveFailHTLC(htlcIndex, []byte("bad"))
 	require.NoError(t, err, "unable to recv htlc cancel")
+
+	// We must do a state transition before the balance is available
+	// for Alice.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete alice's state "+
+			"transition: %v", err)
+	}
+
+	// With the HTLC's settled in the log, we'll now assert that if we
+	// initiate a state transition, then our guess was correct.
+	assertBandwidthEstimateCorrect(false)
+
+	// TODO(roasbeef): additional tests from diff starting conditions
 }
 
-// TestChannelRestoreCommitHeight tests that the local and remote commit
-// heights of HTLCs are set correctly across restores.
-func TestChannelRestoreCommitHeight(t *testing.T) {
+// TestNextLocalCommitTxid asserts that the txid returned by
+// NextLocalCommitTxid matches the txid of the local commitment once it's
+// signed by the remote party.
+func TestNextLocalCommitTxid(t *testing.T) {
 	t.Parallel()
 
 	aliceChannel, bobChannel, err := CreateTestChannels(
@@ -7177,2448 +7488,7434 @@ func TestChannelRestoreCommitHeight(t *testing.T) {
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// helper method to check add heights of the htlcs found in the given
-	// log after a restore.
-	restoreAndAssertCommitHeights := func(t *testing.T,
-		channel *LightningChannel, remoteLog bool, htlcIndex uint64,
-		expLocal, expRemote uint64) *LightningChannel {
-
-		newChannel, err := NewLightningChannel(
-			channel.Signer, channel.channelState, channel.sigPool,
-		)
-		if err != nil {
-			t.Fatalf("unable to create new channel: %v", err)
-		}
-
-		var pd *PaymentDescriptor
-		if remoteLog {
-			if newChannel.localUpdateLog.lookupHtlc(htlcIndex) != nil {
-				t.Fatalf("htlc found in wrong log")
-			}
-			pd = newChannel.remoteUpdateLog.lookupHtlc(htlcIndex)
-
-		} else {
-			if newChannel.remoteUpdateLog.lookupHtlc(htlcIndex) != nil {
-				t.Fatalf("htlc found in wrong log")
-			}
-			pd = newChannel.localUpdateLog.lookupHtlc(htlcIndex)
-		}
-		if pd == nil {
-			t.Fatalf("htlc not found in log")
-		}
-
-		if pd.addCommitHeightLocal != expLocal {
-			t.Fatalf("expected local add height to be %d, was %d",
-				expLocal, pd.addCommitHeightLocal)
-		}
-		if pd.addCommitHeightRemote != expRemote {
-			t.Fatalf("expected remote add height to be %d, was %d",
-				expRemote, pd.addCommitHeightRemote)
-		}
-		return newChannel
-	}
+	// Without any updates, the next commitment only differs from the
+	// current one by its commitment point.
+	currentTxid := aliceChannel.channelState.LocalCommitment.CommitTx.
+		TxHash()
+	nextTxid, err := aliceChannel.NextLocalCommitTxid()
+	require.NoError(t, err)
+	require.NotEqual(t, currentTxid, nextTxid)
 
-	// We'll send an HtLC from Alice to Bob.
-	htlcAmount := lnwire.NewMSatFromSatoshis(100000000)
-	htlcAlice, _ := createHTLC(0, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
-	}
+	// Alice adds an HTLC, which Bob ACKs by revoking his commitment.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
 
-	// Let Alice sign a new state, which will include the HTLC just sent.
 	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-
-	// The HTLC should only be on the pending remote commitment, so the
-	// only the remote add height should be set during a restore.
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 0, 1,
-	)
-
-	// Bob receives this commitment signature, and revokes his old state.
+	require.NoError(t, err)
 	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
+	require.NoError(t, err)
 	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
 
-	// Now the HTLC is locked into Bob's commitment, a restoration should
-	// set only the local commit height, as it is not locked into Alice's
-	// yet.
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 1, 0)
+	// Alice's next commitment now includes the HTLC. Computing its txid
+	// doesn't modify the channel state, so it's the same on every call.
+	nextTxid, err = aliceChannel.NextLocalCommitTxid()
+	require.NoError(t, err)
+	againTxid, err := aliceChannel.NextLocalCommitTxid()
+	require.NoError(t, err)
+	require.Equal(t, nextTxid, againTxid)
 
-	// Alice receives the revocation, ACKing her pending commitment.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "unable to receive revocation")
-
-	// However, the HTLC is still not locked into her local commitment, so
-	// the local add height should still be 0 after a restoration.
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 0, 1,
-	)
-
-	// Now let Bob send the commitment signature making the HTLC lock in on
-	// Alice's commitment.
+	// Bob signs Alice's next commitment, which she accepts.
 	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-
-	// At this stage Bob has a pending remote commitment. Make sure
-	// restoring at this stage correctly restores the HTLC add commit
-	// heights.
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 1, 1)
-
+	require.NoError(t, err)
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
+	require.NoError(t, err)
 	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
-
-	// Now both the local and remote add heights should be properly set.
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 1, 1,
-	)
-
+	require.NoError(t, err)
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "unable to receive revocation")
-
-	// Alice ACKing Bob's pending commitment shouldn't change the heights
-	// restored.
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 1, 1)
-
-	// Send andother HTLC from Alice to Bob, to test whether already
-	// existing HTLCs (the HTLC with index 0) keep getting the add heights
-	// restored properly.
-	htlcAlice, _ = createHTLC(1, htlcAmount)
-	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
-		t.Fatalf("alice unable to add htlc: %v", err)
-	}
-	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
-		t.Fatalf("bob unable to recv add htlc: %v", err)
-	}
-
-	// Send a new signature from Alice to Bob, making Alice have a pending
-	// remote commitment.
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
-
-	// A restoration should keep the add heights iof the first HTLC, and
-	// the new HTLC should have a remote add height 2.
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 1, 1,
-	)
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 1, 0, 2,
-	)
-
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
-	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
-
-	// Since Bob just revoked another commitment, a restoration should
-	// increase the add height of the first HTLC to 2, as we only keep the
-	// last unrevoked commitment. The new HTLC will also have a local add
-	// height of 2.
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 1)
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 0)
-
-	// Alice receives the revocation, ACKing her pending commitment for Bob.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
-	require.NoError(t, err, "unable to receive revocation")
-
-	// Alice receiving Bob's revocation should bump both addCommitHeightRemote
-	// heights to 2.
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 1, 2,
-	)
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 1, 0, 2,
-	)
-
-	// Sign a new state for Alice, making Bob have a pending remote
-	// commitment.
-	bobNewCommit, err = bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
+	require.NoError(t, err)
 
-	// The signing of a new commitment for Alice should have given the new
-	// HTLC an add height.
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 1)
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 2)
+	// The txid of Alice's signed commitment matches the one computed
+	// beforehand.
+	signedTx, err := aliceChannel.getSignedCommitTx()
+	require.NoError(t, err)
+	require.Equal(t, nextTxid, signedTx.TxHash())
+	require.Len(t, signedTx.TxOut, 3)
+}
 
-	// Alice should receive the commitment and send over a revocation.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
-	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
+// TestLiquidityReport asserts that LiquidityReport reports our available
+// balance as outbound liquidity, and the remote party's balance minus their
+// reserve and fees as inbound liquidity, also when our balance is drained to
+// the reserve.
+func TestLiquidityReport(t *testing.T) {
+	t.Parallel()
 
-	// Both heights should be 2 and they are on both commitments.
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 2, 2,
-	)
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 1, 2, 2,
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Bob receives the revocation, which should set both addCommitHeightRemote
-	// fields to 2.
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err, "unable to receive revocation")
-
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 2)
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 2)
+	// manualInbound computes the inbound liquidity of Alice, who is the
+	// initiator, from Bob's balance and reserve.
+	manualInbound := func() lnwire.MilliSatoshi {
+		state := aliceChannel.channelState
+		reserve := lnwire.NewMSatFromSatoshis(
+			state.RemoteChanCfg.ChanReserve,
+		)
 
-	// Bob now fails back the htlc that was just locked in.
-	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err, "unable to cancel HTLC")
-	err = aliceChannel.ReceiveFailHTLC(0, []byte("bad"))
-	require.NoError(t, err, "unable to recv htlc cancel")
+		return state.LocalCommitment.RemoteBalance - reserve
+	}
 
-	// Now Bob signs for the fail update.
-	bobNewCommit, err = bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commitment")
+	// The liquidity of both parties mirrors each other.
+	aliceInbound, aliceOutbound := aliceChannel.LiquidityReport()
+	bobInbound, bobOutbound := bobChannel.LiquidityReport()
+	require.Equal(t, aliceChannel.AvailableBalance(), aliceOutbound)
+	require.Equal(t, manualInbound(), aliceInbound)
+	require.Equal(t, aliceOutbound, bobInbound)
+	require.Equal(t, aliceInbound, bobOutbound)
 
-	// Bob has a pending commitment for Alice, it shouldn't affect the add
-	// commit heights though.
-	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 2)
-	_ = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 2)
+	// Alice sends her entire available balance to Bob, which leaves her
+	// with her reserve and the commitment fee.
+	initialInbound := aliceInbound
+	htlcAmt := aliceOutbound
+	htlc, preimage := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Alice receives commitment, sends revocation.
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
-	_, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke commitment")
+	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveHTLCSettle(preimage, 0)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
 
-	aliceChannel = restoreAndAssertCommitHeights(
-		t, aliceChannel, false, 0, 3, 2,
-	)
-	_ = restoreAndAssertCommitHeights(t, aliceChannel, false, 1, 3, 2)
+	// Alice can't send anything anymore, and Bob can't receive anything,
+	// while Alice can receive all of Bob's balance above his reserve.
+	aliceInbound, aliceOutbound = aliceChannel.LiquidityReport()
+	bobInbound, _ = bobChannel.LiquidityReport()
+	require.Zero(t, aliceOutbound)
+	require.Zero(t, bobInbound)
+	require.Equal(t, manualInbound(), aliceInbound)
+	require.Equal(t, initialInbound+htlcAmt, aliceInbound)
 }
 
-// TestForceCloseFailLocalDataLoss tests that we don't allow a force close of a
-// channel that's in a non-default state.
-func TestForceCloseFailLocalDataLoss(t *testing.T) {
+// TestDustTransitions asserts that DustTransitions reports the HTLCs that
+// change their dust status under a new fee rate.
+func TestDustTransitions(t *testing.T) {
 	t.Parallel()
 
-	aliceChannel, _, err := CreateTestChannels(
-		t, channeldb.SingleFunderBit,
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Now that we have our set of channels, we'll modify the channel state
-	// to have a non-default channel flag.
-	err = aliceChannel.channelState.ApplyChanStatus(
-		channeldb.ChanStatusLocalDataLoss,
-	)
-	require.NoError(t, err, "unable to apply channel state")
-
-	// Due to the change above, if we attempt to force close this
-	// channel, we should fail as it isn't safe to force close a
-	// channel that isn't in the pure default state.
-	_, err = aliceChannel.ForceClose()
-	require.ErrorIs(t, err, ErrForceCloseLocalDataLoss)
+	chanType := aliceChannel.channelState.ChanType
+	feeRate := aliceChannel.CommitFeeRate()
+
+	// Alice's HTLCs are closest to the dust boundary on Bob's commitment,
+	// where they are claimed by Bob with a success transaction. The first
+	// HTLC is right at the boundary, the second just below it and the
+	// third well above it.
+	boundary := bobDustLimit + HtlcSuccessFee(chanType, feeRate)
+	amts := []btcutil.Amount{boundary, boundary - 1, boundary * 100}
+	for i, amt := range amts {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(amt))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// At the current fee rate, none of the HTLCs changes its status.
+	nowDust, nowMaterial := aliceChannel.DustTransitions(feeRate)
+	require.Empty(t, nowDust)
+	require.Empty(t, nowMaterial)
+
+	// A slightly higher fee rate makes the HTLC at the boundary dust,
+	// while the HTLC below it remains dust.
+	higherFeeRate := feeRate + 1000
+	nowDust, nowMaterial = aliceChannel.DustTransitions(higherFeeRate)
+	require.Equal(t, []uint64{0}, nowDust)
+	require.Empty(t, nowMaterial)
+
+	// A lower fee rate gives the HTLC below the boundary its own output.
+	nowDust, nowMaterial = aliceChannel.DustTransitions(feeRate / 2)
+	require.Empty(t, nowDust)
+	require.Equal(t, []uint64{1}, nowMaterial)
+
+	// Bob sees his incoming HTLCs flip the same way.
+	nowDust, nowMaterial = bobChannel.DustTransitions(higherFeeRate)
+	require.Equal(t, []uint64{0}, nowDust)
+	require.Empty(t, nowMaterial)
 }
 
-// TestForceCloseBorkedState tests that once we force close a channel, it's
-// marked as borked in the database. Additionally, all calls to mutate channel
-// state should also fail.
-func TestForceCloseBorkedState(t *testing.T) {
+// TestChanAvailableBalanceNearHtlcFee checks that we get the expected reported
+// balance when it is close to the htlc fee.
+func TestChanAvailableBalanceNearHtlcFee(t *testing.T) {
 	t.Parallel()
 
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderBit,
+		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err, "unable to create test channels")
 
-	// Do the commitment dance until Bob sends a revocation so Alice is
-	// able to receive the revocation, and then also make a new state
-	// herself.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commit")
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
-	revokeMsg, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err, "unable to revoke bob commitment")
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err, "unable to sign commit")
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err, "unable to receive commitment")
+	// Alice and Bob start with half the channel capacity.
+	aliceBalance := lnwire.NewMSatFromSatoshis(5 * btcutil.SatoshiPerBitcoin)
+	bobBalance := lnwire.NewMSatFromSatoshis(5 * btcutil.SatoshiPerBitcoin)
 
-	// Now that we have a new Alice channel, we'll force close once to
-	// trigger the update on disk to mark the channel as borked.
-	if _, err := aliceChannel.ForceClose(); err != nil {
-		t.Fatalf("unable to force close channel: %v", err)
-	}
+	aliceReserve := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalChanCfg.ChanReserve,
+	)
+	bobReserve := lnwire.NewMSatFromSatoshis(
+		bobChannel.channelState.LocalChanCfg.ChanReserve,
+	)
 
-	// Next we'll mark the channel as borked before we proceed.
-	err = aliceChannel.channelState.ApplyChanStatus(
-		channeldb.ChanStatusBorked,
+	aliceDustlimit := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalChanCfg.DustLimit,
+	)
+	feeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	htlcFee := lnwire.NewMSatFromSatoshis(
+		feeRate.FeeForWeight(input.HTLCWeight),
+	)
+	commitFee := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalCommitment.CommitFee,
+	)
+	htlcTimeoutFee := lnwire.NewMSatFromSatoshis(
+		HtlcTimeoutFee(aliceChannel.channelState.ChanType, feeRate),
+	)
+	htlcSuccessFee := lnwire.NewMSatFromSatoshis(
+		HtlcSuccessFee(aliceChannel.channelState.ChanType, feeRate),
 	)
-	require.NoError(t, err, "unable to apply chan status")
 
-	// The on-disk state should indicate that the channel is now borked.
-	if !aliceChannel.channelState.HasChanStatus(
-		channeldb.ChanStatusBorked,
-	) {
-		t.Fatalf("chan status not updated as borked")
-	}
+	// Helper method to check the current reported balance.
+	checkBalance := func(t *testing.T, expBalanceAlice,
+		expBalanceBob lnwire.MilliSatoshi) {
 
-	// At this point, all channel mutating methods should now fail as they
-	// shouldn't be able to proceed if the channel is borked.
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(revokeMsg)
-	if err != channeldb.ErrChanBorked {
-		t.Fatalf("advance commitment tail should have failed")
-	}
+		t.Helper()
+		aliceBalance := aliceChannel.AvailableBalance()
+		if aliceBalance != expBalanceAlice {
+			t.Fatalf("Expected alice balance %v, got %v",
+				expBalanceAlice, aliceBalance)
+		}
 
-	// We manually advance the commitment tail here since the above
-	// ReceiveRevocation call will fail before it's actually advanced.
-	aliceChannel.remoteCommitChain.advanceTail()
-	_, err = aliceChannel.SignNextCommitment()
-	if err != channeldb.ErrChanBorked {
-		t.Fatalf("sign commitment should have failed: %v", err)
-	}
-	_, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	if err != channeldb.ErrChanBorked {
-		t.Fatalf("append remove chain tail should have failed")
+		bobBalance := bobChannel.AvailableBalance()
+		if bobBalance != expBalanceBob {
+			t.Fatalf("Expected bob balance %v, got %v",
+				expBalanceBob, bobBalance)
+		}
 	}
-}
 
-// TestChannelMaxFeeRate ensures we correctly compute a channel initiator's max
-// fee rate based on an allocation and its available balance. It should never
-// dip below the established fee floor.
-func TestChannelMaxFeeRate(t *testing.T) {
-	t.Parallel()
+	// Helper method to send an HTLC from Alice to Bob, decreasing Alice's
+	// balance.
+	htlcIndex := uint64(0)
+	sendHtlc := func(htlcAmt lnwire.MilliSatoshi) {
+		t.Helper()
 
-	assertMaxFeeRate := func(c *LightningChannel, maxAlloc float64,
-		expFeeRate chainfee.SatPerKWeight) {
+		htlc, preImage := createHTLC(int(htlcIndex), htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
 
-		maxFeeRate := c.MaxFeeRate(maxAlloc)
-		if maxFeeRate != expFeeRate {
-			t.Fatalf("expected max fee rate of %v with max "+
-				"allocation of %v, got %v", expFeeRate,
-				maxAlloc, maxFeeRate)
+		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+			t.Fatalf("unable to complete alice's state "+
+				"transition: %v", err)
 		}
 
-		if err := c.validateFeeRate(maxFeeRate); err != nil {
-			t.Fatalf("fee rate validation failed: %v", err)
+		err = bobChannel.SettleHTLC(preImage, htlcIndex, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+		err = aliceChannel.ReceiveHTLCSettle(preImage, htlcIndex)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
 		}
-	}
 
-	// propertyTest tests that the validateFeeRate function always passes
-	// for the output returned by MaxFeeRate for any valid random inputs
-	// fed to MaxFeeRate.
-	propertyTest := func(c *LightningChannel) func(alloc maxAlloc) bool {
-		return func(ma maxAlloc) bool {
-			maxFeeRate := c.MaxFeeRate(float64(ma))
-			return c.validateFeeRate(maxFeeRate) == nil
+		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+			t.Fatalf("unable to complete alice's state "+
+				"transition: %v", err)
 		}
+
+		htlcIndex++
+		aliceBalance -= htlcAmt
+		bobBalance += htlcAmt
 	}
 
-	aliceChannel, _, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	// Balance should start out equal to half the channel capacity minus
+	// the commitment fee Alice must pay and the channel reserve. In
+	// addition the HTLC fee will be subtracted fromt the balance to
+	// reflect that this value must be reserved for any payment above the
+	// dust limit.
+	expAliceBalance := aliceBalance - commitFee - aliceReserve - htlcFee
 
-	if err := quick.Check(propertyTest(aliceChannel), nil); err != nil {
-		t.Fatal(err)
-	}
+	// Bob is not the initiator, so he will have all his balance available,
+	// since Alice pays for fees. Bob only need to keep his balance above
+	// the reserve.
+	expBobBalance := bobBalance - bobReserve
+	checkBalance(t, expAliceBalance, expBobBalance)
 
-	assertMaxFeeRate(aliceChannel, 1.0, 676794154)
-	assertMaxFeeRate(aliceChannel, 0.001, 676794)
-	assertMaxFeeRate(aliceChannel, 0.000001, 676)
-	assertMaxFeeRate(aliceChannel, 0.0000001, chainfee.FeePerKwFloor)
+	// Find the minumim size of a non-dust HTLC.
+	aliceNonDustHtlc := aliceDustlimit + htlcTimeoutFee
 
-	// Check that anchor channels are capped at their max fee rate.
-	anchorChannel, _, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit|
-			channeldb.AnchorOutputsBit|channeldb.ZeroHtlcTxFeeBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+	// Send a HTLC leaving Alice's remaining balance just enough to have
+	// nonDustHtlc left after paying the commit fee and htlc fee.
+	htlcAmt := aliceBalance - (commitFee + aliceReserve + htlcFee + aliceNonDustHtlc)
+	sendHtlc(htlcAmt)
 
-	if err = quick.Check(propertyTest(anchorChannel), nil); err != nil {
-		t.Fatal(err)
-	}
+	// Now the real balance left will be
+	// nonDustHtlc+commitfee+aliceReserve+htlcfee. The available balance
+	// reported will just be nonDustHtlc, since the rest of the balance is
+	// reserved.
+	expAliceBalance = aliceNonDustHtlc
+	expBobBalance = bobBalance - bobReserve
+	checkBalance(t, expAliceBalance, expBobBalance)
 
-	// Anchor commitments are heavier, hence will the same allocation lead
-	// to slightly lower fee rates.
-	assertMaxFeeRate(anchorChannel, 1.0, 435941555)
-	assertMaxFeeRate(anchorChannel, 0.001, 435941)
-	assertMaxFeeRate(anchorChannel, 0.000001, 435)
-	assertMaxFeeRate(anchorChannel, 0.0000001, chainfee.FeePerKwFloor)
-}
+	// Send an HTLC using all but one msat of the reported balance.
+	htlcAmt = aliceNonDustHtlc - 1
+	sendHtlc(htlcAmt)
 
-// TestIdealCommitFeeRate tests that we correctly compute the ideal commitment
-// fee of a channel given the current network fee, minimum relay fee, maximum
-// fee allocation and whether the channel has anchor outputs.
-func TestIdealCommitFeeRate(t *testing.T) {
-	t.Parallel()
+	// 1 msat should be left.
+	expAliceBalance = 1
 
-	assertIdealFeeRate := func(c *LightningChannel, netFee, minRelay,
-		maxAnchorCommit chainfee.SatPerKWeight,
-		maxFeeAlloc float64, expectedFeeRate chainfee.SatPerKWeight) {
+	// Bob should still have all his balance available, since even though
+	// Alice cannot afford to add a non-dust HTLC, she can afford to add a
+	// non-dust HTLC from Bob.
+	expBobBalance = bobBalance - bobReserve
+	checkBalance(t, expAliceBalance, expBobBalance)
 
-		feeRate := c.IdealCommitFeeRate(
-			netFee, minRelay, maxAnchorCommit, maxFeeAlloc,
-		)
-		if feeRate != expectedFeeRate {
-			t.Fatalf("expected fee rate of %v got %v",
-				expectedFeeRate, feeRate)
-		}
+	// Sendng the last msat.
+	htlcAmt = 1
+	sendHtlc(htlcAmt)
 
-		if err := c.validateFeeRate(feeRate); err != nil {
-			t.Fatalf("fee rate validation failed: %v", err)
-		}
-	}
+	// No balance left.
+	expAliceBalance = 0
 
-	// propertyTest tests that the validateFeeRate function always passes
-	// for the output returned by IdealCommitFeeRate for any valid random
-	// inputs fed to IdealCommitFeeRate.
-	propertyTest := func(c *LightningChannel) func(ma maxAlloc,
-		netFee, minRelayFee, maxAnchorFee fee) bool {
+	// We try to always reserve enough for the non-iniitator to be able to
+	// add an HTLC, hence Bob should still have all his non-reserved
+	// balance available.
+	expBobBalance = bobBalance - bobReserve
+	checkBalance(t, expAliceBalance, expBobBalance)
 
-		return func(ma maxAlloc, netFee, minRelayFee,
-			maxAnchorFee fee) bool {
+	// Even though Alice has a reported balance of 0, this is because we
+	// try to avoid getting into the position where she cannot pay the fee
+	// for Bob adding another HTLC. This means she actually _has_ some
+	// balance left, and we now force the channel into this situation by
+	// sending yet another HTLC. In practice this can also happen if a fee
+	// update eats into Alice's balance.
+	htlcAmt = 1
+	sendHtlc(htlcAmt)
 
-			idealFeeRate := c.IdealCommitFeeRate(
-				chainfee.SatPerKWeight(netFee),
-				chainfee.SatPerKWeight(minRelayFee),
-				chainfee.SatPerKWeight(maxAnchorFee),
-				float64(ma),
-			)
+	// Now Alice balance is so low that she cannot even afford to add a new
+	// HTLC from Bob to the commitment transaction. Bob's balance should
+	// reflect this, by only reporting dust amount being available. Alice
+	// should still report a zero balance.
 
-			return c.validateFeeRate(idealFeeRate) == nil
-		}
-	}
+	// Since the dustlimit is different for the two commitments, the
+	// largest HTLC Bob can send that Alice can afford on both commitments
+	// (remember she cannot afford to pay the HTLC fee) is the largest dust
+	// HTLC on Alice's commitemnt, since her dust limit is lower.
+	bobNonDustHtlc := aliceDustlimit + htlcSuccessFee
+	expBobBalance = bobNonDustHtlc - 1
+	expAliceBalance = 0
+	checkBalance(t, expAliceBalance, expBobBalance)
+}
 
-	// Test ideal fee rates for a non-anchor channel
-	t.Run("non-anchor-channel", func(t *testing.T) {
-		aliceChannel, _, err := CreateTestChannels(
-			t, channeldb.SingleFunderTweaklessBit,
-		)
-		if err != nil {
-			t.Fatalf("unable to create test channels: %v", err)
-		}
+// TestChanAvailableBalancePendingIncomingHtlc checks that an incoming HTLC that
+// isn't yet part of the initiator's commitment still reduces its available
+// balance, as the added weight increases the commitment fee it must pay.
+func TestChanAvailableBalancePendingIncomingHtlc(t *testing.T) {
+	t.Parallel()
 
-		err = quick.Check(propertyTest(aliceChannel), nil)
-		if err != nil {
-			t.Fatal(err)
-		}
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC. Alice is the initiator.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+	require.True(t, aliceChannel.channelState.IsInitiator)
 
-		// If the maximum fee is lower than the network fee and above
-		// the min relay fee, use the maximum fee.
-		assertIdealFeeRate(
-			aliceChannel, 700000000, 0, 0, 1.0, 676794154,
-		)
+	aliceBalance, aliceWeight := aliceChannel.availableBalance()
 
-		// If the network fee is lower than the max fee and above the
-		// min relay fee, use the network fee.
-		assertIdealFeeRate(
-			aliceChannel, 500000000, 0, 0, 1.0, 500000000,
-		)
+	// Bob now sends a large HTLC to Alice, which she receives but which
+	// isn't yet committed to.
+	htlcAmt := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(0, htlcAmt)
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
 
-		// If the fee is below the minimum relay fee, and the min relay
-		// fee is less than our max fee, use the minimum relay fee.
-		assertIdealFeeRate(
-			aliceChannel, 500000000, 600000000, 0, 1.0, 600000000,
-		)
+	// The weight of the next commitment should include the new HTLC, and
+	// Alice's available balance should be reduced by the fee for it.
+	newBalance, newWeight := aliceChannel.availableBalance()
+	require.Equal(t, aliceWeight+input.HTLCWeight, newWeight)
 
-		// The absolute maximum fee rate we can pay (ie, using a max
-		// allocation of 1) is still below the minimum relay fee. In
-		// this case, use the absolute max fee.
-		assertIdealFeeRate(
-			aliceChannel, 800000000, 700000000, 0, 0.001,
-			676794154,
-		)
-	})
+	feePerKw := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	feeIncrease := lnwire.NewMSatFromSatoshis(
+		feePerKw.FeeForWeight(newWeight+input.HTLCWeight) -
+			feePerKw.FeeForWeight(aliceWeight+input.HTLCWeight),
+	)
+	require.Equal(t, aliceBalance-feeIncrease, newBalance)
 
-	// Test ideal fee rates for an anchor channel
-	t.Run("anchor-channel", func(t *testing.T) {
-		anchorChannel, _, err := CreateTestChannels(
-			t, channeldb.SingleFunderTweaklessBit|
-				channeldb.AnchorOutputsBit|
-				channeldb.ZeroHtlcTxFeeBit,
-		)
-		if err != nil {
-			t.Fatalf("unable to create test channels: %v", err)
-		}
+	// Alice should still be able to add an HTLC of her full available
+	// balance, but nothing above it.
+	require.NoError(t, aliceChannel.CanAddHTLC(newBalance))
+	require.Error(t, aliceChannel.CanAddHTLC(newBalance+1))
+}
 
-		err = quick.Check(propertyTest(anchorChannel), nil)
-		if err != nil {
-			t.Fatal(err)
+// TestChanCommitWeightDustHtlcs checks that we correctly calculate the
+// commitment weight when some HTLCs are dust.
+func TestChanCommitWeightDustHtlcs(t *testing.T) {
+	t.Parallel()
+
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	aliceDustlimit := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalChanCfg.DustLimit,
+	)
+	bobDustlimit := lnwire.NewMSatFromSatoshis(
+		bobChannel.channelState.LocalChanCfg.DustLimit,
+	)
+
+	feeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	htlcTimeoutFee := lnwire.NewMSatFromSatoshis(
+		HtlcTimeoutFee(aliceChannel.channelState.ChanType, feeRate),
+	)
+	htlcSuccessFee := lnwire.NewMSatFromSatoshis(
+		HtlcSuccessFee(aliceChannel.channelState.ChanType, feeRate),
+	)
+
+	// Helper method to add an HTLC from Alice to Bob.
+	htlcIndex := uint64(0)
+	addHtlc := func(htlcAmt lnwire.MilliSatoshi) lntypes.Preimage {
+		t.Helper()
+
+		htlc, preImage := createHTLC(int(htlcIndex), htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
 		}
 
-		// Anchor commitments are heavier, hence the same allocation
-		// leads to slightly lower fee rates.
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 0, chainfee.FeePerKwFloor,
-			0.1, chainfee.FeePerKwFloor,
-		)
+		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+			t.Fatalf("unable to complete alice's state "+
+				"transition: %v", err)
+		}
 
-		// If the maximum fee is lower than the network fee, use the
-		// maximum fee.
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 0, 1000000, 0.001, 435941,
-		)
+		return preImage
+	}
 
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 0, 700, 0.000001, 435,
-		)
+	settleHtlc := func(preImage lntypes.Preimage) {
+		t.Helper()
 
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 0, 1000000, 0.0000001,
-			chainfee.FeePerKwFloor,
-		)
+		err = bobChannel.SettleHTLC(preImage, htlcIndex, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+		err = aliceChannel.ReceiveHTLCSettle(preImage, htlcIndex)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
 
-		// If the maximum anchor commit fee rate is less than the
-		// maximum fee, use the max anchor commit fee since this is an
-		// anchor channel.
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 0, 400000, 0.001, 400000,
-		)
+		if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+			t.Fatalf("unable to complete alice's state "+
+				"transition: %v", err)
+		}
+		htlcIndex++
+	}
 
-		// If the minimum relay fee is above the max anchor commitment
-		// fee rate but still below our max fee rate then use the min
-		// relay fee.
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 400000, 300000, 0.001,
-			400000,
-		)
+	// Helper method that fetches the current remote commitment weight
+	// fromt the given channel's POV.
+	remoteCommitWeight := func(lc *LightningChannel) int64 {
+		remoteACKedIndex := lc.localCommitChain.tip().theirMessageIndex
+		htlcView := lc.fetchHTLCView(remoteACKedIndex,
+			lc.localUpdateLog.logIndex)
 
-		// If the min relay fee is above the ideal max fee rate but is
-		// below the max fee rate when the max fee allocation is set to
-		// 1, the minimum relay fee is used.
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 500000, 300000, 0.001,
-			500000,
+		_, w := lc.availableCommitmentBalance(
+			htlcView, true,
 		)
 
-		// The absolute maximum fee rate we can pay (ie, using a max
-		// allocation of 1) is still below the minimum relay fee. In
-		// this case, use the absolute max fee.
-		assertIdealFeeRate(
-			anchorChannel, 700000000, 450000000, 300000, 0.001,
-			435941555,
-		)
-	})
-}
+		return w
+	}
 
-type maxAlloc float64
+	// Start by getting the initial remote commitment weight seen from
+	// Alice's perspective. At this point there are no HTLCs on the
+	// commitment.
+	weight1 := remoteCommitWeight(aliceChannel)
 
-// Generate ensures that the random value generated by the testing quick
-// package for maxAlloc is always a positive float64 between 0 and 1.
-func (maxAlloc) Generate(r *rand.Rand, _ int) reflect.Value {
-	ma := maxAlloc(r.Float64())
-	return reflect.ValueOf(ma)
-}
+	// Now add an HTLC that will be just below Bob's dustlimit.
+	// Since this is an HTLC added from Alice on Bob's commitment, we will
+	// use the HTLC success fee.
+	bobDustHtlc := bobDustlimit + htlcSuccessFee - 1
+	preimg := addHtlc(bobDustHtlc)
 
-type fee chainfee.SatPerKWeight
+	// Now get the current weight of the remote commitment. We expect it to
+	// not have changed, since the HTLC we added is considered dust.
+	weight2 := remoteCommitWeight(aliceChannel)
+	require.Equal(t, weight1, weight2)
 
-// Generate ensures that the random value generated by the testing quick
-// package for a fee is always a positive int64.
-func (fee) Generate(r *rand.Rand, _ int) reflect.Value {
-	am := fee(r.Int63())
-	return reflect.ValueOf(am)
+	// In addition, we expect this weight to result in the fee we currently
+	// see being paid on the remote commitent.
+	calcFee := feeRate.FeeForWeight(weight2)
+	remoteCommitFee := aliceChannel.channelState.RemoteCommitment.CommitFee
+	require.Equal(t, calcFee, remoteCommitFee)
+
+	// Settle the HTLC, bringing commitment weight back to base.
+	settleHtlc(preimg)
+
+	// Now we do a similar check from Bob's POV. Start with getting his
+	// current view of Alice's commitment weight.
+	weight1 = remoteCommitWeight(bobChannel)
+
+	// We'll add an HTLC from Alice to Bob, that is just above dust on
+	// Alice's commitment. Now we'll use the timeout fee.
+	aliceDustHtlc := aliceDustlimit + htlcTimeoutFee
+	preimg = addHtlc(aliceDustHtlc)
+
+	// Get the current remote commitment weight from Bob's POV, and ensure
+	// it is now heavier, since Alice added a non-dust HTLC.
+	weight2 = remoteCommitWeight(bobChannel)
+	require.Greater(t, weight2, weight1)
+
+	// Ensure the current remote commit has the expected commitfee.
+	calcFee = feeRate.FeeForWeight(weight2)
+	remoteCommitFee = bobChannel.channelState.RemoteCommitment.CommitFee
+	require.Equal(t, calcFee, remoteCommitFee)
+
+	settleHtlc(preimg)
 }
 
-// TestChannelFeeRateFloor asserts that valid commitments can be proposed and
-// received using chainfee.FeePerKwFloor as the initiator's fee rate.
-func TestChannelFeeRateFloor(t *testing.T) {
-	t.Parallel()
+// TestCapacityBreakdown asserts that the capacity breakdown of a channel with
+// both dust and non-dust HTLCs adds back up to the channel capacity.
+func TestCapacityBreakdown(t *testing.T) {
+	t.Run("tweakless", func(t *testing.T) {
+		testCapacityBreakdown(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("anchors", func(t *testing.T) {
+		testCapacityBreakdown(
+			t, channeldb.SingleFunderTweaklessBit|
+				channeldb.AnchorOutputsBit,
+		)
+	})
+}
 
-	alice, bob, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
-	)
-	require.NoError(t, err, "unable to create test channels")
+func testCapacityBreakdown(t *testing.T, chanType channeldb.ChannelType) {
+	t.Parallel()
 
-	// Set the fee rate to the proposing fee rate floor.
-	minFee := chainfee.FeePerKwFloor
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err)
 
-	// Alice is the initiator, so only she can propose fee updates.
-	if err := alice.UpdateFee(minFee); err != nil {
-		t.Fatalf("unable to send fee update")
-	}
-	if err := bob.ReceiveUpdateFee(minFee); err != nil {
-		t.Fatalf("unable to receive fee update")
+	// Add a non-dust and a dust HTLC from Alice to Bob, and lock them in.
+	amts := []btcutil.Amount{100_000, 100}
+	for i, amt := range amts {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(amt))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
 	}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Check that alice can still sign commitments.
-	aliceNewCommit, err := alice.SignNextCommitment()
-	require.NoError(t, err, "alice unable to sign commitment")
+	capacity := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.Capacity,
+	)
+	for _, channel := range []*LightningChannel{aliceChannel, bobChannel} {
+		b := channel.CapacityBreakdown()
 
-	// Check that bob can still receive commitments.
-	err = bob.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	if err != nil {
-		t.Fatalf("bob unable to process alice's new commitment: %v",
-			err)
+		require.Equal(
+			t, lnwire.NewMSatFromSatoshis(100_100),
+			b.CommittedHTLCs,
+		)
+		require.NotZero(t, b.CommitFee)
+		require.Equal(t, chanType.HasAnchors(), b.AnchorOutputs != 0)
+
+		chanState := channel.channelState
+		require.Equal(
+			t, lnwire.NewMSatFromSatoshis(
+				chanState.LocalChanCfg.ChanReserve,
+			), b.LocalReserve,
+		)
+		require.Equal(
+			t, lnwire.NewMSatFromSatoshis(
+				chanState.RemoteChanCfg.ChanReserve,
+			), b.RemoteReserve,
+		)
+
+		total := b.LocalReserve + b.RemoteReserve + b.LocalAvailable +
+			b.RemoteAvailable + b.CommittedHTLCs + b.CommitFee +
+			b.AnchorOutputs
+		require.Equal(t, capacity, total)
 	}
 }
 
-// TestFetchParent tests lookup of an entry's parent in the appropriate log.
-func TestFetchParent(t *testing.T) {
-	tests := []struct {
-		name          string
-		remoteChain   bool
-		remoteLog     bool
-		localEntries  []*PaymentDescriptor
-		remoteEntries []*PaymentDescriptor
-
-		// parentIndex is the parent index of the entry that we will
-		// lookup with fetch parent.
-		parentIndex uint64
-
-		// expectErr indicates that we expect fetch parent to fail.
-		expectErr bool
+// TestCommitmentScriptsByChannelType asserts that channels of each type are
+// opened with a commitment transaction whose outputs use the keys and scripts
+// selected by the type of the channel.
+func TestCommitmentScriptsByChannelType(t *testing.T) {
+	t.Parallel()
 
-		// expectedIndex is the htlc index that we expect the parent
-		// to have.
-		expectedIndex uint64
+	testCases := []struct {
+		name     string
+		chanType channeldb.ChannelType
 	}{
 		{
-			name:          "not found in remote log",
-			localEntries:  nil,
-			remoteEntries: nil,
-			remoteChain:   true,
-			remoteLog:     true,
-			parentIndex:   0,
-			expectErr:     true,
+			name:     "legacy tweaked",
+			chanType: channeldb.SingleFunderBit,
 		},
 		{
-			name:          "not found in local log",
-			localEntries:  nil,
-			remoteEntries: nil,
-			remoteChain:   false,
-			remoteLog:     false,
-			parentIndex:   0,
-			expectErr:     true,
+			name:     "static remote key",
+			chanType: channeldb.SingleFunderTweaklessBit,
 		},
 		{
-			name:         "remote log + chain, remote add height 0",
-			localEntries: nil,
-			remoteEntries: []*PaymentDescriptor{
-				// This entry will be added at log index =0.
-				{
-					HtlcIndex:             1,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 100,
-				},
-				// This entry will be added at log index =1, it
-				// is the parent entry we are looking for.
-				{
-					HtlcIndex:             2,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 0,
-				},
-			},
-			remoteChain: true,
-			remoteLog:   true,
-			parentIndex: 1,
-			expectErr:   true,
-		},
-		{
-			name: "remote log, local chain, local add height 0",
-			remoteEntries: []*PaymentDescriptor{
-				// This entry will be added at log index =0.
-				{
-					HtlcIndex:             1,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 100,
-				},
-				// This entry will be added at log index =1, it
-				// is the parent entry we are looking for.
-				{
-					HtlcIndex:             2,
-					addCommitHeightLocal:  0,
-					addCommitHeightRemote: 100,
-				},
-			},
-			localEntries: nil,
-			remoteChain:  false,
-			remoteLog:    true,
-			parentIndex:  1,
-			expectErr:    true,
-		},
-		{
-			name: "local log + chain, local add height 0",
-			localEntries: []*PaymentDescriptor{
-				// This entry will be added at log index =0.
-				{
-					HtlcIndex:             1,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 100,
-				},
-				// This entry will be added at log index =1, it
-				// is the parent entry we are looking for.
-				{
-					HtlcIndex:             2,
-					addCommitHeightLocal:  0,
-					addCommitHeightRemote: 100,
-				},
-			},
-			remoteEntries: nil,
-			remoteChain:   false,
-			remoteLog:     false,
-			parentIndex:   1,
-			expectErr:     true,
-		},
-
-		{
-			name: "local log + remote chain, remote add height 0",
-			localEntries: []*PaymentDescriptor{
-				// This entry will be added at log index =0.
-				{
-					HtlcIndex:             1,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 100,
-				},
-				// This entry will be added at log index =1, it
-				// is the parent entry we are looking for.
-				{
-					HtlcIndex:             2,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 0,
-				},
-			},
-			remoteEntries: nil,
-			remoteChain:   true,
-			remoteLog:     false,
-			parentIndex:   1,
-			expectErr:     true,
+			name: "anchors",
+			chanType: channeldb.SingleFunderTweaklessBit |
+				channeldb.AnchorOutputsBit,
 		},
 		{
-			name:         "remote log found",
-			localEntries: nil,
-			remoteEntries: []*PaymentDescriptor{
-				// This entry will be added at log index =0.
-				{
-					HtlcIndex:             1,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 0,
-				},
-				// This entry will be added at log index =1, it
-				// is the parent entry we are looking for.
-				{
-					HtlcIndex:             2,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 100,
-				},
-			},
-			remoteChain:   true,
-			remoteLog:     true,
-			parentIndex:   1,
-			expectErr:     false,
-			expectedIndex: 2,
+			name: "anchors zero fee htlc tx",
+			chanType: channeldb.SingleFunderTweaklessBit |
+				channeldb.AnchorOutputsBit |
+				channeldb.ZeroHtlcTxFeeBit,
 		},
 		{
-			name: "local log found",
-			localEntries: []*PaymentDescriptor{
-				// This entry will be added at log index =0.
-				{
-					HtlcIndex:             1,
-					addCommitHeightLocal:  0,
-					addCommitHeightRemote: 100,
-				},
-				// This entry will be added at log index =1, it
-				// is the parent entry we are looking for.
-				{
-					HtlcIndex:             2,
-					addCommitHeightLocal:  100,
-					addCommitHeightRemote: 100,
-				},
-			},
-			remoteEntries: nil,
-			remoteChain:   false,
-			remoteLog:     false,
-			parentIndex:   1,
-			expectErr:     false,
-			expectedIndex: 2,
+			name: "simple taproot",
+			chanType: channeldb.SingleFunderTweaklessBit |
+				channeldb.AnchorOutputsBit |
+				channeldb.ZeroHtlcTxFeeBit |
+				channeldb.SimpleTaprootFeatureBit,
 		},
 	}
 
-	for _, test := range tests {
-		test := test
+	for _, tc := range testCases {
+		tc := tc
 
-		t.Run(test.name, func(t *testing.T) {
-			// Create a lightning channel with newly initialized
-			// local and remote logs.
-			lc := LightningChannel{
-				localUpdateLog:  newUpdateLog(0, 0),
-				remoteUpdateLog: newUpdateLog(0, 0),
-			}
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
 
-			// Add the local and remote entries to update logs.
-			for _, entry := range test.localEntries {
-				lc.localUpdateLog.appendHtlc(entry)
-			}
-			for _, entry := range test.remoteEntries {
-				lc.remoteUpdateLog.appendHtlc(entry)
-			}
+			aliceChannel, _, err := CreateTestChannels(
+				t, tc.chanType,
+			)
+			require.NoError(t, err)
 
-			parent, err := lc.fetchParent(
-				&PaymentDescriptor{
-					ParentIndex: test.parentIndex,
-				},
-				test.remoteChain,
-				test.remoteLog,
+			state := aliceChannel.channelState
+			localCfg := &state.LocalChanCfg
+			remoteCfg := &state.RemoteChanCfg
+
+			// Derive the keys of Alice's initial commitment.
+			secret, err := state.RevocationProducer.AtIndex(0)
+			require.NoError(t, err)
+			commitPoint := input.ComputeCommitmentPoint(secret[:])
+			keyRing := DeriveCommitmentKeys(
+				commitPoint, true, tc.chanType, localCfg,
+				remoteCfg,
 			)
-			gotErr := err != nil
-			if test.expectErr != gotErr {
-				t.Fatalf("expected error: %v, got: %v, "+
-					"error:%v", test.expectErr, gotErr, err)
-			}
 
-			// If our lookup failed, we do not need to check parent
-			// index.
-			if err != nil {
-				return
+			// Only the legacy type tweaks Bob's key within the
+			// to_remote output.
+			remoteBaseKey := remoteCfg.PaymentBasePoint.PubKey
+			require.Equal(
+				t, tc.chanType.IsTweakless(),
+				keyRing.ToRemoteKey.IsEqual(remoteBaseKey),
+			)
+
+			toLocal, err := CommitScriptToSelf(
+				tc.chanType, true, keyRing.ToLocalKey,
+				keyRing.RevocationKey,
+				uint32(localCfg.CsvDelay), 0,
+			)
+			require.NoError(t, err)
+			toRemote, _, err := CommitScriptToRemote(
+				tc.chanType, true, keyRing.ToRemoteKey, 0,
+			)
+			require.NoError(t, err)
+
+			// The commitment must hold both outputs, and an anchor
+			// for each party if the type has anchors.
+			commitTx := state.LocalCommitment.CommitTx
+			pkScripts := make([][]byte, 0, len(commitTx.TxOut))
+			for _, txOut := range commitTx.TxOut {
+				pkScripts = append(pkScripts, txOut.PkScript)
 			}
+			require.Contains(t, pkScripts, toLocal.PkScript())
+			require.Contains(t, pkScripts, toRemote.PkScript())
 
-			if parent.HtlcIndex != test.expectedIndex {
-				t.Fatalf("expected parent index: %v, got: %v",
-					test.parentIndex, parent.HtlcIndex)
+			numOutputs := 2
+			if tc.chanType.HasAnchors() {
+				numOutputs += 2
 			}
+			require.Len(t, commitTx.TxOut, numOutputs)
 		})
 	}
 }
 
-// TestEvaluateView tests the creation of a htlc view and the opt in mutation of
-// send and receive balances. This test does not check htlc mutation on a htlc
-// level.
-func TestEvaluateView(t *testing.T) {
-	const (
-		// addHeight is a non-zero height that is used for htlc adds.
-		addHeight = 200
-
-		// nextHeight is a constant that we use for the next height in
-		// all unit tests.
-		nextHeight = 400
+// TestCommitmentStateHint asserts that both parties recover the height of
+// either party's commitment from its state hint.
+func TestCommitmentStateHint(t *testing.T) {
+	t.Parallel()
 
-		// feePerKw is the fee we start all of our unit tests with.
-		feePerKw = 1
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
 
-		// htlcAddAmount is the amount for htlc adds in tests.
-		htlcAddAmount = 15
+	assertHints := func(height uint64) {
+		t.Helper()
 
-		// ourFeeUpdateAmt is an amount that we update fees to
-		// expressed in msat.
-		ourFeeUpdateAmt = 20000
+		channels := []*LightningChannel{aliceChannel, bobChannel}
+		for _, lc := range channels {
+			for _, owner := range channels {
+				commit := owner.channelState.LocalCommitment
 
-		// ourFeeUpdatePerSat is the fee rate *in satoshis* that we
-		// expect if we update to ourFeeUpdateAmt.
-		ourFeeUpdatePerSat = chainfee.SatPerKWeight(20)
+				hint, err := lc.CommitmentStateHint(
+					commit.CommitTx,
+				)
+				require.NoError(t, err)
+				require.Equal(t, height, hint)
+			}
+		}
+	}
 
-		// theirFeeUpdateAmt iis an amount that they update fees to
-		// expressed in msat.
-		theirFeeUpdateAmt = 10000
+	assertHints(0)
+	for height := uint64(1); height <= 3; height++ {
+		htlc, _ := createHTLC(
+			int(height-1), lnwire.NewMSatFromSatoshis(20_000),
+		)
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
 
-		// theirFeeUpdatePerSat is the fee rate *in satoshis* that we
-		// expect if we update to ourFeeUpdateAmt.
-		theirFeeUpdatePerSat = chainfee.SatPerKWeight(10)
-	)
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
 
-	tests := []struct {
-		name        string
-		ourHtlcs    []*PaymentDescriptor
-		theirHtlcs  []*PaymentDescriptor
-		remoteChain bool
-		mutateState bool
+		assertHints(height)
+	}
 
-		// ourExpectedHtlcs is the set of our htlcs that we expect in
-		// the htlc view once it has been evaluated. We just store
-		// htlc index -> bool for brevity, because we only check the
-		// presence of the htlc in the returned set.
-		ourExpectedHtlcs map[uint64]bool
+	// A transaction that isn't spending the funding output alone can't
+	// be a commitment.
+	_, err = aliceChannel.CommitmentStateHint(wire.NewMsgTx(2))
+	require.Error(t, err)
+}
 
-		// theirExpectedHtlcs is the set of their htlcs that we expect
-		// in the htlc view once it has been evaluated. We just store
-		// htlc index -> bool for brevity, because we only check the
-		// presence of the htlc in the returned set.
-		theirExpectedHtlcs map[uint64]bool
+// TestSignCommitmentFailNotLockedIn tests that a channel will not attempt to
+// create a new state if it doesn't yet know of the next revocation point for
+// the remote party.
+func TestSignCommitmentFailNotLockedIn(t *testing.T) {
+	t.Parallel()
 
-		// expectedFee is the fee we expect to be set after evaluating
-		// the htlc view.
-		expectedFee chainfee.SatPerKWeight
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Next, we'll modify Alice's internal state to omit knowledge of Bob's
+	// next revocation point.
+	aliceChannel.channelState.RemoteNextRevocation = nil
+
+	// If we now try to initiate a state update, then it should fail as
+	// Alice is unable to actually create a new state.
+	_, err = aliceChannel.SignNextCommitment()
+	if err != ErrNoWindow {
+		t.Fatalf("expected ErrNoWindow, instead have: %v", err)
+	}
+}
+
+// TestLockedInHtlcForwardingSkipAfterRestart ensures that after a restart, a
+// state machine doesn't attempt to re-forward any HTLC's that were already
+// locked in, but in a prior state.
+func TestLockedInHtlcForwardingSkipAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	// First, we'll make a channel between Alice and Bob.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We'll now add two HTLC's from Alice to Bob, then Alice will initiate
+	// a state transition.
+	var htlcAmt lnwire.MilliSatoshi = 100000
+	htlc, _ := createHTLC(0, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+	htlc2, _ := createHTLC(1, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc2, nil); err != nil {
+		t.Fatalf("unable to add htlc2: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc2); err != nil {
+		t.Fatalf("unable to recv htlc2: %v", err)
+	}
+
+	// We'll now manually initiate a state transition between Alice and
+	// bob.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice should detect that she doesn't need to forward any HTLC's.
+	fwdPkg, _, _, _, err := aliceChannel.ReceiveRevocation(bobRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fwdPkg.Adds) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(fwdPkg.Adds))
+	}
+	if len(fwdPkg.SettleFails) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(fwdPkg.SettleFails))
+	}
+
+	// Now, have Bob initiate a transition to lock in the Adds sent by
+	// Alice.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob should now detect that he now has 2 incoming HTLC's that he can
+	// forward along.
+	fwdPkg, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fwdPkg.Adds) != 2 {
+		t.Fatalf("bob should forward 2 hltcs, instead has %v",
+			len(fwdPkg.Adds))
+	}
+	if len(fwdPkg.SettleFails) != 0 {
+		t.Fatalf("bob should forward 0 hltcs, instead has %v",
+			len(fwdPkg.SettleFails))
+	}
+
+	// We'll now restart both Alice and Bob. This emulates a reconnection
+	// between the two peers.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart alice")
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart bob")
+
+	// With both nodes restarted, Bob will now attempt to cancel one of
+	// Alice's HTLC's.
+	err = bobChannel.FailHTLC(htlc.ID, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveFailHTLC(htlc.ID, []byte("bad"))
+	require.NoError(t, err, "unable to recv htlc cancel")
+
+	// We'll now initiate another state transition, but this time Bob will
+	// lead.
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At this point, Bob receives the revocation from Alice, which is now
+	// his signal to examine all the HTLC's that have been locked in to
+	// process.
+	fwdPkg, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob should detect that he doesn't need to forward *any* HTLC's, as
+	// he was the one that initiated extending the commitment chain of
+	// Alice.
+	if len(fwdPkg.Adds) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(fwdPkg.Adds))
+	}
+	if len(fwdPkg.SettleFails) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(fwdPkg.SettleFails))
+	}
+
+	// Now, begin another state transition led by Alice, and fail the second
+	// HTLC part-way through the dance.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failing the HTLC here will cause the update to be included in Alice's
+	// remote log, but it should not be committed by this transition.
+	err = bobChannel.FailHTLC(htlc2.ID, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveFailHTLC(htlc2.ID, []byte("bad"))
+	require.NoError(t, err, "unable to recv htlc cancel")
+
+	bobRevocation, _, finalHtlcs, err := bobChannel.
+		RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check finalHtlcs for the expected final resolution.
+	require.Len(t, finalHtlcs, 1, "final htlc expected")
+	for _, settled := range finalHtlcs {
+		require.False(t, settled, "final fail expected")
+	}
+
+	// Alice should detect that she doesn't need to forward any Adds's, but
+	// that the Fail has been locked in an can be forwarded.
+	_, adds, settleFails, _, err := aliceChannel.ReceiveRevocation(bobRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adds) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(adds))
+	}
+	if len(settleFails) != 1 {
+		t.Fatalf("alice should only forward %d HTLC's, instead wants to "+
+			"forward %v htlcs", 1, len(settleFails))
+	}
+	if settleFails[0].ParentIndex != htlc.ID {
+		t.Fatalf("alice should forward fail for htlcid=%d, instead "+
+			"forwarding id=%d", htlc.ID,
+			settleFails[0].ParentIndex)
+	}
+
+	// We'll now restart both Alice and Bob. This emulates a reconnection
+	// between the two peers.
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart alice")
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart bob")
+
+	// Re-add the Fail to both Alice and Bob's channels, as the non-committed
+	// update will not have survived the restart.
+	err = bobChannel.FailHTLC(htlc2.ID, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveFailHTLC(htlc2.ID, []byte("bad"))
+	require.NoError(t, err, "unable to recv htlc cancel")
+
+	// Have Alice initiate a state transition, which does not include the
+	// HTLCs just re-added to the channel state.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice should detect that she doesn't need to forward any HTLC's, as
+	// the updates haven't been committed by Bob yet.
+	fwdPkg, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fwdPkg.Adds) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(fwdPkg.Adds))
+	}
+	if len(fwdPkg.SettleFails) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(fwdPkg.SettleFails))
+	}
+
+	// Now initiate a final update from Bob to lock in the final Fail.
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob should detect that he has nothing to forward, as he hasn't
+	// received any HTLCs.
+	fwdPkg, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fwdPkg.Adds) != 0 {
+		t.Fatalf("bob should forward 4 hltcs, instead has %v",
+			len(fwdPkg.Adds))
+	}
+	if len(fwdPkg.SettleFails) != 0 {
+		t.Fatalf("bob should forward 0 hltcs, instead has %v",
+			len(fwdPkg.SettleFails))
+	}
+
+	// Finally, have Bob initiate a state transition that locks in the Fail
+	// added after the restart.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When Alice receives the revocation, she should detect that she
+	// can now forward the freshly locked-in Fail.
+	_, adds, settleFails, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adds) != 0 {
+		t.Fatalf("alice shouldn't forward any HTLC's, instead wants to "+
+			"forward %v htlcs", len(adds))
+	}
+	if len(settleFails) != 1 {
+		t.Fatalf("alice should only forward one HTLC, instead wants to "+
+			"forward %v htlcs", len(settleFails))
+	}
+	if settleFails[0].ParentIndex != htlc2.ID {
+		t.Fatalf("alice should forward fail for htlcid=%d, instead "+
+			"forwarding id=%d", htlc2.ID,
+			settleFails[0].ParentIndex)
+	}
+}
+
+// TestInvalidCommitSigError tests that if the remote party sends us an invalid
+// commitment signature, then we'll reject it and return a special error that
+// contains information to allow the remote party to debug their issues.
+func TestInvalidCommitSigError(t *testing.T) {
+	t.Parallel()
+
+	// First, we'll make a channel between Alice and Bob.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// With the channel established, we'll now send a single HTLC from
+	// Alice to Bob.
+	var htlcAmt lnwire.MilliSatoshi = 100000
+	htlc, _ := createHTLC(0, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Alice will now attempt to initiate a state transition.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign new commit")
+
+	// Before the signature gets to Bob, we'll mutate it, such that the
+	// signature is now actually invalid.
+	aliceSigCopy := aliceNewCommit.CommitSig.Copy()
+	aliceSigCopyBytes := aliceSigCopy.RawBytes()
+	aliceSigCopyBytes[0] ^= 80
+	aliceNewCommit.CommitSig, err = lnwire.NewSigFromWireECDSA(
+		aliceSigCopyBytes,
+	)
+	require.NoError(t, err)
+
+	// Bob should reject this new state, and return the proper error.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err == nil {
+		t.Fatalf("bob accepted invalid state but shouldn't have")
+	}
+	if _, ok := err.(*InvalidCommitSigError); !ok {
+		t.Fatalf("bob sent incorrect error, expected %T, got %T",
+			&InvalidCommitSigError{}, err)
+	}
+}
+
+// TestReceiveNewCommitmentSigPoolShutdown tests that if the HTLC signatures of
+// a new commitment can't be verified as the sig pool is shutting down, the
+// commitment isn't reported as invalid.
+func TestReceiveNewCommitmentSigPoolShutdown(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Add a non-dust HTLC, so the new commitment carries an HTLC
+	// signature that needs to be verified by the sig pool.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	require.Len(t, aliceNewCommit.HtlcSigs, 1)
+
+	// Once Bob's sig pool is stopped, it refuses the verification jobs,
+	// which must be surfaced as is rather than as an invalid signature.
+	require.NoError(t, bobChannel.sigPool.Stop())
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.ErrorIs(t, err, ErrSigPoolShuttingDown)
+
+	var htlcSigErr *InvalidHtlcSigError
+	require.False(t, errors.As(err, &htlcSigErr))
+}
+
+// TestChannelUnilateralCloseHtlcResolution tests that in the case of a
+// unilateral channel closure, then the party that didn't broadcast the
+// commitment is able to properly sweep all relevant outputs.
+func TestChannelUnilateralCloseHtlcResolution(t *testing.T) {
+	t.Parallel()
+
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We'll start off the test by adding an HTLC in both directions, then
+	// initiating enough state transitions to lock both of them in.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
+	htlcBob, preimageBob := createHTLC(0, htlcAmount)
+	if _, err := bobChannel.AddHTLC(htlcBob, nil); err != nil {
+		t.Fatalf("bob unable to add htlc: %v", err)
+	}
+	if _, err := aliceChannel.ReceiveHTLC(htlcBob); err != nil {
+		t.Fatalf("alice unable to recv add htlc: %v", err)
+	}
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
+	}
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("Can't update the channel state: %v", err)
+	}
+
+	// With both HTLC's locked in, we'll now simulate Bob force closing the
+	// transaction on Alice.
+	bobForceClose, err := bobChannel.ForceClose()
+	require.NoError(t, err, "unable to close")
+
+	// We'll then use Bob's transaction to trigger a spend notification for
+	// Alice.
+	closeTx := bobForceClose.CloseTx
+	commitTxHash := closeTx.TxHash()
+	spendDetail := &chainntnfs.SpendDetail{
+		SpendingTx:    closeTx,
+		SpenderTxHash: &commitTxHash,
+	}
+	aliceCloseSummary, err := NewUnilateralCloseSummary(
+		aliceChannel.channelState, aliceChannel.Signer,
+		spendDetail,
+		aliceChannel.channelState.RemoteCommitment,
+		aliceChannel.channelState.RemoteCurrentRevocation,
+	)
+	require.NoError(t, err, "unable to create alice close summary")
+
+	// She should detect that she can sweep both the outgoing HTLC as well
+	// as the incoming one from Bob.
+	if len(aliceCloseSummary.HtlcResolutions.OutgoingHTLCs) != 1 {
+		t.Fatalf("alice out htlc resolutions not populated: expected %v "+
+			"htlcs, got %v htlcs",
+			1, len(aliceCloseSummary.HtlcResolutions.OutgoingHTLCs))
+	}
+	if len(aliceCloseSummary.HtlcResolutions.IncomingHTLCs) != 1 {
+		t.Fatalf("alice in htlc resolutions not populated: expected %v "+
+			"htlcs, got %v htlcs",
+			1, len(aliceCloseSummary.HtlcResolutions.IncomingHTLCs))
+	}
+
+	outHtlcResolution := aliceCloseSummary.HtlcResolutions.OutgoingHTLCs[0]
+	inHtlcResolution := aliceCloseSummary.HtlcResolutions.IncomingHTLCs[0]
+
+	// First, we'll ensure that Alice can directly spend the outgoing HTLC
+	// given a transaction with the proper lock time set.
+	receiverHtlcScript := closeTx.TxOut[outHtlcResolution.ClaimOutpoint.Index].PkScript
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: outHtlcResolution.ClaimOutpoint,
+	})
+	sweepTx.AddTxOut(&wire.TxOut{
+		PkScript: receiverHtlcScript,
+		Value:    outHtlcResolution.SweepSignDesc.Output.Value,
+	})
+	outHtlcResolution.SweepSignDesc.InputIndex = 0
+	outHtlcResolution.SweepSignDesc.SigHashes = input.NewTxSigHashesV0Only(
+		sweepTx,
+	)
+	sweepTx.LockTime = outHtlcResolution.Expiry
+
+	// With the transaction constructed, we'll generate a witness that
+	// should be valid for it, and verify using an instance of Script.
+	sweepTx.TxIn[0].Witness, err = input.ReceiverHtlcSpendTimeout(
+		aliceChannel.Signer, &outHtlcResolution.SweepSignDesc,
+		sweepTx, int32(outHtlcResolution.Expiry),
+	)
+	require.NoError(t, err, "unable to witness")
+	vm, err := txscript.NewEngine(
+		outHtlcResolution.SweepSignDesc.Output.PkScript,
+		sweepTx, 0, txscript.StandardVerifyFlags, nil,
+		nil, outHtlcResolution.SweepSignDesc.Output.Value,
+		txscript.NewCannedPrevOutputFetcher(
+			outHtlcResolution.SweepSignDesc.Output.PkScript,
+			outHtlcResolution.SweepSignDesc.Output.Value,
+		),
+	)
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc timeout spend is invalid: %v", err)
+	}
+
+	// Next, we'll ensure that we're able to sweep the incoming HTLC with a
+	// similar sweep transaction, this time using the payment pre-image.
+	senderHtlcScript := closeTx.TxOut[inHtlcResolution.ClaimOutpoint.Index].PkScript
+	sweepTx = wire.NewMsgTx(2)
+	sweepTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: inHtlcResolution.ClaimOutpoint,
+	})
+	sweepTx.AddTxOut(&wire.TxOut{
+		PkScript: senderHtlcScript,
+		Value:    inHtlcResolution.SweepSignDesc.Output.Value,
+	})
+	inHtlcResolution.SweepSignDesc.InputIndex = 0
+	inHtlcResolution.SweepSignDesc.SigHashes = input.NewTxSigHashesV0Only(
+		sweepTx,
+	)
+	sweepTx.TxIn[0].Witness, err = input.SenderHtlcSpendRedeem(
+		aliceChannel.Signer, &inHtlcResolution.SweepSignDesc,
+		sweepTx, preimageBob[:],
+	)
+	if err != nil {
+		t.Fatalf("unable to generate witness for success "+
+			"output: %v", err)
+	}
+
+	// Finally, we'll verify the constructed witness to ensure that Alice
+	// can properly sweep the output.
+	vm, err = txscript.NewEngine(
+		inHtlcResolution.SweepSignDesc.Output.PkScript,
+		sweepTx, 0, txscript.StandardVerifyFlags, nil,
+		nil, inHtlcResolution.SweepSignDesc.Output.Value,
+		txscript.NewCannedPrevOutputFetcher(
+			inHtlcResolution.SweepSignDesc.Output.PkScript,
+			inHtlcResolution.SweepSignDesc.Output.Value,
+		),
+	)
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc timeout spend is invalid: %v", err)
+	}
+}
+
+// TestStaticRemoteKeySweep asserts that the output paying to us on the remote
+// party's commitment can be swept both on unilateral close and breach, and
+// that it only uses our static payment base point as key for channels with
+// the static_remotekey feature.
+func TestStaticRemoteKeySweep(t *testing.T) {
+	t.Run("tweaked", func(t *testing.T) {
+		testStaticRemoteKeySweep(t, channeldb.SingleFunderBit)
+	})
+	t.Run("tweakless", func(t *testing.T) {
+		testStaticRemoteKeySweep(t, channeldb.SingleFunderTweaklessBit)
+	})
+}
+
+func testStaticRemoteKeySweep(t *testing.T, chanType channeldb.ChannelType) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	tweakless := chanType.IsTweakless()
+
+	// assertSweepable asserts that the output described by the sign
+	// descriptor can be swept by Alice, and that the key it's locked to
+	// is only tweaked for channels without a static remote key.
+	assertSweepable := func(signDesc *input.SignDescriptor,
+		outpoint wire.OutPoint) {
+
+		t.Helper()
+
+		basePoint := aliceChannel.channelState.LocalChanCfg.
+			PaymentBasePoint.PubKey
+		require.True(t, signDesc.KeyDesc.PubKey.IsEqual(basePoint))
+
+		outputKey := basePoint
+		if tweakless {
+			require.Nil(t, signDesc.SingleTweak)
+		} else {
+			require.NotNil(t, signDesc.SingleTweak)
+			outputKey = input.TweakPubKeyWithTweak(
+				basePoint, signDesc.SingleTweak,
+			)
+		}
+
+		pkScript, err := input.CommitScriptUnencumbered(outputKey)
+		require.NoError(t, err)
+		require.Equal(t, pkScript, signDesc.Output.PkScript)
+
+		sweepTx := wire.NewMsgTx(2)
+		sweepTx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: outpoint,
+		})
+		sweepTx.AddTxOut(&wire.TxOut{
+			PkScript: pkScript,
+			Value:    signDesc.Output.Value,
+		})
+
+		signDesc.InputIndex = 0
+		signDesc.SigHashes = input.NewTxSigHashesV0Only(sweepTx)
+		sweepTx.TxIn[0].Witness, err = input.CommitSpendNoDelay(
+			aliceChannel.Signer, signDesc, sweepTx, tweakless,
+		)
+		require.NoError(t, err, "unable to generate witness")
+
+		vm, err := txscript.NewEngine(
+			signDesc.Output.PkScript, sweepTx, 0,
+			txscript.StandardVerifyFlags, nil, nil,
+			signDesc.Output.Value,
+			txscript.NewCannedPrevOutputFetcher(
+				signDesc.Output.PkScript, signDesc.Output.Value,
+			),
+		)
+		require.NoError(t, err, "unable to create engine")
+		require.NoError(t, vm.Execute(), "sweep is invalid")
+	}
+
+	// We'll advance the state once, such that Bob's commitment at height
+	// zero is revoked and the per-commitment point differs from the
+	// initial one.
+	revokedCommit := bobChannel.channelState.LocalCommitment.CommitTx
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// First, Bob force closes using his current commitment.
+	bobForceClose, err := bobChannel.ForceClose()
+	require.NoError(t, err, "unable to force close")
+
+	closeTx := bobForceClose.CloseTx
+	closeTxHash := closeTx.TxHash()
+	aliceCloseSummary, err := NewUnilateralCloseSummary(
+		aliceChannel.channelState, aliceChannel.Signer,
+		&chainntnfs.SpendDetail{
+			SpendingTx:    closeTx,
+			SpenderTxHash: &closeTxHash,
+		},
+		aliceChannel.channelState.RemoteCommitment,
+		aliceChannel.channelState.RemoteCurrentRevocation,
+	)
+	require.NoError(t, err, "unable to create alice close summary")
+
+	commitRes := aliceCloseSummary.CommitResolution
+	require.NotNil(t, commitRes)
+	assertSweepable(&commitRes.SelfOutputSignDesc, commitRes.SelfOutPoint)
+
+	// Alternatively, Bob may broadcast his revoked commitment, in which
+	// case Alice should be able to sweep her output in the same way.
+	breachRet, err := NewBreachRetribution(
+		aliceChannel.channelState, 0, 0, revokedCommit,
+	)
+	require.NoError(t, err, "unable to create breach retribution")
+	require.NotNil(t, breachRet.LocalOutputSignDesc)
+	assertSweepable(breachRet.LocalOutputSignDesc, breachRet.LocalOutpoint)
+}
+
+// TestChannelUnilateralClosePendingCommit tests that if the remote party
+// broadcasts their pending commit (hasn't yet revoked the lower one), then
+// we'll create a proper unilateral channel clsoure that can sweep the created
+// outputs.
+func TestChannelUnilateralClosePendingCommit(t *testing.T) {
+	t.Parallel()
+
+	// Create a test channel which will be used for the duration of this
+	// unittest. The channel will be funded evenly with Alice having 5 BTC,
+	// and Bob having 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First, we'll add an HTLC from Alice to Bob, just to be be able to
+	// create a new state transition.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
+
+	// With the HTLC added, we'll now manually initiate a state transition
+	// from Alice to Bob.
+	_, err = aliceChannel.SignNextCommitment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// At this point, Alice's commitment chain should have a new pending
+	// commit for Bob. We'll extract it so we can simulate Bob broadcasting
+	// the commitment due to an issue.
+	bobCommit := aliceChannel.remoteCommitChain.tip().txn
+	bobTxHash := bobCommit.TxHash()
+	spendDetail := &chainntnfs.SpendDetail{
+		SpenderTxHash: &bobTxHash,
+		SpendingTx:    bobCommit,
+	}
+
+	// At this point, if we attempt to create a unilateral close summary
+	// using this commitment, but with the wrong state, we should find that
+	// our output wasn't picked up.
+	aliceWrongCloseSummary, err := NewUnilateralCloseSummary(
+		aliceChannel.channelState, aliceChannel.Signer,
+		spendDetail,
+		aliceChannel.channelState.RemoteCommitment,
+		aliceChannel.channelState.RemoteCurrentRevocation,
+	)
+	require.NoError(t, err, "unable to create alice close summary")
+
+	if aliceWrongCloseSummary.CommitResolution != nil {
+		t.Fatalf("alice shouldn't have found self output")
+	}
+
+	// If we create the close summary again, but this time use Alice's
+	// pending commit to Bob, then the unilateral close summary should be
+	// properly populated.
+	aliceRemoteChainTip, err := aliceChannel.channelState.RemoteCommitChainTip()
+	require.NoError(t, err, "unable to fetch remote chain tip")
+	aliceCloseSummary, err := NewUnilateralCloseSummary(
+		aliceChannel.channelState, aliceChannel.Signer,
+		spendDetail,
+		aliceRemoteChainTip.Commitment,
+		aliceChannel.channelState.RemoteNextRevocation,
+	)
+	require.NoError(t, err, "unable to create alice close summary")
+
+	// With this proper version, Alice's commit resolution should have been
+	// properly located.
+	if aliceCloseSummary.CommitResolution == nil {
+		t.Fatalf("unable to find alice's commit resolution")
+	}
+
+	// The proper short channel ID should also be set in Alice's close
+	// channel summary.
+	if aliceCloseSummary.ChannelCloseSummary.ShortChanID !=
+		aliceChannel.ShortChanID() {
+
+		t.Fatalf("wrong short chan ID, expected %v got %v",
+			aliceChannel.ShortChanID(),
+			aliceCloseSummary.ChannelCloseSummary.ShortChanID)
+	}
+
+	aliceSignDesc := aliceCloseSummary.CommitResolution.SelfOutputSignDesc
+
+	// Finally, we'll ensure that we're able to properly sweep our output
+	// from using the materials within the unilateral close summary.
+	sweepTx := wire.NewMsgTx(2)
+	sweepTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: aliceCloseSummary.CommitResolution.SelfOutPoint,
+	})
+	sweepTx.AddTxOut(&wire.TxOut{
+		PkScript: testHdSeed[:],
+		Value:    aliceSignDesc.Output.Value,
+	})
+	aliceSignDesc.SigHashes = input.NewTxSigHashesV0Only(sweepTx)
+	sweepTx.TxIn[0].Witness, err = input.CommitSpendNoDelay(
+		aliceChannel.Signer, &aliceSignDesc, sweepTx, false,
+	)
+	require.NoError(t, err, "unable to generate sweep witness")
+
+	// If we validate the signature on the new sweep transaction, it should
+	// be fully valid.
+	vm, err := txscript.NewEngine(
+		aliceSignDesc.Output.PkScript, sweepTx, 0,
+		txscript.StandardVerifyFlags, nil, nil,
+		aliceSignDesc.Output.Value,
+		txscript.NewCannedPrevOutputFetcher(
+			aliceSignDesc.Output.PkScript,
+			aliceSignDesc.Output.Value,
+		),
+	)
+	require.NoError(t, err, "unable to create engine")
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("htlc timeout spend is invalid: %v", err)
+	}
+}
+
+// TestDesyncHTLCs checks that we cannot add HTLCs that would make the
+// balance negative, when the remote and local update logs are desynced.
+func TestDesyncHTLCs(t *testing.T) {
+	t.Parallel()
+
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First add one HTLC of value 4.1 BTC.
+	htlcAmt := lnwire.NewMSatFromSatoshis(4.1 * btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(0, htlcAmt)
+	aliceIndex, err := aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "unable to add htlc")
+	bobIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "unable to recv htlc")
+
+	// Lock this HTLC in.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	// Now let Bob fail this HTLC.
+	err = bobChannel.FailHTLC(bobIndex, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	if err := aliceChannel.ReceiveFailHTLC(aliceIndex, []byte("bad")); err != nil {
+		t.Fatalf("unable to recv htlc cancel: %v", err)
+	}
+
+	// Alice now has gotten all her original balance (5 BTC) back, however,
+	// adding a new HTLC at this point SHOULD fail, since if she adds the
+	// HTLC and signs the next state, Bob cannot assume she received the
+	// FailHTLC, and must assume she doesn't have the necessary balance
+	// available.
+	//
+	// We try adding an HTLC of value 1 BTC, which should fail because the
+	// balance is unavailable.
+	htlcAmt = lnwire.NewMSatFromSatoshis(1 * btcutil.SatoshiPerBitcoin)
+	htlc, _ = createHTLC(1, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// Now do a state transition, which will ACK the FailHTLC, making Alice
+	// able to add the new HTLC.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+	if _, err = aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+}
+
+// TODO(roasbeef): testing.Quick test case for retrans!!!
+
+// TestMaxAcceptedHTLCs tests that the correct error message (ErrMaxHTLCNumber)
+// is thrown when a node tries to accept more than MaxAcceptedHTLCs in a
+// channel.
+func TestMaxAcceptedHTLCs(t *testing.T) {
+	t.Parallel()
+
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// One over the maximum number of HTLCs that either can accept.
+	const numHTLCs = 12
+
+	// Set the remote's required MaxAcceptedHtlcs. This means that Alice
+	// can only offer the remote up to numHTLCs HTLCs.
+	aliceChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+	bobChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+
+	// Similarly, set the remote config's MaxAcceptedHtlcs. This means
+	// that the remote will be aware that Bob will only accept up to
+	// numHTLCs at a time.
+	aliceChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+	bobChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+
+	// Each HTLC amount is 0.1 BTC.
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+
+	// htlcID is used to keep track of the HTLC that Bob will fail back to
+	// Alice.
+	var htlcID uint64
+
+	// Send the maximum allowed number of HTLCs.
+	for i := 0; i < numHTLCs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
+
+		// Just assign htlcID to the last received HTLC.
+		htlcID = htlc.ID
+	}
+
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to transition state: %v", err)
+	}
+
+	// The next HTLC should fail with ErrMaxHTLCNumber.
+	htlc, _ := createHTLC(numHTLCs, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	if err != ErrMaxHTLCNumber {
+		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
+	}
+
+	// Receiving the next HTLC should fail.
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != ErrMaxHTLCNumber {
+		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
+	}
+
+	// Bob will fail the htlc specified by htlcID and then force a state
+	// transition.
+	err = bobChannel.FailHTLC(htlcID, []byte{}, nil, nil, nil)
+	require.NoError(t, err, "unable to fail htlc")
+
+	if err := aliceChannel.ReceiveFailHTLC(htlcID, []byte{}); err != nil {
+		t.Fatalf("unable to receive fail htlc: %v", err)
+	}
+
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to transition state: %v", err)
+	}
+
+	// Bob should succeed in adding a new HTLC since a previous HTLC was just
+	// failed. We use numHTLCs here since the previous AddHTLC with this index
+	// failed.
+	htlc, _ = createHTLC(numHTLCs, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Add a commitment to Bob's commitment chain.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign next commitment")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to recv new commitment")
+
+	// The next HTLC should fail with ErrMaxHTLCNumber. The index is incremented
+	// by one.
+	htlc, _ = createHTLC(numHTLCs+1, htlcAmt)
+	if _, err = aliceChannel.AddHTLC(htlc, nil); err != ErrMaxHTLCNumber {
+		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
+	}
+
+	// Likewise, Bob should not be able to receive this HTLC if Alice can't
+	// add it.
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != ErrMaxHTLCNumber {
+		t.Fatalf("expected ErrMaxHTLCNumber, instead received: %v", err)
+	}
+}
+
+// TestMaxAsynchronousHtlcs tests that Bob correctly receives (and does not
+// fail) an HTLC from Alice when exchanging asynchronous payments. We want to
+// mimic the following case where Bob's commitment transaction is full before
+// starting:
+//
+//	Alice                    Bob
+//
+// 1.         <---settle/fail---
+// 2.         <-------sig-------
+// 3.         --------sig------> (covers an add sent before step 1)
+// 4.         <-------rev-------
+// 5.         --------rev------>
+// 6.         --------add------>
+// 7.         - - - - sig - - ->
+// This represents an asynchronous commitment dance in which both sides are
+// sending signatures at the same time. In step 3, the signature does not
+// cover the recent settle/fail that Bob sent in step 1. However, the add that
+// Alice sends to Bob in step 6 does not overflow Bob's commitment transaction.
+// This is because validateCommitmentSanity counts the HTLC's by ignoring
+// HTLC's which will be removed in the next signature that Alice sends. Thus,
+// the add won't overflow. This is because the signature received in step 7
+// covers the settle/fail in step 1 and makes space for the add in step 6.
+func TestMaxAsynchronousHtlcs(t *testing.T) {
+	t.Parallel()
+
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// One over the maximum number of HTLCs that either can accept.
+	const numHTLCs = 12
+
+	// Set the remote's required MaxAcceptedHtlcs. This means that Alice
+	// can only offer the remote up to numHTLCs HTLCs.
+	aliceChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+	bobChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+
+	// Similarly, set the remote config's MaxAcceptedHtlcs. This means
+	// that the remote will be aware that Bob will only accept up to
+	// numHTLCs at a time.
+	aliceChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+	bobChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+
+	// Each HTLC amount is 0.1 BTC.
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+
+	var htlcID uint64
+
+	// Send the maximum allowed number of HTLCs minus one.
+	for i := 0; i < numHTLCs-1; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
+
+		// Just assign htlcID to the last received HTLC.
+		htlcID = htlc.ID
+	}
+
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to transition state: %v", err)
+	}
+
+	// Send an HTLC to Bob so that Bob's commitment transaction is full.
+	htlc, _ := createHTLC(numHTLCs-1, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Fail back an HTLC and sign a commitment as in steps 1 & 2.
+	err = bobChannel.FailHTLC(htlcID, []byte{}, nil, nil, nil)
+	require.NoError(t, err, "unable to fail htlc")
+
+	if err := aliceChannel.ReceiveFailHTLC(htlcID, []byte{}); err != nil {
+		t.Fatalf("unable to receive fail htlc: %v", err)
+	}
+
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign next commitment")
+
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive new commitment")
+
+	// Cover the HTLC referenced with id equal to numHTLCs-1 with a new
+	// signature (step 3).
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign next commitment")
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive new commitment")
+
+	// Both sides exchange revocations as in step 4 & 5.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke revocation")
+
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke revocation")
+
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	// Send the final Add which should succeed as in step 6.
+	htlc, _ = createHTLC(numHTLCs, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Receiving the commitment should succeed as in step 7 since space was
+	// made.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign next commitment")
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive new commitment")
+}
+
+// TestMaxCommitWeight tests that HTLCs pushing the weight of a commitment
+// transaction beyond the configured maximum are rejected with
+// ErrMaxWeightCost, while a channel whose commitments exceed a lowered maximum
+// can still be loaded and sign new commitments.
+func TestMaxCommitWeight(t *testing.T) {
+	t.Parallel()
+
+	chanType := channeldb.SingleFunderTweaklessBit
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Restart Alice with a maximum weight that leaves room for exactly two
+	// HTLC outputs on the commitment.
+	const numHTLCs = 2
+	maxWeight := CommitWeight(chanType) + input.HTLCWeight*numHTLCs
+	aliceChannel, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithMaxCommitWeight(maxWeight),
+	)
+	require.NoError(t, err)
+
+	// Bob adds HTLCs up to the weight boundary, which Alice accepts, and
+	// locks them in.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20000)
+	for i := 0; i < numHTLCs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		_, err := bobChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = aliceChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
+
+	// Another HTLC from Bob would push the commitment beyond the maximum
+	// weight, so Alice should reject it.
+	htlc, _ := createHTLC(numHTLCs, htlcAmt)
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.ErrorIs(t, err, ErrMaxWeightCost)
+
+	// Alice shouldn't be able to add an HTLC of her own either.
+	htlc, _ = createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrMaxWeightCost)
+
+	// Bob drops his rejected HTLC by restarting, which leaves both
+	// parties with the locked in HTLCs only. If Alice's maximum is
+	// lowered now, she can still be restarted and exchange commitments
+	// carrying them, as only new HTLCs are checked against it.
+	bobChannel, err = NewLightningChannel(
+		bobChannel.Signer, bobChannel.channelState, bobChannel.sigPool,
+	)
+	require.NoError(t, err)
+	aliceChannel, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithMaxCommitWeight(maxWeight-1),
+	)
+	require.NoError(t, err)
+
+	newFeeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	) * 2
+	require.NoError(t, aliceChannel.UpdateFee(newFeeRate))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(newFeeRate))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	require.Len(
+		t, aliceChannel.channelState.LocalCommitment.Htlcs, numHTLCs,
+	)
+
+	// New HTLCs are still rejected though.
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrMaxWeightCost)
+}
+
+// TestReceiveHTLCMinCLTVDelta tests that HTLCs received through
+// ReceiveHTLCAtHeight are rejected if they expire within less than the
+// configured minimum CLTV delta from the current height.
+func TestReceiveHTLCMinCLTVDelta(t *testing.T) {
+	t.Parallel()
+
+	chanType := channeldb.SingleFunderTweaklessBit
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	const (
+		minDelta      = 40
+		currentHeight = 1000
+	)
+	aliceChannel, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithMinCLTVDelta(minDelta),
+	)
+	require.NoError(t, err)
+
+	// An HTLC expiring one block before the minimum delta is reached
+	// must be rejected with the expiry_too_soon failure code.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(10_000))
+	htlc.Expiry = currentHeight + minDelta - 1
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+
+	_, err = aliceChannel.ReceiveHTLCAtHeight(htlc, currentHeight)
+	var tooSoonErr ErrHtlcExpiryTooSoon
+	require.ErrorAs(t, err, &tooSoonErr)
+	require.Equal(t, lnwire.CodeExpiryTooSoon, tooSoonErr.FailCode())
+
+	// The plain ReceiveHTLC doesn't know the current height, so it
+	// doesn't apply the check.
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	// An HTLC expiring exactly at the minimum delta is accepted.
+	htlc, _ = createHTLC(1, lnwire.NewMSatFromSatoshis(10_000))
+	htlc.Expiry = currentHeight + minDelta
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLCAtHeight(htlc, currentHeight)
+	require.NoError(t, err)
+}
+
+// TestMaxDustExposure tests that dust HTLCs are accepted until the configured
+// maximum dust exposure is reached, after which further dust HTLCs offered by
+// either party are rejected with ErrDustExposureExceeded.
+func TestMaxDustExposure(t *testing.T) {
+	t.Parallel()
+
+	chanType := channeldb.SingleFunderTweaklessBit
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Restart Alice with a maximum dust exposure that leaves room for
+	// exactly three dust HTLCs. An HTLC of 100 sat is below the dust limit
+	// of both parties.
+	const numHTLCs = 3
+	dustAmt := lnwire.NewMSatFromSatoshis(100)
+	aliceChannel, err = NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool, WithMaxDustExposure(dustAmt*numHTLCs),
+	)
+	require.NoError(t, err)
+
+	// Bob fills up Alice's dust exposure to the limit.
+	for i := 0; i < numHTLCs; i++ {
+		htlc, _ := createHTLC(i, dustAmt)
+		_, err := bobChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = aliceChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+	require.Equal(t, dustAmt*numHTLCs, aliceChannel.GetDustSum(false))
+	require.Equal(t, dustAmt*numHTLCs, aliceChannel.GetDustSum(true))
+
+	// Alice can't add a dust HTLC of her own anymore, but an HTLC that
+	// isn't dust doesn't add to the exposure.
+	htlc, _ := createHTLC(0, dustAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrDustExposureExceeded)
+	require.ErrorIs(
+		t, aliceChannel.CanAddHTLC(dustAmt), ErrDustExposureExceeded,
+	)
+
+	htlc, _ = createHTLC(0, lnwire.NewMSatFromSatoshis(50_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	// Another dust HTLC from Bob should be rejected by Alice.
+	htlc, _ = createHTLC(numHTLCs, dustAmt)
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.ErrorIs(t, err, ErrDustExposureExceeded)
+}
+
+// TestMaxPendingAmount tests that the maximum overall pending HTLC value is met
+// given several HTLCs that, combined, exceed this value. An ErrMaxPendingAmount
+// error should be returned.
+func TestMaxPendingAmount(t *testing.T) {
+	t.Parallel()
+
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We set the remote required MaxPendingAmount to 3 BTC. We will
+	// attempt to overflow this value and see if it gives us the
+	// ErrMaxPendingAmount error.
+	maxPending := lnwire.NewMSatFromSatoshis(btcutil.SatoshiPerBitcoin * 3)
+
+	// We set the max pending amount of Alice's config. This mean that she
+	// cannot offer Bob HTLCs with a total value above this limit at a given
+	// time.
+	aliceChannel.channelState.LocalChanCfg.MaxPendingAmount = maxPending
+	bobChannel.channelState.RemoteChanCfg.MaxPendingAmount = maxPending
+
+	// First, we'll add 2 HTLCs of 1.5 BTC each to Alice's commitment.
+	// This won't trigger Alice's ErrMaxPendingAmount error.
+	const numHTLCs = 2
+	htlcAmt := lnwire.NewMSatFromSatoshis(1.5 * btcutil.SatoshiPerBitcoin)
+	for i := 0; i < numHTLCs; i++ {
+		htlc, _ := createHTLC(i, htlcAmt)
+		if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+			t.Fatalf("unable to add htlc: %v", err)
+		}
+		if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+			t.Fatalf("unable to recv htlc: %v", err)
+		}
+	}
+
+	// We finally add one more HTLC of 0.1 BTC to Alice's commitment. This
+	// SHOULD trigger Alice's ErrMaxPendingAmount error.
+	htlcAmt = lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(numHTLCs, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	if err != ErrMaxPendingAmount {
+		t.Fatalf("expected ErrMaxPendingAmount, instead received: %v", err)
+	}
+
+	// And also Bob shouldn't be accepting this HTLC upon calling ReceiveHTLC.
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != ErrMaxPendingAmount {
+		t.Fatalf("expected ErrMaxPendingAmount, instead received: %v", err)
+	}
+}
+
+func assertChannelBalances(t *testing.T, alice, bob *LightningChannel,
+	aliceBalance, bobBalance btcutil.Amount) {
+
+	_, _, line, _ := runtime.Caller(1)
+
+	aliceSelfBalance := alice.channelState.LocalCommitment.LocalBalance.ToSatoshis()
+	aliceBobBalance := alice.channelState.LocalCommitment.RemoteBalance.ToSatoshis()
+	if aliceSelfBalance != aliceBalance {
+		t.Fatalf("line #%v: wrong alice self balance: expected %v, got %v",
+			line, aliceBalance, aliceSelfBalance)
+	}
+	if aliceBobBalance != bobBalance {
+		t.Fatalf("line #%v: wrong alice bob's balance: expected %v, got %v",
+			line, bobBalance, aliceBobBalance)
+	}
+
+	bobSelfBalance := bob.channelState.LocalCommitment.LocalBalance.ToSatoshis()
+	bobAliceBalance := bob.channelState.LocalCommitment.RemoteBalance.ToSatoshis()
+	if bobSelfBalance != bobBalance {
+		t.Fatalf("line #%v: wrong bob self balance: expected %v, got %v",
+			line, bobBalance, bobSelfBalance)
+	}
+	if bobAliceBalance != aliceBalance {
+		t.Fatalf("line #%v: wrong alice bob's balance: expected %v, got %v",
+			line, aliceBalance, bobAliceBalance)
+	}
+}
+
+// TestChanReserve tests that the ErrBelowChanReserve error is thrown when an
+// HTLC is added that causes a node's balance to dip below its channel reserve
+// limit.
+func TestChanReserve(t *testing.T) {
+	t.Parallel()
+
+	setupChannels := func() (*LightningChannel, *LightningChannel) {
+		// We'll kick off the test by creating our channels which both
+		// are loaded with 5 BTC each.
+		aliceChannel, bobChannel, err := CreateTestChannels(
+			t, channeldb.SingleFunderTweaklessBit,
+		)
+		if err != nil {
+			t.Fatalf("unable to create test channels: %v", err)
+		}
+
+		// We set the remote required ChanReserve to 0.5 BTC. We will
+		// attempt to cause Alice's balance to dip below this amount
+		// and test whether it triggers the ErrBelowChanReserve error.
+		aliceMinReserve := btcutil.Amount(0.5 *
+			btcutil.SatoshiPerBitcoin)
+
+		// Alice will need to keep her reserve above aliceMinReserve,
+		// so set this limit to here local config.
+		aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
+
+		// During channel opening Bob will also get to know Alice's
+		// minimum reserve, and this will be found in his remote
+		// config.
+		bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
+
+		// We set Bob's channel reserve to a value that is larger than
+		// his current balance in the channel. This will ensure that
+		// after a channel is first opened, Bob can still receive HTLCs
+		// even though his balance is less than his channel reserve.
+		bobMinReserve := btcutil.Amount(6 * btcutil.SatoshiPerBitcoin)
+		bobChannel.channelState.LocalChanCfg.ChanReserve = bobMinReserve
+		aliceChannel.channelState.RemoteChanCfg.ChanReserve = bobMinReserve
+
+		return aliceChannel, bobChannel
+	}
+	aliceChannel, bobChannel := setupChannels()
+
+	aliceIndex := 0
+	bobIndex := 0
+
+	// Add an HTLC that will increase Bob's balance. This should succeed,
+	// since Alice stays above her channel reserve, and Bob increases his
+	// balance (while still being below his channel reserve).
+	//
+	// Resulting balances:
+	//	Alice:	4.5
+	//	Bob:	5.0
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.5 * btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(aliceIndex, htlcAmt)
+	aliceIndex++
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Force a state transition, making sure this HTLC is considered valid
+	// even though the channel reserves are not met.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
+	assertChannelBalances(
+		t, aliceChannel, bobChannel,
+		btcutil.SatoshiPerBitcoin*4.5-commitFee, btcutil.SatoshiPerBitcoin*5,
+	)
+
+	// Now let Bob try to add an HTLC. This should fail, since it will
+	// decrease his balance, which is already below the channel reserve.
+	//
+	// Resulting balances:
+	//	Alice:	4.5
+	//	Bob:	5.0
+	htlc, _ = createHTLC(bobIndex, htlcAmt)
+	bobIndex++
+	_, err := bobChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// Alice will reject this htlc upon receiving the htlc.
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// We must setup the channels again, since a violation of the channel
+	// constraints leads to channel shutdown.
+	aliceChannel, bobChannel = setupChannels()
+
+	aliceIndex = 0
+	bobIndex = 0
+
+	// Now we'll add HTLC of 3.5 BTC to Alice's commitment, this should put
+	// Alice's balance at 1.5 BTC.
+	//
+	// Resulting balances:
+	//	Alice:	1.5
+	//	Bob:	9.5
+	htlcAmt = lnwire.NewMSatFromSatoshis(3.5 * btcutil.SatoshiPerBitcoin)
+
+	// The first HTLC should successfully be sent.
+	htlc, _ = createHTLC(aliceIndex, htlcAmt)
+	aliceIndex++
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Add a second HTLC of 1 BTC. This should fail because it will take
+	// Alice's balance all the way down to her channel reserve, but since
+	// she is the initiator the additional transaction fee makes her
+	// balance dip below.
+	htlcAmt = lnwire.NewMSatFromSatoshis(1 * btcutil.SatoshiPerBitcoin)
+	htlc, _ = createHTLC(aliceIndex, htlcAmt)
+	aliceIndex++
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// Likewise, Bob will reject receiving the htlc because of the same reason.
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// We must setup the channels again, since a violation of the channel
+	// constraints leads to channel shutdown.
+	aliceChannel, bobChannel = setupChannels()
+
+	aliceIndex = 0
+	bobIndex = 0
+
+	// Add a HTLC of 2 BTC to Alice, and the settle it.
+	// Resulting balances:
+	//	Alice:	3.0
+	//	Bob:	7.0
+	htlcAmt = lnwire.NewMSatFromSatoshis(2 * btcutil.SatoshiPerBitcoin)
+	htlc, preimage := createHTLC(aliceIndex, htlcAmt)
+	aliceIndex++
+	aliceHtlcIndex, err := aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "unable to add htlc")
+	bobHtlcIndex, err := bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "unable to recv htlc")
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	commitFee = aliceChannel.channelState.LocalCommitment.CommitFee
+	assertChannelBalances(
+		t, aliceChannel, bobChannel,
+		btcutil.SatoshiPerBitcoin*3-commitFee, btcutil.SatoshiPerBitcoin*5,
+	)
+
+	if err := bobChannel.SettleHTLC(preimage, bobHtlcIndex, nil, nil, nil); err != nil {
+		t.Fatalf("bob unable to settle inbound htlc: %v", err)
+	}
+	if err := aliceChannel.ReceiveHTLCSettle(preimage, aliceHtlcIndex); err != nil {
+		t.Fatalf("alice unable to accept settle of outbound htlc: %v", err)
+	}
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	commitFee = aliceChannel.channelState.LocalCommitment.CommitFee
+	assertChannelBalances(
+		t, aliceChannel, bobChannel,
+		btcutil.SatoshiPerBitcoin*3-commitFee, btcutil.SatoshiPerBitcoin*7,
+	)
+
+	// And now let Bob add an HTLC of 1 BTC. This will take Bob's balance
+	// all the way down to his channel reserve, but since he is not paying
+	// the fee this is okay.
+	htlcAmt = lnwire.NewMSatFromSatoshis(1 * btcutil.SatoshiPerBitcoin)
+	htlc, _ = createHTLC(bobIndex, htlcAmt)
+	bobIndex++
+	if _, err := bobChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := aliceChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// Do a last state transition, which should succeed.
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	commitFee = aliceChannel.channelState.LocalCommitment.CommitFee
+	assertChannelBalances(
+		t, aliceChannel, bobChannel,
+		btcutil.SatoshiPerBitcoin*3-commitFee, btcutil.SatoshiPerBitcoin*6,
+	)
+}
+
+// TestChanReserveRemoteInitiator tests that the channel reserve of the
+// initiator is accounted for when adding HTLCs, whether the initiator is the
+// local or remote node.
+func TestChanReserveRemoteInitiator(t *testing.T) {
+	t.Parallel()
+
+	// We start out with a channel where both parties have 5 BTC.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set Alice's channel reserve to be 5 BTC-commitfee. This means she
+	// has just enough balance to cover the comitment fee, but not enough
+	// to add any more HTLCs to the commitment. Although a reserve this
+	// high is unrealistic, a channel can easily get into a situation
+	// where the initiator cannot pay for the fee of any more HTLCs.
+	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
+	aliceMinReserve := 5*btcutil.SatoshiPerBitcoin - commitFee
+
+	aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
+	bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
+
+	// Now let Bob attempt to add an HTLC of 0.1 BTC. He has plenty of
+	// money available to spend, but Alice, which is the initiator, cannot
+	// afford any more HTLCs on the commitment transaction because that
+	// would take here below her channel reserve..
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(0, htlcAmt)
+
+	// Bob should refuse to add this HTLC, since he realizes it will create
+	// an invalid commitment.
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// Of course Alice will also not have enough balance to add it herself.
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// Same for Alice, she should refuse to accept this second HTLC.
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+}
+
+// TestChanReserveLocalInitiatorDustHtlc tests that fee the initiator must pay
+// when adding HTLCs is accounted for, even though the HTLC is considered dust
+// by the remote bode.
+func TestChanReserveLocalInitiatorDustHtlc(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The amount of the HTLC should not be considered dust according to
+	// Alice's dust limit (200 sat), but be dust according to Bob's dust
+	// limit (1300 sat). It is considered dust if the amount remaining
+	// after paying the HTLC fee is below the dustlimit, so we choose a
+	// size of 500+htlcFee.
+	htlcSat := btcutil.Amount(500) + HtlcTimeoutFee(
+		aliceChannel.channelState.ChanType,
+		chainfee.SatPerKWeight(
+			aliceChannel.channelState.LocalCommitment.FeePerKw,
+		),
+	)
+
+	// Set Alice's channel reserve to be low enough to carry the value of
+	// the HTLC, but not low enough to allow the extra fee from adding the
+	// HTLC to the commitment.
+	commitFee := aliceChannel.channelState.LocalCommitment.CommitFee
+	aliceMinReserve := 5*btcutil.SatoshiPerBitcoin - commitFee - htlcSat
+
+	aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
+	bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
+
+	htlcDustAmt := lnwire.NewMSatFromSatoshis(htlcSat)
+	htlc, _ := createHTLC(0, htlcDustAmt)
+
+	// Alice should realize that the fee she must pay to add this HTLC to
+	// the local commitment would take her below the channel reserve.
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+}
+
+// TestChanReserveRemoteSymmetry tests that the reserve we require the remote
+// party to keep is enforced for both their HTLCs and their fee updates, while
+// updates that don't decrease their balance are still accepted.
+func TestChanReserveRemoteSymmetry(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Require Bob to keep 4.5 BTC on his side of the channel, leaving
+	// him with only 0.5 BTC above his reserve.
+	bobMinReserve := btcutil.Amount(4.5 * btcutil.SatoshiPerBitcoin)
+	bobChannel.channelState.LocalChanCfg.ChanReserve = bobMinReserve
+	aliceChannel.channelState.RemoteChanCfg.ChanReserve = bobMinReserve
+	require.Equal(t, bobMinReserve, aliceChannel.RemoteReserve())
+
+	// Bob adds an HTLC of 0.4 BTC, which keeps him above his reserve, so
+	// Alice should accept it.
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.4 * btcutil.SatoshiPerBitcoin)
+	htlc, preimage := createHTLC(0, htlcAmt)
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "unable to add htlc")
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "unable to recv htlc")
+
+	// A second HTLC of 0.2 BTC would take Bob below his reserve, which
+	// Alice must refuse.
+	htlcAmt = lnwire.NewMSatFromSatoshis(0.2 * btcutil.SatoshiPerBitcoin)
+	htlc2, _ := createHTLC(1, htlcAmt)
+	_, err = aliceChannel.ReceiveHTLC(htlc2)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// Lock in the first HTLC. Alice then settles it, which moves funds
+	// away from Bob's HTLC output but leaves his main output untouched,
+	// so the new state must be accepted even though Bob is now close to
+	// his reserve.
+	err = ForceStateTransition(bobChannel, aliceChannel)
+	require.NoError(t, err, "unable to complete state update")
+
+	err = aliceChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = bobChannel.ReceiveHTLCSettle(preimage, 0)
+	require.NoError(t, err, "unable to recv settle")
+
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err, "unable to complete state update")
+
+	// Now put Alice, the initiator, right above her reserve. A fee update
+	// from her that only marginally increases the fee is accepted, but
+	// one that forces her to pay a fee that takes her below her reserve
+	// must be rejected by Bob.
+	aliceBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
+	aliceMinReserve := aliceBalance.ToSatoshis() - 1000
+	aliceChannel.channelState.LocalChanCfg.ChanReserve = aliceMinReserve
+	bobChannel.channelState.RemoteChanCfg.ChanReserve = aliceMinReserve
+	require.Equal(t, aliceMinReserve, bobChannel.RemoteReserve())
+
+	feePerKw := chainfee.SatPerKWeight(
+		bobChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	require.NoError(t, bobChannel.ReceiveUpdateFee(feePerKw+1))
+
+	err = bobChannel.ReceiveUpdateFee(feePerKw * 10)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+}
+
+// TestHtlcIsDustWeightProfiles asserts that the dust boundary of HTLCs depends
+// on the second-level transaction weights of the channel type.
+func TestHtlcIsDustWeightProfiles(t *testing.T) {
+	t.Parallel()
+
+	const (
+		feePerKw  = chainfee.SatPerKWeight(1000)
+		dustLimit = btcutil.Amount(354)
+	)
+
+	legacy := channeldb.SingleFunderTweaklessBit
+	anchors := legacy | channeldb.AnchorOutputsBit
+	zeroFee := anchors | channeldb.ZeroHtlcTxFeeBit
+
+	// The HTLC outputs of anchor channels have a CSV 1 overhead, which
+	// makes their second-level transactions slightly heavier.
+	legacyWeights := HtlcTxWeightsForType(legacy)
+	anchorWeights := HtlcTxWeightsForType(anchors)
+	require.EqualValues(t, input.HtlcTimeoutWeight, legacyWeights.Timeout)
+	require.EqualValues(t, input.HtlcSuccessWeight, legacyWeights.Success)
+	require.Greater(t, anchorWeights.Timeout, legacyWeights.Timeout)
+	require.Greater(t, anchorWeights.Success, legacyWeights.Success)
+
+	testCases := []struct {
+		name      string
+		incoming  bool
+		ourCommit bool
+		weight    func(HtlcTxWeights) int64
+	}{
+		{
+			name:      "outgoing on our commitment",
+			incoming:  false,
+			ourCommit: true,
+			weight:    func(w HtlcTxWeights) int64 { return w.Timeout },
+		},
+		{
+			name:      "incoming on our commitment",
+			incoming:  true,
+			ourCommit: true,
+			weight:    func(w HtlcTxWeights) int64 { return w.Success },
+		},
+		{
+			name:      "outgoing on their commitment",
+			incoming:  false,
+			ourCommit: false,
+			weight:    func(w HtlcTxWeights) int64 { return w.Success },
+		},
+		{
+			name:      "incoming on their commitment",
+			incoming:  true,
+			ourCommit: false,
+			weight:    func(w HtlcTxWeights) int64 { return w.Timeout },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			isDust := func(chanType channeldb.ChannelType,
+				amt btcutil.Amount) bool {
+
+				return HtlcIsDust(
+					chanType, tc.incoming, tc.ourCommit,
+					feePerKw, amt, dustLimit,
+				)
+			}
+
+			// The smallest non-dust HTLC of the legacy profile
+			// doesn't cover the heavier anchor transaction.
+			legacyFee := feePerKw.FeeForWeight(
+				tc.weight(legacyWeights),
+			)
+			legacyMin := dustLimit + legacyFee
+			require.True(t, isDust(legacy, legacyMin-1))
+			require.False(t, isDust(legacy, legacyMin))
+			require.True(t, isDust(anchors, legacyMin))
+
+			anchorFee := feePerKw.FeeForWeight(
+				tc.weight(anchorWeights),
+			)
+			anchorMin := dustLimit + anchorFee
+			require.True(t, isDust(anchors, anchorMin-1))
+			require.False(t, isDust(anchors, anchorMin))
+
+			// With zero-fee HTLC transactions, the weights don't
+			// matter and only the dust limit counts.
+			require.True(t, isDust(zeroFee, dustLimit-1))
+			require.False(t, isDust(zeroFee, dustLimit))
+		})
+	}
+}
+
+// TestHtlcOnionBlobSize asserts that the onion blob of an HTLC is kept at its
+// fixed packet size throughout the update logs and persisted commitments, and
+// that a wire message carrying a truncated onion can't be decoded.
+func TestHtlcOnionBlobSize(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20000))
+	_, err = rand.Read(htlc.OnionBlob[:])
+	require.NoError(t, err)
+
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	aliceAdd := aliceChannel.localUpdateLog.lookupHtlc(0)
+	bobAdd := bobChannel.remoteUpdateLog.lookupHtlc(0)
+	require.Equal(t, htlc.OnionBlob[:], aliceAdd.OnionBlob)
+	require.Equal(t, htlc.OnionBlob[:], bobAdd.OnionBlob)
+
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+
+	// The onion is persisted along with the HTLC on the commitment.
+	bobHtlcs := bobChannel.channelState.LocalCommitment.Htlcs
+	require.Len(t, bobHtlcs, 1)
+	require.Equal(t, htlc.OnionBlob, bobHtlcs[0].OnionBlob)
+
+	// An add message with an undersized onion fails to decode, so such a
+	// blob can never make it into the update log.
+	var b bytes.Buffer
+	require.NoError(t, htlc.Encode(&b, 0))
+	truncated := b.Bytes()[:b.Len()-lnwire.OnionPacketSize/2]
+
+	var decoded lnwire.UpdateAddHTLC
+	err = decoded.Decode(bytes.NewReader(truncated), 0)
+	require.Error(t, err)
+}
+
+// TestMinHTLC tests that the ErrBelowMinHTLC error is thrown if an HTLC is added
+// that is below the minimm allowed value for HTLCs.
+func TestMinHTLC(t *testing.T) {
+	t.Parallel()
+
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We set Alice's MinHTLC to 0.1 BTC. We will attempt to send an
+	// HTLC BELOW this value to trigger the ErrBelowMinHTLC error.
+	minValue := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+
+	// Setting the min value in Alice's local config means that the
+	// remote will not accept any HTLCs of value less than specified.
+	aliceChannel.channelState.LocalChanCfg.MinHTLC = minValue
+	bobChannel.channelState.RemoteChanCfg.MinHTLC = minValue
+
+	// First, we will add an HTLC of 0.5 BTC. This will not trigger
+	// ErrBelowMinHTLC.
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.5 * btcutil.SatoshiPerBitcoin)
+	htlc, _ := createHTLC(0, htlcAmt)
+	if _, err := aliceChannel.AddHTLC(htlc, nil); err != nil {
+		t.Fatalf("unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlc); err != nil {
+		t.Fatalf("unable to recv htlc: %v", err)
+	}
+
+	// We add an HTLC below the min value, this should result in
+	// an ErrBelowMinHTLC error.
+	amt := minValue - 100
+	htlc, _ = createHTLC(1, amt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	if err != ErrBelowMinHTLC {
+		t.Fatalf("expected ErrBelowMinHTLC, instead received: %v", err)
+	}
+
+	// Bob will receive this HTLC, but reject the next received htlc, since
+	// the htlc is too small.
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	if err != ErrBelowMinHTLC {
+		t.Fatalf("expected ErrBelowMinHTLC, instead received: %v", err)
+	}
+}
+
+// TestInvalidHTLCAmt tests that ErrInvalidHTLCAmt is returned when trying to
+// add HTLCs that don't carry a positive value, for all channel types that
+// don't permit zero-value HTLCs.
+func TestInvalidHTLCAmt(t *testing.T) {
+	t.Parallel()
+
+	chanTypes := map[string]channeldb.ChannelType{
+		"tweakless": channeldb.SingleFunderTweaklessBit,
+		"anchors": channeldb.SingleFunderTweaklessBit |
+			channeldb.AnchorOutputsBit,
+		"taproot": channeldb.SimpleTaprootFeatureBit |
+			channeldb.AnchorOutputsBit |
+			channeldb.ZeroHtlcTxFeeBit |
+			channeldb.SingleFunderTweaklessBit,
+	}
+	for name, chanType := range chanTypes {
+		chanType := chanType
+
+		t.Run(name, func(t *testing.T) {
+			require.False(t, allowsZeroValueHtlcs(chanType))
+			testInvalidHTLCAmt(t, chanType)
+		})
+	}
+}
+
+func testInvalidHTLCAmt(t *testing.T, chanType channeldb.ChannelType) {
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We'll set the min HTLC values for each party to zero, which
+	// technically would permit zero-value HTLCs.
+	aliceChannel.channelState.LocalChanCfg.MinHTLC = 0
+	bobChannel.channelState.RemoteChanCfg.MinHTLC = 0
+
+	// Create a zero-value HTLC.
+	htlcAmt := lnwire.MilliSatoshi(0)
+	htlc, _ := createHTLC(0, htlcAmt)
+
+	// Sending or receiving the HTLC should fail with ErrInvalidHTLCAmt.
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	if err != ErrInvalidHTLCAmt {
+		t.Fatalf("expected ErrInvalidHTLCAmt, got: %v", err)
+	}
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	if err != ErrInvalidHTLCAmt {
+		t.Fatalf("expected ErrInvalidHTLCAmt, got: %v", err)
+	}
+}
+
+// TestNewBreachRetributionSkipsDustHtlcs ensures that in the case of a
+// contract breach, all dust HTLCs are ignored and not reflected in the
+// produced BreachRetribution struct. We ignore these HTLCs as they aren't
+// actually manifested on the commitment transaction, as a result we can't
+// actually revoked them.
+func TestNewBreachRetributionSkipsDustHtlcs(t *testing.T) {
+	t.Parallel()
+
+	// We'll kick off the test by creating our channels which both are
+	// loaded with 5 BTC each.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	var fakeOnionBlob [lnwire.OnionPacketSize]byte
+	copy(fakeOnionBlob[:], bytes.Repeat([]byte{0x05}, lnwire.OnionPacketSize))
+
+	// We'll modify the dust settings on both channels to be a predictable
+	// value for the prurpose of the test.
+	dustValue := btcutil.Amount(200)
+	aliceChannel.channelState.LocalChanCfg.DustLimit = dustValue
+	aliceChannel.channelState.RemoteChanCfg.DustLimit = dustValue
+	bobChannel.channelState.LocalChanCfg.DustLimit = dustValue
+	bobChannel.channelState.RemoteChanCfg.DustLimit = dustValue
+
+	// We'll now create a series of dust HTLC's, and send then from Alice
+	// to Bob, finally locking both of them in.
+	var bobPreimage [32]byte
+	copy(bobPreimage[:], bytes.Repeat([]byte{0xbb}, 32))
+	for i := 0; i < 3; i++ {
+		rHash := sha256.Sum256(bobPreimage[:])
+		h := &lnwire.UpdateAddHTLC{
+			PaymentHash: rHash,
+			Amount:      lnwire.NewMSatFromSatoshis(dustValue),
+			Expiry:      uint32(10),
+			OnionBlob:   fakeOnionBlob,
+		}
+
+		htlcIndex, err := aliceChannel.AddHTLC(h, nil)
+		if err != nil {
+			t.Fatalf("unable to add bob's htlc: %v", err)
+		}
+
+		h.ID = htlcIndex
+		if _, err := bobChannel.ReceiveHTLC(h); err != nil {
+			t.Fatalf("unable to recv bob's htlc: %v", err)
+		}
+	}
+
+	// With the HTLC's applied to both update logs, we'll initiate a state
+	// transition from Alice.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete alice's state transition: %v", err)
+	}
+
+	// At this point, we'll capture the current state number, as well as
+	// the current commitment.
+	revokedStateNum := aliceChannel.channelState.LocalCommitment.CommitHeight
+
+	// We'll now have Bob settle those HTLC's to Alice and then advance
+	// forward to a new state.
+	for i := 0; i < 3; i++ {
+		err := bobChannel.SettleHTLC(bobPreimage, uint64(i), nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+		err = aliceChannel.ReceiveHTLCSettle(bobPreimage, uint64(i))
+		if err != nil {
+			t.Fatalf("unable to settle htlc: %v", err)
+		}
+	}
+	if err := ForceStateTransition(bobChannel, aliceChannel); err != nil {
+		t.Fatalf("unable to complete bob's state transition: %v", err)
+	}
+
+	// At this point, we'll now simulate a contract breach by Bob using the
+	// NewBreachRetribution method.
+	breachTx := aliceChannel.channelState.RemoteCommitment.CommitTx
+	breachRet, err := NewBreachRetribution(
+		aliceChannel.channelState, revokedStateNum, 100, breachTx,
+	)
+	require.NoError(t, err, "unable to create breach retribution")
+
+	// The retribution shouldn't have any HTLCs set as they were all below
+	// dust for both parties.
+	if len(breachRet.HtlcRetributions) != 0 {
+		t.Fatalf("zero HTLC retributions should have been created, "+
+			"instead %v were", len(breachRet.HtlcRetributions))
+	}
+}
+
+// compareHtlcs compares two PaymentDescriptors.
+func compareHtlcs(htlc1, htlc2 *PaymentDescriptor) error {
+	if htlc1.LogIndex != htlc2.LogIndex {
+		return fmt.Errorf("htlc log index did not match")
+	}
+	if htlc1.HtlcIndex != htlc2.HtlcIndex {
+		return fmt.Errorf("htlc index did not match")
+	}
+	if htlc1.ParentIndex != htlc2.ParentIndex {
+		return fmt.Errorf("htlc parent index did not match")
+	}
+
+	if htlc1.RHash != htlc2.RHash {
+		return fmt.Errorf("htlc rhash did not match")
+	}
+	return nil
+}
+
+// compareIndexes is a helper method to compare two index maps.
+func compareIndexes(a, b map[uint64]*list.Element) error {
+	for k1, e1 := range a {
+		e2, ok := b[k1]
+		if !ok {
+			return fmt.Errorf("element with key %d "+
+				"not found in b", k1)
+		}
+		htlc1, htlc2 := e1.Value.(*PaymentDescriptor), e2.Value.(*PaymentDescriptor)
+		if err := compareHtlcs(htlc1, htlc2); err != nil {
+			return err
+		}
+	}
+
+	for k1, e1 := range b {
+		e2, ok := a[k1]
+		if !ok {
+			return fmt.Errorf("element with key %d not "+
+				"found in a", k1)
+		}
+		htlc1, htlc2 := e1.Value.(*PaymentDescriptor), e2.Value.(*PaymentDescriptor)
+		if err := compareHtlcs(htlc1, htlc2); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compareLogs is a helper method to compare two updateLogs.
+func compareLogs(a, b *updateLog) error {
+	if a.logIndex != b.logIndex {
+		return fmt.Errorf("log indexes don't match: %d vs %d",
+			a.logIndex, b.logIndex)
+	}
+
+	if a.htlcCounter != b.htlcCounter {
+		return fmt.Errorf("htlc counters don't match: %d vs %d",
+			a.htlcCounter, b.htlcCounter)
+	}
+
+	if err := compareIndexes(a.updateIndex, b.updateIndex); err != nil {
+		return fmt.Errorf("update indexes don't match: %v", err)
+	}
+	if err := compareIndexes(a.htlcIndex, b.htlcIndex); err != nil {
+		return fmt.Errorf("htlc indexes don't match: %v", err)
+	}
+
+	if a.Len() != b.Len() {
+		return fmt.Errorf("list lengths not equal: %d vs %d",
+			a.Len(), b.Len())
+	}
+
+	e1, e2 := a.Front(), b.Front()
+	for ; e1 != nil; e1, e2 = e1.Next(), e2.Next() {
+		htlc1, htlc2 := e1.Value.(*PaymentDescriptor), e2.Value.(*PaymentDescriptor)
+		if err := compareHtlcs(htlc1, htlc2); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TestCommitDiffLogUpdatesSorted asserts that the log updates of a persisted
+// commit diff are sorted by their log index, even if the entries of the local
+// update log are out of order.
+func TestCommitDiffLogUpdatesSorted(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(10_000))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+	require.NoError(t, aliceChannel.UpdateFee(
+		chainfee.SatPerKWeight(
+			aliceChannel.channelState.LocalCommitment.FeePerKw,
+		)*2,
+	))
+
+	// Shuffle the update log, such that it's no longer ordered by log
+	// index.
+	updates := aliceChannel.localUpdateLog
+	updates.MoveToFront(updates.Back())
+	updates.MoveAfter(updates.Front().Next(), updates.Back())
+
+	_, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+
+	commitDiff, err := aliceChannel.channelState.RemoteCommitChainTip()
+	require.NoError(t, err)
+	require.Len(t, commitDiff.LogUpdates, 4)
+	for i, logUpdate := range commitDiff.LogUpdates {
+		require.EqualValues(t, i, logUpdate.LogIndex)
+	}
+}
+
+// TestChannelRestoreUpdateLogs makes sure we are able to properly restore the
+// update logs in the case where a different number of HTLCs are locked in on
+// the local, remote and pending remote commitment.
+func TestChannelRestoreUpdateLogs(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First, we'll add an HTLC from Alice to Bob, which we will lock in on
+	// Bob's commit, but not on Alice's.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
+
+	// Let Alice sign a new state, which will include the HTLC just sent.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// Bob receives this commitment signature, and revokes his old state.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+
+	// When Alice now receives this revocation, she will advance her remote
+	// commitment chain to the commitment which includes the HTLC just
+	// sent. However her local commitment chain still won't include the
+	// state with the HTLC, since she hasn't received a new commitment
+	// signature from Bob yet.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	// Now make Alice send and sign an additional HTLC. We don't let Bob
+	// receive it. We do this since we want to check that update logs are
+	// restored properly below, and we'll only restore updates that have
+	// been ACKed.
+	htlcAlice, _ = createHTLC(1, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+
+	// Send the signature covering the HTLC. This is okay, since the local
+	// and remote commit chains are updated in an async fashion. Since the
+	// remote chain was updated with the latest state (since Bob sent the
+	// revocation earlier) we can keep advancing the remote commit chain.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// After Alice has signed this commitment, her local commitment will
+	// contain no HTLCs, her remote commitment will contain an HTLC with
+	// index 0, and the pending remote commitment (a signed remote
+	// commitment which is not AKCed yet) will contain an additional HTLC
+	// with index 1.
+
+	// We now re-create the channels, mimicking a restart. This should sync
+	// the update logs up to the correct state set up above.
+	newAliceChannel, err := NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool,
+	)
+	require.NoError(t, err, "unable to create new channel")
+
+	newBobChannel, err := NewLightningChannel(
+		bobChannel.Signer, bobChannel.channelState,
+		bobChannel.sigPool,
+	)
+	require.NoError(t, err, "unable to create new channel")
+
+	// compare all the logs between the old and new channels, to make sure
+	// they all got restored properly.
+	err = compareLogs(aliceChannel.localUpdateLog,
+		newAliceChannel.localUpdateLog)
+	require.NoError(t, err, "alice local log not restored")
+
+	err = compareLogs(aliceChannel.remoteUpdateLog,
+		newAliceChannel.remoteUpdateLog)
+	require.NoError(t, err, "alice remote log not restored")
+
+	err = compareLogs(bobChannel.localUpdateLog,
+		newBobChannel.localUpdateLog)
+	require.NoError(t, err, "bob local log not restored")
+
+	err = compareLogs(bobChannel.remoteUpdateLog,
+		newBobChannel.remoteUpdateLog)
+	require.NoError(t, err, "bob remote log not restored")
+}
+
+// fetchNumUpdates counts the number of updateType in the log.
+func fetchNumUpdates(t updateType, log *updateLog) int {
+	num := 0
+	for e := log.Front(); e != nil; e = e.Next() {
+		htlc := e.Value.(*PaymentDescriptor)
+		if htlc.EntryType == t {
+			num++
+		}
+	}
+	return num
+}
+
+// assertInLog checks that the given log contains the expected number of Adds
+// and Fails.
+func assertInLog(t *testing.T, log *updateLog, numAdds, numFails int) {
+	adds := fetchNumUpdates(Add, log)
+	if adds != numAdds {
+		t.Fatalf("expected %d adds, found %d", numAdds, adds)
+	}
+	fails := fetchNumUpdates(Fail, log)
+	if fails != numFails {
+		t.Fatalf("expected %d fails, found %d", numFails, fails)
+	}
+}
+
+// assertInLogs asserts that the expected number of Adds and Fails occurs in
+// the local and remote update log of the given channel.
+func assertInLogs(t *testing.T, channel *LightningChannel, numAddsLocal,
+	numFailsLocal, numAddsRemote, numFailsRemote int) {
+	assertInLog(t, channel.localUpdateLog, numAddsLocal, numFailsLocal)
+	assertInLog(t, channel.remoteUpdateLog, numAddsRemote, numFailsRemote)
+}
+
+// restoreAndAssert creates a new LightningChannel from the given channel's
+// state, and asserts that the new channel has had its logs restored to the
+// expected state.
+func restoreAndAssert(t *testing.T, channel *LightningChannel, numAddsLocal,
+	numFailsLocal, numAddsRemote, numFailsRemote int) {
+
+	newChannel, err := NewLightningChannel(
+		channel.Signer, channel.channelState,
+		channel.sigPool,
+	)
+	require.NoError(t, err, "unable to create new channel")
+
+	assertInLog(t, newChannel.localUpdateLog, numAddsLocal, numFailsLocal)
+	assertInLog(t, newChannel.remoteUpdateLog, numAddsRemote, numFailsRemote)
+}
+
+// TestChannelRestoreUpdateLogsFailedHTLC runs through a scenario where an
+// HTLC is added and failed, and asserts along the way that we would restore
+// the update logs of the channel to the expected state at any point.
+func TestChannelRestoreUpdateLogsFailedHTLC(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First, we'll add an HTLC from Alice to Bob, and lock it in for both.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	// The htlc Alice sent should be in her local update log.
+	assertInLogs(t, aliceChannel, 1, 0, 0, 0)
+
+	// A restore at this point should NOT restore this update, as it is not
+	// locked in anywhere yet.
+	restoreAndAssert(t, aliceChannel, 0, 0, 0, 0)
+
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
+
+	// Lock in the Add on both sides.
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	// Since it is locked in, Alice should have the Add in the local log,
+	// and it should be restored during restoration.
+	assertInLogs(t, aliceChannel, 1, 0, 0, 0)
+	restoreAndAssert(t, aliceChannel, 1, 0, 0, 0)
+
+	// Now we make Bob fail this HTLC.
+	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+
+	err = aliceChannel.ReceiveFailHTLC(0, []byte("failreason"))
+	require.NoError(t, err, "unable to recv htlc cancel")
+
+	// This Fail update should have been added to Alice's remote update log.
+	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
+
+	// Restoring should restore the HTLC added to Alice's local log, but
+	// NOT the Fail sent by Bob, since it is not locked in.
+	restoreAndAssert(t, aliceChannel, 1, 0, 0, 0)
+
+	// Bob sends a signature.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+
+	// When Alice receives Bob's new commitment, the logs will stay the
+	// same until she revokes her old state. The Fail will still not be
+	// restored during a restoration.
+	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
+	restoreAndAssert(t, aliceChannel, 1, 0, 0, 0)
+
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "bob unable to process alice's revocation")
+
+	// At this point Alice has advanced her local commitment chain to a
+	// commitment with no HTLCs left. The current state on her remote
+	// commitment chain, however, still has the HTLC active, as she hasn't
+	// sent a new signature yet. If we'd now restart and restore, the htlc
+	// failure update should still be waiting for inclusion in Alice's next
+	// signature. Otherwise the produced signature would be invalid.
+	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
+	restoreAndAssert(t, aliceChannel, 1, 0, 0, 1)
+
+	// Now send a signature from Alice. This will give Bob a new commitment
+	// where the HTLC is removed.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+
+	// When sending a new commitment, Alice will add a pending commit to
+	// her remote chain. Since the unsigned acked updates aren't deleted
+	// until we receive a revocation, the fail should still be present.
+	assertInLogs(t, aliceChannel, 1, 0, 0, 1)
+	restoreAndAssert(t, aliceChannel, 1, 0, 0, 1)
+
+	// When Alice receives Bob's revocation, the Fail is irrevocably locked
+	// in on both sides. She should compact the logs, removing the HTLC and
+	// the corresponding Fail from the local update log.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	assertInLogs(t, aliceChannel, 0, 0, 0, 0)
+	restoreAndAssert(t, aliceChannel, 0, 0, 0, 0)
+}
+
+// TestDuplicateFailRejection tests that if either party attempts to fail an
+// HTLC twice, then we'll reject the second fail attempt.
+func TestDuplicateFailRejection(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First, we'll add an HTLC from Alice to Bob, and lock it in for both
+	// parties.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	_, err = bobChannel.ReceiveHTLC(htlcAlice)
+	require.NoError(t, err, "unable to recv htlc")
+
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	// With the HTLC locked in, we'll now have Bob fail the HTLC back to
+	// Alice.
+	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	if err := aliceChannel.ReceiveFailHTLC(0, []byte("bad")); err != nil {
+		t.Fatalf("unable to recv htlc cancel: %v", err)
+	}
+
+	// If we attempt to fail it AGAIN, then both sides should reject this
+	// second failure attempt.
+	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	if err == nil {
+		t.Fatalf("duplicate HTLC failure attempt should have failed")
+	}
+	if err := aliceChannel.ReceiveFailHTLC(0, []byte("bad")); err == nil {
+		t.Fatalf("duplicate HTLC failure attempt should have failed")
+	}
+
+	// We'll now have Bob sign a new commitment to lock in the HTLC fail
+	// for Alice.
+	_, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commit")
+
+	// We'll now force a restart for Bob and Alice, so we can test the
+	// persistence related portion of this assertion.
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart channel")
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart channel")
+
+	// If we try to fail the same HTLC again, then we should get an error.
+	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	if err == nil {
+		t.Fatalf("duplicate HTLC failure attempt should have failed")
+	}
+
+	// Alice on the other hand should accept the failure again, as she
+	// dropped all items in the logs which weren't committed.
+	if err := aliceChannel.ReceiveFailHTLC(0, []byte("bad")); err != nil {
+		t.Fatalf("unable to recv htlc cancel: %v", err)
+	}
+}
+
+// processFwdPkgs marks the unprocessed forwarding packages of the channel as
+// processed, with the adds of the given htlc indexes handed over to the switch.
+func processFwdPkgs(t *testing.T, channel *LightningChannel,
+	forwarded ...uint64) {
+
+	t.Helper()
+
+	fwdPkgs, err := channel.LoadFwdPkgs()
+	require.NoError(t, err, "unable to load fwd pkgs")
+
+	for _, fwdPkg := range fwdPkgs {
+		if fwdPkg.State != channeldb.FwdStateLockedIn {
+			continue
+		}
+
+		fwdFilter := channeldb.NewPkgFilter(uint16(len(fwdPkg.Adds)))
+		for i, add := range fwdPkg.Adds {
+			addMsg := add.UpdateMsg.(*lnwire.UpdateAddHTLC)
+			for _, htlcIndex := range forwarded {
+				if addMsg.ID == htlcIndex {
+					fwdFilter.Set(uint16(i))
+				}
+			}
+		}
+
+		err := channel.SetFwdFilter(fwdPkg.Height, fwdFilter)
+		require.NoError(t, err, "unable to set fwd filter")
+	}
+}
+
+// drainFailReason returns a failure reason specific to the given htlc.
+func drainFailReason(htlc *PaymentDescriptor) (lnwire.OpaqueReason, error) {
+	return lnwire.OpaqueReason(fmt.Sprintf("drain %d", htlc.HtlcIndex)),
+		nil
+}
+
+// TestFailAllPendingIncoming asserts that FailAllPendingIncoming only fails
+// the locked in incoming HTLCs that weren't handed over to the switch or held
+// by the HTLC interceptor and haven't been settled or failed yet, and that
+// calling it again doesn't produce duplicate fails.
+func TestFailAllPendingIncoming(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Alice sends six HTLCs to Bob which are locked in for both parties.
+	const numHtlcs = 6
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	preimages := make([][32]byte, numHtlcs)
+	for i := 0; i < numHtlcs; i++ {
+		htlc, preimage := createHTLC(i, htlcAmount)
+		preimages[i] = preimage
+
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err, "alice unable to add htlc")
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err, "bob unable to recv htlc")
+	}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Until Bob's link processed the HTLCs, none of them can be failed.
+	failed, err := bobChannel.FailAllPendingIncoming(drainFailReason)
+	require.NoError(t, err)
+	require.Empty(t, failed)
+
+	// Bob forwards the fourth HTLC, and holds the fifth one.
+	processFwdPkgs(t, bobChannel, 3)
+	fwdPkgs, err := bobChannel.LoadFwdPkgs()
+	require.NoError(t, err)
+	require.Len(t, fwdPkgs, 1)
+	err = bobChannel.SetHtlcIntercept(
+		fwdPkgs[0].Height, 4, &channeldb.HtlcIntercept{
+			State: channeldb.HtlcInterceptHeld,
+		},
+	)
+	require.NoError(t, err)
+
+	// Another HTLC is added, but not yet locked in, so it must not be
+	// failed.
+	htlc, _ := createHTLC(numHtlcs, htlcAmount)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err, "alice unable to add htlc")
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err, "bob unable to recv htlc")
+
+	// Bob settles the first HTLC and fails the second one himself.
+	err = bobChannel.SettleHTLC(preimages[0], 0, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = bobChannel.FailHTLC(1, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to fail htlc")
+
+	// If a failure can't be created, no HTLC is failed.
+	_, err = bobChannel.FailAllPendingIncoming(
+		func(*PaymentDescriptor) (lnwire.OpaqueReason, error) {
+			return nil, errors.New("no failure")
+		},
+	)
+	require.Error(t, err)
+
+	// Draining the channel should only fail the third HTLC and the last
+	// locked in one.
+	failed, err = bobChannel.FailAllPendingIncoming(drainFailReason)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 5}, failed)
+
+	// A second call should be a noop, as all HTLCs have already been
+	// modified.
+	failed2, err := bobChannel.FailAllPendingIncoming(drainFailReason)
+	require.NoError(t, err)
+	require.Empty(t, failed2)
+
+	// Alice should accept all of Bob's updates, after which both parties
+	// can complete a state transition.
+	err = aliceChannel.ReceiveHTLCSettle(preimages[0], 0)
+	require.NoError(t, err, "unable to recv settle")
+	err = aliceChannel.ReceiveFailHTLC(1, []byte("failreason"))
+	require.NoError(t, err, "unable to recv fail")
+	for _, htlcIndex := range failed {
+		reason := []byte(fmt.Sprintf("drain %d", htlcIndex))
+		err := aliceChannel.ReceiveFailHTLC(htlcIndex, reason)
+		require.NoError(t, err, "unable to recv fail")
+	}
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
+
+	// Signing the fails acked the failed adds in the forwarding package.
+	fwdPkgs, err = bobChannel.LoadFwdPkgs()
+	require.NoError(t, err)
+	require.True(t, fwdPkgs[0].AckFilter.Contains(2))
+	require.True(t, fwdPkgs[0].AckFilter.Contains(5))
+	require.False(t, fwdPkgs[0].AckFilter.Contains(3))
+	require.False(t, fwdPkgs[0].AckFilter.Contains(4))
+
+	// Only the HTLC that wasn't locked in before should remain, which can
+	// be drained as well once it's processed.
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	processFwdPkgs(t, bobChannel)
+	failed, err = bobChannel.FailAllPendingIncoming(drainFailReason)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{numHtlcs}, failed)
+}
+
+// TestChannelMetrics asserts that the update counters of both parties reflect
+// the HTLC updates and state transitions they processed.
+func TestChannelMetrics(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	require.Equal(t, ChannelMetrics{}, aliceChannel.ChannelMetrics())
+	require.Equal(t, ChannelMetrics{}, bobChannel.ChannelMetrics())
+
+	// Alice sends three HTLCs to Bob, which are locked in with a single
+	// state transition.
+	const numHtlcs = 3
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	preimages := make([][32]byte, numHtlcs)
+	for i := 0; i < numHtlcs; i++ {
+		htlc, preimage := createHTLC(i, htlcAmount)
+		preimages[i] = preimage
+
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err, "alice unable to add htlc")
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err, "bob unable to recv htlc")
+	}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Bob settles the first HTLC, fails the second one and fails the third
+	// one as malformed.
+	err = bobChannel.SettleHTLC(preimages[0], 0, nil, nil, nil)
+	require.NoError(t, err, "unable to settle htlc")
+	err = aliceChannel.ReceiveHTLCSettle(preimages[0], 0)
+	require.NoError(t, err, "unable to recv settle")
+
+	err = bobChannel.FailHTLC(1, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to fail htlc")
+	err = aliceChannel.ReceiveFailHTLC(1, []byte("failreason"))
+	require.NoError(t, err, "unable to recv fail")
+
+	err = bobChannel.MalformedFailHTLC(
+		2, lnwire.CodeInvalidOnionKey, [sha256.Size]byte{}, nil,
+	)
+	require.NoError(t, err, "unable to fail htlc")
+	err = aliceChannel.ReceiveFailHTLC(2, []byte{})
+	require.NoError(t, err, "unable to recv fail")
+
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
+
+	// Both parties went through two full state transitions, each of which
+	// has them sign, receive and revoke a commitment once.
+	expected := ChannelMetrics{
+		CommitmentsSigned:   2,
+		CommitmentsReceived: 2,
+		RevocationsSent:     2,
+		RevocationsReceived: 2,
+		HtlcsAdded:          numHtlcs,
+		HtlcsSettled:        1,
+		HtlcsFailed:         2,
+	}
+	require.Equal(t, expected, aliceChannel.ChannelMetrics())
+	require.Equal(t, expected, bobChannel.ChannelMetrics())
+}
+
+// TestPreimageObserver asserts that the preimage observer is notified with
+// the correct payment hash and preimage when either party settles an HTLC.
+func TestPreimageObserver(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	type notification struct {
+		rHash    PaymentHash
+		preimage [32]byte
+	}
+	var aliceNotes, bobNotes []notification
+	aliceChannel.SetPreimageObserver(func(h PaymentHash, p [32]byte) {
+		aliceNotes = append(aliceNotes, notification{h, p})
+	})
+	bobChannel.SetPreimageObserver(func(h PaymentHash, p [32]byte) {
+		bobNotes = append(bobNotes, notification{h, p})
+	})
+
+	htlc, preimage := createHTLC(0, lnwire.NewMSatFromSatoshis(10_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// A settle with the wrong preimage must not notify the observer.
+	err = bobChannel.SettleHTLC([32]byte{1}, 0, nil, nil, nil)
+	require.Error(t, err)
+	require.Empty(t, bobNotes)
+
+	require.NoError(t, bobChannel.SettleHTLC(preimage, 0, nil, nil, nil))
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimage, 0))
+
+	expected := []notification{{htlc.PaymentHash, preimage}}
+	require.Equal(t, expected, bobNotes)
+	require.Equal(t, expected, aliceNotes)
+}
+
+// TestDuplicateSettleRejection tests that if either party attempts to settle
+// an HTLC twice, then we'll reject the second settle attempt.
+func TestDuplicateSettleRejection(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// First, we'll add an HTLC from Alice to Bob, and lock it in for both
+	// parties.
+	htlcAmount := lnwire.NewMSatFromSatoshis(20000)
+	htlcAlice, alicePreimage := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	_, err = bobChannel.ReceiveHTLC(htlcAlice)
+	require.NoError(t, err, "unable to recv htlc")
+
+	if err := ForceStateTransition(aliceChannel, bobChannel); err != nil {
+		t.Fatalf("unable to complete state update: %v", err)
+	}
+
+	// With the HTLC locked in, we'll now have Bob settle the HTLC back to
+	// Alice.
+	err = bobChannel.SettleHTLC(alicePreimage, uint64(0), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, uint64(0))
+	require.NoError(t, err, "unable to recv htlc cancel")
+
+	// If we attempt to fail it AGAIN, then both sides should reject this
+	// second failure attempt.
+	err = bobChannel.SettleHTLC(alicePreimage, uint64(0), nil, nil, nil)
+	if err == nil {
+		t.Fatalf("duplicate HTLC failure attempt should have failed")
+	}
+	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, uint64(0))
+	if err == nil {
+		t.Fatalf("duplicate HTLC failure attempt should have failed")
+	}
+
+	// We'll now have Bob sign a new commitment to lock in the HTLC fail
+	// for Alice.
+	_, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commit")
+
+	// We'll now force a restart for Bob and Alice, so we can test the
+	// persistence related portion of this assertion.
+	bobChannel, err = restartChannel(bobChannel)
+	require.NoError(t, err, "unable to restart channel")
+	aliceChannel, err = restartChannel(aliceChannel)
+	require.NoError(t, err, "unable to restart channel")
+
+	// If we try to fail the same HTLC again, then we should get an error.
+	err = bobChannel.SettleHTLC(alicePreimage, uint64(0), nil, nil, nil)
+	if err == nil {
+		t.Fatalf("duplicate HTLC failure attempt should have failed")
+	}
+
+	// Alice on the other hand should accept the failure again, as she
+	// dropped all items in the logs which weren't committed.
+	err = aliceChannel.ReceiveHTLCSettle(alicePreimage, uint64(0))
+	require.NoError(t, err, "unable to recv htlc cancel")
+}
+
+// TestChannelRestoreCommitHeight tests that the local and remote commit
+// heights of HTLCs are set correctly across restores.
+func TestChannelRestoreCommitHeight(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// helper method to check add heights of the htlcs found in the given
+	// log after a restore.
+	restoreAndAssertCommitHeights := func(t *testing.T,
+		channel *LightningChannel, remoteLog bool, htlcIndex uint64,
+		expLocal, expRemote uint64) *LightningChannel {
+
+		newChannel, err := NewLightningChannel(
+			channel.Signer, channel.channelState, channel.sigPool,
+		)
+		if err != nil {
+			t.Fatalf("unable to create new channel: %v", err)
+		}
+
+		var pd *PaymentDescriptor
+		if remoteLog {
+			if newChannel.localUpdateLog.lookupHtlc(htlcIndex) != nil {
+				t.Fatalf("htlc found in wrong log")
+			}
+			pd = newChannel.remoteUpdateLog.lookupHtlc(htlcIndex)
+
+		} else {
+			if newChannel.remoteUpdateLog.lookupHtlc(htlcIndex) != nil {
+				t.Fatalf("htlc found in wrong log")
+			}
+			pd = newChannel.localUpdateLog.lookupHtlc(htlcIndex)
+		}
+		if pd == nil {
+			t.Fatalf("htlc not found in log")
+		}
+
+		if pd.addCommitHeightLocal != expLocal {
+			t.Fatalf("expected local add height to be %d, was %d",
+				expLocal, pd.addCommitHeightLocal)
+		}
+		if pd.addCommitHeightRemote != expRemote {
+			t.Fatalf("expected remote add height to be %d, was %d",
+				expRemote, pd.addCommitHeightRemote)
+		}
+		return newChannel
+	}
+
+	// We'll send an HtLC from Alice to Bob.
+	htlcAmount := lnwire.NewMSatFromSatoshis(100000000)
+	htlcAlice, _ := createHTLC(0, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
+
+	// Let Alice sign a new state, which will include the HTLC just sent.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// The HTLC should only be on the pending remote commitment, so the
+	// only the remote add height should be set during a restore.
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 0, 1,
+	)
+
+	// Bob receives this commitment signature, and revokes his old state.
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+
+	// Now the HTLC is locked into Bob's commitment, a restoration should
+	// set only the local commit height, as it is not locked into Alice's
+	// yet.
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 1, 0)
+
+	// Alice receives the revocation, ACKing her pending commitment.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	// However, the HTLC is still not locked into her local commitment, so
+	// the local add height should still be 0 after a restoration.
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 0, 1,
+	)
+
+	// Now let Bob send the commitment signature making the HTLC lock in on
+	// Alice's commitment.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// At this stage Bob has a pending remote commitment. Make sure
+	// restoring at this stage correctly restores the HTLC add commit
+	// heights.
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 1, 1)
+
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+
+	// Now both the local and remote add heights should be properly set.
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 1, 1,
+	)
+
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	// Alice ACKing Bob's pending commitment shouldn't change the heights
+	// restored.
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 1, 1)
+
+	// Send andother HTLC from Alice to Bob, to test whether already
+	// existing HTLCs (the HTLC with index 0) keep getting the add heights
+	// restored properly.
+	htlcAlice, _ = createHTLC(1, htlcAmount)
+	if _, err := aliceChannel.AddHTLC(htlcAlice, nil); err != nil {
+		t.Fatalf("alice unable to add htlc: %v", err)
+	}
+	if _, err := bobChannel.ReceiveHTLC(htlcAlice); err != nil {
+		t.Fatalf("bob unable to recv add htlc: %v", err)
+	}
+
+	// Send a new signature from Alice to Bob, making Alice have a pending
+	// remote commitment.
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// A restoration should keep the add heights iof the first HTLC, and
+	// the new HTLC should have a remote add height 2.
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 1, 1,
+	)
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 1, 0, 2,
+	)
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+
+	// Since Bob just revoked another commitment, a restoration should
+	// increase the add height of the first HTLC to 2, as we only keep the
+	// last unrevoked commitment. The new HTLC will also have a local add
+	// height of 2.
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 1)
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 0)
+
+	// Alice receives the revocation, ACKing her pending commitment for Bob.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	// Alice receiving Bob's revocation should bump both addCommitHeightRemote
+	// heights to 2.
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 1, 2,
+	)
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 1, 0, 2,
+	)
+
+	// Sign a new state for Alice, making Bob have a pending remote
+	// commitment.
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// The signing of a new commitment for Alice should have given the new
+	// HTLC an add height.
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 1)
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 2)
+
+	// Alice should receive the commitment and send over a revocation.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+
+	// Both heights should be 2 and they are on both commitments.
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 2, 2,
+	)
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 1, 2, 2,
+	)
+
+	// Bob receives the revocation, which should set both addCommitHeightRemote
+	// fields to 2.
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err, "unable to receive revocation")
+
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 2)
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 2)
+
+	// Bob now fails back the htlc that was just locked in.
+	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err, "unable to cancel HTLC")
+	err = aliceChannel.ReceiveFailHTLC(0, []byte("bad"))
+	require.NoError(t, err, "unable to recv htlc cancel")
+
+	// Now Bob signs for the fail update.
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commitment")
+
+	// Bob has a pending commitment for Alice, it shouldn't affect the add
+	// commit heights though.
+	bobChannel = restoreAndAssertCommitHeights(t, bobChannel, true, 0, 2, 2)
+	_ = restoreAndAssertCommitHeights(t, bobChannel, true, 1, 2, 2)
+
+	// Alice receives commitment, sends revocation.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	_, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke commitment")
+
+	aliceChannel = restoreAndAssertCommitHeights(
+		t, aliceChannel, false, 0, 3, 2,
+	)
+	_ = restoreAndAssertCommitHeights(t, aliceChannel, false, 1, 3, 2)
+}
+
+// TestForceCloseFailLocalDataLoss tests that we don't allow a force close of a
+// channel that's in a non-default state.
+func TestForceCloseFailLocalDataLoss(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Now that we have our set of channels, we'll modify the channel state
+	// to have a non-default channel flag.
+	err = aliceChannel.channelState.ApplyChanStatus(
+		channeldb.ChanStatusLocalDataLoss,
+	)
+	require.NoError(t, err, "unable to apply channel state")
+
+	// Due to the change above, if we attempt to force close this
+	// channel, we should fail as it isn't safe to force close a
+	// channel that isn't in the pure default state.
+	_, err = aliceChannel.ForceClose()
+	require.ErrorIs(t, err, ErrForceCloseLocalDataLoss)
+}
+
+// TestForceCloseBorkedState tests that once we force close a channel, it's
+// marked as borked in the database. Additionally, all calls to mutate channel
+// state should also fail.
+func TestForceCloseBorkedState(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Do the commitment dance until Bob sends a revocation so Alice is
+	// able to receive the revocation, and then also make a new state
+	// herself.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commit")
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+	revokeMsg, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err, "unable to revoke bob commitment")
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err, "unable to sign commit")
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err, "unable to receive commitment")
+
+	// Now that we have a new Alice channel, we'll force close once to
+	// trigger the update on disk to mark the channel as borked.
+	if _, err := aliceChannel.ForceClose(); err != nil {
+		t.Fatalf("unable to force close channel: %v", err)
+	}
+
+	// Next we'll mark the channel as borked before we proceed.
+	err = aliceChannel.channelState.ApplyChanStatus(
+		channeldb.ChanStatusBorked,
+	)
+	require.NoError(t, err, "unable to apply chan status")
+
+	// The on-disk state should indicate that the channel is now borked.
+	if !aliceChannel.channelState.HasChanStatus(
+		channeldb.ChanStatusBorked,
+	) {
+		t.Fatalf("chan status not updated as borked")
+	}
+
+	// At this point, all channel mutating methods should now fail as they
+	// shouldn't be able to proceed if the channel is borked.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(revokeMsg)
+	if err != channeldb.ErrChanBorked {
+		t.Fatalf("advance commitment tail should have failed")
+	}
+
+	// We manually advance the commitment tail here since the above
+	// ReceiveRevocation call will fail before it's actually advanced.
+	aliceChannel.remoteCommitChain.advanceTail()
+	_, err = aliceChannel.SignNextCommitment()
+	if err != channeldb.ErrChanBorked {
+		t.Fatalf("sign commitment should have failed: %v", err)
+	}
+	_, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	if err != channeldb.ErrChanBorked {
+		t.Fatalf("append remove chain tail should have failed")
+	}
+}
+
+// TestChannelMaxFeeRate ensures we correctly compute a channel initiator's max
+// fee rate based on an allocation and its available balance. It should never
+// dip below the established fee floor.
+func TestChannelMaxFeeRate(t *testing.T) {
+	t.Parallel()
+
+	assertMaxFeeRate := func(c *LightningChannel, maxAlloc float64,
+		expFeeRate chainfee.SatPerKWeight) {
+
+		maxFeeRate := c.MaxFeeRate(maxAlloc)
+		if maxFeeRate != expFeeRate {
+			t.Fatalf("expected max fee rate of %v with max "+
+				"allocation of %v, got %v", expFeeRate,
+				maxAlloc, maxFeeRate)
+		}
+
+		if err := c.validateFeeRate(maxFeeRate); err != nil {
+			t.Fatalf("fee rate validation failed: %v", err)
+		}
+	}
+
+	// propertyTest tests that the validateFeeRate function always passes
+	// for the output returned by MaxFeeRate for any valid random inputs
+	// fed to MaxFeeRate.
+	propertyTest := func(c *LightningChannel) func(alloc maxAlloc) bool {
+		return func(ma maxAlloc) bool {
+			maxFeeRate := c.MaxFeeRate(float64(ma))
+			return c.validateFeeRate(maxFeeRate) == nil
+		}
+	}
+
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	if err := quick.Check(propertyTest(aliceChannel), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	assertMaxFeeRate(aliceChannel, 1.0, 676794154)
+	assertMaxFeeRate(aliceChannel, 0.001, 676794)
+	assertMaxFeeRate(aliceChannel, 0.000001, 676)
+	assertMaxFeeRate(aliceChannel, 0.0000001, chainfee.FeePerKwFloor)
+
+	// Check that anchor channels are capped at their max fee rate.
+	anchorChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit|channeldb.ZeroHtlcTxFeeBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	if err = quick.Check(propertyTest(anchorChannel), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anchor commitments are heavier, hence will the same allocation lead
+	// to slightly lower fee rates.
+	assertMaxFeeRate(anchorChannel, 1.0, 435941555)
+	assertMaxFeeRate(anchorChannel, 0.001, 435941)
+	assertMaxFeeRate(anchorChannel, 0.000001, 435)
+	assertMaxFeeRate(anchorChannel, 0.0000001, chainfee.FeePerKwFloor)
+}
+
+// TestIdealCommitFeeRate tests that we correctly compute the ideal commitment
+// fee of a channel given the current network fee, minimum relay fee, maximum
+// fee allocation and whether the channel has anchor outputs.
+func TestIdealCommitFeeRate(t *testing.T) {
+	t.Parallel()
+
+	assertIdealFeeRate := func(c *LightningChannel, netFee, minRelay,
+		maxAnchorCommit chainfee.SatPerKWeight,
+		maxFeeAlloc float64, expectedFeeRate chainfee.SatPerKWeight) {
+
+		feeRate := c.IdealCommitFeeRate(
+			netFee, minRelay, maxAnchorCommit, maxFeeAlloc,
+		)
+		if feeRate != expectedFeeRate {
+			t.Fatalf("expected fee rate of %v got %v",
+				expectedFeeRate, feeRate)
+		}
+
+		if err := c.validateFeeRate(feeRate); err != nil {
+			t.Fatalf("fee rate validation failed: %v", err)
+		}
+	}
+
+	// propertyTest tests that the validateFeeRate function always passes
+	// for the output returned by IdealCommitFeeRate for any valid random
+	// inputs fed to IdealCommitFeeRate.
+	propertyTest := func(c *LightningChannel) func(ma maxAlloc,
+		netFee, minRelayFee, maxAnchorFee fee) bool {
+
+		return func(ma maxAlloc, netFee, minRelayFee,
+			maxAnchorFee fee) bool {
+
+			idealFeeRate := c.IdealCommitFeeRate(
+				chainfee.SatPerKWeight(netFee),
+				chainfee.SatPerKWeight(minRelayFee),
+				chainfee.SatPerKWeight(maxAnchorFee),
+				float64(ma),
+			)
+
+			return c.validateFeeRate(idealFeeRate) == nil
+		}
+	}
+
+	// Test ideal fee rates for a non-anchor channel
+	t.Run("non-anchor-channel", func(t *testing.T) {
+		aliceChannel, _, err := CreateTestChannels(
+			t, channeldb.SingleFunderTweaklessBit,
+		)
+		if err != nil {
+			t.Fatalf("unable to create test channels: %v", err)
+		}
+
+		err = quick.Check(propertyTest(aliceChannel), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// If the maximum fee is lower than the network fee and above
+		// the min relay fee, use the maximum fee.
+		assertIdealFeeRate(
+			aliceChannel, 700000000, 0, 0, 1.0, 676794154,
+		)
+
+		// If the network fee is lower than the max fee and above the
+		// min relay fee, use the network fee.
+		assertIdealFeeRate(
+			aliceChannel, 500000000, 0, 0, 1.0, 500000000,
+		)
+
+		// If the fee is below the minimum relay fee, and the min relay
+		// fee is less than our max fee, use the minimum relay fee.
+		assertIdealFeeRate(
+			aliceChannel, 500000000, 600000000, 0, 1.0, 600000000,
+		)
+
+		// The absolute maximum fee rate we can pay (ie, using a max
+		// allocation of 1) is still below the minimum relay fee. In
+		// this case, use the absolute max fee.
+		assertIdealFeeRate(
+			aliceChannel, 800000000, 700000000, 0, 0.001,
+			676794154,
+		)
+	})
+
+	// Test ideal fee rates for an anchor channel
+	t.Run("anchor-channel", func(t *testing.T) {
+		anchorChannel, _, err := CreateTestChannels(
+			t, channeldb.SingleFunderTweaklessBit|
+				channeldb.AnchorOutputsBit|
+				channeldb.ZeroHtlcTxFeeBit,
+		)
+		if err != nil {
+			t.Fatalf("unable to create test channels: %v", err)
+		}
+
+		err = quick.Check(propertyTest(anchorChannel), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Anchor commitments are heavier, hence the same allocation
+		// leads to slightly lower fee rates.
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 0, chainfee.FeePerKwFloor,
+			0.1, chainfee.FeePerKwFloor,
+		)
+
+		// If the maximum fee is lower than the network fee, use the
+		// maximum fee.
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 0, 1000000, 0.001, 435941,
+		)
+
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 0, 700, 0.000001, 435,
+		)
+
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 0, 1000000, 0.0000001,
+			chainfee.FeePerKwFloor,
+		)
+
+		// If the maximum anchor commit fee rate is less than the
+		// maximum fee, use the max anchor commit fee since this is an
+		// anchor channel.
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 0, 400000, 0.001, 400000,
+		)
+
+		// If the minimum relay fee is above the max anchor commitment
+		// fee rate but still below our max fee rate then use the min
+		// relay fee.
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 400000, 300000, 0.001,
+			400000,
+		)
+
+		// If the min relay fee is above the ideal max fee rate but is
+		// below the max fee rate when the max fee allocation is set to
+		// 1, the minimum relay fee is used.
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 500000, 300000, 0.001,
+			500000,
+		)
+
+		// The absolute maximum fee rate we can pay (ie, using a max
+		// allocation of 1) is still below the minimum relay fee. In
+		// this case, use the absolute max fee.
+		assertIdealFeeRate(
+			anchorChannel, 700000000, 450000000, 300000, 0.001,
+			435941555,
+		)
+	})
+}
+
+type maxAlloc float64
+
+// Generate ensures that the random value generated by the testing quick
+// package for maxAlloc is always a positive float64 between 0 and 1.
+func (maxAlloc) Generate(r *rand.Rand, _ int) reflect.Value {
+	ma := maxAlloc(r.Float64())
+	return reflect.ValueOf(ma)
+}
+
+type fee chainfee.SatPerKWeight
+
+// Generate ensures that the random value generated by the testing quick
+// package for a fee is always a positive int64.
+func (fee) Generate(r *rand.Rand, _ int) reflect.Value {
+	am := fee(r.Int63())
+	return reflect.ValueOf(am)
+}
+
+// TestChannelFeeRateFloor asserts that valid commitments can be proposed and
+// received using chainfee.FeePerKwFloor as the initiator's fee rate.
+func TestChannelFeeRateFloor(t *testing.T) {
+	t.Parallel()
+
+	alice, bob, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Set the fee rate to the proposing fee rate floor.
+	minFee := chainfee.FeePerKwFloor
+
+	// Alice is the initiator, so only she can propose fee updates.
+	if err := alice.UpdateFee(minFee); err != nil {
+		t.Fatalf("unable to send fee update")
+	}
+	if err := bob.ReceiveUpdateFee(minFee); err != nil {
+		t.Fatalf("unable to receive fee update")
+	}
+
+	// Check that alice can still sign commitments.
+	aliceNewCommit, err := alice.SignNextCommitment()
+	require.NoError(t, err, "alice unable to sign commitment")
+
+	// Check that bob can still receive commitments.
+	err = bob.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	if err != nil {
+		t.Fatalf("bob unable to process alice's new commitment: %v",
+			err)
+	}
+}
+
+// TestFetchParent tests lookup of an entry's parent in the appropriate log.
+func TestFetchParent(t *testing.T) {
+	tests := []struct {
+		name          string
+		remoteChain   bool
+		remoteLog     bool
+		localEntries  []*PaymentDescriptor
+		remoteEntries []*PaymentDescriptor
+
+		// parentIndex is the parent index of the entry that we will
+		// lookup with fetch parent.
+		parentIndex uint64
+
+		// expectErr indicates that we expect fetch parent to fail.
+		expectErr bool
+
+		// expectedIndex is the htlc index that we expect the parent
+		// to have.
+		expectedIndex uint64
+	}{
+		{
+			name:          "not found in remote log",
+			localEntries:  nil,
+			remoteEntries: nil,
+			remoteChain:   true,
+			remoteLog:     true,
+			parentIndex:   0,
+			expectErr:     true,
+		},
+		{
+			name:          "not found in local log",
+			localEntries:  nil,
+			remoteEntries: nil,
+			remoteChain:   false,
+			remoteLog:     false,
+			parentIndex:   0,
+			expectErr:     true,
+		},
+		{
+			name:         "remote log + chain, remote add height 0",
+			localEntries: nil,
+			remoteEntries: []*PaymentDescriptor{
+				// This entry will be added at log index =0.
+				{
+					HtlcIndex:             1,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 100,
+				},
+				// This entry will be added at log index =1, it
+				// is the parent entry we are looking for.
+				{
+					HtlcIndex:             2,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 0,
+				},
+			},
+			remoteChain: true,
+			remoteLog:   true,
+			parentIndex: 1,
+			expectErr:   true,
+		},
+		{
+			name: "remote log, local chain, local add height 0",
+			remoteEntries: []*PaymentDescriptor{
+				// This entry will be added at log index =0.
+				{
+					HtlcIndex:             1,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 100,
+				},
+				// This entry will be added at log index =1, it
+				// is the parent entry we are looking for.
+				{
+					HtlcIndex:             2,
+					addCommitHeightLocal:  0,
+					addCommitHeightRemote: 100,
+				},
+			},
+			localEntries: nil,
+			remoteChain:  false,
+			remoteLog:    true,
+			parentIndex:  1,
+			expectErr:    true,
+		},
+		{
+			name: "local log + chain, local add height 0",
+			localEntries: []*PaymentDescriptor{
+				// This entry will be added at log index =0.
+				{
+					HtlcIndex:             1,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 100,
+				},
+				// This entry will be added at log index =1, it
+				// is the parent entry we are looking for.
+				{
+					HtlcIndex:             2,
+					addCommitHeightLocal:  0,
+					addCommitHeightRemote: 100,
+				},
+			},
+			remoteEntries: nil,
+			remoteChain:   false,
+			remoteLog:     false,
+			parentIndex:   1,
+			expectErr:     true,
+		},
+
+		{
+			name: "local log + remote chain, remote add height 0",
+			localEntries: []*PaymentDescriptor{
+				// This entry will be added at log index =0.
+				{
+					HtlcIndex:             1,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 100,
+				},
+				// This entry will be added at log index =1, it
+				// is the parent entry we are looking for.
+				{
+					HtlcIndex:             2,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 0,
+				},
+			},
+			remoteEntries: nil,
+			remoteChain:   true,
+			remoteLog:     false,
+			parentIndex:   1,
+			expectErr:     true,
+		},
+		{
+			name:         "remote log found",
+			localEntries: nil,
+			remoteEntries: []*PaymentDescriptor{
+				// This entry will be added at log index =0.
+				{
+					HtlcIndex:             1,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 0,
+				},
+				// This entry will be added at log index =1, it
+				// is the parent entry we are looking for.
+				{
+					HtlcIndex:             2,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 100,
+				},
+			},
+			remoteChain:   true,
+			remoteLog:     true,
+			parentIndex:   1,
+			expectErr:     false,
+			expectedIndex: 2,
+		},
+		{
+			name: "local log found",
+			localEntries: []*PaymentDescriptor{
+				// This entry will be added at log index =0.
+				{
+					HtlcIndex:             1,
+					addCommitHeightLocal:  0,
+					addCommitHeightRemote: 100,
+				},
+				// This entry will be added at log index =1, it
+				// is the parent entry we are looking for.
+				{
+					HtlcIndex:             2,
+					addCommitHeightLocal:  100,
+					addCommitHeightRemote: 100,
+				},
+			},
+			remoteEntries: nil,
+			remoteChain:   false,
+			remoteLog:     false,
+			parentIndex:   1,
+			expectErr:     false,
+			expectedIndex: 2,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			// Create a lightning channel with newly initialized
+			// local and remote logs.
+			lc := LightningChannel{
+				localUpdateLog:  newUpdateLog(0, 0),
+				remoteUpdateLog: newUpdateLog(0, 0),
+			}
+
+			// Add the local and remote entries to update logs.
+			for _, entry := range test.localEntries {
+				lc.localUpdateLog.appendHtlc(entry)
+			}
+			for _, entry := range test.remoteEntries {
+				lc.remoteUpdateLog.appendHtlc(entry)
+			}
+
+			parent, err := lc.fetchParent(
+				&PaymentDescriptor{
+					ParentIndex: test.parentIndex,
+				},
+				test.remoteChain,
+				test.remoteLog,
+			)
+			gotErr := err != nil
+			if test.expectErr != gotErr {
+				t.Fatalf("expected error: %v, got: %v, "+
+					"error:%v", test.expectErr, gotErr, err)
+			}
+
+			// If our lookup failed, we do not need to check parent
+			// index.
+			if err != nil {
+				return
+			}
+
+			if parent.HtlcIndex != test.expectedIndex {
+				t.Fatalf("expected parent index: %v, got: %v",
+					test.parentIndex, parent.HtlcIndex)
+			}
+		})
+	}
+}
+
+// TestEvaluateView tests the creation of a htlc view and the opt in mutation of
+// send and receive balances. This test does not check htlc mutation on a htlc
+// level.
+func TestEvaluateView(t *testing.T) {
+	const (
+		// addHeight is a non-zero height that is used for htlc adds.
+		addHeight = 200
+
+		// nextHeight is a constant that we use for the next height in
+		// all unit tests.
+		nextHeight = 400
+
+		// feePerKw is the fee we start all of our unit tests with.
+		feePerKw = 1
+
+		// htlcAddAmount is the amount for htlc adds in tests.
+		htlcAddAmount = 15
+
+		// ourFeeUpdateAmt is an amount that we update fees to
+		// expressed in msat.
+		ourFeeUpdateAmt = 20000
+
+		// ourFeeUpdatePerSat is the fee rate *in satoshis* that we
+		// expect if we update to ourFeeUpdateAmt.
+		ourFeeUpdatePerSat = chainfee.SatPerKWeight(20)
+
+		// theirFeeUpdateAmt iis an amount that they update fees to
+		// expressed in msat.
+		theirFeeUpdateAmt = 10000
+
+		// theirFeeUpdatePerSat is the fee rate *in satoshis* that we
+		// expect if we update to ourFeeUpdateAmt.
+		theirFeeUpdatePerSat = chainfee.SatPerKWeight(10)
+	)
+
+	tests := []struct {
+		name        string
+		ourHtlcs    []*PaymentDescriptor
+		theirHtlcs  []*PaymentDescriptor
+		remoteChain bool
+		mutateState bool
+
+		// ourExpectedHtlcs is the set of our htlcs that we expect in
+		// the htlc view once it has been evaluated. We just store
+		// htlc index -> bool for brevity, because we only check the
+		// presence of the htlc in the returned set.
+		ourExpectedHtlcs map[uint64]bool
+
+		// theirExpectedHtlcs is the set of their htlcs that we expect
+		// in the htlc view once it has been evaluated. We just store
+		// htlc index -> bool for brevity, because we only check the
+		// presence of the htlc in the returned set.
+		theirExpectedHtlcs map[uint64]bool
+
+		// expectedFee is the fee we expect to be set after evaluating
+		// the htlc view.
+		expectedFee chainfee.SatPerKWeight
+	}{
+		{
+			name:        "our fee update is applied",
+			remoteChain: false,
+			mutateState: false,
+			ourHtlcs: []*PaymentDescriptor{
+				{
+					Amount:    ourFeeUpdateAmt,
+					EntryType: FeeUpdate,
+				},
+			},
+			theirHtlcs:         nil,
+			expectedFee:        ourFeeUpdatePerSat,
+			ourExpectedHtlcs:   nil,
+			theirExpectedHtlcs: nil,
+		},
+		{
+			name:        "their fee update is applied",
+			remoteChain: false,
+			mutateState: false,
+			ourHtlcs:    []*PaymentDescriptor{},
+			theirHtlcs: []*PaymentDescriptor{
+				{
+					Amount:    theirFeeUpdateAmt,
+					EntryType: FeeUpdate,
+				},
+			},
+			expectedFee:        theirFeeUpdatePerSat,
+			ourExpectedHtlcs:   nil,
+			theirExpectedHtlcs: nil,
+		},
+		{
+			// We expect unresolved htlcs to to remain in the view.
+			name:        "htlcs adds without settles",
+			remoteChain: false,
+			mutateState: false,
+			ourHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex: 0,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+			},
+			theirHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex: 0,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+				{
+					HtlcIndex: 1,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+			},
+			expectedFee: feePerKw,
+			ourExpectedHtlcs: map[uint64]bool{
+				0: true,
+			},
+			theirExpectedHtlcs: map[uint64]bool{
+				0: true,
+				1: true,
+			},
+		},
+		{
+			name:        "our htlc settled, state mutated",
+			remoteChain: false,
+			mutateState: true,
+			ourHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex:            0,
+					Amount:               htlcAddAmount,
+					EntryType:            Add,
+					addCommitHeightLocal: addHeight,
+				},
+			},
+			theirHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex: 0,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+				{
+					HtlcIndex: 1,
+					Amount:    htlcAddAmount,
+					EntryType: Settle,
+					// Map their htlc settle update to our
+					// htlc add (0).
+					ParentIndex: 0,
+				},
+			},
+			expectedFee:      feePerKw,
+			ourExpectedHtlcs: nil,
+			theirExpectedHtlcs: map[uint64]bool{
+				0: true,
+			},
+		},
+		{
+			name:        "our htlc settled, state not mutated",
+			remoteChain: false,
+			mutateState: false,
+			ourHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex:            0,
+					Amount:               htlcAddAmount,
+					EntryType:            Add,
+					addCommitHeightLocal: addHeight,
+				},
+			},
+			theirHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex: 0,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+				{
+					HtlcIndex: 1,
+					Amount:    htlcAddAmount,
+					EntryType: Settle,
+					// Map their htlc settle update to our
+					// htlc add (0).
+					ParentIndex: 0,
+				},
+			},
+			expectedFee:      feePerKw,
+			ourExpectedHtlcs: nil,
+			theirExpectedHtlcs: map[uint64]bool{
+				0: true,
+			},
+		},
+		{
+			name:        "their htlc settled, state mutated",
+			remoteChain: false,
+			mutateState: true,
+			ourHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex: 0,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+				{
+					HtlcIndex: 1,
+					Amount:    htlcAddAmount,
+					EntryType: Settle,
+					// Map our htlc settle update to their
+					// htlc add (1).
+					ParentIndex: 1,
+				},
+			},
+			theirHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex:            0,
+					Amount:               htlcAddAmount,
+					EntryType:            Add,
+					addCommitHeightLocal: addHeight,
+				},
+				{
+					HtlcIndex:            1,
+					Amount:               htlcAddAmount,
+					EntryType:            Add,
+					addCommitHeightLocal: addHeight,
+				},
+			},
+			expectedFee: feePerKw,
+			ourExpectedHtlcs: map[uint64]bool{
+				0: true,
+			},
+			theirExpectedHtlcs: map[uint64]bool{
+				0: true,
+			},
+		},
+		{
+			name:        "their htlc settled, state not mutated",
+			remoteChain: false,
+			mutateState: false,
+			ourHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex: 0,
+					Amount:    htlcAddAmount,
+					EntryType: Add,
+				},
+				{
+					HtlcIndex: 1,
+					Amount:    htlcAddAmount,
+					EntryType: Settle,
+					// Map our htlc settle update to their
+					// htlc add (0).
+					ParentIndex: 0,
+				},
+			},
+			theirHtlcs: []*PaymentDescriptor{
+				{
+					HtlcIndex:            0,
+					Amount:               htlcAddAmount,
+					EntryType:            Add,
+					addCommitHeightLocal: addHeight,
+				},
+			},
+			expectedFee: feePerKw,
+			ourExpectedHtlcs: map[uint64]bool{
+				0: true,
+			},
+			theirExpectedHtlcs: nil,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			lc := LightningChannel{
+				channelState: &channeldb.OpenChannel{
+					TotalMSatSent:     0,
+					TotalMSatReceived: 0,
+				},
+
+				// Create update logs for local and remote.
+				localUpdateLog:  newUpdateLog(0, 0),
+				remoteUpdateLog: newUpdateLog(0, 0),
+			}
+
+			for _, htlc := range test.ourHtlcs {
+				if htlc.EntryType == Add {
+					lc.localUpdateLog.appendHtlc(htlc)
+				} else {
+					lc.localUpdateLog.appendUpdate(htlc)
+				}
+			}
+
+			for _, htlc := range test.theirHtlcs {
+				if htlc.EntryType == Add {
+					lc.remoteUpdateLog.appendHtlc(htlc)
+				} else {
+					lc.remoteUpdateLog.appendUpdate(htlc)
+				}
+			}
+
+			view := &htlcView{
+				ourUpdates:   test.ourHtlcs,
+				theirUpdates: test.theirHtlcs,
+				feePerKw:     feePerKw,
+			}
+
+			var (
+				// Create vars to store balance changes. We do
+				// not check these values in this test because
+				// balance modification happens on the htlc
+				// processing level.
+				ourBalance   lnwire.MilliSatoshi
+				theirBalance lnwire.MilliSatoshi
+			)
+
+			// Evaluate the htlc view, mutate as test expects.
+			result, err := lc.evaluateHTLCView(
+				view, &ourBalance, &theirBalance, nextHeight,
+				test.remoteChain, test.mutateState,
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.feePerKw != test.expectedFee {
+				t.Fatalf("expected fee: %v, got: %v",
+					test.expectedFee, result.feePerKw)
+			}
+
+			checkExpectedHtlcs(
+				t, result.ourUpdates, test.ourExpectedHtlcs,
+			)
+
+			checkExpectedHtlcs(
+				t, result.theirUpdates, test.theirExpectedHtlcs,
+			)
+
+			// Settles are only accounted for once they're
+			// irreversible, which evaluating a view never makes
+			// them.
+			require.Zero(t, lc.channelState.TotalMSatSent)
+			require.Zero(t, lc.channelState.TotalMSatReceived)
+		})
+	}
+}
+
+// TestSettleAccountingOnRevocation asserts that the amounts sent and received
+// within the channel are only incremented once the remote party revoked the
+// commitment still holding a settled HTLC, and that evaluating views of the
+// channel in the meantime doesn't count the settle.
+func TestSettleAccountingOnRevocation(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	htlcAmt := lnwire.NewMSatFromSatoshis(20_000)
+	htlc, preimage := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimage, 0))
+
+	assertTotals := func(aliceSent, bobReceived lnwire.MilliSatoshi) {
+		t.Helper()
+
+		aliceState := aliceChannel.channelState
+		bobState := bobChannel.channelState
+
+		require.Equal(t, aliceSent, aliceState.TotalMSatSent)
+		require.Zero(t, aliceState.TotalMSatReceived)
+		require.Zero(t, bobState.TotalMSatSent)
+		require.Equal(t, bobReceived, bobState.TotalMSatReceived)
+
+		// The totals are persisted along with the revocation, so
+		// they're not lost if we go down before the next commitment.
+		for _, state := range []*channeldb.OpenChannel{
+			aliceState, bobState,
+		} {
+			dbChannels, err := state.Db.FetchOpenChannels(
+				state.IdentityPub,
+			)
+			require.NoError(t, err)
+			require.Len(t, dbChannels, 1)
+			require.Equal(
+				t, state.TotalMSatSent,
+				dbChannels[0].TotalMSatSent,
+			)
+			require.Equal(
+				t, state.TotalMSatReceived,
+				dbChannels[0].TotalMSatReceived,
+			)
+		}
+	}
+
+	// Evaluating views that include the settle doesn't count it.
+	for i := 0; i < 3; i++ {
+		aliceChannel.AvailableBalance()
+		bobChannel.AvailableBalance()
+		aliceChannel.CapacityBreakdown()
+		bobChannel.CapacityBreakdown()
+	}
+	assertTotals(0, 0)
+
+	// Bob signs the settle, and Alice accepts it and revokes her prior
+	// commitment. Alice's old commitment, which still holds the HTLC, is
+	// now revoked, so only Bob counts the settle.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertTotals(0, 0)
+
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+	assertTotals(0, htlcAmt)
+
+	// Once Bob revokes his prior commitment as well, Alice counts it.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertTotals(0, htlcAmt)
+
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	assertTotals(htlcAmt, htlcAmt)
+
+	// Further state transitions don't count the settle again.
+	require.NoError(t, aliceChannel.UpdateFee(
+		aliceChannel.CommitFeeRate()*2,
+	))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(
+		aliceChannel.CommitFeeRate()*2,
+	))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	assertTotals(htlcAmt, htlcAmt)
+}
+
+// checkExpectedHtlcs checks that a set of htlcs that we have contains all the
+// htlcs we expect.
+func checkExpectedHtlcs(t *testing.T, actual []*PaymentDescriptor,
+	expected map[uint64]bool) {
+
+	if len(expected) != len(actual) {
+		t.Fatalf("expected: %v htlcs, got: %v",
+			len(expected), len(actual))
+	}
+
+	for _, htlc := range actual {
+		_, ok := expected[htlc.HtlcIndex]
+		if !ok {
+			t.Fatalf("htlc with index: %v not "+
+				"expected in set", htlc.HtlcIndex)
+		}
+	}
+}
+
+// heights represents the heights on a payment descriptor.
+type heights struct {
+	localAdd     uint64
+	localRemove  uint64
+	remoteAdd    uint64
+	remoteRemove uint64
+}
+
+// TestProcessFeeUpdate tests the applying of fee updates and mutation of
+// local and remote add and remove heights on update messages.
+func TestProcessFeeUpdate(t *testing.T) {
+	const (
+		// height is a non-zero height that can be used for htlcs
+		// heights.
+		height = 200
+
+		// nextHeight is a constant that we use for the next height in
+		// all unit tests.
+		nextHeight = 400
+
+		// feePerKw is the fee we start all of our unit tests with.
+		feePerKw = 1
+
+		// ourFeeUpdateAmt is an amount that we update fees to expressed
+		// in msat.
+		ourFeeUpdateAmt = 20000
+
+		// ourFeeUpdatePerSat is the fee rate *in satoshis* that we
+		// expect if we update to ourFeeUpdateAmt.
+		ourFeeUpdatePerSat = chainfee.SatPerKWeight(20)
+	)
+
+	tests := []struct {
+		name            string
+		startHeights    heights
+		expectedHeights heights
+		remoteChain     bool
+		mutate          bool
+		expectedFee     chainfee.SatPerKWeight
+	}{
+		{
+			// Looking at local chain, local add is non-zero so
+			// the update has been applied already; no fee change.
+			name: "non-zero local height, fee unchanged",
+			startHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: height,
+			},
+			expectedHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: height,
+			},
+			remoteChain: false,
+			mutate:      false,
+			expectedFee: feePerKw,
+		},
+		{
+			// Looking at local chain, local add is zero so the
+			// update has not been applied yet; we expect a fee
+			// update.
+			name: "zero local height, fee changed",
+			startHeights: heights{
+				localAdd:     0,
+				localRemove:  0,
+				remoteAdd:    height,
+				remoteRemove: 0,
+			},
+			expectedHeights: heights{
+				localAdd:     0,
+				localRemove:  0,
+				remoteAdd:    height,
+				remoteRemove: 0,
+			},
+			remoteChain: false,
+			mutate:      false,
+			expectedFee: ourFeeUpdatePerSat,
+		},
+		{
+			// Looking at remote chain, the remote add height is
+			// zero, so the update has not been applied so we expect
+			// a fee change.
+			name: "zero remote height, fee changed",
+			startHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: 0,
+			},
+			expectedHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: 0,
+			},
+			remoteChain: true,
+			mutate:      false,
+			expectedFee: ourFeeUpdatePerSat,
+		},
+		{
+			// Looking at remote chain, the remote add height is
+			// non-zero, so the update has been applied so we expect
+			// no fee change.
+			name: "non-zero remote height, no fee change",
+			startHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    height,
+				remoteRemove: 0,
+			},
+			expectedHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    height,
+				remoteRemove: 0,
+			},
+			remoteChain: true,
+			mutate:      false,
+			expectedFee: feePerKw,
+		},
+		{
+			// Local add height is non-zero, so the update has
+			// already been applied; we do not expect fee to
+			// change or any mutations to be applied.
+			name: "non-zero local height, mutation not applied",
+			startHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: height,
+			},
+			expectedHeights: heights{
+				localAdd:     height,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: height,
+			},
+			remoteChain: false,
+			mutate:      true,
+			expectedFee: feePerKw,
+		},
+		{
+			// Local add is zero and we are looking at our local
+			// chain, so the update has not been applied yet. We
+			// expect the local add and remote heights to be
+			// mutated.
+			name: "zero height, fee changed, mutation applied",
+			startHeights: heights{
+				localAdd:     0,
+				localRemove:  0,
+				remoteAdd:    0,
+				remoteRemove: 0,
+			},
+			expectedHeights: heights{
+				localAdd:     nextHeight,
+				localRemove:  nextHeight,
+				remoteAdd:    0,
+				remoteRemove: 0,
+			},
+			remoteChain: false,
+			mutate:      true,
+			expectedFee: ourFeeUpdatePerSat,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			// Create a fee update with add and remove heights as
+			// set in the test.
+			heights := test.startHeights
+			update := &PaymentDescriptor{
+				Amount:                   ourFeeUpdateAmt,
+				addCommitHeightRemote:    heights.remoteAdd,
+				addCommitHeightLocal:     heights.localAdd,
+				removeCommitHeightRemote: heights.remoteRemove,
+				removeCommitHeightLocal:  heights.localRemove,
+				EntryType:                FeeUpdate,
+			}
+
+			view := &htlcView{
+				feePerKw: chainfee.SatPerKWeight(feePerKw),
+			}
+			processFeeUpdate(
+				update, nextHeight, test.remoteChain,
+				test.mutate, view,
+			)
+
+			if view.feePerKw != test.expectedFee {
+				t.Fatalf("expected fee: %v, got: %v",
+					test.expectedFee, feePerKw)
+			}
+
+			checkHeights(t, update, test.expectedHeights)
+		})
+	}
+}
+
+func checkHeights(t *testing.T, update *PaymentDescriptor, expected heights) {
+	updateHeights := heights{
+		localAdd:     update.addCommitHeightLocal,
+		localRemove:  update.removeCommitHeightLocal,
+		remoteAdd:    update.addCommitHeightRemote,
+		remoteRemove: update.removeCommitHeightRemote,
+	}
+
+	if !reflect.DeepEqual(updateHeights, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, updateHeights)
+	}
+}
+
+// TestProcessAddRemoveEntry tests the updating of our and their balances when
+// we process adds, settles and fails. It also tests the mutating of add and
+// remove heights.
+func TestProcessAddRemoveEntry(t *testing.T) {
+	const (
+		// addHeight is a non-zero addHeight that is used for htlc
+		// add heights.
+		addHeight = 100
+
+		// removeHeight is a non-zero removeHeight that is used for
+		// htlc remove heights.
+		removeHeight = 200
+
+		// nextHeight is a constant that we use for the nextHeight in
+		// all unit tests.
+		nextHeight = 400
+
+		// updateAmount is the amount that the update is set to.
+		updateAmount = lnwire.MilliSatoshi(10)
+
+		// startBalance is a balance we start both sides out with
+		// so that balances can be incremented.
+		startBalance = lnwire.MilliSatoshi(100)
+	)
+
+	tests := []struct {
+		name                 string
+		startHeights         heights
+		remoteChain          bool
+		isIncoming           bool
+		mutateState          bool
+		ourExpectedBalance   lnwire.MilliSatoshi
+		theirExpectedBalance lnwire.MilliSatoshi
+		expectedHeights      heights
+		updateType           updateType
+	}{
+		{
+			name: "add, remote chain, already processed",
+			startHeights: heights{
+				localAdd:     0,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     0,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Add,
+		},
+		{
+			name: "add, local chain, already processed",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          false,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Add,
+		},
+		{
+			name: "incoming add, local chain, not mutated",
+			startHeights: heights{
+				localAdd:     0,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          false,
+			isIncoming:           true,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance - updateAmount,
+			expectedHeights: heights{
+				localAdd:     0,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Add,
+		},
+		{
+			name: "incoming add, local chain, mutated",
+			startHeights: heights{
+				localAdd:     0,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          false,
+			isIncoming:           true,
+			mutateState:          true,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance - updateAmount,
+			expectedHeights: heights{
+				localAdd:     nextHeight,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Add,
+		},
+
+		{
+			name: "outgoing add, remote chain, not mutated",
+			startHeights: heights{
+				localAdd:     0,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance - updateAmount,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     0,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Add,
+		},
+		{
+			name: "outgoing add, remote chain, mutated",
+			startHeights: heights{
+				localAdd:     0,
+				remoteAdd:    0,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           false,
+			mutateState:          true,
+			ourExpectedBalance:   startBalance - updateAmount,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     0,
+				remoteAdd:    nextHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Add,
+		},
+		{
+			name: "settle, remote chain, already processed",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: removeHeight,
+			},
+			remoteChain:          true,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: removeHeight,
+			},
+			updateType: Settle,
+		},
+		{
+			name: "settle, local chain, already processed",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  removeHeight,
+				remoteRemove: 0,
+			},
+			remoteChain:          false,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  removeHeight,
+				remoteRemove: 0,
+			},
+			updateType: Settle,
+		},
+		{
+			// Remote chain, and not processed yet. Incoming settle,
+			// so we expect our balance to increase.
+			name: "incoming settle",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           true,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance + updateAmount,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Settle,
+		},
+		{
+			// Remote chain, and not processed yet. Incoming settle,
+			// so we expect our balance to increase.
+			name: "outgoing settle",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance + updateAmount,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Settle,
+		},
+		{
+			// Remote chain, and not processed yet. Incoming fail,
+			// so we expect their balance to increase.
+			name: "incoming fail",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           true,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance,
+			theirExpectedBalance: startBalance + updateAmount,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Fail,
+		},
+		{
+			// Remote chain, and not processed yet. Outgoing fail,
+			// so we expect our balance to increase.
+			name: "outgoing fail",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           false,
+			mutateState:          false,
+			ourExpectedBalance:   startBalance + updateAmount,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			updateType: Fail,
+		},
+		{
+			// Local chain, and not processed yet. Incoming settle,
+			// so we expect our balance to increase. Mutate is
+			// true, so we expect our remove removeHeight to have
+			// changed.
+			name: "fail, our remove height mutated",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          false,
+			isIncoming:           true,
+			mutateState:          true,
+			ourExpectedBalance:   startBalance + updateAmount,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  nextHeight,
+				remoteRemove: 0,
+			},
+			updateType: Settle,
+		},
+		{
+			// Remote chain, and not processed yet. Incoming settle,
+			// so we expect our balance to increase. Mutate is
+			// true, so we expect their remove removeHeight to have
+			// changed.
+			name: "fail, their remove height mutated",
+			startHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: 0,
+			},
+			remoteChain:          true,
+			isIncoming:           true,
+			mutateState:          true,
+			ourExpectedBalance:   startBalance + updateAmount,
+			theirExpectedBalance: startBalance,
+			expectedHeights: heights{
+				localAdd:     addHeight,
+				remoteAdd:    addHeight,
+				localRemove:  0,
+				remoteRemove: nextHeight,
+			},
+			updateType: Settle,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			heights := test.startHeights
+			update := &PaymentDescriptor{
+				Amount:                   updateAmount,
+				addCommitHeightLocal:     heights.localAdd,
+				addCommitHeightRemote:    heights.remoteAdd,
+				removeCommitHeightLocal:  heights.localRemove,
+				removeCommitHeightRemote: heights.remoteRemove,
+				EntryType:                test.updateType,
+			}
+
+			var (
+				// Start both parties off with an initial
+				// balance. Copy by value here so that we do
+				// not mutate the startBalance constant.
+				ourBalance, theirBalance = startBalance,
+					startBalance
+			)
+
+			// Choose the processing function we need based on the
+			// update type. Process remove is used for settles,
+			// fails and malformed htlcs.
+			process := processRemoveEntry
+			if test.updateType == Add {
+				process = processAddEntry
+			}
+
+			process(
+				update, &ourBalance, &theirBalance, nextHeight,
+				test.remoteChain, test.isIncoming,
+				test.mutateState,
+			)
+
+			// Check that balances were updated as expected.
+			if ourBalance != test.ourExpectedBalance {
+				t.Fatalf("expected our balance: %v, got: %v",
+					test.ourExpectedBalance, ourBalance)
+			}
+
+			if theirBalance != test.theirExpectedBalance {
+				t.Fatalf("expected their balance: %v, got: %v",
+					test.theirExpectedBalance, theirBalance)
+			}
+
+			// Check that heights on the update are as expected.
+			checkHeights(t, update, test.expectedHeights)
+		})
+	}
+}
+
+// TestChannelUnsignedAckedFailure tests that unsigned acked updates are
+// properly restored after signing for them and disconnecting.
+//
+// The full state transition of this test is:
+//
+// Alice                   Bob
+//
+//	-----add----->
+//	-----sig----->
+//	<----rev------
+//	<----sig------
+//	-----rev----->
+//	<----fail-----
+//	<----sig------
+//	-----rev----->
+//	-----sig-----X (does not reach Bob! Alice dies!)
+//
+//	-----sig----->
+//	<----rev------
+//	<----add------
+//	<----sig------
+//
+// The last sig was rejected with the old behavior of deleting unsigned
+// acked updates from the database after signing for them. The current
+// behavior of filtering them for deletion upon receiving a revocation
+// causes Alice to accept the signature as valid.
+func TestChannelUnsignedAckedFailure(t *testing.T) {
+	t.Parallel()
+
+	// Create a test channel so that we can test the buggy behavior.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	// First we create an HTLC that Alice sends to Bob.
+	htlc, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+
+	// -----add----->
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	// Force a state transition to lock in this add on both commitments.
+	// -----sig----->
+	// <----rev------
+	// <----sig------
+	// -----rev----->
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+
+	// Now Bob should fail the htlc back to Alice.
+	// <----fail-----
+	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveFailHTLC(0, []byte("bad"))
+	require.NoError(t, err)
+
+	// Bob should send a commitment signature to Alice.
+	// <----sig------
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// Alice should reply with a revocation.
+	// -----rev----->
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+
+	// Alice should sign the next commitment and go down before
+	// sending it.
+	// -----sig-----X
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+
+	newAliceChannel, err := NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool,
+	)
+	require.NoError(t, err)
+
+	// Bob receives Alice's signature.
+	// -----sig----->
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// Bob revokes his current commitment and sends a revocation
+	// to Alice.
+	// <----rev------
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = newAliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+
+	// Now Bob sends an HTLC to Alice.
+	htlc2, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+
+	// <----add------
+	_, err = bobChannel.AddHTLC(htlc2, nil)
+	require.NoError(t, err)
+	_, err = newAliceChannel.ReceiveHTLC(htlc2)
+	require.NoError(t, err)
+
+	// Bob sends the final signature to Alice and Alice should not
+	// reject it, given that we properly restore the unsigned acked
+	// updates and therefore our update log is structured correctly.
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = newAliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+}
+
+// TestChannelLocalUnsignedUpdatesFailure checks that updates from the local
+// log are restored if the remote hasn't sent us a signature covering them.
+//
+// The full state transition is:
+//
+// Alice                Bob
+//
+//	<----add-----
+//	<----sig-----
+//	-----rev---->
+//	-----sig---->
+//	<----rev-----
+//	----fail---->
+//	-----sig---->
+//	<----rev-----
+//	 *reconnect*
+//	<----sig-----
+//
+// Alice should reject the last signature since the settle is not restored
+// into the local update log and thus calculates Bob's signature as invalid.
+func TestChannelLocalUnsignedUpdatesFailure(t *testing.T) {
+	t.Parallel()
+
+	// Create a test channel so that we can test the buggy behavior.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	// First we create an htlc that Bob sends to Alice.
+	htlc, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+
+	// <----add-----
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	// Force a state transition to lock in this add on both commitments.
+	// <----sig-----
+	// -----rev---->
+	// -----sig---->
+	// <----rev-----
+	err = ForceStateTransition(bobChannel, aliceChannel)
+	require.NoError(t, err)
+
+	// Now Alice should fail the htlc back to Bob.
+	// -----fail--->
+	err = aliceChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	require.NoError(t, err)
+	err = bobChannel.ReceiveFailHTLC(0, []byte("bad"))
+	require.NoError(t, err)
+
+	// Alice should send a commitment signature to Bob.
+	// -----sig---->
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// Bob should reply with a revocation and Alice should save the fail as
+	// an unsigned local update.
+	// <----rev-----
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+
+	// Restart Alice and assert that she can receive Bob's next commitment
+	// signature.
+	// *reconnect*
+	newAliceChannel, err := NewLightningChannel(
+		aliceChannel.Signer, aliceChannel.channelState,
+		aliceChannel.sigPool,
+	)
+	require.NoError(t, err)
+
+	// Bob sends the final signature and Alice should not reject it.
+	// <----sig-----
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = newAliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+}
+
+// TestChannelSignedAckRegression tests a previously-regressing state
+// transition no longer causes channel desynchronization.
+//
+// The full state transition of this test is:
+//
+// Alice                   Bob
+//
+//	<----add-------
+//	<----sig-------
+//	-----rev------>
+//	-----sig------>
+//	<----rev-------
+//	----settle---->
+//	-----sig------>
+//	<----rev-------
+//	<----sig-------
+//	-----add------>
+//	-----sig------>
+//	<----rev-------
+//	                *restarts*
+//	-----rev------>
+//	<----sig-------
+func TestChannelSignedAckRegression(t *testing.T) {
+	t.Parallel()
+
+	// Create test channels to test out this state transition.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	// Create an HTLC that Bob will send to Alice.
+	htlc, preimage := createHTLC(0, lnwire.MilliSatoshi(5000000))
+
+	// <----add------
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	// Force a state transition to lock in the HTLC.
+	// <----sig------
+	// -----rev----->
+	// -----sig----->
+	// <----rev------
+	err = ForceStateTransition(bobChannel, aliceChannel)
+	require.NoError(t, err)
+
+	// Alice settles the HTLC back to Bob.
+	// ----settle--->
+	err = aliceChannel.SettleHTLC(preimage, uint64(0), nil, nil, nil)
+	require.NoError(t, err)
+	err = bobChannel.ReceiveHTLCSettle(preimage, uint64(0))
+	require.NoError(t, err)
+
+	// -----sig---->
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// <----rev-----
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+
+	// <----sig-----
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// Create an HTLC that Alice will send to Bob.
+	htlc2, _ := createHTLC(0, lnwire.MilliSatoshi(5000000))
+
+	// -----add---->
+	_, err = aliceChannel.AddHTLC(htlc2, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc2)
+	require.NoError(t, err)
+
+	// -----sig---->
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	// <----rev-----
+	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+
+	// Restart Bob's channel state here.
+	newBobChannel, err := NewLightningChannel(
+		bobChannel.Signer, bobChannel.channelState,
+		bobChannel.sigPool,
+	)
+	require.NoError(t, err)
+
+	// -----rev---->
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	fwdPkg, _, _, _, err := newBobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+
+	// Assert that the fwdpkg is not empty.
+	require.Equal(t, len(fwdPkg.SettleFails), 1)
+
+	// Bob should no longer fail to sign this commitment due to faulty
+	// update logs.
+	// <----sig-----
+	bobNewCommit, err = newBobChannel.SignNextCommitment()
+	require.NoError(t, err)
+
+	// Alice should receive the new commitment without hiccups.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+}
+
+// TestMayAddOutgoingHtlc tests MayAddOutgoingHtlc against zero and non-zero
+// htlc amounts.
+func TestMayAddOutgoingHtlc(t *testing.T) {
+	t.Parallel()
+
+	// The default channel created as a part of the test fixture already
+	// has a MinHTLC value of zero, so we don't need to do anything here
+	// other than create it.
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	// The channels start out with a 50/50 balance, so both sides should be
+	// able to add an outgoing HTLC with no specific amount added.
+	require.NoError(t, aliceChannel.MayAddOutgoingHtlc(0))
+	require.NoError(t, bobChannel.MayAddOutgoingHtlc(0))
+
+	chanBal, err := btcutil.NewAmount(testChannelCapacity)
+	require.NoError(t, err)
+
+	// Each side should be able to add 1/4 of total channel balance since
+	// we're 50/50 split.
+	mayAdd := lnwire.MilliSatoshi(chanBal / 4 * 1000)
+	require.NoError(t, aliceChannel.MayAddOutgoingHtlc(mayAdd))
+	require.NoError(t, bobChannel.MayAddOutgoingHtlc(mayAdd))
+
+	// Both channels should fail if we try to add an amount more than
+	// their current balance.
+	mayNotAdd := lnwire.MilliSatoshi(chanBal * 1000)
+	require.Error(t, aliceChannel.MayAddOutgoingHtlc(mayNotAdd))
+	require.Error(t, bobChannel.MayAddOutgoingHtlc(mayNotAdd))
+
+	// Hard set alice's min htlc to zero and test the case where we just
+	// fall back to a non-zero value.
+	aliceChannel.channelState.LocalChanCfg.MinHTLC = 0
+	require.NoError(t, aliceChannel.MayAddOutgoingHtlc(0))
+}
+
+// TestCanAddHTLCDustBoundary tests that CanAddHTLC takes the additional
+// commitment fee of a non-dust HTLC into account, accepting the largest dust
+// HTLC but rejecting an HTLC just above the dust limit, and that it agrees
+// with AddHTLC.
+func TestCanAddHTLCDustBoundary(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	chanType := aliceChannel.channelState.ChanType
+	aliceBalance := lnwire.NewMSatFromSatoshis(5 * btcutil.SatoshiPerBitcoin)
+	aliceReserve := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalChanCfg.ChanReserve,
+	)
+	feeRate := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw,
+	)
+	htlcFee := lnwire.NewMSatFromSatoshis(
+		feeRate.FeeForWeight(input.HTLCWeight),
+	)
+	commitFee := lnwire.NewMSatFromSatoshis(
+		aliceChannel.channelState.LocalCommitment.CommitFee,
+	)
+
+	// An HTLC offered by Alice is dust on her own commitment if it can't
+	// pay for the timeout transaction, and dust on Bob's if it can't pay
+	// for the success transaction. The largest HTLC that doesn't add any
+	// weight to either commitment is the smaller of the two.
+	localDustMax := aliceChannel.channelState.LocalChanCfg.DustLimit +
+		HtlcTimeoutFee(chanType, feeRate) - 1
+	remoteDustMax := aliceChannel.channelState.RemoteChanCfg.DustLimit +
+		HtlcSuccessFee(chanType, feeRate) - 1
+	maxDust := localDustMax
+	if remoteDustMax < maxDust {
+		maxDust = remoteDustMax
+	}
+	maxDustMsat := lnwire.NewMSatFromSatoshis(maxDust)
+
+	// Drain Alice's balance so that she has exactly enough left for the
+	// largest dust HTLC plus the fee of an additional HTLC output.
+	htlcAmt := aliceBalance - (commitFee + aliceReserve + htlcFee +
+		maxDustMsat)
+	htlc, preimage := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	require.NoError(t, bobChannel.SettleHTLC(preimage, 0, nil, nil, nil))
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimage, 0))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// The largest dust HTLC doesn't require any additional commitment
+	// fee, so it should be accepted.
+	require.NoError(t, aliceChannel.CanAddHTLC(maxDustMsat))
+
+	// An HTLC just above the dust limit would need to pay for its own
+	// output, which Alice can't afford without dipping below her
+	// reserve.
+	nonDustMsat := lnwire.NewMSatFromSatoshis(maxDust + 1)
+	err = aliceChannel.CanAddHTLC(nonDustMsat)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	// The predicate shouldn't have modified the update log.
+	require.EqualValues(t, 1, aliceChannel.localUpdateLog.htlcCounter)
+
+	// AddHTLC should come to the same conclusions.
+	htlc, _ = createHTLC(1, nonDustMsat)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.ErrorIs(t, err, ErrBelowChanReserve)
+
+	htlc, _ = createHTLC(1, maxDustMsat)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+}
+
+// TestCanAddHTLCMaxHTLCNumber tests that CanAddHTLC rejects an HTLC that
+// would exceed the maximum number of accepted HTLCs, and that zero amounts are
+// rejected.
+func TestCanAddHTLCMaxHTLCNumber(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	const numHTLCs = 5
+	aliceChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+	aliceChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+	bobChannel.channelState.LocalChanCfg.MaxAcceptedHtlcs = numHTLCs
+	bobChannel.channelState.RemoteChanCfg.MaxAcceptedHtlcs = numHTLCs
+
+	htlcAmt := lnwire.NewMSatFromSatoshis(0.1 * btcutil.SatoshiPerBitcoin)
+
+	require.ErrorIs(t, aliceChannel.CanAddHTLC(0), ErrInvalidHTLCAmt)
+
+	// Up until the limit is reached, every HTLC should be accepted.
+	for i := 0; i < numHTLCs; i++ {
+		require.NoError(t, aliceChannel.CanAddHTLC(htlcAmt))
+
+		htlc, _ := createHTLC(i, htlcAmt)
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+
+	// The next HTLC would exceed the limit.
+	require.ErrorIs(t, aliceChannel.CanAddHTLC(htlcAmt), ErrMaxHTLCNumber)
+
+	// Locking in the HTLCs shouldn't change this.
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	require.ErrorIs(t, aliceChannel.CanAddHTLC(htlcAmt), ErrMaxHTLCNumber)
+}
+
+// TestIsChannelClean tests that IsChannelClean returns the expected values
+// in different channel states.
+func TestIsChannelClean(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.ZeroHtlcTxFeeBit,
+	)
+	require.NoError(t, err)
+
+	// Channel state should be clean at the start of the test.
+	assertCleanOrDirty(true, aliceChannel, bobChannel, t)
+
+	// Assert that neither side considers the channel clean when alice
+	// sends an htlc.
+	// ---add--->
+	htlc, preimage := createHTLC(0, lnwire.MilliSatoshi(5000000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// Assert that the channel remains dirty until the HTLC is completely
+	// removed from both commitments.
+
+	// ---sig--->
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <---rev---
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <---sig---
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// ---rev--->
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <--settle--
+	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveHTLCSettle(preimage, 0)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <---sig---
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// ---rev--->
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// ---sig--->
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <---rev---
+	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	assertCleanOrDirty(true, aliceChannel, bobChannel, t)
 
-		// expectReceived is the amount we expect the channel to have
-		// tracked as our receive total.
-		expectReceived lnwire.MilliSatoshi
+	// Now we check that update_fee is handled and state is dirty until it
+	// is completely locked in.
+	// ---fee--->
+	fee := chainfee.SatPerKWeight(333)
+	err = aliceChannel.UpdateFee(fee)
+	require.NoError(t, err)
+	err = bobChannel.ReceiveUpdateFee(fee)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-		// expectSent is the amount we expect the channel to have
-		// tracked as our send total.
-		expectSent lnwire.MilliSatoshi
-	}{
-		{
-			name:        "our fee update is applied",
-			remoteChain: false,
-			mutateState: false,
-			ourHtlcs: []*PaymentDescriptor{
-				{
-					Amount:    ourFeeUpdateAmt,
-					EntryType: FeeUpdate,
-				},
-			},
-			theirHtlcs:         nil,
-			expectedFee:        ourFeeUpdatePerSat,
-			ourExpectedHtlcs:   nil,
-			theirExpectedHtlcs: nil,
-			expectReceived:     0,
-			expectSent:         0,
-		},
-		{
-			name:        "their fee update is applied",
-			remoteChain: false,
-			mutateState: false,
-			ourHtlcs:    []*PaymentDescriptor{},
-			theirHtlcs: []*PaymentDescriptor{
-				{
-					Amount:    theirFeeUpdateAmt,
-					EntryType: FeeUpdate,
-				},
-			},
-			expectedFee:        theirFeeUpdatePerSat,
-			ourExpectedHtlcs:   nil,
-			theirExpectedHtlcs: nil,
-			expectReceived:     0,
-			expectSent:         0,
-		},
-		{
-			// We expect unresolved htlcs to to remain in the view.
-			name:        "htlcs adds without settles",
-			remoteChain: false,
-			mutateState: false,
-			ourHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex: 0,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-			},
-			theirHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex: 0,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-				{
-					HtlcIndex: 1,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-			},
-			expectedFee: feePerKw,
-			ourExpectedHtlcs: map[uint64]bool{
-				0: true,
-			},
-			theirExpectedHtlcs: map[uint64]bool{
-				0: true,
-				1: true,
-			},
-			expectReceived: 0,
-			expectSent:     0,
-		},
-		{
-			name:        "our htlc settled, state mutated",
-			remoteChain: false,
-			mutateState: true,
-			ourHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex:            0,
-					Amount:               htlcAddAmount,
-					EntryType:            Add,
-					addCommitHeightLocal: addHeight,
-				},
-			},
-			theirHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex: 0,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-				{
-					HtlcIndex: 1,
-					Amount:    htlcAddAmount,
-					EntryType: Settle,
-					// Map their htlc settle update to our
-					// htlc add (0).
-					ParentIndex: 0,
-				},
-			},
-			expectedFee:      feePerKw,
-			ourExpectedHtlcs: nil,
-			theirExpectedHtlcs: map[uint64]bool{
-				0: true,
-			},
-			expectReceived: 0,
-			expectSent:     htlcAddAmount,
-		},
-		{
-			name:        "our htlc settled, state not mutated",
-			remoteChain: false,
-			mutateState: false,
-			ourHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex:            0,
-					Amount:               htlcAddAmount,
-					EntryType:            Add,
-					addCommitHeightLocal: addHeight,
-				},
-			},
-			theirHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex: 0,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-				{
-					HtlcIndex: 1,
-					Amount:    htlcAddAmount,
-					EntryType: Settle,
-					// Map their htlc settle update to our
-					// htlc add (0).
-					ParentIndex: 0,
-				},
-			},
-			expectedFee:      feePerKw,
-			ourExpectedHtlcs: nil,
-			theirExpectedHtlcs: map[uint64]bool{
-				0: true,
-			},
-			expectReceived: 0,
-			expectSent:     0,
-		},
-		{
-			name:        "their htlc settled, state mutated",
-			remoteChain: false,
-			mutateState: true,
-			ourHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex: 0,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-				{
-					HtlcIndex: 1,
-					Amount:    htlcAddAmount,
-					EntryType: Settle,
-					// Map our htlc settle update to their
-					// htlc add (1).
-					ParentIndex: 1,
-				},
-			},
-			theirHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex:            0,
-					Amount:               htlcAddAmount,
-					EntryType:            Add,
-					addCommitHeightLocal: addHeight,
-				},
-				{
-					HtlcIndex:            1,
-					Amount:               htlcAddAmount,
-					EntryType:            Add,
-					addCommitHeightLocal: addHeight,
-				},
-			},
-			expectedFee: feePerKw,
-			ourExpectedHtlcs: map[uint64]bool{
-				0: true,
-			},
-			theirExpectedHtlcs: map[uint64]bool{
-				0: true,
-			},
-			expectReceived: htlcAddAmount,
-			expectSent:     0,
-		},
-		{
-			name:        "their htlc settled, state not mutated",
-			remoteChain: false,
-			mutateState: false,
-			ourHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex: 0,
-					Amount:    htlcAddAmount,
-					EntryType: Add,
-				},
-				{
-					HtlcIndex: 1,
-					Amount:    htlcAddAmount,
-					EntryType: Settle,
-					// Map our htlc settle update to their
-					// htlc add (0).
-					ParentIndex: 0,
-				},
-			},
-			theirHtlcs: []*PaymentDescriptor{
-				{
-					HtlcIndex:            0,
-					Amount:               htlcAddAmount,
-					EntryType:            Add,
-					addCommitHeightLocal: addHeight,
-				},
-			},
-			expectedFee: feePerKw,
-			ourExpectedHtlcs: map[uint64]bool{
-				0: true,
-			},
-			theirExpectedHtlcs: nil,
-			expectReceived:     0,
-			expectSent:         0,
-		},
+	// ---sig--->
+	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <---rev---
+	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// <---sig---
+	bobNewCommit, err = bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+
+	// The state should finally be clean after alice sends her revocation.
+	// ---rev--->
+	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+	assertCleanOrDirty(true, aliceChannel, bobChannel, t)
+}
+
+// assertCleanOrDirty is a helper function that asserts that both channels are
+// clean if clean is true, and dirty if clean is false.
+func assertCleanOrDirty(clean bool, alice, bob *LightningChannel,
+	t *testing.T) {
+
+	t.Helper()
+
+	if clean {
+		require.True(t, alice.IsChannelClean())
+		require.True(t, bob.IsChannelClean())
+		return
 	}
 
-	for _, test := range tests {
-		test := test
+	require.False(t, alice.IsChannelClean())
+	require.False(t, bob.IsChannelClean())
+}
 
-		t.Run(test.name, func(t *testing.T) {
-			lc := LightningChannel{
-				channelState: &channeldb.OpenChannel{
-					TotalMSatSent:     0,
-					TotalMSatReceived: 0,
-				},
+// TestChannelGetDustSum tests that we correctly calculate the channel's dust
+// sum for the local and remote commitments.
+func TestChannelGetDustSum(t *testing.T) {
+	t.Run("dust sum tweakless", func(t *testing.T) {
+		testGetDustSum(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("dust sum anchors zero htlc fee", func(t *testing.T) {
+		testGetDustSum(t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit|
+			channeldb.ZeroHtlcTxFeeBit,
+		)
+	})
+}
 
-				// Create update logs for local and remote.
-				localUpdateLog:  newUpdateLog(0, 0),
-				remoteUpdateLog: newUpdateLog(0, 0),
-			}
+func testGetDustSum(t *testing.T, chantype channeldb.ChannelType) {
+	t.Parallel()
 
-			for _, htlc := range test.ourHtlcs {
-				if htlc.EntryType == Add {
-					lc.localUpdateLog.appendHtlc(htlc)
-				} else {
-					lc.localUpdateLog.appendUpdate(htlc)
-				}
-			}
+	// This makes a channel with Alice's dust limit set to 200sats and
+	// Bob's dust limit set to 1300sats.
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chantype)
+	require.NoError(t, err)
 
-			for _, htlc := range test.theirHtlcs {
-				if htlc.EntryType == Add {
-					lc.remoteUpdateLog.appendHtlc(htlc)
-				} else {
-					lc.remoteUpdateLog.appendUpdate(htlc)
-				}
-			}
+	// Use a function closure to assert the dust sum for a passed channel's
+	// local and remote commitments match the expected values.
+	checkDust := func(c *LightningChannel, expLocal,
+		expRemote lnwire.MilliSatoshi) {
 
-			view := &htlcView{
-				ourUpdates:   test.ourHtlcs,
-				theirUpdates: test.theirHtlcs,
-				feePerKw:     feePerKw,
-			}
+		localDustSum := c.GetDustSum(false)
+		require.Equal(t, expLocal, localDustSum)
+		remoteDustSum := c.GetDustSum(true)
+		require.Equal(t, expRemote, remoteDustSum)
+	}
 
-			var (
-				// Create vars to store balance changes. We do
-				// not check these values in this test because
-				// balance modification happens on the htlc
-				// processing level.
-				ourBalance   lnwire.MilliSatoshi
-				theirBalance lnwire.MilliSatoshi
-			)
+	// We'll lower the fee from 6000sats/kWU to 253sats/kWU for our test.
+	fee := chainfee.SatPerKWeight(253)
+	err = aliceChannel.UpdateFee(fee)
+	require.NoError(t, err)
+	err = bobChannel.ReceiveUpdateFee(fee)
+	require.NoError(t, err)
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
 
-			// Evaluate the htlc view, mutate as test expects.
-			result, err := lc.evaluateHTLCView(
-				view, &ourBalance, &theirBalance, nextHeight,
-				test.remoteChain, test.mutateState,
-			)
-			if err != nil {
-				t.Fatalf("unexpected error: %v", err)
-			}
+	// Create an HTLC that Bob will send to Alice which is above Alice's
+	// dust limit and below Bob's dust limit. This takes into account dust
+	// trimming for non-zero-fee channels.
+	htlc1Amt := lnwire.MilliSatoshi(700_000)
+	htlc1, preimage1 := createHTLC(0, htlc1Amt)
 
-			if result.feePerKw != test.expectedFee {
-				t.Fatalf("expected fee: %v, got: %v",
-					test.expectedFee, result.feePerKw)
-			}
+	_, err = bobChannel.AddHTLC(htlc1, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc1)
+	require.NoError(t, err)
 
-			checkExpectedHtlcs(
-				t, result.ourUpdates, test.ourExpectedHtlcs,
-			)
+	// Assert that GetDustSum from Alice's perspective does not consider
+	// the HTLC dust on her commitment, but does on Bob's commitment.
+	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
 
-			checkExpectedHtlcs(
-				t, result.theirUpdates, test.theirExpectedHtlcs,
-			)
+	// Assert that GetDustSum from Bob's perspective results in the same
+	// conditions above holding.
+	checkDust(bobChannel, htlc1Amt, lnwire.MilliSatoshi(0))
 
-			if lc.channelState.TotalMSatSent != test.expectSent {
-				t.Fatalf("expected sent: %v, got: %v",
-					test.expectSent,
-					lc.channelState.TotalMSatSent)
-			}
+	// Forcing a state transition to occur should not change the dust sum.
+	err = ForceStateTransition(bobChannel, aliceChannel)
+	require.NoError(t, err)
+	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
+	checkDust(bobChannel, htlc1Amt, lnwire.MilliSatoshi(0))
+
+	// Settling the HTLC back from Alice to Bob should not change the dust
+	// sum because the HTLC is counted until it's removed from the update
+	// logs via compactLogs.
+	err = aliceChannel.SettleHTLC(preimage1, uint64(0), nil, nil, nil)
+	require.NoError(t, err)
+	err = bobChannel.ReceiveHTLCSettle(preimage1, uint64(0))
+	require.NoError(t, err)
+	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
+	checkDust(bobChannel, htlc1Amt, lnwire.MilliSatoshi(0))
 
-			if lc.channelState.TotalMSatReceived !=
-				test.expectReceived {
+	// Forcing a state transition will remove the HTLC in-memory for Bob
+	// since ReceiveRevocation is called which calls compactLogs. Bob
+	// should have a zero dust sum at this point. Alice will see Bob as
+	// having the original dust sum since compactLogs hasn't been called.
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
+	checkDust(bobChannel, lnwire.MilliSatoshi(0), lnwire.MilliSatoshi(0))
 
-				t.Fatalf("expected received: %v, got: %v",
-					test.expectReceived,
-					lc.channelState.TotalMSatReceived)
-			}
-		})
-	}
-}
+	// Alice now sends an HTLC of 100sats, which is below both sides' dust
+	// limits.
+	htlc2Amt := lnwire.MilliSatoshi(100_000)
+	htlc2, _ := createHTLC(0, htlc2Amt)
 
-// checkExpectedHtlcs checks that a set of htlcs that we have contains all the
-// htlcs we expect.
-func checkExpectedHtlcs(t *testing.T, actual []*PaymentDescriptor,
-	expected map[uint64]bool) {
+	_, err = aliceChannel.AddHTLC(htlc2, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc2)
+	require.NoError(t, err)
 
-	if len(expected) != len(actual) {
-		t.Fatalf("expected: %v htlcs, got: %v",
-			len(expected), len(actual))
-	}
+	// Assert that GetDustSum from Alice's perspective includes the new
+	// HTLC as dust on both commitments.
+	checkDust(aliceChannel, htlc2Amt, htlc1Amt+htlc2Amt)
 
-	for _, htlc := range actual {
-		_, ok := expected[htlc.HtlcIndex]
-		if !ok {
-			t.Fatalf("htlc with index: %v not "+
-				"expected in set", htlc.HtlcIndex)
-		}
-	}
-}
+	// Assert that GetDustSum from Bob's perspective also includes the HTLC
+	// on both commitments.
+	checkDust(bobChannel, htlc2Amt, htlc2Amt)
 
-// heights represents the heights on a payment descriptor.
-type heights struct {
-	localAdd     uint64
-	localRemove  uint64
-	remoteAdd    uint64
-	remoteRemove uint64
+	// Alice signs for this HTLC and neither perspective should change.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	checkDust(aliceChannel, htlc2Amt, htlc1Amt+htlc2Amt)
+	checkDust(bobChannel, htlc2Amt, htlc2Amt)
+
+	// Bob now sends a revocation for his prior commitment, and this should
+	// change Alice's perspective to no longer include the first HTLC as
+	// dust.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	checkDust(aliceChannel, htlc2Amt, htlc2Amt)
+	checkDust(bobChannel, htlc2Amt, htlc2Amt)
+
+	// The rest of the dance is completed and neither perspective should
+	// change.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+	checkDust(aliceChannel, htlc2Amt, htlc2Amt)
+	checkDust(bobChannel, htlc2Amt, htlc2Amt)
+
+	// We'll now assert that if Alice sends an HTLC above her dust limit
+	// and then updates the fee of the channel to trigger the trimmed to
+	// dust mechanism, Alice will count this HTLC in the dust sum for her
+	// commitment in the non-zero-fee case.
+	htlc3Amt := lnwire.MilliSatoshi(400_000)
+	htlc3, _ := createHTLC(1, htlc3Amt)
+
+	_, err = aliceChannel.AddHTLC(htlc3, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc3)
+	require.NoError(t, err)
+
+	// Assert that this new HTLC is not counted on Alice's local commitment
+	// in the dust sum. Bob's commitment should count it.
+	checkDust(aliceChannel, htlc2Amt, htlc2Amt+htlc3Amt)
+	checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt)
+
+	// Alice will now send UpdateFee with a large feerate and neither
+	// perspective should change.
+	fee = chainfee.SatPerKWeight(50_000)
+	err = aliceChannel.UpdateFee(fee)
+	require.NoError(t, err)
+	err = bobChannel.ReceiveUpdateFee(fee)
+	require.NoError(t, err)
+	checkDust(aliceChannel, htlc2Amt, htlc2Amt+htlc3Amt)
+	checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt)
+
+	// Forcing a state transition should change in the non-zero-fee case.
+	err = ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+	if chantype.ZeroHtlcTxFee() {
+		checkDust(aliceChannel, htlc2Amt, htlc2Amt+htlc3Amt)
+		checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt)
+	} else {
+		checkDust(aliceChannel, htlc2Amt+htlc3Amt, htlc2Amt+htlc3Amt)
+		checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt+htlc3Amt)
+	}
 }
 
-// TestProcessFeeUpdate tests the applying of fee updates and mutation of
-// local and remote add and remove heights on update messages.
-func TestProcessFeeUpdate(t *testing.T) {
-	const (
-		// height is a non-zero height that can be used for htlcs
-		// heights.
-		height = 200
+// deriveDummyRetributionParams is a helper function that derives a list of
+// dummy params to assist retribution creation related tests.
+func deriveDummyRetributionParams(chanState *channeldb.OpenChannel) (uint32,
+	*CommitmentKeyRing, chainhash.Hash) {
 
-		// nextHeight is a constant that we use for the next height in
-		// all unit tests.
-		nextHeight = 400
+	config := chanState.RemoteChanCfg
+	commitHash := chanState.RemoteCommitment.CommitTx.TxHash()
+	keyRing := DeriveCommitmentKeys(
+		config.RevocationBasePoint.PubKey, false, chanState.ChanType,
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+	)
+	leaseExpiry := chanState.ThawHeight
+	return leaseExpiry, keyRing, commitHash
+}
 
-		// feePerKw is the fee we start all of our unit tests with.
-		feePerKw = 1
+// TestCreateHtlcRetribution checks that `createHtlcRetribution` behaves as
+// epxected.
+func TestCreateHtlcRetribution(t *testing.T) {
+	t.Parallel()
 
-		// ourFeeUpdateAmt is an amount that we update fees to expressed
-		// in msat.
-		ourFeeUpdateAmt = 20000
+	// Create a dummy private key and an HTLC amount for testing.
+	dummyPrivate, _ := btcec.PrivKeyFromBytes([]byte{1})
+	testAmt := btcutil.Amount(100)
 
-		// ourFeeUpdatePerSat is the fee rate *in satoshis* that we
-		// expect if we update to ourFeeUpdateAmt.
-		ourFeeUpdatePerSat = chainfee.SatPerKWeight(20)
+	// Create a test channel.
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.ZeroHtlcTxFeeBit,
 	)
+	require.NoError(t, err)
 
-	tests := []struct {
-		name            string
-		startHeights    heights
-		expectedHeights heights
-		remoteChain     bool
-		mutate          bool
-		expectedFee     chainfee.SatPerKWeight
-	}{
-		{
-			// Looking at local chain, local add is non-zero so
-			// the update has been applied already; no fee change.
-			name: "non-zero local height, fee unchanged",
-			startHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: height,
-			},
-			expectedHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: height,
-			},
-			remoteChain: false,
-			mutate:      false,
-			expectedFee: feePerKw,
-		},
-		{
-			// Looking at local chain, local add is zero so the
-			// update has not been applied yet; we expect a fee
-			// update.
-			name: "zero local height, fee changed",
-			startHeights: heights{
-				localAdd:     0,
-				localRemove:  0,
-				remoteAdd:    height,
-				remoteRemove: 0,
-			},
-			expectedHeights: heights{
-				localAdd:     0,
-				localRemove:  0,
-				remoteAdd:    height,
-				remoteRemove: 0,
-			},
-			remoteChain: false,
-			mutate:      false,
-			expectedFee: ourFeeUpdatePerSat,
-		},
-		{
-			// Looking at remote chain, the remote add height is
-			// zero, so the update has not been applied so we expect
-			// a fee change.
-			name: "zero remote height, fee changed",
-			startHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: 0,
-			},
-			expectedHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: 0,
-			},
-			remoteChain: true,
-			mutate:      false,
-			expectedFee: ourFeeUpdatePerSat,
-		},
-		{
-			// Looking at remote chain, the remote add height is
-			// non-zero, so the update has been applied so we expect
-			// no fee change.
-			name: "non-zero remote height, no fee change",
-			startHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    height,
-				remoteRemove: 0,
-			},
-			expectedHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    height,
-				remoteRemove: 0,
-			},
-			remoteChain: true,
-			mutate:      false,
-			expectedFee: feePerKw,
-		},
-		{
-			// Local add height is non-zero, so the update has
-			// already been applied; we do not expect fee to
-			// change or any mutations to be applied.
-			name: "non-zero local height, mutation not applied",
-			startHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: height,
-			},
-			expectedHeights: heights{
-				localAdd:     height,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: height,
-			},
-			remoteChain: false,
-			mutate:      true,
-			expectedFee: feePerKw,
-		},
-		{
-			// Local add is zero and we are looking at our local
-			// chain, so the update has not been applied yet. We
-			// expect the local add and remote heights to be
-			// mutated.
-			name: "zero height, fee changed, mutation applied",
-			startHeights: heights{
-				localAdd:     0,
-				localRemove:  0,
-				remoteAdd:    0,
-				remoteRemove: 0,
-			},
-			expectedHeights: heights{
-				localAdd:     nextHeight,
-				localRemove:  nextHeight,
-				remoteAdd:    0,
-				remoteRemove: 0,
-			},
-			remoteChain: false,
-			mutate:      true,
-			expectedFee: ourFeeUpdatePerSat,
-		},
+	// Prepare the params needed to call the function. Note that the values
+	// here are not necessary "cryptography-correct", we just use them to
+	// construct the htlc retribution.
+	leaseExpiry, keyRing, commitHash := deriveDummyRetributionParams(
+		aliceChannel.channelState,
+	)
+	htlc := &channeldb.HTLCEntry{
+		Amt:         testAmt,
+		Incoming:    true,
+		OutputIndex: 1,
 	}
 
-	for _, test := range tests {
-		test := test
+	// Create the htlc retribution.
+	hr, err := createHtlcRetribution(
+		aliceChannel.channelState, keyRing, commitHash,
+		dummyPrivate, leaseExpiry, htlc,
+	)
+	// Expect no error.
+	require.NoError(t, err)
 
-		t.Run(test.name, func(t *testing.T) {
-			// Create a fee update with add and remove heights as
-			// set in the test.
-			heights := test.startHeights
-			update := &PaymentDescriptor{
-				Amount:                   ourFeeUpdateAmt,
-				addCommitHeightRemote:    heights.remoteAdd,
-				addCommitHeightLocal:     heights.localAdd,
-				removeCommitHeightRemote: heights.remoteRemove,
-				removeCommitHeightLocal:  heights.localRemove,
-				EntryType:                FeeUpdate,
-			}
+	// Check the fields have expected values.
+	require.EqualValues(t, testAmt, hr.SignDesc.Output.Value)
+	require.Equal(t, commitHash, hr.OutPoint.Hash)
+	require.EqualValues(t, htlc.OutputIndex, hr.OutPoint.Index)
+	require.Equal(t, htlc.Incoming, hr.IsIncoming)
+}
 
-			view := &htlcView{
-				feePerKw: chainfee.SatPerKWeight(feePerKw),
-			}
-			processFeeUpdate(
-				update, nextHeight, test.remoteChain,
-				test.mutate, view,
-			)
+// TestCreateBreachRetribution checks that `createBreachRetribution` behaves as
+// expected.
+func TestCreateBreachRetribution(t *testing.T) {
+	t.Parallel()
 
-			if view.feePerKw != test.expectedFee {
-				t.Fatalf("expected fee: %v, got: %v",
-					test.expectedFee, feePerKw)
-			}
+	// Create dummy values for the test.
+	dummyPrivate, _ := btcec.PrivKeyFromBytes([]byte{1})
+	testAmt := int64(100)
+	ourAmt := int64(1000)
+	theirAmt := int64(2000)
+	localIndex := uint32(0)
+	remoteIndex := uint32(1)
+	htlcIndex := uint32(2)
 
-			checkHeights(t, update, test.expectedHeights)
-		})
+	// Create a dummy breach tx, which has our output located at index 0
+	// and theirs at 1.
+	spendTx := &wire.MsgTx{
+		TxOut: []*wire.TxOut{
+			{Value: ourAmt},
+			{Value: theirAmt},
+			{Value: testAmt},
+		},
 	}
-}
 
-func checkHeights(t *testing.T, update *PaymentDescriptor, expected heights) {
-	updateHeights := heights{
-		localAdd:     update.addCommitHeightLocal,
-		localRemove:  update.removeCommitHeightLocal,
-		remoteAdd:    update.addCommitHeightRemote,
-		remoteRemove: update.removeCommitHeightRemote,
+	// Create a test channel.
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.ZeroHtlcTxFeeBit,
+	)
+	require.NoError(t, err)
+
+	// Prepare the params needed to call the function. Note that the values
+	// here are not necessary "cryptography-correct", we just use them to
+	// construct the retribution.
+	leaseExpiry, keyRing, commitHash := deriveDummyRetributionParams(
+		aliceChannel.channelState,
+	)
+	htlc := &channeldb.HTLCEntry{
+		Amt:         btcutil.Amount(testAmt),
+		Incoming:    true,
+		OutputIndex: uint16(htlcIndex),
 	}
 
-	if !reflect.DeepEqual(updateHeights, expected) {
-		t.Fatalf("expected: %v, got: %v", expected, updateHeights)
+	// Create a dummy revocation log.
+	ourAmtMsat := lnwire.MilliSatoshi(ourAmt * 1000)
+	theirAmtMsat := lnwire.MilliSatoshi(theirAmt * 1000)
+	revokedLog := channeldb.RevocationLog{
+		CommitTxHash:     commitHash,
+		OurOutputIndex:   uint16(localIndex),
+		TheirOutputIndex: uint16(remoteIndex),
+		HTLCEntries:      []*channeldb.HTLCEntry{htlc},
+		TheirBalance:     &theirAmtMsat,
+		OurBalance:       &ourAmtMsat,
 	}
-}
-
-// TestProcessAddRemoveEntry tests the updating of our and their balances when
-// we process adds, settles and fails. It also tests the mutating of add and
-// remove heights.
-func TestProcessAddRemoveEntry(t *testing.T) {
-	const (
-		// addHeight is a non-zero addHeight that is used for htlc
-		// add heights.
-		addHeight = 100
-
-		// removeHeight is a non-zero removeHeight that is used for
-		// htlc remove heights.
-		removeHeight = 200
-
-		// nextHeight is a constant that we use for the nextHeight in
-		// all unit tests.
-		nextHeight = 400
 
-		// updateAmount is the amount that the update is set to.
-		updateAmount = lnwire.MilliSatoshi(10)
+	// Create a log with an empty local output index.
+	revokedLogNoLocal := revokedLog
+	revokedLogNoLocal.OurOutputIndex = channeldb.OutputIndexEmpty
 
-		// startBalance is a balance we start both sides out with
-		// so that balances can be incremented.
-		startBalance = lnwire.MilliSatoshi(100)
-	)
+	// Create a log with an empty remote output index.
+	revokedLogNoRemote := revokedLog
+	revokedLogNoRemote.TheirOutputIndex = channeldb.OutputIndexEmpty
 
-	tests := []struct {
-		name                 string
-		startHeights         heights
-		remoteChain          bool
-		isIncoming           bool
-		mutateState          bool
-		ourExpectedBalance   lnwire.MilliSatoshi
-		theirExpectedBalance lnwire.MilliSatoshi
-		expectedHeights      heights
-		updateType           updateType
+	testCases := []struct {
+		name             string
+		revocationLog    *channeldb.RevocationLog
+		expectedErr      error
+		expectedOurAmt   int64
+		expectedTheirAmt int64
+		noSpendTx        bool
 	}{
 		{
-			name: "add, remote chain, already processed",
-			startHeights: heights{
-				localAdd:     0,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     0,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Add,
-		},
-		{
-			name: "add, local chain, already processed",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          false,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Add,
-		},
-		{
-			name: "incoming add, local chain, not mutated",
-			startHeights: heights{
-				localAdd:     0,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          false,
-			isIncoming:           true,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance - updateAmount,
-			expectedHeights: heights{
-				localAdd:     0,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Add,
-		},
-		{
-			name: "incoming add, local chain, mutated",
-			startHeights: heights{
-				localAdd:     0,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          false,
-			isIncoming:           true,
-			mutateState:          true,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance - updateAmount,
-			expectedHeights: heights{
-				localAdd:     nextHeight,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Add,
-		},
-
-		{
-			name: "outgoing add, remote chain, not mutated",
-			startHeights: heights{
-				localAdd:     0,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance - updateAmount,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     0,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Add,
-		},
-		{
-			name: "outgoing add, remote chain, mutated",
-			startHeights: heights{
-				localAdd:     0,
-				remoteAdd:    0,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           false,
-			mutateState:          true,
-			ourExpectedBalance:   startBalance - updateAmount,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     0,
-				remoteAdd:    nextHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Add,
-		},
-		{
-			name: "settle, remote chain, already processed",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: removeHeight,
-			},
-			remoteChain:          true,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: removeHeight,
-			},
-			updateType: Settle,
-		},
-		{
-			name: "settle, local chain, already processed",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  removeHeight,
-				remoteRemove: 0,
-			},
-			remoteChain:          false,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  removeHeight,
-				remoteRemove: 0,
-			},
-			updateType: Settle,
+			name: "create retribution successfully " +
+				"with spend tx",
+			revocationLog:    &revokedLog,
+			expectedErr:      nil,
+			expectedOurAmt:   ourAmt,
+			expectedTheirAmt: theirAmt,
 		},
 		{
-			// Remote chain, and not processed yet. Incoming settle,
-			// so we expect our balance to increase.
-			name: "incoming settle",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           true,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance + updateAmount,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Settle,
+			name: "create retribution successfully " +
+				"without spend tx",
+			revocationLog:    &revokedLog,
+			expectedErr:      nil,
+			expectedOurAmt:   ourAmt,
+			expectedTheirAmt: theirAmt,
+			noSpendTx:        true,
 		},
 		{
-			// Remote chain, and not processed yet. Incoming settle,
-			// so we expect our balance to increase.
-			name: "outgoing settle",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance + updateAmount,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
+			name: "fail due to our index too big",
+			revocationLog: &channeldb.RevocationLog{
+				OurOutputIndex: uint16(htlcIndex + 1),
 			},
-			updateType: Settle,
+			expectedErr: ErrOutputIndexOutOfRange,
 		},
 		{
-			// Remote chain, and not processed yet. Incoming fail,
-			// so we expect their balance to increase.
-			name: "incoming fail",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           true,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance,
-			theirExpectedBalance: startBalance + updateAmount,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
+			name: "fail due to their index too big",
+			revocationLog: &channeldb.RevocationLog{
+				TheirOutputIndex: uint16(htlcIndex + 1),
 			},
-			updateType: Fail,
+			expectedErr: ErrOutputIndexOutOfRange,
 		},
 		{
-			// Remote chain, and not processed yet. Outgoing fail,
-			// so we expect our balance to increase.
-			name: "outgoing fail",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           false,
-			mutateState:          false,
-			ourExpectedBalance:   startBalance + updateAmount,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			updateType: Fail,
+			name: "empty local output index with spend " +
+				"tx",
+			revocationLog:    &revokedLogNoLocal,
+			expectedErr:      nil,
+			expectedOurAmt:   0,
+			expectedTheirAmt: theirAmt,
 		},
 		{
-			// Local chain, and not processed yet. Incoming settle,
-			// so we expect our balance to increase. Mutate is
-			// true, so we expect our remove removeHeight to have
-			// changed.
-			name: "fail, our remove height mutated",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          false,
-			isIncoming:           true,
-			mutateState:          true,
-			ourExpectedBalance:   startBalance + updateAmount,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  nextHeight,
-				remoteRemove: 0,
-			},
-			updateType: Settle,
+			name: "empty local output index without spend " +
+				"tx",
+			revocationLog:    &revokedLogNoLocal,
+			expectedErr:      nil,
+			expectedOurAmt:   0,
+			expectedTheirAmt: theirAmt,
+			noSpendTx:        true,
 		},
 		{
-			// Remote chain, and not processed yet. Incoming settle,
-			// so we expect our balance to increase. Mutate is
-			// true, so we expect their remove removeHeight to have
-			// changed.
-			name: "fail, their remove height mutated",
-			startHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: 0,
-			},
-			remoteChain:          true,
-			isIncoming:           true,
-			mutateState:          true,
-			ourExpectedBalance:   startBalance + updateAmount,
-			theirExpectedBalance: startBalance,
-			expectedHeights: heights{
-				localAdd:     addHeight,
-				remoteAdd:    addHeight,
-				localRemove:  0,
-				remoteRemove: nextHeight,
-			},
-			updateType: Settle,
+			name: "empty remote output index with spend " +
+				"tx",
+			revocationLog:    &revokedLogNoRemote,
+			expectedErr:      nil,
+			expectedOurAmt:   ourAmt,
+			expectedTheirAmt: 0,
+		},
+		{
+			name: "empty remote output index without spend " +
+				"tx",
+			revocationLog:    &revokedLogNoRemote,
+			expectedErr:      nil,
+			expectedOurAmt:   ourAmt,
+			expectedTheirAmt: 0,
+			noSpendTx:        true,
 		},
 	}
 
-	for _, test := range tests {
-		test := test
+	// assertRetribution is a helper closure that checks a given breach
+	// retribution has the expected values on certain fields.
+	assertRetribution := func(br *BreachRetribution, our, their int64) {
+		chainHash := aliceChannel.channelState.ChainHash
+		require.Equal(t, commitHash, br.BreachTxHash)
+		require.Equal(t, chainHash, br.ChainHash)
 
-		t.Run(test.name, func(t *testing.T) {
-			t.Parallel()
+		// Construct local outpoint, we only have the index when the
+		// amount is not zero.
+		local := wire.OutPoint{
+			Hash: commitHash,
+		}
+		if our != 0 {
+			local.Index = localIndex
+		}
 
-			heights := test.startHeights
-			update := &PaymentDescriptor{
-				Amount:                   updateAmount,
-				addCommitHeightLocal:     heights.localAdd,
-				addCommitHeightRemote:    heights.remoteAdd,
-				removeCommitHeightLocal:  heights.localRemove,
-				removeCommitHeightRemote: heights.remoteRemove,
-				EntryType:                test.updateType,
+		// Construct remote outpoint, we only have the index when the
+		// amount is not zero.
+		remote := wire.OutPoint{
+			Hash: commitHash,
+		}
+		if their != 0 {
+			remote.Index = remoteIndex
+		}
+
+		require.Equal(t, local, br.LocalOutpoint)
+		require.Equal(t, remote, br.RemoteOutpoint)
+
+		for _, hr := range br.HtlcRetributions {
+			require.EqualValues(t, testAmt,
+				hr.SignDesc.Output.Value)
+			require.Equal(t, commitHash, hr.OutPoint.Hash)
+			require.EqualValues(t, htlcIndex, hr.OutPoint.Index)
+			require.Equal(t, htlc.Incoming, hr.IsIncoming)
+		}
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tx := spendTx
+			if tc.noSpendTx {
+				tx = nil
 			}
 
-			var (
-				// Start both parties off with an initial
-				// balance. Copy by value here so that we do
-				// not mutate the startBalance constant.
-				ourBalance, theirBalance = startBalance,
-					startBalance
+			br, our, their, err := createBreachRetribution(
+				tc.revocationLog, tx,
+				aliceChannel.channelState, keyRing,
+				dummyPrivate, leaseExpiry,
 			)
 
-			// Choose the processing function we need based on the
-			// update type. Process remove is used for settles,
-			// fails and malformed htlcs.
-			process := processRemoveEntry
-			if test.updateType == Add {
-				process = processAddEntry
+			// Check the error if expected.
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				// Otherwise we expect no error.
+				require.NoError(t, err)
+
+				// Check the amounts and the contructed partial
+				// retribution are returned as expected.
+				require.Equal(t, tc.expectedOurAmt, our)
+				require.Equal(t, tc.expectedTheirAmt, their)
+				assertRetribution(br, our, their)
 			}
+		})
+	}
+}
 
-			process(
-				update, &ourBalance, &theirBalance, nextHeight,
-				test.remoteChain, test.isIncoming,
-				test.mutateState,
-			)
+// TestCreateBreachRetributionLegacy checks that
+// `createBreachRetributionLegacy` behaves as expected.
+func TestCreateBreachRetributionLegacy(t *testing.T) {
+	t.Parallel()
+
+	// Create dummy values for the test.
+	dummyPrivate, _ := btcec.PrivKeyFromBytes([]byte{1})
+
+	// Create a test channel.
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.ZeroHtlcTxFeeBit,
+	)
+	require.NoError(t, err)
+
+	// Prepare the params needed to call the function. Note that the values
+	// here are not necessary "cryptography-correct", we just use them to
+	// construct the retribution.
+	leaseExpiry, keyRing, _ := deriveDummyRetributionParams(
+		aliceChannel.channelState,
+	)
 
-			// Check that balances were updated as expected.
-			if ourBalance != test.ourExpectedBalance {
-				t.Fatalf("expected our balance: %v, got: %v",
-					test.ourExpectedBalance, ourBalance)
-			}
+	// Use the remote commitment as our revocation log.
+	revokedLog := aliceChannel.channelState.RemoteCommitment
 
-			if theirBalance != test.theirExpectedBalance {
-				t.Fatalf("expected their balance: %v, got: %v",
-					test.theirExpectedBalance, theirBalance)
-			}
+	ourOp := revokedLog.CommitTx.TxOut[0]
+	theirOp := revokedLog.CommitTx.TxOut[1]
 
-			// Check that heights on the update are as expected.
-			checkHeights(t, update, test.expectedHeights)
-		})
+	// Create the dummy scripts.
+	ourScript := &WitnessScriptDesc{
+		OutputScript: ourOp.PkScript,
+	}
+	theirScript := &WitnessScriptDesc{
+		OutputScript: theirOp.PkScript,
 	}
-}
-
-// TestChannelUnsignedAckedFailure tests that unsigned acked updates are
-// properly restored after signing for them and disconnecting.
-//
-// The full state transition of this test is:
-//
-// Alice                   Bob
-//
-//	-----add----->
-//	-----sig----->
-//	<----rev------
-//	<----sig------
-//	-----rev----->
-//	<----fail-----
-//	<----sig------
-//	-----rev----->
-//	-----sig-----X (does not reach Bob! Alice dies!)
-//
-//	-----sig----->
-//	<----rev------
-//	<----add------
-//	<----sig------
-//
-// The last sig was rejected with the old behavior of deleting unsigned
-// acked updates from the database after signing for them. The current
-// behavior of filtering them for deletion upon receiving a revocation
-// causes Alice to accept the signature as valid.
-func TestChannelUnsignedAckedFailure(t *testing.T) {
-	t.Parallel()
 
-	// Create a test channel so that we can test the buggy behavior.
-	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+	// Create the breach retribution using the legacy format.
+	br, ourAmt, theirAmt, err := createBreachRetributionLegacy(
+		&revokedLog, aliceChannel.channelState, keyRing,
+		dummyPrivate, ourScript, theirScript, leaseExpiry,
 	)
 	require.NoError(t, err)
 
-	// First we create an HTLC that Alice sends to Bob.
-	htlc, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+	// Check the commitHash and chainHash.
+	commitHash := revokedLog.CommitTx.TxHash()
+	chainHash := aliceChannel.channelState.ChainHash
+	require.Equal(t, commitHash, br.BreachTxHash)
+	require.Equal(t, chainHash, br.ChainHash)
 
-	// -----add----->
-	_, err = aliceChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err)
-	_, err = bobChannel.ReceiveHTLC(htlc)
-	require.NoError(t, err)
+	// Check the outpoints.
+	local := wire.OutPoint{
+		Hash:  commitHash,
+		Index: 0,
+	}
+	remote := wire.OutPoint{
+		Hash:  commitHash,
+		Index: 1,
+	}
+	require.Equal(t, local, br.LocalOutpoint)
+	require.Equal(t, remote, br.RemoteOutpoint)
 
-	// Force a state transition to lock in this add on both commitments.
-	// -----sig----->
-	// <----rev------
-	// <----sig------
-	// -----rev----->
-	err = ForceStateTransition(aliceChannel, bobChannel)
-	require.NoError(t, err)
+	// Validate the amounts, note that in the legacy format, our amount is
+	// not directly the amount found in the to local output. Rather, it's
+	// the local output value minus the commit fee and anchor value(if
+	// present).
+	require.EqualValues(t, revokedLog.LocalBalance.ToSatoshis(), ourAmt)
+	require.Equal(t, theirOp.Value, theirAmt)
+}
 
-	// Now Bob should fail the htlc back to Alice.
-	// <----fail-----
-	err = bobChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
-	require.NoError(t, err)
-	err = aliceChannel.ReceiveFailHTLC(0, []byte("bad"))
-	require.NoError(t, err)
+// TestNewBreachRetribution tests that the function `NewBreachRetribution`
+// behaves as expected.
+func TestNewBreachRetribution(t *testing.T) {
+	t.Run("non-anchor", func(t *testing.T) {
+		testNewBreachRetribution(t, channeldb.ZeroHtlcTxFeeBit)
+	})
+	t.Run("anchor", func(t *testing.T) {
+		chanType := channeldb.SingleFunderTweaklessBit |
+			channeldb.AnchorOutputsBit
+		testNewBreachRetribution(t, chanType)
+	})
+}
 
-	// Bob should send a commitment signature to Alice.
-	// <----sig------
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err)
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err)
+// testNewBreachRetribution takes a channel type and tests the function
+// `NewBreachRetribution`.
+func testNewBreachRetribution(t *testing.T, chanType channeldb.ChannelType) {
+	t.Parallel()
 
-	// Alice should reply with a revocation.
-	// -----rev----->
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err)
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
 	require.NoError(t, err)
 
-	// Alice should sign the next commitment and go down before
-	// sending it.
-	// -----sig-----X
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err)
+	breachHeight := uint32(101)
+	stateNum := uint64(0)
+	chainHash := aliceChannel.channelState.ChainHash
+	theirDelay := uint32(aliceChannel.channelState.RemoteChanCfg.CsvDelay)
+	breachTx := aliceChannel.channelState.RemoteCommitment.CommitTx
 
-	newAliceChannel, err := NewLightningChannel(
-		aliceChannel.Signer, aliceChannel.channelState,
-		aliceChannel.sigPool,
+	// Create a breach retribution at height 0, which should give us an
+	// error as there are no past delta state saved as revocation logs yet.
+	_, err = NewBreachRetribution(
+		aliceChannel.channelState, stateNum, breachHeight, breachTx,
 	)
-	require.NoError(t, err)
+	require.ErrorIs(t, err, channeldb.ErrNoPastDeltas)
 
-	// Bob receives Alice's signature.
-	// -----sig----->
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err)
+	// We also check that the same error is returned if no breach tx is
+	// provided.
+	_, err = NewBreachRetribution(
+		aliceChannel.channelState, stateNum, breachHeight, nil,
+	)
+	require.ErrorIs(t, err, channeldb.ErrNoPastDeltas)
 
-	// Bob revokes his current commitment and sends a revocation
-	// to Alice.
-	// <----rev------
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
-	require.NoError(t, err)
-	_, _, _, _, err = newAliceChannel.ReceiveRevocation(bobRevocation)
+	// We now force a state transition which will give us a revocation log
+	// at height 0.
+	txid := aliceChannel.channelState.RemoteCommitment.CommitTx.TxHash()
+	err = ForceStateTransition(aliceChannel, bobChannel)
 	require.NoError(t, err)
 
-	// Now Bob sends an HTLC to Alice.
-	htlc2, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+	// assertRetribution is a helper closure that checks a given breach
+	// retribution has the expected values on certain fields.
+	assertRetribution := func(br *BreachRetribution,
+		localIndex, remoteIndex uint32) {
 
-	// <----add------
-	_, err = bobChannel.AddHTLC(htlc2, nil)
-	require.NoError(t, err)
-	_, err = newAliceChannel.ReceiveHTLC(htlc2)
-	require.NoError(t, err)
+		require.Equal(t, txid, br.BreachTxHash)
+		require.Equal(t, chainHash, br.ChainHash)
+		require.Equal(t, breachHeight, br.BreachHeight)
+		require.Equal(t, stateNum, br.RevokedStateNum)
+		require.Equal(t, theirDelay, br.RemoteDelay)
 
-	// Bob sends the final signature to Alice and Alice should not
-	// reject it, given that we properly restore the unsigned acked
-	// updates and therefore our update log is structured correctly.
-	bobNewCommit, err = bobChannel.SignNextCommitment()
+		local := wire.OutPoint{
+			Hash:  txid,
+			Index: localIndex,
+		}
+		remote := wire.OutPoint{
+			Hash:  txid,
+			Index: remoteIndex,
+		}
+
+		if chanType.HasAnchors() {
+			// For anchor channels, we expect the local delay to be
+			// 1 otherwise 0.
+			require.EqualValues(t, 1, br.LocalDelay)
+		} else {
+			require.Zero(t, br.LocalDelay)
+		}
+
+		require.Equal(t, local, br.LocalOutpoint)
+		require.Equal(t, remote, br.RemoteOutpoint)
+	}
+
+	// Create the retribution again and we should expect it to be created
+	// successfully.
+	br, err := NewBreachRetribution(
+		aliceChannel.channelState, stateNum, breachHeight, breachTx,
+	)
 	require.NoError(t, err)
-	err = newAliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+
+	// Check the retribution is as expected.
+	t.Log(spew.Sdump(breachTx))
+	assertRetribution(br, 1, 0)
+
+	// Repeat the check but with the breach tx set to nil. This should work
+	// since the necessary info should now be found in the revocation log.
+	br, err = NewBreachRetribution(
+		aliceChannel.channelState, stateNum, breachHeight, nil,
+	)
 	require.NoError(t, err)
-}
+	assertRetribution(br, 1, 0)
 
-// TestChannelLocalUnsignedUpdatesFailure checks that updates from the local
-// log are restored if the remote hasn't sent us a signature covering them.
-//
-// The full state transition is:
-//
-// Alice                Bob
-//
-//	<----add-----
-//	<----sig-----
-//	-----rev---->
-//	-----sig---->
-//	<----rev-----
-//	----fail---->
-//	-----sig---->
-//	<----rev-----
-//	 *reconnect*
-//	<----sig-----
-//
-// Alice should reject the last signature since the settle is not restored
-// into the local update log and thus calculates Bob's signature as invalid.
-func TestChannelLocalUnsignedUpdatesFailure(t *testing.T) {
+	// Create the retribution using a stateNum+1 and we should expect an
+	// error.
+	_, err = NewBreachRetribution(
+		aliceChannel.channelState, stateNum+1, breachHeight, breachTx,
+	)
+	require.ErrorIs(t, err, channeldb.ErrLogEntryNotFound)
+
+	// Once again, repeat the check for the case when no breach tx is
+	// provided.
+	_, err = NewBreachRetribution(
+		aliceChannel.channelState, stateNum+1, breachHeight, nil,
+	)
+	require.ErrorIs(t, err, channeldb.ErrLogEntryNotFound)
+}
+
+// TestRevocationChainBroken asserts that a revocation secret which doesn't
+// chain with the previously revealed ones is rejected with an
+// ErrRevocationChainBroken, and that the channel still accepts the correct
+// secret afterwards.
+func TestRevocationChainBroken(t *testing.T) {
 	t.Parallel()
 
-	// Create a test channel so that we can test the buggy behavior.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err)
 
-	// First we create an htlc that Bob sends to Alice.
-	htlc, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
-
-	// <----add-----
-	_, err = bobChannel.AddHTLC(htlc, nil)
-	require.NoError(t, err)
-	_, err = aliceChannel.ReceiveHTLC(htlc)
+	// Transition once so that Alice's revocation store holds a secret the
+	// next ones have to chain with.
+	htlc, _ := createHTLC(0, lnwire.MilliSatoshi(10_000_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
-
-	// Force a state transition to lock in this add on both commitments.
-	// <----sig-----
-	// -----rev---->
-	// -----sig---->
-	// <----rev-----
-	err = ForceStateTransition(bobChannel, aliceChannel)
+	_, err = bobChannel.ReceiveHTLC(htlc)
 	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Now Alice should fail the htlc back to Bob.
-	// -----fail--->
-	err = aliceChannel.FailHTLC(0, []byte("failreason"), nil, nil, nil)
+	htlc, _ = createHTLC(1, lnwire.MilliSatoshi(10_000_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
-	err = bobChannel.ReceiveFailHTLC(0, []byte("bad"))
+	_, err = bobChannel.ReceiveHTLC(htlc)
 	require.NoError(t, err)
 
-	// Alice should send a commitment signature to Bob.
-	// -----sig---->
 	aliceNewCommit, err := aliceChannel.SignNextCommitment()
 	require.NoError(t, err)
 	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
 	require.NoError(t, err)
-
-	// Bob should reply with a revocation and Alice should save the fail as
-	// an unsigned local update.
-	// <----rev-----
 	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
 	require.NoError(t, err)
+
+	// Bob now reveals the secret of a later commitment instead of the one
+	// he's meant to revoke.
+	revocation := *bobRevocation
+	producer := bobChannel.channelState.RevocationProducer
+	secret, err := producer.AtIndex(3)
+	require.NoError(t, err)
+	copy(revocation.Revocation[:], secret[:])
+
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(&revocation)
+	var errChainBroken *ErrRevocationChainBroken
+	require.ErrorAs(t, err, &errChainBroken)
+	require.EqualValues(t, 1, errChainBroken.height)
+	require.True(t, errChainBroken.derivedPoint.IsEqual(
+		input.ComputeCommitmentPoint(secret[:]),
+	))
+	require.True(t, errChainBroken.expectedPoint.IsEqual(
+		aliceChannel.channelState.RemoteCurrentRevocation,
+	))
+
+	// The rejected secret mustn't have been stored, so the correct one is
+	// still accepted.
 	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
 	require.NoError(t, err)
+}
 
-	// Restart Alice and assert that she can receive Bob's next commitment
-	// signature.
-	// *reconnect*
-	newAliceChannel, err := NewLightningChannel(
-		aliceChannel.Signer, aliceChannel.channelState,
-		aliceChannel.sigPool,
+// TestAuditRevocationState asserts that AuditRevocationState accepts a
+// consistent channel state and detects a desynchronized revocation producer,
+// revocation store and commitment heights.
+func TestAuditRevocationState(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err)
 
-	// Bob sends the final signature and Alice should not reject it.
-	// <----sig-----
-	bobNewCommit, err := bobChannel.SignNextCommitment()
-	require.NoError(t, err)
-	err = newAliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, aliceChannel.AuditRevocationState())
+
+	// Advance the channel a few states, making sure the revocation state
+	// stays consistent along the way, also across restarts.
+	for i := 0; i < 3; i++ {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(10_000))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
+
+		require.NoError(t, aliceChannel.AuditRevocationState())
+		require.NoError(t, bobChannel.AuditRevocationState())
+	}
+
+	aliceChannel, err = restartChannel(aliceChannel)
 	require.NoError(t, err)
+	require.NoError(t, aliceChannel.AuditRevocationState())
+
+	chanState := aliceChannel.channelState
+
+	// An off-by-one between the persisted commitment and the in-memory
+	// commitment chain is detected.
+	chanState.LocalCommitment.CommitHeight++
+	err = aliceChannel.AuditRevocationState()
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
+	chanState.LocalCommitment.CommitHeight--
+
+	// A revocation store that lost the latest secrets is detected.
+	revocationStore := chanState.RevocationStore
+	chanState.RevocationStore = shachain.NewRevocationStore()
+	err = aliceChannel.AuditRevocationState()
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
+	chanState.RevocationStore = revocationStore
+
+	// A remote commitment point that wasn't rotated after the last
+	// revocation is detected.
+	remoteHeight := chanState.RemoteCommitment.CommitHeight
+	lastSecret, err := revocationStore.LookUp(remoteHeight - 1)
+	require.NoError(t, err)
+	currentPoint := chanState.RemoteCurrentRevocation
+	chanState.RemoteCurrentRevocation = input.ComputeCommitmentPoint(
+		lastSecret[:],
+	)
+	err = aliceChannel.AuditRevocationState()
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
+	chanState.RemoteCurrentRevocation = currentPoint
+
+	// Finally, a revocation producer that doesn't yield the commitment
+	// point of our current commitment is detected.
+	var wrongRoot chainhash.Hash
+	wrongRoot[0] = 1
+	chanState.RevocationProducer = shachain.NewRevocationProducer(wrongRoot)
+	err = aliceChannel.AuditRevocationState()
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
 }
 
-// TestChannelSignedAckRegression tests a previously-regressing state
-// transition no longer causes channel desynchronization.
-//
-// The full state transition of this test is:
-//
-// Alice                   Bob
-//
-//	<----add-------
-//	<----sig-------
-//	-----rev------>
-//	-----sig------>
-//	<----rev-------
-//	----settle---->
-//	-----sig------>
-//	<----rev-------
-//	<----sig-------
-//	-----add------>
-//	-----sig------>
-//	<----rev-------
-//	                *restarts*
-//	-----rev------>
-//	<----sig-------
-func TestChannelSignedAckRegression(t *testing.T) {
+// TestRevocationStateExportImport asserts that the revocation state of a
+// channel can be migrated to a replica whose revocation state lags behind
+// through ExportRevocationState and ImportRevocationState, and that importing
+// a state inconsistent with the channel is refused.
+func TestRevocationStateExportImport(t *testing.T) {
 	t.Parallel()
 
-	// Create test channels to test out this state transition.
 	aliceChannel, bobChannel, err := CreateTestChannels(
 		t, channeldb.SingleFunderTweaklessBit,
 	)
 	require.NoError(t, err)
 
-	// Create an HTLC that Bob will send to Alice.
-	htlc, preimage := createHTLC(0, lnwire.MilliSatoshi(5000000))
+	advanceState := func(id int) {
+		t.Helper()
 
-	// <----add------
-	_, err = bobChannel.AddHTLC(htlc, nil)
+		htlc, _ := createHTLC(id, lnwire.NewMSatFromSatoshis(10_000))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
+	}
+
+	advanceState(0)
+	staleState, err := aliceChannel.ExportRevocationState()
 	require.NoError(t, err)
-	_, err = aliceChannel.ReceiveHTLC(htlc)
+
+	// Set up a replica of Alice's channel in a separate database. It
+	// holds the commitments of the channel as of now, but only the
+	// revocation secrets of the first state.
+	replicaDB, err := channeldb.Open(t.TempDir())
 	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, replicaDB.Close())
+	})
 
-	// Force a state transition to lock in the HTLC.
-	// <----sig------
-	// -----rev----->
-	// -----sig----->
-	// <----rev------
-	err = ForceStateTransition(bobChannel, aliceChannel)
+	for i := 1; i < 4; i++ {
+		advanceState(i)
+	}
+
+	chanState := aliceChannel.channelState
+	dbChannels, err := chanState.Db.FetchOpenChannels(chanState.IdentityPub)
 	require.NoError(t, err)
+	require.Len(t, dbChannels, 1)
 
-	// Alice settles the HTLC back to Bob.
-	// ----settle--->
-	err = aliceChannel.SettleHTLC(preimage, uint64(0), nil, nil, nil)
+	var (
+		staleProducer shachain.Producer
+		staleStore    shachain.Store
+	)
+	err = channeldb.ReadElements(
+		bytes.NewReader(staleState[1:]), &staleProducer, &staleStore,
+	)
 	require.NoError(t, err)
-	err = bobChannel.ReceiveHTLCSettle(preimage, uint64(0))
+
+	replicaState := dbChannels[0]
+	replicaState.Db = replicaDB.ChannelStateDB()
+	replicaState.RevocationStore = staleStore
+
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18556}
+	require.NoError(t, replicaState.SyncPending(addr, 101))
+
+	replica, err := NewLightningChannel(
+		aliceChannel.Signer, replicaState, aliceChannel.sigPool,
+	)
 	require.NoError(t, err)
+	err = replica.AuditRevocationState()
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
 
-	// -----sig---->
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	aliceState, err := aliceChannel.ExportRevocationState()
 	require.NoError(t, err)
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	bobState, err := bobChannel.ExportRevocationState()
 	require.NoError(t, err)
 
-	// <----rev-----
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	// A state that lags behind the commitments, or the state of another
+	// channel, is refused.
+	err = replica.ImportRevocationState(staleState)
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
+	err = replica.ImportRevocationState(bobState)
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
+
+	// Bob's store holds the secrets of as many commitments as Alice's,
+	// but they're Alice's own secrets, which diverge from the ones the
+	// replica already holds.
+	var mixedState bytes.Buffer
+	mixedState.WriteByte(revocationStateVersion)
+	err = channeldb.WriteElements(
+		&mixedState, chanState.RevocationProducer,
+		bobChannel.channelState.RevocationStore,
+	)
 	require.NoError(t, err)
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	err = replica.ImportRevocationState(mixedState.Bytes())
+	require.ErrorIs(t, err, ErrRevocationStateMismatch)
+
+	// Malformed states are refused as well.
+	err = replica.ImportRevocationState(aliceState[:len(aliceState)-1])
+	require.Error(t, err)
+	err = replica.ImportRevocationState(append(aliceState, 0))
+	require.Error(t, err)
+
+	// The current state of Alice's channel catches the replica up, also
+	// on disk.
+	require.NoError(t, replica.ImportRevocationState(aliceState))
+
+	replica, err = restartChannel(replica)
 	require.NoError(t, err)
+	require.NoError(t, replica.AuditRevocationState())
 
-	// <----sig-----
-	bobNewCommit, err := bobChannel.SignNextCommitment()
+	replicaRevState, err := replica.ExportRevocationState()
 	require.NoError(t, err)
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.Equal(t, aliceState, replicaRevState)
+
+	// Importing the state the channel already has is a no-op.
+	require.NoError(t, replica.ImportRevocationState(aliceState))
+
+	// The replica takes over the channel, and continues to operate it
+	// normally.
+	aliceChannel = replica
+	advanceState(4)
+	require.NoError(t, aliceChannel.AuditRevocationState())
+	require.NoError(t, bobChannel.AuditRevocationState())
+}
+
+// TestRevokedStateSweepInfo asserts that the sweep material returned for a
+// range of revoked heights matches the outputs of the revoked commitments.
+func TestRevokedStateSweepInfo(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit,
+	)
 	require.NoError(t, err)
 
-	// Create an HTLC that Alice will send to Bob.
-	htlc2, _ := createHTLC(0, lnwire.MilliSatoshi(5000000))
+	// Without any revoked states, no height can be requested.
+	_, err = aliceChannel.RevokedStateSweepInfo(0, 0)
+	require.ErrorIs(t, err, ErrRevokedHeightOutOfRange)
 
-	// -----add---->
-	_, err = aliceChannel.AddHTLC(htlc2, nil)
+	// Advance the channel a few states, recording Bob's commitment at
+	// each height before it gets revoked.
+	const numStates = 3
+	revokedTxs := make([]*wire.MsgTx, 0, numStates)
+	for i := 0; i < numStates; i++ {
+		revokedTxs = append(
+			revokedTxs,
+			aliceChannel.channelState.RemoteCommitment.CommitTx,
+		)
+
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(10_000))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
+	}
+
+	infos, err := aliceChannel.RevokedStateSweepInfo(0, numStates-1)
 	require.NoError(t, err)
-	_, err = bobChannel.ReceiveHTLC(htlc2)
+	require.Len(t, infos, numStates)
+
+	// hasOutput returns true if the transaction has an output with the
+	// given pkScript.
+	hasOutput := func(tx *wire.MsgTx, pkScript []byte) bool {
+		for _, txOut := range tx.TxOut {
+			if bytes.Equal(txOut.PkScript, pkScript) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for i, info := range infos {
+		require.EqualValues(t, i, info.Height)
+		require.EqualValues(t, 1, info.OurDelay)
+		require.True(t, hasOutput(
+			revokedTxs[i], info.TheirScript.PkScript(),
+		))
+		require.True(t, hasOutput(
+			revokedTxs[i], info.OurScript.PkScript(),
+		))
+	}
+
+	// A sub-range should return the same information.
+	subInfos, err := aliceChannel.RevokedStateSweepInfo(1, 1)
 	require.NoError(t, err)
+	require.Equal(t, infos[1:2], subInfos)
 
-	// -----sig---->
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
+	// The current remote commitment hasn't been revoked yet, and an
+	// inverted range is invalid.
+	_, err = aliceChannel.RevokedStateSweepInfo(0, numStates)
+	require.ErrorIs(t, err, ErrRevokedHeightOutOfRange)
+	_, err = aliceChannel.RevokedStateSweepInfo(2, 1)
+	require.ErrorIs(t, err, ErrRevokedHeightOutOfRange)
+}
+
+// TestPeekRevocation asserts that PeekRevocation returns the revocation that
+// RevokeCurrentCommitment sends afterwards, without modifying the state of
+// the channel.
+func TestPeekRevocation(t *testing.T) {
+	t.Run("non-taproot", func(t *testing.T) {
+		testPeekRevocation(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("taproot", func(t *testing.T) {
+		testPeekRevocation(
+			t, channeldb.SimpleTaprootFeatureBit|
+				channeldb.AnchorOutputsBit|
+				channeldb.ZeroHtlcTxFeeBit|
+				channeldb.SingleFunderTweaklessBit,
+		)
+	})
+}
+
+func testPeekRevocation(t *testing.T, chanType channeldb.ChannelType) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Alice adds an HTLC and signs Bob's next commitment, which he accepts
+	// but doesn't revoke his current commitment for yet.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	_, err = bobChannel.ReceiveHTLC(htlc)
 	require.NoError(t, err)
 
-	// <----rev-----
-	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
 	require.NoError(t, err)
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
 	require.NoError(t, err)
 
-	// Restart Bob's channel state here.
-	newBobChannel, err := NewLightningChannel(
-		bobChannel.Signer, bobChannel.channelState,
-		bobChannel.sigPool,
-	)
-	require.NoError(t, err)
+	height := bobChannel.currentHeight
+	tailHeight := bobChannel.localCommitChain.tail().height
+	localCommit := bobChannel.channelState.LocalCommitment
 
-	// -----rev---->
-	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	// Peeking at the revocation doesn't modify the state, so it returns
+	// the same message on every call.
+	peekedRevocation, err := bobChannel.PeekRevocation()
 	require.NoError(t, err)
-	fwdPkg, _, _, _, err := newBobChannel.ReceiveRevocation(aliceRevocation)
+	againRevocation, err := bobChannel.PeekRevocation()
 	require.NoError(t, err)
+	require.Equal(t, peekedRevocation, againRevocation)
 
-	// Assert that the fwdpkg is not empty.
-	require.Equal(t, len(fwdPkg.SettleFails), 1)
+	require.Equal(t, height, bobChannel.currentHeight)
+	require.Equal(t, tailHeight, bobChannel.localCommitChain.tail().height)
+	require.Equal(t, localCommit, bobChannel.channelState.LocalCommitment)
 
-	// Bob should no longer fail to sign this commitment due to faulty
-	// update logs.
-	// <----sig-----
-	bobNewCommit, err = newBobChannel.SignNextCommitment()
+	// The revocation sent once Bob revokes his commitment is the one that
+	// was peeked, and the state transition completes as usual.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
 	require.NoError(t, err)
+	require.Equal(t, peekedRevocation, bobRevocation)
+	require.Equal(t, height+1, bobChannel.currentHeight)
 
-	// Alice should receive the new commitment without hiccups.
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
 	require.NoError(t, err)
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
 }
 
-// TestMayAddOutgoingHtlc tests MayAddOutgoingHtlc against zero and non-zero
-// htlc amounts.
-func TestMayAddOutgoingHtlc(t *testing.T) {
+// TestCheckBalanceInvariant asserts that the balance invariant holds for the
+// commitments of both parties after state transitions with HTLCs in flight,
+// and that a corrupted balance is detected.
+func TestCheckBalanceInvariant(t *testing.T) {
 	t.Parallel()
 
-	// The default channel created as a part of the test fixture already
-	// has a MinHTLC value of zero, so we don't need to do anything here
-	// other than create it.
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.SingleFunderTweaklessBit,
+		t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit,
 	)
-	require.NoError(t, err)
-
-	// The channels start out with a 50/50 balance, so both sides should be
-	// able to add an outgoing HTLC with no specific amount added.
-	require.NoError(t, aliceChannel.MayAddOutgoingHtlc(0))
-	require.NoError(t, bobChannel.MayAddOutgoingHtlc(0))
+	require.NoError(t, err, "unable to create test channels")
 
-	chanBal, err := btcutil.NewAmount(testChannelCapacity)
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Each side should be able to add 1/4 of total channel balance since
-	// we're 50/50 split.
-	mayAdd := lnwire.MilliSatoshi(chanBal / 4 * 1000)
-	require.NoError(t, aliceChannel.MayAddOutgoingHtlc(mayAdd))
-	require.NoError(t, bobChannel.MayAddOutgoingHtlc(mayAdd))
-
-	// Both channels should fail if we try to add an amount more than
-	// their current balance.
-	mayNotAdd := lnwire.MilliSatoshi(chanBal * 1000)
-	require.Error(t, aliceChannel.MayAddOutgoingHtlc(mayNotAdd))
-	require.Error(t, bobChannel.MayAddOutgoingHtlc(mayNotAdd))
+	for _, channel := range []*LightningChannel{aliceChannel, bobChannel} {
+		localCommit := channel.localCommitChain.tail()
+		numHtlcs := len(localCommit.outgoingHTLCs) +
+			len(localCommit.incomingHTLCs)
+		require.Equal(t, 1, numHtlcs)
+		require.NoError(t, channel.checkBalanceInvariant(localCommit))
 
-	// Hard set alice's min htlc to zero and test the case where we just
-	// fall back to a non-zero value.
-	aliceChannel.channelState.LocalChanCfg.MinHTLC = 0
-	require.NoError(t, aliceChannel.MayAddOutgoingHtlc(0))
+		remoteCommit := channel.remoteCommitChain.tail()
+		require.NoError(t, channel.checkBalanceInvariant(remoteCommit))
+	}
+
+	// If a single msat leaks from a balance, the invariant is violated.
+	localCommit := aliceChannel.localCommitChain.tail()
+	localCommit.ourBalance--
+
+	err = aliceChannel.checkBalanceInvariant(localCommit)
+	var invariantErr ErrBalanceInvariant
+	require.ErrorAs(t, err, &invariantErr)
+	require.Equal(t, localCommit.height, invariantErr.height)
+	require.Equal(
+		t, aliceChannel.channelState.Capacity, invariantErr.capacity,
+	)
+	require.Equal(t, 2*anchorSize, invariantErr.anchors)
+	require.EqualValues(t, 100_000_000, invariantErr.htlcTotal)
 }
 
-// TestIsChannelClean tests that IsChannelClean returns the expected values
-// in different channel states.
-func TestIsChannelClean(t *testing.T) {
+// TestSyncDiagnostics asserts that SyncDiagnostics reports which messages are
+// owed by either party at every step of a state transition.
+func TestSyncDiagnostics(t *testing.T) {
 	t.Parallel()
 
 	aliceChannel, bobChannel, err := CreateTestChannels(
-		t, channeldb.ZeroHtlcTxFeeBit,
+		t, channeldb.SingleFunderTweaklessBit,
 	)
-	require.NoError(t, err)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Channel state should be clean at the start of the test.
-	assertCleanOrDirty(true, aliceChannel, bobChannel, t)
+	assertDiagnostics := func(channel *LightningChannel,
+		expected SyncDiagnostics) {
 
-	// Assert that neither side considers the channel clean when alice
-	// sends an htlc.
-	// ---add--->
-	htlc, preimage := createHTLC(0, lnwire.MilliSatoshi(5000000))
+		t.Helper()
+
+		diagnostics := channel.SyncDiagnostics()
+		require.Equal(t, expected, *diagnostics)
+		require.Equal(
+			t, expected == SyncDiagnostics{}, diagnostics.Synced(),
+		)
+	}
+
+	// Both channels start out synced.
+	assertDiagnostics(aliceChannel, SyncDiagnostics{})
+	assertDiagnostics(bobChannel, SyncDiagnostics{})
+	require.Equal(t, "synced", aliceChannel.SyncDiagnostics().String())
+
+	// Once Alice adds an HTLC, she owes a commitment, and from Bob's
+	// point of view, she does as well.
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(100_000))
 	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
 	_, err = bobChannel.ReceiveHTLC(htlc)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// Assert that the channel remains dirty until the HTLC is completely
-	// removed from both commitments.
+	assertDiagnostics(aliceChannel, SyncDiagnostics{
+		OweCommitment:        true,
+		UnsignedLocalUpdates: 1,
+	})
+	assertDiagnostics(bobChannel, SyncDiagnostics{
+		RemoteOwesCommitment:  true,
+		UnsignedRemoteUpdates: 1,
+	})
+	require.Equal(
+		t, "we owe a commitment (unsigned local updates: 1)",
+		aliceChannel.SyncDiagnostics().String(),
+	)
 
-	// ---sig--->
+	// After signing, Alice is waiting for Bob's revocation, and Bob owes
+	// both a revocation and a commitment covering the HTLC.
 	aliceNewCommit, err := aliceChannel.SignNextCommitment()
 	require.NoError(t, err)
 	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// <---rev---
+	assertDiagnostics(aliceChannel, SyncDiagnostics{
+		PendingRemoteCommit:  true,
+		RemoteOwesCommitment: true,
+	})
+	assertDiagnostics(bobChannel, SyncDiagnostics{
+		OweCommitment: true,
+		OweRevocation: true,
+	})
+	require.Equal(
+		t, "we owe a commitment (unsigned local updates: 0), we owe "+
+			"a revocation", bobChannel.SyncDiagnostics().String(),
+	)
+
+	// Once Bob revoked his commitment, he still owes Alice a commitment.
 	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
 	require.NoError(t, err)
 	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// <---sig---
+	assertDiagnostics(aliceChannel, SyncDiagnostics{
+		RemoteOwesCommitment: true,
+	})
+	assertDiagnostics(bobChannel, SyncDiagnostics{
+		OweCommitment: true,
+	})
+
+	// Bob signs Alice's commitment, so Alice owes a revocation.
 	bobNewCommit, err := bobChannel.SignNextCommitment()
 	require.NoError(t, err)
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// ---rev--->
+	assertDiagnostics(aliceChannel, SyncDiagnostics{
+		OweRevocation: true,
+	})
+	assertDiagnostics(bobChannel, SyncDiagnostics{
+		PendingRemoteCommit: true,
+	})
+	require.Equal(
+		t, "waiting for the remote revocation",
+		bobChannel.SyncDiagnostics().String(),
+	)
+
+	// Once Alice revoked her commitment, both channels are synced again,
+	// even though the HTLC is still active.
 	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
 	require.NoError(t, err)
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// <--settle--
-	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
-	require.NoError(t, err)
-	err = aliceChannel.ReceiveHTLCSettle(preimage, 0)
-	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+	assertDiagnostics(aliceChannel, SyncDiagnostics{})
+	assertDiagnostics(bobChannel, SyncDiagnostics{})
+	require.False(t, aliceChannel.IsChannelClean())
+}
 
-	// <---sig---
-	bobNewCommit, err = bobChannel.SignNextCommitment()
-	require.NoError(t, err)
-	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
-	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+// TestSettleAndSign asserts that SettleAndSign settles an HTLC and signs a
+// commitment covering it, and that the settle is undone if signing fails.
+func TestSettleAndSign(t *testing.T) {
+	t.Parallel()
 
-	// ---rev--->
-	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
-	require.NoError(t, err)
-	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
-	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// ---sig--->
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err)
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+	var observed []PaymentHash
+	bobChannel.SetPreimageObserver(func(hash PaymentHash, _ [32]byte) {
+		observed = append(observed, hash)
+	})
 
-	// <---rev---
-	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	// Alice sends an HTLC to Bob, which is locked in.
+	htlc, preimage := createHTLC(0, lnwire.MilliSatoshi(500000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	_, err = bobChannel.ReceiveHTLC(htlc)
 	require.NoError(t, err)
-	assertCleanOrDirty(true, aliceChannel, bobChannel, t)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-	// Now we check that update_fee is handled and state is dirty until it
-	// is completely locked in.
-	// ---fee--->
-	fee := chainfee.SatPerKWeight(333)
-	err = aliceChannel.UpdateFee(fee)
-	require.NoError(t, err)
-	err = bobChannel.ReceiveUpdateFee(fee)
+	// Bob signs a commitment without receiving Alice's revocation, which
+	// exhausts his revocation window.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// ---sig--->
-	aliceNewCommit, err = aliceChannel.SignNextCommitment()
-	require.NoError(t, err)
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
+	// Settling and signing must now fail, and leave the channel state
+	// as it was before the call.
+	logLen := bobChannel.localUpdateLog.Len()
+	logIndex := bobChannel.localUpdateLog.logIndex
+	_, err = bobChannel.SettleAndSign(preimage, 0, nil, nil, nil)
+	require.ErrorIs(t, err, ErrNoWindow)
 
-	// <---rev---
-	bobRevocation, _, _, err = bobChannel.RevokeCurrentCommitment()
+	require.Equal(t, logLen, bobChannel.localUpdateLog.Len())
+	require.Equal(t, logIndex, bobChannel.localUpdateLog.logIndex)
+	require.False(t, bobChannel.remoteUpdateLog.htlcHasModification(0))
+	require.Empty(t, observed)
+
+	// Once Alice revoked her commitment, Bob is able to settle and sign
+	// in one step.
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
 	require.NoError(t, err)
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
 
-	// <---sig---
-	bobNewCommit, err = bobChannel.SignNextCommitment()
+	bobNewCommit, err = bobChannel.SettleAndSign(preimage, 0, nil, nil, nil)
 	require.NoError(t, err)
+	require.Equal(t, []PaymentHash{htlc.PaymentHash}, observed)
+
+	// A second attempt to settle the HTLC is rejected.
+	_, err = bobChannel.SettleAndSign(preimage, 0, nil, nil, nil)
+	require.Error(t, err)
+
+	// Alice receives the settle along with the new commitment, which
+	// removes the HTLC from her commitment once she revoked.
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimage, 0))
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
 	require.NoError(t, err)
-	assertCleanOrDirty(false, aliceChannel, bobChannel, t)
-
-	// The state should finally be clean after alice sends her revocation.
-	// ---rev--->
 	aliceRevocation, _, _, err = aliceChannel.RevokeCurrentCommitment()
 	require.NoError(t, err)
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
 	require.NoError(t, err)
-	assertCleanOrDirty(true, aliceChannel, bobChannel, t)
-}
-
-// assertCleanOrDirty is a helper function that asserts that both channels are
-// clean if clean is true, and dirty if clean is false.
-func assertCleanOrDirty(clean bool, alice, bob *LightningChannel,
-	t *testing.T) {
-
-	t.Helper()
-
-	if clean {
-		require.True(t, alice.IsChannelClean())
-		require.True(t, bob.IsChannelClean())
-		return
-	}
-
-	require.False(t, alice.IsChannelClean())
-	require.False(t, bob.IsChannelClean())
-}
 
-// TestChannelGetDustSum tests that we correctly calculate the channel's dust
-// sum for the local and remote commitments.
-func TestChannelGetDustSum(t *testing.T) {
-	t.Run("dust sum tweakless", func(t *testing.T) {
-		testGetDustSum(t, channeldb.SingleFunderTweaklessBit)
-	})
-	t.Run("dust sum anchors zero htlc fee", func(t *testing.T) {
-		testGetDustSum(t, channeldb.SingleFunderTweaklessBit|
-			channeldb.AnchorOutputsBit|
-			channeldb.ZeroHtlcTxFeeBit,
-		)
-	})
+	require.Empty(t, aliceChannel.channelState.LocalCommitment.Htlcs)
 }
 
-func testGetDustSum(t *testing.T, chantype channeldb.ChannelType) {
+// TestApplyLogUpdate asserts that replaying the log updates recorded in the
+// commit diffs of one channel with ApplyLogUpdate yields the same state in
+// another channel.
+func TestApplyLogUpdate(t *testing.T) {
 	t.Parallel()
 
-	// This makes a channel with Alice's dust limit set to 200sats and
-	// Bob's dust limit set to 1300sats.
-	aliceChannel, bobChannel, err := CreateTestChannels(t, chantype)
-	require.NoError(t, err)
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Use a function closure to assert the dust sum for a passed channel's
-	// local and remote commitments match the expected values.
-	checkDust := func(c *LightningChannel, expLocal,
-		expRemote lnwire.MilliSatoshi) {
+	// signAndRecord lets the sender sign a commitment, records the updates
+	// it covers from the persisted commit diff, and completes the state
+	// transition.
+	signAndRecord := func(sender,
+		receiver *LightningChannel) []channeldb.LogUpdate {
 
-		localDustSum := c.GetDustSum(false)
-		require.Equal(t, expLocal, localDustSum)
-		remoteDustSum := c.GetDustSum(true)
-		require.Equal(t, expRemote, remoteDustSum)
-	}
+		t.Helper()
 
-	// We'll lower the fee from 6000sats/kWU to 253sats/kWU for our test.
-	fee := chainfee.SatPerKWeight(253)
-	err = aliceChannel.UpdateFee(fee)
-	require.NoError(t, err)
-	err = bobChannel.ReceiveUpdateFee(fee)
-	require.NoError(t, err)
-	err = ForceStateTransition(aliceChannel, bobChannel)
-	require.NoError(t, err)
+		newCommit, err := sender.SignNextCommitment()
+		require.NoError(t, err)
 
-	// Create an HTLC that Bob will send to Alice which is above Alice's
-	// dust limit and below Bob's dust limit. This takes into account dust
-	// trimming for non-zero-fee channels.
-	htlc1Amt := lnwire.MilliSatoshi(700_000)
-	htlc1, preimage1 := createHTLC(0, htlc1Amt)
+		diff, err := sender.channelState.RemoteCommitChainTip()
+		require.NoError(t, err)
 
-	_, err = bobChannel.AddHTLC(htlc1, nil)
-	require.NoError(t, err)
-	_, err = aliceChannel.ReceiveHTLC(htlc1)
-	require.NoError(t, err)
+		err = receiver.ReceiveNewCommitment(newCommit.CommitSigs)
+		require.NoError(t, err)
+		revocation, _, _, err := receiver.RevokeCurrentCommitment()
+		require.NoError(t, err)
+		_, _, _, _, err = sender.ReceiveRevocation(revocation)
+		require.NoError(t, err)
+		require.NoError(t, ForceStateTransition(receiver, sender))
 
-	// Assert that GetDustSum from Alice's perspective does not consider
-	// the HTLC dust on her commitment, but does on Bob's commitment.
-	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
+		return diff.LogUpdates
+	}
 
-	// Assert that GetDustSum from Bob's perspective results in the same
-	// conditions above holding.
-	checkDust(bobChannel, htlc1Amt, lnwire.MilliSatoshi(0))
+	// Alice offers two HTLCs to Bob and updates the fee.
+	htlc0, preimage0 := createHTLC(0, lnwire.MilliSatoshi(500000))
+	htlc1, _ := createHTLC(1, lnwire.MilliSatoshi(700000))
+	for _, htlc := range []*lnwire.UpdateAddHTLC{htlc0, htlc1} {
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+	fee := chainfee.SatPerKWeight(
+		aliceChannel.channelState.LocalCommitment.FeePerKw * 2,
+	)
+	require.NoError(t, aliceChannel.UpdateFee(fee))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(fee))
+	aliceUpdates := signAndRecord(aliceChannel, bobChannel)
+	require.Len(t, aliceUpdates, 3)
 
-	// Forcing a state transition to occur should not change the dust sum.
-	err = ForceStateTransition(bobChannel, aliceChannel)
+	// Bob settles the first HTLC and fails the second one due to a
+	// malformed onion.
+	err = bobChannel.SettleHTLC(preimage0, 0, nil, nil, nil)
 	require.NoError(t, err)
-	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
-	checkDust(bobChannel, htlc1Amt, lnwire.MilliSatoshi(0))
-
-	// Settling the HTLC back from Alice to Bob should not change the dust
-	// sum because the HTLC is counted until it's removed from the update
-	// logs via compactLogs.
-	err = aliceChannel.SettleHTLC(preimage1, uint64(0), nil, nil, nil)
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimage0, 0))
+	shaOnionBlob := sha256.Sum256(htlc1.OnionBlob[:])
+	err = bobChannel.MalformedFailHTLC(
+		1, lnwire.CodeInvalidOnionKey, shaOnionBlob, nil,
+	)
 	require.NoError(t, err)
-	err = bobChannel.ReceiveHTLCSettle(preimage1, uint64(0))
+	err = aliceChannel.receiveMalformedFailHTLC(
+		1, lnwire.CodeInvalidOnionKey, shaOnionBlob,
+	)
 	require.NoError(t, err)
-	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
-	checkDust(bobChannel, htlc1Amt, lnwire.MilliSatoshi(0))
+	bobUpdates := signAndRecord(bobChannel, aliceChannel)
+	require.Len(t, bobUpdates, 2)
 
-	// Forcing a state transition will remove the HTLC in-memory for Bob
-	// since ReceiveRevocation is called which calls compactLogs. Bob
-	// should have a zero dust sum at this point. Alice will see Bob as
-	// having the original dust sum since compactLogs hasn't been called.
-	err = ForceStateTransition(aliceChannel, bobChannel)
-	require.NoError(t, err)
-	checkDust(aliceChannel, lnwire.MilliSatoshi(0), htlc1Amt)
-	checkDust(bobChannel, lnwire.MilliSatoshi(0), lnwire.MilliSatoshi(0))
+	// Now replay the recorded updates in a fresh pair of channels, which
+	// must record the same updates and end up in the same state.
+	aliceReplay, bobReplay, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Alice now sends an HTLC of 100sats, which is below both sides' dust
-	// limits.
-	htlc2Amt := lnwire.MilliSatoshi(100_000)
-	htlc2, _ := createHTLC(0, htlc2Amt)
+	replay := func(sender, receiver *LightningChannel,
+		updates []channeldb.LogUpdate) {
 
-	_, err = aliceChannel.AddHTLC(htlc2, nil)
-	require.NoError(t, err)
-	_, err = bobChannel.ReceiveHTLC(htlc2)
-	require.NoError(t, err)
+		t.Helper()
 
-	// Assert that GetDustSum from Alice's perspective includes the new
-	// HTLC as dust on both commitments.
-	checkDust(aliceChannel, htlc2Amt, htlc1Amt+htlc2Amt)
+		for i := range updates {
+			require.NoError(t, sender.ApplyLogUpdate(
+				&updates[i], false,
+			))
+			require.NoError(t, receiver.ApplyLogUpdate(
+				&updates[i], true,
+			))
+		}
+
+		// The replayed updates only differ in the channel ID of
+		// their messages.
+		replayed := signAndRecord(sender, receiver)
+		require.Len(t, replayed, len(updates))
+		for i := range updates {
+			require.Equal(
+				t, updates[i].LogIndex, replayed[i].LogIndex,
+			)
+			require.IsType(
+				t, updates[i].UpdateMsg, replayed[i].UpdateMsg,
+			)
+		}
+	}
+	replay(aliceReplay, bobReplay, aliceUpdates)
+	replay(bobReplay, aliceReplay, bobUpdates)
 
-	// Assert that GetDustSum from Bob's perspective also includes the HTLC
-	// on both commitments.
-	checkDust(bobChannel, htlc2Amt, htlc2Amt)
+	for _, c := range []struct {
+		replayed, original *LightningChannel
+	}{
+		{aliceReplay, aliceChannel},
+		{bobReplay, bobChannel},
+	} {
+		replayed := c.replayed.channelState.LocalCommitment
+		original := c.original.channelState.LocalCommitment
+		require.Equal(t, original.CommitHeight, replayed.CommitHeight)
+		require.Equal(t, original.LocalBalance, replayed.LocalBalance)
+		require.Equal(t, original.RemoteBalance, replayed.RemoteBalance)
+		require.Equal(t, original.FeePerKw, replayed.FeePerKw)
+		require.Empty(t, replayed.Htlcs)
+	}
+
+	// A replayed HTLC must carry the next HTLC index.
+	htlc2, _ := createHTLC(5, lnwire.MilliSatoshi(500000))
+	htlc2.ID = 5
+	err = aliceReplay.ApplyLogUpdate(&channeldb.LogUpdate{
+		UpdateMsg: htlc2,
+	}, false)
+	require.ErrorContains(t, err, "does not match expected next ID")
+}
 
-	// Alice signs for this HTLC and neither perspective should change.
-	aliceNewCommit, err := aliceChannel.SignNextCommitment()
-	require.NoError(t, err)
-	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
-	require.NoError(t, err)
-	checkDust(aliceChannel, htlc2Amt, htlc1Amt+htlc2Amt)
-	checkDust(bobChannel, htlc2Amt, htlc2Amt)
+// TestDiscardPendingRemoteCommit asserts that a pending remote commitment
+// that was never sent can be discarded, after which a new commitment covering
+// different updates can be signed.
+func TestDiscardPendingRemoteCommit(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Bob now sends a revocation for his prior commitment, and this should
-	// change Alice's perspective to no longer include the first HTLC as
-	// dust.
-	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	// Bob offers an HTLC to Alice and signs for it, which Alice revokes
+	// her prior commitment for. Alice's next commitment must thus include
+	// Bob's HTLC.
+	bobHtlc, _ := createHTLC(0, lnwire.MilliSatoshi(500000))
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
 	require.NoError(t, err)
-	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
 	require.NoError(t, err)
-	checkDust(aliceChannel, htlc2Amt, htlc2Amt)
-	checkDust(bobChannel, htlc2Amt, htlc2Amt)
 
-	// The rest of the dance is completed and neither perspective should
-	// change.
 	bobNewCommit, err := bobChannel.SignNextCommitment()
 	require.NoError(t, err)
 	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
@@ -9627,487 +14924,318 @@ func testGetDustSum(t *testing.T, chantype channeldb.ChannelType) {
 	require.NoError(t, err)
 	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
 	require.NoError(t, err)
-	checkDust(aliceChannel, htlc2Amt, htlc2Amt)
-	checkDust(bobChannel, htlc2Amt, htlc2Amt)
-
-	// We'll now assert that if Alice sends an HTLC above her dust limit
-	// and then updates the fee of the channel to trigger the trimmed to
-	// dust mechanism, Alice will count this HTLC in the dust sum for her
-	// commitment in the non-zero-fee case.
-	htlc3Amt := lnwire.MilliSatoshi(400_000)
-	htlc3, _ := createHTLC(1, htlc3Amt)
 
-	_, err = aliceChannel.AddHTLC(htlc3, nil)
+	// Alice now adds an HTLC and signs a commitment for it, but neither
+	// is sent to Bob.
+	aliceHtlc, _ := createHTLC(0, lnwire.MilliSatoshi(700000))
+	_, err = aliceChannel.AddHTLC(aliceHtlc, nil)
 	require.NoError(t, err)
-	_, err = bobChannel.ReceiveHTLC(htlc3)
+	_, err = aliceChannel.SignNextCommitment()
 	require.NoError(t, err)
 
-	// Assert that this new HTLC is not counted on Alice's local commitment
-	// in the dust sum. Bob's commitment should count it.
-	checkDust(aliceChannel, htlc2Amt, htlc2Amt+htlc3Amt)
-	checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt)
+	require.NoError(t, aliceChannel.DiscardPendingRemoteCommit())
 
-	// Alice will now send UpdateFee with a large feerate and neither
-	// perspective should change.
-	fee = chainfee.SatPerKWeight(50_000)
-	err = aliceChannel.UpdateFee(fee)
+	// The commitment is gone, both from disk and from the remote chain,
+	// and so is the HTLC it added.
+	_, err = aliceChannel.channelState.RemoteCommitChainTip()
+	require.ErrorIs(t, err, channeldb.ErrNoPendingCommit)
+	require.False(t, aliceChannel.remoteCommitChain.hasUnackedCommitment())
+	require.Nil(t, aliceChannel.localUpdateLog.lookupHtlc(0))
+	require.Zero(t, aliceChannel.localUpdateLog.htlcCounter)
+
+	// Nothing is left to discard.
+	err = aliceChannel.DiscardPendingRemoteCommit()
+	require.ErrorIs(t, err, ErrNoPendingRemoteCommit)
+
+	// Alice signs a new commitment, which includes Bob's HTLC, and Bob
+	// accepts it.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
 	require.NoError(t, err)
-	err = bobChannel.ReceiveUpdateFee(fee)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
 	require.NoError(t, err)
-	checkDust(aliceChannel, htlc2Amt, htlc2Amt+htlc3Amt)
-	checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt)
-
-	// Forcing a state transition should change in the non-zero-fee case.
-	err = ForceStateTransition(aliceChannel, bobChannel)
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
 	require.NoError(t, err)
-	if chantype.ZeroHtlcTxFee() {
-		checkDust(aliceChannel, htlc2Amt, htlc2Amt+htlc3Amt)
-		checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt)
-	} else {
-		checkDust(aliceChannel, htlc2Amt+htlc3Amt, htlc2Amt+htlc3Amt)
-		checkDust(bobChannel, htlc2Amt+htlc3Amt, htlc2Amt+htlc3Amt)
-	}
-}
-
-// deriveDummyRetributionParams is a helper function that derives a list of
-// dummy params to assist retribution creation related tests.
-func deriveDummyRetributionParams(chanState *channeldb.OpenChannel) (uint32,
-	*CommitmentKeyRing, chainhash.Hash) {
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+	require.Len(t, bobChannel.channelState.LocalCommitment.Htlcs, 1)
 
-	config := chanState.RemoteChanCfg
-	commitHash := chanState.RemoteCommitment.CommitTx.TxHash()
-	keyRing := DeriveCommitmentKeys(
-		config.RevocationBasePoint.PubKey, false, chanState.ChanType,
-		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
-	)
-	leaseExpiry := chanState.ThawHeight
-	return leaseExpiry, keyRing, commitHash
+	// The channel continues to operate normally, with the HTLC index of
+	// Alice reused.
+	_, err = aliceChannel.AddHTLC(aliceHtlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(aliceHtlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	require.Len(t, bobChannel.channelState.LocalCommitment.Htlcs, 2)
 }
 
-// TestCreateHtlcRetribution checks that `createHtlcRetribution` behaves as
-// epxected.
-func TestCreateHtlcRetribution(t *testing.T) {
+// TestDiscardPendingRemoteCommitUnsafe asserts that a pending remote
+// commitment isn't discarded if this would leave the channel in an
+// inconsistent state.
+func TestDiscardPendingRemoteCommitUnsafe(t *testing.T) {
 	t.Parallel()
 
-	// Create a dummy private key and an HTLC amount for testing.
-	dummyPrivate, _ := btcec.PrivKeyFromBytes([]byte{1})
-	testAmt := btcutil.Amount(100)
-
-	// Create a test channel.
-	aliceChannel, _, err := CreateTestChannels(
-		t, channeldb.ZeroHtlcTxFeeBit,
-	)
-	require.NoError(t, err)
-
-	// Prepare the params needed to call the function. Note that the values
-	// here are not necessary "cryptography-correct", we just use them to
-	// construct the htlc retribution.
-	leaseExpiry, keyRing, commitHash := deriveDummyRetributionParams(
-		aliceChannel.channelState,
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
-	htlc := &channeldb.HTLCEntry{
-		Amt:         testAmt,
-		Incoming:    true,
-		OutputIndex: 1,
-	}
+	require.NoError(t, err, "unable to create test channels")
 
-	// Create the htlc retribution.
-	hr, err := createHtlcRetribution(
-		aliceChannel.channelState, keyRing, commitHash,
-		dummyPrivate, leaseExpiry, htlc,
-	)
-	// Expect no error.
+	// Once Bob revoked his prior commitment, the commitment isn't pending
+	// anymore.
+	htlc, preimage := createHTLC(0, lnwire.MilliSatoshi(500000))
+	_, err = bobChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
 
-	// Check the fields have expected values.
-	require.EqualValues(t, testAmt, hr.SignDesc.Output.Value)
-	require.Equal(t, commitHash, hr.OutPoint.Hash)
-	require.EqualValues(t, htlc.OutputIndex, hr.OutPoint.Index)
-	require.Equal(t, htlc.Incoming, hr.IsIncoming)
-}
-
-// TestCreateBreachRetribution checks that `createBreachRetribution` behaves as
-// expected.
-func TestCreateBreachRetribution(t *testing.T) {
-	t.Parallel()
+	err = aliceChannel.DiscardPendingRemoteCommit()
+	require.ErrorIs(t, err, ErrNoPendingRemoteCommit)
 
-	// Create dummy values for the test.
-	dummyPrivate, _ := btcec.PrivKeyFromBytes([]byte{1})
-	testAmt := int64(100)
-	ourAmt := int64(1000)
-	theirAmt := int64(2000)
-	localIndex := uint32(0)
-	remoteIndex := uint32(1)
-	htlcIndex := uint32(2)
+	// A commitment settling an HTLC can't be discarded.
+	err = aliceChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
 
-	// Create a dummy breach tx, which has our output located at index 0
-	// and theirs at 1.
-	spendTx := &wire.MsgTx{
-		TxOut: []*wire.TxOut{
-			{Value: ourAmt},
-			{Value: theirAmt},
-			{Value: testAmt},
-		},
-	}
+	err = aliceChannel.DiscardPendingRemoteCommit()
+	require.ErrorIs(t, err, ErrCannotDiscardRemoteCommit)
+	require.True(t, aliceChannel.remoteCommitChain.hasUnackedCommitment())
 
-	// Create a test channel.
-	aliceChannel, _, err := CreateTestChannels(
-		t, channeldb.ZeroHtlcTxFeeBit,
+	// Neither can a commitment if updates were added after signing it.
+	aliceChannel, _, err = CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
+	require.NoError(t, err, "unable to create test channels")
+
+	htlc, _ = createHTLC(0, lnwire.MilliSatoshi(500000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	htlc, _ = createHTLC(1, lnwire.MilliSatoshi(500000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
 	require.NoError(t, err)
 
-	// Prepare the params needed to call the function. Note that the values
-	// here are not necessary "cryptography-correct", we just use them to
-	// construct the retribution.
-	leaseExpiry, keyRing, commitHash := deriveDummyRetributionParams(
-		aliceChannel.channelState,
+	err = aliceChannel.DiscardPendingRemoteCommit()
+	require.ErrorIs(t, err, ErrCannotDiscardRemoteCommit)
+
+	// Commitments of taproot channels can't be discarded either.
+	aliceChannel, _, err = CreateTestChannels(
+		t, channeldb.SimpleTaprootFeatureBit|
+			channeldb.SingleFunderTweaklessBit,
 	)
-	htlc := &channeldb.HTLCEntry{
-		Amt:         btcutil.Amount(testAmt),
-		Incoming:    true,
-		OutputIndex: uint16(htlcIndex),
-	}
+	require.NoError(t, err, "unable to create test channels")
 
-	// Create a dummy revocation log.
-	ourAmtMsat := lnwire.MilliSatoshi(ourAmt * 1000)
-	theirAmtMsat := lnwire.MilliSatoshi(theirAmt * 1000)
-	revokedLog := channeldb.RevocationLog{
-		CommitTxHash:     commitHash,
-		OurOutputIndex:   uint16(localIndex),
-		TheirOutputIndex: uint16(remoteIndex),
-		HTLCEntries:      []*channeldb.HTLCEntry{htlc},
-		TheirBalance:     &theirAmtMsat,
-		OurBalance:       &ourAmtMsat,
-	}
+	htlc, _ = createHTLC(0, lnwire.MilliSatoshi(500000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
 
-	// Create a log with an empty local output index.
-	revokedLogNoLocal := revokedLog
-	revokedLogNoLocal.OurOutputIndex = channeldb.OutputIndexEmpty
+	err = aliceChannel.DiscardPendingRemoteCommit()
+	require.ErrorIs(t, err, ErrCannotDiscardRemoteCommit)
+}
 
-	// Create a log with an empty remote output index.
-	revokedLogNoRemote := revokedLog
-	revokedLogNoRemote.TheirOutputIndex = channeldb.OutputIndexEmpty
+// TestCircularHtlc asserts that an HTLC whose payment hash is pending in the
+// opposite direction of the channel is flagged, and rejected if circular
+// HTLCs are rejected.
+func TestCircularHtlc(t *testing.T) {
+	t.Parallel()
 
-	testCases := []struct {
-		name             string
-		revocationLog    *channeldb.RevocationLog
-		expectedErr      error
-		expectedOurAmt   int64
-		expectedTheirAmt int64
-		noSpendTx        bool
-	}{
-		{
-			name: "create retribution successfully " +
-				"with spend tx",
-			revocationLog:    &revokedLog,
-			expectedErr:      nil,
-			expectedOurAmt:   ourAmt,
-			expectedTheirAmt: theirAmt,
-		},
-		{
-			name: "create retribution successfully " +
-				"without spend tx",
-			revocationLog:    &revokedLog,
-			expectedErr:      nil,
-			expectedOurAmt:   ourAmt,
-			expectedTheirAmt: theirAmt,
-			noSpendTx:        true,
-		},
-		{
-			name: "fail due to our index too big",
-			revocationLog: &channeldb.RevocationLog{
-				OurOutputIndex: uint16(htlcIndex + 1),
-			},
-			expectedErr: ErrOutputIndexOutOfRange,
-		},
-		{
-			name: "fail due to their index too big",
-			revocationLog: &channeldb.RevocationLog{
-				TheirOutputIndex: uint16(htlcIndex + 1),
-			},
-			expectedErr: ErrOutputIndexOutOfRange,
-		},
-		{
-			name: "empty local output index with spend " +
-				"tx",
-			revocationLog:    &revokedLogNoLocal,
-			expectedErr:      nil,
-			expectedOurAmt:   0,
-			expectedTheirAmt: theirAmt,
-		},
-		{
-			name: "empty local output index without spend " +
-				"tx",
-			revocationLog:    &revokedLogNoLocal,
-			expectedErr:      nil,
-			expectedOurAmt:   0,
-			expectedTheirAmt: theirAmt,
-			noSpendTx:        true,
-		},
-		{
-			name: "empty remote output index with spend " +
-				"tx",
-			revocationLog:    &revokedLogNoRemote,
-			expectedErr:      nil,
-			expectedOurAmt:   ourAmt,
-			expectedTheirAmt: 0,
-		},
-		{
-			name: "empty remote output index without spend " +
-				"tx",
-			revocationLog:    &revokedLogNoRemote,
-			expectedErr:      nil,
-			expectedOurAmt:   ourAmt,
-			expectedTheirAmt: 0,
-			noSpendTx:        true,
-		},
-	}
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
 
-	// assertRetribution is a helper closure that checks a given breach
-	// retribution has the expected values on certain fields.
-	assertRetribution := func(br *BreachRetribution, our, their int64) {
-		chainHash := aliceChannel.channelState.ChainHash
-		require.Equal(t, commitHash, br.BreachTxHash)
-		require.Equal(t, chainHash, br.ChainHash)
+	// Alice sends an HTLC to Bob.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20_000)
+	aliceHtlc, preimage := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(aliceHtlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(aliceHtlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
 
-		// Construct local outpoint, we only have the index when the
-		// amount is not zero.
-		local := wire.OutPoint{
-			Hash: commitHash,
-		}
-		if our != 0 {
-			local.Index = localIndex
-		}
+	// Bob sends an HTLC with the same payment hash back to Alice. By
+	// default, both parties only flag it.
+	bobHtlc, _ := createHTLC(0, htlcAmt)
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err)
 
-		// Construct remote outpoint, we only have the index when the
-		// amount is not zero.
-		remote := wire.OutPoint{
-			Hash: commitHash,
-		}
-		if their != 0 {
-			remote.Index = remoteIndex
-		}
+	require.EqualValues(t, 1, aliceChannel.ChannelMetrics().CircularHtlcs)
+	require.EqualValues(t, 1, bobChannel.ChannelMetrics().CircularHtlcs)
 
-		require.Equal(t, local, br.LocalOutpoint)
-		require.Equal(t, remote, br.RemoteOutpoint)
+	// Once both parties reject circular HTLCs, the next one is rejected
+	// on either side.
+	aliceChannel.rejectCircularHtlcs = true
+	bobChannel.rejectCircularHtlcs = true
 
-		for _, hr := range br.HtlcRetributions {
-			require.EqualValues(t, testAmt,
-				hr.SignDesc.Output.Value)
-			require.Equal(t, commitHash, hr.OutPoint.Hash)
-			require.EqualValues(t, htlcIndex, hr.OutPoint.Index)
-			require.Equal(t, htlc.Incoming, hr.IsIncoming)
-		}
-	}
+	bobHtlc.ID = 1
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
+	require.ErrorIs(t, err, ErrCircularHtlc)
 
-	for _, tc := range testCases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			tx := spendTx
-			if tc.noSpendTx {
-				tx = nil
-			}
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+	require.ErrorIs(t, err, ErrCircularHtlc)
 
-			br, our, their, err := createBreachRetribution(
-				tc.revocationLog, tx,
-				aliceChannel.channelState, keyRing,
-				dummyPrivate, leaseExpiry,
-			)
+	require.EqualValues(t, 2, aliceChannel.ChannelMetrics().CircularHtlcs)
+	require.EqualValues(t, 2, bobChannel.ChannelMetrics().CircularHtlcs)
 
-			// Check the error if expected.
-			if tc.expectedErr != nil {
-				require.ErrorIs(t, err, tc.expectedErr)
-			} else {
-				// Otherwise we expect no error.
-				require.NoError(t, err)
+	// Once Bob settles Alice's HTLC, it's no longer pending, so the
+	// payment hash can be used in the opposite direction again.
+	err = bobChannel.SettleHTLC(preimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveHTLCSettle(preimage, 0)
+	require.NoError(t, err)
 
-				// Check the amounts and the contructed partial
-				// retribution are returned as expected.
-				require.Equal(t, tc.expectedOurAmt, our)
-				require.Equal(t, tc.expectedTheirAmt, their)
-				assertRetribution(br, our, their)
-			}
-		})
-	}
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err)
 }
 
-// TestCreateBreachRetributionLegacy checks that
-// `createBreachRetributionLegacy` behaves as expected.
-func TestCreateBreachRetributionLegacy(t *testing.T) {
+// TestHTLCsByCommitment asserts that the HTLCs on the current commitments of
+// both parties are reported separately, as they differ in the middle of a
+// state transition.
+func TestHTLCsByCommitment(t *testing.T) {
 	t.Parallel()
 
-	// Create dummy values for the test.
-	dummyPrivate, _ := btcec.PrivKeyFromBytes([]byte{1})
-
-	// Create a test channel.
-	aliceChannel, _, err := CreateTestChannels(
-		t, channeldb.ZeroHtlcTxFeeBit,
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
-	require.NoError(t, err)
+	require.NoError(t, err, "unable to create test channels")
 
-	// Prepare the params needed to call the function. Note that the values
-	// here are not necessary "cryptography-correct", we just use them to
-	// construct the retribution.
-	leaseExpiry, keyRing, _ := deriveDummyRetributionParams(
-		aliceChannel.channelState,
-	)
+	// assertHtlcs asserts that the channel has the given number of HTLCs
+	// on its local and remote commitment, all of which in the given
+	// direction.
+	assertHtlcs := func(channel *LightningChannel, numLocal,
+		numRemote int, incoming bool) {
 
-	// Use the remote commitment as our revocation log.
-	revokedLog := aliceChannel.channelState.RemoteCommitment
+		t.Helper()
 
-	ourOp := revokedLog.CommitTx.TxOut[0]
-	theirOp := revokedLog.CommitTx.TxOut[1]
+		localHtlcs, remoteHtlcs := channel.HTLCsByCommitment()
+		require.Len(t, localHtlcs, numLocal)
+		require.Len(t, remoteHtlcs, numRemote)
 
-	// Create the dummy scripts.
-	ourScript := &WitnessScriptDesc{
-		OutputScript: ourOp.PkScript,
-	}
-	theirScript := &WitnessScriptDesc{
-		OutputScript: theirOp.PkScript,
+		for _, htlc := range append(localHtlcs, remoteHtlcs...) {
+			require.Equal(t, incoming, htlc.Incoming)
+			require.EqualValues(t, 0, htlc.HtlcIndex)
+		}
 	}
 
-	// Create the breach retribution using the legacy format.
-	br, ourAmt, theirAmt, err := createBreachRetributionLegacy(
-		&revokedLog, aliceChannel.channelState, keyRing,
-		dummyPrivate, ourScript, theirScript, leaseExpiry,
-	)
+	htlc, _ := createHTLC(0, lnwire.NewMSatFromSatoshis(20_000))
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
 	require.NoError(t, err)
 
-	// Check the commitHash and chainHash.
-	commitHash := revokedLog.CommitTx.TxHash()
-	chainHash := aliceChannel.channelState.ChainHash
-	require.Equal(t, commitHash, br.BreachTxHash)
-	require.Equal(t, chainHash, br.ChainHash)
-
-	// Check the outpoints.
-	local := wire.OutPoint{
-		Hash:  commitHash,
-		Index: 0,
-	}
-	remote := wire.OutPoint{
-		Hash:  commitHash,
-		Index: 1,
-	}
-	require.Equal(t, local, br.LocalOutpoint)
-	require.Equal(t, remote, br.RemoteOutpoint)
+	// Alice signs a commitment for Bob covering the HTLC. As long as Bob
+	// didn't revoke his prior commitment, it's not locked in anywhere.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
 
-	// Validate the amounts, note that in the legacy format, our amount is
-	// not directly the amount found in the to local output. Rather, it's
-	// the local output value minus the commit fee and anchor value(if
-	// present).
-	require.EqualValues(t, revokedLog.LocalBalance.ToSatoshis(), ourAmt)
-	require.Equal(t, theirOp.Value, theirAmt)
-}
+	assertHtlcs(aliceChannel, 0, 0, false)
+	assertHtlcs(bobChannel, 0, 0, true)
 
-// TestNewBreachRetribution tests that the function `NewBreachRetribution`
-// behaves as expected.
-func TestNewBreachRetribution(t *testing.T) {
-	t.Run("non-anchor", func(t *testing.T) {
-		testNewBreachRetribution(t, channeldb.ZeroHtlcTxFeeBit)
-	})
-	t.Run("anchor", func(t *testing.T) {
-		chanType := channeldb.SingleFunderTweaklessBit |
-			channeldb.AnchorOutputsBit
-		testNewBreachRetribution(t, chanType)
-	})
-}
+	// Once Bob revokes his prior commitment, the HTLC is on his
+	// commitment, but not yet on Alice's.
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
 
-// testNewBreachRetribution takes a channel type and tests the function
-// `NewBreachRetribution`.
-func testNewBreachRetribution(t *testing.T, chanType channeldb.ChannelType) {
-	t.Parallel()
+	assertHtlcs(aliceChannel, 0, 1, false)
+	assertHtlcs(bobChannel, 1, 0, true)
 
-	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	// Completing the state transition puts the HTLC on both commitments.
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
 	require.NoError(t, err)
 
-	breachHeight := uint32(101)
-	stateNum := uint64(0)
-	chainHash := aliceChannel.channelState.ChainHash
-	theirDelay := uint32(aliceChannel.channelState.RemoteChanCfg.CsvDelay)
-	breachTx := aliceChannel.channelState.RemoteCommitment.CommitTx
+	assertHtlcs(aliceChannel, 1, 1, false)
+	assertHtlcs(bobChannel, 1, 1, true)
+}
 
-	// Create a breach retribution at height 0, which should give us an
-	// error as there are no past delta state saved as revocation logs yet.
-	_, err = NewBreachRetribution(
-		aliceChannel.channelState, stateNum, breachHeight, breachTx,
-	)
-	require.ErrorIs(t, err, channeldb.ErrNoPastDeltas)
+// TestReserveObserver asserts that a ReserveWarning is reported when a party
+// is below its reserve after settled HTLCs are locked in, without affecting
+// the state transition.
+func TestReserveObserver(t *testing.T) {
+	t.Parallel()
 
-	// We also check that the same error is returned if no breach tx is
-	// provided.
-	_, err = NewBreachRetribution(
-		aliceChannel.channelState, stateNum, breachHeight, nil,
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
 	)
-	require.ErrorIs(t, err, channeldb.ErrNoPastDeltas)
-
-	// We now force a state transition which will give us a revocation log
-	// at height 0.
-	txid := aliceChannel.channelState.RemoteCommitment.CommitTx.TxHash()
-	err = ForceStateTransition(aliceChannel, bobChannel)
-	require.NoError(t, err)
+	require.NoError(t, err, "unable to create test channels")
 
-	// assertRetribution is a helper closure that checks a given breach
-	// retribution has the expected values on certain fields.
-	assertRetribution := func(br *BreachRetribution,
-		localIndex, remoteIndex uint32) {
+	var warnings []ReserveWarning
+	aliceChannel.SetReserveObserver(func(warning ReserveWarning) {
+		warnings = append(warnings, warning)
+	})
 
-		require.Equal(t, txid, br.BreachTxHash)
-		require.Equal(t, chainHash, br.ChainHash)
-		require.Equal(t, breachHeight, br.BreachHeight)
-		require.Equal(t, stateNum, br.RevokedStateNum)
-		require.Equal(t, theirDelay, br.RemoteDelay)
+	// sendAndSettle sends an HTLC from Alice to Bob, and has Bob settle
+	// it.
+	sendAndSettle := func(htlcIndex uint64, beforeSettle func()) {
+		t.Helper()
 
-		local := wire.OutPoint{
-			Hash:  txid,
-			Index: localIndex,
-		}
-		remote := wire.OutPoint{
-			Hash:  txid,
-			Index: remoteIndex,
-		}
+		htlc, preimage := createHTLC(
+			int(htlcIndex), lnwire.NewMSatFromSatoshis(20_000),
+		)
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
 
-		if chanType.HasAnchors() {
-			// For anchor channels, we expect the local delay to be
-			// 1 otherwise 0.
-			require.EqualValues(t, 1, br.LocalDelay)
-		} else {
-			require.Zero(t, br.LocalDelay)
-		}
+		beforeSettle()
 
-		require.Equal(t, local, br.LocalOutpoint)
-		require.Equal(t, remote, br.RemoteOutpoint)
+		err = bobChannel.SettleHTLC(preimage, htlcIndex, nil, nil, nil)
+		require.NoError(t, err)
+		err = aliceChannel.ReceiveHTLCSettle(preimage, htlcIndex)
+		require.NoError(t, err)
+		err = ForceStateTransition(bobChannel, aliceChannel)
+		require.NoError(t, err)
 	}
 
-	// Create the retribution again and we should expect it to be created
-	// successfully.
-	br, err := NewBreachRetribution(
-		aliceChannel.channelState, stateNum, breachHeight, breachTx,
-	)
-	require.NoError(t, err)
+	// With both parties above their reserves, nothing is reported.
+	sendAndSettle(0, func() {})
+	require.Empty(t, warnings)
 
-	// Check the retribution is as expected.
-	t.Log(spew.Sdump(breachTx))
-	assertRetribution(br, 1, 0)
+	// Raise Alice's reserve to the channel capacity once the second HTLC
+	// is locked in, which the settle can't fix. The warning is reported,
+	// but the state transition still completes.
+	localReserve := aliceChannel.channelState.Capacity
+	sendAndSettle(1, func() {
+		localCfg := &aliceChannel.channelState.LocalChanCfg
+		localCfg.ChanReserve = localReserve
+	})
+	require.Len(t, warnings, 1)
 
-	// Repeat the check but with the breach tx set to nil. This should work
-	// since the necessary info should now be found in the revocation log.
-	br, err = NewBreachRetribution(
-		aliceChannel.channelState, stateNum, breachHeight, nil,
+	warning := warnings[0]
+	require.True(t, warning.LocalBelowReserve())
+	require.False(t, warning.RemoteBelowReserve())
+	require.Equal(t, localReserve, warning.LocalReserve)
+	require.Equal(
+		t, aliceChannel.remoteCommitChain.tail().height, warning.Height,
 	)
-	require.NoError(t, err)
-	assertRetribution(br, 1, 0)
-
-	// Create the retribution using a stateNum+1 and we should expect an
-	// error.
-	_, err = NewBreachRetribution(
-		aliceChannel.channelState, stateNum+1, breachHeight, breachTx,
+	require.Equal(
+		t, aliceChannel.channelState.RemoteCommitment.LocalBalance,
+		warning.LocalBalance,
 	)
-	require.ErrorIs(t, err, channeldb.ErrLogEntryNotFound)
 
-	// Once again, repeat the check for the case when no breach tx is
-	// provided.
-	_, err = NewBreachRetribution(
-		aliceChannel.channelState, stateNum+1, breachHeight, nil,
+	aliceBalance := aliceChannel.channelState.LocalCommitment.LocalBalance
+	bobBalance := bobChannel.channelState.LocalCommitment.RemoteBalance
+	require.Equal(t, aliceBalance, bobBalance)
+	require.Equal(
+		t, aliceChannel.StateSnapshot().LocalBalance, aliceBalance,
 	)
-	require.ErrorIs(t, err, channeldb.ErrLogEntryNotFound)
 }
@@ -0,0 +1,157 @@
+package lnwallet
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightninglabs/neutrino/cache"
+	"github.com/lightninglabs/neutrino/cache/lru"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/shachain"
+)
+
+// commitPointCacheSize is the number of commitment secrets we keep around
+// per channel. The heights in use at any time are clustered around our
+// current commitment height, so a handful of entries suffice.
+const commitPointCacheSize = 8
+
+// cachedCommitSecret is a commitment secret derived from our revocation
+// producer, along with the commitment point derived from it.
+type cachedCommitSecret struct {
+	// secret is the commitment secret, which is revealed to the remote
+	// party once the commitment is revoked.
+	secret chainhash.Hash
+
+	// point is the commitment point matching the secret. It's only
+	// computed once it's first requested.
+	point *btcec.PublicKey
+}
+
+// Size returns the "size" of an entry. We return 1 as we just want to limit
+// the total number of entries.
+func (c *cachedCommitSecret) Size() (uint64, error) {
+	return 1, nil
+}
+
+// commitPointCache is an LRU cache of the commitment secrets and points of
+// our commitment chain, keyed by commitment height. Deriving a secret walks
+// the shachain, and deriving a point from it requires a scalar
+// multiplication, while the same few heights are requested over and over
+// again during restoration and state transitions.
+type commitPointCache struct {
+	// producer is the revocation producer the cached entries were
+	// derived from. If the channel's producer is replaced, all entries
+	// are discarded.
+	producer shachain.Producer
+
+	// size is the maximum number of cached entries.
+	size uint64
+
+	entries *lru.Cache[uint64, *cachedCommitSecret]
+
+	mtx sync.Mutex
+}
+
+// newCommitPointCache creates a new cache holding up to size commitment
+// secrets.
+func newCommitPointCache(size uint64) *commitPointCache {
+	return &commitPointCache{
+		size:    size,
+		entries: lru.NewCache[uint64, *cachedCommitSecret](size),
+	}
+}
+
+// lookup returns the cached entry for the given height, deriving it from the
+// passed producer if it's not cached yet.
+//
+// NOTE: This method requires the cache's mutex to be held.
+func (c *commitPointCache) lookup(producer shachain.Producer,
+	height uint64) (*cachedCommitSecret, error) {
+
+	// The cached entries are only valid for the producer they were
+	// derived from.
+	if c.producer != producer {
+		c.entries = lru.NewCache[uint64, *cachedCommitSecret](c.size)
+		c.producer = producer
+	}
+
+	entry, err := c.entries.Get(height)
+	switch {
+	case err == nil:
+		return entry, nil
+
+	case err != cache.ErrElementNotFound:
+		return nil, err
+	}
+
+	secret, err := producer.AtIndex(height)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &cachedCommitSecret{secret: *secret}
+	if _, err := c.entries.Put(height, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// secret returns the commitment secret of the given height derived from the
+// passed producer.
+func (c *commitPointCache) secret(producer shachain.Producer,
+	height uint64) (*chainhash.Hash, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	entry, err := c.lookup(producer, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return a copy, so the caller can't modify the cached secret.
+	secret := entry.secret
+
+	return &secret, nil
+}
+
+// point returns the commitment point of the given height derived from the
+// passed producer.
+func (c *commitPointCache) point(producer shachain.Producer,
+	height uint64) (*btcec.PublicKey, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	entry, err := c.lookup(producer, height)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.point == nil {
+		entry.point = input.ComputeCommitmentPoint(entry.secret[:])
+	}
+
+	return entry.point, nil
+}
+
+// commitSecretAt returns the secret of our commitment at the given height.
+func (lc *LightningChannel) commitSecretAt(
+	height uint64) (*chainhash.Hash, error) {
+
+	return lc.commitPoints.secret(
+		lc.channelState.RevocationProducer, height,
+	)
+}
+
+// commitPointAt returns the commitment point of our commitment at the given
+// height.
+func (lc *LightningChannel) commitPointAt(
+	height uint64) (*btcec.PublicKey, error) {
+
+	return lc.commitPoints.point(
+		lc.channelState.RevocationProducer, height,
+	)
+}
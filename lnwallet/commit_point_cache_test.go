@@ -0,0 +1,91 @@
+package lnwallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/shachain"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCommitPointCache asserts that the commitment point cache returns the
+// values of its producer, evicts the least recently used heights and is
+// invalidated once the producer changes.
+func TestCommitPointCache(t *testing.T) {
+	t.Parallel()
+
+	producer := shachain.NewRevocationProducer(chainhash.Hash{1})
+	c := newCommitPointCache(2)
+
+	// assertEntry asserts that the cache returns the secret and point
+	// derived from the passed producer at the given height.
+	assertEntry := func(producer shachain.Producer, height uint64) {
+		t.Helper()
+
+		expectedSecret, err := producer.AtIndex(height)
+		require.NoError(t, err)
+
+		secret, err := c.secret(producer, height)
+		require.NoError(t, err)
+		require.Equal(t, expectedSecret, secret)
+
+		point, err := c.point(producer, height)
+		require.NoError(t, err)
+		require.Equal(
+			t, input.ComputeCommitmentPoint(expectedSecret[:]),
+			point,
+		)
+	}
+
+	assertEntry(producer, 100_000)
+	assertEntry(producer, 100_001)
+	require.Equal(t, 2, c.entries.Len())
+
+	// Modifying a returned secret mustn't affect the cache.
+	secret, err := c.secret(producer, 100_000)
+	require.NoError(t, err)
+	secret[0] ^= 1
+	assertEntry(producer, 100_000)
+
+	// A third height evicts the least recently used one.
+	assertEntry(producer, 100_002)
+	require.Equal(t, 2, c.entries.Len())
+	_, err = c.entries.Get(100_001)
+	require.Error(t, err)
+
+	// Once the producer changes, the entries derived from the prior one
+	// are discarded.
+	newProducer := shachain.NewRevocationProducer(chainhash.Hash{2})
+	assertEntry(newProducer, 100_000)
+	require.Equal(t, 1, c.entries.Len())
+}
+
+// TestCommitPointCacheProducerChange asserts that the channel derives its
+// commitment points from its current revocation producer, even if it's
+// replaced after the points of the prior one were cached.
+func TestCommitPointCacheProducerChange(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, _, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	oldPoint, err := aliceChannel.NextRevocationKey()
+	require.NoError(t, err)
+
+	producer := shachain.NewRevocationProducer(chainhash.Hash{1})
+	aliceChannel.channelState.RevocationProducer = producer
+
+	newSecret, err := producer.AtIndex(aliceChannel.currentHeight + 1)
+	require.NoError(t, err)
+
+	newPoint, err := aliceChannel.NextRevocationKey()
+	require.NoError(t, err)
+	require.NotEqual(t, oldPoint, newPoint)
+	require.Equal(
+		t, input.ComputeCommitmentPoint(newSecret[:]), newPoint,
+	)
+}
@@ -485,23 +485,54 @@ func CommitWeight(chanType channeldb.ChannelType) int64 {
 	}
 }
 
+// HtlcTxWeights is the weight profile of the second-level HTLC transactions of
+// a channel type.
+type HtlcTxWeights struct {
+	// Timeout is the weight of the HTLC timeout transaction.
+	Timeout int64
+
+	// Success is the weight of the HTLC success transaction.
+	Success int64
+}
+
+// HtlcTxWeightsForType returns the weights of the second-level HTLC
+// transactions for the given channel type. The HTLC outputs of anchor
+// channels carry an additional CSV 1 script overhead, which increases the
+// weight of the transactions spending them.
+func HtlcTxWeightsForType(chanType channeldb.ChannelType) HtlcTxWeights {
+	switch {
+	case chanType.IsTaproot():
+		return HtlcTxWeights{
+			Timeout: input.TaprootHtlcTimeoutWeight,
+			Success: input.TaprootHtlcSuccessWeight,
+		}
+
+	case chanType.HasAnchors():
+		return HtlcTxWeights{
+			Timeout: input.HtlcTimeoutWeightConfirmed,
+			Success: input.HtlcSuccessWeightConfirmed,
+		}
+
+	default:
+		return HtlcTxWeights{
+			Timeout: input.HtlcTimeoutWeight,
+			Success: input.HtlcSuccessWeight,
+		}
+	}
+}
+
 // HtlcTimeoutFee returns the fee in satoshis required for an HTLC timeout
 // transaction based on the current fee rate.
 func HtlcTimeoutFee(chanType channeldb.ChannelType,
 	feePerKw chainfee.SatPerKWeight) btcutil.Amount {
 
-	switch {
 	// For zero-fee HTLC channels, this will always be zero, regardless of
 	// feerate.
-	case chanType.ZeroHtlcTxFee() || chanType.IsTaproot():
+	if chanType.ZeroHtlcTxFee() || chanType.IsTaproot() {
 		return 0
-
-	case chanType.HasAnchors():
-		return feePerKw.FeeForWeight(input.HtlcTimeoutWeightConfirmed)
-
-	default:
-		return feePerKw.FeeForWeight(input.HtlcTimeoutWeight)
 	}
+
+	return feePerKw.FeeForWeight(HtlcTxWeightsForType(chanType).Timeout)
 }
 
 // HtlcSuccessFee returns the fee in satoshis required for an HTLC success
@@ -509,18 +540,13 @@ func HtlcTimeoutFee(chanType channeldb.ChannelType,
 func HtlcSuccessFee(chanType channeldb.ChannelType,
 	feePerKw chainfee.SatPerKWeight) btcutil.Amount {
 
-	switch {
 	// For zero-fee HTLC channels, this will always be zero, regardless of
 	// feerate.
-	case chanType.ZeroHtlcTxFee() || chanType.IsTaproot():
+	if chanType.ZeroHtlcTxFee() || chanType.IsTaproot() {
 		return 0
-
-	case chanType.HasAnchors():
-		return feePerKw.FeeForWeight(input.HtlcSuccessWeightConfirmed)
-
-	default:
-		return feePerKw.FeeForWeight(input.HtlcSuccessWeight)
 	}
+
+	return feePerKw.FeeForWeight(HtlcTxWeightsForType(chanType).Success)
 }
 
 // CommitScriptAnchors return the scripts to use for the local and remote
@@ -622,6 +648,11 @@ type CommitmentBuilder struct {
 	// obfuscator is a 48-bit state hint that's used to obfuscate the
 	// current state number on the commitment transactions.
 	obfuscator [StateHintSize]byte
+
+	// remoteObfuscator, if set, replaces obfuscator for the state hints of
+	// the remote commitments we create. It's only set in dev builds, see
+	// SetStateHintObfuscator.
+	remoteObfuscator *[StateHintSize]byte
 }
 
 // NewCommitmentBuilder creates a new CommitmentBuilder from chanState.
@@ -831,7 +862,11 @@ func (cb *CommitmentBuilder) createUnsignedCommitmentTx(ourBalance,
 	// Set the state hint of the commitment transaction to facilitate
 	// quickly recovering the necessary penalty state in the case of an
 	// uncooperative broadcast.
-	err = SetStateNumHint(commitTx, height, cb.obfuscator)
+	obfuscator := cb.obfuscator
+	if !isOurs && cb.remoteObfuscator != nil {
+		obfuscator = *cb.remoteObfuscator
+	}
+	err = SetStateNumHint(commitTx, height, obfuscator)
 	if err != nil {
 		return nil, err
 	}
@@ -61,4 +61,9 @@ type Config struct {
 	// passively rebroadcast transactions in the background until they're
 	// detected as being confirmed.
 	Rebroadcaster Rebroadcaster
+
+	// ReserveBelowDustPolicy determines how channel constraints with a
+	// channel reserve below the dust limit are handled. By default, such
+	// constraints are rejected as mandated by BOLT #2.
+	ReserveBelowDustPolicy ReserveBelowDustPolicy
 }
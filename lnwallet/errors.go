@@ -59,6 +59,15 @@ func ErrCsvDelayTooLarge(remoteDelay, maxDelay uint16) ReservationError {
 	}
 }
 
+// ErrCsvDelayZero returns an error indicating that a CSV delay of zero was
+// proposed, which would allow the owner of a revoked commitment to sweep their
+// output before it can be penalized.
+func ErrCsvDelayZero() ReservationError {
+	return ReservationError{
+		fmt.Errorf("CSV delay of zero not allowed"),
+	}
+}
+
 // ErrChanReserveTooSmall returns an error indicating that the channel reserve
 // the remote is requiring is too small to be accepted.
 func ErrChanReserveTooSmall(reserve, dustLimit btcutil.Amount) ReservationError {
@@ -192,6 +201,53 @@ func (e ErrInvalidSettlePreimage) Error() string {
 		e.preimage, e.rhash)
 }
 
+// ErrHtlcExpiryTooSoon is returned when receiving an HTLC whose expiry doesn't
+// leave enough blocks relative to the current height to safely forward it.
+type ErrHtlcExpiryTooSoon struct {
+	expiry        uint32
+	currentHeight uint32
+	minDelta      uint32
+}
+
+// Error returns an error message with the offending expiry and the required
+// minimum.
+func (e ErrHtlcExpiryTooSoon) Error() string {
+	return fmt.Sprintf("HTLC expiry %d too soon: current height %d, "+
+		"minimum cltv delta %d", e.expiry, e.currentHeight, e.minDelta)
+}
+
+// FailCode returns the BOLT #4 failure code to use when failing an HTLC back
+// for this reason.
+func (e ErrHtlcExpiryTooSoon) FailCode() lnwire.FailCode {
+	return lnwire.CodeExpiryTooSoon
+}
+
+// ErrBalanceInvariant is returned when the balances, HTLCs and fee of a
+// commitment don't add up to the capacity of the channel, which indicates a bug
+// in the balance accounting.
+type ErrBalanceInvariant struct {
+	height       uint64
+	ourBalance   lnwire.MilliSatoshi
+	theirBalance lnwire.MilliSatoshi
+	htlcTotal    lnwire.MilliSatoshi
+	fee          btcutil.Amount
+	anchors      btcutil.Amount
+	capacity     btcutil.Amount
+}
+
+// Error returns an error message with the amounts of the offending
+// commitment.
+func (e ErrBalanceInvariant) Error() string {
+	total := e.ourBalance + e.theirBalance + e.htlcTotal +
+		lnwire.NewMSatFromSatoshis(e.fee+e.anchors)
+
+	return fmt.Sprintf("balance invariant violated at commitment "+
+		"height %d: our_balance=%v + their_balance=%v + htlcs=%v + "+
+		"fee=%v + anchors=%v = %v, expected capacity %v", e.height,
+		e.ourBalance, e.theirBalance, e.htlcTotal, e.fee, e.anchors,
+		total, e.capacity)
+}
+
 // ErrUnknownHtlcIndex is returned when locally settling or failing an HTLC, but
 // the HTLC index is not known to the channel. This typically indicates that the
 // HTLC was already settled in a prior commitment.
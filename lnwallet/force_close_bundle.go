@@ -0,0 +1,410 @@
+package lnwallet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+)
+
+// forceCloseBundleVersion is the version of the encoding of a
+// ForceCloseBundle produced by ExportForceCloseBundle.
+const forceCloseBundleVersion uint8 = 0
+
+// ForceCloseBundle holds everything needed to force close a channel and sweep
+// its outputs without access to the channel: our signed commitment
+// transaction, along with the resolutions of all the outputs we can claim from
+// it.
+type ForceCloseBundle struct {
+	// CloseTx is our latest fully signed commitment transaction.
+	CloseTx *wire.MsgTx
+
+	// CommitResolution is the resolution of our to_local output. It is nil
+	// if our balance is dust.
+	CommitResolution *CommitOutputResolution
+
+	// HtlcResolutions holds the resolutions of the HTLCs on CloseTx,
+	// including the signed second level transactions.
+	HtlcResolutions *HtlcResolutions
+
+	// AnchorResolution is the resolution of our anchor output. It is nil
+	// if the channel has no anchors, or our anchor isn't present on
+	// CloseTx.
+	AnchorResolution *AnchorResolution
+}
+
+// ExportSignedCommitment returns our latest commitment transaction, fully
+// signed and serialized in the wire format, so it can be broadcast by an
+// external tool.
+//
+// NOTE: Unlike ForceClose, the channel isn't marked as being in dispute. The
+// caller must stop using the channel before broadcasting the transaction.
+func (lc *LightningChannel) ExportSignedCommitment() ([]byte, error) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	commitTx, err := lc.exportableCommitTx()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := commitTx.Serialize(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// ExportForceCloseBundle serializes our latest signed commitment transaction
+// along with the resolutions of all the outputs we can claim from it, namely
+// the signed HTLC transactions and the sign descriptors needed to sweep the
+// outputs. This allows an air-gapped setup to hand the force close over to an
+// external tool, which can decode the bundle using DecodeForceCloseBundle.
+//
+// NOTE: The sign details of the HTLC resolutions are not included in the
+// bundle, as the exported HTLC transactions are already fully signed. Like for
+// ExportSignedCommitment, the caller must stop using the channel before
+// broadcasting the commitment.
+func (lc *LightningChannel) ExportForceCloseBundle() ([]byte, error) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	commitTx, err := lc.exportableCommitTx()
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := newLocalForceCloseSummary(
+		lc.channelState, lc.Signer, commitTx,
+		lc.channelState.LocalCommitment.CommitHeight, false,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to gen force close "+
+			"summary: %w", err)
+	}
+
+	bundle := &ForceCloseBundle{
+		CloseTx:          summary.CloseTx,
+		CommitResolution: summary.CommitResolution,
+		HtlcResolutions:  summary.HtlcResolutions,
+		AnchorResolution: summary.AnchorResolution,
+	}
+
+	var b bytes.Buffer
+	if err := bundle.encode(&b); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// exportableCommitTx returns our latest signed commitment transaction, unless
+// we've detected local data loss for this channel, in which case our
+// commitment may be outdated.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) exportableCommitTx() (*wire.MsgTx, error) {
+	if lc.channelState.HasChanStatus(channeldb.ChanStatusLocalDataLoss) {
+		return nil, fmt.Errorf("%w: channel_state=%v",
+			ErrForceCloseLocalDataLoss,
+			lc.channelState.ChanStatus())
+	}
+
+	return lc.getSignedCommitTx()
+}
+
+// DecodeForceCloseBundle decodes a bundle created by ExportForceCloseBundle.
+//
+// NOTE: Like for the resolutions stored by the channel arbitrator, the prev
+// output fetchers of the sign descriptors aren't encoded. They can be rebuilt
+// from the Output of each sign descriptor.
+func DecodeForceCloseBundle(b []byte) (*ForceCloseBundle, error) {
+	bundle := &ForceCloseBundle{}
+	if err := bundle.decode(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// encode writes the encoding of the bundle to the passed writer.
+func (f *ForceCloseBundle) encode(w io.Writer) error {
+	err := binary.Write(w, binary.BigEndian, forceCloseBundleVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := f.CloseTx.Serialize(w); err != nil {
+		return err
+	}
+
+	hasCommitRes := f.CommitResolution != nil
+	if err := binary.Write(w, binary.BigEndian, hasCommitRes); err != nil {
+		return err
+	}
+	if hasCommitRes {
+		res := f.CommitResolution
+		if err := writeOutPoint(w, &res.SelfOutPoint); err != nil {
+			return err
+		}
+		err := writeSignDesc(w, &res.SelfOutputSignDesc)
+		if err != nil {
+			return err
+		}
+		err = binary.Write(w, binary.BigEndian, res.MaturityDelay)
+		if err != nil {
+			return err
+		}
+	}
+
+	var incoming []IncomingHtlcResolution
+	var outgoing []OutgoingHtlcResolution
+	if f.HtlcResolutions != nil {
+		incoming = f.HtlcResolutions.IncomingHTLCs
+		outgoing = f.HtlcResolutions.OutgoingHTLCs
+	}
+
+	numIncoming := uint16(len(incoming))
+	if err := binary.Write(w, binary.BigEndian, numIncoming); err != nil {
+		return err
+	}
+	for i := range incoming {
+		htlc := &incoming[i]
+
+		if _, err := w.Write(htlc.Preimage[:]); err != nil {
+			return err
+		}
+		if err := writeOptionalTx(w, htlc.SignedSuccessTx); err != nil {
+			return err
+		}
+		err := binary.Write(w, binary.BigEndian, htlc.CsvDelay)
+		if err != nil {
+			return err
+		}
+		if err := writeOutPoint(w, &htlc.ClaimOutpoint); err != nil {
+			return err
+		}
+		if err := writeSignDesc(w, &htlc.SweepSignDesc); err != nil {
+			return err
+		}
+	}
+
+	numOutgoing := uint16(len(outgoing))
+	if err := binary.Write(w, binary.BigEndian, numOutgoing); err != nil {
+		return err
+	}
+	for i := range outgoing {
+		htlc := &outgoing[i]
+
+		err := binary.Write(w, binary.BigEndian, htlc.Expiry)
+		if err != nil {
+			return err
+		}
+		if err := writeOptionalTx(w, htlc.SignedTimeoutTx); err != nil {
+			return err
+		}
+		err = binary.Write(w, binary.BigEndian, htlc.CsvDelay)
+		if err != nil {
+			return err
+		}
+		if err := writeOutPoint(w, &htlc.ClaimOutpoint); err != nil {
+			return err
+		}
+		if err := writeSignDesc(w, &htlc.SweepSignDesc); err != nil {
+			return err
+		}
+	}
+
+	hasAnchorRes := f.AnchorResolution != nil
+	if err := binary.Write(w, binary.BigEndian, hasAnchorRes); err != nil {
+		return err
+	}
+	if !hasAnchorRes {
+		return nil
+	}
+
+	res := f.AnchorResolution
+	if err := writeSignDesc(w, &res.AnchorSignDescriptor); err != nil {
+		return err
+	}
+	if err := writeOutPoint(w, &res.CommitAnchor); err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, int64(res.CommitFee))
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w, binary.BigEndian, res.CommitWeight)
+}
+
+// decode reads the encoding of the bundle from the passed reader.
+func (f *ForceCloseBundle) decode(r io.Reader) error {
+	var version uint8
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version != forceCloseBundleVersion {
+		return fmt.Errorf("unknown force close bundle version %v",
+			version)
+	}
+
+	f.CloseTx = &wire.MsgTx{}
+	if err := f.CloseTx.Deserialize(r); err != nil {
+		return err
+	}
+
+	var hasCommitRes bool
+	if err := binary.Read(r, binary.BigEndian, &hasCommitRes); err != nil {
+		return err
+	}
+	if hasCommitRes {
+		res := &CommitOutputResolution{}
+		if err := readOutPoint(r, &res.SelfOutPoint); err != nil {
+			return err
+		}
+		if err := readSignDesc(r, &res.SelfOutputSignDesc); err != nil {
+			return err
+		}
+		err := binary.Read(r, binary.BigEndian, &res.MaturityDelay)
+		if err != nil {
+			return err
+		}
+		f.CommitResolution = res
+	}
+
+	f.HtlcResolutions = &HtlcResolutions{}
+
+	var numIncoming uint16
+	if err := binary.Read(r, binary.BigEndian, &numIncoming); err != nil {
+		return err
+	}
+	if numIncoming > 0 {
+		f.HtlcResolutions.IncomingHTLCs = make(
+			[]IncomingHtlcResolution, numIncoming,
+		)
+	}
+	for i := range f.HtlcResolutions.IncomingHTLCs {
+		htlc := &f.HtlcResolutions.IncomingHTLCs[i]
+
+		if _, err := io.ReadFull(r, htlc.Preimage[:]); err != nil {
+			return err
+		}
+
+		var err error
+		htlc.SignedSuccessTx, err = readOptionalTx(r)
+		if err != nil {
+			return err
+		}
+		err = binary.Read(r, binary.BigEndian, &htlc.CsvDelay)
+		if err != nil {
+			return err
+		}
+		if err := readOutPoint(r, &htlc.ClaimOutpoint); err != nil {
+			return err
+		}
+		if err := readSignDesc(r, &htlc.SweepSignDesc); err != nil {
+			return err
+		}
+	}
+
+	var numOutgoing uint16
+	if err := binary.Read(r, binary.BigEndian, &numOutgoing); err != nil {
+		return err
+	}
+	if numOutgoing > 0 {
+		f.HtlcResolutions.OutgoingHTLCs = make(
+			[]OutgoingHtlcResolution, numOutgoing,
+		)
+	}
+	for i := range f.HtlcResolutions.OutgoingHTLCs {
+		htlc := &f.HtlcResolutions.OutgoingHTLCs[i]
+
+		err := binary.Read(r, binary.BigEndian, &htlc.Expiry)
+		if err != nil {
+			return err
+		}
+		htlc.SignedTimeoutTx, err = readOptionalTx(r)
+		if err != nil {
+			return err
+		}
+		err = binary.Read(r, binary.BigEndian, &htlc.CsvDelay)
+		if err != nil {
+			return err
+		}
+		if err := readOutPoint(r, &htlc.ClaimOutpoint); err != nil {
+			return err
+		}
+		if err := readSignDesc(r, &htlc.SweepSignDesc); err != nil {
+			return err
+		}
+	}
+
+	var hasAnchorRes bool
+	if err := binary.Read(r, binary.BigEndian, &hasAnchorRes); err != nil {
+		return err
+	}
+	if !hasAnchorRes {
+		return nil
+	}
+
+	res := &AnchorResolution{}
+	if err := readSignDesc(r, &res.AnchorSignDescriptor); err != nil {
+		return err
+	}
+	if err := readOutPoint(r, &res.CommitAnchor); err != nil {
+		return err
+	}
+
+	var commitFee int64
+	if err := binary.Read(r, binary.BigEndian, &commitFee); err != nil {
+		return err
+	}
+	res.CommitFee = btcutil.Amount(commitFee)
+
+	err := binary.Read(r, binary.BigEndian, &res.CommitWeight)
+	if err != nil {
+		return err
+	}
+	f.AnchorResolution = res
+
+	return nil
+}
+
+// writeOptionalTx writes a transaction that may be nil, prefixed by a flag
+// indicating whether it is present.
+func writeOptionalTx(w io.Writer, tx *wire.MsgTx) error {
+	hasTx := tx != nil
+	if err := binary.Write(w, binary.BigEndian, hasTx); err != nil {
+		return err
+	}
+	if !hasTx {
+		return nil
+	}
+
+	return tx.Serialize(w)
+}
+
+// readOptionalTx reads a transaction written by writeOptionalTx, returning nil
+// if it wasn't present.
+func readOptionalTx(r io.Reader) (*wire.MsgTx, error) {
+	var hasTx bool
+	if err := binary.Read(r, binary.BigEndian, &hasTx); err != nil {
+		return nil, err
+	}
+	if !hasTx {
+		return nil, nil
+	}
+
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(r); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,152 @@
+package lnwallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExportForceCloseBundle asserts that the exported signed commitment and
+// force close bundle decode to the commitment and resolutions returned by
+// ForceClose.
+func TestExportForceCloseBundle(t *testing.T) {
+	t.Run("non-anchor", func(t *testing.T) {
+		testExportForceCloseBundle(
+			t, channeldb.SingleFunderTweaklessBit,
+		)
+	})
+	t.Run("anchor", func(t *testing.T) {
+		testExportForceCloseBundle(
+			t, channeldb.SingleFunderTweaklessBit|
+				channeldb.AnchorOutputsBit,
+		)
+	})
+	t.Run("taproot", func(t *testing.T) {
+		testExportForceCloseBundle(
+			t, channeldb.SimpleTaprootFeatureBit|
+				channeldb.AnchorOutputsBit|
+				channeldb.ZeroHtlcTxFeeBit|
+				channeldb.SingleFunderTweaklessBit,
+		)
+	})
+}
+
+func testExportForceCloseBundle(t *testing.T,
+	chanType channeldb.ChannelType) {
+
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Lock in an HTLC in each direction, so the bundle carries both
+	// incoming and outgoing HTLC resolutions.
+	htlcAmt := lnwire.NewMSatFromSatoshis(20_000)
+	htlc, _ := createHTLC(0, htlcAmt)
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	htlc, _ = createHTLC(0, htlcAmt)
+	_, err = bobChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	rawCommit, err := aliceChannel.ExportSignedCommitment()
+	require.NoError(t, err)
+
+	rawBundle, err := aliceChannel.ExportForceCloseBundle()
+	require.NoError(t, err)
+
+	// Exporting doesn't mark the channel as being in dispute, so it can
+	// still be force closed as usual.
+	summary, err := aliceChannel.ForceClose()
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, summary.CloseTx.Serialize(&b))
+	require.Equal(t, b.Bytes(), rawCommit)
+
+	// The bundle should match the encoding of the force close summary,
+	// and re-encode to the same bytes once decoded.
+	b.Reset()
+	expectedBundle := &ForceCloseBundle{
+		CloseTx:          summary.CloseTx,
+		CommitResolution: summary.CommitResolution,
+		HtlcResolutions:  summary.HtlcResolutions,
+		AnchorResolution: summary.AnchorResolution,
+	}
+	require.NoError(t, expectedBundle.encode(&b))
+	require.Equal(t, b.Bytes(), rawBundle)
+
+	bundle, err := DecodeForceCloseBundle(rawBundle)
+	require.NoError(t, err)
+
+	b.Reset()
+	require.NoError(t, bundle.encode(&b))
+	require.Equal(t, rawBundle, b.Bytes())
+
+	require.Equal(t, summary.CloseTx.TxHash(), bundle.CloseTx.TxHash())
+
+	// Like when the resolutions are stored by the arbitrator, the prev
+	// output fetchers of the sign descriptors aren't encoded, so we clear
+	// them before comparing.
+	if summary.CommitResolution != nil {
+		res := summary.CommitResolution
+		res.SelfOutputSignDesc.PrevOutputFetcher = nil
+	}
+	if summary.AnchorResolution != nil {
+		res := summary.AnchorResolution
+		res.AnchorSignDescriptor.PrevOutputFetcher = nil
+	}
+	require.Equal(t, summary.CommitResolution, bundle.CommitResolution)
+	require.Equal(t, summary.AnchorResolution, bundle.AnchorResolution)
+
+	htlcResolutions := bundle.HtlcResolutions
+	require.Len(t, htlcResolutions.IncomingHTLCs, 1)
+	require.Len(t, htlcResolutions.OutgoingHTLCs, 1)
+
+	incoming := summary.HtlcResolutions.IncomingHTLCs[0]
+	incoming.SweepSignDesc.PrevOutputFetcher = nil
+	require.Equal(
+		t, incoming.SweepSignDesc,
+		htlcResolutions.IncomingHTLCs[0].SweepSignDesc,
+	)
+	require.Equal(
+		t, incoming.ClaimOutpoint,
+		htlcResolutions.IncomingHTLCs[0].ClaimOutpoint,
+	)
+
+	outgoing := summary.HtlcResolutions.OutgoingHTLCs[0]
+	outgoing.SweepSignDesc.PrevOutputFetcher = nil
+	require.Equal(
+		t, outgoing.SweepSignDesc,
+		htlcResolutions.OutgoingHTLCs[0].SweepSignDesc,
+	)
+	require.Equal(
+		t, outgoing.Expiry, htlcResolutions.OutgoingHTLCs[0].Expiry,
+	)
+
+	// A truncated bundle fails to decode.
+	_, err = DecodeForceCloseBundle(rawBundle[:len(rawBundle)-1])
+	require.Error(t, err)
+
+	// Once we've detected local data loss, we won't export our
+	// commitment anymore.
+	err = aliceChannel.channelState.ApplyChanStatus(
+		channeldb.ChanStatusLocalDataLoss,
+	)
+	require.NoError(t, err)
+
+	_, err = aliceChannel.ExportSignedCommitment()
+	require.ErrorIs(t, err, ErrForceCloseLocalDataLoss)
+
+	_, err = aliceChannel.ExportForceCloseBundle()
+	require.ErrorIs(t, err, ErrForceCloseLocalDataLoss)
+}
@@ -18,6 +18,7 @@ import (
 	base "github.com/btcsuite/btcwallet/wallet"
 	"github.com/btcsuite/btcwallet/wallet/txauthor"
 	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
 )
@@ -577,6 +578,42 @@ type MessageSigner interface {
 		doubleHash bool) (*ecdsa.Signature, error)
 }
 
+// SignContext describes the commitment a signature is requested for. It's
+// passed to a PolicySigner alongside the sign descriptor, such that the signer
+// can enforce a policy based on the state of the channel.
+type SignContext struct {
+	// ChanPoint is the funding outpoint of the channel the commitment
+	// belongs to.
+	ChanPoint wire.OutPoint
+
+	// CommitHeight is the height of the commitment that's being signed,
+	// or that the signed HTLC transaction spends from.
+	CommitHeight uint64
+
+	// LocalCommit is true if the commitment is our own, e.g. when signing
+	// it to force close the channel, and false if it's a commitment of
+	// the remote party.
+	LocalCommit bool
+}
+
+// PolicySigner is an optional extension of the input.Signer interface for
+// signers that enforce a policy on the commitments they sign, such as remote
+// signers or HSMs. A typical policy is to never sign a remote commitment
+// below the current height, as those have already been revoked. If the signer
+// of a channel implements this interface, all signatures for commitment and
+// HTLC transactions are requested through SignOutputRawWithContext.
+type PolicySigner interface {
+	input.Signer
+
+	// SignOutputRawWithContext generates a signature for the passed
+	// transaction like SignOutputRaw, given the context of the
+	// commitment the signature is for. An error is returned if signing
+	// would violate the policy of the signer.
+	SignOutputRawWithContext(tx *wire.MsgTx,
+		signDesc *input.SignDescriptor,
+		signCtx *SignContext) (input.Signature, error)
+}
+
 // WalletDriver represents a "driver" for a particular concrete
 // WalletController implementation. A driver is identified by a globally unique
 // string identifier along with a 'New()' method which is responsible for
@@ -0,0 +1,348 @@
+package lnwallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/watchtower/blob"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// justiceKitVersion is the version of the plaintext encoding of a
+// BreachRetribution produced by EncodeJusticeKit.
+const justiceKitVersion uint8 = 0
+
+// EncodeJusticeKit serializes the sweep descriptors of the breach retribution,
+// namely the sign descriptors of both commitment outputs and all HTLC
+// retributions, and encrypts them under a key derived from the breach txid.
+// The resulting blob can be handed to a watchtower ahead of time, which is
+// expected to store it under blob.NewBreachHintFromHash of the breach txid.
+// Only once the breach transaction is seen on-chain will the tower be able to
+// derive the key and decrypt the blob using DecodeJusticeKit.
+//
+// NOTE: The KeyRing of the retribution is not included in the blob.
+func (br *BreachRetribution) EncodeJusticeKit() ([]byte, error) {
+	var b bytes.Buffer
+	if err := br.encodeJusticeKit(&b); err != nil {
+		return nil, err
+	}
+
+	key := blob.NewBreachKeyFromHash(&br.BreachTxHash)
+	cipher, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// The ciphertext is prefixed with a random nonce, followed by the
+	// encrypted plaintext and its MAC.
+	plaintext := b.Bytes()
+	ciphertext := make(
+		[]byte, blob.NonceSize+len(plaintext)+cipher.Overhead(),
+	)
+	nonce := ciphertext[:blob.NonceSize]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	cipher.Seal(ciphertext[blob.NonceSize:blob.NonceSize], nonce, plaintext,
+		nil)
+
+	return ciphertext, nil
+}
+
+// DecodeJusticeKit decrypts a blob created by EncodeJusticeKit using the key
+// derived from the given breach txid, and returns the breach retribution it
+// contains.
+func DecodeJusticeKit(breachTxHash chainhash.Hash,
+	ciphertext []byte) (*BreachRetribution, error) {
+
+	if len(ciphertext) < blob.NonceSize+blob.CiphertextExpansion {
+		return nil, blob.ErrCiphertextTooSmall
+	}
+
+	key := blob.NewBreachKeyFromHash(&breachTxHash)
+	cipher, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := ciphertext[:blob.NonceSize]
+	plaintext, err := cipher.Open(
+		nil, nonce, ciphertext[blob.NonceSize:], nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	br := &BreachRetribution{
+		BreachTxHash: breachTxHash,
+	}
+	if err := br.decodeJusticeKit(bytes.NewReader(plaintext)); err != nil {
+		return nil, err
+	}
+
+	return br, nil
+}
+
+// encodeJusticeKit writes the plaintext encoding of the breach retribution to
+// the passed writer.
+func (br *BreachRetribution) encodeJusticeKit(w io.Writer) error {
+	err := binary.Write(w, binary.BigEndian, justiceKitVersion)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(br.ChainHash[:]); err != nil {
+		return err
+	}
+
+	err = binary.Write(w, binary.BigEndian, br.BreachHeight)
+	if err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, br.RevokedStateNum)
+	if err != nil {
+		return err
+	}
+
+	err = writeOptionalSignDesc(w, br.LocalOutputSignDesc)
+	if err != nil {
+		return err
+	}
+	if err := writeOutPoint(w, &br.LocalOutpoint); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, br.LocalDelay); err != nil {
+		return err
+	}
+
+	err = writeOptionalSignDesc(w, br.RemoteOutputSignDesc)
+	if err != nil {
+		return err
+	}
+	if err := writeOutPoint(w, &br.RemoteOutpoint); err != nil {
+		return err
+	}
+	err = binary.Write(w, binary.BigEndian, br.RemoteDelay)
+	if err != nil {
+		return err
+	}
+
+	numHtlcs := uint16(len(br.HtlcRetributions))
+	if err := binary.Write(w, binary.BigEndian, numHtlcs); err != nil {
+		return err
+	}
+	for i := range br.HtlcRetributions {
+		htlc := &br.HtlcRetributions[i]
+
+		if err := writeSignDesc(w, &htlc.SignDesc); err != nil {
+			return err
+		}
+		if err := writeOutPoint(w, &htlc.OutPoint); err != nil {
+			return err
+		}
+		err := wire.WriteVarBytes(w, 0, htlc.SecondLevelWitnessScript)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(htlc.SecondLevelTapTweak[:]); err != nil {
+			return err
+		}
+		err = binary.Write(w, binary.BigEndian, htlc.IsIncoming)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeJusticeKit reads the plaintext encoding of the breach retribution from
+// the passed reader.
+func (br *BreachRetribution) decodeJusticeKit(r io.Reader) error {
+	var version uint8
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return err
+	}
+	if version != justiceKitVersion {
+		return fmt.Errorf("unknown justice kit version %v", version)
+	}
+
+	if _, err := io.ReadFull(r, br.ChainHash[:]); err != nil {
+		return err
+	}
+
+	err := binary.Read(r, binary.BigEndian, &br.BreachHeight)
+	if err != nil {
+		return err
+	}
+	err = binary.Read(r, binary.BigEndian, &br.RevokedStateNum)
+	if err != nil {
+		return err
+	}
+
+	br.LocalOutputSignDesc, err = readOptionalSignDesc(r)
+	if err != nil {
+		return err
+	}
+	if err := readOutPoint(r, &br.LocalOutpoint); err != nil {
+		return err
+	}
+	err = binary.Read(r, binary.BigEndian, &br.LocalDelay)
+	if err != nil {
+		return err
+	}
+
+	br.RemoteOutputSignDesc, err = readOptionalSignDesc(r)
+	if err != nil {
+		return err
+	}
+	if err := readOutPoint(r, &br.RemoteOutpoint); err != nil {
+		return err
+	}
+	err = binary.Read(r, binary.BigEndian, &br.RemoteDelay)
+	if err != nil {
+		return err
+	}
+
+	var numHtlcs uint16
+	if err := binary.Read(r, binary.BigEndian, &numHtlcs); err != nil {
+		return err
+	}
+	if numHtlcs == 0 {
+		return nil
+	}
+
+	br.HtlcRetributions = make([]HtlcRetribution, numHtlcs)
+	for i := range br.HtlcRetributions {
+		htlc := &br.HtlcRetributions[i]
+
+		if err := readSignDesc(r, &htlc.SignDesc); err != nil {
+			return err
+		}
+		if err := readOutPoint(r, &htlc.OutPoint); err != nil {
+			return err
+		}
+		htlc.SecondLevelWitnessScript, err = wire.ReadVarBytes(
+			r, 0, 500, "secondLevelWitnessScript",
+		)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(r, htlc.SecondLevelTapTweak[:])
+		if err != nil {
+			return err
+		}
+		err = binary.Read(r, binary.BigEndian, &htlc.IsIncoming)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeOptionalSignDesc writes a sign descriptor that may be nil, prefixed by
+// a flag indicating whether it is present.
+func writeOptionalSignDesc(w io.Writer, signDesc *input.SignDescriptor) error {
+	hasSignDesc := signDesc != nil
+	if err := binary.Write(w, binary.BigEndian, hasSignDesc); err != nil {
+		return err
+	}
+	if !hasSignDesc {
+		return nil
+	}
+
+	return writeSignDesc(w, signDesc)
+}
+
+// readOptionalSignDesc reads a sign descriptor written by
+// writeOptionalSignDesc, returning nil if it wasn't present.
+func readOptionalSignDesc(r io.Reader) (*input.SignDescriptor, error) {
+	var hasSignDesc bool
+	if err := binary.Read(r, binary.BigEndian, &hasSignDesc); err != nil {
+		return nil, err
+	}
+	if !hasSignDesc {
+		return nil, nil
+	}
+
+	signDesc := &input.SignDescriptor{}
+	if err := readSignDesc(r, signDesc); err != nil {
+		return nil, err
+	}
+
+	return signDesc, nil
+}
+
+// writeSignDesc writes the sign descriptor along with the taproot specific
+// fields not covered by input.WriteSignDescriptor.
+func writeSignDesc(w io.Writer, signDesc *input.SignDescriptor) error {
+	if err := input.WriteSignDescriptor(w, signDesc); err != nil {
+		return err
+	}
+
+	signMethod := uint8(signDesc.SignMethod)
+	if err := binary.Write(w, binary.BigEndian, signMethod); err != nil {
+		return err
+	}
+	if err := wire.WriteVarBytes(w, 0, signDesc.TapTweak); err != nil {
+		return err
+	}
+
+	return wire.WriteVarBytes(w, 0, signDesc.ControlBlock)
+}
+
+// readSignDesc reads a sign descriptor written by writeSignDesc.
+func readSignDesc(r io.Reader, signDesc *input.SignDescriptor) error {
+	if err := input.ReadSignDescriptor(r, signDesc); err != nil {
+		return err
+	}
+
+	var signMethod uint8
+	if err := binary.Read(r, binary.BigEndian, &signMethod); err != nil {
+		return err
+	}
+	signDesc.SignMethod = input.SignMethod(signMethod)
+
+	tapTweak, err := wire.ReadVarBytes(r, 0, 32, "tapTweak")
+	if err != nil {
+		return err
+	}
+	if len(tapTweak) != 0 {
+		signDesc.TapTweak = tapTweak
+	}
+
+	controlBlock, err := wire.ReadVarBytes(r, 0, 1000, "controlBlock")
+	if err != nil {
+		return err
+	}
+	if len(controlBlock) != 0 {
+		signDesc.ControlBlock = controlBlock
+	}
+
+	return nil
+}
+
+// writeOutPoint writes the hash and index of the outpoint.
+func writeOutPoint(w io.Writer, op *wire.OutPoint) error {
+	if _, err := w.Write(op.Hash[:]); err != nil {
+		return err
+	}
+
+	return binary.Write(w, binary.BigEndian, op.Index)
+}
+
+// readOutPoint reads an outpoint written by writeOutPoint.
+func readOutPoint(r io.Reader, op *wire.OutPoint) error {
+	if _, err := io.ReadFull(r, op.Hash[:]); err != nil {
+		return err
+	}
+
+	return binary.Read(r, binary.BigEndian, &op.Index)
+}
@@ -0,0 +1,89 @@
+package lnwallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/watchtower/blob"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJusticeKitRoundTrip asserts that a breach retribution encoded using
+// EncodeJusticeKit can be decrypted and decoded with the breach txid.
+func TestJusticeKitRoundTrip(t *testing.T) {
+	t.Run("non-anchor", func(t *testing.T) {
+		testJusticeKitRoundTrip(t, channeldb.SingleFunderTweaklessBit)
+	})
+	t.Run("anchor", func(t *testing.T) {
+		testJusticeKitRoundTrip(
+			t, channeldb.SingleFunderTweaklessBit|
+				channeldb.AnchorOutputsBit,
+		)
+	})
+	t.Run("taproot", func(t *testing.T) {
+		testJusticeKitRoundTrip(
+			t, channeldb.SimpleTaprootFeatureBit|
+				channeldb.AnchorOutputsBit|
+				channeldb.ZeroHtlcTxFeeBit|
+				channeldb.SingleFunderTweaklessBit,
+		)
+	})
+}
+
+func testJusticeKitRoundTrip(t *testing.T, chanType channeldb.ChannelType) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(t, chanType)
+	require.NoError(t, err)
+
+	// addHtlc has Alice offer an HTLC to Bob and locks it in.
+	addHtlc := func(id uint64) {
+		htlc, _ := createHTLC(
+			int(id), lnwire.NewMSatFromSatoshis(100_000),
+		)
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+
+		err = ForceStateTransition(aliceChannel, bobChannel)
+		require.NoError(t, err)
+	}
+
+	// Move to a state that carries an HTLC, then to a new state which
+	// revokes it.
+	addHtlc(0)
+	breachTx := aliceChannel.channelState.RemoteCommitment.CommitTx
+	stateNum := aliceChannel.channelState.RemoteCommitment.CommitHeight
+	addHtlc(1)
+
+	br, err := NewBreachRetribution(
+		aliceChannel.channelState, stateNum, 101, breachTx,
+	)
+	require.NoError(t, err)
+	require.NotNil(t, br.LocalOutputSignDesc)
+	require.NotNil(t, br.RemoteOutputSignDesc)
+	require.Len(t, br.HtlcRetributions, 1)
+
+	kit, err := br.EncodeJusticeKit()
+	require.NoError(t, err)
+
+	// The blob can only be decrypted using the breach txid.
+	var wrongHash chainhash.Hash
+	_, err = DecodeJusticeKit(wrongHash, kit)
+	require.Error(t, err)
+
+	_, err = DecodeJusticeKit(br.BreachTxHash, kit[:blob.NonceSize])
+	require.ErrorIs(t, err, blob.ErrCiphertextTooSmall)
+
+	decoded, err := DecodeJusticeKit(br.BreachTxHash, kit)
+	require.NoError(t, err)
+
+	// The key ring isn't part of the blob, so apart from it the decoded
+	// retribution should match the original one.
+	require.Nil(t, decoded.KeyRing)
+	decoded.KeyRing = br.KeyRing
+	require.Equal(t, br, decoded)
+}
@@ -0,0 +1,63 @@
+package lnwallet
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// OnionValidator is a callback invoked with every HTLC received from the
+// remote party, after it passed the validation of the channel. It decodes and
+// validates the onion of the HTLC, and returns the BADONION failure code the
+// HTLC must be failed with if the onion is malformed, such as
+// lnwire.CodeInvalidOnionVersion, or zero if the onion is valid.
+type OnionValidator func(htlc *lnwire.UpdateAddHTLC) lnwire.FailCode
+
+// SetOnionValidator registers a callback which validates the onion of every
+// HTLC received by ReceiveHTLC. Passing nil removes the validator, in which
+// case onions are stored opaquely and only processed once the HTLCs are
+// forwarded.
+//
+// An HTLC whose onion is rejected is still added to the remote update log, as
+// the remote party includes it in the next commitments regardless of our
+// decision. As an HTLC can only be failed once it's irrevocably committed,
+// ReceiveRevocation stages an update_fail_malformed_htlc for it as soon as
+// it's locked in, instead of returning it as an add to forward, see
+// StagedFails. The add is still
+// recorded in the forwarding package, and the staged fail references it, so
+// the fail is signed with the next commitment without waiting for the HTLC to
+// go through the switch.
+//
+// NOTE: The validator is called while the channel's lock is held, so it must
+// not call back into the channel. Rejected onions are only tracked in memory,
+// so after a restart the HTLCs are forwarded from the forwarding package as
+// usual, where their onions are processed again.
+func (lc *LightningChannel) SetOnionValidator(validator OnionValidator) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	lc.onionValidator = validator
+}
+
+// validateOnion returns the failure code the onion validator rejected the
+// onion of the passed HTLC with, or zero if the onion is valid or no validator
+// is registered. An error is returned if the failure code doesn't have the
+// BADONION flag set, as only those can be sent in
+// update_fail_malformed_htlc.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) validateOnion(
+	htlc *lnwire.UpdateAddHTLC) (lnwire.FailCode, error) {
+
+	if lc.onionValidator == nil {
+		return 0, nil
+	}
+
+	failCode := lc.onionValidator(htlc)
+	if failCode != 0 && failCode&lnwire.FlagBadOnion == 0 {
+		return 0, fmt.Errorf("onion validator returned failure code "+
+			"%v without the BADONION flag", failCode)
+	}
+
+	return failCode, nil
+}
@@ -0,0 +1,108 @@
+package lnwallet
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// malformedFails returns the MalformedFail entries in the passed update log.
+func malformedFails(log *updateLog) []*PaymentDescriptor {
+	var fails []*PaymentDescriptor
+	for e := log.Front(); e != nil; e = e.Next() {
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.EntryType == MalformedFail {
+			fails = append(fails, pd)
+		}
+	}
+
+	return fails
+}
+
+// TestOnionValidator asserts that an HTLC whose onion is rejected by the onion
+// validator is failed back as malformed once it's locked in, instead of being
+// returned as an add to forward.
+func TestOnionValidator(t *testing.T) {
+	t.Parallel()
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// Bob's validator only accepts onions of version zero.
+	bobChannel.SetOnionValidator(
+		func(htlc *lnwire.UpdateAddHTLC) lnwire.FailCode {
+			if htlc.OnionBlob[0] != 0 {
+				return lnwire.CodeInvalidOnionVersion
+			}
+
+			return 0
+		},
+	)
+
+	validHtlc, _ := createHTLC(0, lnwire.MilliSatoshi(10_000_000))
+	badHtlc, _ := createHTLC(1, lnwire.MilliSatoshi(20_000_000))
+	badHtlc.OnionBlob[0] = 1
+
+	for _, htlc := range []*lnwire.UpdateAddHTLC{validHtlc, badHtlc} {
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+
+	// The HTLC can't be failed before it's locked in, so nothing is staged
+	// yet.
+	require.Empty(t, malformedFails(bobChannel.localUpdateLog))
+
+	// Once locked in, both HTLCs are recorded in the forwarding package,
+	// but only the valid one is forwarded, while a MalformedFail is staged
+	// for the other one.
+	fwdPkg, adds := lockInIncoming(t, aliceChannel, bobChannel)
+	require.Len(t, fwdPkg.Adds, 2)
+	require.Len(t, adds, 1)
+	require.EqualValues(t, 0, adds[0].HtlcIndex)
+
+	fails := malformedFails(bobChannel.localUpdateLog)
+	require.Len(t, fails, 1)
+	require.EqualValues(t, 1, fails[0].ParentIndex)
+	require.Equal(t, lnwire.CodeInvalidOnionVersion, fails[0].FailCode)
+	require.Equal(
+		t, sha256.Sum256(badHtlc.OnionBlob[:]), fails[0].ShaOnionBlob,
+	)
+	require.NotNil(t, fails[0].SourceRef)
+	require.True(t, bobChannel.OweCommitment())
+
+	// The matching wire message is returned to be sent to Alice.
+	require.Equal(t, []lnwire.Message{&lnwire.UpdateFailMalformedHTLC{
+		ChanID: lnwire.NewChanIDFromOutPoint(
+			&bobChannel.channelState.FundingOutpoint,
+		),
+		ID:           1,
+		ShaOnionBlob: sha256.Sum256(badHtlc.OnionBlob[:]),
+		FailureCode:  lnwire.CodeInvalidOnionVersion,
+	}}, bobChannel.StagedFails())
+
+	// The fail removes the HTLC with the next state transition.
+	err = aliceChannel.ReceiveFailHTLC(1, []byte{})
+	require.NoError(t, err)
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
+	require.Len(t, aliceChannel.channelState.LocalCommitment.Htlcs, 1)
+	require.Len(t, bobChannel.channelState.LocalCommitment.Htlcs, 1)
+
+	// A failure code without the BADONION flag is refused, as it can't be
+	// sent in update_fail_malformed_htlc.
+	bobChannel.SetOnionValidator(
+		func(htlc *lnwire.UpdateAddHTLC) lnwire.FailCode {
+			return lnwire.CodeTemporaryChannelFailure
+		},
+	)
+	htlc, _ := createHTLC(2, lnwire.MilliSatoshi(10_000_000))
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.Error(t, err)
+	require.EqualValues(t, 2, bobChannel.remoteUpdateLog.htlcCounter)
+}
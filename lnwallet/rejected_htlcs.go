@@ -0,0 +1,71 @@
+package lnwallet
+
+import (
+	"crypto/sha256"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// rejectHtlc adds the passed incoming HTLC to the remote update log, but
+// records that it must be failed back with the given failure code once it's
+// locked in. As BOLT 2 forbids failing an HTLC before it's irrevocably
+// committed, the HTLC can't be refused right away.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) rejectHtlc(pd *PaymentDescriptor,
+	failCode lnwire.FailCode) uint64 {
+
+	lc.log.Debugf("Rejecting incoming HTLC %v: %v", pd.HtlcIndex,
+		failCode)
+
+	lc.rejectedHtlcs[pd.HtlcIndex] = failCode
+	lc.remoteUpdateLog.appendHtlc(pd)
+	lc.metrics.HtlcsAdded++
+
+	return pd.HtlcIndex
+}
+
+// failRejectedHtlc stages the update_fail_malformed_htlc of a rejected HTLC
+// that was just locked in, and queues the matching wire message to be returned
+// by StagedFails.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) failRejectedHtlc(pd *PaymentDescriptor,
+	failCode lnwire.FailCode) error {
+
+	shaOnionBlob := sha256.Sum256(pd.OnionBlob)
+	err := lc.malformedFailHTLC(
+		pd.HtlcIndex, failCode, shaOnionBlob, pd.SourceRef,
+	)
+	if err != nil {
+		return err
+	}
+
+	chanID := lnwire.NewChanIDFromOutPoint(&lc.channelState.FundingOutpoint)
+	lc.stagedFails = append(lc.stagedFails, &lnwire.UpdateFailMalformedHTLC{
+		ChanID:       chanID,
+		ID:           pd.HtlcIndex,
+		ShaOnionBlob: shaOnionBlob,
+		FailureCode:  failCode,
+	})
+
+	return nil
+}
+
+// StagedFails returns the update_fail_malformed_htlc messages of the rejected
+// HTLCs that ReceiveRevocation failed back since the last call. As the fails
+// are already in the local update log, the caller must send these messages to
+// the remote party before signing the next commitment, which covers them.
+//
+// NOTE: The staged fails are only kept in memory. If the channel is reloaded
+// before they're signed, the HTLCs are processed again from the forwarding
+// package, where their onions are rejected once more.
+func (lc *LightningChannel) StagedFails() []lnwire.Message {
+	lc.Lock()
+	defer lc.Unlock()
+
+	fails := lc.stagedFails
+	lc.stagedFails = nil
+
+	return fails
+}
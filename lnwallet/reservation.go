@@ -2,6 +2,7 @@ package lnwallet
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 
@@ -505,7 +506,10 @@ func (r *ChannelReservation) CommitConstraints(c *channeldb.ChannelConstraints,
 	defer r.Unlock()
 
 	// First, verify the sanity of the channel constraints.
-	err := VerifyConstraints(c, maxLocalCSVDelay, r.partialState.Capacity)
+	err := VerifyConstraints(
+		c, maxLocalCSVDelay, r.partialState.Capacity,
+		r.wallet.Cfg.ReserveBelowDustPolicy,
+	)
 	if err != nil {
 		return err
 	}
@@ -563,6 +567,26 @@ func (r *ChannelReservation) OurContribution() *ChannelContribution {
 	return r.ourContribution
 }
 
+// OurContributionInputs returns the outpoints of the inputs the wallet
+// contributed to the funding transaction of the pending channel so far. As the
+// inputs are modified while the funding transaction is negotiated, a copy is
+// returned, taken while holding the reservation's lock.
+func (r *ChannelReservation) OurContributionInputs() []wire.OutPoint {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.ourContribution == nil {
+		return nil
+	}
+
+	inputs := make([]wire.OutPoint, 0, len(r.ourContribution.Inputs))
+	for _, txIn := range r.ourContribution.Inputs {
+		inputs = append(inputs, txIn.PreviousOutPoint)
+	}
+
+	return inputs
+}
+
 // ProcessContribution verifies the counterparty's contribution to the pending
 // payment channel. As a result of this incoming message, lnwallet is able to
 // build the funding transaction, and both commitment transactions. Once this
@@ -800,20 +824,69 @@ func (r *ChannelReservation) Cancel() error {
 	return <-errChan
 }
 
+// ReserveBelowDustPolicy determines how VerifyConstraints handles a channel
+// reserve that is below the dust limit. Such a reserve can't be enforced, as
+// an output of that value would be trimmed from the commitment transaction.
+type ReserveBelowDustPolicy uint8
+
+const (
+	// ReserveBelowDustReject rejects the constraints. This follows BOLT
+	// #2, which requires the receiver of open_channel and accept_channel
+	// to fail the channel if the channel_reserve_satoshis is below the
+	// dust_limit_satoshis.
+	ReserveBelowDustReject ReserveBelowDustPolicy = iota
+
+	// ReserveBelowDustClamp raises the reserve to the dust limit. As the
+	// reserve is the amount a party must keep on its side, a higher value
+	// only restricts us further, so this can be used to accept peers that
+	// don't follow BOLT #2 without weakening our own guarantees.
+	ReserveBelowDustClamp
+)
+
+// String returns a human-readable name of the policy.
+func (p ReserveBelowDustPolicy) String() string {
+	switch p {
+	case ReserveBelowDustReject:
+		return "reject"
+
+	case ReserveBelowDustClamp:
+		return "clamp"
+
+	default:
+		return fmt.Sprintf("unknown policy %d", uint8(p))
+	}
+}
+
 // VerifyConstraints is a helper function that can be used to check the sanity
-// of various channel constraints.
+// of various channel constraints. If the channel reserve is below the dust
+// limit, it's handled according to the passed policy, which may raise the
+// reserve of the passed constraints.
 func VerifyConstraints(c *channeldb.ChannelConstraints,
-	maxLocalCSVDelay uint16, channelCapacity btcutil.Amount) error {
+	maxLocalCSVDelay uint16, channelCapacity btcutil.Amount,
+	reservePolicy ReserveBelowDustPolicy) error {
 
-	// Fail if the csv delay for our funds exceeds our maximum.
+	// Fail if the csv delay for our funds is zero or exceeds our maximum.
+	if c.CsvDelay == 0 {
+		return ErrCsvDelayZero()
+	}
 	if c.CsvDelay > maxLocalCSVDelay {
 		return ErrCsvDelayTooLarge(c.CsvDelay, maxLocalCSVDelay)
 	}
 
 	// The channel reserve should always be greater or equal to the dust
-	// limit. The reservation request should be denied if otherwise.
+	// limit. The reservation request should be denied if otherwise,
+	// unless we're configured to clamp the reserve.
 	if c.DustLimit > c.ChanReserve {
-		return ErrChanReserveTooSmall(c.ChanReserve, c.DustLimit)
+		if reservePolicy != ReserveBelowDustClamp {
+			return ErrChanReserveTooSmall(
+				c.ChanReserve, c.DustLimit,
+			)
+		}
+
+		walletLog.Debugf("Clamping channel reserve of %v to dust "+
+			"limit %v", c.ChanReserve, c.DustLimit)
+
+		c.ChanReserve = c.DustLimit
 	}
 
 	// Validate against the maximum-sized witness script dust limit, and
@@ -0,0 +1,98 @@
+package lnwallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerifyConstraintsReserveBelowDust asserts that a channel reserve below
+// the dust limit is rejected or clamped to the dust limit depending on the
+// policy.
+func TestVerifyConstraintsReserveBelowDust(t *testing.T) {
+	t.Parallel()
+
+	const (
+		maxLocalCSVDelay = 1000
+		capacity         = btcutil.SatoshiPerBitcoin
+	)
+	dustLimit := DustLimitForSize(input.UnknownWitnessSize)
+
+	newConstraints := func(
+		chanReserve btcutil.Amount) *channeldb.ChannelConstraints {
+
+		return &channeldb.ChannelConstraints{
+			DustLimit:        dustLimit,
+			ChanReserve:      chanReserve,
+			MaxPendingAmount: lnwire.NewMSatFromSatoshis(capacity),
+			MinHTLC:          1000,
+			MaxAcceptedHtlcs: input.MaxHTLCNumber / 2,
+			CsvDelay:         144,
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		chanReserve     btcutil.Amount
+		policy          ReserveBelowDustPolicy
+		expectedReserve btcutil.Amount
+		expectErr       bool
+	}{{
+		name:            "reserve above dust",
+		chanReserve:     dustLimit + 1,
+		policy:          ReserveBelowDustReject,
+		expectedReserve: dustLimit + 1,
+	}, {
+		name:            "reserve at dust limit",
+		chanReserve:     dustLimit,
+		policy:          ReserveBelowDustReject,
+		expectedReserve: dustLimit,
+	}, {
+		name:        "reserve below dust rejected",
+		chanReserve: dustLimit - 1,
+		policy:      ReserveBelowDustReject,
+		expectErr:   true,
+	}, {
+		name:            "reserve below dust clamped",
+		chanReserve:     dustLimit - 1,
+		policy:          ReserveBelowDustClamp,
+		expectedReserve: dustLimit,
+	}, {
+		name:            "zero reserve clamped",
+		chanReserve:     0,
+		policy:          ReserveBelowDustClamp,
+		expectedReserve: dustLimit,
+	}, {
+		name:            "reserve above dust untouched by clamp",
+		chanReserve:     dustLimit + 1,
+		policy:          ReserveBelowDustClamp,
+		expectedReserve: dustLimit + 1,
+	}}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newConstraints(tc.chanReserve)
+			err := VerifyConstraints(
+				c, maxLocalCSVDelay, capacity, tc.policy,
+			)
+			if tc.expectErr {
+				require.ErrorContains(
+					t, err, "channel reserve of",
+				)
+				require.Equal(t, tc.chanReserve, c.ChanReserve)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedReserve, c.ChanReserve)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package lnwallet
+
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// ReserveWarning describes a commitment of the remote party on which either
+// party's balance is below its channel reserve once settled HTLCs are locked
+// in.
+type ReserveWarning struct {
+	// Height is the height of the remote commitment.
+	Height uint64
+
+	// LocalBalance is our balance on the commitment.
+	LocalBalance lnwire.MilliSatoshi
+
+	// LocalReserve is the reserve we're required to keep.
+	LocalReserve btcutil.Amount
+
+	// RemoteBalance is the balance of the remote party on the commitment.
+	RemoteBalance lnwire.MilliSatoshi
+
+	// RemoteReserve is the reserve the remote party is required to keep.
+	RemoteReserve btcutil.Amount
+}
+
+// LocalBelowReserve returns true if our balance is below our reserve.
+func (w ReserveWarning) LocalBelowReserve() bool {
+	return w.LocalBalance < lnwire.NewMSatFromSatoshis(w.LocalReserve)
+}
+
+// RemoteBelowReserve returns true if the balance of the remote party is below
+// their reserve.
+func (w ReserveWarning) RemoteBelowReserve() bool {
+	return w.RemoteBalance < lnwire.NewMSatFromSatoshis(w.RemoteReserve)
+}
+
+// ReserveObserver is a callback invoked when either party is below its
+// channel reserve after settled HTLCs are locked in.
+type ReserveObserver func(ReserveWarning)
+
+// SetReserveObserver registers a callback which is invoked whenever
+// ReceiveRevocation locks in settled HTLCs, and either party's balance on the
+// new remote commitment is below its channel reserve. As settles never
+// decrease a balance, this means the party was already below its reserve,
+// which may point at a misbehaving peer. The warning doesn't affect the state
+// transition. Passing nil removes the observer, in which case the condition
+// is only logged.
+//
+// NOTE: The observer is called while the channel's lock is held, so it must
+// not call back into the channel.
+func (lc *LightningChannel) SetReserveObserver(observer ReserveObserver) {
+	lc.Lock()
+	defer lc.Unlock()
+
+	lc.reserveObserver = observer
+}
+
+// checkReserves reports a ReserveWarning to the reserve observer, if either
+// party is below its reserve on the tail of the remote commitment chain.
+//
+// NOTE: This method requires the channel's lock to be held.
+func (lc *LightningChannel) checkReserves() {
+	remoteCommit := lc.remoteCommitChain.tail()
+
+	warning := ReserveWarning{
+		Height:        remoteCommit.height,
+		LocalBalance:  remoteCommit.ourBalance,
+		LocalReserve:  lc.channelState.LocalChanCfg.ChanReserve,
+		RemoteBalance: remoteCommit.theirBalance,
+		RemoteReserve: lc.channelState.RemoteChanCfg.ChanReserve,
+	}
+	if !warning.LocalBelowReserve() && !warning.RemoteBelowReserve() {
+		return
+	}
+
+	lc.log.Warnf("Balance below reserve after settle at remote height "+
+		"%v: local=%v (reserve %v), remote=%v (reserve %v)",
+		warning.Height, warning.LocalBalance, warning.LocalReserve,
+		warning.RemoteBalance, warning.RemoteReserve)
+
+	if lc.reserveObserver != nil {
+		lc.reserveObserver(warning)
+	}
+}
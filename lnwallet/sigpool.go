@@ -1,8 +1,10 @@
 package lnwallet
 
 import (
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/wire"
@@ -17,6 +19,22 @@ const (
 	jobBuffer = 100
 
 	// TODO(roasbeef): job buffer pool?
+
+	// DefaultSigPoolDrainTimeout is the default time Stop waits for the
+	// jobs that were already submitted to the pool to be processed before
+	// shutting down the workers.
+	DefaultSigPoolDrainTimeout = 10 * time.Second
+)
+
+var (
+	// ErrSigPoolDrainTimeout is returned by Stop if the jobs submitted to
+	// the pool weren't all processed within the drain timeout.
+	ErrSigPoolDrainTimeout = errors.New("timeout while waiting for sig " +
+		"pool jobs to drain")
+
+	// ErrSigPoolShuttingDown is the result of jobs submitted to the pool
+	// after it started shutting down.
+	ErrSigPoolShuttingDown = errors.New("sig pool shutting down")
 )
 
 // VerifyJob is a job sent to the sigPool sig pool to verify a signature
@@ -86,6 +104,11 @@ type SignJob struct {
 	// transaction being signed.
 	OutputIndex int32
 
+	// SignCtx is the optional context of the commitment the HTLC belongs
+	// to. If set and the signer of the pool is a PolicySigner, the
+	// signature is requested along with this context.
+	SignCtx *SignContext
+
 	// Cancel is a channel that should be closed if the caller wishes to
 	// abandon all pending sign jobs part of a single batch.
 	Cancel chan struct{}
@@ -134,19 +157,56 @@ type SigPool struct {
 	quit chan struct{}
 
 	numWorkers int
+
+	// drainTimeout is the time Stop waits for pending jobs to be processed
+	// before shutting down the workers.
+	drainTimeout time.Duration
+
+	// pendingJobs is the number of submitted jobs that haven't been
+	// processed by a worker yet. Once it drops to zero, the drained
+	// channel is closed if set.
+	pendingJobs int
+	drained     chan struct{}
+
+	// draining is set once Stop is called, after which no new jobs are
+	// accepted.
+	draining bool
+
+	pendingMtx sync.Mutex
+}
+
+// SigPoolOpt is a functional option that can be used to modify the default
+// behavior of a SigPool.
+type SigPoolOpt func(*SigPool)
+
+// WithSigPoolDrainTimeout sets the time Stop waits for the jobs that were
+// already submitted to the pool to be processed. With a timeout of zero, Stop
+// shuts down the workers right away.
+func WithSigPoolDrainTimeout(timeout time.Duration) SigPoolOpt {
+	return func(s *SigPool) {
+		s.drainTimeout = timeout
+	}
 }
 
 // NewSigPool creates a new signature pool with the specified number of
 // workers. The recommended parameter for the number of works is the number of
 // physical CPU cores available on the target machine.
-func NewSigPool(numWorkers int, signer input.Signer) *SigPool {
-	return &SigPool{
-		signer:     signer,
-		numWorkers: numWorkers,
-		verifyJobs: make(chan VerifyJob, jobBuffer),
-		signJobs:   make(chan SignJob, jobBuffer),
-		quit:       make(chan struct{}),
+func NewSigPool(numWorkers int, signer input.Signer,
+	opts ...SigPoolOpt) *SigPool {
+
+	s := &SigPool{
+		signer:       signer,
+		numWorkers:   numWorkers,
+		verifyJobs:   make(chan VerifyJob, jobBuffer),
+		signJobs:     make(chan SignJob, jobBuffer),
+		quit:         make(chan struct{}),
+		drainTimeout: DefaultSigPoolDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // Start starts of all goroutines that the sigPool sig pool needs to
@@ -163,13 +223,88 @@ func (s *SigPool) Start() error {
 }
 
 // Stop signals any active workers carrying out jobs to exit so the sigPool can
-// gracefully shutdown.
+// gracefully shutdown. Before doing so, it waits for all jobs that were
+// already submitted to be processed, so callers waiting for the results of an
+// in-flight batch receive them. Jobs submitted in the meantime are refused
+// with ErrSigPoolShuttingDown. If the jobs aren't drained within the drain
+// timeout, the pool is shut down regardless and ErrSigPoolDrainTimeout is
+// returned.
 func (s *SigPool) Stop() error {
+	var err error
 	s.stopped.Do(func() {
+		s.pendingMtx.Lock()
+		s.draining = true
+		s.pendingMtx.Unlock()
+
+		select {
+		case <-s.waitDrained():
+		case <-time.After(s.drainTimeout):
+			err = ErrSigPoolDrainTimeout
+		}
+
 		close(s.quit)
 		s.wg.Wait()
 	})
-	return nil
+
+	return err
+}
+
+// waitDrained returns a channel that is closed once no submitted jobs are
+// pending anymore.
+func (s *SigPool) waitDrained() <-chan struct{} {
+	s.pendingMtx.Lock()
+	defer s.pendingMtx.Unlock()
+
+	drained := make(chan struct{})
+	if s.pendingJobs == 0 {
+		close(drained)
+		return drained
+	}
+
+	s.drained = drained
+
+	return drained
+}
+
+// jobSubmitted marks a job as pending. False is returned if the pool is
+// shutting down, in which case the job must not be submitted.
+func (s *SigPool) jobSubmitted() bool {
+	s.pendingMtx.Lock()
+	defer s.pendingMtx.Unlock()
+
+	if s.draining {
+		return false
+	}
+
+	s.pendingJobs++
+
+	return true
+}
+
+// jobDone marks a pending job as processed, notifying a waiting Stop call if
+// it was the last one.
+func (s *SigPool) jobDone() {
+	s.pendingMtx.Lock()
+	defer s.pendingMtx.Unlock()
+
+	s.pendingJobs--
+	if s.pendingJobs == 0 && s.drained != nil {
+		close(s.drained)
+		s.drained = nil
+	}
+}
+
+// sign generates the signature for the passed sign job, using the context of
+// the job if both the job carries one and our signer is a PolicySigner.
+func (s *SigPool) sign(job SignJob) (input.Signature, error) {
+	policySigner, ok := s.signer.(PolicySigner)
+	if !ok || job.SignCtx == nil {
+		return s.signer.SignOutputRaw(job.Tx, &job.SignDesc)
+	}
+
+	return policySigner.SignOutputRawWithContext(
+		job.Tx, &job.SignDesc, job.SignCtx,
+	)
 }
 
 // poolWorker is the main worker goroutine within the sigPool sig pool.
@@ -187,36 +322,9 @@ func (s *SigPool) poolWorker() {
 		// send the result along with a possible error back to the
 		// caller.
 		case sigMsg := <-s.signJobs:
-			rawSig, err := s.signer.SignOutputRaw(
-				sigMsg.Tx, &sigMsg.SignDesc,
-			)
-			if err != nil {
-				select {
-				case sigMsg.Resp <- SignJobResp{
-					Sig: lnwire.Sig{},
-					Err: err,
-				}:
-					continue
-				case <-sigMsg.Cancel:
-					continue
-				case <-s.quit:
-					return
-				}
-			}
-
-			// Use the sig mapper to go from the input.Signature
-			// into the serialized lnwire.Sig that we'll send
-			// across the wire.
-			sig, err := lnwire.NewSigFromSignature(rawSig)
-
-			select {
-			case sigMsg.Resp <- SignJobResp{
-				Sig: sig,
-				Err: err,
-			}:
-			case <-sigMsg.Cancel:
-				continue
-			case <-s.quit:
+			ok := s.processSignJob(sigMsg)
+			s.jobDone()
+			if !ok {
 				return
 			}
 
@@ -224,42 +332,10 @@ func (s *SigPool) poolWorker() {
 		// world. We'll attempt to construct the sighash, parse the
 		// signature, and finally verify the signature.
 		case verifyMsg := <-s.verifyJobs:
-			sigHash, err := verifyMsg.SigHash()
-			if err != nil {
-				select {
-				case verifyMsg.ErrResp <- &HtlcIndexErr{
-					error:     err,
-					VerifyJob: &verifyMsg,
-				}:
-					continue
-				case <-verifyMsg.Cancel:
-					continue
-				}
-			}
-
-			rawSig := verifyMsg.Sig
-
-			if !rawSig.Verify(sigHash, verifyMsg.PubKey) {
-				err := fmt.Errorf("invalid signature "+
-					"sighash: %x, sig: %x", sigHash,
-					rawSig.Serialize())
-
-				select {
-				case verifyMsg.ErrResp <- &HtlcIndexErr{
-					error:     err,
-					VerifyJob: &verifyMsg,
-				}:
-				case <-verifyMsg.Cancel:
-				case <-s.quit:
-					return
-				}
-			} else {
-				select {
-				case verifyMsg.ErrResp <- nil:
-				case <-verifyMsg.Cancel:
-				case <-s.quit:
-					return
-				}
+			ok := s.processVerifyJob(verifyMsg)
+			s.jobDone()
+			if !ok {
+				return
 			}
 
 		// The sigPool sig pool is exiting, so we will as well.
@@ -269,17 +345,109 @@ func (s *SigPool) poolWorker() {
 	}
 }
 
+// processSignJob generates the signature of the sign job and sends it to the
+// caller. False is returned if the pool is exiting.
+func (s *SigPool) processSignJob(sigMsg SignJob) bool {
+	rawSig, err := s.sign(sigMsg)
+	if err != nil {
+		select {
+		case sigMsg.Resp <- SignJobResp{
+			Sig: lnwire.Sig{},
+			Err: err,
+		}:
+			return true
+		case <-sigMsg.Cancel:
+			return true
+		case <-s.quit:
+			return false
+		}
+	}
+
+	// Use the sig mapper to go from the input.Signature into the
+	// serialized lnwire.Sig that we'll send across the wire.
+	sig, err := lnwire.NewSigFromSignature(rawSig)
+
+	select {
+	case sigMsg.Resp <- SignJobResp{
+		Sig: sig,
+		Err: err,
+	}:
+	case <-sigMsg.Cancel:
+	case <-s.quit:
+		return false
+	}
+
+	return true
+}
+
+// processVerifyJob verifies the signature of the verify job and sends the
+// result to the caller. False is returned if the pool is exiting.
+func (s *SigPool) processVerifyJob(verifyMsg VerifyJob) bool {
+	sigHash, err := verifyMsg.SigHash()
+	if err != nil {
+		select {
+		case verifyMsg.ErrResp <- &HtlcIndexErr{
+			error:     err,
+			VerifyJob: &verifyMsg,
+		}:
+		case <-verifyMsg.Cancel:
+		}
+
+		return true
+	}
+
+	rawSig := verifyMsg.Sig
+
+	if !rawSig.Verify(sigHash, verifyMsg.PubKey) {
+		err := fmt.Errorf("invalid signature "+
+			"sighash: %x, sig: %x", sigHash,
+			rawSig.Serialize())
+
+		select {
+		case verifyMsg.ErrResp <- &HtlcIndexErr{
+			error:     err,
+			VerifyJob: &verifyMsg,
+		}:
+		case <-verifyMsg.Cancel:
+		case <-s.quit:
+			return false
+		}
+	} else {
+		select {
+		case verifyMsg.ErrResp <- nil:
+		case <-verifyMsg.Cancel:
+		case <-s.quit:
+			return false
+		}
+	}
+
+	return true
+}
+
 // SubmitSignBatch submits a batch of signature jobs to the sigPool.  The
 // response and cancel channels for each of the SignJob's are expected to be
 // fully populated, as the response for each job will be sent over the
 // response channel within the job itself.
 func (s *SigPool) SubmitSignBatch(signJobs []SignJob) {
 	for _, job := range signJobs {
+		if !s.jobSubmitted() {
+			select {
+			case job.Resp <- SignJobResp{
+				Err: ErrSigPoolShuttingDown,
+			}:
+			case <-job.Cancel:
+			}
+
+			continue
+		}
+
 		select {
 		case s.signJobs <- job:
 		case <-job.Cancel:
 			// TODO(roasbeef): return error?
+			s.jobDone()
 		case <-s.quit:
+			s.jobDone()
 			return
 		}
 	}
@@ -299,9 +467,20 @@ func (s *SigPool) SubmitVerifyBatch(verifyJobs []VerifyJob,
 		job.Cancel = cancelChan
 		job.ErrResp = errChan
 
+		if !s.jobSubmitted() {
+			job := job
+			errChan <- &HtlcIndexErr{
+				error:     ErrSigPoolShuttingDown,
+				VerifyJob: &job,
+			}
+
+			continue
+		}
+
 		select {
 		case s.verifyJobs <- job:
 		case <-job.Cancel:
+			s.jobDone()
 			return errChan
 		}
 	}
@@ -0,0 +1,250 @@
+package lnwallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedSigner is a signer that blocks each signature until the gate channel
+// is closed.
+type gatedSigner struct {
+	*input.MockSigner
+
+	privKey *btcec.PrivateKey
+	gate    chan struct{}
+}
+
+// SignOutputRaw waits for the gate to open and then returns a signature over
+// an all-zero hash.
+func (g *gatedSigner) SignOutputRaw(_ *wire.MsgTx,
+	_ *input.SignDescriptor) (input.Signature, error) {
+
+	<-g.gate
+
+	var hash chainhash.Hash
+	return ecdsa.Sign(g.privKey, hash[:]), nil
+}
+
+// newGatedSigner creates a gatedSigner with a closed gate.
+func newGatedSigner(t *testing.T) *gatedSigner {
+	privKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	gate := make(chan struct{})
+	close(gate)
+
+	return &gatedSigner{
+		MockSigner: input.NewMockSigner(
+			[]*btcec.PrivateKey{privKey},
+			&chaincfg.RegressionNetParams,
+		),
+		privKey: privKey,
+		gate:    gate,
+	}
+}
+
+// submitSignBatch submits the given number of sign jobs to the pool and
+// returns their response channels.
+func submitSignBatch(pool *SigPool, numJobs int) []chan SignJobResp {
+	cancelChan := make(chan struct{})
+	jobs := make([]SignJob, numJobs)
+	resps := make([]chan SignJobResp, numJobs)
+	for i := range jobs {
+		resps[i] = make(chan SignJobResp, 1)
+		jobs[i] = SignJob{
+			Tx:     wire.NewMsgTx(2),
+			Cancel: cancelChan,
+			Resp:   resps[i],
+		}
+	}
+
+	go pool.SubmitSignBatch(jobs)
+
+	return resps
+}
+
+// TestSigPoolStopDrainsJobs asserts that stopping the pool while a large sign
+// batch is in flight waits for the signatures of all jobs submitted so far to
+// be delivered, while the remaining jobs of the batch are refused.
+func TestSigPoolStopDrainsJobs(t *testing.T) {
+	t.Parallel()
+
+	signer := newGatedSigner(t)
+	signer.gate = make(chan struct{})
+
+	pool := NewSigPool(4, signer)
+	require.NoError(t, pool.Start())
+
+	// Submit more jobs than fit into the job buffer, and wait until the
+	// first ones have been picked up by the workers.
+	const numJobs = jobBuffer * 2
+	resps := submitSignBatch(pool, numJobs)
+	require.Eventually(t, func() bool {
+		pool.pendingMtx.Lock()
+		defer pool.pendingMtx.Unlock()
+
+		return pool.pendingJobs > jobBuffer
+	}, time.Second, 10*time.Millisecond)
+
+	stopErr := make(chan error, 1)
+	go func() {
+		stopErr <- pool.Stop()
+	}()
+
+	// Stop mustn't return while the batch is still in flight.
+	select {
+	case err := <-stopErr:
+		t.Fatalf("stop returned before jobs drained: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Once the signer makes progress, every job submitted before Stop
+	// should receive its signature, and Stop should return without an
+	// error.
+	close(signer.gate)
+	var numSigned int
+	for _, resp := range resps {
+		select {
+		case r := <-resp:
+			if r.Err == nil {
+				numSigned++
+				continue
+			}
+			require.ErrorIs(t, r.Err, ErrSigPoolShuttingDown)
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("sign job response not received")
+		}
+	}
+	require.Greater(t, numSigned, jobBuffer)
+
+	select {
+	case err := <-stopErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stop didn't return")
+	}
+}
+
+// TestSigPoolStopDrainTimeout asserts that Stop shuts the pool down with
+// ErrSigPoolDrainTimeout if pending jobs aren't processed in time.
+func TestSigPoolStopDrainTimeout(t *testing.T) {
+	t.Parallel()
+
+	signer := newGatedSigner(t)
+	signer.gate = make(chan struct{})
+
+	const drainTimeout = 50 * time.Millisecond
+	pool := NewSigPool(1, signer, WithSigPoolDrainTimeout(drainTimeout))
+	require.NoError(t, pool.Start())
+
+	_ = submitSignBatch(pool, 10)
+	require.Eventually(t, func() bool {
+		pool.pendingMtx.Lock()
+		defer pool.pendingMtx.Unlock()
+
+		return pool.pendingJobs == 10
+	}, time.Second, 10*time.Millisecond)
+
+	stopErr := make(chan error, 1)
+	go func() {
+		stopErr <- pool.Stop()
+	}()
+
+	// The worker is stuck in the signer, so the drain times out. Once the
+	// signer returns, the worker observes the quit signal and exits.
+	time.Sleep(2 * drainTimeout)
+	close(signer.gate)
+
+	select {
+	case err := <-stopErr:
+		require.ErrorIs(t, err, ErrSigPoolDrainTimeout)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stop didn't return")
+	}
+}
+
+// TestSigPoolStopIdle asserts that stopping an idle pool returns right away.
+func TestSigPoolStopIdle(t *testing.T) {
+	t.Parallel()
+
+	pool := NewSigPool(2, newGatedSigner(t))
+	require.NoError(t, pool.Start())
+
+	resps := submitSignBatch(pool, 5)
+	for _, resp := range resps {
+		require.NoError(t, (<-resp).Err)
+	}
+
+	require.NoError(t, pool.Stop())
+}
+
+// TestSigPoolStopRefusesNewJobs asserts that jobs submitted while the pool is
+// draining are refused right away, so they can't prolong the shutdown.
+func TestSigPoolStopRefusesNewJobs(t *testing.T) {
+	t.Parallel()
+
+	signer := newGatedSigner(t)
+	signer.gate = make(chan struct{})
+
+	pool := NewSigPool(1, signer)
+	require.NoError(t, pool.Start())
+
+	pendingResps := submitSignBatch(pool, 1)
+	require.Eventually(t, func() bool {
+		pool.pendingMtx.Lock()
+		defer pool.pendingMtx.Unlock()
+
+		return pool.pendingJobs == 1
+	}, time.Second, 10*time.Millisecond)
+
+	stopErr := make(chan error, 1)
+	go func() {
+		stopErr <- pool.Stop()
+	}()
+	require.Eventually(t, func() bool {
+		pool.pendingMtx.Lock()
+		defer pool.pendingMtx.Unlock()
+
+		return pool.draining
+	}, time.Second, 10*time.Millisecond)
+
+	// While the pending job blocks the shutdown, new sign and verify jobs
+	// are refused.
+	for _, resp := range submitSignBatch(pool, 3) {
+		select {
+		case r := <-resp:
+			require.ErrorIs(t, r.Err, ErrSigPoolShuttingDown)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("sign job response not received")
+		}
+	}
+
+	verifyResps := pool.SubmitVerifyBatch(
+		make([]VerifyJob, 2), make(chan struct{}),
+	)
+	for i := 0; i < 2; i++ {
+		verifyErr := <-verifyResps
+		require.NotNil(t, verifyErr)
+		require.ErrorIs(t, verifyErr.error, ErrSigPoolShuttingDown)
+	}
+
+	// The pending job is still processed.
+	close(signer.gate)
+	require.NoError(t, (<-pendingResps[0]).Err)
+
+	select {
+	case err := <-stopErr:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("stop didn't return")
+	}
+}
@@ -106,7 +106,7 @@ var (
 // allocated to each side. Within the channel, Alice is the initiator. If
 // tweaklessCommits is true, then the commits within the channels will use the
 // new format, otherwise the legacy format.
-func CreateTestChannels(t *testing.T, chanType channeldb.ChannelType,
+func CreateTestChannels(t testing.TB, chanType channeldb.ChannelType,
 	dbModifiers ...channeldb.OptionModifier) (*LightningChannel,
 	*LightningChannel, error) {
 
@@ -348,6 +348,20 @@ func CreateTestChannels(t *testing.T, chanType channeldb.ChannelType,
 
 	// TODO(roasbeef): make mock version of pre-image store
 
+	obfuscator := createStateHintObfuscator(aliceChannelState)
+	err = SetStateNumHint(
+		aliceCommitTx, 0, obfuscator,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = SetStateNumHint(
+		bobCommitTx, 0, obfuscator,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	alicePool := NewSigPool(1, aliceSigner)
 	channelAlice, err := NewLightningChannel(
 		aliceSigner, aliceChannelState, alicePool,
@@ -360,8 +374,6 @@ func CreateTestChannels(t *testing.T, chanType channeldb.ChannelType,
 		require.NoError(t, alicePool.Stop())
 	})
 
-	obfuscator := createStateHintObfuscator(aliceChannelState)
-
 	bobPool := NewSigPool(1, bobSigner)
 	channelBob, err := NewLightningChannel(
 		bobSigner, bobChannelState, bobPool,
@@ -374,19 +386,6 @@ func CreateTestChannels(t *testing.T, chanType channeldb.ChannelType,
 		require.NoError(t, bobPool.Stop())
 	})
 
-	err = SetStateNumHint(
-		aliceCommitTx, 0, obfuscator,
-	)
-	if err != nil {
-		return nil, nil, err
-	}
-	err = SetStateNumHint(
-		bobCommitTx, 0, obfuscator,
-	)
-	if err != nil {
-		return nil, nil, err
-	}
-
 	addr := &net.TCPAddr{
 		IP:   net.ParseIP("127.0.0.1"),
 		Port: 18556,
@@ -789,6 +789,141 @@ func TestCommitmentSpendValidation(t *testing.T) {
 	}
 }
 
+// TestCreateCommitTxAnchors asserts that CreateCommitTx adds an anchor output
+// for each party with a balance output on anchor channels, or for both
+// parties if there are HTLCs, and none for other channel types.
+func TestCreateCommitTxAnchors(t *testing.T) {
+	t.Parallel()
+
+	newKey := func() *btcec.PublicKey {
+		privKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+
+		return privKey.PubKey()
+	}
+
+	dustLimit := DustLimitForSize(input.UnknownWitnessSize)
+	localCfg := &channeldb.ChannelConfig{
+		ChannelConstraints: channeldb.ChannelConstraints{
+			DustLimit: dustLimit,
+			CsvDelay:  144,
+		},
+		MultiSigKey: keychain.KeyDescriptor{PubKey: newKey()},
+	}
+	remoteCfg := &channeldb.ChannelConfig{
+		ChannelConstraints: channeldb.ChannelConstraints{
+			DustLimit: dustLimit,
+			CsvDelay:  144,
+		},
+		MultiSigKey: keychain.KeyDescriptor{PubKey: newKey()},
+	}
+	keyRing := &CommitmentKeyRing{
+		ToLocalKey:    newKey(),
+		ToRemoteKey:   newKey(),
+		RevocationKey: newKey(),
+	}
+	fundingTxIn := wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 1}}
+
+	legacy := channeldb.SingleFunderTweaklessBit
+	anchors := legacy | channeldb.AnchorOutputsBit
+
+	// The anchors add two outputs to the commitment weight.
+	require.EqualValues(t, input.CommitWeight, CommitWeight(legacy))
+	require.EqualValues(t, input.AnchorCommitWeight, CommitWeight(anchors))
+
+	// Each party's anchor is spendable with its multi-sig key, or by
+	// anyone after 16 blocks.
+	localAnchor, remoteAnchor, err := CommitScriptAnchors(
+		anchors, localCfg, remoteCfg, keyRing,
+	)
+	require.NoError(t, err)
+
+	anchorScript, err := input.CommitScriptAnchor(
+		localCfg.MultiSigKey.PubKey,
+	)
+	require.NoError(t, err)
+	require.Equal(t, anchorScript, localAnchor.WitnessScriptToSign())
+
+	disasm, err := txscript.DisasmString(anchorScript)
+	require.NoError(t, err)
+	require.Contains(t, disasm, "16 OP_CHECKSEQUENCEVERIFY")
+
+	const balance = btcutil.Amount(100_000)
+
+	testCases := []struct {
+		name           string
+		chanType       channeldb.ChannelType
+		remoteBalance  btcutil.Amount
+		numHTLCs       int64
+		expectedScript [][]byte
+	}{
+		{
+			name:          "no anchors",
+			chanType:      legacy,
+			remoteBalance: balance,
+			expectedScript: [][]byte{
+				nil, nil,
+			},
+		},
+		{
+			name:          "both balances",
+			chanType:      anchors,
+			remoteBalance: balance,
+			expectedScript: [][]byte{
+				nil, nil, localAnchor.PkScript(),
+				remoteAnchor.PkScript(),
+			},
+		},
+		{
+			name:          "remote balance dust",
+			chanType:      anchors,
+			remoteBalance: dustLimit - 1,
+			expectedScript: [][]byte{
+				nil, localAnchor.PkScript(),
+			},
+		},
+		{
+			name:          "remote balance dust with htlcs",
+			chanType:      anchors,
+			remoteBalance: dustLimit - 1,
+			numHTLCs:      1,
+			expectedScript: [][]byte{
+				nil, localAnchor.PkScript(),
+				remoteAnchor.PkScript(),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			commitTx, err := CreateCommitTx(
+				tc.chanType, fundingTxIn, keyRing, localCfg,
+				remoteCfg, balance, tc.remoteBalance,
+				tc.numHTLCs, true, 0,
+			)
+			require.NoError(t, err)
+			require.Len(t, commitTx.TxOut, len(tc.expectedScript))
+
+			// The anchors follow the balance outputs, which we
+			// don't check here, and carry a fixed value.
+			for i, script := range tc.expectedScript {
+				txOut := commitTx.TxOut[i]
+				if script == nil {
+					require.NotEqual(
+						t, int64(anchorSize), txOut.Value,
+					)
+					continue
+				}
+
+				require.Equal(t, script, txOut.PkScript)
+				require.EqualValues(t, anchorSize, txOut.Value)
+			}
+		})
+	}
+}
+
 type mockProducer struct {
 	secret chainhash.Hash
 }
@@ -0,0 +1,253 @@
+package lnwallet
+
+import (
+	"container/list"
+	"sort"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+// updateLogOverflow is a store that HTLCs can be spilled to from an in-memory
+// update log. It's implemented by channeldb.OpenChannel.
+type updateLogOverflow interface {
+	// PutOverflowHtlc stores the passed HTLC.
+	PutOverflowHtlc(htlc *channeldb.OverflowHtlc) error
+
+	// FetchOverflowHtlc returns the HTLC with the given direction and
+	// index.
+	FetchOverflowHtlc(incoming bool,
+		htlcIndex uint64) (*channeldb.OverflowHtlc, error)
+
+	// FetchOverflowHtlcs returns all stored HTLCs of the given direction.
+	FetchOverflowHtlcs(incoming bool) ([]channeldb.OverflowHtlc, error)
+
+	// DeleteOverflowHtlc removes the HTLC with the given direction and
+	// index.
+	DeleteOverflowHtlc(incoming bool, htlcIndex uint64) error
+}
+
+// spilledHtlc is the in-memory summary of an HTLC that was spilled to the
+// overflow store. It holds everything needed to evaluate balances, fees and
+// dust of a commitment view, so the store is only read when a commitment is
+// actually built. Whether the HTLC is dust depends on the fee rate of the
+// commitment, so it's classified from its amount on evaluation.
+type spilledHtlc struct {
+	amount                lnwire.MilliSatoshi
+	timeout               uint32
+	logIndex              uint64
+	addCommitHeightLocal  uint64
+	addCommitHeightRemote uint64
+}
+
+// WithMaxInMemoryUpdates caps the number of entries each update log of the
+// channel keeps in memory. Once a log holds more entries after a state
+// transition, its oldest HTLCs which are irrevocably committed on both
+// commitments are spilled to the channel database. Such HTLCs only need to be
+// read again when a new commitment is built, or when they're settled or
+// failed, at which point they're restored transparently. This trades disk
+// reads for memory on channels with thousands of pending HTLCs. A value of
+// zero, the default, keeps all entries in memory.
+func WithMaxInMemoryUpdates(maxUpdates int) ChannelOpt {
+	return func(o *channelOpts) {
+		o.maxInMemoryUpdates = maxUpdates
+	}
+}
+
+// setOverflow enables spilling HTLCs of the update log to the passed store
+// once it holds more than maxInMemory entries. The incoming flag denotes
+// whether the HTLCs added to this log are incoming from our point of view.
+func (u *updateLog) setOverflow(store updateLogOverflow, incoming bool,
+	maxInMemory int) {
+
+	u.overflow = store
+	u.incoming = incoming
+	u.maxInMemory = maxInMemory
+}
+
+// numSpilledHtlcs returns the number of HTLCs of the log that currently reside
+// in the overflow store.
+func (u *updateLog) numSpilledHtlcs() int {
+	return len(u.spilledHtlcs)
+}
+
+// spillHtlcs moves the oldest HTLCs of the log to the overflow store until the
+// log holds no more than maxInMemory entries, or no more HTLCs can be spilled.
+// Only HTLCs that are committed on the tails of both commitment chains and
+// have no pending modification are spilled, as they won't change until they
+// are settled or failed.
+func (u *updateLog) spillHtlcs(localChainTail, remoteChainTail uint64) error {
+	if u.overflow == nil || u.maxInMemory <= 0 {
+		return nil
+	}
+
+	var next *list.Element
+	for e := u.Front(); e != nil && u.Len() > u.maxInMemory; e = next {
+		next = e.Next()
+
+		pd := e.Value.(*PaymentDescriptor)
+		if pd.EntryType != Add || u.htlcHasModification(pd.HtlcIndex) {
+			continue
+		}
+
+		lockedIn := pd.addCommitHeightLocal != 0 &&
+			pd.addCommitHeightRemote != 0 &&
+			localChainTail >= pd.addCommitHeightLocal &&
+			remoteChainTail >= pd.addCommitHeightRemote
+		if !lockedIn {
+			continue
+		}
+
+		err := u.overflow.PutOverflowHtlc(
+			newOverflowHtlc(pd, u.incoming),
+		)
+		if err != nil {
+			return err
+		}
+
+		u.Remove(e)
+		delete(u.htlcIndex, pd.HtlcIndex)
+		u.spilledHtlcs[pd.HtlcIndex] = &spilledHtlc{
+			amount:                pd.Amount,
+			timeout:               pd.Timeout,
+			logIndex:              pd.LogIndex,
+			addCommitHeightLocal:  pd.addCommitHeightLocal,
+			addCommitHeightRemote: pd.addCommitHeightRemote,
+		}
+	}
+
+	return nil
+}
+
+// restoreSpilledHtlc reads the spilled HTLC with the given index back from the
+// overflow store and re-inserts it into the log, ordered by its log index.
+func (u *updateLog) restoreSpilledHtlc(i uint64) (*PaymentDescriptor, error) {
+	htlc, err := u.overflow.FetchOverflowHtlc(u.incoming, i)
+	if err != nil {
+		return nil, err
+	}
+	pd := paymentDescriptorFromOverflow(htlc)
+
+	// Insert the HTLC in front of the first entry that was added after
+	// it, so the log remains ordered by log index.
+	e := u.Front()
+	for e != nil && e.Value.(*PaymentDescriptor).LogIndex < pd.LogIndex {
+		e = e.Next()
+	}
+	if e == nil {
+		u.htlcIndex[i] = u.PushBack(pd)
+	} else {
+		u.htlcIndex[i] = u.InsertBefore(pd, e)
+	}
+	delete(u.spilledHtlcs, i)
+
+	// The copy in the overflow store is no longer needed. Failing to
+	// delete it is harmless, as it's ignored unless spilled again, which
+	// overwrites it.
+	if err := u.overflow.DeleteOverflowHtlc(u.incoming, i); err != nil {
+		walletLog.Warnf("Unable to delete spilled htlc %v: %v", i, err)
+	}
+
+	return pd, nil
+}
+
+// spilledHtlcSummaries returns Add entries for all HTLCs of the log that
+// currently reside in the overflow store, ordered by log index. They're built
+// from the in-memory summaries and thus lack the payment hash and onion blob,
+// so they may only be used to evaluate balances, not to build a commitment.
+func (u *updateLog) spilledHtlcSummaries() []*PaymentDescriptor {
+	if len(u.spilledHtlcs) == 0 {
+		return nil
+	}
+
+	pds := make([]*PaymentDescriptor, 0, len(u.spilledHtlcs))
+	for htlcIndex, htlc := range u.spilledHtlcs {
+		pds = append(pds, &PaymentDescriptor{
+			Timeout:               htlc.timeout,
+			Amount:                htlc.amount,
+			EntryType:             Add,
+			HtlcIndex:             htlcIndex,
+			LogIndex:              htlc.logIndex,
+			addCommitHeightLocal:  htlc.addCommitHeightLocal,
+			addCommitHeightRemote: htlc.addCommitHeightRemote,
+			isForwarded:           u.incoming,
+		})
+	}
+	sort.Slice(pds, func(i, j int) bool {
+		return pds[i].LogIndex < pds[j].LogIndex
+	})
+
+	return pds
+}
+
+// fetchSpilledHtlcs returns copies of all HTLCs of the log that currently
+// reside in the overflow store, without restoring them to the log.
+func (u *updateLog) fetchSpilledHtlcs() ([]*PaymentDescriptor, error) {
+	if len(u.spilledHtlcs) == 0 {
+		return nil, nil
+	}
+
+	htlcs, err := u.overflow.FetchOverflowHtlcs(u.incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	// The store may still hold stale copies of HTLCs that were restored
+	// or resolved in the meantime, so we only return the ones we know to
+	// be spilled.
+	pds := make([]*PaymentDescriptor, 0, len(u.spilledHtlcs))
+	for i := range htlcs {
+		if _, ok := u.spilledHtlcs[htlcs[i].HtlcIndex]; !ok {
+			continue
+		}
+
+		pds = append(pds, paymentDescriptorFromOverflow(&htlcs[i]))
+	}
+
+	return pds, nil
+}
+
+// newOverflowHtlc converts the passed Add entry into the format stored by the
+// overflow store.
+func newOverflowHtlc(pd *PaymentDescriptor,
+	incoming bool) *channeldb.OverflowHtlc {
+
+	htlc := &channeldb.OverflowHtlc{
+		HTLC: channeldb.HTLC{
+			RHash:         pd.RHash,
+			Amt:           pd.Amount,
+			RefundTimeout: pd.Timeout,
+			Incoming:      incoming,
+			HtlcIndex:     pd.HtlcIndex,
+			LogIndex:      pd.LogIndex,
+		},
+		AddCommitHeightLocal:  pd.addCommitHeightLocal,
+		AddCommitHeightRemote: pd.addCommitHeightRemote,
+	}
+	copy(htlc.OnionBlob[:], pd.OnionBlob)
+
+	return htlc
+}
+
+// paymentDescriptorFromOverflow converts the stored HTLC back into an Add
+// entry. As only HTLCs locked in on both commitments are spilled, incoming
+// HTLCs have already been forwarded.
+func paymentDescriptorFromOverflow(
+	htlc *channeldb.OverflowHtlc) *PaymentDescriptor {
+
+	onionBlob := make([]byte, len(htlc.OnionBlob))
+	copy(onionBlob, htlc.OnionBlob[:])
+
+	return &PaymentDescriptor{
+		RHash:                 htlc.RHash,
+		Timeout:               htlc.RefundTimeout,
+		Amount:                htlc.Amt,
+		EntryType:             Add,
+		HtlcIndex:             htlc.HtlcIndex,
+		LogIndex:              htlc.LogIndex,
+		OnionBlob:             onionBlob,
+		addCommitHeightLocal:  htlc.AddCommitHeightLocal,
+		addCommitHeightRemote: htlc.AddCommitHeightRemote,
+		isForwarded:           htlc.Incoming,
+	}
+}
@@ -0,0 +1,269 @@
+package lnwallet
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpdateLogSpill asserts that locked in HTLCs are spilled out of the
+// update logs once they exceed their in-memory limit, and that the channel
+// keeps operating on them as if they were still in memory.
+func TestUpdateLogSpill(t *testing.T) {
+	t.Parallel()
+
+	const maxInMemory = 2
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	// Restart both parties with a limit on the in-memory entries.
+	restart := func(lc *LightningChannel) *LightningChannel {
+		lc, err := NewLightningChannel(
+			lc.Signer, lc.channelState, lc.sigPool,
+			WithMaxInMemoryUpdates(maxInMemory),
+		)
+		require.NoError(t, err)
+
+		return lc
+	}
+	aliceChannel = restart(aliceChannel)
+	bobChannel = restart(bobChannel)
+
+	// Alice offers a number of HTLCs to Bob, including a dust HTLC, and
+	// Bob offers one to Alice.
+	amts := []btcutil.Amount{100, 20_000, 30_000, 40_000, 50_000}
+	preimages := make([][32]byte, len(amts))
+	for i, amt := range amts {
+		htlc, preimage := createHTLC(i, lnwire.NewMSatFromSatoshis(amt))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+
+		preimages[i] = preimage
+	}
+	bobHtlc, bobPreimage := createHTLC(
+		0, lnwire.NewMSatFromSatoshis(60_000),
+	)
+	_, err = bobChannel.AddHTLC(bobHtlc, nil)
+	require.NoError(t, err)
+	_, err = aliceChannel.ReceiveHTLC(bobHtlc)
+	require.NoError(t, err)
+
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	// Alice only learns that the HTLCs are locked in on both commitments
+	// with the next revocation, so we run another state transition that
+	// also updates the fee. Bob already spilled the HTLCs at this point,
+	// so the signatures only verify if the new states still cover them.
+	newFeeRate := aliceChannel.CommitFeeRate() * 2
+	require.NoError(t, aliceChannel.UpdateFee(newFeeRate))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(newFeeRate))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	require.Len(
+		t, aliceChannel.channelState.LocalCommitment.Htlcs,
+		len(amts)+1,
+	)
+
+	// Both parties now spilled enough of Alice's HTLCs to respect the
+	// limit. Alice's log may also still hold her fee update, which can't
+	// be spilled.
+	aliceLog := aliceChannel.localUpdateLog
+	bobLog := bobChannel.remoteUpdateLog
+	for _, log := range []*updateLog{aliceLog, bobLog} {
+		require.LessOrEqual(t, log.Len(), maxInMemory)
+		require.GreaterOrEqual(
+			t, log.numSpilledHtlcs(), len(amts)-maxInMemory,
+		)
+	}
+	require.Zero(t, aliceChannel.remoteUpdateLog.numSpilledHtlcs())
+	require.Zero(t, bobChannel.localUpdateLog.numSpilledHtlcs())
+
+	// The spilled dust HTLC still counts towards the dust exposure.
+	dustSum := lnwire.NewMSatFromSatoshis(amts[0])
+	require.Equal(t, dustSum, aliceChannel.GetDustSum(false))
+	require.Equal(t, dustSum, bobChannel.GetDustSum(true))
+
+	// Bob settles the oldest HTLC, which was spilled, so looking it up
+	// restores it.
+	aliceSpilled := aliceLog.numSpilledHtlcs()
+	bobSpilled := bobLog.numSpilledHtlcs()
+	err = bobChannel.SettleHTLC(preimages[0], 0, nil, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, aliceChannel.ReceiveHTLCSettle(preimages[0], 0))
+	require.Equal(t, aliceSpilled-1, aliceLog.numSpilledHtlcs())
+	require.Equal(t, bobSpilled-1, bobLog.numSpilledHtlcs())
+	require.NotNil(t, aliceLog.lookupHtlc(0))
+	require.NotNil(t, bobLog.lookupHtlc(0))
+
+	// Alice settles Bob's HTLC as well, and both settles are locked in.
+	err = aliceChannel.SettleHTLC(bobPreimage, 0, nil, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, bobChannel.ReceiveHTLCSettle(bobPreimage, 0))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+	require.Len(
+		t, aliceChannel.channelState.LocalCommitment.Htlcs,
+		len(amts)-1,
+	)
+
+	// Failing all of Bob's incoming HTLCs, once his link processed them,
+	// includes the spilled ones.
+	processFwdPkgs(t, bobChannel)
+	failed, err := bobChannel.FailAllPendingIncoming(drainFailReason)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3, 4}, failed)
+	require.Zero(t, bobLog.numSpilledHtlcs())
+
+	for _, htlcIndex := range failed {
+		reason := []byte(fmt.Sprintf("drain %d", htlcIndex))
+		err := aliceChannel.ReceiveFailHTLC(htlcIndex, reason)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ForceStateTransition(bobChannel, aliceChannel))
+	require.Empty(t, aliceChannel.channelState.LocalCommitment.Htlcs)
+	require.Empty(t, bobChannel.channelState.LocalCommitment.Htlcs)
+}
+
+// countingOverflow wraps an overflow store and counts the reads of all its
+// HTLCs.
+type countingOverflow struct {
+	updateLogOverflow
+
+	fetches int
+}
+
+// FetchOverflowHtlcs returns all stored HTLCs of the given direction.
+func (c *countingOverflow) FetchOverflowHtlcs(
+	incoming bool) ([]channeldb.OverflowHtlc, error) {
+
+	c.fetches++
+
+	return c.updateLogOverflow.FetchOverflowHtlcs(incoming)
+}
+
+// TestUpdateLogSpillCommitment asserts that balances and dust of a channel
+// with spilled HTLCs are evaluated without reading the overflow store, and
+// that new commitments signed and received afterwards still cover the
+// spilled HTLCs in full.
+func TestUpdateLogSpillCommitment(t *testing.T) {
+	t.Parallel()
+
+	const maxInMemory = 2
+
+	aliceChannel, bobChannel, err := CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err)
+
+	restart := func(lc *LightningChannel) *LightningChannel {
+		lc, err := NewLightningChannel(
+			lc.Signer, lc.channelState, lc.sigPool,
+			WithMaxInMemoryUpdates(maxInMemory),
+		)
+		require.NoError(t, err)
+
+		return lc
+	}
+	aliceChannel = restart(aliceChannel)
+	bobChannel = restart(bobChannel)
+
+	// Alice offers a number of HTLCs to Bob, including a dust HTLC, and
+	// locks them in on both commitments, so they get spilled.
+	amts := []btcutil.Amount{100, 20_000, 30_000, 40_000}
+	var hashes [][32]byte
+	addHtlc := func(i int, amt btcutil.Amount) {
+		htlc, _ := createHTLC(i, lnwire.NewMSatFromSatoshis(amt))
+		_, err := aliceChannel.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+		_, err = bobChannel.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+
+		hashes = append(hashes, htlc.PaymentHash)
+	}
+	for i, amt := range amts {
+		addHtlc(i, amt)
+	}
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	newFeeRate := aliceChannel.CommitFeeRate() * 2
+	require.NoError(t, aliceChannel.UpdateFee(newFeeRate))
+	require.NoError(t, bobChannel.ReceiveUpdateFee(newFeeRate))
+	require.NoError(t, ForceStateTransition(aliceChannel, bobChannel))
+
+	aliceLog := aliceChannel.localUpdateLog
+	bobLog := bobChannel.remoteUpdateLog
+	require.Positive(t, aliceLog.numSpilledHtlcs())
+	require.Positive(t, bobLog.numSpilledHtlcs())
+
+	aliceStore := &countingOverflow{updateLogOverflow: aliceLog.overflow}
+	aliceLog.overflow = aliceStore
+	bobStore := &countingOverflow{updateLogOverflow: bobLog.overflow}
+	bobLog.overflow = bobStore
+
+	// Evaluating balances and dust, as well as validating a new HTLC,
+	// only needs the in-memory summaries of the spilled HTLCs.
+	aliceBalance := aliceChannel.AvailableBalance()
+	require.Positive(t, aliceBalance)
+	require.Positive(t, bobChannel.AvailableBalance())
+	aliceChannel.LiquidityReport()
+	bobChannel.LiquidityReport()
+
+	dustSum := lnwire.NewMSatFromSatoshis(amts[0])
+	require.Equal(t, dustSum, aliceChannel.GetDustSum(false))
+	require.Equal(t, dustSum, bobChannel.GetDustSum(true))
+
+	addHtlc(len(amts), 50_000)
+	require.Zero(t, aliceStore.fetches)
+	require.Zero(t, bobStore.fetches)
+
+	// The new HTLC is deducted from Alice's balance, which is still
+	// evaluated without reading the store.
+	require.Less(t, aliceChannel.AvailableBalance(), aliceBalance)
+	require.Zero(t, aliceStore.fetches)
+
+	// Building the commitments to sign and verify reads the spilled
+	// HTLCs back. Bob only accepts Alice's signatures if the spilled
+	// HTLCs are covered with their payment hashes, and vice versa.
+	aliceNewCommit, err := aliceChannel.SignNextCommitment()
+	require.NoError(t, err)
+	require.Positive(t, aliceStore.fetches)
+
+	err = bobChannel.ReceiveNewCommitment(aliceNewCommit.CommitSigs)
+	require.NoError(t, err)
+	require.Positive(t, bobStore.fetches)
+
+	bobRevocation, _, _, err := bobChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = aliceChannel.ReceiveRevocation(bobRevocation)
+	require.NoError(t, err)
+
+	bobNewCommit, err := bobChannel.SignNextCommitment()
+	require.NoError(t, err)
+	err = aliceChannel.ReceiveNewCommitment(bobNewCommit.CommitSigs)
+	require.NoError(t, err)
+
+	aliceRevocation, _, _, err := aliceChannel.RevokeCurrentCommitment()
+	require.NoError(t, err)
+	_, _, _, _, err = bobChannel.ReceiveRevocation(aliceRevocation)
+	require.NoError(t, err)
+
+	for _, lc := range []*LightningChannel{aliceChannel, bobChannel} {
+		htlcs := lc.channelState.LocalCommitment.Htlcs
+		require.Len(t, htlcs, len(hashes))
+
+		committed := make(map[[32]byte]struct{}, len(htlcs))
+		for _, htlc := range htlcs {
+			committed[htlc.RHash] = struct{}{}
+		}
+		for _, hash := range hashes {
+			require.Contains(t, committed, hash)
+		}
+	}
+}
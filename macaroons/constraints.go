@@ -2,12 +2,16 @@ package macaroons
 
 import (
 	"bytes"
+	"container/list"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/lightningnetwork/lnd/clock"
 	"google.golang.org/grpc/peer"
 	"gopkg.in/macaroon-bakery.v2/bakery/checkers"
 	macaroon "gopkg.in/macaroon.v2"
@@ -21,6 +25,24 @@ const (
 	// in the serialized macaroon. We choose a single space as the delimiter
 	// between the because that is also used by the macaroon bakery library.
 	CondLndCustom = "lnd-custom"
+
+	// CondNonce is the first party caveat condition name of the nonce
+	// caveat, which carries a random value that makes every derived
+	// macaroon unique.
+	CondNonce = "nonce"
+
+	// NonceSize is the size in bytes of the random value of a nonce
+	// caveat.
+	NonceSize = 16
+
+	// DefaultNonceExpiry is the default duration for which NonceChecker
+	// remembers a nonce. It's well above the default validity time of
+	// the macaroons lncli derives.
+	DefaultNonceExpiry = 10 * time.Minute
+
+	// DefaultMaxNonces is the default number of nonces NonceChecker
+	// remembers per host at a time.
+	DefaultMaxNonces = 10_000
 )
 
 // CustomCaveatAcceptor is an interface that contains a single method for
@@ -122,6 +144,244 @@ func IPLockChecker() (string, checkers.Func) {
 	}
 }
 
+// NonceConstraint adds a caveat with the given random nonce to the macaroon.
+// Together with the TimeoutConstraint this makes every derived macaroon unique
+// within its validity window, which allows the server to reject a macaroon
+// that is replayed by a different client, see NonceChecker.
+func NonceConstraint(nonce [NonceSize]byte) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		caveat := checkers.Condition(CondNonce, hex.EncodeToString(
+			nonce[:],
+		))
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// macaroonCtxKey is the context key under which CheckMacAuth stores the
+// macaroon that is being checked.
+type macaroonCtxKey struct{}
+
+// ContextWithMacaroon returns a copy of the context that carries the passed
+// macaroon. CheckMacAuth uses it to make the macaroon that is being checked
+// available to checkers that need to inspect its other caveats.
+func ContextWithMacaroon(ctx context.Context,
+	mac *macaroon.Macaroon) context.Context {
+
+	return context.WithValue(ctx, macaroonCtxKey{}, mac)
+}
+
+// macaroonExpiry returns the earliest time-before caveat of the macaroon
+// stored in the context, if any.
+func macaroonExpiry(ctx context.Context) (time.Time, bool) {
+	mac, ok := ctx.Value(macaroonCtxKey{}).(*macaroon.Macaroon)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	var (
+		expiry    time.Time
+		hasExpiry bool
+	)
+	for _, caveat := range mac.Caveats() {
+		if len(caveat.VerificationId) > 0 {
+			continue
+		}
+
+		cond, arg, err := checkers.ParseCaveat(string(caveat.Id))
+		if err != nil || cond != checkers.CondTimeBefore {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, arg)
+		if err != nil {
+			continue
+		}
+
+		if !hasExpiry || t.Before(expiry) {
+			expiry, hasExpiry = t, true
+		}
+	}
+
+	return expiry, hasExpiry
+}
+
+// seenNonce is a nonce NonceChecker has accepted.
+type seenNonce struct {
+	// nonce is the hex encoded nonce.
+	nonce string
+
+	// peerHost is the host of the connection the nonce was first
+	// presented by.
+	peerHost string
+
+	// expiry is the time after which the nonce is forgotten.
+	expiry time.Time
+
+	// hostElem is the element of the nonce in the queue of its host.
+	hostElem *list.Element
+}
+
+// nonceTracker keeps track of the nonces NonceChecker has accepted.
+type nonceTracker struct {
+	expiry    time.Duration
+	maxNonces int
+	clock     clock.Clock
+
+	// seen maps each nonce to its element in queue.
+	seen map[string]*list.Element
+
+	// queue holds the seen nonces ordered by their expiry. As every nonce
+	// is remembered for the same duration, this is the order in which
+	// they were first seen.
+	queue *list.List
+
+	// hosts holds the seen nonces of each host, in the order in which
+	// they were first seen.
+	hosts map[string]*list.List
+
+	mtx sync.Mutex
+}
+
+// forget removes the nonce of the passed queue element from the tracker.
+//
+// NOTE: The caller must hold the tracker's mutex.
+func (n *nonceTracker) forget(e *list.Element) {
+	s := e.Value.(*seenNonce)
+
+	n.queue.Remove(e)
+	delete(n.seen, s.nonce)
+
+	hostQueue := n.hosts[s.peerHost]
+	hostQueue.Remove(s.hostElem)
+	if hostQueue.Len() == 0 {
+		delete(n.hosts, s.peerHost)
+	}
+}
+
+// check accepts the nonce if it hasn't been seen before, or if it has been
+// first presented by the same host. If the host already has maxNonces nonces
+// remembered, its oldest one is forgotten to make room for the new one.
+func (n *nonceTracker) check(nonce, peerHost string) error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	// Forget all nonces that expired. As the queue is ordered by expiry,
+	// we can stop at the first one that hasn't.
+	now := n.clock.Now()
+	for e := n.queue.Front(); e != nil; e = n.queue.Front() {
+		if !now.After(e.Value.(*seenNonce).expiry) {
+			break
+		}
+
+		n.forget(e)
+	}
+
+	if e, ok := n.seen[nonce]; ok {
+		if e.Value.(*seenNonce).peerHost != peerHost {
+			return fmt.Errorf("macaroon nonce replayed")
+		}
+
+		return nil
+	}
+
+	// Bounding the nonces per host keeps a single host from exhausting
+	// the tracker for all others. Evicting the oldest nonce of the host
+	// only allows another host to replay it, which a host flooding us
+	// with nonces can't use to its advantage.
+	hostQueue, ok := n.hosts[peerHost]
+	if !ok {
+		hostQueue = list.New()
+		n.hosts[peerHost] = hostQueue
+	}
+	if hostQueue.Len() >= n.maxNonces {
+		oldest := hostQueue.Front().Value.(*seenNonce)
+		n.forget(n.seen[oldest.nonce])
+	}
+
+	s := &seenNonce{
+		nonce:    nonce,
+		peerHost: peerHost,
+		expiry:   now.Add(n.expiry),
+	}
+	s.hostElem = hostQueue.PushBack(s)
+	n.seen[nonce] = n.queue.PushBack(s)
+
+	return nil
+}
+
+// NonceChecker returns a Checker for the nonce caveat of a macaroon. A client
+// sends the same macaroon with every call it makes, so a nonce is bound to the
+// host that first presents it, and is rejected if it's presented by any other
+// host within the given expiry. The nonce is bound to the IP address of the
+// host rather than to the connection, so a client that reconnects from a new
+// port can keep using its macaroon. At most maxNonces nonces are remembered
+// per host at a time. Once a host exceeds that, its oldest nonce is forgotten.
+//
+// As a nonce is forgotten once the expiry has passed, a macaroon carrying a
+// nonce is only accepted if its time-before caveat lets it expire within the
+// nonce expiry, see ContextWithMacaroon.
+//
+// NOTE: Replays from the same host as the legitimate client aren't detected.
+// Calls relayed by the REST proxy all share its host, so the nonces of such
+// calls only protect against replay by gRPC clients.
+func NonceChecker(expiry time.Duration, maxNonces int,
+	clock clock.Clock) Checker {
+
+	tracker := &nonceTracker{
+		expiry:    expiry,
+		maxNonces: maxNonces,
+		clock:     clock,
+		seen:      make(map[string]*list.Element),
+		queue:     list.New(),
+		hosts:     make(map[string]*list.List),
+	}
+
+	return func() (string, checkers.Func) {
+		return CondNonce, func(ctx context.Context, cond,
+			arg string) error {
+
+			nonce, err := hex.DecodeString(arg)
+			if err != nil {
+				return fmt.Errorf("invalid macaroon nonce: %w",
+					err)
+			}
+			if len(nonce) != NonceSize {
+				return fmt.Errorf("invalid macaroon nonce "+
+					"size %d, expected %d", len(nonce),
+					NonceSize)
+			}
+
+			macExpiry, ok := macaroonExpiry(ctx)
+			if !ok {
+				return fmt.Errorf("macaroon nonce requires " +
+					"a time-before caveat")
+			}
+			maxExpiry := clock.Now().Add(expiry)
+			if macExpiry.After(maxExpiry) {
+				return fmt.Errorf("macaroon with nonce "+
+					"expires after %v, must expire within "+
+					"%v", macExpiry, expiry)
+			}
+
+			pr, ok := peer.FromContext(ctx)
+			if !ok {
+				return fmt.Errorf("unable to get peer info " +
+					"from context")
+			}
+
+			// Addresses without a port, e.g. of unix sockets, are
+			// used as is.
+			peerHost := pr.Addr.String()
+			host, _, err := net.SplitHostPort(peerHost)
+			if err == nil {
+				peerHost = host
+			}
+
+			return tracker.check(arg, peerHost)
+		}
+	}
+}
+
 // CustomConstraint returns a function that adds a custom caveat condition to
 // a macaroon.
 func CustomConstraint(name, condition string) func(*macaroon.Macaroon) error {
@@ -1,13 +1,17 @@
 package macaroons_test
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/lightningnetwork/lnd/clock"
 	"github.com/lightningnetwork/lnd/macaroons"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/peer"
 	macaroon "gopkg.in/macaroon.v2"
 )
 
@@ -147,3 +151,90 @@ func TestCustomConstraint(t *testing.T) {
 	)
 	require.Equal(t, customCaveatCondition, "")
 }
+
+// TestNonceConstraint tests that a nonce caveat is added to a macaroon and
+// that the nonce checker only accepts well formed nonces of short-lived
+// macaroons, which aren't replayed by a different host before they expire.
+func TestNonceConstraint(t *testing.T) {
+	var nonce [macaroons.NonceSize]byte
+	copy(nonce[:], "0123456789abcdef")
+
+	testMacaroon := createDummyMacaroon(t)
+	require.NoError(t, macaroons.NonceConstraint(nonce)(testMacaroon))
+	require.Equal(
+		t, []byte("nonce 30313233343536373839616263646566"),
+		testMacaroon.Caveats()[0].Id,
+	)
+
+	const expiry = time.Minute
+	testClock := clock.NewTestClock(time.Now())
+	name, checker := macaroons.NonceChecker(expiry, 2, testClock)()
+	require.Equal(t, macaroons.CondNonce, name)
+
+	shortMac := createDummyMacaroon(t)
+	require.NoError(t, macaroons.TimeoutConstraint(30)(shortMac))
+	longMac := createDummyMacaroon(t)
+	require.NoError(t, macaroons.TimeoutConstraint(3600)(longMac))
+
+	peerCtx := func(ip string, port int,
+		mac *macaroon.Macaroon) context.Context {
+
+		ctx := peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{
+				IP:   net.ParseIP(ip),
+				Port: port,
+			},
+		})
+
+		return macaroons.ContextWithMacaroon(ctx, mac)
+	}
+	ctxA := peerCtx("127.0.0.1", 10001, shortMac)
+	ctxB := peerCtx("10.0.0.1", 10002, shortMac)
+	arg := "30313233343536373839616263646566"
+
+	require.Error(t, checker(ctxA, name, "not hex"))
+	require.Error(t, checker(ctxA, name, "3031"))
+	require.Error(t, checker(
+		macaroons.ContextWithMacaroon(context.Background(), shortMac),
+		name, arg,
+	))
+
+	// A macaroon without a time-before caveat, or one that is valid for
+	// longer than the nonce is remembered, could be replayed once the
+	// nonce is forgotten, so it's rejected.
+	require.ErrorContains(t, checker(
+		peerCtx("127.0.0.1", 10001, createDummyMacaroon(t)), name, arg,
+	), "time-before")
+	require.ErrorContains(t, checker(
+		peerCtx("127.0.0.1", 10001, longMac), name, arg,
+	), "must expire within")
+
+	// The host that first presents the nonce may use it for all its
+	// calls, even after reconnecting from a different port, while any
+	// other host is rejected.
+	require.NoError(t, checker(ctxA, name, arg))
+	require.NoError(t, checker(ctxA, name, arg))
+	require.NoError(t, checker(
+		peerCtx("127.0.0.1", 10003, shortMac), name, arg,
+	))
+	require.ErrorContains(t, checker(ctxB, name, arg), "replayed")
+
+	// Only two nonces are remembered per host, so a third one of the
+	// same host evicts its oldest one, which then may be used by another
+	// host. The nonces of other hosts aren't affected.
+	arg2 := "30313233343536373839616263646567"
+	arg3 := "30313233343536373839616263646568"
+	arg4 := "30313233343536373839616263646569"
+	require.NoError(t, checker(ctxB, name, arg2))
+	require.NoError(t, checker(ctxB, name, arg3))
+	require.NoError(t, checker(ctxB, name, arg4))
+	require.NoError(t, checker(ctxA, name, arg2))
+	require.ErrorContains(t, checker(ctxA, name, arg3), "replayed")
+	require.ErrorContains(t, checker(ctxB, name, arg), "replayed")
+
+	// Once the nonces expired, they're forgotten.
+	testClock.SetTime(testClock.Now().Add(expiry + time.Second))
+	require.NoError(t, checker(ctxB, name, arg))
+	require.ErrorContains(t, checker(ctxA, name, arg), "replayed")
+	require.NoError(t, checker(ctxB, name, arg3))
+}
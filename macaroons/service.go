@@ -210,6 +210,9 @@ func (svc *Service) CheckMacAuth(ctx context.Context, macBytes []byte,
 
 	// Check the method being called against the permitted operation, the
 	// expiration time and IP address and return the result.
+	// The macaroon is added to the context, so checkers are able to
+	// inspect its other caveats.
+	ctx = ContextWithMacaroon(ctx, mac)
 	authChecker := svc.Checker.Auth(macaroon.Slice{mac})
 	_, err = authChecker.Allow(ctx, requiredPermissions...)
 
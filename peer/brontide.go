@@ -293,6 +293,11 @@ type Config struct {
 	// not to replay adds on its commitment tx.
 	UnsafeReplay bool
 
+	// VerifyHtlcSigs indicates whether the stored HTLC signatures of a
+	// channel's local commitment should be verified when the channel is
+	// loaded. Channels failing the verification aren't started.
+	VerifyHtlcSigs bool
+
 	// MaxOutgoingCltvExpiry is used when creating ChannelLinks and is the max
 	// number of blocks that funds could be locked up for when forwarding
 	// payments.
@@ -342,6 +347,19 @@ type Config struct {
 	// aware of the channel's aliases.
 	GetAliases func(base lnwire.ShortChannelID) []lnwire.ShortChannelID
 
+	// HtlcInterceptor, if set, is passed to created links to decide
+	// whether the incoming HTLCs to be forwarded are accepted, held or
+	// rejected.
+	//
+	// NOTE: The server doesn't set an interceptor, this is a hook for
+	// embedders of the peer package.
+	HtlcInterceptor htlcswitch.HtlcInterceptor
+
+	// InterceptRejectDelta defines the number of blocks before the expiry
+	// of an incoming HTLC held by the HtlcInterceptor at which it's failed
+	// back.
+	InterceptRejectDelta uint32
+
 	// RequestAlias allows the Brontide struct to request an alias to send
 	// to the peer.
 	RequestAlias func() (lnwire.ShortChannelID, error)
@@ -799,6 +817,18 @@ func (p *Brontide) loadActiveChannels(chans []*channeldb.OpenChannel) (
 		p.log.Infof("Loading ChannelPoint(%v), isPending=%v",
 			chanPoint, lnChan.IsPending())
 
+		// If requested, we'll make sure the HTLC signatures we'd need
+		// to go to chain are still intact before using the channel.
+		if p.cfg.VerifyHtlcSigs {
+			if err := lnChan.VerifyStoredHTLCSigs(); err != nil {
+				p.log.Errorf("Unable to verify HTLC signatures "+
+					"of ChannelPoint(%v), won't start: %v",
+					chanPoint, err)
+
+				continue
+			}
+		}
+
 		// Skip adding any permanently irreconcilable channels to the
 		// htlcswitch.
 		if !dbChan.HasChanStatus(channeldb.ChanStatusDefault) &&
@@ -1032,6 +1062,9 @@ func (p *Brontide) addLink(chanPoint *wire.OutPoint,
 		NotifyInactiveLinkEvent: p.cfg.ChannelNotifier.NotifyInactiveLinkEvent,
 		HtlcNotifier:            p.cfg.HtlcNotifier,
 		GetAliases:              p.cfg.GetAliases,
+		HtlcInterceptor:         p.cfg.HtlcInterceptor,
+		InterceptRejectDelta:    p.cfg.InterceptRejectDelta,
+		ChainNotifier:           p.cfg.ChainNotifier,
 	}
 
 	// Before adding our new link, purge the switch of any pending or live
@@ -2915,8 +2948,9 @@ func (p *Brontide) createChanCloser(channel *lnwallet.LightningChannel,
 			Disconnect: func() error {
 				return p.cfg.DisconnectPeer(p.IdentityKey())
 			},
-			ChainParams: &p.cfg.Wallet.Cfg.NetParams,
-			Quit:        p.quit,
+			ChainParams:       &p.cfg.Wallet.Cfg.NetParams,
+			Quit:              p.quit,
+			AnySegwitShutdown: p.taprootShutdownAllowed(),
 		},
 		deliveryScript,
 		fee,
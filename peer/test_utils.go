@@ -169,6 +169,26 @@ func createTestPeer(t *testing.T, notifier chainntnfs.ChainNotifier,
 		return nil, nil, err
 	}
 
+	// Encode the initial state number within both commitments, as it's
+	// validated once the channels are restored.
+	obfuscator := lnwallet.DeriveStateHintObfuscator(
+		aliceCfg.PaymentBasePoint.PubKey, bobCfg.PaymentBasePoint.PubKey,
+	)
+	if !isAliceInitiator {
+		obfuscator = lnwallet.DeriveStateHintObfuscator(
+			bobCfg.PaymentBasePoint.PubKey,
+			aliceCfg.PaymentBasePoint.PubKey,
+		)
+	}
+	err = lnwallet.SetStateNumHint(aliceCommitTx, 0, obfuscator)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = lnwallet.SetStateNumHint(bobCommitTx, 0, obfuscator)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	dbAlice, err := channeldb.Open(t.TempDir())
 	if err != nil {
 		return nil, nil, err
@@ -484,6 +504,17 @@ func (m *mockUpdateHandler) MayAddOutgoingHtlc(lnwire.MilliSatoshi) error { retu
 // ShutdownIfChannelClean currently returns nil.
 func (m *mockUpdateHandler) ShutdownIfChannelClean() error { return nil }
 
+// ParkedHtlcs currently returns nil.
+func (m *mockUpdateHandler) ParkedHtlcs() []uint64 { return nil }
+
+// ResumeParkedHtlc currently returns nil.
+func (m *mockUpdateHandler) ResumeParkedHtlc(uint64) error { return nil }
+
+// CancelParkedHtlc currently returns nil.
+func (m *mockUpdateHandler) CancelParkedHtlc(uint64, lnwire.FailCode) error {
+	return nil
+}
+
 type mockMessageConn struct {
 	t *testing.T
 
@@ -77,6 +77,7 @@ import (
 	"github.com/tv42/zbase32"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"gopkg.in/macaroon-bakery.v2/bakery"
 )
@@ -325,6 +326,10 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "write",
 		}},
+		"/lnrpc.Lightning/ResyncChannel": {{
+			Entity: "offchain",
+			Action: "write",
+		}},
 		"/lnrpc.Lightning/GetInfo": {{
 			Entity: "info",
 			Action: "read",
@@ -2896,6 +2901,86 @@ func (r *rpcServer) AbandonChannel(_ context.Context,
 	return &lnrpc.AbandonChannelResponse{}, nil
 }
 
+// ResyncChannel cycles the connection to the peer of an open channel, which
+// triggers a new channel_reestablish exchange for all channels with the peer.
+// The sync status of the channel as seen from our local state before the
+// reconnection is returned.
+func (r *rpcServer) ResyncChannel(_ context.Context,
+	in *lnrpc.ResyncChannelRequest) (*lnrpc.ResyncChannelResponse, error) {
+
+	if !r.server.Started() {
+		return nil, ErrServerNotActive
+	}
+
+	if in.ChannelPoint == nil {
+		return nil, fmt.Errorf("channel point must be set")
+	}
+
+	txid, err := lnrpc.GetChanPointFundingTxid(in.GetChannelPoint())
+	if err != nil {
+		return nil, err
+	}
+	index := in.ChannelPoint.OutputIndex
+	chanPoint := wire.NewOutPoint(txid, index)
+
+	channel, err := r.server.chanStateDB.FetchChannel(nil, *chanPoint)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch channel %v: %w",
+			chanPoint, err)
+	}
+
+	status, err := chanSyncStatus(channel)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine sync status: %w", err)
+	}
+
+	rpcsLog.Infof("[resyncchannel] resyncing channel %v with sync "+
+		"status %v", chanPoint, status)
+
+	// A live link only exchanges channel_reestablish when it's started, so
+	// we cycle the connection to the peer to restart all of its links.
+	if err := r.server.ReconnectPeer(channel.IdentityPub); err != nil {
+		return nil, fmt.Errorf("unable to reconnect peer: %w", err)
+	}
+
+	return &lnrpc.ResyncChannelResponse{
+		SyncStatus: status,
+	}, nil
+}
+
+// chanSyncStatus returns the sync status of the channel as seen from our
+// persisted state. A revocation we may still owe the remote party can't be
+// derived from our state alone, as we send it right after persisting the new
+// local commitment, so if no commitment is outstanding on our side the status
+// is unknown. Whether a revocation has to be retransmitted is determined during
+// the channel_reestablish exchange.
+func chanSyncStatus(channel *channeldb.OpenChannel) (
+	lnrpc.ResyncChannelResponse_SyncStatus, error) {
+
+	// If we've sent a commitment the remote party hasn't revoked their
+	// prior state for yet, we're waiting for their revocation.
+	_, err := channel.RemoteCommitChainTip()
+	switch {
+	case err == nil:
+		return lnrpc.ResyncChannelResponse_AWAITING_REVOCATION, nil
+
+	case !errors.Is(err, channeldb.ErrNoPendingCommit):
+		return 0, err
+	}
+
+	// If there are remote updates we've acked but not yet signed for, we
+	// owe the remote party a commitment.
+	updates, err := channel.UnsignedAckedUpdates()
+	if err != nil {
+		return 0, err
+	}
+	if len(updates) > 0 {
+		return lnrpc.ResyncChannelResponse_OWE_COMMITMENT, nil
+	}
+
+	return lnrpc.ResyncChannelResponse_SYNC_STATUS_UNKNOWN, nil
+}
+
 // GetInfo returns general information concerning the lightning node including
 // its identity pubkey, alias, the chains it is connected to, and information
 // concerning the number of open+pending channels.
@@ -3314,19 +3399,42 @@ func (r *rpcServer) WalletBalance(ctx context.Context,
 	// the outputs will chose as being "gone" until they're confirmed on
 	// chain.
 	var lockedBalance btcutil.Amount
+	lockedOutpoints := make(map[wire.OutPoint]struct{})
+	addLocked := func(op wire.OutPoint) error {
+		if _, ok := lockedOutpoints[op]; ok {
+			return nil
+		}
+		lockedOutpoints[op] = struct{}{}
+
+		utxoInfo, err := r.server.cc.Wallet.FetchInputInfo(&op)
+		if err != nil {
+			return err
+		}
+
+		lockedBalance += utxoInfo.Value
+
+		return nil
+	}
+
 	leases, err := r.server.cc.Wallet.ListLeasedOutputs()
 	if err != nil {
 		return nil, err
 	}
 	for _, leasedOutput := range leases {
-		utxoInfo, err := r.server.cc.Wallet.FetchInputInfo(
-			&leasedOutput.Outpoint,
-		)
-		if err != nil {
+		if err := addLocked(leasedOutput.Outpoint); err != nil {
 			return nil, err
 		}
+	}
 
-		lockedBalance += utxoInfo.Value
+	// The inputs committed to channel openings that are still being
+	// negotiated are locked as well, so we'll include those in the
+	// locked balance.
+	for _, reservation := range r.server.cc.Wallet.ActiveReservations() {
+		for _, op := range reservation.OurContributionInputs() {
+			if err := addLocked(op); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Get the current number of non-private anchor channels.
@@ -6366,9 +6474,11 @@ func (r *rpcServer) GetNetworkInfo(ctx context.Context,
 }
 
 // StopDaemon will send a shutdown request to the interrupt handler, triggering
-// a graceful shutdown of the daemon.
+// a graceful shutdown of the daemon. If the force flag is set, the daemon exits
+// after the configured grace period even if some subsystems haven't finished
+// shutting down.
 func (r *rpcServer) StopDaemon(_ context.Context,
-	_ *lnrpc.StopRequest) (*lnrpc.StopResponse, error) {
+	in *lnrpc.StopRequest) (*lnrpc.StopResponse, error) {
 
 	// Before we even consider a shutdown, are we currently in recovery
 	// mode? We don't want to allow shutting down during recovery because
@@ -6385,6 +6495,18 @@ func (r *rpcServer) StopDaemon(_ context.Context,
 			"shut down, please wait until rescan finishes")
 	}
 
+	// If the caller asked for a forced shutdown, we won't wait for
+	// in-flight HTLCs or close negotiations with possibly unresponsive
+	// peers, and instead exit once the grace period has passed.
+	if in.Force {
+		timeout := r.cfg.ForceShutdownTimeout
+		rpcsLog.Warnf("Forced shutdown requested, exiting in at most %v",
+			timeout)
+
+		r.interceptor.RequestForcedShutdown(timeout)
+		return &lnrpc.StopResponse{}, nil
+	}
+
 	r.interceptor.RequestShutdown()
 	return &lnrpc.StopResponse{}, nil
 }
@@ -6655,12 +6777,13 @@ func (r *rpcServer) DebugLevel(ctx context.Context,
 	req *lnrpc.DebugLevelRequest) (*lnrpc.DebugLevelResponse, error) {
 
 	// If show is set, then we simply print out the list of available
-	// sub-systems.
+	// sub-systems along with their current log levels.
 	if req.Show {
 		return &lnrpc.DebugLevelResponse{
 			SubSystems: strings.Join(
 				r.cfg.LogWriter.SupportedSubsystems(), " ",
 			),
+			LevelSpec: build.DebugLevelSpec(r.cfg.LogWriter),
 		}, nil
 	}
 
@@ -559,6 +559,11 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		KeysendHoldTime:             cfg.KeysendHoldTime,
 	}
 
+	sigPool := lnwallet.NewSigPool(
+		cfg.Workers.Sig, cc.Signer,
+		lnwallet.WithSigPoolDrainTimeout(cfg.Workers.SigDrainTimeout),
+	)
+
 	s := &server{
 		cfg:            cfg,
 		graphDB:        dbs.GraphDB.ChannelGraph(),
@@ -566,7 +571,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		addrSource:     dbs.ChanStateDB,
 		miscDB:         dbs.ChanStateDB,
 		cc:             cc,
-		sigPool:        lnwallet.NewSigPool(cfg.Workers.Sig, cc.Signer),
+		sigPool:        sigPool,
 		writePool:      writePool,
 		readPool:       readPool,
 		chansToRestore: chansToRestore,
@@ -2333,7 +2338,9 @@ func (s *server) Stop() error {
 		s.wg.Wait()
 
 		srvrLog.Debug("Stopping buffer pools...")
-		s.sigPool.Stop()
+		if err := s.sigPool.Stop(); err != nil {
+			srvrLog.Warnf("unable to stop sig pool: %v", err)
+		}
 		s.writePool.Stop()
 		s.readPool.Stop()
 	})
@@ -3810,6 +3817,7 @@ func (s *server) peerConnected(conn net.Conn, connReq *connmgr.ConnReq,
 		Features:                initFeatures,
 		LegacyFeatures:          legacyFeatures,
 		OutgoingCltvRejectDelta: lncfg.DefaultOutgoingCltvRejectDelta,
+		InterceptRejectDelta:    lncfg.DefaultFinalCltvRejectDelta,
 		ChanActiveTimeout:       s.cfg.ChanEnableTimeout,
 		ErrorBuffer:             errBuffer,
 		WritePool:               s.writePool,
@@ -3852,6 +3860,7 @@ func (s *server) peerConnected(conn net.Conn, connReq *connmgr.ConnReq,
 
 		Hodl:                    s.cfg.Hodl,
 		UnsafeReplay:            s.cfg.UnsafeReplay,
+		VerifyHtlcSigs:          s.cfg.VerifyHtlcSigs,
 		MaxOutgoingCltvExpiry:   s.cfg.MaxOutgoingCltvExpiry,
 		MaxChannelFeeAllocation: s.cfg.MaxChannelFeeAllocation,
 		CoopCloseTargetConfs:    s.cfg.CoopCloseTargetConfs,
@@ -4471,6 +4480,47 @@ func (s *server) DisconnectPeer(pubKey *btcec.PublicKey) error {
 	return nil
 }
 
+// ReconnectPeer disconnects from a persistent peer, without removing it from
+// the set of persistent peers, so a new connection is established right away.
+// As all links with the peer are restarted, this triggers a fresh
+// channel_reestablish exchange for each channel with the peer.
+//
+// Non-persistent peers are refused, as the peer termination watcher only
+// reconnects to persistent peers. For any other peer we don't know an address
+// to dial, since its inbound connection is usually made from an ephemeral
+// port, so it's up to the peer to reconnect to us.
+//
+// NOTE: This function is safe for concurrent access.
+func (s *server) ReconnectPeer(pubKey *btcec.PublicKey) error {
+	pubBytes := pubKey.SerializeCompressed()
+	pubStr := string(pubBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peer, err := s.findPeerByPubStr(pubStr)
+	if err == ErrPeerNotConnected {
+		return fmt.Errorf("peer %x is not connected", pubBytes)
+	}
+
+	// Only persistent peers are reconnected to by the peer termination
+	// watcher, so we refuse to cycle the connection of any other peer.
+	if _, ok := s.persistentPeers[pubStr]; !ok {
+		return fmt.Errorf("peer %x is not a persistent peer, no "+
+			"address to reconnect to is known", pubBytes)
+	}
+
+	srvrLog.Infof("Reconnecting to %v", peer)
+
+	// Reset the backoff, so the connection isn't delayed as if the peer
+	// was flapping.
+	delete(s.persistentPeersBackoff, pubStr)
+
+	peer.Disconnect(fmt.Errorf("server: ReconnectPeer called"))
+
+	return nil
+}
+
 // OpenChannel sends a request to the server to open a channel to the specified
 // peer identified by nodeKey with the passed channel funding parameters.
 //
@@ -10,8 +10,10 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/coreos/go-systemd/daemon"
 )
@@ -111,6 +113,15 @@ type Interceptor struct {
 	// close this channel.
 	quit chan struct{}
 
+	// shutdownComplete is closed once the application has finished its
+	// graceful shutdown. It is used to disarm a pending forced shutdown.
+	shutdownComplete chan struct{}
+
+	// completeOnce makes sure shutdownComplete is only closed once. It's a
+	// pointer, as the Interceptor is passed around by value and all copies
+	// must share it.
+	completeOnce *sync.Once
+
 	// Notifier handles sending shutdown notifications.
 	Notifier Notifier
 }
@@ -127,6 +138,8 @@ func Intercept() (Interceptor, error) {
 		shutdownChannel:        make(chan struct{}),
 		shutdownRequestChannel: make(chan struct{}),
 		quit:                   make(chan struct{}),
+		shutdownComplete:       make(chan struct{}),
+		completeOnce:           &sync.Once{},
 	}
 
 	signalsToCatch := []os.Signal{
@@ -222,6 +235,43 @@ func (c *Interceptor) RequestShutdown() {
 	}
 }
 
+// RequestForcedShutdown initiates a shutdown just like RequestShutdown, but
+// terminates the process if the graceful shutdown hasn't completed within the
+// given timeout. This is meant for cases where a subsystem, for example one
+// waiting on an unresponsive peer, keeps the graceful shutdown from
+// finishing. The timer is disarmed once ShutdownComplete is called.
+func (c *Interceptor) RequestForcedShutdown(timeout time.Duration) {
+	c.RequestShutdown()
+
+	timer := time.NewTimer(timeout)
+	go func() {
+		select {
+		case <-timer.C:
+			log.Warnf("Graceful shutdown did not complete within "+
+				"%v, forcing exit", timeout)
+			os.Exit(1)
+
+		case <-c.shutdownComplete:
+			timer.Stop()
+		}
+	}()
+}
+
+// ShutdownComplete signals that the application has finished its graceful
+// shutdown, which stops any pending forced shutdown from terminating the
+// process. It is safe to call this method multiple times, and on an
+// Interceptor that wasn't created by Intercept, in which case it's a no-op.
+func (c *Interceptor) ShutdownComplete() {
+	// A zero-value Interceptor has no forced shutdown to disarm.
+	if c.completeOnce == nil {
+		return
+	}
+
+	c.completeOnce.Do(func() {
+		close(c.shutdownComplete)
+	})
+}
+
 // ShutdownChannel returns the channel that will be closed once the main
 // interrupt handler has exited.
 func (c *Interceptor) ShutdownChannel() <-chan struct{} {